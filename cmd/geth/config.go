@@ -37,6 +37,7 @@ import (
 	"github.com/ethereum/go-ethereum/eth/catalyst"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/exporter"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/internal/flags"
 	"github.com/ethereum/go-ethereum/internal/version"
@@ -95,6 +96,7 @@ type gethConfig struct {
 	Eth      ethconfig.Config
 	Node     node.Config
 	Ethstats ethstatsConfig
+	Exporter exporter.Config
 	Metrics  metrics.Config
 }
 
@@ -129,9 +131,10 @@ func defaultNodeConfig() node.Config {
 func loadBaseConfig(ctx *cli.Context) gethConfig {
 	// Load defaults.
 	cfg := gethConfig{
-		Eth:     ethconfig.Defaults,
-		Node:    defaultNodeConfig(),
-		Metrics: metrics.DefaultConfig,
+		Eth:      ethconfig.Defaults,
+		Node:     defaultNodeConfig(),
+		Exporter: exporter.DefaultConfig,
+		Metrics:  metrics.DefaultConfig,
 	}
 
 	// Load config file.
@@ -162,6 +165,7 @@ func makeConfigNode(ctx *cli.Context) (*node.Node, gethConfig) {
 	if ctx.IsSet(utils.EthStatsURLFlag.Name) {
 		cfg.Ethstats.URL = ctx.String(utils.EthStatsURLFlag.Name)
 	}
+	utils.SetExporterConfig(ctx, &cfg.Exporter)
 	applyMetricConfig(ctx, &cfg)
 
 	return stack, cfg
@@ -205,6 +209,10 @@ func makeFullNode(ctx *cli.Context) (*node.Node, ethapi.Backend) {
 	if cfg.Ethstats.URL != "" {
 		utils.RegisterEthStatsService(stack, backend, cfg.Ethstats.URL)
 	}
+	// Add the chain exporter if any sink is configured.
+	if cfg.Exporter.Enabled() {
+		utils.RegisterExporterService(stack, backend, &cfg.Exporter)
+	}
 	// Configure full-sync tester service if requested
 	if ctx.IsSet(utils.SyncTargetFlag.Name) {
 		hex := hexutil.MustDecode(ctx.String(utils.SyncTargetFlag.Name))
@@ -17,11 +17,15 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
@@ -30,13 +34,16 @@ import (
 	"github.com/ethereum/go-ethereum/cmd/utils"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/zephyria"
 	"github.com/ethereum/go-ethereum/console/prompt"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state/snapshot"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/internal/era"
 	"github.com/ethereum/go-ethereum/internal/flags"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/trie"
 	"github.com/olekukonko/tablewriter"
 	"github.com/urfave/cli/v2"
@@ -69,6 +76,14 @@ Remove blockchain and state databases`,
 			dbExportCmd,
 			dbMetadataCmd,
 			dbCheckStateContentCmd,
+			dbPruneHistoryCmd,
+			dbExportEraCmd,
+			dbImportEraCmd,
+			dbVerifyEraCmd,
+			dbMigrateSchemeCmd,
+			dbExportStateDiffsCmd,
+			dbVerifyCmd,
+			dbZephyriaSnapshotsCmd,
 		},
 	}
 	dbInspectCmd = &cli.Command{
@@ -193,6 +208,169 @@ WARNING: This is a low-level operation which may cause database corruption!`,
 		}, utils.NetworkFlags, utils.DatabaseFlags),
 		Description: "Shows metadata about the chain status.",
 	}
+	dbPruneHistoryKeepFlag = &cli.Uint64Flag{
+		Name:  "keep",
+		Usage: "Number of recent blocks to retain bodies and receipts for",
+		Value: params.FullImmutabilityThreshold,
+	}
+	dbPruneHistoryCmd = &cli.Command{
+		Action: pruneHistory,
+		Name:   "prune-history",
+		Usage:  "Prune ancient block bodies and receipts, retaining the headers",
+		Flags: flags.Merge([]cli.Flag{
+			utils.SyncModeFlag,
+			dbPruneHistoryKeepFlag,
+		}, utils.NetworkFlags, utils.DatabaseFlags),
+		Description: `This command deletes the bodies and receipts of all frozen blocks older than
+the most recent --keep blocks from the ancient store. Block headers, canonical
+hashes and total difficulties are retained, the transaction indices of the
+pruned blocks are removed. Pruned blocks are not served to the network anymore.`,
+	}
+	dbEraNetworkFlag = &cli.StringFlag{
+		Name:  "era.network",
+		Usage: "Network name used in the era file names",
+		Value: "polarys",
+	}
+	dbEraSizeFlag = &cli.Uint64Flag{
+		Name:  "era.size",
+		Usage: "Number of blocks per era file",
+		Value: era.MaxSize,
+	}
+	dbExportEraCmd = &cli.Command{
+		Action:    exportEra,
+		Name:      "export-era",
+		Usage:     "Export frozen chain history into era files",
+		ArgsUsage: "<dir> [<first> <last>]",
+		Flags: flags.Merge([]cli.Flag{
+			utils.SyncModeFlag,
+			dbEraNetworkFlag,
+			dbEraSizeFlag,
+		}, utils.NetworkFlags, utils.DatabaseFlags),
+		Description: `This command exports the headers, bodies, receipts and total difficulties
+of the ancient store into era files of --era.size blocks each. Every file carries
+a block index for random access and an accumulator root over the block hashes and
+total difficulties, which is also part of its name. A checksums.txt file with the
+sha256 hashes of the files is written alongside. If no range is given, the entire
+frozen history is exported.`,
+	}
+	dbImportEraCmd = &cli.Command{
+		Action:    importEra,
+		Name:      "import-era",
+		Usage:     "Import chain history from era files into a fresh database",
+		ArgsUsage: "<dir>",
+		Flags: flags.Merge([]cli.Flag{
+			utils.SyncModeFlag,
+			dbEraNetworkFlag,
+		}, utils.NetworkFlags, utils.DatabaseFlags),
+		Description: `This command verifies and imports the era files of the given network into
+the ancient store of a database initialized with 'geth init'. Afterwards the node
+continues syncing from the last imported block.`,
+	}
+	dbVerifyEraCmd = &cli.Command{
+		Action:    verifyEra,
+		Name:      "verify-era",
+		Usage:     "Verify the consistency of era files offline",
+		ArgsUsage: "<dir>",
+		Flags:     []cli.Flag{dbEraNetworkFlag},
+		Description: `This command checks that the era files of the given network link up, that
+the bodies and receipts match their headers and that the accumulator roots are
+correct. The reported roots should be compared against a trusted source.`,
+	}
+	dbMigrateSchemeCmd = &cli.Command{
+		Action: migrateScheme,
+		Name:   "migrate-scheme",
+		Usage:  "Convert the state of a hash-scheme database to the path scheme",
+		Flags: flags.Merge([]cli.Flag{
+			utils.SyncModeFlag,
+		}, utils.NetworkFlags, utils.DatabaseFlags),
+		Description: `This command converts the persisted head state of a hash-scheme database into
+the path scheme and deletes all the legacy trie nodes, so that the node can be
+restarted with --state.scheme=path without a resync. Only the head state is kept,
+the node has to be shut down cleanly beforehand. An interrupted conversion can be
+resumed by running the command again.`,
+	}
+	dbExportStateDiffsCmd = &cli.Command{
+		Action:    exportStateDiffs,
+		Name:      "export-statediffs",
+		Usage:     "Export the stored state diffs of canonical blocks",
+		ArgsUsage: "<file> [<first> <last>]",
+		Flags: flags.Merge([]cli.Flag{
+			utils.SyncModeFlag,
+		}, utils.NetworkFlags, utils.DatabaseFlags),
+		Description: `This command writes the state diffs of the canonical blocks in the given range
+as newline delimited JSON into the file, one block per line, in the same format
+as debug_getStateDiff. If no range is given, all the retained diffs up to the
+head block are exported. Blocks without a stored diff are skipped.`,
+	}
+	dbVerifyRepairFlag = &cli.BoolFlag{
+		Name:  "repair",
+		Usage: "Rebuild the inconsistent data which can be derived from other data",
+	}
+	dbVerifyCmd = &cli.Command{
+		Action: verifyDatabase,
+		Name:   "verify",
+		Usage:  "Cross-check the consistency of the key-value store and the freezer",
+		Flags: flags.Merge([]cli.Flag{
+			utils.SyncModeFlag,
+			dbVerifyRepairFlag,
+		}, utils.NetworkFlags, utils.DatabaseFlags),
+		Description: `This command walks the canonical chain from the head block down to the genesis
+and checks that the freezer tables cover the ancient range, that the canonical
+hashes and hash to number mappings link up, that the headers, total difficulties,
+bodies and receipts are present and that the indexed transactions are looked up
+into their blocks. The Zephyria consensus snapshots are checked to decode and to
+belong to known checkpoint headers.
+
+Every problem found is reported. With --repair, the missing mappings, total
+difficulties and transaction lookups are rebuilt and broken consensus snapshots
+are deleted to be regenerated on demand. The freezer is never modified. The
+node has to be shut down beforehand.`,
+	}
+	dbZephyriaSnapshotsBelowFlag = &cli.Uint64Flag{
+		Name:  "below",
+		Usage: "Delete all the snapshots of blocks below the given number",
+	}
+	dbZephyriaSnapshotsCmd = &cli.Command{
+		Name:  "zephyria-snapshots",
+		Usage: "Inspect and manage the stored Zephyria consensus snapshots",
+		Subcommands: []*cli.Command{
+			{
+				Action: listZephyriaSnapshots,
+				Name:   "list",
+				Usage:  "List the stored consensus snapshots",
+				Flags: flags.Merge([]cli.Flag{
+					utils.SyncModeFlag,
+				}, utils.NetworkFlags, utils.DatabaseFlags),
+				Description: `This command lists the stored consensus snapshots ordered by block number,
+along with their encoding version (0 being the legacy JSON format), size and
+number of validators.`,
+			},
+			{
+				Action:    dumpZephyriaSnapshot,
+				Name:      "dump",
+				Usage:     "Dump a stored consensus snapshot as JSON",
+				ArgsUsage: "<hash | number>",
+				Flags: flags.Merge([]cli.Flag{
+					utils.SyncModeFlag,
+				}, utils.NetworkFlags, utils.DatabaseFlags),
+				Description: `This command prints the consensus snapshot stored for the given block hash, or
+for the canonical block of the given number, in the zephyria_getSnapshot format.`,
+			},
+			{
+				Action:    deleteZephyriaSnapshots,
+				Name:      "delete",
+				Usage:     "Delete stored consensus snapshots",
+				ArgsUsage: "[<hash | number> ...]",
+				Flags: flags.Merge([]cli.Flag{
+					utils.SyncModeFlag,
+					dbZephyriaSnapshotsBelowFlag,
+				}, utils.NetworkFlags, utils.DatabaseFlags),
+				Description: `This command deletes the consensus snapshots stored for the given blocks, or
+all the ones below the block number given with --below. The engine regenerates
+deleted snapshots from the headers when needed.`,
+			},
+		},
+	}
 )
 
 func removeDB(ctx *cli.Context) error {
@@ -695,6 +873,141 @@ func exportChaindata(ctx *cli.Context) error {
 	return utils.ExportChaindata(ctx.Args().Get(1), kind, exporter(db), stop)
 }
 
+func pruneHistory(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, false)
+	defer db.Close()
+
+	head := rawdb.ReadHeadBlock(db)
+	if head == nil {
+		return errors.New("failed to load head block")
+	}
+	keep := ctx.Uint64(dbPruneHistoryKeepFlag.Name)
+	if head.NumberU64() < keep {
+		log.Info("Chain history shorter than retention, nothing to prune", "head", head.NumberU64(), "keep", keep)
+		return nil
+	}
+	var (
+		interrupt = make(chan os.Signal, 1)
+		stop      = make(chan struct{})
+	)
+	signal.Notify(interrupt, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+	defer close(interrupt)
+	go func() {
+		if _, ok := <-interrupt; ok {
+			log.Info("Interrupted during history pruning, stopping at next batch")
+		}
+		close(stop)
+	}()
+	tail, err := rawdb.PruneHistory(db, head.NumberU64()-keep+1, stop)
+	if err != nil {
+		return err
+	}
+	log.Info("Chain history available", "tail", tail, "head", head.NumberU64())
+	return nil
+}
+
+func exportEra(ctx *cli.Context) error {
+	if ctx.NArg() != 1 && ctx.NArg() != 3 {
+		return fmt.Errorf("required arguments: %v", ctx.Command.ArgsUsage)
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, true)
+	defer db.Close()
+
+	var first, last uint64
+	if ctx.NArg() == 3 {
+		var err error
+		if first, err = strconv.ParseUint(ctx.Args().Get(1), 10, 64); err != nil {
+			return fmt.Errorf("invalid first block: %v", err)
+		}
+		if last, err = strconv.ParseUint(ctx.Args().Get(2), 10, 64); err != nil {
+			return fmt.Errorf("invalid last block: %v", err)
+		}
+	} else {
+		frozen, err := db.Ancients()
+		if err != nil {
+			return err
+		}
+		if frozen == 0 {
+			return errors.New("no frozen history to export")
+		}
+		if first, err = db.Tail(); err != nil {
+			return err
+		}
+		last = frozen - 1
+
+		// Start from the first complete epoch of pruned history, so the files
+		// are named after the epochs they hold
+		if step := ctx.Uint64(dbEraSizeFlag.Name); step > 0 && first%step != 0 {
+			first += step - first%step
+			if first > last {
+				return fmt.Errorf("no complete era of %d blocks in the frozen history", step)
+			}
+			log.Info("Skipping partial era of pruned history", "first", first)
+		}
+	}
+	var (
+		interrupt = make(chan os.Signal, 1)
+		stop      = make(chan struct{})
+	)
+	signal.Notify(interrupt, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+	defer close(interrupt)
+	go func() {
+		if _, ok := <-interrupt; ok {
+			log.Info("Interrupted during era export, stopping at next file")
+		}
+		close(stop)
+	}()
+
+	return utils.ExportHistory(db, ctx.Args().Get(0), ctx.String(dbEraNetworkFlag.Name), first, last, ctx.Uint64(dbEraSizeFlag.Name), stop)
+}
+
+func importEra(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("required arguments: %v", ctx.Command.ArgsUsage)
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, false)
+	defer db.Close()
+
+	var (
+		interrupt = make(chan os.Signal, 1)
+		stop      = make(chan struct{})
+	)
+	signal.Notify(interrupt, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+	defer close(interrupt)
+	go func() {
+		if _, ok := <-interrupt; ok {
+			log.Info("Interrupted during era import, stopping at next file")
+		}
+		close(stop)
+	}()
+
+	return utils.ImportHistory(db, ctx.Args().Get(0), ctx.String(dbEraNetworkFlag.Name), stop)
+}
+
+func verifyEra(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("required arguments: %v", ctx.Command.ArgsUsage)
+	}
+	roots, err := utils.VerifyHistory(ctx.Args().Get(0), ctx.String(dbEraNetworkFlag.Name))
+	if err != nil {
+		return err
+	}
+	log.Info("Era files verified", "files", len(roots))
+	return nil
+}
+
 func showMetaData(ctx *cli.Context) error {
 	stack, _ := makeConfigNode(ctx)
 	defer stack.Close()
@@ -707,6 +1020,9 @@ func showMetaData(ctx *cli.Context) error {
 	}
 	data := rawdb.ReadChainMetadata(db)
 	data = append(data, []string{"frozen", fmt.Sprintf("%d items", ancients)})
+	if tail, err := db.Tail(); err == nil && tail > 0 {
+		data = append(data, []string{"historyTail", fmt.Sprintf("%d", tail)})
+	}
 	data = append(data, []string{"snapshotGenerator", snapshot.ParseGeneratorStatus(rawdb.ReadSnapshotGenerator(db))})
 	if b := rawdb.ReadHeadBlock(db); b != nil {
 		data = append(data, []string{"headBlock.Hash", fmt.Sprintf("%v", b.Hash())})
@@ -724,3 +1040,251 @@ func showMetaData(ctx *cli.Context) error {
 	table.Render()
 	return nil
 }
+
+func migrateScheme(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, false)
+	defer db.Close()
+
+	var (
+		interrupt = make(chan os.Signal, 1)
+		stop      = make(chan struct{})
+	)
+	signal.Notify(interrupt, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+	defer close(interrupt)
+	go func() {
+		if _, ok := <-interrupt; ok {
+			log.Info("Interrupted during state migration, stopping at next batch")
+		}
+		close(stop)
+	}()
+	return utils.MigrateStateScheme(db, stop)
+}
+
+func exportStateDiffs(ctx *cli.Context) error {
+	if ctx.NArg() != 1 && ctx.NArg() != 3 {
+		return fmt.Errorf("required arguments: %v", ctx.Command.ArgsUsage)
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, true)
+	defer db.Close()
+
+	var first, last uint64
+	if ctx.NArg() == 3 {
+		var err error
+		if first, err = strconv.ParseUint(ctx.Args().Get(1), 10, 64); err != nil {
+			return fmt.Errorf("invalid first block: %v", err)
+		}
+		if last, err = strconv.ParseUint(ctx.Args().Get(2), 10, 64); err != nil {
+			return fmt.Errorf("invalid last block: %v", err)
+		}
+	} else {
+		tail := rawdb.ReadStateDiffTail(db)
+		if tail == nil {
+			return errors.New("no state diffs stored")
+		}
+		head := rawdb.ReadHeadBlock(db)
+		if head == nil {
+			return errors.New("failed to load head block")
+		}
+		first, last = *tail, head.NumberU64()
+	}
+	if first > last {
+		return fmt.Errorf("invalid range %d-%d", first, last)
+	}
+	file, err := os.Create(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var (
+		writer   = bufio.NewWriter(file)
+		encoder  = json.NewEncoder(writer)
+		start    = time.Now()
+		logged   = time.Now()
+		exported int
+	)
+	for number := first; number <= last; number++ {
+		hash := rawdb.ReadCanonicalHash(db, number)
+		if hash == (common.Hash{}) {
+			return fmt.Errorf("canonical hash of block #%d missing", number)
+		}
+		diff := rawdb.ReadStateDiff(db, hash, number)
+		if diff == nil {
+			continue
+		}
+		if err := encoder.Encode(diff); err != nil {
+			return err
+		}
+		exported++
+
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Exporting state diffs", "number", number, "exported", exported, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	log.Info("Exported state diffs", "first", first, "last", last, "exported", exported, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+func verifyDatabase(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	repair := ctx.Bool(dbVerifyRepairFlag.Name)
+	db := utils.MakeChainDatabase(ctx, stack, !repair)
+	defer db.Close()
+
+	start := time.Now()
+	chain, err := rawdb.VerifyChain(db, repair)
+	if err != nil {
+		return err
+	}
+	snaps, err := zephyria.VerifySnapshots(db, repair)
+	if err != nil {
+		return err
+	}
+	var (
+		problems = chain.Problems + snaps.Problems
+		repaired = chain.Repaired + snaps.Repaired
+	)
+	log.Info("Database verification finished", "problems", problems, "repaired", repaired, "elapsed", common.PrettyDuration(time.Since(start)))
+	if problems > repaired {
+		return fmt.Errorf("%d problems left unrepaired", problems-repaired)
+	}
+	return nil
+}
+
+// parseZephyriaSnapshotArg resolves a snapshot argument, either a block hash or
+// the number of a canonical block, into the block hash.
+func parseZephyriaSnapshotArg(db ethdb.Database, arg string) (common.Hash, error) {
+	if strings.HasPrefix(arg, "0x") && len(arg) == 2+2*common.HashLength {
+		return common.HexToHash(arg), nil
+	}
+	number, err := strconv.ParseUint(arg, 10, 64)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("invalid block hash or number %q", arg)
+	}
+	hash := rawdb.ReadCanonicalHash(db, number)
+	if hash == (common.Hash{}) {
+		return common.Hash{}, fmt.Errorf("canonical block #%d not found", number)
+	}
+	return hash, nil
+}
+
+func listZephyriaSnapshots(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, true)
+	defer db.Close()
+
+	var snaps []*zephyria.StoredSnapshot
+	if err := zephyria.IterateSnapshots(db, func(stored *zephyria.StoredSnapshot) bool {
+		snaps = append(snaps, stored)
+		return true
+	}); err != nil {
+		return err
+	}
+	sort.Slice(snaps, func(i, j int) bool {
+		if snaps[i].Snapshot == nil || snaps[j].Snapshot == nil {
+			return snaps[j].Snapshot != nil
+		}
+		return snaps[i].Snapshot.Number < snaps[j].Snapshot.Number
+	})
+	var data [][]string
+	for _, stored := range snaps {
+		number, validators := "-", "-"
+		if stored.Snapshot != nil {
+			number = fmt.Sprint(stored.Snapshot.Number)
+			validators = fmt.Sprint(len(stored.Snapshot.Validators))
+		}
+		data = append(data, []string{number, stored.Hash.Hex(), fmt.Sprint(stored.Version), common.StorageSize(stored.Size).String(), validators})
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Number", "Hash", "Version", "Size", "Validators"})
+	table.SetFooter([]string{"", "", "", "Total", fmt.Sprint(len(snaps))})
+	table.AppendBulk(data)
+	table.Render()
+	return nil
+}
+
+func dumpZephyriaSnapshot(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("required arguments: %v", ctx.Command.ArgsUsage)
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, true)
+	defer db.Close()
+
+	hash, err := parseZephyriaSnapshotArg(db, ctx.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	stored, err := zephyria.ReadStoredSnapshot(db, hash)
+	if err != nil {
+		return fmt.Errorf("no snapshot stored for block %x", hash)
+	}
+	if stored.Err != nil {
+		return fmt.Errorf("failed to decode snapshot of block %x: %v", hash, stored.Err)
+	}
+	out, err := json.MarshalIndent(stored.Snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+func deleteZephyriaSnapshots(ctx *cli.Context) error {
+	below := ctx.Uint64(dbZephyriaSnapshotsBelowFlag.Name)
+	if ctx.NArg() == 0 && !ctx.IsSet(dbZephyriaSnapshotsBelowFlag.Name) {
+		return fmt.Errorf("required arguments: %v or --%s", ctx.Command.ArgsUsage, dbZephyriaSnapshotsBelowFlag.Name)
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, false)
+	defer db.Close()
+
+	var hashes []common.Hash
+	for _, arg := range ctx.Args().Slice() {
+		hash, err := parseZephyriaSnapshotArg(db, arg)
+		if err != nil {
+			return err
+		}
+		hashes = append(hashes, hash)
+	}
+	if ctx.IsSet(dbZephyriaSnapshotsBelowFlag.Name) {
+		if err := zephyria.IterateSnapshots(db, func(stored *zephyria.StoredSnapshot) bool {
+			if stored.Snapshot != nil && stored.Snapshot.Number < below {
+				hashes = append(hashes, stored.Hash)
+			}
+			return true
+		}); err != nil {
+			return err
+		}
+	}
+	batch := db.NewBatch()
+	for _, hash := range hashes {
+		if err := zephyria.DeleteSnapshot(batch, hash); err != nil {
+			return err
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	log.Info("Deleted consensus snapshots", "count", len(hashes))
+	return nil
+}
@@ -60,6 +60,9 @@ var (
 		utils.PasswordFileFlag,
 		utils.BootnodesFlag,
 		utils.MinFreeDiskSpaceFlag,
+		utils.ReplicaFlag,
+		utils.ReplicaAncientFlag,
+		utils.ReplicaEndpointFlag,
 		utils.KeyStoreDirFlag,
 		utils.ExternalSignerFlag,
 		utils.NoUSBFlag,
@@ -72,6 +75,9 @@ var (
 		utils.TxPoolNoLocalsFlag,
 		utils.TxPoolJournalFlag,
 		utils.TxPoolRejournalFlag,
+		utils.TxPoolSnapshotFlag,
+		utils.TxPoolSnapshotMaxAgeFlag,
+		utils.TxPoolSnapshotLimitFlag,
 		utils.TxPoolPriceLimitFlag,
 		utils.TxPoolPriceBumpFlag,
 		utils.TxPoolAccountSlotsFlag,
@@ -79,6 +85,12 @@ var (
 		utils.TxPoolAccountQueueFlag,
 		utils.TxPoolGlobalQueueFlag,
 		utils.TxPoolLifetimeFlag,
+		utils.TxPoolSenderRateFlag,
+		utils.TxPoolSenderBurstFlag,
+		utils.TxPoolContractRateFlag,
+		utils.TxPoolContractBurstFlag,
+		utils.TxPoolGapSlotsFlag,
+		utils.TxPoolListsFlag,
 		utils.BlobPoolDataDirFlag,
 		utils.BlobPoolDataCapFlag,
 		utils.BlobPoolPriceBumpFlag,
@@ -90,6 +102,13 @@ var (
 		utils.TxLookupLimitFlag,
 		utils.TransactionHistoryFlag,
 		utils.StateHistoryFlag,
+		utils.BlockHistoryFlag,
+		utils.StateDiffFlag,
+		utils.StateDiffHistoryFlag,
+		utils.AccountHistoryFlag,
+		utils.TxHistoryFlag,
+		utils.TxHistoryInternalFlag,
+		utils.TokenIndexFlag,
 		utils.LightServeFlag,
 		utils.LightIngressFlag,
 		utils.LightEgressFlag,
@@ -110,6 +129,7 @@ var (
 		utils.CacheNoPrefetchFlag,
 		utils.CachePreimagesFlag,
 		utils.CacheLogSizeFlag,
+		utils.ParallelTxFlag,
 		utils.FDLimitFlag,
 		utils.CryptoKZGFlag,
 		utils.ListenPortFlag,
@@ -138,6 +158,13 @@ var (
 		utils.VMEnableDebugFlag,
 		utils.NetworkIdFlag,
 		utils.EthStatsURLFlag,
+		utils.ExporterFileFlag,
+		utils.ExporterFileMaxSizeFlag,
+		utils.ExporterWebhookFlag,
+		utils.ExporterWebhookRetriesFlag,
+		utils.ExporterKafkaFlag,
+		utils.ExporterKafkaTopicFlag,
+		utils.ExporterKafkaPartitionFlag,
 		utils.NoCompactionFlag,
 		utils.GpoBlocksFlag,
 		utils.GpoPercentileFlag,
@@ -161,6 +188,8 @@ var (
 		utils.GraphQLVirtualHostsFlag,
 		utils.HTTPApiFlag,
 		utils.HTTPPathPrefixFlag,
+		utils.HTTPH2CFlag,
+		utils.HTTPGRPCFlag,
 		utils.WSEnabledFlag,
 		utils.WSListenAddrFlag,
 		utils.WSPortFlag,
@@ -173,9 +202,15 @@ var (
 		utils.RPCGlobalGasCapFlag,
 		utils.RPCGlobalEVMTimeoutFlag,
 		utils.RPCGlobalTxFeeCapFlag,
+		utils.RPCLogRangeLimitFlag,
+		utils.RPCLogResultLimitFlag,
 		utils.AllowUnprotectedTxs,
 		utils.BatchRequestLimit,
 		utils.BatchResponseMaxSize,
+		utils.RPCQuotasFlag,
+		utils.RPCSlowQueryFlag,
+		utils.RPCTracingFlag,
+		utils.RPCTracingEndpointFlag,
 	}
 
 	metricsFlags = []cli.Flag{
@@ -21,6 +21,7 @@ import (
 	"context"
 	"crypto/ecdsa"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
@@ -40,6 +41,7 @@ import (
 	"github.com/ethereum/go-ethereum/common/fdlimit"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/txpool"
 	"github.com/ethereum/go-ethereum/core/txpool/legacypool"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -54,9 +56,11 @@ import (
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/ethdb/remotedb"
 	"github.com/ethereum/go-ethereum/ethstats"
+	"github.com/ethereum/go-ethereum/exporter"
 	"github.com/ethereum/go-ethereum/graphql"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/internal/flags"
+	"github.com/ethereum/go-ethereum/internal/tracing"
 	"github.com/ethereum/go-ethereum/les"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
@@ -114,6 +118,21 @@ var (
 		Usage:    "Minimum free disk space in MB, once reached triggers auto shut down (default = --cache.gc converted to MB, 0 = disabled)",
 		Category: flags.EthCategory,
 	}
+	ReplicaFlag = &flags.DirectoryFlag{
+		Name:     "replica",
+		Usage:    "Run as a read replica serving the database of the node with the given data directory (pebble and hash scheme only, on the same file system)",
+		Category: flags.EthCategory,
+	}
+	ReplicaAncientFlag = &flags.DirectoryFlag{
+		Name:     "replica.ancient",
+		Usage:    "Root directory for the ancient data of the replicated node (default = inside its chaindata)",
+		Category: flags.EthCategory,
+	}
+	ReplicaEndpointFlag = &cli.StringFlag{
+		Name:     "replica.endpoint",
+		Usage:    "RPC endpoint of the replicated node to follow its head and forward transactions to (default = IPC socket in its data directory)",
+		Category: flags.EthCategory,
+	}
 	KeyStoreDirFlag = &flags.DirectoryFlag{
 		Name:     "keystore",
 		Usage:    "Directory for the keystore (default = inside the datadir)",
@@ -283,6 +302,43 @@ var (
 		Value:    ethconfig.Defaults.TransactionHistory,
 		Category: flags.StateCategory,
 	}
+	BlockHistoryFlag = &cli.Uint64Flag{
+		Name:     "history.blocks",
+		Usage:    "Number of recent blocks to retain bodies and receipts for, headers are always kept (0 = entire chain)",
+		Value:    ethconfig.Defaults.HistoryBlocks,
+		Category: flags.StateCategory,
+	}
+	StateDiffFlag = &cli.BoolFlag{
+		Name:     "statediffs",
+		Usage:    "Store the state diff of every imported block, served via debug_getStateDiff",
+		Category: flags.StateCategory,
+	}
+	StateDiffHistoryFlag = &cli.Uint64Flag{
+		Name:     "history.statediffs",
+		Usage:    "Number of recent blocks to retain state diffs for (0 = entire chain)",
+		Value:    ethconfig.Defaults.StateDiffHistory,
+		Category: flags.StateCategory,
+	}
+	AccountHistoryFlag = &cli.BoolFlag{
+		Name:     "accounthistory",
+		Usage:    "Index the historical balances and nonces of accounts, served by eth_getBalance and eth_getTransactionCount",
+		Category: flags.StateCategory,
+	}
+	TxHistoryFlag = &cli.BoolFlag{
+		Name:     "txhistory",
+		Usage:    "Index the transactions by sender, recipient and created contract, served by eth_getTransactionsByAddress",
+		Category: flags.StateCategory,
+	}
+	TxHistoryInternalFlag = &cli.BoolFlag{
+		Name:     "txhistory.internal",
+		Usage:    "Index the transactions by the addresses called by contracts too (traces every block, slowing down import)",
+		Category: flags.StateCategory,
+	}
+	TokenIndexFlag = &cli.BoolFlag{
+		Name:     "tokenindex",
+		Usage:    "Index the ERC-20, ERC-721 and ERC-1155 token transfers and balances, served by polarys_getTokenTransfers and polarys_getTokenBalances",
+		Category: flags.StateCategory,
+	}
 	// Light server and client settings
 	LightServeFlag = &cli.IntFlag{
 		Name:     "light.serve",
@@ -341,6 +397,24 @@ var (
 		Value:    ethconfig.Defaults.TxPool.Rejournal,
 		Category: flags.TxPoolCategory,
 	}
+	TxPoolSnapshotFlag = &cli.StringFlag{
+		Name:     "txpool.snapshot",
+		Usage:    "Disk file to dump all remote transactions into on shutdown and replay on startup (empty = disabled)",
+		Value:    ethconfig.Defaults.TxPool.Snapshot,
+		Category: flags.TxPoolCategory,
+	}
+	TxPoolSnapshotMaxAgeFlag = &cli.DurationFlag{
+		Name:     "txpool.snapshot.maxage",
+		Usage:    "Maximum age of a transaction pool snapshot to still be replayed on startup",
+		Value:    ethconfig.Defaults.TxPool.SnapshotMaxAge,
+		Category: flags.TxPoolCategory,
+	}
+	TxPoolSnapshotLimitFlag = &cli.Uint64Flag{
+		Name:     "txpool.snapshot.limit",
+		Usage:    "Maximum number of transactions to snapshot and replay (0 = pool capacity)",
+		Value:    ethconfig.Defaults.TxPool.SnapshotLimit,
+		Category: flags.TxPoolCategory,
+	}
 	TxPoolPriceLimitFlag = &cli.Uint64Flag{
 		Name:     "txpool.pricelimit",
 		Usage:    "Minimum gas price tip to enforce for acceptance into the pool",
@@ -383,6 +457,42 @@ var (
 		Value:    ethconfig.Defaults.TxPool.Lifetime,
 		Category: flags.TxPoolCategory,
 	}
+	TxPoolSenderRateFlag = &cli.Float64Flag{
+		Name:     "txpool.senderrate",
+		Usage:    "Maximum number of transactions per second admitted from a single sender (0 = unlimited)",
+		Value:    ethconfig.Defaults.TxAdmission.SenderRate,
+		Category: flags.TxPoolCategory,
+	}
+	TxPoolSenderBurstFlag = &cli.Uint64Flag{
+		Name:     "txpool.senderburst",
+		Usage:    "Maximum number of transactions admitted from a single sender in a burst",
+		Value:    ethconfig.Defaults.TxAdmission.SenderBurst,
+		Category: flags.TxPoolCategory,
+	}
+	TxPoolContractRateFlag = &cli.Float64Flag{
+		Name:     "txpool.contractrate",
+		Usage:    "Maximum number of transactions per second admitted towards a single contract (0 = unlimited)",
+		Value:    ethconfig.Defaults.TxAdmission.ContractRate,
+		Category: flags.TxPoolCategory,
+	}
+	TxPoolContractBurstFlag = &cli.Uint64Flag{
+		Name:     "txpool.contractburst",
+		Usage:    "Maximum number of transactions admitted towards a single contract in a burst",
+		Value:    ethconfig.Defaults.TxAdmission.ContractBurst,
+		Category: flags.TxPoolCategory,
+	}
+	TxPoolGapSlotsFlag = &cli.Uint64Flag{
+		Name:     "txpool.gapslots",
+		Usage:    "Maximum number of nonce-gapped transactions admitted per account (0 = unlimited)",
+		Value:    ethconfig.Defaults.TxAdmission.GapSlots,
+		Category: flags.TxPoolCategory,
+	}
+	TxPoolListsFlag = &cli.StringFlag{
+		Name:     "txpool.lists",
+		Usage:    "JSON file with the allowed and denied addresses, reloadable via admin_reloadTxPoolLists",
+		Value:    ethconfig.Defaults.TxAdmission.Lists,
+		Category: flags.TxPoolCategory,
+	}
 	// Blob transaction pool settings
 	BlobPoolDataDirFlag = &cli.StringFlag{
 		Name:     "blobpool.datadir",
@@ -449,6 +559,11 @@ var (
 		Category: flags.PerfCategory,
 		Value:    ethconfig.Defaults.FilterLogCacheSize,
 	}
+	ParallelTxFlag = &cli.BoolFlag{
+		Name:     "parallel.tx",
+		Usage:    "Execute the transactions of imported blocks in parallel, re-executing the conflicting ones (more CPU, less time per block)",
+		Category: flags.PerfCategory,
+	}
 	FDLimitFlag = &cli.IntFlag{
 		Name:     "fdlimit",
 		Usage:    "Raise the open file descriptor resource limit (default = system fd limit)",
@@ -553,6 +668,18 @@ var (
 		Value:    ethconfig.Defaults.RPCTxFeeCap,
 		Category: flags.APICategory,
 	}
+	RPCLogRangeLimitFlag = &cli.Uint64Flag{
+		Name:     "rpc.logs.maxrange",
+		Usage:    "Maximum number of blocks searched by eth_getLogs and the GraphQL logs queries (0 = no limit)",
+		Value:    ethconfig.Defaults.RPCLogRangeLimit,
+		Category: flags.APICategory,
+	}
+	RPCLogResultLimitFlag = &cli.IntFlag{
+		Name:     "rpc.logs.maxresults",
+		Usage:    "Maximum number of logs returned by eth_getLogs and the GraphQL logs queries (0 = no limit)",
+		Value:    ethconfig.Defaults.RPCLogResultLimit,
+		Category: flags.APICategory,
+	}
 	// Authenticated RPC HTTP settings
 	AuthListenFlag = &cli.StringFlag{
 		Name:     "authrpc.addr",
@@ -584,6 +711,45 @@ var (
 		Usage:    "Reporting URL of a ethstats service (nodename:secret@host:port)",
 		Category: flags.MetricsCategory,
 	}
+	// Chain exporter settings
+	ExporterFileFlag = &cli.StringFlag{
+		Name:     "exporter.file",
+		Usage:    "Directory the canonical blocks are exported to as NDJSON files (relative to the data directory)",
+		Category: flags.ExporterCategory,
+	}
+	ExporterFileMaxSizeFlag = &cli.Uint64Flag{
+		Name:     "exporter.file.maxsize",
+		Usage:    "Size in megabytes beyond which a new NDJSON export file is started",
+		Value:    exporter.DefaultConfig.FileMaxSize / 1024 / 1024,
+		Category: flags.ExporterCategory,
+	}
+	ExporterWebhookFlag = &cli.StringFlag{
+		Name:     "exporter.webhook",
+		Usage:    "URL the records of the canonical blocks are posted to",
+		Category: flags.ExporterCategory,
+	}
+	ExporterWebhookRetriesFlag = &cli.IntFlag{
+		Name:     "exporter.webhook.retries",
+		Usage:    "Attempts of a webhook delivery before backing off",
+		Value:    exporter.DefaultConfig.WebhookRetries,
+		Category: flags.ExporterCategory,
+	}
+	ExporterKafkaFlag = &cli.StringFlag{
+		Name:     "exporter.kafka",
+		Usage:    "Address of the Kafka broker the canonical blocks are produced to (host:port)",
+		Category: flags.ExporterCategory,
+	}
+	ExporterKafkaTopicFlag = &cli.StringFlag{
+		Name:     "exporter.kafka.topic",
+		Usage:    "Kafka topic the records of the canonical blocks are produced to",
+		Value:    exporter.DefaultConfig.KafkaTopic,
+		Category: flags.ExporterCategory,
+	}
+	ExporterKafkaPartitionFlag = &cli.IntFlag{
+		Name:     "exporter.kafka.partition",
+		Usage:    "Partition of the Kafka topic, led by the broker",
+		Category: flags.ExporterCategory,
+	}
 	NoCompactionFlag = &cli.BoolFlag{
 		Name:     "nocompaction",
 		Usage:    "Disables db compaction after import",
@@ -650,6 +816,16 @@ var (
 		Value:    "",
 		Category: flags.APICategory,
 	}
+	HTTPH2CFlag = &cli.BoolFlag{
+		Name:     "http.h2c",
+		Usage:    "Accept HTTP/2 over cleartext TCP on the HTTP-RPC server",
+		Category: flags.APICategory,
+	}
+	HTTPGRPCFlag = &cli.BoolFlag{
+		Name:     "http.grpc",
+		Usage:    "Enable the gRPC transport on the HTTP-RPC server, serving the HTTP API modules over HTTP/2 (implies --http.h2c)",
+		Category: flags.APICategory,
+	}
 	GraphQLEnabledFlag = &cli.BoolFlag{
 		Name:     "graphql",
 		Usage:    "Enable GraphQL on the HTTP-RPC server. Note that GraphQL can only be started if an HTTP server is started as well.",
@@ -729,6 +905,27 @@ var (
 		Value:    node.DefaultConfig.BatchResponseMaxSize,
 		Category: flags.APICategory,
 	}
+	RPCQuotasFlag = &cli.StringFlag{
+		Name:     "rpc.quotas",
+		Usage:    "Path to a JSON file configuring the API keys and quotas of the HTTP and WebSocket RPC",
+		Category: flags.APICategory,
+	}
+	RPCSlowQueryFlag = &cli.DurationFlag{
+		Name:     "rpc.slowquery",
+		Usage:    "Log the RPC calls taking longer than this duration (0 = disabled)",
+		Category: flags.APICategory,
+	}
+	RPCTracingFlag = &cli.BoolFlag{
+		Name:     "rpc.tracing",
+		Usage:    "Enable exporting spans of the RPC calls to an OpenTelemetry collector",
+		Category: flags.APICategory,
+	}
+	RPCTracingEndpointFlag = &cli.StringFlag{
+		Name:     "rpc.tracing.endpoint",
+		Usage:    "OTLP/HTTP traces endpoint of the OpenTelemetry collector",
+		Value:    tracing.DefaultOTLPEndpoint,
+		Category: flags.APICategory,
+	}
 	EnablePersonal = &cli.BoolFlag{
 		Name:     "rpc.enabledeprecatedpersonal",
 		Usage:    "Enables the (deprecated) personal namespace",
@@ -1168,6 +1365,12 @@ func setHTTP(ctx *cli.Context, cfg *node.Config) {
 	if ctx.IsSet(HTTPPathPrefixFlag.Name) {
 		cfg.HTTPPathPrefix = ctx.String(HTTPPathPrefixFlag.Name)
 	}
+	if ctx.IsSet(HTTPH2CFlag.Name) {
+		cfg.HTTPH2C = ctx.Bool(HTTPH2CFlag.Name)
+	}
+	if ctx.IsSet(HTTPGRPCFlag.Name) {
+		cfg.HTTPGRPC = ctx.Bool(HTTPGRPCFlag.Name)
+	}
 	if ctx.IsSet(AllowUnprotectedTxs.Name) {
 		cfg.AllowUnprotectedTxs = ctx.Bool(AllowUnprotectedTxs.Name)
 	}
@@ -1179,6 +1382,24 @@ func setHTTP(ctx *cli.Context, cfg *node.Config) {
 	if ctx.IsSet(BatchResponseMaxSize.Name) {
 		cfg.BatchResponseMaxSize = ctx.Int(BatchResponseMaxSize.Name)
 	}
+
+	if ctx.IsSet(RPCQuotasFlag.Name) {
+		blob, err := os.ReadFile(ctx.String(RPCQuotasFlag.Name))
+		if err != nil {
+			Fatalf("Failed to read RPC quotas: %v", err)
+		}
+		cfg.RPCQuotas = new(node.RPCQuotaConfig)
+		if err := json.Unmarshal(blob, cfg.RPCQuotas); err != nil {
+			Fatalf("Invalid RPC quotas %s: %v", ctx.String(RPCQuotasFlag.Name), err)
+		}
+	}
+
+	if ctx.IsSet(RPCSlowQueryFlag.Name) {
+		cfg.RPCSlowQueryThreshold = ctx.Duration(RPCSlowQueryFlag.Name)
+	}
+	if ctx.Bool(RPCTracingFlag.Name) {
+		cfg.RPCTracingEndpoint = ctx.String(RPCTracingEndpointFlag.Name)
+	}
 }
 
 // setGraphQL creates the GraphQL listener interface string from the set
@@ -1368,6 +1589,11 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 			cfg.MaxPeers = lightPeers
 		}
 	}
+	// Read replicas don't take part in the network, they follow the primary
+	if ctx.IsSet(ReplicaFlag.Name) {
+		cfg.MaxPeers = 0
+		cfg.NoDiscovery = true
+	}
 	if !(lightClient || lightServer) {
 		lightPeers = 0
 	}
@@ -1542,6 +1768,15 @@ func setTxPool(ctx *cli.Context, cfg *legacypool.Config) {
 	if ctx.IsSet(TxPoolRejournalFlag.Name) {
 		cfg.Rejournal = ctx.Duration(TxPoolRejournalFlag.Name)
 	}
+	if ctx.IsSet(TxPoolSnapshotFlag.Name) {
+		cfg.Snapshot = ctx.String(TxPoolSnapshotFlag.Name)
+	}
+	if ctx.IsSet(TxPoolSnapshotMaxAgeFlag.Name) {
+		cfg.SnapshotMaxAge = ctx.Duration(TxPoolSnapshotMaxAgeFlag.Name)
+	}
+	if ctx.IsSet(TxPoolSnapshotLimitFlag.Name) {
+		cfg.SnapshotLimit = ctx.Uint64(TxPoolSnapshotLimitFlag.Name)
+	}
 	if ctx.IsSet(TxPoolPriceLimitFlag.Name) {
 		cfg.PriceLimit = ctx.Uint64(TxPoolPriceLimitFlag.Name)
 	}
@@ -1565,6 +1800,27 @@ func setTxPool(ctx *cli.Context, cfg *legacypool.Config) {
 	}
 }
 
+func setTxAdmission(ctx *cli.Context, cfg *txpool.AdmissionConfig) {
+	if ctx.IsSet(TxPoolSenderRateFlag.Name) {
+		cfg.SenderRate = ctx.Float64(TxPoolSenderRateFlag.Name)
+	}
+	if ctx.IsSet(TxPoolSenderBurstFlag.Name) {
+		cfg.SenderBurst = ctx.Uint64(TxPoolSenderBurstFlag.Name)
+	}
+	if ctx.IsSet(TxPoolContractRateFlag.Name) {
+		cfg.ContractRate = ctx.Float64(TxPoolContractRateFlag.Name)
+	}
+	if ctx.IsSet(TxPoolContractBurstFlag.Name) {
+		cfg.ContractBurst = ctx.Uint64(TxPoolContractBurstFlag.Name)
+	}
+	if ctx.IsSet(TxPoolGapSlotsFlag.Name) {
+		cfg.GapSlots = ctx.Uint64(TxPoolGapSlotsFlag.Name)
+	}
+	if ctx.IsSet(TxPoolListsFlag.Name) {
+		cfg.Lists = ctx.String(TxPoolListsFlag.Name)
+	}
+}
+
 func setMiner(ctx *cli.Context, cfg *miner.Config) {
 	if ctx.IsSet(MinerExtraDataFlag.Name) {
 		cfg.ExtraData = []byte(ctx.String(MinerExtraDataFlag.Name))
@@ -1663,6 +1919,7 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	setEtherbase(ctx, cfg)
 	setGPO(ctx, &cfg.GPO, ctx.String(SyncModeFlag.Name) == "light")
 	setTxPool(ctx, &cfg.TxPool)
+	setTxAdmission(ctx, &cfg.TxAdmission)
 	setMiner(ctx, &cfg.Miner)
 	setRequiredBlocks(ctx, cfg)
 	setLes(ctx, cfg)
@@ -1702,6 +1959,14 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.IsSet(AncientFlag.Name) {
 		cfg.DatabaseFreezer = ctx.String(AncientFlag.Name)
 	}
+	if ctx.IsSet(ReplicaFlag.Name) {
+		if ctx.Bool(MiningEnabledFlag.Name) {
+			Fatalf("Mining is not supported on a read replica")
+		}
+		cfg.ReplicaOf = ctx.String(ReplicaFlag.Name)
+		cfg.ReplicaAncient = ctx.String(ReplicaAncientFlag.Name)
+		cfg.ReplicaEndpoint = ctx.String(ReplicaEndpointFlag.Name)
+	}
 
 	if gcmode := ctx.String(GCModeFlag.Name); gcmode != "full" && gcmode != "archive" {
 		Fatalf("--%s must be either 'full' or 'archive'", GCModeFlag.Name)
@@ -1712,6 +1977,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.IsSet(CacheNoPrefetchFlag.Name) {
 		cfg.NoPrefetch = ctx.Bool(CacheNoPrefetchFlag.Name)
 	}
+	if ctx.IsSet(ParallelTxFlag.Name) {
+		cfg.ParallelTx = ctx.Bool(ParallelTxFlag.Name)
+	}
 	// Read the value from the flag no matter if it's set or not.
 	cfg.Preimages = ctx.Bool(CachePreimagesFlag.Name)
 	if cfg.NoPruning && !cfg.Preimages {
@@ -1746,6 +2014,31 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 		cfg.TransactionHistory = 0
 		log.Warn("Disabled transaction unindexing for archive node")
 	}
+	if ctx.IsSet(BlockHistoryFlag.Name) {
+		cfg.HistoryBlocks = ctx.Uint64(BlockHistoryFlag.Name)
+	}
+	if ctx.String(GCModeFlag.Name) == "archive" && cfg.HistoryBlocks != 0 {
+		cfg.HistoryBlocks = 0
+		log.Warn("Disabled chain history pruning for archive node")
+	}
+	if ctx.IsSet(StateDiffFlag.Name) {
+		cfg.StateDiffs = ctx.Bool(StateDiffFlag.Name)
+	}
+	if ctx.IsSet(StateDiffHistoryFlag.Name) {
+		cfg.StateDiffHistory = ctx.Uint64(StateDiffHistoryFlag.Name)
+	}
+	if ctx.IsSet(AccountHistoryFlag.Name) {
+		cfg.AccountHistory = ctx.Bool(AccountHistoryFlag.Name)
+	}
+	if ctx.IsSet(TxHistoryFlag.Name) {
+		cfg.TxHistory = ctx.Bool(TxHistoryFlag.Name)
+	}
+	if ctx.IsSet(TxHistoryInternalFlag.Name) {
+		cfg.TxHistoryInternal = ctx.Bool(TxHistoryInternalFlag.Name)
+	}
+	if ctx.IsSet(TokenIndexFlag.Name) {
+		cfg.TokenIndex = ctx.Bool(TokenIndexFlag.Name)
+	}
 	if ctx.IsSet(LightServeFlag.Name) && cfg.TransactionHistory != 0 {
 		log.Warn("LES server cannot serve old transaction status and cannot connect below les/4 protocol version if transaction lookup index is limited")
 	}
@@ -1792,6 +2085,12 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.IsSet(RPCGlobalTxFeeCapFlag.Name) {
 		cfg.RPCTxFeeCap = ctx.Float64(RPCGlobalTxFeeCapFlag.Name)
 	}
+	if ctx.IsSet(RPCLogRangeLimitFlag.Name) {
+		cfg.RPCLogRangeLimit = ctx.Uint64(RPCLogRangeLimitFlag.Name)
+	}
+	if ctx.IsSet(RPCLogResultLimitFlag.Name) {
+		cfg.RPCLogResultLimit = ctx.Int(RPCLogResultLimitFlag.Name)
+	}
 	if ctx.IsSet(NoDiscoverFlag.Name) {
 		cfg.EthDiscoveryURLs, cfg.SnapDiscoveryURLs = []string{}, []string{}
 	} else if ctx.IsSet(DNSDiscoveryFlag.Name) {
@@ -1952,6 +2251,39 @@ func RegisterEthStatsService(stack *node.Node, backend ethapi.Backend, url strin
 	}
 }
 
+// SetExporterConfig applies the chain exporter related command line flags to the
+// config.
+func SetExporterConfig(ctx *cli.Context, cfg *exporter.Config) {
+	if ctx.IsSet(ExporterFileFlag.Name) {
+		cfg.File = ctx.String(ExporterFileFlag.Name)
+	}
+	if ctx.IsSet(ExporterFileMaxSizeFlag.Name) {
+		cfg.FileMaxSize = ctx.Uint64(ExporterFileMaxSizeFlag.Name) * 1024 * 1024
+	}
+	if ctx.IsSet(ExporterWebhookFlag.Name) {
+		cfg.Webhook = ctx.String(ExporterWebhookFlag.Name)
+	}
+	if ctx.IsSet(ExporterWebhookRetriesFlag.Name) {
+		cfg.WebhookRetries = ctx.Int(ExporterWebhookRetriesFlag.Name)
+	}
+	if ctx.IsSet(ExporterKafkaFlag.Name) {
+		cfg.Kafka = ctx.String(ExporterKafkaFlag.Name)
+	}
+	if ctx.IsSet(ExporterKafkaTopicFlag.Name) {
+		cfg.KafkaTopic = ctx.String(ExporterKafkaTopicFlag.Name)
+	}
+	if ctx.IsSet(ExporterKafkaPartitionFlag.Name) {
+		cfg.KafkaPartition = int32(ctx.Int(ExporterKafkaPartitionFlag.Name))
+	}
+}
+
+// RegisterExporterService configures the chain exporter and adds it to the node.
+func RegisterExporterService(stack *node.Node, backend ethapi.Backend, cfg *exporter.Config) {
+	if err := exporter.New(stack, backend, cfg); err != nil {
+		Fatalf("Failed to register the chain exporter: %v", err)
+	}
+}
+
 // RegisterGraphQLService adds the GraphQL API to the node.
 func RegisterGraphQLService(stack *node.Node, backend ethapi.Backend, filterSystem *filters.FilterSystem, cfg *node.Config) {
 	err := graphql.New(stack, backend, filterSystem, cfg.GraphQLCors, cfg.GraphQLVirtualHosts)
@@ -1965,6 +2297,8 @@ func RegisterFilterAPI(stack *node.Node, backend ethapi.Backend, ethcfg *ethconf
 	isLightClient := ethcfg.SyncMode == downloader.LightSync
 	filterSystem := filters.NewFilterSystem(backend, filters.Config{
 		LogCacheSize: ethcfg.FilterLogCacheSize,
+		RangeLimit:   ethcfg.RPCLogRangeLimit,
+		ResultLimit:  ethcfg.RPCLogResultLimit,
 	})
 	stack.RegisterAPIs([]rpc.API{{
 		Namespace: "eth",
@@ -2172,6 +2506,7 @@ func MakeChain(ctx *cli.Context, stack *node.Node, readonly bool) (*core.BlockCh
 	cache := &core.CacheConfig{
 		TrieCleanLimit:      ethconfig.Defaults.TrieCleanCache,
 		TrieCleanNoPrefetch: ctx.Bool(CacheNoPrefetchFlag.Name),
+		ParallelTx:          ctx.Bool(ParallelTxFlag.Name),
 		TrieDirtyLimit:      ethconfig.Defaults.TrieDirtyCache,
 		TrieDirtyDisabled:   ctx.String(GCModeFlag.Name) == "archive",
 		TrieTimeLimit:       ethconfig.Defaults.TrieTimeout,
@@ -0,0 +1,446 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/internal/era"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// ExportHistory exports the frozen chain segment [first, last] from the ancient
+// store into era files of step blocks each, together with a checksums.txt file
+// listing their sha256 hashes. The checksums of files exported previously into
+// the same directory are retained.
+func ExportHistory(db ethdb.Database, dir, network string, first, last, step uint64, interrupt chan struct{}) error {
+	log.Info("Exporting chain history", "dir", dir, "first", first, "last", last)
+
+	if step == 0 || step > era.MaxSize {
+		return fmt.Errorf("invalid era size %d, must be in [1, %d]", step, era.MaxSize)
+	}
+	if first%step != 0 {
+		return fmt.Errorf("first block %d not aligned to era size %d", first, step)
+	}
+	if first > last {
+		return fmt.Errorf("invalid range [%d, %d]", first, last)
+	}
+	frozen, err := db.Ancients()
+	if err != nil {
+		return err
+	}
+	if last >= frozen {
+		return fmt.Errorf("block %d not frozen yet, only %d ancient blocks available", last, frozen)
+	}
+	if tail, err := db.Tail(); err != nil {
+		return err
+	} else if first < tail {
+		return fmt.Errorf("block %d pruned from the ancient store, history starts at %d", first, tail)
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return fmt.Errorf("error creating output directory: %w", err)
+	}
+	checksums, err := readChecksums(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		checksums = make(map[string]string)
+	} else if err != nil {
+		return err
+	}
+	var (
+		start    = time.Now()
+		reported = time.Now()
+		exported int
+	)
+	for from := first; from <= last; from += step {
+		select {
+		case <-interrupt:
+			return errors.New("interrupted")
+		default:
+		}
+		to := from + step - 1
+		if to > last {
+			to = last
+		}
+		name, checksum, err := exportEra(db, dir, network, int(from/step), from, to)
+		if err != nil {
+			return err
+		}
+		checksums[name] = checksum
+		exported++
+
+		if time.Since(reported) >= 8*time.Second {
+			log.Info("Exporting chain history", "exported", to-first+1, "file", name, "elapsed", common.PrettyDuration(time.Since(start)))
+			reported = time.Now()
+		}
+	}
+	names := make([]string, 0, len(checksums))
+	for name := range checksums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := make([]string, len(names))
+	for i, name := range names {
+		lines[i] = fmt.Sprintf("%s %s", checksums[name], name)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "checksums.txt"), []byte(strings.Join(lines, "\n")+"\n"), os.ModePerm); err != nil {
+		return err
+	}
+	log.Info("Exported chain history", "dir", dir, "files", exported, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// exportEra writes the frozen blocks [from, to] into a single era file, named
+// after the epoch and the resulting accumulator. The file name and its sha256
+// checksum are returned.
+func exportEra(db ethdb.Database, dir, network string, epoch int, from, to uint64) (string, string, error) {
+	f, err := os.CreateTemp(dir, "era-*.tmp")
+	if err != nil {
+		return "", "", err
+	}
+	defer os.Remove(f.Name()) // noop if renamed
+
+	builder := era.NewBuilder(f)
+	for n := from; n <= to; n++ {
+		var (
+			hash, header, body, receipts, diff []byte
+			td                                 = new(big.Int)
+		)
+		err := db.ReadAncients(func(op ethdb.AncientReaderOp) (err error) {
+			if hash, err = op.Ancient(rawdb.ChainFreezerHashTable, n); err != nil {
+				return err
+			}
+			if header, err = op.Ancient(rawdb.ChainFreezerHeaderTable, n); err != nil {
+				return err
+			}
+			if body, err = op.Ancient(rawdb.ChainFreezerBodiesTable, n); err != nil {
+				return err
+			}
+			if receipts, err = op.Ancient(rawdb.ChainFreezerReceiptTable, n); err != nil {
+				return err
+			}
+			diff, err = op.Ancient(rawdb.ChainFreezerDifficultyTable, n)
+			return err
+		})
+		if err != nil {
+			f.Close()
+			return "", "", fmt.Errorf("failed to read ancient block %d: %w", n, err)
+		}
+		if err := rlp.DecodeBytes(diff, td); err != nil {
+			f.Close()
+			return "", "", fmt.Errorf("invalid total difficulty of block %d: %w", n, err)
+		}
+		if err := builder.AddRLP(header, body, receipts, n, common.BytesToHash(hash), td); err != nil {
+			f.Close()
+			return "", "", err
+		}
+	}
+	root, err := builder.Finalize()
+	if err != nil {
+		f.Close()
+		return "", "", err
+	}
+	// Checksum the finished file before moving it into place
+	hasher := sha256.New()
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return "", "", err
+	}
+	if _, err := io.Copy(hasher, f); err != nil {
+		f.Close()
+		return "", "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", "", err
+	}
+	name := era.Filename(network, epoch, root)
+	if err := os.Rename(f.Name(), filepath.Join(dir, name)); err != nil {
+		return "", "", err
+	}
+	return name, common.Bytes2Hex(hasher.Sum(nil)), nil
+}
+
+// VerifyHistory checks all era files of the given network in the directory
+// against their checksums, returning the accumulator roots in epoch order.
+func VerifyHistory(dir, network string) ([]common.Hash, error) {
+	files, err := era.ReadDir(dir, network)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no era files found for network %s", network)
+	}
+	checksums, err := readChecksums(dir)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		roots []common.Hash
+		prev  *types.Header
+	)
+	for _, name := range files {
+		if err := verifyChecksum(dir, name, checksums); err != nil {
+			return nil, err
+		}
+		root, first, last, err := verifyEra(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if prev != nil && (first.Number.Uint64() != prev.Number.Uint64()+1 || first.ParentHash != prev.Hash()) {
+			return nil, fmt.Errorf("%s: not contiguous with previous era file", name)
+		}
+		log.Info("Verified era file", "file", name, "first", first.Number, "last", last.Number, "accumulator", root)
+		roots, prev = append(roots, root), last
+	}
+	return roots, nil
+}
+
+// readChecksums reads the sha256 checksums listed in the checksums.txt file of
+// an era directory, keyed by file name.
+func readChecksums(dir string) (map[string]string, error) {
+	blob, err := os.ReadFile(filepath.Join(dir, "checksums.txt"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read era checksums: %w", err)
+	}
+	checksums := make(map[string]string)
+	for _, line := range strings.Split(string(blob), "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid era checksum line %q", line)
+		}
+		checksums[fields[1]] = fields[0]
+	}
+	return checksums, nil
+}
+
+// verifyChecksum checks the sha256 checksum of an era file against the listed
+// one, rejecting unlisted files.
+func verifyChecksum(dir, name string, checksums map[string]string) error {
+	want, ok := checksums[name]
+	if !ok {
+		return fmt.Errorf("%s: not listed in checksums", name)
+	}
+	f, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return err
+	}
+	if have := common.Bytes2Hex(hasher.Sum(nil)); have != want {
+		return fmt.Errorf("%s: checksum mismatch: have %s, want %s", name, have, want)
+	}
+	return nil
+}
+
+// verifyEra checks the consistency of an era file, also matching the accumulator
+// against the file name. The accumulator and the boundary headers are returned.
+func verifyEra(path string) (common.Hash, *types.Header, *types.Header, error) {
+	e, err := era.Open(path)
+	if err != nil {
+		return common.Hash{}, nil, nil, err
+	}
+	defer e.Close()
+
+	root, err := era.Verify(e)
+	if err != nil {
+		return common.Hash{}, nil, nil, err
+	}
+	if !strings.HasSuffix(filepath.Base(path), fmt.Sprintf("-%s.era1", root.Hex()[2:10])) {
+		return common.Hash{}, nil, nil, fmt.Errorf("accumulator %x does not match file name", root)
+	}
+	first, err := readEraHeader(e, e.Start())
+	if err != nil {
+		return common.Hash{}, nil, nil, err
+	}
+	last, err := readEraHeader(e, e.Start()+e.Count()-1)
+	if err != nil {
+		return common.Hash{}, nil, nil, err
+	}
+	return root, first, last, nil
+}
+
+// readEraHeader decodes the header of the given block from an era file.
+func readEraHeader(e *era.Era, num uint64) (*types.Header, error) {
+	raw, _, _, _, err := e.GetRawByNumber(num)
+	if err != nil {
+		return nil, err
+	}
+	header := new(types.Header)
+	if err := rlp.DecodeBytes(raw, header); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// ImportHistory imports all era files of the given network in the directory into
+// the ancient store of a freshly initialized database. Every file is verified,
+// also against its listed checksum, before it is written, files which were
+// imported previously are skipped. The files have to continue the ancient
+// store by number and parent hash, an empty one starting at genesis.
+//
+// After the import, the imported chain segment is treated like the result of an
+// interrupted snap sync: the head header and head snap block are moved to the
+// last imported block, while the head block remains at genesis.
+func ImportHistory(db ethdb.Database, dir, network string, interrupt chan struct{}) error {
+	files, err := era.ReadDir(dir, network)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no era files found for network %s", network)
+	}
+	head := rawdb.ReadHeadBlock(db)
+	if head == nil {
+		return errors.New("database not initialized, run geth init first")
+	}
+	frozen, err := db.Ancients()
+	if err != nil {
+		return err
+	}
+	if head.NumberU64() != 0 {
+		return fmt.Errorf("history import requires a fresh database, head block is %d", head.NumberU64())
+	}
+	if header := rawdb.ReadHeadHeader(db); header == nil || (header.Number.Uint64() != 0 && header.Number.Uint64()+1 != frozen) {
+		return errors.New("history import requires a fresh database, headers beyond the ancient store exist")
+	}
+	checksums, err := readChecksums(dir)
+	if err != nil {
+		return err
+	}
+	var (
+		start    = time.Now()
+		reported = time.Now()
+		imported uint64
+	)
+	for _, name := range files {
+		select {
+		case <-interrupt:
+			return errors.New("interrupted")
+		default:
+		}
+		if err := verifyChecksum(dir, name, checksums); err != nil {
+			return err
+		}
+		path := filepath.Join(dir, name)
+		root, first, last, err := verifyEra(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if frozen, err = db.Ancients(); err != nil {
+			return err
+		}
+		if last.Number.Uint64() < frozen {
+			log.Debug("Skipping imported era file", "file", name)
+			continue
+		}
+		if first.Number.Uint64() != frozen {
+			return fmt.Errorf("%s: starts at block %d, ancient store ends at %d", name, first.Number, frozen)
+		}
+		// Make sure the file links into the local chain
+		if frozen == 0 {
+			if genesis := rawdb.ReadCanonicalHash(db, 0); first.Hash() != genesis {
+				return fmt.Errorf("%s: genesis mismatch: have %x, want %x", name, first.Hash(), genesis)
+			}
+		} else if parent := rawdb.ReadCanonicalHash(db, frozen-1); first.ParentHash != parent {
+			return fmt.Errorf("%s: parent mismatch: have %x, want %x", name, first.ParentHash, parent)
+		}
+		if err := importEra(db, path); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		imported += last.Number.Uint64() - first.Number.Uint64() + 1
+
+		if time.Since(reported) >= 8*time.Second {
+			log.Info("Importing chain history", "imported", imported, "file", name, "accumulator", root, "elapsed", common.PrettyDuration(time.Since(start)))
+			reported = time.Now()
+		}
+	}
+	log.Info("Imported chain history", "blocks", imported, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// importEra appends the blocks of an already verified era file to the ancient
+// store and links them into the key-value store.
+func importEra(db ethdb.Database, path string) error {
+	e, err := era.Open(path)
+	if err != nil {
+		return err
+	}
+	defer e.Close()
+
+	var (
+		batch = db.NewBatch()
+		hash  common.Hash
+	)
+	_, err = db.ModifyAncients(func(op ethdb.AncientWriteOp) error {
+		for n := e.Start(); n < e.Start()+e.Count(); n++ {
+			header, body, receipts, td, err := e.GetRawByNumber(n)
+			if err != nil {
+				return err
+			}
+			hash = crypto.Keccak256Hash(header)
+			if err := op.AppendRaw(rawdb.ChainFreezerHashTable, n, hash[:]); err != nil {
+				return err
+			}
+			if err := op.AppendRaw(rawdb.ChainFreezerHeaderTable, n, header); err != nil {
+				return err
+			}
+			if err := op.AppendRaw(rawdb.ChainFreezerBodiesTable, n, body); err != nil {
+				return err
+			}
+			if err := op.AppendRaw(rawdb.ChainFreezerReceiptTable, n, receipts); err != nil {
+				return err
+			}
+			if err := op.Append(rawdb.ChainFreezerDifficultyTable, n, td); err != nil {
+				return err
+			}
+			rawdb.WriteHeaderNumber(batch, hash, n)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := db.Sync(); err != nil {
+		return err
+	}
+	rawdb.WriteHeadHeaderHash(batch, hash)
+	rawdb.WriteHeadFastBlockHash(batch, hash)
+	return batch.Write()
+}
@@ -0,0 +1,240 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// newHistoryTestChain creates a database with a chain of 40 blocks frozen into
+// its ancient store.
+func newHistoryTestChain(t *testing.T) (ethdb.Database, *core.Genesis, []*types.Block) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		genesis = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{address: {Balance: big.NewInt(1000000000000000000)}},
+		}
+		signer = types.LatestSigner(genesis.Config)
+	)
+	_, blocks, receipts := core.GenerateChainWithGenesis(genesis, ethash.NewFaker(), 40, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignNewTx(key, signer, &types.LegacyTx{Nonce: uint64(i), GasPrice: b.BaseFee(), Gas: 21000, To: &common.Address{0xaa}})
+		b.AddTx(tx)
+	})
+	// Freeze the entire chain into a source database
+	src, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), t.TempDir(), "", false)
+	if err != nil {
+		t.Fatalf("failed to create source database: %v", err)
+	}
+	t.Cleanup(func() { src.Close() })
+
+	gblock := genesis.ToBlock()
+	if _, err := rawdb.WriteAncientBlocks(src, append([]*types.Block{gblock}, blocks...), append([]types.Receipts{nil}, receipts...), gblock.Difficulty()); err != nil {
+		t.Fatalf("failed to freeze chain: %v", err)
+	}
+	return src, genesis, blocks
+}
+
+// newHistoryTestDatabase creates a freshly initialized database to import the
+// history into.
+func newHistoryTestDatabase(t *testing.T, genesis *core.Genesis) ethdb.Database {
+	db, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), t.TempDir(), "", false)
+	if err != nil {
+		t.Fatalf("failed to create destination database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	genesis.MustCommit(db, trie.NewDatabase(db, trie.HashDefaults))
+	return db
+}
+
+func TestHistoryExportImport(t *testing.T) {
+	src, genesis, blocks := newHistoryTestChain(t)
+	gblock := genesis.ToBlock()
+
+	dir := t.TempDir()
+	if err := ExportHistory(src, dir, "test", 0, 40, 16, nil); err != nil {
+		t.Fatalf("failed to export history: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "checksums.txt")); err != nil {
+		t.Fatalf("missing checksums: %v", err)
+	}
+	roots, err := VerifyHistory(dir, "test")
+	if err != nil {
+		t.Fatalf("failed to verify history: %v", err)
+	}
+	if len(roots) != 3 {
+		t.Fatalf("era file count mismatch: have %d, want %d", len(roots), 3)
+	}
+	// Import the history into a freshly initialized database
+	dst := newHistoryTestDatabase(t, genesis)
+	if err := ImportHistory(dst, dir, "test", nil); err != nil {
+		t.Fatalf("failed to import history: %v", err)
+	}
+	if frozen, _ := dst.Ancients(); frozen != 41 {
+		t.Fatalf("ancient count mismatch: have %d, want %d", frozen, 41)
+	}
+	for _, block := range blocks {
+		hash := rawdb.ReadCanonicalHash(dst, block.NumberU64())
+		if hash != block.Hash() {
+			t.Fatalf("block %d: canonical hash mismatch", block.NumberU64())
+		}
+		if number := rawdb.ReadHeaderNumber(dst, hash); number == nil || *number != block.NumberU64() {
+			t.Fatalf("block %d: header number mismatch", block.NumberU64())
+		}
+		if body := rawdb.ReadBody(dst, hash, block.NumberU64()); body == nil || len(body.Transactions) != 1 {
+			t.Fatalf("block %d: body mismatch", block.NumberU64())
+		}
+		if rs := rawdb.ReadRawReceipts(dst, hash, block.NumberU64()); len(rs) != 1 {
+			t.Fatalf("block %d: receipts mismatch", block.NumberU64())
+		}
+	}
+	head := blocks[len(blocks)-1].Hash()
+	if rawdb.ReadHeadHeaderHash(dst) != head || rawdb.ReadHeadFastBlockHash(dst) != head {
+		t.Fatal("head markers not updated")
+	}
+	if rawdb.ReadHeadBlockHash(dst) != gblock.Hash() {
+		t.Fatal("head block moved away from genesis")
+	}
+	// Importing again is a noop
+	if err := ImportHistory(dst, dir, "test", nil); err != nil {
+		t.Fatalf("failed to reimport history: %v", err)
+	}
+	// Files not matching their checksums are rejected
+	checksums, err := os.ReadFile(filepath.Join(dir, "checksums.txt"))
+	if err != nil {
+		t.Fatalf("failed to read checksums: %v", err)
+	}
+	checksums = append([]byte(strings.Repeat("0", 64)), checksums[64:]...)
+	if err := os.WriteFile(filepath.Join(dir, "checksums.txt"), checksums, 0644); err != nil {
+		t.Fatalf("failed to write checksums: %v", err)
+	}
+	if _, err := VerifyHistory(dir, "test"); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("corrupt history verified: %v", err)
+	}
+	fresh := newHistoryTestDatabase(t, genesis)
+	if err := ImportHistory(fresh, dir, "test", nil); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("corrupt history imported: %v", err)
+	}
+	if frozen, _ := fresh.Ancients(); frozen != 0 {
+		t.Errorf("corrupt history partially imported: %d ancients", frozen)
+	}
+}
+
+// Tests that history exported from a block other than genesis is verified on
+// its own, but only imported on top of the preceding history.
+func TestHistoryExportRange(t *testing.T) {
+	src, genesis, _ := newHistoryTestChain(t)
+
+	head, tail := t.TempDir(), t.TempDir()
+	if err := ExportHistory(src, head, "test", 0, 15, 16, nil); err != nil {
+		t.Fatalf("failed to export history head: %v", err)
+	}
+	if err := ExportHistory(src, tail, "test", 16, 40, 16, nil); err != nil {
+		t.Fatalf("failed to export history tail: %v", err)
+	}
+	if roots, err := VerifyHistory(tail, "test"); err != nil {
+		t.Fatalf("failed to verify history tail: %v", err)
+	} else if len(roots) != 2 {
+		t.Fatalf("era file count mismatch: have %d, want %d", len(roots), 2)
+	}
+	// The tail doesn't link to genesis
+	db := newHistoryTestDatabase(t, genesis)
+	if err := ImportHistory(db, tail, "test", nil); err == nil {
+		t.Fatal("unlinked history imported")
+	}
+	if err := ImportHistory(db, head, "test", nil); err != nil {
+		t.Fatalf("failed to import history head: %v", err)
+	}
+	if err := ImportHistory(db, tail, "test", nil); err != nil {
+		t.Fatalf("failed to import history tail: %v", err)
+	}
+	if frozen, _ := db.Ancients(); frozen != 41 {
+		t.Fatalf("ancient count mismatch: have %d, want %d", frozen, 41)
+	}
+	for n := uint64(0); n <= 40; n++ {
+		if rawdb.ReadCanonicalHash(db, n) != rawdb.ReadCanonicalHash(src, n) {
+			t.Fatalf("block %d: canonical hash mismatch", n)
+		}
+	}
+	// Gaps within the run are rejected
+	files, _ := os.ReadDir(tail)
+	for _, file := range files {
+		if strings.Contains(file.Name(), "-00001-") {
+			os.Remove(filepath.Join(tail, file.Name()))
+		}
+	}
+	files, _ = os.ReadDir(head)
+	for _, file := range files {
+		if strings.HasSuffix(file.Name(), ".era1") {
+			os.Rename(filepath.Join(head, file.Name()), filepath.Join(tail, file.Name()))
+		}
+	}
+	if _, err := VerifyHistory(tail, "test"); err == nil || !strings.Contains(err.Error(), "missing epoch 1") {
+		t.Errorf("history with gap verified: %v", err)
+	}
+}
+
+// Tests that exporting into a directory holding earlier exports retains their
+// checksums, so that the combined history verifies and imports.
+func TestHistoryExportMerge(t *testing.T) {
+	src, genesis, _ := newHistoryTestChain(t)
+
+	dir := t.TempDir()
+	if err := ExportHistory(src, dir, "test", 0, 15, 16, nil); err != nil {
+		t.Fatalf("failed to export history head: %v", err)
+	}
+	if err := ExportHistory(src, dir, "test", 16, 40, 16, nil); err != nil {
+		t.Fatalf("failed to export history tail: %v", err)
+	}
+	// Re-exporting a file replaces its own checksum only
+	if err := ExportHistory(src, dir, "test", 16, 31, 16, nil); err != nil {
+		t.Fatalf("failed to re-export history: %v", err)
+	}
+	if checksums, err := readChecksums(dir); err != nil {
+		t.Fatalf("failed to read checksums: %v", err)
+	} else if len(checksums) != 3 {
+		t.Fatalf("checksum count mismatch: have %d, want %d", len(checksums), 3)
+	}
+	if roots, err := VerifyHistory(dir, "test"); err != nil {
+		t.Fatalf("failed to verify history: %v", err)
+	} else if len(roots) != 3 {
+		t.Fatalf("era file count mismatch: have %d, want %d", len(roots), 3)
+	}
+	db := newHistoryTestDatabase(t, genesis)
+	if err := ImportHistory(db, dir, "test", nil); err != nil {
+		t.Fatalf("failed to import history: %v", err)
+	}
+	if frozen, _ := db.Ancients(); frozen != 41 {
+		t.Fatalf("ancient count mismatch: have %d, want %d", frozen, 41)
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+var errMigrationInterrupted = errors.New("state migration interrupted")
+
+// MigrateStateScheme converts the persisted head state of a hash-based database
+// into the path-based scheme, then deletes all the legacy trie nodes.
+//
+// The root node of the account trie is written last, which switches the database
+// over to the path scheme. An interrupted conversion thus leaves a hash-based
+// database behind and can simply be restarted. If the database has already been
+// converted, the leftover legacy nodes are cleaned up.
+func MigrateStateScheme(db ethdb.Database, interrupt chan struct{}) error {
+	switch scheme := rawdb.ReadStateScheme(db); scheme {
+	case rawdb.PathScheme:
+		log.Info("Database already in path scheme, removing leftover legacy nodes")
+		return deleteLegacyNodes(db, interrupt)
+	case rawdb.HashScheme:
+	default:
+		return fmt.Errorf("unexpected state scheme %q", scheme)
+	}
+	head := rawdb.ReadHeadBlock(db)
+	if head == nil {
+		return errors.New("failed to load head block")
+	}
+	if !rawdb.HasLegacyTrieNode(db, head.Root()) {
+		return fmt.Errorf("head state %x of block %d not persisted, shut the node down cleanly first", head.Root(), head.NumberU64())
+	}
+	log.Info("Converting state to path scheme", "number", head.NumberU64(), "root", head.Root())
+
+	var (
+		start  = time.Now()
+		logged = time.Now()
+		triedb = trie.NewDatabase(db, trie.HashDefaults)
+		batch  = db.NewBatch()
+		nodes  int
+		size   common.StorageSize
+		root   []byte
+	)
+	flush := func(force bool) error {
+		if !force && batch.ValueSize() < ethdb.IdealBatchSize {
+			return nil
+		}
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		batch.Reset()
+
+		select {
+		case <-interrupt:
+			return errMigrationInterrupted
+		default:
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Converting state to path scheme", "nodes", nodes, "size", size, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+		return nil
+	}
+	t, err := trie.New(trie.StateTrieID(head.Root()), triedb)
+	if err != nil {
+		return err
+	}
+	accIter, err := t.NodeIterator(nil)
+	if err != nil {
+		return err
+	}
+	for accIter.Next(true) {
+		// Embedded nodes are stored as part of their parents
+		if accIter.Hash() != (common.Hash{}) {
+			blob := accIter.NodeBlob()
+			if len(accIter.Path()) == 0 {
+				root = common.CopyBytes(blob)
+			} else {
+				rawdb.WriteAccountTrieNode(batch, accIter.Path(), blob)
+			}
+			nodes, size = nodes+1, size+common.StorageSize(len(accIter.Path())+len(blob))
+		}
+		if !accIter.Leaf() {
+			continue
+		}
+		var acc types.StateAccount
+		if err := rlp.DecodeBytes(accIter.LeafBlob(), &acc); err != nil {
+			return err
+		}
+		owner := common.BytesToHash(accIter.LeafKey())
+		if acc.Root != types.EmptyRootHash {
+			storageTrie, err := trie.New(trie.StorageTrieID(head.Root(), owner, acc.Root), triedb)
+			if err != nil {
+				return err
+			}
+			storageIter, err := storageTrie.NodeIterator(nil)
+			if err != nil {
+				return err
+			}
+			for storageIter.Next(true) {
+				if storageIter.Hash() == (common.Hash{}) {
+					continue
+				}
+				blob := storageIter.NodeBlob()
+				rawdb.WriteStorageTrieNode(batch, owner, storageIter.Path(), blob)
+				nodes, size = nodes+1, size+common.StorageSize(common.HashLength+len(storageIter.Path())+len(blob))
+
+				if err := flush(false); err != nil {
+					return err
+				}
+			}
+			if err := storageIter.Error(); err != nil {
+				return err
+			}
+		}
+		// Contract codes stored by their bare hash would be deleted along
+		// with the legacy trie nodes, move them over to the prefixed keys.
+		codeHash := common.BytesToHash(acc.CodeHash)
+		if codeHash != types.EmptyCodeHash && !rawdb.HasCodeWithPrefix(db, codeHash) {
+			code := rawdb.ReadCode(db, codeHash)
+			if len(code) == 0 {
+				return fmt.Errorf("missing code %x", codeHash)
+			}
+			rawdb.WriteCode(batch, codeHash, code)
+		}
+		if err := flush(false); err != nil {
+			return err
+		}
+	}
+	if err := accIter.Error(); err != nil {
+		return err
+	}
+	if err := flush(true); err != nil {
+		return err
+	}
+	// All nodes are persisted, switch the database over by writing the root
+	if root == nil {
+		return errors.New("missing account trie root")
+	}
+	rawdb.WriteAccountTrieNode(batch, nil, root)
+	if err := flush(true); err != nil {
+		return err
+	}
+	log.Info("Converted state to path scheme", "nodes", nodes, "size", size, "elapsed", common.PrettyDuration(time.Since(start)))
+
+	return deleteLegacyNodes(db, interrupt)
+}
+
+// deleteLegacyNodes removes all the trie nodes stored in the hash-based scheme.
+func deleteLegacyNodes(db ethdb.Database, interrupt chan struct{}) error {
+	var (
+		start  = time.Now()
+		logged = time.Now()
+		batch  = db.NewBatch()
+		iter   = db.NewIterator(nil, nil)
+		count  int
+		size   common.StorageSize
+	)
+	defer func() { iter.Release() }()
+
+	for iter.Next() {
+		key := iter.Key()
+		if len(key) != common.HashLength {
+			continue
+		}
+		batch.Delete(key)
+		count, size = count+1, size+common.StorageSize(len(key)+len(iter.Value()))
+
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+
+			select {
+			case <-interrupt:
+				return errMigrationInterrupted
+			default:
+			}
+			if time.Since(logged) > 8*time.Second {
+				log.Info("Deleting legacy trie nodes", "nodes", count, "size", size, "elapsed", common.PrettyDuration(time.Since(start)))
+				logged = time.Now()
+			}
+			// Recreate the iterator after every batch commit in order
+			// to allow the underlying compactor to delete the entries.
+			iter.Release()
+			iter = db.NewIterator(nil, key)
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	log.Info("Deleted legacy trie nodes", "nodes", count, "size", size, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
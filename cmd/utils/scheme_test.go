@@ -0,0 +1,103 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestMigrateStateScheme(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		code    = common.Hex2Bytes("6001600055") // sstore(0, 1)
+		genesis = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				address:           {Balance: big.NewInt(1000000000000000000)},
+				common.Address{1}: {Code: code},
+			},
+		}
+		signer = types.LatestSigner(genesis.Config)
+	)
+	_, blocks, _ := core.GenerateChainWithGenesis(genesis, ethash.NewFaker(), 17, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignNewTx(key, signer, &types.LegacyTx{Nonce: uint64(i), GasPrice: b.BaseFee(), Gas: 100000, To: &common.Address{1}})
+		b.AddTx(tx)
+	})
+	db := rawdb.NewMemoryDatabase()
+	chain, err := core.NewBlockChain(db, core.DefaultCacheConfigWithScheme(rawdb.HashScheme), genesis, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	next := blocks[len(blocks)-1]
+	blocks = blocks[:len(blocks)-1]
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	chain.Stop()
+
+	if err := MigrateStateScheme(db, nil); err != nil {
+		t.Fatalf("failed to migrate state: %v", err)
+	}
+	if scheme := rawdb.ReadStateScheme(db); scheme != rawdb.PathScheme {
+		t.Fatalf("state scheme mismatch: have %s, want %s", scheme, rawdb.PathScheme)
+	}
+	it := db.NewIterator(nil, nil)
+	for it.Next() {
+		if len(it.Key()) == common.HashLength {
+			t.Fatalf("legacy node %x left behind", it.Key())
+		}
+	}
+	it.Release()
+
+	// Reopen the chain in path scheme and extend it on top of the migrated state
+	chain, err = core.NewBlockChain(db, core.DefaultCacheConfigWithScheme(rawdb.PathScheme), genesis, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to reopen chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if head := chain.CurrentBlock(); head.Hash() != blocks[len(blocks)-1].Hash() {
+		t.Fatalf("head mismatch: have %d, want %d", head.Number, len(blocks))
+	}
+	statedb, err := chain.State()
+	if err != nil {
+		t.Fatalf("failed to open migrated state: %v", err)
+	}
+	if nonce := statedb.GetNonce(address); nonce != uint64(len(blocks)) {
+		t.Fatalf("nonce mismatch: have %d, want %d", nonce, len(blocks))
+	}
+	if val := statedb.GetState(common.Address{1}, common.Hash{}); val != common.BigToHash(common.Big1) {
+		t.Fatalf("storage mismatch: have %x", val)
+	}
+	if !statedb.Exist(common.Address{1}) || len(statedb.GetCode(common.Address{1})) == 0 {
+		t.Fatal("contract code missing")
+	}
+	if _, err := chain.InsertChain(types.Blocks{next}); err != nil {
+		t.Fatalf("failed to extend migrated chain: %v", err)
+	}
+}
@@ -137,6 +137,23 @@ type PoW interface {
 	Hashrate() float64
 }
 
+// GasLimitTargeter is a consensus engine able to provide the gas limit block
+// producers should converge on, typically set through on-chain governance.
+type GasLimitTargeter interface {
+	// GasLimitTarget returns the gas limit to target for the block on top of the
+	// given parent, or false if the engine has no opinion on it.
+	GasLimitTarget(chain ChainHeaderReader, parent *types.Header) (uint64, bool)
+}
+
+// BlockSimulator is a consensus engine able to assemble blocks that are only
+// simulated and never sealed, without the credentials of a block producer.
+type BlockSimulator interface {
+	// FinalizeAndAssembleUnsigned is like FinalizeAndAssemble, but doesn't sign
+	// anything the engine adds to the block.
+	FinalizeAndAssembleUnsigned(chain ChainHeaderReader, header *types.Header, state *state.StateDB, txs []*types.Transaction,
+		uncles []*types.Header, receipts []*types.Receipt, withdrawals []*types.Withdrawal) (*types.Block, []*types.Receipt, error)
+}
+
 type PoSA interface {
 	Engine
 
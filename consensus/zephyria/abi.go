@@ -3321,3 +3321,19 @@ const stakingDelegatorABI = `[
       "type": "function"
     }
   ]`
+
+const govHubABI = `[
+	{
+		"inputs": [],
+		"name": "gasLimitTarget",
+		"outputs": [
+			{
+				"internalType": "uint256",
+				"name": "",
+				"type": "uint256"
+			}
+		],
+		"stateMutability": "view",
+		"type": "function"
+	}
+]`
@@ -12,6 +12,30 @@ type API struct {
 	zephyria *Zephyria
 }
 
+// apiDocs documents the methods of the zephyria namespace in rpc_discover.
+var apiDocs = map[string]rpc.MethodDoc{
+	"getSnapshot": {
+		Summary: "Returns the validator snapshot at a block, the latest one if none is given.",
+		Params:  []string{"blockNumber"},
+		Result:  "snapshot",
+	},
+	"getSnapshotAtHash": {
+		Summary: "Returns the validator snapshot at the block with the given hash.",
+		Params:  []string{"blockHash"},
+		Result:  "snapshot",
+	},
+	"getValidators": {
+		Summary: "Returns the validators at a block, the latest one if none is given.",
+		Params:  []string{"blockNumber"},
+		Result:  "validators",
+	},
+	"getValidatorsAtHash": {
+		Summary: "Returns the validators at the block with the given hash.",
+		Params:  []string{"blockHash"},
+		Result:  "validators",
+	},
+}
+
 // GetSnapshot retrieves the state snapshot at a given block.
 func (api *API) GetSnapshot(number *rpc.BlockNumber) (*Snapshot, error) {
 	// Retrieve the requested block number (or current if none requested)
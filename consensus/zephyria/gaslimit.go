@@ -0,0 +1,132 @@
+package zephyria
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/systemcontracts"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// GasLimitTarget implements consensus.GasLimitTargeter, returning the gas limit
+// set in the GovHub contract as of the last epoch block before the given parent.
+// The target only changes at epoch boundaries, so all validators converge on the
+// same value regardless of when they picked up a governance update.
+func (p *Zephyria) GasLimitTarget(chain consensus.ChainHeaderReader, parent *types.Header) (uint64, bool) {
+	epoch := chain.GetHeaderByNumber(parent.Number.Uint64() / p.config.Epoch * p.config.Epoch)
+	if epoch == nil {
+		return 0, false
+	}
+	if target, ok := p.gasTargets.Get(epoch.Hash()); ok {
+		return target.(uint64), target.(uint64) != 0
+	}
+	// Targets are persisted as the epoch state might be gone later on, e.g. after
+	// a restart with the limited state history of the path-based scheme
+	if p.db != nil {
+		if target, ok := rawdb.ReadZephyriaGasTarget(p.db, epoch.Hash()); ok {
+			p.gasTargets.Add(epoch.Hash(), target)
+			return target, target != 0
+		}
+	}
+	// The state might not be available yet (e.g. during sync). Don't cache the
+	// failure for the epoch, but don't retry it for the same block either, the
+	// target is requested repeatedly while verifying or sealing a block.
+	if p.gasMisses.Contains(parent.Hash()) {
+		return 0, false
+	}
+	target, err := p.getGasLimitTarget(epoch.Hash())
+	if err != nil {
+		log.Debug("Failed to retrieve gas limit target", "epoch", epoch.Number, "err", err)
+		p.gasMisses.Add(parent.Hash(), struct{}{})
+		return 0, false
+	}
+	// Ignore unset or nonsensical targets, falling back to the local configuration
+	if target < params.MinGasLimit {
+		target = 0
+	}
+	p.gasTargets.Add(epoch.Hash(), target)
+	if p.db != nil {
+		if err := rawdb.WriteZephyriaGasTarget(p.db, epoch.Hash(), target); err != nil {
+			log.Warn("Failed to store gas limit target", "epoch", epoch.Number, "err", err)
+		}
+	}
+	return target, target != 0
+}
+
+// errCodeReverted is the error code of the calls reverted by the EVM.
+const errCodeReverted = 3
+
+// getGasLimitTarget retrieves the gas limit target parameter from the GovHub
+// contract at the given block. If the contract doesn't provide the parameter,
+// the target is reported as unset, i.e. zero.
+func (p *Zephyria) getGasLimitTarget(blockHash common.Hash) (uint64, error) {
+	if p.ethAPI == nil {
+		return 0, errUnknownBlock
+	}
+	blockNr := rpc.BlockNumberOrHashWithHash(blockHash, false)
+
+	method := "gasLimitTarget"
+	data, err := p.govHubABI.Pack(method)
+	if err != nil {
+		return 0, err
+	}
+	var (
+		msgData   = (hexutil.Bytes)(data)
+		toAddress = common.HexToAddress(systemcontracts.GovHubContract)
+		gas       = (hexutil.Uint64)(uint64(math.MaxUint64 / 2))
+	)
+	result, err := p.ethAPI.Call(context.Background(), ethapi.TransactionArgs{
+		Gas:  &gas,
+		To:   &toAddress,
+		Data: &msgData,
+	}, blockNr, nil, nil)
+	if err != nil {
+		// Reverts with a reason are returned as API errors, the others as is
+		if rerr, ok := err.(rpc.Error); (ok && rerr.ErrorCode() == errCodeReverted) || errors.Is(err, vm.ErrExecutionReverted) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	out, err := p.govHubABI.Unpack(method, result)
+	if err != nil {
+		return 0, nil // Not implemented by the contract
+	}
+	target := *abi.ConvertType(out[0], new(*big.Int)).(**big.Int)
+	if !target.IsUint64() {
+		return math.MaxUint64, nil
+	}
+	return target.Uint64(), nil
+}
+
+// checkGasLimitTarget warns if the gas limit of a header moves away from, or
+// stalls short of, the governance target instead of converging on it.
+func (p *Zephyria) checkGasLimitTarget(chain consensus.ChainHeaderReader, header *types.Header, parent *types.Header) {
+	target, ok := p.GasLimitTarget(chain, parent)
+	if !ok || parent.GasLimit == target {
+		return
+	}
+	distance := func(limit uint64) uint64 {
+		if limit > target {
+			return limit - target
+		}
+		return target - limit
+	}
+	if distance(header.GasLimit) >= distance(parent.GasLimit) {
+		log.Warn("Block gas limit diverges from governance target", "number", header.Number, "hash", header.Hash(),
+			"validator", header.Coinbase, "gaslimit", header.GasLimit, "parent", parent.GasLimit,
+			"target", target, "expected", core.CalcGasLimit(parent.GasLimit, target))
+	}
+}
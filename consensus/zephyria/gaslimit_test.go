@@ -0,0 +1,173 @@
+package zephyria
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/systemcontracts"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// gasTargetBackend is the minimal ethapi backend executing the GovHub calls of
+// the gas limit target lookups, counting the state accesses.
+type gasTargetBackend struct {
+	ethapi.Backend
+	chain       *core.BlockChain
+	unavailable bool // Whether the state lookups fail
+	lookups     int
+}
+
+func (b *gasTargetBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.Header, error) {
+	b.lookups++
+	if b.unavailable {
+		return nil, nil, errors.New("state unavailable")
+	}
+	hash, _ := blockNrOrHash.Hash()
+	header := b.chain.GetHeaderByHash(hash)
+	if header == nil {
+		return nil, nil, errors.New("header not found")
+	}
+	statedb, err := b.chain.StateAt(header.Root)
+	return statedb, header, err
+}
+
+func (b *gasTargetBackend) GetEVM(ctx context.Context, msg *core.Message, state *state.StateDB, header *types.Header, vmConfig *vm.Config, blockCtx *vm.BlockContext) (*vm.EVM, func() error) {
+	return vm.NewEVM(*blockCtx, core.NewEVMTxContext(msg), state, b.chain.Config(), *vmConfig), func() error { return nil }
+}
+
+func (b *gasTargetBackend) Engine() consensus.Engine     { return b.chain.Engine() }
+func (b *gasTargetBackend) RPCGasCap() uint64            { return 0 }
+func (b *gasTargetBackend) RPCEVMTimeout() time.Duration { return 0 }
+
+// newGasTargetTester creates a chain of a few epochs whose GovHub contract runs
+// the given code, and a Zephyria engine reading the gas limit targets from it.
+func newGasTargetTester(t *testing.T, govHub []byte) (*Zephyria, *gasTargetBackend, []*types.Header) {
+	config := *params.TestChainConfig
+	config.Zephyria = &params.ZephyriaConfig{Period: 3, Epoch: 4}
+
+	gspec := &core.Genesis{
+		Config: &config,
+		Alloc:  core.GenesisAlloc{common.HexToAddress(systemcontracts.GovHubContract): {Code: govHub}},
+	}
+	db, blocks, _ := core.GenerateChainWithGenesis(gspec, ethash.NewFaker(), 10, nil)
+	chain, err := core.NewBlockChain(db, nil, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	t.Cleanup(chain.Stop)
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	backend := &gasTargetBackend{chain: chain}
+	engine := New(&config, rawdb.NewMemoryDatabase(), ethapi.NewBlockChainAPI(backend), chain.Genesis().Hash())
+
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	return engine, backend, headers
+}
+
+// govHubReturning returns the code of a contract answering every call with the
+// given number.
+func govHubReturning(target uint32) []byte {
+	return []byte{
+		byte(vm.PUSH4), byte(target >> 24), byte(target >> 16), byte(target >> 8), byte(target),
+		byte(vm.PUSH1), 0, byte(vm.MSTORE),
+		byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.RETURN),
+	}
+}
+
+// Tests that the gas limit target set in the GovHub contract as of the last
+// epoch block is used, and only retrieved once per epoch.
+func TestGasLimitTargetHit(t *testing.T) {
+	engine, backend, headers := newGasTargetTester(t, govHubReturning(30_000_000))
+
+	// Blocks 5 to 8 are built on top of the state of epoch block 4
+	for _, parent := range headers[4:7] {
+		if target, ok := engine.GasLimitTarget(backend.chain, parent); !ok || target != 30_000_000 {
+			t.Fatalf("parent %d: target mismatch: have %d, %v, want %d", parent.Number, target, ok, 30_000_000)
+		}
+	}
+	if backend.lookups != 1 {
+		t.Errorf("state accessed %d times, want once", backend.lookups)
+	}
+	// The target is persisted, surviving a restart without the state
+	restarted := New(engine.chainConfig, engine.db, ethapi.NewBlockChainAPI(backend), engine.genesisHash)
+	backend.unavailable = true
+	if target, ok := restarted.GasLimitTarget(backend.chain, headers[5]); !ok || target != 30_000_000 {
+		t.Errorf("restarted target mismatch: have %d, %v, want %d", target, ok, 30_000_000)
+	}
+	// It's stored outside of the snapshot namespace
+	it := engine.db.NewIterator(rawdb.ZephyriaSnapshotPrefix, nil)
+	defer it.Release()
+	if it.Next() {
+		t.Errorf("gas target stored among the snapshots: %x", it.Key())
+	}
+}
+
+// Tests that unset, invalid and unretrievable targets fall back to the local
+// configuration, and that contracts without the target are only called once.
+func TestGasLimitTargetFallback(t *testing.T) {
+	tests := []struct {
+		name   string
+		govHub []byte
+	}{
+		{"unset", govHubReturning(0)},
+		{"below minimum", govHubReturning(uint32(params.MinGasLimit - 1))},
+		{"reverted", []byte{byte(vm.PUSH1), 0, byte(vm.DUP1), byte(vm.REVERT)}},
+		{"no return data", []byte{byte(vm.STOP)}},
+	}
+	for _, tt := range tests {
+		engine, backend, headers := newGasTargetTester(t, tt.govHub)
+		for _, parent := range headers[4:7] {
+			if target, ok := engine.GasLimitTarget(backend.chain, parent); ok {
+				t.Errorf("%s: parent %d: unexpected target %d", tt.name, parent.Number, target)
+			}
+		}
+		if backend.lookups != 1 {
+			t.Errorf("%s: state accessed %d times, want once", tt.name, backend.lookups)
+		}
+	}
+}
+
+// Tests that failing to access the state of an epoch is only remembered for
+// the block being built, later blocks retry the lookup.
+func TestGasLimitTargetMiss(t *testing.T) {
+	engine, backend, headers := newGasTargetTester(t, govHubReturning(30_000_000))
+
+	backend.unavailable = true
+	for i := 0; i < 3; i++ {
+		if target, ok := engine.GasLimitTarget(backend.chain, headers[5]); ok {
+			t.Fatalf("unexpected target %d without state", target)
+		}
+	}
+	if backend.lookups != 1 {
+		t.Errorf("state accessed %d times for the same block, want once", backend.lookups)
+	}
+	backend.unavailable = false
+	if _, ok := engine.GasLimitTarget(backend.chain, headers[5]); ok {
+		t.Errorf("failed lookup of the same block retried")
+	}
+	if target, ok := engine.GasLimitTarget(backend.chain, headers[6]); !ok || target != 30_000_000 {
+		t.Errorf("next block: target mismatch: have %d, %v, want %d", target, ok, 30_000_000)
+	}
+	// Blocks without a known epoch block don't have a target either
+	orphan := &types.Header{Number: big.NewInt(100)}
+	if _, ok := engine.GasLimitTarget(backend.chain, orphan); ok {
+		t.Errorf("target found for unknown epoch")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"math/big"
 	"sort"
 
@@ -13,7 +14,9 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
 	lru "github.com/hashicorp/golang-lru"
 )
 
@@ -63,16 +66,22 @@ func (s validatorsAscending) Len() int           { return len(s) }
 func (s validatorsAscending) Less(i, j int) bool { return bytes.Compare(s[i][:], s[j][:]) < 0 }
 func (s validatorsAscending) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
 
-// loadSnapshot loads an existing snapshot from the database.
+// loadSnapshot loads an existing snapshot from the database. Snapshots still
+// stored in the legacy JSON format are converted to the current one.
 func loadSnapshot(config *params.ZephyriaConfig, sigCache *lru.ARCCache, db ethdb.Database, hash common.Hash, ethAPI *ethapi.BlockChainAPI) (*Snapshot, error) {
-	blob, err := db.Get(append([]byte("zephyria-"), hash[:]...))
+	blob, err := db.Get(snapshotKey(hash))
 	if err != nil {
 		return nil, err
 	}
-	snap := new(Snapshot)
-	if err := json.Unmarshal(blob, snap); err != nil {
+	snap, version, err := decodeSnapshot(blob)
+	if err != nil {
 		return nil, err
 	}
+	if version != snapshotVersion {
+		if err := snap.store(db); err != nil {
+			log.Warn("Failed to migrate snapshot", "number", snap.Number, "hash", hash, "err", err)
+		}
+	}
 	snap.config = config
 	snap.sigCache = sigCache
 	snap.ethAPI = ethAPI
@@ -81,12 +90,114 @@ func loadSnapshot(config *params.ZephyriaConfig, sigCache *lru.ARCCache, db ethd
 }
 
 // store inserts the snapshot into the database.
-func (s *Snapshot) store(db ethdb.Database) error {
-	blob, err := json.Marshal(s)
+func (s *Snapshot) store(db ethdb.KeyValueWriter) error {
+	blob, err := s.encode()
 	if err != nil {
 		return err
 	}
-	return db.Put(append([]byte("zephyria-"), s.Hash[:]...), blob)
+	return db.Put(snapshotKey(s.Hash), blob)
+}
+
+const (
+	// snapshotLegacyVersion is the version reported for snapshots stored as
+	// plain JSON, which carry no version byte.
+	snapshotLegacyVersion = 0
+
+	// snapshotVersion is the version of the current snapshot encoding: a version
+	// byte followed by the RLP encoding of storedSnapshot.
+	snapshotVersion = 1
+)
+
+// storedSnapshot is the RLP representation of a snapshot. The maps are stored
+// as lists sorted by their keys, so that the encoding is deterministic.
+type storedSnapshot struct {
+	Number     uint64
+	Hash       common.Hash
+	Validators []common.Address
+	Recents    []storedRecent
+	ForkHashes []storedForkHash
+}
+
+// storedRecent is a recent validator entry of a stored snapshot.
+type storedRecent struct {
+	Number    uint64
+	Validator common.Address
+}
+
+// storedForkHash is a recent fork hash entry of a stored snapshot.
+type storedForkHash struct {
+	Number   uint64
+	ForkHash []byte
+}
+
+// encode serializes the snapshot in the current versioned format.
+func (s *Snapshot) encode() ([]byte, error) {
+	enc := storedSnapshot{
+		Number:     s.Number,
+		Hash:       s.Hash,
+		Validators: s.validators(),
+		Recents:    make([]storedRecent, 0, len(s.Recents)),
+		ForkHashes: make([]storedForkHash, 0, len(s.RecentForkHashes)),
+	}
+	for number, validator := range s.Recents {
+		enc.Recents = append(enc.Recents, storedRecent{number, validator})
+	}
+	sort.Slice(enc.Recents, func(i, j int) bool { return enc.Recents[i].Number < enc.Recents[j].Number })
+
+	for number, forkHash := range s.RecentForkHashes {
+		blob, err := hex.DecodeString(forkHash)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fork hash %q at block %d: %v", forkHash, number, err)
+		}
+		enc.ForkHashes = append(enc.ForkHashes, storedForkHash{number, blob})
+	}
+	sort.Slice(enc.ForkHashes, func(i, j int) bool { return enc.ForkHashes[i].Number < enc.ForkHashes[j].Number })
+
+	blob, err := rlp.EncodeToBytes(&enc)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{snapshotVersion}, blob...), nil
+}
+
+// decodeSnapshot deserializes a stored snapshot, returning the version of the
+// encoding it was stored in along with it.
+func decodeSnapshot(blob []byte) (*Snapshot, byte, error) {
+	if len(blob) == 0 {
+		return nil, 0, errors.New("empty snapshot")
+	}
+	// Legacy snapshots are JSON objects, which can't start with a version byte
+	if blob[0] == '{' {
+		snap := new(Snapshot)
+		if err := json.Unmarshal(blob, snap); err != nil {
+			return nil, snapshotLegacyVersion, err
+		}
+		return snap, snapshotLegacyVersion, nil
+	}
+	if blob[0] != snapshotVersion {
+		return nil, blob[0], fmt.Errorf("unsupported snapshot version %d", blob[0])
+	}
+	var dec storedSnapshot
+	if err := rlp.DecodeBytes(blob[1:], &dec); err != nil {
+		return nil, blob[0], err
+	}
+	snap := &Snapshot{
+		Number:           dec.Number,
+		Hash:             dec.Hash,
+		Validators:       make(map[common.Address]struct{}, len(dec.Validators)),
+		Recents:          make(map[uint64]common.Address, len(dec.Recents)),
+		RecentForkHashes: make(map[uint64]string, len(dec.ForkHashes)),
+	}
+	for _, validator := range dec.Validators {
+		snap.Validators[validator] = struct{}{}
+	}
+	for _, recent := range dec.Recents {
+		snap.Recents[recent.Number] = recent.Validator
+	}
+	for _, forkHash := range dec.ForkHashes {
+		snap.RecentForkHashes[forkHash.Number] = hex.EncodeToString(forkHash.ForkHash)
+	}
+	return snap, blob[0], nil
 }
 
 // copy creates a deep copy of the snapshot
@@ -0,0 +1,160 @@
+package zephyria
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+const (
+	// snapshotRetention is the number of blocks below the finalized block whose
+	// checkpoint snapshots are kept in the database. Older ones are deleted, the
+	// engine regenerates them from the headers if ever needed.
+	snapshotRetention = 16 * checkpointInterval
+
+	// snapshotPruneInterval is the number of checkpoints after which the stored
+	// snapshots are garbage collected.
+	snapshotPruneInterval = 64
+)
+
+// snapshotPrefix is the database key prefix of the stored snapshots.
+var snapshotPrefix = []byte("zephyria-")
+
+// snapshotKey = snapshotPrefix + hash
+func snapshotKey(hash common.Hash) []byte {
+	key := make([]byte, 0, len(snapshotPrefix)+common.HashLength)
+	key = append(key, snapshotPrefix...)
+	return append(key, hash.Bytes()...)
+}
+
+// StoredSnapshot is a consensus snapshot as persisted in the database.
+type StoredSnapshot struct {
+	Hash     common.Hash // Block hash the snapshot is keyed by
+	Version  byte        // Encoding version, zero for the legacy JSON format
+	Size     int         // Size of the encoded snapshot
+	Snapshot *Snapshot   // Decoded snapshot, nil if it failed to decode
+	Err      error       // Decoding error, if any
+}
+
+// newStoredSnapshot decodes a snapshot record read from the database.
+func newStoredSnapshot(hash common.Hash, blob []byte) *StoredSnapshot {
+	snap, version, err := decodeSnapshot(blob)
+	return &StoredSnapshot{
+		Hash:     hash,
+		Version:  version,
+		Size:     len(blob),
+		Snapshot: snap,
+		Err:      err,
+	}
+}
+
+// ReadStoredSnapshot retrieves the snapshot stored for the given block hash.
+func ReadStoredSnapshot(db ethdb.KeyValueReader, hash common.Hash) (*StoredSnapshot, error) {
+	blob, err := db.Get(snapshotKey(hash))
+	if err != nil {
+		return nil, err
+	}
+	return newStoredSnapshot(hash, blob), nil
+}
+
+// IterateSnapshots calls fn for every snapshot stored in the database, in the
+// order of their block hashes. Records with malformed keys are skipped. The
+// iteration stops if fn returns false.
+func IterateSnapshots(db ethdb.Iteratee, fn func(*StoredSnapshot) bool) error {
+	it := db.NewIterator(snapshotPrefix, nil)
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(snapshotPrefix)+common.HashLength {
+			continue
+		}
+		if !fn(newStoredSnapshot(common.BytesToHash(key[len(snapshotPrefix):]), it.Value())) {
+			break
+		}
+	}
+	return it.Error()
+}
+
+// DeleteSnapshot removes the snapshot stored for the given block hash.
+func DeleteSnapshot(db ethdb.KeyValueWriter, hash common.Hash) error {
+	return db.Delete(snapshotKey(hash))
+}
+
+// PruneSnapshots garbage collects the stored snapshots against the finalized
+// block number. Snapshots more than snapshotRetention blocks below it, as well
+// as the ones of finalized numbers not on the canonical chain, are deleted. The
+// retained snapshots stored in the legacy JSON format are converted to the
+// current one. Undecodable records are left for `geth db verify` to report.
+func PruneSnapshots(db ethdb.Database, finalized uint64) (deleted int, migrated int, err error) {
+	batch := db.NewBatch()
+	iterErr := IterateSnapshots(db, func(stored *StoredSnapshot) bool {
+		snap := stored.Snapshot
+		if snap == nil {
+			return true
+		}
+		switch {
+		case snap.Number+snapshotRetention <= finalized:
+			err = DeleteSnapshot(batch, stored.Hash)
+			deleted++
+		case snap.Number <= finalized && rawdb.ReadCanonicalHash(db, snap.Number) != stored.Hash:
+			err = DeleteSnapshot(batch, stored.Hash)
+			deleted++
+		case stored.Version != snapshotVersion:
+			err = snap.store(batch)
+			migrated++
+		}
+		if err == nil && batch.ValueSize() > ethdb.IdealBatchSize {
+			if err = batch.Write(); err == nil {
+				batch.Reset()
+			}
+		}
+		return err == nil
+	})
+	if err != nil {
+		return deleted, migrated, err
+	}
+	if iterErr != nil {
+		return deleted, migrated, iterErr
+	}
+	return deleted, migrated, batch.Write()
+}
+
+// pruneSnapshots garbage collects the stored snapshots once after startup and
+// then every snapshotPruneInterval checkpoints. The finalized block is the one
+// marked in the database or, lacking that, the one deemed immutable below the
+// head of the chain.
+func (p *Zephyria) pruneSnapshots(chain consensus.ChainHeaderReader, number uint64) {
+	if p.snapsPruned.Load() && number%(snapshotPruneInterval*checkpointInterval) != 0 {
+		return
+	}
+	if !p.pruneLock.TryLock() {
+		return
+	}
+	defer p.pruneLock.Unlock()
+
+	var finalized uint64
+	if head := chain.CurrentHeader(); head != nil && head.Number.Uint64() > params.FullImmutabilityThreshold {
+		finalized = head.Number.Uint64() - params.FullImmutabilityThreshold
+	}
+	if hash := rawdb.ReadFinalizedBlockHash(p.db); hash != (common.Hash{}) {
+		if n := rawdb.ReadHeaderNumber(p.db, hash); n != nil && *n > finalized {
+			finalized = *n
+		}
+	}
+	start := time.Now()
+	deleted, migrated, err := PruneSnapshots(p.db, finalized)
+	if err != nil {
+		log.Warn("Failed to prune snapshots", "finalized", finalized, "err", err)
+		return
+	}
+	p.snapsPruned.Store(true)
+	if deleted > 0 || migrated > 0 {
+		log.Info("Pruned stored snapshots", "finalized", finalized, "deleted", deleted, "migrated", migrated, "elapsed", common.PrettyDuration(time.Since(start)))
+	}
+}
@@ -0,0 +1,113 @@
+package zephyria
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// newTestSnapshot creates a snapshot with a few validators and recents.
+func newTestSnapshot(number uint64, hash common.Hash) *Snapshot {
+	snap := newSnapshot(nil, nil, number, hash, []common.Address{{0x03}, {0x01}, {0x02}}, nil)
+	snap.Recents[number] = common.Address{0x01}
+	snap.Recents[number-1] = common.Address{0x02}
+	snap.RecentForkHashes[number] = "0a0b0c0d"
+	snap.RecentForkHashes[number-1] = "00000000"
+	return snap
+}
+
+// Tests that snapshots survive a round trip through the versioned encoding, and
+// that legacy JSON snapshots are still decoded.
+func TestSnapshotEncoding(t *testing.T) {
+	snap := newTestSnapshot(2048, common.Hash{0xaa})
+
+	blob, err := snap.encode()
+	if err != nil {
+		t.Fatalf("failed to encode snapshot: %v", err)
+	}
+	if blob[0] != snapshotVersion {
+		t.Fatalf("version mismatch: have %d, want %d", blob[0], snapshotVersion)
+	}
+	dec, version, err := decodeSnapshot(blob)
+	if err != nil {
+		t.Fatalf("failed to decode snapshot: %v", err)
+	}
+	if version != snapshotVersion || !reflect.DeepEqual(dec, snap) {
+		t.Fatalf("snapshot mismatch: have %+v (v%d), want %+v", dec, version, snap)
+	}
+	legacy, _ := json.Marshal(snap)
+	if dec, version, err = decodeSnapshot(legacy); err != nil {
+		t.Fatalf("failed to decode legacy snapshot: %v", err)
+	}
+	if version != snapshotLegacyVersion || !reflect.DeepEqual(dec, snap) {
+		t.Fatalf("legacy snapshot mismatch: have %+v (v%d), want %+v", dec, version, snap)
+	}
+	if _, _, err := decodeSnapshot([]byte{0x7f}); err == nil {
+		t.Fatalf("unknown version accepted")
+	}
+}
+
+// Tests that pruning deletes the snapshots deep below the finalized block and
+// the ones off the canonical chain, converting the retained legacy ones.
+func TestPruneSnapshots(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+
+	var (
+		finalized = uint64(20 * checkpointInterval)
+		canonical = make(map[uint64]common.Hash)
+	)
+	for i := uint64(1); i <= 24; i++ {
+		number := i * checkpointInterval
+		header := &types.Header{Number: new(big.Int).SetUint64(number), Extra: []byte{byte(i)}}
+		rawdb.WriteHeader(db, header)
+		rawdb.WriteCanonicalHash(db, header.Hash(), number)
+		canonical[number] = header.Hash()
+
+		// Store every other snapshot in the legacy format
+		snap := newTestSnapshot(number, header.Hash())
+		if i%2 == 0 {
+			blob, _ := json.Marshal(snap)
+			db.Put(snapshotKey(snap.Hash), blob)
+		} else if err := snap.store(db); err != nil {
+			t.Fatalf("failed to store snapshot: %v", err)
+		}
+	}
+	// Add a finalized side chain snapshot and one above the finalized block
+	newTestSnapshot(finalized, common.Hash{0x01}).store(db)
+	newTestSnapshot(finalized+checkpointInterval, common.Hash{0x02}).store(db)
+
+	deleted, migrated, err := PruneSnapshots(db, finalized)
+	if err != nil {
+		t.Fatalf("failed to prune snapshots: %v", err)
+	}
+	// Snapshots 1..4 are beyond retention, the finalized side one is dead
+	if deleted != 5 || migrated != 10 {
+		t.Fatalf("prune result mismatch: have %d deleted, %d migrated, want 5, 10", deleted, migrated)
+	}
+	for i := uint64(1); i <= 24; i++ {
+		number := i * checkpointInterval
+		stored, err := ReadStoredSnapshot(db, canonical[number])
+		if number+snapshotRetention <= finalized {
+			if err == nil {
+				t.Errorf("snapshot %d not pruned", number)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("snapshot %d missing: %v", number, err)
+		} else if stored.Version != snapshotVersion {
+			t.Errorf("snapshot %d not migrated: version %d", number, stored.Version)
+		}
+	}
+	if _, err := ReadStoredSnapshot(db, common.Hash{0x01}); err == nil {
+		t.Errorf("side chain snapshot not pruned")
+	}
+	if _, err := ReadStoredSnapshot(db, common.Hash{0x02}); err != nil {
+		t.Errorf("unfinalized snapshot pruned: %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package zephyria
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// VerifySnapshots checks the consistency of the snapshots stored by the engine.
+// Every record needs to decode, be keyed by the hash it was created at, belong
+// to a known checkpoint header and carry a validator set. Every problem found
+// is logged; if repair is set, the broken records are deleted so that they are
+// regenerated from the headers the next time they are needed.
+func VerifySnapshots(db ethdb.Database, repair bool) (rawdb.VerifyResult, error) {
+	var (
+		result rawdb.VerifyResult
+		batch  = db.NewBatch()
+		it     = db.NewIterator(snapshotPrefix, nil)
+	)
+	defer it.Release()
+
+	report := func(key []byte, msg string, ctx ...interface{}) {
+		result.Problems++
+		if repair {
+			batch.Delete(common.CopyBytes(key))
+			result.Repaired++
+			log.Warn(msg+", deleted", ctx...)
+		} else {
+			log.Error(msg, ctx...)
+		}
+	}
+	var count int
+	for it.Next() {
+		count++
+		key := it.Key()
+		if len(key) != len(snapshotPrefix)+common.HashLength {
+			report(key, "Invalid snapshot key", "key", common.Bytes2Hex(key))
+			continue
+		}
+		hash := common.BytesToHash(key[len(snapshotPrefix):])
+
+		snap, _, err := decodeSnapshot(it.Value())
+		if err != nil {
+			report(key, "Corrupted snapshot", "hash", hash, "err", err)
+			continue
+		}
+		switch number := rawdb.ReadHeaderNumber(db, hash); {
+		case snap.Hash != hash:
+			report(key, "Snapshot stored under wrong hash", "hash", hash, "have", snap.Hash)
+		case snap.Number%checkpointInterval != 0:
+			report(key, "Snapshot not at a checkpoint", "number", snap.Number, "hash", hash)
+		case number == nil || *number != snap.Number || !rawdb.HasHeader(db, hash, snap.Number):
+			report(key, "Snapshot of unknown header", "number", snap.Number, "hash", hash)
+		case len(snap.Validators) == 0:
+			report(key, "Snapshot without validators", "number", snap.Number, "hash", hash)
+		}
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return result, err
+			}
+			batch.Reset()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return result, err
+	}
+	if batch.ValueSize() > 0 {
+		if err := batch.Write(); err != nil {
+			return result, err
+		}
+	}
+	log.Info("Verified consensus snapshots", "snapshots", count, "problems", result.Problems)
+	return result, nil
+}
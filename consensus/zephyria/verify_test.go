@@ -0,0 +1,45 @@
+package zephyria
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Tests that broken snapshots are reported and repaired, while the gas limit
+// targets stored by the engine are left alone.
+func TestVerifySnapshots(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+
+	header := &types.Header{Number: big.NewInt(checkpointInterval)}
+	rawdb.WriteHeader(db, header)
+	if err := newTestSnapshot(checkpointInterval, header.Hash()).store(db); err != nil {
+		t.Fatalf("failed to store snapshot: %v", err)
+	}
+	db.Put(snapshotKey(common.Hash{0xbb}), []byte{0x7f})
+	if err := rawdb.WriteZephyriaGasTarget(db, header.Hash(), 30_000_000); err != nil {
+		t.Fatalf("failed to store gas target: %v", err)
+	}
+	result, err := VerifySnapshots(db, false)
+	if err != nil {
+		t.Fatalf("failed to verify snapshots: %v", err)
+	}
+	if result.Problems != 1 || result.Repaired != 0 {
+		t.Fatalf("verification result mismatch: have %+v, want 1 problem", result)
+	}
+	if result, err = VerifySnapshots(db, true); err != nil || result.Problems != 1 || result.Repaired != 1 {
+		t.Fatalf("repair result mismatch: have %+v, %v, want 1 repaired", result, err)
+	}
+	if result, err = VerifySnapshots(db, false); err != nil || result.Problems != 0 {
+		t.Fatalf("problems left after repair: %+v, %v", result, err)
+	}
+	if ok, _ := db.Has(snapshotKey(header.Hash())); !ok {
+		t.Error("valid snapshot deleted")
+	}
+	if target, ok := rawdb.ReadZephyriaGasTarget(db, header.Hash()); !ok || target != 30_000_000 {
+		t.Errorf("gas target mismatch: have %d, %v, want %d", target, ok, 30_000_000)
+	}
+}
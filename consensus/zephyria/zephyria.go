@@ -13,6 +13,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum"
@@ -44,6 +45,8 @@ import (
 const (
 	inMemorySnapshots  = 128  // Number of recent snapshots to keep in memory
 	inMemorySignatures = 4096 // Number of recent block signatures to keep in memory
+	inMemoryGasTargets = 16   // Number of recent epoch gas limit targets to keep in memory
+	inMemoryGasMisses  = 128  // Number of recent blocks whose gas limit target couldn't be retrieved
 
 	checkpointInterval = 1024          // Number of blocks after which to save the snapshot to the database
 	defaultEpochLength = uint64(30000) // Default number of blocks of checkpoint to update validatorSet from contract
@@ -200,6 +203,13 @@ type Zephyria struct {
 	validatorHubABI        abi.ABI
 	slashABI               abi.ABI
 	stakingDelegatorABI    abi.ABI
+	govHubABI              abi.ABI
+
+	gasTargets *lru.ARCCache // Gas limit targets of recent epochs, keyed by epoch block hash
+	gasMisses  *lru.ARCCache // Parent hashes of recent blocks whose gas limit target couldn't be retrieved
+
+	snapsPruned atomic.Bool // Whether the stored snapshots were pruned since startup
+	pruneLock   sync.Mutex  // Prevents concurrent snapshot pruning
 
 	// The fields below are for testing only
 	fakeDiff bool // Skip difficulty verifications
@@ -247,6 +257,18 @@ func New(
 	if err != nil {
 		panic(err)
 	}
+	gABI, err := abi.JSON(strings.NewReader(govHubABI))
+	if err != nil {
+		panic(err)
+	}
+	gasTargets, err := lru.NewARC(inMemoryGasTargets)
+	if err != nil {
+		panic(err)
+	}
+	gasMisses, err := lru.NewARC(inMemoryGasMisses)
+	if err != nil {
+		panic(err)
+	}
 
 	c := &Zephyria{
 		chainConfig:            chainConfig,
@@ -260,6 +282,9 @@ func New(
 		validatorHubABI:        vHubABI,
 		slashABI:               sABI,
 		stakingDelegatorABI:    pABI,
+		govHubABI:              gABI,
+		gasTargets:             gasTargets,
+		gasMisses:              gasMisses,
 		signer:                 types.LatestSigner(chainConfig),
 	}
 
@@ -456,6 +481,9 @@ func (p *Zephyria) verifyCascadingFields(chain consensus.ChainHeaderReader, head
 	if uint64(diff) >= limit || header.GasLimit < params.MinGasLimit {
 		return fmt.Errorf("invalid gas limit: have %d, want %d += %d", header.GasLimit, parent.GasLimit, limit)
 	}
+	// Flag validators not converging on the governance gas limit target. This is
+	// not a consensus rule, so outliers are only reported.
+	p.checkGasLimitTarget(chain, header, parent)
 
 	// All basic checks passed, verify the seal and return
 	return p.verifySeal(chain, header, parents)
@@ -511,6 +539,7 @@ func (p *Zephyria) snapshot(chain consensus.ChainHeaderReader, number uint64, ha
 					return nil, err
 				}
 				log.Info("Stored checkpoint snapshot to disk", "number", number, "hash", hash)
+				p.pruneSnapshots(chain, number)
 				break
 			}
 		}
@@ -557,6 +586,7 @@ func (p *Zephyria) snapshot(chain consensus.ChainHeaderReader, number uint64, ha
 			return nil, err
 		}
 		log.Trace("Stored snapshot to disk", "number", snap.Number, "hash", snap.Hash)
+		p.pruneSnapshots(chain, snap.Number)
 	}
 	return snap, err
 }
@@ -698,12 +728,12 @@ func (p *Zephyria) Prepare(chain consensus.ChainHeaderReader, header *types.Head
 	uncles []*types.Header, receipts *[]*types.Receipt, systemTxs *[]*types.Transaction, usedGas *uint64) error {
 	cx := chainContext{Chain: chain, zephyria: p}
 	if header.Number.Uint64()%p.config.Epoch == 0 {
-		err := p.setNewRound(state, header, cx, txs, receipts, systemTxs, usedGas, false)
+		err := p.setNewRound(state, header, cx, txs, receipts, systemTxs, usedGas, systemTxVerify)
 		if err != nil {
 			return err
 		}
 
-		err = p.distributeDelegatorReward(chain, state, header, cx, txs, receipts, systemTxs, usedGas, false)
+		err = p.distributeDelegatorReward(chain, state, header, cx, txs, receipts, systemTxs, usedGas, systemTxVerify)
 		if err != nil {
 			return err
 		}
@@ -716,12 +746,12 @@ func (p *Zephyria) BeforePackTx(chain consensus.ChainHeaderReader, header *types
 	cx := chainContext{Chain: chain, zephyria: p}
 	// If the block is the last one in a round, execute turn round to update the validator set.
 	if header.Number.Uint64()%p.config.Epoch == 0 {
-		err := p.setNewRound(state, header, cx, txs, receipts, nil, &header.GasUsed, true)
+		err := p.setNewRound(state, header, cx, txs, receipts, nil, &header.GasUsed, systemTxMine)
 		if err != nil {
 			return err
 		}
 
-		err = p.distributeDelegatorReward(chain, state, header, cx, txs, receipts, nil, &header.GasUsed, true)
+		err = p.distributeDelegatorReward(chain, state, header, cx, txs, receipts, nil, &header.GasUsed, systemTxMine)
 		if err != nil {
 			return err
 		}
@@ -775,7 +805,7 @@ func (p *Zephyria) Finalize(chain consensus.ChainHeaderReader, header *types.Hea
 
 	// Inicializar el contrato si el número de bloque es igual a 1.
 	if header.Number.Cmp(common.Big1) == 0 {
-		err := p.initContract(state, header, cx, txs, receipts, systemTxs, usedGas, false)
+		err := p.initContract(state, header, cx, txs, receipts, systemTxs, usedGas, systemTxVerify)
 		if err != nil {
 			log.Error("init contract failed")
 		}
@@ -798,7 +828,7 @@ func (p *Zephyria) Finalize(chain consensus.ChainHeaderReader, header *types.Hea
 
 		if !signedRecently {
 			log.Trace("slash validator", "block hash", header.Hash(), "address", spoiledVal)
-			err = p.slash(spoiledVal, state, header, cx, txs, receipts, systemTxs, usedGas, false)
+			err = p.slash(spoiledVal, state, header, cx, txs, receipts, systemTxs, usedGas, systemTxVerify)
 			if err != nil {
 				// Es posible que la función "slash" haya fallado porque el canal de castigo está deshabilitado.
 				log.Error("slash validator failed", "block hash", header.Hash(), "address", spoiledVal)
@@ -808,7 +838,7 @@ func (p *Zephyria) Finalize(chain consensus.ChainHeaderReader, header *types.Hea
 
 	// Distribuir las recompensas entrantes al validador del bloque.
 	val := header.Coinbase
-	err = p.distributeIncoming(val, state, header, cx, txs, receipts, systemTxs, usedGas, false)
+	err = p.distributeIncoming(val, state, header, cx, txs, receipts, systemTxs, usedGas, systemTxVerify)
 	if err != nil {
 		return err
 	}
@@ -826,6 +856,20 @@ func (p *Zephyria) Finalize(chain consensus.ChainHeaderReader, header *types.Hea
 // ni se otorguen recompensas de bloque, y devuelve el bloque final.
 func (p *Zephyria) FinalizeAndAssemble(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB,
 	txs []*types.Transaction, uncles []*types.Header, receipts []*types.Receipt, _ []*types.Withdrawal) (*types.Block, []*types.Receipt, error) {
+	return p.finalizeAndAssemble(chain, header, state, txs, receipts, systemTxMine)
+}
+
+// FinalizeAndAssembleUnsigned implements consensus.BlockSimulator, assembling the
+// block like FinalizeAndAssemble but leaving its system transactions unsigned.
+// The block can't be sealed, but it doesn't need an authorized validator and the
+// system transactions are sent from whatever coinbase the header names.
+func (p *Zephyria) FinalizeAndAssembleUnsigned(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB,
+	txs []*types.Transaction, uncles []*types.Header, receipts []*types.Receipt, _ []*types.Withdrawal) (*types.Block, []*types.Receipt, error) {
+	return p.finalizeAndAssemble(chain, header, state, txs, receipts, systemTxSimulate)
+}
+
+func (p *Zephyria) finalizeAndAssemble(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB,
+	txs []*types.Transaction, receipts []*types.Receipt, mode systemTxMode) (*types.Block, []*types.Receipt, error) {
 	// No hay recompensas de bloque en PoA, por lo que el estado permanece igual y los tíos se descartan.
 	cx := chainContext{Chain: chain, zephyria: p}
 
@@ -839,7 +883,7 @@ func (p *Zephyria) FinalizeAndAssemble(chain consensus.ChainHeaderReader, header
 
 	// Si el número de bloque es 1, inicializa los contratos.
 	if header.Number.Cmp(common.Big1) == 0 {
-		err := p.initContract(state, header, cx, &txs, &receipts, nil, &header.GasUsed, true)
+		err := p.initContract(state, header, cx, &txs, &receipts, nil, &header.GasUsed, mode)
 		if err != nil {
 			log.Error("init contract failed")
 		}
@@ -869,7 +913,7 @@ func (p *Zephyria) FinalizeAndAssemble(chain consensus.ChainHeaderReader, header
 
 		// Si no ha firmado recientemente, realiza un "slash" del validador.
 		if !signedRecently {
-			err = p.slash(spoiledVal, state, header, cx, &txs, &receipts, nil, &header.GasUsed, true)
+			err = p.slash(spoiledVal, state, header, cx, &txs, &receipts, nil, &header.GasUsed, mode)
 			if err != nil {
 				// Es posible que el "slash" del validador haya fallado debido a que el canal de "slash" está desactivado.
 				log.Error("slash validator failed", "block hash", header.Hash(), "address", spoiledVal)
@@ -877,7 +921,12 @@ func (p *Zephyria) FinalizeAndAssemble(chain consensus.ChainHeaderReader, header
 		}
 	}
 
-	err := p.distributeIncoming(p.val, state, header, cx, &txs, &receipts, nil, &header.GasUsed, true)
+	// Las simulaciones reparten las recompensas al coinbase indicado en el encabezado.
+	val := p.val
+	if mode == systemTxSimulate {
+		val = header.Coinbase
+	}
+	err := p.distributeIncoming(val, state, header, cx, &txs, &receipts, nil, &header.GasUsed, mode)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -1104,6 +1153,7 @@ func (p *Zephyria) APIs(chain consensus.ChainHeaderReader) []rpc.API {
 		Version:   "1.0",
 		Service:   &API{chain: chain, zephyria: p},
 		Public:    false,
+		Docs:      apiDocs,
 	}}
 }
 
@@ -1174,7 +1224,7 @@ func (p *Zephyria) getCurrentValidators(blockHash common.Hash) ([]common.Address
 
 // Distribuir a los validadores y al contrato de recompensa del sistema
 func (p *Zephyria) distributeIncoming(val common.Address, state *state.StateDB, header *types.Header, chain core.ChainContext,
-	txs *[]*types.Transaction, receipts *[]*types.Receipt, receivedTxs *[]*types.Transaction, usedGas *uint64, mining bool) error {
+	txs *[]*types.Transaction, receipts *[]*types.Receipt, receivedTxs *[]*types.Transaction, usedGas *uint64, mode systemTxMode) error {
 	coinbase := header.Coinbase
 	balance := state.GetBalance(consensus.SystemAddress)
 
@@ -1197,7 +1247,7 @@ func (p *Zephyria) distributeIncoming(val common.Address, state *state.StateDB,
 
 		// Si las recompensas son mayores que cero, distribuirlas al contrato de recompensa del sistema.
 		if rewards.Cmp(common.Big0) > 0 {
-			err := p.distributeToSystem(rewards, state, header, chain, txs, receipts, receivedTxs, usedGas, mining)
+			err := p.distributeToSystem(rewards, state, header, chain, txs, receipts, receivedTxs, usedGas, mode)
 			if err != nil {
 				return err
 			}
@@ -1208,12 +1258,12 @@ func (p *Zephyria) distributeIncoming(val common.Address, state *state.StateDB,
 
 	// Distribuir el saldo restante al contrato del validador.
 	log.Trace("distribute to validator contract", "block hash", header.Hash(), "amount", balance)
-	return p.distributeToValidator(balance, val, state, header, chain, txs, receipts, receivedTxs, usedGas, mining)
+	return p.distributeToValidator(balance, val, state, header, chain, txs, receipts, receivedTxs, usedGas, mode)
 }
 
 // Realiza una operación de "slashing" en la blockchain para sancionar a un validador que ha incumplido las reglas.
 func (p *Zephyria) slash(spoiledVal common.Address, state *state.StateDB, header *types.Header, chain core.ChainContext,
-	txs *[]*types.Transaction, receipts *[]*types.Receipt, receivedTxs *[]*types.Transaction, usedGas *uint64, mining bool) error {
+	txs *[]*types.Transaction, receipts *[]*types.Receipt, receivedTxs *[]*types.Transaction, usedGas *uint64, mode systemTxMode) error {
 	// Método a llamar en el contrato de "slashing"
 	method := "slash"
 
@@ -1228,12 +1278,12 @@ func (p *Zephyria) slash(spoiledVal common.Address, state *state.StateDB, header
 	msg := p.getSystemMessage(header.Coinbase, common.HexToAddress(systemcontracts.SlashContract), data, common.Big0)
 
 	// Aplicar el mensaje en el estado, lo que representa llevar a cabo la operación de "slashing" en la blockchain
-	return p.applyTransaction(msg, state, header, chain, txs, receipts, receivedTxs, usedGas, mining)
+	return p.applyTransaction(msg, state, header, chain, txs, receipts, receivedTxs, usedGas, mode)
 }
 
 // initContract inicializa contratos específicos en la cadena.
 func (p *Zephyria) initContract(state *state.StateDB, header *types.Header, chain core.ChainContext,
-	txs *[]*types.Transaction, receipts *[]*types.Receipt, receivedTxs *[]*types.Transaction, usedGas *uint64, mining bool) error {
+	txs *[]*types.Transaction, receipts *[]*types.Receipt, receivedTxs *[]*types.Transaction, usedGas *uint64, mode systemTxMode) error {
 	// Método a ser llamado en los contratos.
 	method := "init"
 
@@ -1265,7 +1315,7 @@ func (p *Zephyria) initContract(state *state.StateDB, header *types.Header, chai
 
 		// Aplica el mensaje para inicializar el contrato.
 		log.Trace("init contract", "block hash", header.Hash(), "contract", c)
-		err = p.applyTransaction(msg, state, header, chain, txs, receipts, receivedTxs, usedGas, mining)
+		err = p.applyTransaction(msg, state, header, chain, txs, receipts, receivedTxs, usedGas, mode)
 		if err != nil {
 			return err
 		}
@@ -1275,17 +1325,17 @@ func (p *Zephyria) initContract(state *state.StateDB, header *types.Header, chai
 }
 
 func (p *Zephyria) distributeToSystem(amount *big.Int, state *state.StateDB, header *types.Header, chain core.ChainContext,
-	txs *[]*types.Transaction, receipts *[]*types.Receipt, receivedTxs *[]*types.Transaction, usedGas *uint64, mining bool) error {
+	txs *[]*types.Transaction, receipts *[]*types.Receipt, receivedTxs *[]*types.Transaction, usedGas *uint64, mode systemTxMode) error {
 	// get system message
 	msg := p.getSystemMessage(header.Coinbase, common.HexToAddress(systemcontracts.SystemRewardContract), nil, amount)
 	// apply message
-	return p.applyTransaction(msg, state, header, chain, txs, receipts, receivedTxs, usedGas, mining)
+	return p.applyTransaction(msg, state, header, chain, txs, receipts, receivedTxs, usedGas, mode)
 }
 
 // Realizar una operación de depósito en el contrato del validador
 func (p *Zephyria) distributeToValidator(amount *big.Int, validator common.Address,
 	state *state.StateDB, header *types.Header, chain core.ChainContext,
-	txs *[]*types.Transaction, receipts *[]*types.Receipt, receivedTxs *[]*types.Transaction, usedGas *uint64, mining bool) error {
+	txs *[]*types.Transaction, receipts *[]*types.Receipt, receivedTxs *[]*types.Transaction, usedGas *uint64, mode systemTxMode) error {
 	// Método a llamar en el contrato del validador
 	method := "deposit"
 
@@ -1300,11 +1350,11 @@ func (p *Zephyria) distributeToValidator(amount *big.Int, validator common.Addre
 	msg := p.getSystemMessage(header.Coinbase, common.HexToAddress(systemcontracts.ValidatorController), data, amount)
 
 	// Aplicar el mensaje en el estado, lo que representa realizar el depósito en el contrato del validador
-	return p.applyTransaction(msg, state, header, chain, txs, receipts, receivedTxs, usedGas, mining)
+	return p.applyTransaction(msg, state, header, chain, txs, receipts, receivedTxs, usedGas, mode)
 }
 
 func (p *Zephyria) distributeDelegatorReward(chain consensus.ChainHeaderReader, state *state.StateDB, header *types.Header, cx core.ChainContext,
-	txs *[]*types.Transaction, receipts *[]*types.Receipt, receivedTxs *[]*types.Transaction, usedGas *uint64, mining bool) error {
+	txs *[]*types.Transaction, receipts *[]*types.Receipt, receivedTxs *[]*types.Transaction, usedGas *uint64, mode systemTxMode) error {
 
 	snap, err := p.snapshot(chain, header.Number.Uint64()-1, header.ParentHash, nil)
 	if err != nil {
@@ -1322,11 +1372,11 @@ func (p *Zephyria) distributeDelegatorReward(chain consensus.ChainHeaderReader,
 
 	msg := p.getSystemMessage(header.Coinbase, common.HexToAddress(systemcontracts.StakingDelegator), data, common.Big0)
 
-	return p.applyTransaction(msg, state, header, cx, txs, receipts, receivedTxs, usedGas, mining)
+	return p.applyTransaction(msg, state, header, cx, txs, receipts, receivedTxs, usedGas, mode)
 }
 
 func (p *Zephyria) updateValidators(state *state.StateDB, header *types.Header, chain core.ChainContext,
-	txs *[]*types.Transaction, receipts *[]*types.Receipt, receivedTxs *[]*types.Transaction, usedGas *uint64, mining bool) error {
+	txs *[]*types.Transaction, receipts *[]*types.Receipt, receivedTxs *[]*types.Transaction, usedGas *uint64, mode systemTxMode) error {
 	method := "updateValidators"
 
 	data, err := p.validatorHubABI.Pack(method)
@@ -1336,11 +1386,11 @@ func (p *Zephyria) updateValidators(state *state.StateDB, header *types.Header,
 
 	msg := p.getSystemMessage(header.Coinbase, common.HexToAddress(systemcontracts.ValidatorHub), data, common.Big0)
 
-	return p.applyTransaction(msg, state, header, chain, txs, receipts, receivedTxs, usedGas, mining)
+	return p.applyTransaction(msg, state, header, chain, txs, receipts, receivedTxs, usedGas, mode)
 }
 
 func (p *Zephyria) setNewRound(state *state.StateDB, header *types.Header, chain core.ChainContext,
-	txs *[]*types.Transaction, receipts *[]*types.Receipt, receivedTxs *[]*types.Transaction, usedGas *uint64, mining bool) error {
+	txs *[]*types.Transaction, receipts *[]*types.Receipt, receivedTxs *[]*types.Transaction, usedGas *uint64, mode systemTxMode) error {
 	method := "setNewRound"
 
 	data, err := p.stakingDelegatorABI.Pack(method)
@@ -1350,11 +1400,11 @@ func (p *Zephyria) setNewRound(state *state.StateDB, header *types.Header, chain
 
 	msg := p.getSystemMessage(header.Coinbase, common.HexToAddress(systemcontracts.StakingDelegator), data, common.Big0)
 
-	return p.applyTransaction(msg, state, header, chain, txs, receipts, receivedTxs, usedGas, mining)
+	return p.applyTransaction(msg, state, header, chain, txs, receipts, receivedTxs, usedGas, mode)
 }
 
 func (p *Zephyria) emitWithdrawals(state *state.StateDB, header *types.Header, chain core.ChainContext,
-	txs *[]*types.Transaction, receipts *[]*types.Receipt, receivedTxs *[]*types.Transaction, usedGas *uint64, mining bool) error {
+	txs *[]*types.Transaction, receipts *[]*types.Receipt, receivedTxs *[]*types.Transaction, usedGas *uint64, mode systemTxMode) error {
 
 	method := "emitWithdrawals"
 
@@ -1366,9 +1416,19 @@ func (p *Zephyria) emitWithdrawals(state *state.StateDB, header *types.Header, c
 
 	msg := p.getSystemMessage(header.Coinbase, common.HexToAddress(systemcontracts.ValidatorHub), data, common.Big0)
 
-	return p.applyTransaction(msg, state, header, chain, txs, receipts, receivedTxs, usedGas, mining)
+	return p.applyTransaction(msg, state, header, chain, txs, receipts, receivedTxs, usedGas, mode)
 }
 
+// systemTxMode tells applyTransaction where the system transactions of a block
+// come from.
+type systemTxMode int
+
+const (
+	systemTxVerify   systemTxMode = iota // taken from the block being verified
+	systemTxMine                         // signed by the local validator
+	systemTxSimulate                     // left unsigned, the block is never sealed
+)
+
 // get system message
 func (p *Zephyria) getSystemMessage(from, toAddress common.Address, data []byte, value *big.Int) callmsg {
 	return callmsg{
@@ -1389,18 +1449,21 @@ func (p *Zephyria) applyTransaction(
 	header *types.Header,
 	chainContext core.ChainContext,
 	txs *[]*types.Transaction, receipts *[]*types.Receipt,
-	receivedTxs *[]*types.Transaction, usedGas *uint64, mining bool,
+	receivedTxs *[]*types.Transaction, usedGas *uint64, mode systemTxMode,
 ) (err error) {
 	nonce := state.GetNonce(msg.From())
 	expectedTx := types.NewTransaction(nonce, *msg.To(), msg.Value(), msg.Gas(), msg.GasPrice(), msg.Data())
 	expectedHash := p.signer.Hash(expectedTx)
 
-	if msg.From() == p.val && mining {
+	switch {
+	case mode == systemTxSimulate:
+		// Simulated blocks are never sealed, so there's nobody to sign for.
+	case mode == systemTxMine && msg.From() == p.val:
 		expectedTx, err = p.signTxFn(accounts.Account{Address: msg.From()}, expectedTx, p.chainConfig.ChainID)
 		if err != nil {
 			return err
 		}
-	} else {
+	default:
 		if receivedTxs == nil || len(*receivedTxs) == 0 || (*receivedTxs)[0] == nil {
 			return errors.New("supposed to get a actual transaction, but get none")
 		}
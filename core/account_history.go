@@ -0,0 +1,202 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// AccountHistoryAt retrieves the nonce and balance of an account at the given
+// canonical block from the account history index. The flag reports whether the
+// index covers the block, otherwise the state of the block needs to be used.
+//
+// Every block from the index tail onwards is indexed. If the account didn't
+// change since the tail, its value is only known if the index reaches down to
+// the genesis. If the back-fill stopped short of the genesis, the reason is
+// returned for the blocks it can't cover.
+func (bc *BlockChain) AccountHistoryAt(address common.Address, hash common.Hash, number uint64) (uint64, *big.Int, bool, error) {
+	if !bc.cacheConfig.AccountHistory {
+		return 0, nil, false, nil
+	}
+	if rawdb.ReadCanonicalHash(bc.db, number) != hash {
+		return 0, nil, false, nil
+	}
+	tail := rawdb.ReadAccountHistoryTail(bc.db)
+	if tail == nil {
+		return 0, nil, false, nil
+	}
+	if number >= *tail {
+		if entry := rawdb.ReadAccountHistory(bc.db, address, number); entry != nil && entry.Number >= *tail {
+			return entry.Nonce, entry.Balance, true, nil
+		}
+		if *tail == 0 {
+			return 0, new(big.Int), true, nil
+		}
+	}
+	// Not covered by the index, report if it won't ever be
+	if err := bc.accountHistoryErr.Load(); err != nil {
+		return 0, nil, false, *err
+	}
+	return 0, nil, false, nil
+}
+
+// writeAccountHistory indexes the nonce and balance changes of a block.
+func (bc *BlockChain) writeAccountHistory(batch ethdb.KeyValueWriter, number uint64, hash common.Hash, diffs []*types.AccountDiff) {
+	for _, diff := range diffs {
+		prevNonce, prevBalance := nonceAndBalance(diff.Prev)
+		nonce, balance := nonceAndBalance(diff.Post)
+		if prevNonce == nonce && prevBalance.Cmp(balance) == 0 {
+			continue
+		}
+		rawdb.WriteAccountHistory(batch, diff.Address, number, hash, nonce, balance)
+	}
+}
+
+// nonceAndBalance returns the nonce and balance of an account in a state diff,
+// zero if the account doesn't exist.
+func nonceAndBalance(account *types.DiffAccount) (uint64, *big.Int) {
+	if account == nil {
+		return 0, new(big.Int)
+	}
+	return account.Nonce, account.Balance
+}
+
+// maintainAccountHistory back-fills the account history index below the blocks
+// indexed during import, down to the genesis or the first block whose changes
+// can't be derived anymore.
+func (bc *BlockChain) maintainAccountHistory() {
+	defer bc.wg.Done()
+
+	headCh := make(chan ChainHeadEvent, 1)
+	sub := bc.SubscribeChainHeadEvent(headCh)
+	if sub == nil {
+		return
+	}
+	defer sub.Unsubscribe()
+
+	// Wait for the first indexed block if the index was just enabled
+	tail := rawdb.ReadAccountHistoryTail(bc.db)
+	for tail == nil {
+		select {
+		case <-headCh:
+			tail = rawdb.ReadAccountHistoryTail(bc.db)
+		case <-bc.quit:
+			return
+		}
+	}
+	if *tail == 0 {
+		return
+	}
+	var (
+		start  = time.Now()
+		logged = time.Now()
+		first  = *tail
+	)
+	log.Info("Back-filling account history", "tail", first)
+	if !bc.cacheConfig.TrieDirtyDisabled && !bc.cacheConfig.StateDiffs {
+		log.Warn("Account history back-fill needs the historical states, it will stop at the oldest one on non-archive nodes")
+	}
+	stop := func(number uint64, err error) {
+		log.Warn("Stopped account history back-fill, older blocks need their state", "number", number, "err", err)
+		err = fmt.Errorf("account history back-fill stopped at block %d: %w", number, err)
+		bc.accountHistoryErr.Store(&err)
+	}
+	for number := first; number > 0; number-- {
+		select {
+		case <-bc.quit:
+			return
+		default:
+		}
+		block := bc.GetBlockByNumber(number - 1)
+		if block == nil {
+			stop(number-1, errors.New("block unavailable"))
+			return
+		}
+		diffs, err := bc.accountChanges(block)
+		if err != nil {
+			stop(number-1, err)
+			return
+		}
+		batch := bc.db.NewBatch()
+		bc.writeAccountHistory(batch, block.NumberU64(), block.Hash(), diffs)
+		rawdb.WriteAccountHistoryTail(batch, block.NumberU64())
+		if err := batch.Write(); err != nil {
+			log.Crit("Failed writing account history", "err", err)
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Back-filling account history", "tail", block.NumberU64(), "blocks", first-block.NumberU64(), "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	log.Info("Back-filled account history", "blocks", first, "elapsed", common.PrettyDuration(time.Since(start)))
+}
+
+// accountChanges derives the account changes made by a historical block, either
+// from its stored state diff, the genesis specification or by re-executing the
+// block on top of the parent state.
+func (bc *BlockChain) accountChanges(block *types.Block) ([]*types.AccountDiff, error) {
+	if diff := rawdb.ReadStateDiff(bc.db, block.Hash(), block.NumberU64()); diff != nil {
+		return diff.Accounts, nil
+	}
+	if block.NumberU64() == 0 {
+		blob := rawdb.ReadGenesisStateSpec(bc.db, block.Hash())
+		if len(blob) == 0 {
+			return nil, errors.New("genesis state specification not found")
+		}
+		var alloc GenesisAlloc
+		if err := json.Unmarshal(blob, &alloc); err != nil {
+			return nil, err
+		}
+		diffs := make([]*types.AccountDiff, 0, len(alloc))
+		for addr, account := range alloc {
+			balance := account.Balance
+			if balance == nil {
+				balance = new(big.Int)
+			}
+			diffs = append(diffs, &types.AccountDiff{
+				Address: addr,
+				Post:    &types.DiffAccount{Nonce: account.Nonce, Balance: balance},
+			})
+		}
+		return diffs, nil
+	}
+	parent := bc.GetHeader(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, consensus.ErrUnknownAncestor
+	}
+	statedb, err := bc.StateAt(parent.Root)
+	if err != nil {
+		return nil, err
+	}
+	statedb, _, _, _, err = bc.processor.Process(block, statedb, bc.vmConfig)
+	if err != nil {
+		return nil, err
+	}
+	statedb.IntermediateRoot(bc.chainConfig.IsEIP158(block.Number()))
+	return statedb.StateDiff(), nil
+}
@@ -0,0 +1,223 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the account nonces and balances are indexed during import and by
+// the back-fill, that a back-fill stopped by missing states is reported, and
+// that disabling the index discards it.
+func TestAccountHistory(t *testing.T) {
+	var (
+		key, _    = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender    = crypto.PubkeyToAddress(key.PublicKey)
+		recipient = common.HexToAddress("0xaaaa")
+		untouched = common.HexToAddress("0xbbbb")
+		signer    = types.HomesteadSigner{}
+		gspec     = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc: GenesisAlloc{
+				sender:    {Balance: big.NewInt(params.Ether)},
+				untouched: {Balance: big.NewInt(1), Nonce: 1},
+			},
+		}
+	)
+	genDb, blocks, _ := GenerateChainWithGenesis(gspec, ethash.NewFaker(), 6, func(i int, b *BlockGen) {
+		if i == 2 {
+			return // Leave the accounts unchanged in a block
+		}
+		tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(sender), recipient, big.NewInt(int64(i+1)), params.TxGas, b.BaseFee(), nil), signer, key)
+		b.AddTx(tx)
+	})
+	// Retrieve the expected values from the states of the generated chain
+	archive, err := NewBlockChain(genDb, &CacheConfig{TrieDirtyDisabled: true}, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	defer archive.Stop()
+
+	if _, err := archive.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+
+	verify := func(chain *BlockChain, from uint64) {
+		t.Helper()
+
+		for n := from; n <= uint64(len(blocks)); n++ {
+			header := archive.GetHeaderByNumber(n)
+			statedb, err := archive.StateAt(header.Root)
+			if err != nil {
+				t.Fatalf("block %d: failed to retrieve state: %v", n, err)
+			}
+			for _, address := range []common.Address{sender, recipient, untouched} {
+				nonce, balance, ok, err := chain.AccountHistoryAt(address, header.Hash(), n)
+				if err != nil || !ok {
+					t.Fatalf("block %d: history of %x unavailable: %v", n, address, err)
+				}
+				if nonce != statedb.GetNonce(address) || balance.Cmp(statedb.GetBalance(address)) != 0 {
+					t.Errorf("block %d: history of %x mismatch: have %d/%v, want %d/%v", n, address, nonce, balance, statedb.GetNonce(address), statedb.GetBalance(address))
+				}
+			}
+		}
+		// Blocks off the canonical chain aren't covered
+		if _, _, ok, _ := chain.AccountHistoryAt(sender, common.Hash{0x01}, 1); ok {
+			t.Error("history of non-canonical block available")
+		}
+	}
+	waitTail := func(db ethdb.Database) {
+		t.Helper()
+		for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+			if tail := rawdb.ReadAccountHistoryTail(db); tail != nil && *tail == 0 {
+				return
+			}
+			if time.Since(start) > 5*time.Second {
+				t.Fatal("account history not back-filled")
+			}
+		}
+	}
+	config := *defaultCacheConfig
+	config.TrieDirtyDisabled = true
+	config.AccountHistory = true
+
+	// Index the entire chain during import, the genesis being back-filled
+	db := rawdb.NewMemoryDatabase()
+	chain, err := NewBlockChain(db, &config, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	if _, _, ok, _ := chain.AccountHistoryAt(sender, blocks[0].Hash(), 1); ok {
+		t.Fatal("history available before any block was indexed")
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	waitTail(db)
+	verify(chain, 0)
+	chain.Stop()
+
+	// Import the first blocks without the index and back-fill them
+	db = rawdb.NewMemoryDatabase()
+	config.AccountHistory = false
+	chain, err = NewBlockChain(db, &config, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks[:3]); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	chain.Stop()
+
+	config.AccountHistory = true
+	chain, err = NewBlockChain(db, &config, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks[3:]); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	waitTail(db)
+	verify(chain, 0)
+	chain.Stop()
+
+	// Disabling the index discards all of it
+	config.AccountHistory = false
+	chain, err = NewBlockChain(db, &config, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	chain.Stop()
+	if rawdb.ReadAccountHistoryTail(db) != nil || rawdb.HasAccountHistory(db) {
+		t.Error("account history left after disabling the index")
+	}
+}
+
+// Tests that a back-fill stopped by the states pruned on non-archive nodes is
+// reported for the blocks it doesn't cover.
+func TestAccountHistoryBackfillStopped(t *testing.T) {
+	var (
+		key, _    = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender    = crypto.PubkeyToAddress(key.PublicKey)
+		recipient = common.HexToAddress("0xaaaa")
+		signer    = types.HomesteadSigner{}
+		gspec     = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  GenesisAlloc{sender: {Balance: big.NewInt(params.Ether)}},
+		}
+	)
+	_, blocks, _ := GenerateChainWithGenesis(gspec, ethash.NewFaker(), 8, func(i int, b *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(sender), recipient, big.NewInt(1), params.TxGas, b.BaseFee(), nil), signer, key)
+		b.AddTx(tx)
+	})
+	// Import the first blocks without the index, only their last states are
+	// persisted on shutdown
+	db := rawdb.NewMemoryDatabase()
+	config := *defaultCacheConfig
+	chain, err := NewBlockChain(db, &config, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks[:4]); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	chain.Stop()
+
+	config.AccountHistory = true
+	chain, err = NewBlockChain(db, &config, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks[4:]); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		if _, _, _, err := chain.AccountHistoryAt(sender, blocks[0].Hash(), 1); err != nil {
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("stopped back-fill not reported")
+		}
+	}
+	tail := rawdb.ReadAccountHistoryTail(db)
+	if tail == nil || *tail < 2 || *tail > 5 {
+		t.Fatalf("unexpected account history tail: %v", tail)
+	}
+	// The blocks from the tail onwards are still served
+	for _, block := range blocks[*tail-1:] {
+		nonce, _, ok, err := chain.AccountHistoryAt(sender, block.Hash(), block.NumberU64())
+		if err != nil || !ok || nonce != block.NumberU64() {
+			t.Errorf("block %d: history mismatch: have %d, %v, %v, want %d", block.NumberU64(), nonce, ok, err, block.NumberU64())
+		}
+	}
+	if _, _, ok, err := chain.AccountHistoryAt(sender, blocks[*tail-2].Hash(), *tail-1); ok || err == nil {
+		t.Errorf("block below tail: have ok %v, error %v, want failure", ok, err)
+	}
+}
@@ -107,6 +107,9 @@ const (
 	maxFutureBlocks     = 256
 	maxTimeFutureBlocks = 30
 	TriesInMemory       = 128
+	historyPruneBatch   = 2048 // Number of expired blocks to accumulate before pruning the history
+
+	stateDiffUnindexLimit = 256 // Maximum number of expired state diffs to delete per imported block
 
 	// BlockChainVersion ensures that an incompatible database forces a resync from scratch.
 	//
@@ -145,7 +148,15 @@ type CacheConfig struct {
 	SnapshotLimit       int           // Memory allowance (MB) to use for caching snapshot entries in memory
 	Preimages           bool          // Whether to store preimage of trie key to the disk
 	StateHistory        uint64        // Number of blocks from head whose state histories are reserved.
+	HistoryBlocks       uint64        // Number of blocks from head whose bodies and receipts are reserved (0 = all)
 	StateScheme         string        // Scheme used to store ethereum states and merkle tree nodes on top
+	StateDiffs          bool          // Whether to store the state diff of every imported block
+	StateDiffHistory    uint64        // Number of blocks from head whose state diffs are reserved (0 = all)
+	AccountHistory      bool          // Whether to index the historical balances and nonces of accounts
+	TxHistory           bool          // Whether to index the transactions by the addresses involved
+	TxHistoryInternal   bool          // Whether to index the transactions by the addresses called internally too
+	Replica             bool          // Whether the database is owned by another process (read replica)
+	ParallelTx          bool          // Whether to execute the transactions of blocks in parallel
 
 	SnapshotNoBuild bool // Whether the background generation is allowed
 	SnapshotWait    bool // Wait for snapshot construction on startup. TODO(karalabe): This is a dirty hack for testing, nuke it
@@ -243,6 +254,8 @@ type BlockChain struct {
 	currentFinalBlock     atomic.Pointer[types.Header] // Latest (consensus) finalized block
 	currentSafeBlock      atomic.Pointer[types.Header] // Latest (consensus) safe block
 
+	accountHistoryErr atomic.Pointer[error] // Reason the account history back-fill stopped, nil if it didn't
+
 	bodyCache     *lru.Cache[common.Hash, *types.Body]
 	bodyRLPCache  *lru.Cache[common.Hash, rlp.RawValue]
 	receiptsCache *lru.Cache[common.Hash, []*types.Receipt]
@@ -349,9 +362,10 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, genesis *Genesis
 		return nil, err
 	}
 	// Make sure the state associated with the block is available, or log out
-	// if there is no available state, waiting for state sync.
+	// if there is no available state, waiting for state sync. Replicas re-execute
+	// the blocks on top of the persisted state when reloading the head instead.
 	head := bc.CurrentBlock()
-	if !bc.HasState(head.Root) {
+	if !bc.HasState(head.Root) && !bc.cacheConfig.Replica {
 		if head.Number.Uint64() == 0 {
 			// The genesis state is missing, which is only possible in the path-based
 			// scheme. This situation occurs when the initial state sync is not finished
@@ -474,6 +488,28 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, genesis *Genesis
 		}
 		rawdb.WriteChainConfig(db, genesisHash, chainConfig)
 	}
+	// Start the account history back-filler if enabled, otherwise invalidate the
+	// index as the blocks imported in the meantime won't be indexed. Replicas
+	// leave the index to the primary owning the database.
+	switch {
+	case bc.cacheConfig.Replica:
+	case bc.cacheConfig.AccountHistory:
+		bc.wg.Add(1)
+		go bc.maintainAccountHistory()
+	case rawdb.ReadAccountHistoryTail(bc.db) != nil || rawdb.HasAccountHistory(bc.db):
+		log.Warn("Account history index disabled, discarding it")
+		rawdb.DeleteAccountHistory(bc.db)
+	}
+	// Likewise for the transaction history back-filler
+	switch {
+	case bc.cacheConfig.Replica:
+	case bc.cacheConfig.TxHistory:
+		bc.wg.Add(1)
+		go bc.maintainTxHistory()
+	case rawdb.ReadTxHistoryTail(bc.db) != nil:
+		log.Warn("Transaction history index disabled, discarding it")
+		rawdb.DeleteTxHistoryTail(bc.db)
+	}
 	// Start tx indexer/unindexer if required.
 	if txLookupLimit != nil {
 		bc.txLookupLimit = *txLookupLimit
@@ -591,6 +627,9 @@ func (bc *BlockChain) loadLastState() error {
 // was snap synced or full synced and in which state, the method will try to
 // delete minimal data from disk whilst retaining chain consistency.
 func (bc *BlockChain) SetHead(head uint64) error {
+	if bc.cacheConfig.Replica {
+		return errReplicaWrite
+	}
 	if _, err := bc.setHeadBeyondRoot(head, 0, common.Hash{}, false); err != nil {
 		return err
 	}
@@ -613,6 +652,9 @@ func (bc *BlockChain) SetHead(head uint64) error {
 // synced and in which state, the method will try to delete minimal data from
 // disk whilst retaining chain consistency.
 func (bc *BlockChain) SetHeadWithTimestamp(timestamp uint64) error {
+	if bc.cacheConfig.Replica {
+		return errReplicaWrite
+	}
 	if _, err := bc.setHeadBeyondRoot(0, timestamp, common.Hash{}, false); err != nil {
 		return err
 	}
@@ -630,16 +672,21 @@ func (bc *BlockChain) SetHeadWithTimestamp(timestamp uint64) error {
 	return nil
 }
 
-// SetFinalized sets the finalized block.
+// SetFinalized sets the finalized block. Replicas only track it in memory, the
+// marker is persisted by the primary.
 func (bc *BlockChain) SetFinalized(header *types.Header) {
 	bc.currentFinalBlock.Store(header)
+
+	var hash common.Hash
 	if header != nil {
-		rawdb.WriteFinalizedBlockHash(bc.db, header.Hash())
+		hash = header.Hash()
 		headFinalizedBlockGauge.Update(int64(header.Number.Uint64()))
 	} else {
-		rawdb.WriteFinalizedBlockHash(bc.db, common.Hash{})
 		headFinalizedBlockGauge.Update(0)
 	}
+	if !bc.cacheConfig.Replica {
+		rawdb.WriteFinalizedBlockHash(bc.db, hash)
+	}
 }
 
 // SetSafe sets the safe block.
@@ -979,6 +1026,12 @@ func (bc *BlockChain) writeHeadBlock(block *types.Block) {
 	rawdb.WriteHeadFastBlockHash(batch, block.Hash())
 	rawdb.WriteCanonicalHash(batch, block.Hash(), block.NumberU64())
 	rawdb.WriteTxLookupEntriesByBlock(batch, block)
+	if bc.cacheConfig.TxHistory {
+		bc.writeTxHistory(batch, block)
+		if rawdb.ReadTxHistoryTail(bc.db) == nil {
+			rawdb.WriteTxHistoryTail(batch, block.NumberU64())
+		}
+	}
 	rawdb.WriteHeadBlockHash(batch, block.Hash())
 
 	// Flush the whole batch into the disk, exit the node if failed
@@ -1049,7 +1102,9 @@ func (bc *BlockChain) Stop() {
 		//  - HEAD:     So we don't need to reprocess any blocks in the general case
 		//  - HEAD-1:   So we don't do large reorgs if our HEAD becomes an uncle
 		//  - HEAD-127: So we have a hard limit on the number of blocks reexecuted
-		if !bc.cacheConfig.TrieDirtyDisabled {
+		//
+		// Replicas don't own the database, the state is persisted by the primary.
+		if !bc.cacheConfig.TrieDirtyDisabled && !bc.cacheConfig.Replica {
 			triedb := bc.triedb
 
 			for _, offset := range []uint64{0, 1, TriesInMemory - 1} {
@@ -1125,6 +1180,9 @@ const (
 // InsertReceiptChain attempts to complete an already existing header chain with
 // transaction and receipt data.
 func (bc *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain []types.Receipts, ancientLimit uint64) (int, error) {
+	if bc.cacheConfig.Replica {
+		return 0, errReplicaWrite
+	}
 	// We don't require the chainMu here since we want to maximize the
 	// concurrency of header insertion and receipt insertion.
 	bc.wg.Add(1)
@@ -1432,8 +1490,8 @@ func (bc *BlockChain) writeKnownBlock(block *types.Block) error {
 }
 
 // writeBlockWithState writes block, metadata and corresponding state data to the
-// database.
-func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.Receipt, state *state.StateDB) error {
+// database, along with the internal calls of its transactions if collected.
+func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.Receipt, state *state.StateDB, calls *internalCallTracer) error {
 	// Calculate the total difficulty of the block
 	ptd := bc.GetTd(block.ParentHash(), block.NumberU64()-1)
 	if ptd == nil {
@@ -1451,9 +1509,33 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	rawdb.WriteBlock(blockBatch, block)
 	rawdb.WriteReceipts(blockBatch, block.Hash(), block.NumberU64(), receipts)
 	rawdb.WritePreimages(blockBatch, state.Preimages())
+	if calls != nil {
+		// The entries of blocks not on the canonical chain are ignored by the index
+		bc.writeInternalTxHistory(blockBatch, block, calls)
+	}
+	if bc.cacheConfig.StateDiffs || bc.cacheConfig.AccountHistory {
+		diffs := state.StateDiff()
+		if bc.cacheConfig.StateDiffs {
+			rawdb.WriteStateDiff(blockBatch, block.Hash(), block.NumberU64(), &types.StateDiff{Accounts: diffs})
+			bc.unindexStateDiffs(blockBatch, block.NumberU64())
+		}
+		if bc.cacheConfig.AccountHistory {
+			bc.writeAccountHistory(blockBatch, block.NumberU64(), block.Hash(), diffs)
+			if rawdb.ReadAccountHistoryTail(bc.db) == nil {
+				rawdb.WriteAccountHistoryTail(blockBatch, block.NumberU64())
+			}
+		}
+	}
 	if err := blockBatch.Write(); err != nil {
 		log.Crit("Failed to write block into disk", "err", err)
 	}
+	// In path mode the matured layers are flushed while committing the state,
+	// postpone the disk writes if the local validator is about to seal a block.
+	if bc.triedb.Scheme() == rawdb.PathScheme {
+		if posa, ok := bc.engine.(consensus.PoSA); ok {
+			bc.triedb.DeferFlush(!posa.EnoughDistance(bc, block.Header()))
+		}
+	}
 	// Commit all cached state changes into underlying memory database.
 	root, err := state.Commit(block.NumberU64(), bc.chainConfig.IsEIP158(block.Number()))
 	if err != nil {
@@ -1536,6 +1618,28 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	return nil
 }
 
+// unindexStateDiffs deletes the state diffs of the blocks falling out of the
+// retention window of the given head. The deletion is capped per invocation to
+// not stall block import after the retention is lowered across a restart.
+func (bc *BlockChain) unindexStateDiffs(batch ethdb.KeyValueWriter, head uint64) {
+	tail := rawdb.ReadStateDiffTail(bc.db)
+	if tail == nil {
+		rawdb.WriteStateDiffTail(batch, head)
+		return
+	}
+	limit := bc.cacheConfig.StateDiffHistory
+	if limit == 0 || head < limit {
+		return
+	}
+	number := *tail
+	for ; number <= head-limit && number < *tail+stateDiffUnindexLimit; number++ {
+		rawdb.DeleteStateDiffs(bc.db, batch, number)
+	}
+	if number != *tail {
+		rawdb.WriteStateDiffTail(batch, number)
+	}
+}
+
 // WriteBlockAndSetHead writes the given block and all associated state to the database,
 // and applies the block as the new chain head.
 func (bc *BlockChain) WriteBlockAndSetHead(block *types.Block, receipts []*types.Receipt, logs []*types.Log, state *state.StateDB, emitHeadEvent bool) (status WriteStatus, err error) {
@@ -1544,13 +1648,13 @@ func (bc *BlockChain) WriteBlockAndSetHead(block *types.Block, receipts []*types
 	}
 	defer bc.chainmu.Unlock()
 
-	return bc.writeBlockAndSetHead(block, receipts, logs, state, emitHeadEvent)
+	return bc.writeBlockAndSetHead(block, receipts, logs, state, nil, emitHeadEvent)
 }
 
 // writeBlockAndSetHead is the internal implementation of WriteBlockAndSetHead.
 // This function expects the chain mutex to be held.
-func (bc *BlockChain) writeBlockAndSetHead(block *types.Block, receipts []*types.Receipt, logs []*types.Log, state *state.StateDB, emitHeadEvent bool) (status WriteStatus, err error) {
-	if err := bc.writeBlockWithState(block, receipts, state); err != nil {
+func (bc *BlockChain) writeBlockAndSetHead(block *types.Block, receipts []*types.Receipt, logs []*types.Log, state *state.StateDB, calls *internalCallTracer, emitHeadEvent bool) (status WriteStatus, err error) {
+	if err := bc.writeBlockWithState(block, receipts, state, calls); err != nil {
 		return NonStatTy, err
 	}
 	currentBlock := bc.CurrentBlock()
@@ -1627,6 +1731,9 @@ func (bc *BlockChain) InsertChain(chain types.Blocks) (int, error) {
 	if len(chain) == 0 {
 		return 0, nil
 	}
+	if bc.cacheConfig.Replica {
+		return 0, errReplicaWrite
+	}
 	bc.blockProcFeed.Send(true)
 	defer bc.blockProcFeed.Send(false)
 
@@ -1877,7 +1984,11 @@ func (bc *BlockChain) insertChain(chain types.Blocks, setHead bool) (int, error)
 
 		// Process block using the parent state as reference point
 		pstart := time.Now()
-		statedb, receipts, logs, usedGas, err := bc.processor.Process(block, statedb, bc.vmConfig)
+		vmConfig, calls := bc.vmConfig, bc.newInternalCallTracer()
+		if calls != nil {
+			vmConfig.Tracer = calls
+		}
+		statedb, receipts, logs, usedGas, err := bc.processor.Process(block, statedb, vmConfig)
 		if err != nil {
 			bc.reportBlock(block, receipts, err)
 			followupInterrupt.Store(true)
@@ -1917,9 +2028,9 @@ func (bc *BlockChain) insertChain(chain types.Blocks, setHead bool) (int, error)
 		)
 		if !setHead {
 			// Don't set the head, only insert the block
-			err = bc.writeBlockWithState(block, receipts, statedb)
+			err = bc.writeBlockWithState(block, receipts, statedb, calls)
 		} else {
-			status, err = bc.writeBlockAndSetHead(block, receipts, logs, statedb, false)
+			status, err = bc.writeBlockAndSetHead(block, receipts, logs, statedb, calls, false)
 		}
 		followupInterrupt.Store(true)
 		if err != nil {
@@ -2396,6 +2507,9 @@ func (bc *BlockChain) InsertBlockWithoutSetHead(block *types.Block) error {
 // block. It's possible that the state of the new head is missing, and it will
 // be recovered in this function as well.
 func (bc *BlockChain) SetCanonical(head *types.Block) (common.Hash, error) {
+	if bc.cacheConfig.Replica {
+		return common.Hash{}, errReplicaWrite
+	}
 	if !bc.chainmu.TryLock() {
 		return common.Hash{}, errChainStopped
 	}
@@ -2502,19 +2616,27 @@ func (bc *BlockChain) indexBlocks(tail *uint64, head uint64, done chan struct{})
 	if head == 0 {
 		return
 	}
-
+	// Expire the chain history if requested and make sure the indexer never
+	// tries to reach into pruned block bodies.
+	limit := bc.txLookupLimit
+	if bc.pruneHistory(head) {
+		tail = rawdb.ReadTxIndexTail(bc.db)
+	}
+	if htail := bc.HistoryTail(); htail > 0 && htail <= head && (limit == 0 || head-limit+1 < htail) {
+		limit = head - htail + 1
+	}
 	// The tail flag is not existent, it means the node is just initialized
 	// and all blocks(may from ancient store) are not indexed yet.
 	if tail == nil {
 		from := uint64(0)
-		if bc.txLookupLimit != 0 && head >= bc.txLookupLimit {
-			from = head - bc.txLookupLimit + 1
+		if limit != 0 && head >= limit {
+			from = head - limit + 1
 		}
 		rawdb.IndexTransactions(bc.db, from, head+1, bc.quit)
 		return
 	}
 	// The tail flag is existent, but the whole chain is required to be indexed.
-	if bc.txLookupLimit == 0 || head < bc.txLookupLimit {
+	if limit == 0 || head < limit {
 		if *tail > 0 {
 			// It can happen when chain is rewound to a historical point which
 			// is even lower than the indexes tail, recap the indexing target
@@ -2528,15 +2650,33 @@ func (bc *BlockChain) indexBlocks(tail *uint64, head uint64, done chan struct{})
 		return
 	}
 	// Update the transaction index to the new chain state
-	if head-bc.txLookupLimit+1 < *tail {
+	if head-limit+1 < *tail {
 		// Reindex a part of missing indices and rewind index tail to HEAD-limit
-		rawdb.IndexTransactions(bc.db, head-bc.txLookupLimit+1, *tail, bc.quit)
+		rawdb.IndexTransactions(bc.db, head-limit+1, *tail, bc.quit)
 	} else {
 		// Unindex a part of stale indices and forward index tail to HEAD-limit
-		rawdb.UnindexTransactions(bc.db, *tail, head-bc.txLookupLimit+1, bc.quit)
+		rawdb.UnindexTransactions(bc.db, *tail, head-limit+1, bc.quit)
 	}
 }
 
+// pruneHistory expires the bodies and receipts of frozen blocks which fell out
+// of the configured history window. Pruning is done in batches of
+// historyPruneBatch blocks to avoid continuously truncating the freezer. The
+// returned flag reports whether anything was pruned.
+func (bc *BlockChain) pruneHistory(head uint64) bool {
+	if bc.cacheConfig.HistoryBlocks == 0 || head < bc.cacheConfig.HistoryBlocks {
+		return false
+	}
+	cutoff := head - bc.cacheConfig.HistoryBlocks + 1
+	if cutoff < bc.HistoryTail()+historyPruneBatch {
+		return false
+	}
+	if _, err := rawdb.PruneHistory(bc.db, cutoff, bc.quit); err != nil {
+		log.Warn("Failed to prune chain history", "cutoff", cutoff, "err", err)
+	}
+	return true
+}
+
 // maintainTxIndex is responsible for the construction and deletion of the
 // transaction index.
 //
@@ -2627,6 +2767,9 @@ func (bc *BlockChain) InsertHeaderChain(chain []*types.Header) (int, error) {
 	if len(chain) == 0 {
 		return 0, nil
 	}
+	if bc.cacheConfig.Replica {
+		return 0, errReplicaWrite
+	}
 	start := time.Now()
 	if i, err := bc.hc.ValidateHeaderChain(chain); err != nil {
 		return i, err
@@ -226,6 +226,11 @@ func (bc *BlockChain) GetBlocksFromHash(hash common.Hash, n int) (blocks []*type
 	return
 }
 
+// GetStateDiff retrieves the state diff of a block, nil if it's not stored.
+func (bc *BlockChain) GetStateDiff(hash common.Hash, number uint64) *types.StateDiff {
+	return rawdb.ReadStateDiff(bc.db, hash, number)
+}
+
 // GetReceiptsByHash retrieves the receipts for all transactions in a given block.
 func (bc *BlockChain) GetReceiptsByHash(hash common.Hash) types.Receipts {
 	if receipts, ok := bc.receiptsCache.Get(hash); ok {
@@ -400,6 +405,23 @@ func (bc *BlockChain) TxLookupLimit() uint64 {
 	return bc.txLookupLimit
 }
 
+// HistoryTail retrieves the number of the first block whose body and receipts
+// are still available. Blocks below it only have their headers retained.
+func (bc *BlockChain) HistoryTail() uint64 {
+	tail, _ := bc.db.Tail()
+	return tail
+}
+
+// HistoryPruned reports whether the body and receipts of the block with the
+// given hash were expired from the database. The genesis block is never pruned.
+func (bc *BlockChain) HistoryPruned(hash common.Hash) bool {
+	number := bc.hc.GetBlockNumber(hash)
+	if number == nil {
+		return false
+	}
+	return *number > 0 && *number < bc.HistoryTail()
+}
+
 // TrieDB retrieves the low level trie database used for data storage.
 func (bc *BlockChain) TrieDB() *trie.Database {
 	return bc.triedb
@@ -425,6 +447,11 @@ func (bc *BlockChain) SubscribeChainSideEvent(ch chan<- ChainSideEvent) event.Su
 	return bc.scope.Track(bc.chainSideFeed.Subscribe(ch))
 }
 
+// SubscribeFinalizedHeaderEvent registers a subscription of FinalizedHeaderEvent.
+func (bc *BlockChain) SubscribeFinalizedHeaderEvent(ch chan<- FinalizedHeaderEvent) event.Subscription {
+	return bc.scope.Track(bc.finalizedHeaderFeed.Subscribe(ch))
+}
+
 // SubscribeLogsEvent registers a subscription of []*types.Log.
 func (bc *BlockChain) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription {
 	return bc.scope.Track(bc.logsFeed.Subscribe(ch))
@@ -0,0 +1,209 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// replicaReexecLimit is the maximum number of blocks a replica re-executes
+	// on top of the most recent state persisted by the primary.
+	replicaReexecLimit = 16384
+
+	// replicaEventLimit is the maximum number of blocks a replica emits chain
+	// events for when catching up, larger gaps only announce the new head.
+	replicaEventLimit = 1024
+)
+
+// errReplicaWrite is returned by the operations modifying the chain database if
+// it's owned by another process, i.e. on read replicas.
+var errReplicaWrite = errors.New("chain database owned by the primary node, read replicas can't modify it")
+
+// ReloadHead re-reads the chain markers from a database written by another
+// process and moves the local chain to the new head. It's used by read replicas
+// after reopening the database of the primary node.
+//
+// The primary only persists the state periodically, so the blocks whose state
+// is missing are re-executed and their tries are kept in memory. The chain and
+// log events are fired as if the blocks were imported locally.
+func (bc *BlockChain) ReloadHead() error {
+	if !bc.chainmu.TryLock() {
+		return errChainStopped
+	}
+	defer bc.chainmu.Unlock()
+
+	bc.reloadFinalized()
+
+	hash := rawdb.ReadHeadBlockHash(bc.db)
+	current := bc.CurrentBlock()
+	if hash == (common.Hash{}) || (hash == current.Hash() && bc.HasState(current.Root)) {
+		return nil
+	}
+	head := bc.GetBlockByHash(hash)
+	if head == nil {
+		return fmt.Errorf("head block %#x missing", hash)
+	}
+	// Make the state of the new head available before switching to it
+	var pending []*types.Header
+	for header := head.Header(); !bc.HasState(header.Root); {
+		if len(pending) >= replicaReexecLimit {
+			return fmt.Errorf("no state available within %d blocks of head #%d", replicaReexecLimit, head.NumberU64())
+		}
+		pending = append(pending, header)
+		if header = bc.GetHeader(header.ParentHash, header.Number.Uint64()-1); header == nil {
+			return consensus.ErrUnknownAncestor
+		}
+	}
+	for i := len(pending) - 1; i >= 0; i-- {
+		if err := bc.reexecute(pending[i]); err != nil {
+			return err
+		}
+	}
+	if head.Hash() == current.Hash() {
+		return nil
+	}
+	// Gather the blocks dropped from and added to the canonical chain
+	var (
+		oldChain []*types.Block
+		newChain []*types.Block
+		oldBlock = bc.GetBlock(current.Hash(), current.Number.Uint64())
+		newBlock = head
+	)
+	for oldBlock != nil && newBlock != nil && oldBlock.Hash() != newBlock.Hash() {
+		if len(oldChain) > replicaEventLimit || len(newChain) > replicaEventLimit {
+			break
+		}
+		oldNumber, newNumber := oldBlock.NumberU64(), newBlock.NumberU64()
+		if oldNumber >= newNumber {
+			oldChain = append(oldChain, oldBlock)
+			oldBlock = bc.GetBlock(oldBlock.ParentHash(), oldNumber-1)
+		}
+		if newNumber >= oldNumber {
+			newChain = append(newChain, newBlock)
+			newBlock = bc.GetBlock(newBlock.ParentHash(), newNumber-1)
+		}
+	}
+	if oldBlock == nil || newBlock == nil || oldBlock.Hash() != newBlock.Hash() {
+		log.Warn("Replica fell behind, skipping chain events", "old", current.Number, "new", head.NumberU64())
+		oldChain, newChain = nil, []*types.Block{head}
+	}
+	// Switch over to the new head and announce the change
+	bc.hc.SetCurrentHeader(head.Header())
+
+	bc.currentSnapBlock.Store(head.Header())
+	headFastBlockGauge.Update(int64(head.NumberU64()))
+
+	bc.currentBlock.Store(head.Header())
+	headBlockGauge.Update(int64(head.NumberU64()))
+	justifiedBlockGauge.Update(int64(bc.GetJustifiedNumber(head.Header())))
+	finalizedBlockGauge.Update(int64(bc.getFinalizedNumber(head.Header())))
+
+	if len(oldChain) > 0 {
+		bc.txLookupCache.Purge()
+	}
+	var deletedLogs []*types.Log
+	for _, block := range oldChain {
+		bc.chainSideFeed.Send(ChainSideEvent{Block: block})
+		deletedLogs = append(deletedLogs, bc.collectLogs(block, true)...)
+	}
+	if len(deletedLogs) > 0 {
+		bc.rmLogsFeed.Send(RemovedLogsEvent{deletedLogs})
+	}
+	for i := len(newChain) - 1; i >= 0; i-- {
+		logs := bc.collectLogs(newChain[i], false)
+		bc.chainFeed.Send(ChainEvent{Block: newChain[i], Hash: newChain[i].Hash(), Logs: logs})
+		if len(logs) > 0 {
+			bc.logsFeed.Send(logs)
+		}
+	}
+	bc.chainHeadFeed.Send(ChainHeadEvent{Block: head})
+	if posa, ok := bc.Engine().(consensus.PoSA); ok {
+		if finalizedHeader := posa.ComprobeLastBlock(bc, head.Header()); finalizedHeader != nil {
+			bc.finalizedHeaderFeed.Send(FinalizedHeaderEvent{finalizedHeader})
+		}
+	}
+	log.Debug("Reloaded chain head", "number", head.Number(), "hash", head.Hash(), "reexecuted", len(pending), "dropped", len(oldChain))
+	return nil
+}
+
+// reloadFinalized re-reads the finalized block marker from the database. The
+// safe block follows the finalized one, same as on startup.
+func (bc *BlockChain) reloadFinalized() {
+	hash := rawdb.ReadFinalizedBlockHash(bc.db)
+	if hash == (common.Hash{}) {
+		return
+	}
+	if final := bc.CurrentFinalBlock(); final != nil && final.Hash() == hash {
+		return
+	}
+	if header := bc.GetHeaderByHash(hash); header != nil {
+		bc.currentFinalBlock.Store(header)
+		headFinalizedBlockGauge.Update(int64(header.Number.Uint64()))
+		bc.currentSafeBlock.Store(header)
+		headSafeBlockGauge.Update(int64(header.Number.Uint64()))
+	}
+}
+
+// reexecute processes a block on top of its parent state, keeping the resulting
+// trie in memory until it falls out of the TriesInMemory window. The state is
+// never flushed, the database belongs to the primary.
+func (bc *BlockChain) reexecute(header *types.Header) error {
+	block := bc.GetBlock(header.Hash(), header.Number.Uint64())
+	if block == nil {
+		return fmt.Errorf("block #%d [%x..] missing", header.Number, header.Hash().Bytes()[:4])
+	}
+	parent := bc.GetHeader(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	statedb, err := state.New(parent.Root, bc.stateCache, nil)
+	if err != nil {
+		return err
+	}
+	statedb, _, _, _, err = bc.processor.Process(block, statedb, bc.vmConfig)
+	if err != nil {
+		return err
+	}
+	root, err := statedb.Commit(block.NumberU64(), bc.chainConfig.IsEIP158(block.Number()))
+	if err != nil {
+		return err
+	}
+	if root != block.Root() {
+		return fmt.Errorf("state root mismatch in block #%d: have %x, want %x", block.NumberU64(), root, block.Root())
+	}
+	bc.triedb.Reference(root, common.Hash{})
+	bc.triegc.Push(root, -int64(block.NumberU64()))
+
+	for !bc.triegc.Empty() {
+		root, number := bc.triegc.Pop()
+		if uint64(-number)+TriesInMemory > block.NumberU64() {
+			bc.triegc.Push(root, number)
+			break
+		}
+		bc.triedb.Dereference(root)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the operations modifying the chain database are rejected on read
+// replicas instead of aborting the process.
+func TestReplicaRejectsWrites(t *testing.T) {
+	gspec := &Genesis{Config: params.TestChainConfig, BaseFee: big.NewInt(params.InitialBaseFee)}
+	db, blocks, _ := GenerateChainWithGenesis(gspec, ethash.NewFaker(), 8, nil)
+
+	primary, err := NewBlockChain(db, nil, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create primary chain: %v", err)
+	}
+	if _, err := primary.InsertChain(blocks[:4]); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	primary.Stop()
+
+	config := *defaultCacheConfig
+	config.Replica = true
+	replica, err := NewBlockChain(db, &config, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create replica chain: %v", err)
+	}
+	defer replica.Stop()
+
+	if err := replica.SetHead(2); !errors.Is(err, errReplicaWrite) {
+		t.Errorf("set head error mismatch: have %v, want %v", err, errReplicaWrite)
+	}
+	if _, err := replica.InsertChain(blocks[4:]); !errors.Is(err, errReplicaWrite) {
+		t.Errorf("insert error mismatch: have %v, want %v", err, errReplicaWrite)
+	}
+	replica.SetFinalized(blocks[1].Header())
+	if hash := rawdb.ReadFinalizedBlockHash(db); hash == blocks[1].Hash() {
+		t.Errorf("replica persisted the finalized block")
+	}
+	if head := replica.CurrentBlock(); head.Hash() != blocks[3].Hash() {
+		t.Errorf("head mismatch: have #%d, want #%d", head.Number, 4)
+	}
+}
@@ -162,7 +162,7 @@ func testBlockChainImport(chain types.Blocks, blockchain *BlockChain) error {
 		if err != nil {
 			return err
 		}
-		receipts, _, usedGas, err := blockchain.processor.Process(block, statedb, vm.Config{})
+		statedb, receipts, _, usedGas, err := blockchain.processor.Process(block, statedb, vm.Config{})
 		if err != nil {
 			blockchain.reportBlock(block, receipts, err)
 			return err
@@ -4716,3 +4716,79 @@ func TestEIP3651(t *testing.T) {
 		t.Fatalf("sender balance incorrect: expected %d, got %d", expected, actual)
 	}
 }
+
+// Tests that the state diffs of the imported blocks are stored, and that the
+// ones falling out of the retention window are deleted, also after lowering it
+// across a restart.
+func TestStateDiffRetention(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender  = crypto.PubkeyToAddress(key.PublicKey)
+		signer  = types.HomesteadSigner{}
+		genesis = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  GenesisAlloc{sender: {Balance: big.NewInt(params.Ether)}},
+		}
+	)
+	_, blocks, _ := GenerateChainWithGenesis(genesis, ethash.NewFaker(), 10, func(i int, b *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(sender), common.Address{0x01}, big.NewInt(1), params.TxGas, b.BaseFee(), nil), signer, key)
+		b.AddTx(tx)
+	})
+	check := func(db ethdb.Database, head uint64, stored uint64) {
+		t.Helper()
+
+		for _, block := range blocks[:head] {
+			number := block.NumberU64()
+			diff := rawdb.ReadStateDiff(db, block.Hash(), number)
+			if number < stored {
+				if diff != nil {
+					t.Errorf("block %d: expired state diff retained", number)
+				}
+				continue
+			}
+			if diff == nil {
+				t.Fatalf("block %d: state diff missing", number)
+			}
+			var found bool
+			for _, account := range diff.Accounts {
+				if account.Address == sender {
+					found = account.Prev.Nonce == number-1 && account.Post.Nonce == number
+				}
+			}
+			if !found {
+				t.Errorf("block %d: sender nonce change missing", number)
+			}
+		}
+		if tail := rawdb.ReadStateDiffTail(db); tail == nil || *tail != stored {
+			t.Errorf("state diff tail mismatch: have %v, want %d", tail, stored)
+		}
+	}
+	config := *defaultCacheConfig
+	config.TrieDirtyDisabled = true
+	config.StateDiffs = true
+	config.StateDiffHistory = 3
+
+	db := rawdb.NewMemoryDatabase()
+	chain, err := NewBlockChain(db, &config, genesis, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks[:8]); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	check(db, 8, 6)
+	chain.Stop()
+
+	// Lower the retention, the expired diffs are deleted on the next import
+	config.StateDiffHistory = 1
+	chain, err = NewBlockChain(db, &config, genesis, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks[8:]); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	check(db, 10, 10)
+}
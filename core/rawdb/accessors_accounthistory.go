@@ -0,0 +1,141 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/binary"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// AccountHistory is the nonce and balance of an account after a block which
+// changed either of them.
+type AccountHistory struct {
+	Number  uint64 `rlp:"-"` // Number of the block changing the account
+	Nonce   uint64
+	Balance *big.Int
+}
+
+// ReadAccountHistory retrieves the latest change of the account in the canonical
+// chain at or before the given block number, nil if there's none indexed.
+//
+// The entries are keyed by the inverted block number, the first canonical entry
+// found iterating from the requested number is the most recent one.
+func ReadAccountHistory(db ethdb.Database, address common.Address, number uint64) *AccountHistory {
+	prefix := append(append([]byte{}, accountHistoryPrefix...), address.Bytes()...)
+	it := db.NewIterator(prefix, encodeBlockNumber(^number))
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(prefix)+8+common.HashLength {
+			continue
+		}
+		var (
+			n    = ^binary.BigEndian.Uint64(key[len(prefix):])
+			hash = common.BytesToHash(key[len(prefix)+8:])
+		)
+		// Skip the changes made by blocks on side chains
+		if ReadCanonicalHash(db, n) != hash {
+			continue
+		}
+		entry := new(AccountHistory)
+		if err := rlp.DecodeBytes(it.Value(), entry); err != nil {
+			log.Error("Invalid account history RLP", "address", address, "number", n, "err", err)
+			return nil
+		}
+		entry.Number = n
+		return entry
+	}
+	return nil
+}
+
+// WriteAccountHistory stores the nonce and balance of an account after it got
+// changed by the given block.
+func WriteAccountHistory(db ethdb.KeyValueWriter, address common.Address, number uint64, hash common.Hash, nonce uint64, balance *big.Int) {
+	data, err := rlp.EncodeToBytes(&AccountHistory{Nonce: nonce, Balance: balance})
+	if err != nil {
+		log.Crit("Failed to RLP encode account history", "err", err)
+	}
+	if err := db.Put(accountHistoryKey(address, number, hash), data); err != nil {
+		log.Crit("Failed to store account history", "err", err)
+	}
+}
+
+// ReadAccountHistoryTail retrieves the number of the oldest block whose account
+// changes have been indexed.
+func ReadAccountHistoryTail(db ethdb.KeyValueReader) *uint64 {
+	data, _ := db.Get(accountHistoryTailKey)
+	if len(data) != 8 {
+		return nil
+	}
+	number := binary.BigEndian.Uint64(data)
+	return &number
+}
+
+// WriteAccountHistoryTail stores the number of the oldest block whose account
+// changes have been indexed into the database.
+func WriteAccountHistoryTail(db ethdb.KeyValueWriter, number uint64) {
+	if err := db.Put(accountHistoryTailKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store the account history tail", "err", err)
+	}
+}
+
+// DeleteAccountHistoryTail removes the account history tail from the database,
+// invalidating the entire index.
+func DeleteAccountHistoryTail(db ethdb.KeyValueWriter) {
+	if err := db.Delete(accountHistoryTailKey); err != nil {
+		log.Crit("Failed to delete the account history tail", "err", err)
+	}
+}
+
+// HasAccountHistory reports whether any account history entry is stored in the
+// database.
+func HasAccountHistory(db ethdb.Iteratee) bool {
+	it := db.NewIterator(accountHistoryPrefix, nil)
+	defer it.Release()
+	return it.Next()
+}
+
+// DeleteAccountHistory removes the account history tail, invalidating the index,
+// then all its entries from the database.
+func DeleteAccountHistory(db ethdb.Database) {
+	DeleteAccountHistoryTail(db)
+
+	batch := db.NewBatch()
+	it := db.NewIterator(accountHistoryPrefix, nil)
+	defer it.Release()
+
+	for it.Next() {
+		if err := batch.Delete(common.CopyBytes(it.Key())); err != nil {
+			log.Crit("Failed to delete account history", "err", err)
+		}
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				log.Crit("Failed to delete account history", "err", err)
+			}
+			batch.Reset()
+		}
+	}
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to delete account history", "err", err)
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Tests that the latest canonical account change at or before a block is
+// retrieved from the account history index.
+func TestAccountHistory(t *testing.T) {
+	var (
+		db      = NewMemoryDatabase()
+		address = common.HexToAddress("0x1")
+		other   = common.HexToAddress("0x2")
+		side    = common.HexToHash("0xdead")
+	)
+	for i := uint64(0); i < 10; i++ {
+		WriteCanonicalHash(db, common.Hash{byte(i + 1)}, i)
+	}
+	WriteAccountHistory(db, address, 2, common.Hash{3}, 1, big.NewInt(2))
+	WriteAccountHistory(db, address, 5, common.Hash{6}, 2, big.NewInt(5))
+	WriteAccountHistory(db, address, 7, side, 3, big.NewInt(7))
+	WriteAccountHistory(db, other, 4, common.Hash{5}, 9, big.NewInt(9))
+
+	tests := []struct {
+		number  uint64
+		changed uint64
+		nonce   uint64
+		balance int64
+	}{
+		{2, 2, 1, 2},
+		{3, 2, 1, 2},
+		{5, 5, 2, 5},
+		{9, 5, 2, 5}, // side chain change skipped
+	}
+	for _, tt := range tests {
+		entry := ReadAccountHistory(db, address, tt.number)
+		if entry == nil {
+			t.Fatalf("block %d: account history missing", tt.number)
+		}
+		if entry.Number != tt.changed || entry.Nonce != tt.nonce || entry.Balance.Int64() != tt.balance {
+			t.Fatalf("block %d: entry mismatch: have %d/%d/%v, want %d/%d/%d", tt.number, entry.Number, entry.Nonce, entry.Balance, tt.changed, tt.nonce, tt.balance)
+		}
+	}
+	if entry := ReadAccountHistory(db, address, 1); entry != nil {
+		t.Fatalf("unexpected account history before first change: %+v", entry)
+	}
+	if ReadAccountHistoryTail(db) != nil {
+		t.Fatal("unexpected account history tail")
+	}
+	WriteAccountHistoryTail(db, 3)
+	if tail := ReadAccountHistoryTail(db); tail == nil || *tail != 3 {
+		t.Fatalf("account history tail mismatch: %v", tail)
+	}
+}
@@ -17,6 +17,7 @@
 package rawdb
 
 import (
+	"encoding/binary"
 	"encoding/json"
 	"math/big"
 	"time"
@@ -195,3 +196,20 @@ func WriteTransitionStatus(db ethdb.KeyValueWriter, data []byte) {
 		log.Crit("Failed to store the eth2 transition status", "err", err)
 	}
 }
+
+// ReadZephyriaGasTarget retrieves the gas limit target of the Zephyria epoch
+// with the given block hash.
+func ReadZephyriaGasTarget(db ethdb.KeyValueReader, hash common.Hash) (uint64, bool) {
+	blob, err := db.Get(zephyriaGasTargetKey(hash))
+	if err != nil || len(blob) != 8 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(blob), true
+}
+
+// WriteZephyriaGasTarget stores the gas limit target of the Zephyria epoch with
+// the given block hash. The error is returned instead of being fatal, as the
+// target can be recomputed while the epoch state is available.
+func WriteZephyriaGasTarget(db ethdb.KeyValueWriter, hash common.Hash, target uint64) error {
+	return db.Put(zephyriaGasTargetKey(hash), binary.BigEndian.AppendUint64(nil, target))
+}
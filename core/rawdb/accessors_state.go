@@ -160,6 +160,50 @@ func DeleteTrieJournal(db ethdb.KeyValueWriter) {
 	}
 }
 
+// ReadOnlinePruneStatus retrieves the serialized progress of an online state
+// pruning, if one is in progress.
+func ReadOnlinePruneStatus(db ethdb.KeyValueReader) []byte {
+	data, _ := db.Get(onlinePruneStatusKey)
+	return data
+}
+
+// WriteOnlinePruneStatus stores the serialized progress of an online state
+// pruning.
+func WriteOnlinePruneStatus(db ethdb.KeyValueWriter, status []byte) {
+	if err := db.Put(onlinePruneStatusKey, status); err != nil {
+		log.Crit("Failed to store online prune status", "err", err)
+	}
+}
+
+// DeleteOnlinePruneStatus deletes the progress of a finished online state
+// pruning.
+func DeleteOnlinePruneStatus(db ethdb.KeyValueWriter) {
+	if err := db.Delete(onlinePruneStatusKey); err != nil {
+		log.Crit("Failed to remove online prune status", "err", err)
+	}
+}
+
+// WriteOnlinePruneMarker records that the trie node with the given hash was
+// persisted while an online state pruning is in progress.
+func WriteOnlinePruneMarker(db ethdb.KeyValueWriter, hash common.Hash) {
+	if err := db.Put(onlinePruneMarkerKey(hash), nil); err != nil {
+		log.Crit("Failed to store online prune marker", "err", err)
+	}
+}
+
+// DeleteOnlinePruneMarker deletes the online pruning marker of a trie node.
+func DeleteOnlinePruneMarker(db ethdb.KeyValueWriter, hash common.Hash) {
+	if err := db.Delete(onlinePruneMarkerKey(hash)); err != nil {
+		log.Crit("Failed to remove online prune marker", "err", err)
+	}
+}
+
+// IterateOnlinePruneMarkers returns an iterator over all the online pruning
+// markers. The marked node hash is the trailing 32 bytes of the keys.
+func IterateOnlinePruneMarkers(db ethdb.Iteratee) ethdb.Iterator {
+	return NewKeyLengthIterator(db.NewIterator(onlinePruneMarkerPrefix, nil), len(onlinePruneMarkerPrefix)+common.HashLength)
+}
+
 // ReadStateHistoryMeta retrieves the metadata corresponding to the specified
 // state history. Compute the position of state history in freezer by minus
 // one since the id of first state history starts from one(zero for initial
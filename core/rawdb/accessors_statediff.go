@@ -0,0 +1,103 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/binary"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// ReadStateDiff retrieves the state diff of the block with the given hash and
+// number, nil if it's not stored.
+func ReadStateDiff(db ethdb.KeyValueReader, hash common.Hash, number uint64) *types.StateDiff {
+	data, _ := db.Get(stateDiffKey(number, hash))
+	if len(data) == 0 {
+		return nil
+	}
+	diff := new(types.StateDiff)
+	if err := rlp.DecodeBytes(data, diff); err != nil {
+		log.Error("Invalid state diff RLP", "hash", hash, "err", err)
+		return nil
+	}
+	diff.Number, diff.Hash = number, hash
+	return diff
+}
+
+// HasStateDiff verifies the existence of a state diff of the block.
+func HasStateDiff(db ethdb.KeyValueReader, hash common.Hash, number uint64) bool {
+	ok, _ := db.Has(stateDiffKey(number, hash))
+	return ok
+}
+
+// WriteStateDiff stores the state diff of a block into the database.
+func WriteStateDiff(db ethdb.KeyValueWriter, hash common.Hash, number uint64, diff *types.StateDiff) {
+	data, err := rlp.EncodeToBytes(diff)
+	if err != nil {
+		log.Crit("Failed to RLP encode state diff", "err", err)
+	}
+	if err := db.Put(stateDiffKey(number, hash), data); err != nil {
+		log.Crit("Failed to store state diff", "err", err)
+	}
+}
+
+// DeleteStateDiff removes the state diff of a block from the database.
+func DeleteStateDiff(db ethdb.KeyValueWriter, hash common.Hash, number uint64) {
+	if err := db.Delete(stateDiffKey(number, hash)); err != nil {
+		log.Crit("Failed to delete state diff", "err", err)
+	}
+}
+
+// DeleteStateDiffs removes the state diffs of all the blocks, canonical or
+// side chain, at the given number from the database.
+func DeleteStateDiffs(db ethdb.Database, batch ethdb.KeyValueWriter, number uint64) {
+	prefix := append(append([]byte{}, stateDiffPrefix...), encodeBlockNumber(number)...)
+	it := db.NewIterator(prefix, nil)
+	defer it.Release()
+
+	for it.Next() {
+		if len(it.Key()) != len(prefix)+common.HashLength {
+			continue
+		}
+		if err := batch.Delete(common.CopyBytes(it.Key())); err != nil {
+			log.Crit("Failed to delete state diff", "err", err)
+		}
+	}
+}
+
+// ReadStateDiffTail retrieves the number of the oldest block whose state diff
+// is retained.
+func ReadStateDiffTail(db ethdb.KeyValueReader) *uint64 {
+	data, _ := db.Get(stateDiffTailKey)
+	if len(data) != 8 {
+		return nil
+	}
+	number := binary.BigEndian.Uint64(data)
+	return &number
+}
+
+// WriteStateDiffTail stores the number of the oldest block whose state diff is
+// retained into the database.
+func WriteStateDiffTail(db ethdb.KeyValueWriter, number uint64) {
+	if err := db.Put(stateDiffTailKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store the state diff tail", "err", err)
+	}
+}
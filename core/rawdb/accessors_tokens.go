@@ -0,0 +1,300 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/binary"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// TokenStandard is the standard of a token contract.
+type TokenStandard uint8
+
+const (
+	TokenERC20 TokenStandard = iota + 1
+	TokenERC721
+	TokenERC1155
+)
+
+// String returns the name of the token standard.
+func (s TokenStandard) String() string {
+	switch s {
+	case TokenERC20:
+		return "ERC-20"
+	case TokenERC721:
+		return "ERC-721"
+	case TokenERC1155:
+		return "ERC-1155"
+	default:
+		return "unknown"
+	}
+}
+
+// TokenTransfer is a transfer of tokens decoded from a log.
+type TokenTransfer struct {
+	Token    common.Address
+	Standard TokenStandard
+	From     common.Address
+	To       common.Address
+	ID       *big.Int // Identifier of the token, zero for ERC-20
+	Value    *big.Int // Amount of tokens transferred, one for ERC-721
+	TxHash   common.Hash
+	LogIndex uint
+
+	BlockNumber uint64      `rlp:"-"`
+	BlockHash   common.Hash `rlp:"-"`
+}
+
+// TokenBalance is the balance of a holder in a token, or for the multi-token
+// standards, in one of the tokens of the contract.
+type TokenBalance struct {
+	Token    common.Address
+	Standard TokenStandard
+	ID       *big.Int
+	Balance  *big.Int // Negative if the contract doesn't log all its mints
+}
+
+// tokenBalanceRLP is the storage encoding of a token balance.
+type tokenBalanceRLP struct {
+	Standard TokenStandard
+	Negative bool
+	Amount   *big.Int
+}
+
+// ReadTokenTransfers retrieves the canonical token transfers of a holder from
+// at most limit logs, newest first, starting from the log at the given
+// position.
+func ReadTokenTransfers(db ethdb.Database, holder common.Address, number uint64, logIndex uint32, limit int) []*TokenTransfer {
+	prefix := append(append([]byte{}, tokenTransferPrefix...), holder.Bytes()...)
+	start := binary.BigEndian.AppendUint32(encodeBlockNumber(^number), ^logIndex)
+	it := db.NewIterator(prefix, start)
+	defer it.Release()
+
+	var transfers []*TokenTransfer
+	for logs := 0; logs < limit && it.Next(); {
+		key := it.Key()
+		if len(key) != len(prefix)+8+4+common.HashLength {
+			continue
+		}
+		var (
+			n    = ^binary.BigEndian.Uint64(key[len(prefix):])
+			hash = common.BytesToHash(key[len(prefix)+12:])
+		)
+		// Skip the transfers of blocks on side chains
+		if ReadCanonicalHash(db, n) != hash {
+			continue
+		}
+		var entries []*TokenTransfer
+		if err := rlp.DecodeBytes(it.Value(), &entries); err != nil {
+			log.Error("Invalid token transfers RLP", "holder", holder, "number", n, "err", err)
+			continue
+		}
+		for _, transfer := range entries {
+			transfer.BlockNumber, transfer.BlockHash = n, hash
+		}
+		transfers = append(transfers, entries...)
+		logs++
+	}
+	return transfers
+}
+
+// WriteTokenTransfers stores the token transfers of a log involving a holder.
+func WriteTokenTransfers(db ethdb.KeyValueWriter, holder common.Address, number uint64, hash common.Hash, logIndex uint32, transfers []*TokenTransfer) {
+	data, err := rlp.EncodeToBytes(transfers)
+	if err != nil {
+		log.Crit("Failed to RLP encode token transfers", "err", err)
+	}
+	if err := db.Put(tokenTransferKey(holder, number, logIndex, hash), data); err != nil {
+		log.Crit("Failed to store token transfers", "err", err)
+	}
+}
+
+// ReadTokenJournal retrieves the token transfers indexed for a block, which are
+// reverted if the block leaves the canonical chain.
+func ReadTokenJournal(db ethdb.KeyValueReader, hash common.Hash, number uint64) []*TokenTransfer {
+	data, _ := db.Get(tokenJournalKey(number, hash))
+	if len(data) == 0 {
+		return nil
+	}
+	var transfers []*TokenTransfer
+	if err := rlp.DecodeBytes(data, &transfers); err != nil {
+		log.Error("Invalid token journal RLP", "hash", hash, "err", err)
+		return nil
+	}
+	return transfers
+}
+
+// WriteTokenJournal stores the token transfers indexed for a block.
+func WriteTokenJournal(db ethdb.KeyValueWriter, hash common.Hash, number uint64, transfers []*TokenTransfer) {
+	data, err := rlp.EncodeToBytes(transfers)
+	if err != nil {
+		log.Crit("Failed to RLP encode token journal", "err", err)
+	}
+	if err := db.Put(tokenJournalKey(number, hash), data); err != nil {
+		log.Crit("Failed to store token journal", "err", err)
+	}
+}
+
+// ReadTokenBalance retrieves the balance of a holder in a token, zero if none.
+func ReadTokenBalance(db ethdb.KeyValueReader, holder common.Address, token common.Address, id *big.Int) *big.Int {
+	data, _ := db.Get(tokenBalanceKey(holder, token, common.BigToHash(id)))
+	if len(data) == 0 {
+		return new(big.Int)
+	}
+	var enc tokenBalanceRLP
+	if err := rlp.DecodeBytes(data, &enc); err != nil {
+		log.Error("Invalid token balance RLP", "holder", holder, "token", token, "err", err)
+		return new(big.Int)
+	}
+	if enc.Negative {
+		return enc.Amount.Neg(enc.Amount)
+	}
+	return enc.Amount
+}
+
+// ReadTokenBalances retrieves the non-zero balances of a holder in all tokens.
+func ReadTokenBalances(db ethdb.Iteratee, holder common.Address) []*TokenBalance {
+	prefix := append(append([]byte{}, tokenBalancePrefix...), holder.Bytes()...)
+	it := db.NewIterator(prefix, nil)
+	defer it.Release()
+
+	var balances []*TokenBalance
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(prefix)+common.AddressLength+common.HashLength {
+			continue
+		}
+		var enc tokenBalanceRLP
+		if err := rlp.DecodeBytes(it.Value(), &enc); err != nil {
+			log.Error("Invalid token balance RLP", "holder", holder, "err", err)
+			continue
+		}
+		balance := &TokenBalance{
+			Token:    common.BytesToAddress(key[len(prefix) : len(prefix)+common.AddressLength]),
+			Standard: enc.Standard,
+			ID:       new(big.Int).SetBytes(key[len(prefix)+common.AddressLength:]),
+			Balance:  enc.Amount,
+		}
+		if enc.Negative {
+			balance.Balance.Neg(balance.Balance)
+		}
+		balances = append(balances, balance)
+	}
+	return balances
+}
+
+// WriteTokenBalance stores the balance of a holder in a token, deleting it if
+// zero.
+func WriteTokenBalance(db ethdb.KeyValueWriter, holder common.Address, token common.Address, standard TokenStandard, id *big.Int, balance *big.Int) {
+	key := tokenBalanceKey(holder, token, common.BigToHash(id))
+	if balance.Sign() == 0 {
+		if err := db.Delete(key); err != nil {
+			log.Crit("Failed to delete token balance", "err", err)
+		}
+		return
+	}
+	data, err := rlp.EncodeToBytes(&tokenBalanceRLP{
+		Standard: standard,
+		Negative: balance.Sign() < 0,
+		Amount:   new(big.Int).Abs(balance),
+	})
+	if err != nil {
+		log.Crit("Failed to RLP encode token balance", "err", err)
+	}
+	if err := db.Put(key, data); err != nil {
+		log.Crit("Failed to store token balance", "err", err)
+	}
+}
+
+// ReadTokenIndexHead retrieves the number and hash of the last block whose
+// token transfers have been indexed. The flag reports whether the index exists.
+func ReadTokenIndexHead(db ethdb.KeyValueReader) (uint64, common.Hash, bool) {
+	data, _ := db.Get(tokenIndexHeadKey)
+	if len(data) != 8+common.HashLength {
+		return 0, common.Hash{}, false
+	}
+	return binary.BigEndian.Uint64(data), common.BytesToHash(data[8:]), true
+}
+
+// WriteTokenIndexHead stores the number and hash of the last block whose token
+// transfers have been indexed into the database.
+func WriteTokenIndexHead(db ethdb.KeyValueWriter, number uint64, hash common.Hash) {
+	if err := db.Put(tokenIndexHeadKey, append(encodeBlockNumber(number), hash.Bytes()...)); err != nil {
+		log.Crit("Failed to store the token index head", "err", err)
+	}
+}
+
+// ReadTokenIndexStopped retrieves the number of the block whose unavailable
+// receipts stopped the token indexing. The flag reports whether it stopped.
+func ReadTokenIndexStopped(db ethdb.KeyValueReader) (uint64, bool) {
+	data, _ := db.Get(tokenIndexStoppedKey)
+	if len(data) != 8 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(data), true
+}
+
+// WriteTokenIndexStopped stores the number of the block whose unavailable
+// receipts stopped the token indexing into the database.
+func WriteTokenIndexStopped(db ethdb.KeyValueWriter, number uint64) {
+	if err := db.Put(tokenIndexStoppedKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store the token index stop", "err", err)
+	}
+}
+
+// DeleteTokenIndexStopped removes the token indexing stop marker from the
+// database.
+func DeleteTokenIndexStopped(db ethdb.KeyValueWriter) {
+	if err := db.Delete(tokenIndexStoppedKey); err != nil {
+		log.Crit("Failed to delete the token index stop", "err", err)
+	}
+}
+
+// DeleteTokenIndex removes the token index head, its stop marker and all the
+// token balances from the database. The transfers are keyed by block hash, they
+// are overwritten with the same content when the chain is indexed again.
+func DeleteTokenIndex(db ethdb.Database) {
+	batch := db.NewBatch()
+	if err := batch.Delete(tokenIndexHeadKey); err != nil {
+		log.Crit("Failed to delete the token index head", "err", err)
+	}
+	if err := batch.Delete(tokenIndexStoppedKey); err != nil {
+		log.Crit("Failed to delete the token index stop", "err", err)
+	}
+	it := db.NewIterator(tokenBalancePrefix, nil)
+	defer it.Release()
+
+	for it.Next() {
+		if err := batch.Delete(common.CopyBytes(it.Key())); err != nil {
+			log.Crit("Failed to delete token balance", "err", err)
+		}
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				log.Crit("Failed to delete token balances", "err", err)
+			}
+			batch.Reset()
+		}
+	}
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to delete token balances", "err", err)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Tests that the canonical token transfers of a holder are retrieved newest first
+// from the token index, starting at the requested position.
+func TestTokenTransfers(t *testing.T) {
+	var (
+		db     = NewMemoryDatabase()
+		holder = common.HexToAddress("0x1")
+		token  = common.HexToAddress("0x2")
+		side   = common.HexToHash("0xdead")
+	)
+	for i := uint64(0); i < 10; i++ {
+		WriteCanonicalHash(db, common.Hash{byte(i + 1)}, i)
+	}
+	transfer := func(tx byte, index uint, values ...int64) []*TokenTransfer {
+		var transfers []*TokenTransfer
+		for i, value := range values {
+			transfers = append(transfers, &TokenTransfer{
+				Token:    token,
+				Standard: TokenERC1155,
+				To:       holder,
+				ID:       big.NewInt(int64(i)),
+				Value:    big.NewInt(value),
+				TxHash:   common.Hash{tx},
+				LogIndex: index,
+			})
+		}
+		return transfers
+	}
+	WriteTokenTransfers(db, holder, 2, common.Hash{3}, 0, transfer(0x20, 0, 1))
+	WriteTokenTransfers(db, holder, 5, common.Hash{6}, 1, transfer(0x51, 1, 2, 3))
+	WriteTokenTransfers(db, holder, 5, common.Hash{6}, 3, transfer(0x53, 3, 4))
+	WriteTokenTransfers(db, holder, 7, side, 0, transfer(0x70, 0, 5))
+
+	tests := []struct {
+		number   uint64
+		logIndex uint32
+		limit    int
+		want     []common.Hash
+	}{
+		{math.MaxUint64, math.MaxUint32, 10, []common.Hash{{0x53}, {0x51}, {0x51}, {0x20}}}, // side chain skipped
+		{math.MaxUint64, math.MaxUint32, 2, []common.Hash{{0x53}, {0x51}, {0x51}}},
+		{5, 2, 10, []common.Hash{{0x51}, {0x51}, {0x20}}},
+		{1, math.MaxUint32, 10, nil},
+	}
+	for i, tt := range tests {
+		transfers := ReadTokenTransfers(db, holder, tt.number, tt.logIndex, tt.limit)
+		if len(transfers) != len(tt.want) {
+			t.Fatalf("test %d: transfer count mismatch: have %d, want %d", i, len(transfers), len(tt.want))
+		}
+		for j, transfer := range transfers {
+			if transfer.TxHash != tt.want[j] {
+				t.Errorf("test %d: transfer %d mismatch: have %x, want %x", i, j, transfer.TxHash, tt.want[j])
+			}
+		}
+	}
+	transfers := ReadTokenTransfers(db, holder, 5, 1, 1)
+	if tr := transfers[1]; tr.BlockNumber != 5 || tr.BlockHash != (common.Hash{6}) || tr.LogIndex != 1 || tr.ID.Int64() != 1 || tr.Value.Int64() != 3 {
+		t.Errorf("transfer metadata mismatch: %+v", tr)
+	}
+}
+
+// Tests that the token balances of a holder are stored signed and removed once
+// they drop to zero, and that the index can be wiped.
+func TestTokenBalances(t *testing.T) {
+	var (
+		db     = NewMemoryDatabase()
+		holder = common.HexToAddress("0x1")
+		erc20  = common.HexToAddress("0x2")
+		erc721 = common.HexToAddress("0x3")
+	)
+	WriteTokenBalance(db, holder, erc20, TokenERC20, new(big.Int), big.NewInt(-5))
+	WriteTokenBalance(db, holder, erc721, TokenERC721, big.NewInt(7), big.NewInt(1))
+	WriteTokenBalance(db, holder, erc721, TokenERC721, big.NewInt(8), big.NewInt(1))
+	WriteTokenBalance(db, common.HexToAddress("0x4"), erc20, TokenERC20, new(big.Int), big.NewInt(3))
+
+	if balance := ReadTokenBalance(db, holder, erc20, new(big.Int)); balance.Int64() != -5 {
+		t.Errorf("balance mismatch: have %v, want -5", balance)
+	}
+	WriteTokenBalance(db, holder, erc721, TokenERC721, big.NewInt(8), new(big.Int))
+	if balance := ReadTokenBalance(db, holder, erc721, big.NewInt(8)); balance.Sign() != 0 {
+		t.Errorf("balance mismatch: have %v, want 0", balance)
+	}
+	balances := ReadTokenBalances(db, holder)
+	if len(balances) != 2 {
+		t.Fatalf("balance count mismatch: have %d, want 2", len(balances))
+	}
+	if b := balances[0]; b.Token != erc20 || b.Standard != TokenERC20 || b.ID.Sign() != 0 || b.Balance.Int64() != -5 {
+		t.Errorf("balance 0 mismatch: %+v", b)
+	}
+	if b := balances[1]; b.Token != erc721 || b.Standard != TokenERC721 || b.ID.Int64() != 7 || b.Balance.Int64() != 1 {
+		t.Errorf("balance 1 mismatch: %+v", b)
+	}
+	if _, _, ok := ReadTokenIndexHead(db); ok {
+		t.Fatal("unexpected token index head")
+	}
+	WriteTokenIndexHead(db, 3, common.Hash{4})
+	if number, hash, ok := ReadTokenIndexHead(db); !ok || number != 3 || hash != (common.Hash{4}) {
+		t.Fatalf("token index head mismatch: %d %x %v", number, hash, ok)
+	}
+	DeleteTokenIndex(db)
+	if _, _, ok := ReadTokenIndexHead(db); ok {
+		t.Fatal("token index head not deleted")
+	}
+	if balances := ReadTokenBalances(db, holder); len(balances) != 0 {
+		t.Fatalf("token balances not deleted: %d", len(balances))
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/binary"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// TxRole is the set of roles an address has in a transaction.
+type TxRole uint8
+
+const (
+	TxRoleSender    TxRole = 1 << iota // Address signed the transaction
+	TxRoleRecipient                    // Address is the recipient of the transaction
+	TxRoleCreation                     // Address is the contract created by the transaction
+	TxRoleInternal                     // Address was called by a contract during the transaction
+)
+
+// AddressTransaction is a transaction involving an address, as indexed by the
+// transaction history index.
+type AddressTransaction struct {
+	BlockNumber uint64
+	BlockHash   common.Hash
+	Index       uint32
+	Hash        common.Hash
+	Roles       TxRole
+}
+
+// ReadAddressTransactions retrieves at most limit canonical transactions of the
+// address, newest first, starting from the transaction at the given position.
+//
+// The entries are keyed by the inverted block number and transaction index, the
+// iteration from the requested position yields the older transactions in order.
+func ReadAddressTransactions(db ethdb.Database, address common.Address, number uint64, index uint32, limit int) []*AddressTransaction {
+	prefix := append(append([]byte{}, txHistoryPrefix...), address.Bytes()...)
+	start := binary.BigEndian.AppendUint32(encodeBlockNumber(^number), ^index)
+	it := db.NewIterator(prefix, start)
+	defer it.Release()
+
+	var txs []*AddressTransaction
+	for len(txs) < limit && it.Next() {
+		key, value := it.Key(), it.Value()
+		if len(key) != len(prefix)+8+4+common.HashLength || len(value) != common.HashLength+1 {
+			continue
+		}
+		tx := &AddressTransaction{
+			BlockNumber: ^binary.BigEndian.Uint64(key[len(prefix):]),
+			Index:       ^binary.BigEndian.Uint32(key[len(prefix)+8:]),
+			BlockHash:   common.BytesToHash(key[len(prefix)+12:]),
+			Hash:        common.BytesToHash(value[:common.HashLength]),
+			Roles:       TxRole(value[common.HashLength]),
+		}
+		// Skip the transactions of blocks on side chains
+		if ReadCanonicalHash(db, tx.BlockNumber) != tx.BlockHash {
+			continue
+		}
+		txs = append(txs, tx)
+	}
+	return txs
+}
+
+// WriteAddressTransaction stores the roles of an address in the transaction at
+// the given position of a block.
+func WriteAddressTransaction(db ethdb.KeyValueWriter, address common.Address, number uint64, hash common.Hash, index uint32, txHash common.Hash, roles TxRole) {
+	value := append(txHash.Bytes(), byte(roles))
+	if err := db.Put(txHistoryKey(address, number, index, hash), value); err != nil {
+		log.Crit("Failed to store address transaction", "err", err)
+	}
+}
+
+// ReadTxHistoryTail retrieves the number of the oldest block whose transactions
+// have been indexed by address.
+func ReadTxHistoryTail(db ethdb.KeyValueReader) *uint64 {
+	data, _ := db.Get(txHistoryTailKey)
+	if len(data) != 8 {
+		return nil
+	}
+	number := binary.BigEndian.Uint64(data)
+	return &number
+}
+
+// WriteTxHistoryTail stores the number of the oldest block whose transactions
+// have been indexed by address into the database.
+func WriteTxHistoryTail(db ethdb.KeyValueWriter, number uint64) {
+	if err := db.Put(txHistoryTailKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store the transaction history tail", "err", err)
+	}
+}
+
+// DeleteTxHistoryTail removes the transaction history tail from the database,
+// invalidating the entire index.
+func DeleteTxHistoryTail(db ethdb.KeyValueWriter) {
+	if err := db.Delete(txHistoryTailKey); err != nil {
+		log.Crit("Failed to delete the transaction history tail", "err", err)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Tests that the canonical transactions of an address are retrieved newest first
+// from the transaction history index, starting at the requested position.
+func TestAddressTransactions(t *testing.T) {
+	var (
+		db      = NewMemoryDatabase()
+		address = common.HexToAddress("0x1")
+		other   = common.HexToAddress("0x2")
+		side    = common.HexToHash("0xdead")
+	)
+	for i := uint64(0); i < 10; i++ {
+		WriteCanonicalHash(db, common.Hash{byte(i + 1)}, i)
+	}
+	WriteAddressTransaction(db, address, 2, common.Hash{3}, 0, common.Hash{0x20}, TxRoleSender)
+	WriteAddressTransaction(db, address, 5, common.Hash{6}, 1, common.Hash{0x51}, TxRoleRecipient)
+	WriteAddressTransaction(db, address, 5, common.Hash{6}, 3, common.Hash{0x53}, TxRoleSender|TxRoleRecipient)
+	WriteAddressTransaction(db, address, 7, side, 0, common.Hash{0x70}, TxRoleSender)
+	WriteAddressTransaction(db, address, 8, common.Hash{9}, 2, common.Hash{0x82}, TxRoleInternal)
+	WriteAddressTransaction(db, other, 4, common.Hash{5}, 0, common.Hash{0x40}, TxRoleCreation)
+
+	tests := []struct {
+		number uint64
+		index  uint32
+		limit  int
+		want   []common.Hash
+	}{
+		{math.MaxUint64, math.MaxUint32, 10, []common.Hash{{0x82}, {0x53}, {0x51}, {0x20}}}, // side chain skipped
+		{math.MaxUint64, math.MaxUint32, 2, []common.Hash{{0x82}, {0x53}}},
+		{5, 3, 2, []common.Hash{{0x53}, {0x51}}},
+		{5, 2, 10, []common.Hash{{0x51}, {0x20}}},
+		{1, math.MaxUint32, 10, nil},
+	}
+	for i, tt := range tests {
+		txs := ReadAddressTransactions(db, address, tt.number, tt.index, tt.limit)
+		if len(txs) != len(tt.want) {
+			t.Fatalf("test %d: transaction count mismatch: have %d, want %d", i, len(txs), len(tt.want))
+		}
+		for j, tx := range txs {
+			if tx.Hash != tt.want[j] {
+				t.Errorf("test %d: transaction %d mismatch: have %x, want %x", i, j, tx.Hash, tt.want[j])
+			}
+		}
+	}
+	txs := ReadAddressTransactions(db, address, 5, 3, 1)
+	if tx := txs[0]; tx.BlockNumber != 5 || tx.BlockHash != (common.Hash{6}) || tx.Index != 3 || tx.Roles != TxRoleSender|TxRoleRecipient {
+		t.Errorf("transaction metadata mismatch: %+v", tx)
+	}
+	if ReadTxHistoryTail(db) != nil {
+		t.Fatal("unexpected transaction history tail")
+	}
+	WriteTxHistoryTail(db, 3)
+	if tail := ReadTxHistoryTail(db); tail == nil || *tail != 3 {
+		t.Fatalf("transaction history tail mismatch: %v", tail)
+	}
+}
@@ -46,6 +46,15 @@ var chainFreezerNoSnappy = map[string]bool{
 	ChainFreezerDifficultyTable: true,
 }
 
+// chainFreezerRetainedTables are the ancient-tables that are never pruned from the
+// tail. Headers are needed to verify and rebuild consensus snapshots, hashes and
+// difficulties to serve the canonical chain; only bodies and receipts expire.
+var chainFreezerRetainedTables = map[string]bool{
+	ChainFreezerHeaderTable:     true,
+	ChainFreezerHashTable:       true,
+	ChainFreezerDifficultyTable: true,
+}
+
 const (
 	// stateHistoryTableSize defines the maximum size of freezer data files.
 	stateHistoryTableSize = 2 * 1000 * 1000 * 1000
@@ -206,3 +206,67 @@ func TestIndexTransactions(t *testing.T) {
 	verify(8, 11, true, 8)
 	verify(0, 8, false, 8)
 }
+
+func TestPruneHistory(t *testing.T) {
+	db, err := NewDatabaseWithFreezer(NewMemoryDatabase(), t.TempDir(), "", false)
+	if err != nil {
+		t.Fatalf("failed to create database with ancient backend: %v", err)
+	}
+	defer db.Close()
+
+	var (
+		blocks   []*types.Block
+		receipts []types.Receipts
+		parent   common.Hash
+		to       = common.BytesToAddress([]byte{0x11})
+	)
+	for i := uint64(0); i <= 10; i++ {
+		var txs []*types.Transaction
+		if i > 0 {
+			txs = append(txs, types.NewTx(&types.LegacyTx{Nonce: i, GasPrice: big.NewInt(11111), Gas: 1111, To: &to}))
+		}
+		block := types.NewBlock(&types.Header{Number: new(big.Int).SetUint64(i), ParentHash: parent}, txs, nil, nil, newTestHasher())
+		blocks = append(blocks, block)
+		receipts = append(receipts, make(types.Receipts, len(txs)))
+		parent = block.Hash()
+	}
+	if _, err := WriteAncientBlocks(db, blocks, receipts, big.NewInt(100)); err != nil {
+		t.Fatalf("failed to write ancient blocks: %v", err)
+	}
+	// Keep the genesis in the key-value store, like the chain freezer does
+	WriteBlock(db, blocks[0])
+	IndexTransactions(db, 0, 11, nil)
+
+	tail, err := PruneHistory(db, 6, nil)
+	if err != nil {
+		t.Fatalf("failed to prune history: %v", err)
+	}
+	if tail != 6 {
+		t.Fatalf("tail mismatch: have %d, want %d", tail, 6)
+	}
+	if txtail := ReadTxIndexTail(db); txtail == nil || *txtail != 6 {
+		t.Fatalf("tx index tail mismatch: have %v, want %d", txtail, 6)
+	}
+	for _, block := range blocks {
+		number := block.NumberU64()
+		if ReadHeader(db, block.Hash(), number) == nil {
+			t.Fatalf("header %d missing", number)
+		}
+		pruned := number > 0 && number < 6
+		if have := ReadBodyRLP(db, block.Hash(), number) == nil; have != pruned {
+			t.Fatalf("body %d: pruned mismatch, have %v, want %v", number, have, pruned)
+		}
+		for _, tx := range block.Transactions() {
+			if have := ReadTxLookupEntry(db, tx.Hash()) == nil; have != pruned {
+				t.Fatalf("tx lookup %d: pruned mismatch, have %v, want %v", number, have, pruned)
+			}
+		}
+	}
+	// Pruning below the tail is a noop, beyond the frozen items is capped
+	if tail, err := PruneHistory(db, 3, nil); err != nil || tail != 6 {
+		t.Fatalf("unexpected result: tail %d, err %v", tail, err)
+	}
+	if tail, err := PruneHistory(db, 100, nil); err != nil || tail != 11 {
+		t.Fatalf("unexpected result: tail %d, err %v", tail, err)
+	}
+}
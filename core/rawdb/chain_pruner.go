@@ -0,0 +1,69 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// errHistoryPruneInterrupted is returned if history pruning is aborted before
+// the stale transaction indices were removed.
+var errHistoryPruneInterrupted = errors.New("history pruning interrupted")
+
+// PruneHistory expires the block bodies and receipts of all frozen blocks below
+// the given cutoff by advancing the tail of the chain freezer. Headers (and with
+// them the epoch headers needed by consensus engines), canonical hashes and total
+// difficulties are retained.
+//
+// The transaction indices pointing into the pruned range are removed first, so
+// that lookups never resolve into missing bodies. The cutoff is capped to the
+// number of frozen items, the new history tail is returned.
+func PruneHistory(db ethdb.Database, cutoff uint64, interrupt chan struct{}) (uint64, error) {
+	frozen, err := db.Ancients()
+	if err != nil {
+		return 0, err
+	}
+	if cutoff > frozen {
+		cutoff = frozen
+	}
+	tail, err := db.Tail()
+	if err != nil {
+		return 0, err
+	}
+	if cutoff <= tail {
+		return tail, nil
+	}
+	start := time.Now()
+
+	// Drop the transaction indices of the expired range, if any are present
+	if txtail := ReadTxIndexTail(db); txtail != nil && *txtail < cutoff {
+		unindexTransactions(db, *txtail, cutoff, interrupt, nil)
+		if txtail = ReadTxIndexTail(db); txtail == nil || *txtail < cutoff {
+			return tail, errHistoryPruneInterrupted
+		}
+	}
+	if _, err := db.TruncateTail(cutoff); err != nil {
+		return tail, err
+	}
+	log.Info("Pruned chain history", "from", tail, "to", cutoff, "elapsed", common.PrettyDuration(time.Since(start)))
+	return cutoff, nil
+}
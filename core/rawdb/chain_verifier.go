@@ -0,0 +1,298 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// VerifyResult is the outcome of a database verification.
+type VerifyResult struct {
+	Problems int // Number of inconsistencies found
+	Repaired int // Number of inconsistencies fixed
+}
+
+// chainVerifier walks the canonical chain from the head block down to the
+// genesis, cross-checking the key-value store against the freezer.
+type chainVerifier struct {
+	db     ethdb.Database
+	repair bool
+	batch  ethdb.Batch
+	frozen uint64 // Number of items in the freezer, which isn't repaired
+	result VerifyResult
+}
+
+// report logs a problem found, along with whether it was repaired.
+func (v *chainVerifier) report(repaired bool, msg string, ctx ...interface{}) {
+	v.result.Problems++
+	if repaired {
+		v.result.Repaired++
+		log.Warn(msg+", repaired", ctx...)
+	} else {
+		log.Error(msg, ctx...)
+	}
+}
+
+// flush writes the accumulated repairs into the database once the batch gets
+// large enough, or unconditionally if forced.
+func (v *chainVerifier) flush(force bool) error {
+	if v.batch.ValueSize() == 0 || (!force && v.batch.ValueSize() < ethdb.IdealBatchSize) {
+		return nil
+	}
+	if err := v.batch.Write(); err != nil {
+		return err
+	}
+	v.batch.Reset()
+	return nil
+}
+
+// VerifyChain checks the consistency of the chain data stored in the key-value
+// store and the freezer:
+//
+//   - the chain freezer tables all cover the range reported by Ancients and Tail
+//   - the canonical hashes link up from the head block down to the genesis
+//   - the hash to number mappings of the canonical blocks are present
+//   - the headers, total difficulties, bodies and receipts are present, the
+//     latter two only above the history tail
+//   - the transaction lookup entries of the indexed blocks are present
+//
+// Every problem found is logged. If repair is set, the problems which can be
+// fixed from the remaining data are fixed in the key-value store; the freezer
+// is immutable and only verified.
+func VerifyChain(db ethdb.Database, repair bool) (VerifyResult, error) {
+	v := &chainVerifier{
+		db:     db,
+		repair: repair,
+		batch:  db.NewBatch(),
+	}
+	// Databases without a freezer keep the entire chain in the key-value store
+	frozen, err := db.Ancients()
+	if err != nil && !errors.Is(err, errNotSupported) {
+		return v.result, err
+	}
+	tail, err := db.Tail()
+	if err != nil && !errors.Is(err, errNotSupported) {
+		return v.result, err
+	}
+	v.frozen = frozen
+	v.verifyFreezer(frozen, tail)
+
+	head := ReadHeadBlockHash(db)
+	if head == (common.Hash{}) {
+		return v.result, errors.New("head block marker missing")
+	}
+	number := ReadHeaderNumber(db, head)
+	if number == nil {
+		return v.result, errors.New("head block number missing")
+	}
+	for _, marker := range []struct {
+		name string
+		hash common.Hash
+	}{
+		{"header", ReadHeadHeaderHash(db)},
+		{"snap block", ReadHeadFastBlockHash(db)},
+	} {
+		if marker.hash == (common.Hash{}) {
+			v.report(false, "Missing head marker", "kind", marker.name)
+		} else if n := ReadHeaderNumber(db, marker.hash); n == nil || !HasHeader(db, marker.hash, *n) {
+			v.report(false, "Dangling head marker", "kind", marker.name, "hash", marker.hash)
+		}
+	}
+	if err := v.verifyCanonical(head, *number, tail); err != nil {
+		return v.result, err
+	}
+	if err := v.flush(true); err != nil {
+		return v.result, err
+	}
+	return v.result, nil
+}
+
+// verifyFreezer checks that every table of the chain freezer holds the items in
+// the frozen range. The bodies and receipts may be pruned up to the tail, the
+// other tables retain the entire history.
+func (v *chainVerifier) verifyFreezer(frozen, tail uint64) {
+	if frozen == 0 {
+		return
+	}
+	for kind := range chainFreezerNoSnappy {
+		first := uint64(0)
+		if !chainFreezerRetainedTables[kind] {
+			first = tail
+		}
+		if first < frozen {
+			if ok, _ := v.db.HasAncient(kind, first); !ok {
+				v.report(false, "Freezer table misses its first item", "table", kind, "item", first)
+			}
+			if ok, _ := v.db.HasAncient(kind, frozen-1); !ok {
+				v.report(false, "Freezer table shorter than ancients", "table", kind, "items", frozen)
+			}
+		}
+		if ok, _ := v.db.HasAncient(kind, frozen); ok {
+			v.report(false, "Freezer table longer than ancients", "table", kind, "items", frozen)
+		}
+	}
+}
+
+// verifyCanonical walks the canonical chain backwards, following the parent
+// hashes of the headers.
+func (v *chainVerifier) verifyCanonical(hash common.Hash, number uint64, tail uint64) error {
+	var (
+		txtail = ReadTxIndexTail(v.db)
+		start  = time.Now()
+		logged = time.Now()
+	)
+	for {
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Verifying chain", "number", number, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+		// Ensure the canonical hash matches the one linked from the child
+		if canon := ReadCanonicalHash(v.db, number); canon != hash {
+			fixed := v.repair && number >= v.frozen && HasHeader(v.db, hash, number)
+			if fixed {
+				WriteCanonicalHash(v.batch, hash, number)
+			}
+			v.report(fixed, "Canonical hash mismatch", "number", number, "have", canon, "want", hash)
+		}
+		if n := ReadHeaderNumber(v.db, hash); n == nil || *n != number {
+			if v.repair {
+				WriteHeaderNumber(v.batch, hash, number)
+			}
+			v.report(v.repair, "Missing hash to number mapping", "number", number, "hash", hash)
+		}
+		header := ReadHeader(v.db, hash, number)
+		if header == nil {
+			v.report(false, "Missing header", "number", number, "hash", hash)
+
+			// The chain can't be followed without the header, continue with the
+			// canonical mapping of the parent if available
+			if number == 0 {
+				break
+			}
+			number--
+			if hash = ReadCanonicalHash(v.db, number); hash == (common.Hash{}) {
+				return errors.New("canonical chain broken")
+			}
+			continue
+		}
+		if header.Hash() != hash {
+			v.report(false, "Corrupted header", "number", number, "hash", hash, "have", header.Hash())
+		}
+		v.verifyTd(header)
+
+		if number >= tail {
+			body := ReadBody(v.db, hash, number)
+			if body == nil {
+				v.report(false, "Missing block body", "number", number, "hash", hash)
+			} else {
+				v.verifyReceipts(header, body)
+				if txtail != nil && number >= *txtail {
+					v.verifyTxLookups(number, body)
+				}
+			}
+		}
+		if err := v.flush(false); err != nil {
+			return err
+		}
+		if number == 0 {
+			break
+		}
+		hash, number = header.ParentHash, number-1
+	}
+	log.Info("Verified chain", "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// verifyTd checks that the total difficulty of a block is present, restoring
+// it from the parent's one if missing.
+func (v *chainVerifier) verifyTd(header *types.Header) {
+	number := header.Number.Uint64()
+	if ReadTd(v.db, header.Hash(), number) != nil {
+		return
+	}
+	var td *big.Int
+	if number == 0 {
+		td = new(big.Int).Set(header.Difficulty)
+	} else if ptd := ReadTd(v.db, header.ParentHash, number-1); ptd != nil {
+		td = new(big.Int).Add(ptd, header.Difficulty)
+	}
+	fixed := v.repair && td != nil && number >= v.frozen
+	if fixed {
+		WriteTd(v.batch, header.Hash(), number, td)
+	}
+	v.report(fixed, "Missing total difficulty", "number", number, "hash", header.Hash())
+}
+
+// verifyReceipts checks that the receipts of a block are present, one for each
+// transaction. The receipts of empty blocks are restored if missing.
+func (v *chainVerifier) verifyReceipts(header *types.Header, body *types.Body) {
+	var (
+		number = header.Number.Uint64()
+		hash   = header.Hash()
+	)
+	blob := ReadReceiptsRLP(v.db, hash, number)
+	if len(blob) == 0 {
+		fixed := v.repair && len(body.Transactions) == 0 && number >= v.frozen
+		if fixed {
+			WriteReceipts(v.batch, hash, number, nil)
+		}
+		v.report(fixed, "Missing receipts", "number", number, "hash", hash)
+		return
+	}
+	var receipts []*types.ReceiptForStorage
+	if err := rlp.DecodeBytes(blob, &receipts); err != nil {
+		v.report(false, "Corrupted receipts", "number", number, "hash", hash, "err", err)
+		return
+	}
+	if len(receipts) != len(body.Transactions) {
+		v.report(false, "Receipt count mismatch", "number", number, "hash", hash, "receipts", len(receipts), "txs", len(body.Transactions))
+	}
+}
+
+// verifyTxLookups checks that the transactions of an indexed block are looked
+// up into the block, re-indexing the block if not.
+func (v *chainVerifier) verifyTxLookups(number uint64, body *types.Body) {
+	var missing int
+	for _, tx := range body.Transactions {
+		// The lookups into the genesis block are stored as empty values, which
+		// aren't distinguishable from missing ones by the accessor
+		if ok, _ := v.db.Has(txLookupKey(tx.Hash())); !ok {
+			missing++
+		} else if entry := ReadTxLookupEntry(v.db, tx.Hash()); number != 0 && (entry == nil || *entry != number) {
+			missing++
+		}
+	}
+	if missing == 0 {
+		return
+	}
+	if v.repair {
+		hashes := make([]common.Hash, len(body.Transactions))
+		for i, tx := range body.Transactions {
+			hashes[i] = tx.Hash()
+		}
+		WriteTxLookupEntries(v.batch, number, hashes)
+	}
+	v.report(v.repair, "Missing transaction lookups", "number", number, "txs", missing)
+}
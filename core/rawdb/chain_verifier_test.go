@@ -0,0 +1,88 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Tests that the chain verifier finds the inconsistencies of the database and
+// repairs the ones which can be derived from other data.
+func TestVerifyChain(t *testing.T) {
+	db := NewMemoryDatabase()
+
+	var (
+		parent common.Hash
+		blocks []*types.Block
+	)
+	for i := 0; i < 10; i++ {
+		tx := types.NewTransaction(uint64(i), common.Address{0x11}, big.NewInt(111), 1111, big.NewInt(11111), []byte{0x11})
+		block := types.NewBlock(&types.Header{Number: big.NewInt(int64(i)), ParentHash: parent, Difficulty: common.Big1}, []*types.Transaction{tx}, nil, nil, newTestHasher())
+		WriteBlock(db, block)
+		WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		WriteTd(db, block.Hash(), block.NumberU64(), big.NewInt(int64(i+1)))
+		WriteReceipts(db, block.Hash(), block.NumberU64(), types.Receipts{{Status: types.ReceiptStatusSuccessful, Logs: []*types.Log{}}})
+		WriteTxLookupEntriesByBlock(db, block)
+
+		parent, blocks = block.Hash(), append(blocks, block)
+	}
+	head := blocks[len(blocks)-1]
+	WriteHeadBlockHash(db, head.Hash())
+	WriteHeadHeaderHash(db, head.Hash())
+	WriteHeadFastBlockHash(db, head.Hash())
+	WriteTxIndexTail(db, 0)
+
+	if res, err := VerifyChain(db, false); err != nil || res.Problems != 0 {
+		t.Fatalf("consistent database reported problems: %+v, %v", res, err)
+	}
+	// Corrupt the database in repairable and non-repairable ways
+	DeleteCanonicalHash(db, 3)
+	DeleteHeaderNumber(db, blocks[4].Hash())
+	DeleteTd(db, blocks[5].Hash(), 5)
+	DeleteTxLookupEntry(db, blocks[6].Transactions()[0].Hash())
+	DeleteReceipts(db, blocks[7].Hash(), 7)
+
+	res, err := VerifyChain(db, false)
+	if err != nil {
+		t.Fatalf("failed to verify chain: %v", err)
+	}
+	if res.Problems != 5 || res.Repaired != 0 {
+		t.Fatalf("verification result mismatch: have %+v, want 5 problems", res)
+	}
+	if res, err = VerifyChain(db, true); err != nil {
+		t.Fatalf("failed to repair chain: %v", err)
+	}
+	if res.Problems != 5 || res.Repaired != 4 {
+		t.Fatalf("repair result mismatch: have %+v, want 5 problems, 4 repaired", res)
+	}
+	if res, err = VerifyChain(db, false); err != nil || res.Problems != 1 {
+		t.Fatalf("repaired database result mismatch: have %+v, %v, want 1 problem", res, err)
+	}
+	if hash := ReadCanonicalHash(db, 3); hash != blocks[3].Hash() {
+		t.Fatalf("canonical hash not restored: have %x, want %x", hash, blocks[3].Hash())
+	}
+	if td := ReadTd(db, blocks[5].Hash(), 5); td == nil || td.Int64() != 6 {
+		t.Fatalf("total difficulty not restored: have %v, want 6", td)
+	}
+	if entry := ReadTxLookupEntry(db, blocks[6].Transactions()[0].Hash()); entry == nil || *entry != 6 {
+		t.Fatalf("transaction lookup not restored: have %v, want 6", entry)
+	}
+}
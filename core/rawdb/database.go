@@ -479,6 +479,13 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 		beaconHeaders   stat
 		cliqueSnaps     stat
 		zephyriaSnap    stat
+		zephyriaTargets stat
+		stateDiffs      stat
+		accountHistory  stat
+		txHistory       stat
+		tokenTransfers  stat
+		tokenJournals   stat
+		tokenBalances   stat
 
 		// Les statistic
 		chtTrieNodes   stat
@@ -541,8 +548,22 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 			beaconHeaders.Add(size)
 		case bytes.HasPrefix(key, CliqueSnapshotPrefix) && len(key) == 7+common.HashLength:
 			cliqueSnaps.Add(size)
-		case bytes.HasPrefix(key, ZephyriaSnapshotPrefix) && len(key) == 7+common.HashLength:
+		case bytes.HasPrefix(key, ZephyriaSnapshotPrefix) && len(key) == len(ZephyriaSnapshotPrefix)+common.HashLength:
 			zephyriaSnap.Add(size)
+		case bytes.HasPrefix(key, zephyriaGasTargetPrefix) && len(key) == len(zephyriaGasTargetPrefix)+common.HashLength:
+			zephyriaTargets.Add(size)
+		case bytes.HasPrefix(key, stateDiffPrefix) && len(key) == (len(stateDiffPrefix)+8+common.HashLength):
+			stateDiffs.Add(size)
+		case bytes.HasPrefix(key, accountHistoryPrefix) && len(key) == (len(accountHistoryPrefix)+common.AddressLength+8+common.HashLength):
+			accountHistory.Add(size)
+		case bytes.HasPrefix(key, txHistoryPrefix) && len(key) == (len(txHistoryPrefix)+common.AddressLength+8+4+common.HashLength):
+			txHistory.Add(size)
+		case bytes.HasPrefix(key, tokenTransferPrefix) && len(key) == (len(tokenTransferPrefix)+common.AddressLength+8+4+common.HashLength):
+			tokenTransfers.Add(size)
+		case bytes.HasPrefix(key, tokenJournalPrefix) && len(key) == (len(tokenJournalPrefix)+8+common.HashLength):
+			tokenJournals.Add(size)
+		case bytes.HasPrefix(key, tokenBalancePrefix) && len(key) == (len(tokenBalancePrefix)+2*common.AddressLength+common.HashLength):
+			tokenBalances.Add(size)
 		case bytes.HasPrefix(key, ChtTablePrefix) ||
 			bytes.HasPrefix(key, ChtIndexTablePrefix) ||
 			bytes.HasPrefix(key, ChtPrefix): // Canonical hash trie
@@ -559,6 +580,8 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 				snapshotGeneratorKey, snapshotRecoveryKey, txIndexTailKey, fastTxLookupLimitKey,
 				uncleanShutdownKey, badBlockKey, transitionStatusKey, skeletonSyncStatusKey,
 				persistentStateIDKey, trieJournalKey, snapshotSyncStatusKey, snapSyncStatusFlagKey,
+				onlinePruneStatusKey, stateDiffTailKey, accountHistoryTailKey, txHistoryTailKey,
+				tokenIndexHeadKey,
 			} {
 				if bytes.Equal(key, meta) {
 					metadata.Add(size)
@@ -597,6 +620,13 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 		{"Key-Value store", "Beacon sync headers", beaconHeaders.Size(), beaconHeaders.Count()},
 		{"Key-Value store", "Clique snapshots", cliqueSnaps.Size(), cliqueSnaps.Count()},
 		{"Key-Value store", "Zephyria snapshots", zephyriaSnap.Size(), zephyriaSnap.Count()},
+		{"Key-Value store", "Zephyria gas targets", zephyriaTargets.Size(), zephyriaTargets.Count()},
+		{"Key-Value store", "State diffs", stateDiffs.Size(), stateDiffs.Count()},
+		{"Key-Value store", "Account history index", accountHistory.Size(), accountHistory.Count()},
+		{"Key-Value store", "Transaction history index", txHistory.Size(), txHistory.Count()},
+		{"Key-Value store", "Token transfer index", tokenTransfers.Size(), tokenTransfers.Count()},
+		{"Key-Value store", "Token transfer journals", tokenJournals.Size(), tokenJournals.Count()},
+		{"Key-Value store", "Token balances", tokenBalances.Size(), tokenBalances.Count()},
 		{"Key-Value store", "Singleton metadata", metadata.Size(), metadata.Count()},
 		{"Light client", "CHT trie nodes", chtTrieNodes.Size(), chtTrieNodes.Count()},
 		{"Light client", "Bloom trie nodes", bloomTrieNodes.Size(), bloomTrieNodes.Count()},
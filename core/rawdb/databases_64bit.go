@@ -35,3 +35,19 @@ func NewPebbleDBDatabase(file string, cache int, handles int, namespace string,
 	}
 	return NewDatabase(db), nil
 }
+
+// NewPebbleDBReplica opens a private checkpoint of the pebble database of
+// another live process in read-only mode, see pebble.NewReplica.
+func NewPebbleDBReplica(file string, checkpoint string, cache int, handles int, namespace string) (ethdb.KeyValueStore, error) {
+	db, err := pebble.NewReplica(file, checkpoint, cache, handles, namespace)
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// PebbleDBReplicaState returns a fingerprint of the persisted content of the
+// pebble database of another live process, see pebble.ReplicaState.
+func PebbleDBReplicaState(file string) (string, error) {
+	return pebble.ReplicaState(file)
+}
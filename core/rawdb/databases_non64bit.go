@@ -32,3 +32,15 @@ const PebbleEnabled = false
 func NewPebbleDBDatabase(file string, cache int, handles int, namespace string, readonly, ephemeral bool) (ethdb.Database, error) {
 	return nil, errors.New("pebble is not supported on this platform")
 }
+
+// NewPebbleDBReplica opens a private checkpoint of the pebble database of
+// another live process in read-only mode.
+func NewPebbleDBReplica(file string, checkpoint string, cache int, handles int, namespace string) (ethdb.KeyValueStore, error) {
+	return nil, errors.New("pebble is not supported on this platform")
+}
+
+// PebbleDBReplicaState returns a fingerprint of the persisted content of the
+// pebble database of another live process.
+func PebbleDBReplicaState(file string) (string, error) {
+	return "", errors.New("pebble is not supported on this platform")
+}
@@ -63,7 +63,7 @@ const freezerTableSize = 2 * 1000 * 1000 * 1000
 //     of Geth, and thus also GC overhead.
 type Freezer struct {
 	frozen atomic.Uint64 // Number of blocks already frozen
-	tail   atomic.Uint64 // Number of the first stored item in the freezer
+	tail   atomic.Uint64 // Number of the first stored item in the freezer (excluding retained tables)
 
 	// This lock synchronizes writers and the truncate operation, as well as
 	// the "atomic" (batched) read operations.
@@ -72,6 +72,7 @@ type Freezer struct {
 
 	readonly     bool
 	tables       map[string]*freezerTable // Data tables for storing everything
+	retained     map[string]bool          // Data tables exempt from tail truncation
 	instanceLock *flock.Flock             // File-system lock to prevent double opens
 	closeOnce    sync.Once
 }
@@ -79,7 +80,7 @@ type Freezer struct {
 // NewChainFreezer is a small utility method around NewFreezer that sets the
 // default parameters for the chain storage.
 func NewChainFreezer(datadir string, namespace string, readonly bool) (*Freezer, error) {
-	return NewFreezer(datadir, namespace, readonly, freezerTableSize, chainFreezerNoSnappy)
+	return newFreezer(datadir, namespace, readonly, freezerTableSize, chainFreezerNoSnappy, chainFreezerRetainedTables)
 }
 
 // NewFreezer creates a freezer instance for maintaining immutable ordered
@@ -88,12 +89,25 @@ func NewChainFreezer(datadir string, namespace string, readonly bool) (*Freezer,
 // The 'tables' argument defines the data tables. If the value of a map
 // entry is true, snappy compression is disabled for the table.
 func NewFreezer(datadir string, namespace string, readonly bool, maxTableSize uint32, tables map[string]bool) (*Freezer, error) {
+	return newFreezer(datadir, namespace, readonly, maxTableSize, tables, nil)
+}
+
+// newFreezer creates a freezer instance, keeping the entire history of the data
+// tables flagged in 'retained' when truncating the tail.
+func newFreezer(datadir string, namespace string, readonly bool, maxTableSize uint32, tables map[string]bool, retained map[string]bool) (*Freezer, error) {
+	return openFreezer(datadir, namespace, readonly, false, maxTableSize, tables, retained)
+}
+
+// openFreezer creates a freezer instance. In replica mode the freezer of another
+// live process is opened read-only without locking the directory.
+func openFreezer(datadir string, namespace string, readonly bool, replica bool, maxTableSize uint32, tables map[string]bool, retained map[string]bool) (*Freezer, error) {
 	// Create the initial freezer object
 	var (
-		readMeter  = metrics.NewRegisteredMeter(namespace+"ancient/read", nil)
-		writeMeter = metrics.NewRegisteredMeter(namespace+"ancient/write", nil)
-		sizeGauge  = metrics.NewRegisteredGauge(namespace+"ancient/size", nil)
+		readMeter  = metrics.GetOrRegisterMeter(namespace+"ancient/read", nil)
+		writeMeter = metrics.GetOrRegisterMeter(namespace+"ancient/write", nil)
+		sizeGauge  = metrics.GetOrRegisterGauge(namespace+"ancient/size", nil)
 	)
+	readonly = readonly || replica
 	// Ensure the datadir is not a symbolic link if it exists.
 	if info, err := os.Lstat(datadir); !os.IsNotExist(err) {
 		if info.Mode()&os.ModeSymlink != 0 {
@@ -101,26 +115,30 @@ func NewFreezer(datadir string, namespace string, readonly bool, maxTableSize ui
 			return nil, errSymlinkDatadir
 		}
 	}
-	flockFile := filepath.Join(datadir, "FLOCK")
-	if err := os.MkdirAll(filepath.Dir(flockFile), 0755); err != nil {
-		return nil, err
-	}
-	// Leveldb uses LOCK as the filelock filename. To prevent the
-	// name collision, we use FLOCK as the lock name.
-	lock := flock.New(flockFile)
-	tryLock := lock.TryLock
-	if readonly {
-		tryLock = lock.TryRLock
-	}
-	if locked, err := tryLock(); err != nil {
-		return nil, err
-	} else if !locked {
-		return nil, errors.New("locking failed")
+	var lock *flock.Flock
+	if !replica {
+		flockFile := filepath.Join(datadir, "FLOCK")
+		if err := os.MkdirAll(filepath.Dir(flockFile), 0755); err != nil {
+			return nil, err
+		}
+		// Leveldb uses LOCK as the filelock filename. To prevent the
+		// name collision, we use FLOCK as the lock name.
+		lock = flock.New(flockFile)
+		tryLock := lock.TryLock
+		if readonly {
+			tryLock = lock.TryRLock
+		}
+		if locked, err := tryLock(); err != nil {
+			return nil, err
+		} else if !locked {
+			return nil, errors.New("locking failed")
+		}
 	}
 	// Open all the supported data tables
 	freezer := &Freezer{
 		readonly:     readonly,
 		tables:       make(map[string]*freezerTable),
+		retained:     retained,
 		instanceLock: lock,
 	}
 
@@ -131,7 +149,9 @@ func NewFreezer(datadir string, namespace string, readonly bool, maxTableSize ui
 			for _, table := range freezer.tables {
 				table.Close()
 			}
-			lock.Unlock()
+			if lock != nil {
+				lock.Unlock()
+			}
 			return nil, err
 		}
 		freezer.tables[name] = table
@@ -149,14 +169,20 @@ func NewFreezer(datadir string, namespace string, readonly bool, maxTableSize ui
 		for _, table := range freezer.tables {
 			table.Close()
 		}
-		lock.Unlock()
+		if lock != nil {
+			lock.Unlock()
+		}
 		return nil, err
 	}
 
 	// Create the write batch.
 	freezer.writeBatch = newFreezerBatch(freezer)
 
-	log.Info("Opened ancient database", "database", datadir, "readonly", readonly)
+	if replica {
+		log.Debug("Opened ancient database replica", "database", datadir)
+	} else {
+		log.Info("Opened ancient database", "database", datadir, "readonly", readonly)
+	}
 	return freezer, nil
 }
 
@@ -172,8 +198,10 @@ func (f *Freezer) Close() error {
 				errs = append(errs, err)
 			}
 		}
-		if err := f.instanceLock.Unlock(); err != nil {
-			errs = append(errs, err)
+		if f.instanceLock != nil {
+			if err := f.instanceLock.Unlock(); err != nil {
+				errs = append(errs, err)
+			}
 		}
 	})
 	if errs != nil {
@@ -312,7 +340,10 @@ func (f *Freezer) TruncateTail(tail uint64) (uint64, error) {
 	if old >= tail {
 		return old, nil
 	}
-	for _, table := range f.tables {
+	for kind, table := range f.tables {
+		if f.retained[kind] {
+			continue
+		}
 		if err := table.truncateTail(tail); err != nil {
 			return 0, err
 		}
@@ -342,24 +373,35 @@ func (f *Freezer) validate() error {
 		return nil
 	}
 	var (
-		head uint64
-		tail uint64
-		name string
+		head     uint64
+		tail     uint64
+		name     string
+		tailName string
 	)
 	// Hack to get boundary of any table
 	for kind, table := range f.tables {
 		head = table.items.Load()
-		tail = table.itemHidden.Load()
 		name = kind
 		break
 	}
-	// Now check every table against those boundaries.
+	for kind, table := range f.tables {
+		if !f.retained[kind] {
+			tail = table.itemHidden.Load()
+			tailName = kind
+			break
+		}
+	}
+	// Now check every table against those boundaries. Retained tables are not
+	// truncated from the tail, so they may hold more history.
 	for kind, table := range f.tables {
 		if head != table.items.Load() {
 			return fmt.Errorf("freezer tables %s and %s have differing head: %d != %d", kind, name, table.items.Load(), head)
 		}
+		if f.retained[kind] {
+			continue
+		}
 		if tail != table.itemHidden.Load() {
-			return fmt.Errorf("freezer tables %s and %s have differing tail: %d != %d", kind, name, table.itemHidden.Load(), tail)
+			return fmt.Errorf("freezer tables %s and %s have differing tail: %d != %d", kind, tailName, table.itemHidden.Load(), tail)
 		}
 	}
 	f.frozen.Store(head)
@@ -373,20 +415,26 @@ func (f *Freezer) repair() error {
 		head = uint64(math.MaxUint64)
 		tail = uint64(0)
 	)
-	for _, table := range f.tables {
+	for kind, table := range f.tables {
 		items := table.items.Load()
 		if head > items {
 			head = items
 		}
+		if f.retained[kind] {
+			continue
+		}
 		hidden := table.itemHidden.Load()
 		if hidden > tail {
 			tail = hidden
 		}
 	}
-	for _, table := range f.tables {
+	for kind, table := range f.tables {
 		if err := table.truncateHead(head); err != nil {
 			return err
 		}
+		if f.retained[kind] {
+			continue
+		}
 		if err := table.truncateTail(tail); err != nil {
 			return err
 		}
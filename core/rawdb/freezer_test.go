@@ -334,6 +334,64 @@ func TestFreezerConcurrentReadonly(t *testing.T) {
 	}
 }
 
+// This test checks that tail truncation skips the retained tables and that the
+// freezer can be reopened with tables of differing tails.
+func TestFreezerRetainedTables(t *testing.T) {
+	t.Parallel()
+
+	var (
+		dir      = t.TempDir()
+		tables   = map[string]bool{"a": true, "b": true}
+		retained = map[string]bool{"a": true}
+		item     = make([]byte, 1024)
+	)
+	f, err := newFreezer(dir, "", false, 2049, tables, retained)
+	if err != nil {
+		t.Fatal("can't open freezer", err)
+	}
+	_, err = f.ModifyAncients(func(op ethdb.AncientWriteOp) error {
+		for i := uint64(0); i < 10; i++ {
+			if err := op.AppendRaw("a", i, item); err != nil {
+				return err
+			}
+			if err := op.AppendRaw("b", i, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	_, err = f.TruncateTail(5)
+	require.NoError(t, err)
+
+	check := func(f *Freezer) {
+		t.Helper()
+
+		if tail, _ := f.Tail(); tail != 5 {
+			t.Fatalf("tail mismatch: have %d, want %d", tail, 5)
+		}
+		if _, err := f.Ancient("a", 0); err != nil {
+			t.Fatalf("retained item missing: %v", err)
+		}
+		if _, err := f.Ancient("b", 0); err != errOutOfBounds {
+			t.Fatalf("pruned item mismatch: have %v, want %v", err, errOutOfBounds)
+		}
+		checkAncientCount(t, f, "b", 10)
+	}
+	check(f)
+	require.NoError(t, f.Close())
+
+	// Reopen both writable and readonly, tails should be kept as they were
+	for _, readonly := range []bool{false, true} {
+		f, err := newFreezer(dir, "", readonly, 2049, tables, retained)
+		if err != nil {
+			t.Fatal("can't reopen freezer", err)
+		}
+		check(f)
+		require.NoError(t, f.Close())
+	}
+}
+
 func newFreezerForTesting(t *testing.T, tables map[string]bool) (*Freezer, string) {
 	t.Helper()
 
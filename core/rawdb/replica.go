@@ -0,0 +1,500 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// errReplicaClosed is returned if a replica database is accessed after closing.
+var errReplicaClosed = errors.New("replica database closed")
+
+// replicaGeneration is a single checkpoint of the primary's database. Readers
+// hold a reference while accessing it, superseded generations are closed and
+// deleted once all the references are released.
+type replicaGeneration struct {
+	db    ethdb.Database
+	dir   string // Directory of the checkpoint
+	state string // Fingerprint of the primary's database as of the checkpoint
+	refs  sync.WaitGroup
+
+	failed sync.Once // Reports the first read failure of the generation
+}
+
+// check reports a failed read of the generation, unless the error signals a
+// missing key.
+func (gen *replicaGeneration) check(key []byte, err error) error {
+	if err == nil {
+		return nil
+	}
+	if ok, herr := gen.db.Has(key); herr == nil && !ok {
+		return err // Plain missing key
+	}
+	gen.failed.Do(func() {
+		log.Error("Failed to read replica database", "checkpoint", gen.dir, "err", err)
+	})
+	return err
+}
+
+// ReplicaDatabase is a read-only view of the database of another live process.
+// The view is a checkpoint of the persisted data as of the last Reload, it needs
+// to be reloaded whenever the primary announces changes.
+//
+// All writes are rejected, the database is owned by the primary.
+type ReplicaDatabase struct {
+	options     OpenOptions
+	checkpoints string        // Directory holding the checkpoints of the primary's database
+	opened      atomic.Uint64 // Number of checkpoints opened, naming the next one
+
+	gen   *replicaGeneration
+	stale sync.WaitGroup // Superseded generations waiting for their readers
+	lock  sync.RWMutex
+}
+
+// NewReplicaDatabase opens the pebble database and the chain freezer of another
+// live process without acquiring their locks. The key-value store is accessed
+// through private checkpoints created in the given directory, which must be on
+// the same file system as the primary's database.
+func NewReplicaDatabase(o OpenOptions, checkpoints string) (*ReplicaDatabase, error) {
+	// Drop the checkpoints left behind by an earlier run
+	if err := os.RemoveAll(checkpoints); err != nil {
+		return nil, err
+	}
+	db := &ReplicaDatabase{options: o, checkpoints: checkpoints}
+	gen, err := db.open()
+	if err != nil {
+		return nil, err
+	}
+	db.gen = gen
+	return db, nil
+}
+
+// open creates a new generation of the replica database. The key-value store
+// is opened first, blocks migrated into the freezer in the meantime are still
+// found there afterwards.
+func (db *ReplicaDatabase) open() (*replicaGeneration, error) {
+	o := db.options
+	if !PebbleEnabled {
+		return nil, errors.New("database replicas are not supported on this platform")
+	}
+	if PreexistingDatabase(o.Directory) != dbPebble {
+		return nil, errors.New("database replicas require a pebble database")
+	}
+	// Fingerprint the primary before checkpointing it, changes made meanwhile
+	// only lead to a superfluous reload later
+	state, err := PebbleDBReplicaState(o.Directory)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(db.checkpoints, strconv.FormatUint(db.opened.Add(1), 10))
+	kvdb, err := NewPebbleDBReplica(o.Directory, dir, o.Cache, o.Handles, o.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	if len(o.AncientsDirectory) == 0 {
+		return &replicaGeneration{db: NewDatabase(kvdb), dir: dir, state: state}, nil
+	}
+	freezer, err := openFreezer(resolveChainFreezerDir(o.AncientsDirectory), o.Namespace, true, true, freezerTableSize, chainFreezerNoSnappy, chainFreezerRetainedTables)
+	if err != nil {
+		kvdb.Close()
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	fdb := &freezerdb{
+		ancientRoot:   o.AncientsDirectory,
+		KeyValueStore: kvdb,
+		AncientStore:  &chainFreezer{Freezer: freezer, quit: make(chan struct{})},
+	}
+	return &replicaGeneration{db: fdb, dir: dir, state: state}, nil
+}
+
+// close closes the generation's database and deletes its checkpoint.
+func (gen *replicaGeneration) close() error {
+	err := gen.db.Close()
+	if rerr := os.RemoveAll(gen.dir); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// Reload reopens the database, making the data persisted by the primary since
+// the last reload visible. If the primary didn't persist anything since, or if
+// reopening fails, the previous view is retained.
+func (db *ReplicaDatabase) Reload() error {
+	db.lock.RLock()
+	current := db.gen
+	db.lock.RUnlock()
+
+	if current == nil {
+		return errReplicaClosed
+	}
+	state, err := PebbleDBReplicaState(db.options.Directory)
+	if err != nil {
+		return err
+	}
+	if state == current.state {
+		return nil
+	}
+	fresh, err := db.open()
+	if err != nil {
+		return err
+	}
+	db.lock.Lock()
+	old := db.gen
+	if old == nil {
+		db.lock.Unlock()
+		fresh.close()
+		return errReplicaClosed
+	}
+	db.gen = fresh
+	db.stale.Add(1)
+	db.lock.Unlock()
+
+	go func() {
+		defer db.stale.Done()
+
+		old.refs.Wait()
+		if err := old.close(); err != nil {
+			log.Warn("Failed to close stale replica database", "err", err)
+		}
+	}()
+	return nil
+}
+
+// acquire returns the current generation with a reference held on it.
+func (db *ReplicaDatabase) acquire() (*replicaGeneration, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	if db.gen == nil {
+		return nil, errReplicaClosed
+	}
+	db.gen.refs.Add(1)
+	return db.gen, nil
+}
+
+// Has retrieves if a key is present in the key-value data store.
+func (db *ReplicaDatabase) Has(key []byte) (bool, error) {
+	gen, err := db.acquire()
+	if err != nil {
+		return false, err
+	}
+	defer gen.refs.Done()
+
+	ok, err := gen.db.Has(key)
+	return ok, gen.check(key, err)
+}
+
+// Get retrieves the given key if it's present in the key-value data store.
+func (db *ReplicaDatabase) Get(key []byte) ([]byte, error) {
+	gen, err := db.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer gen.refs.Done()
+
+	blob, err := gen.db.Get(key)
+	return blob, gen.check(key, err)
+}
+
+// Put is not supported on a replica.
+func (db *ReplicaDatabase) Put(key []byte, value []byte) error {
+	return errReadOnly
+}
+
+// Delete is not supported on a replica.
+func (db *ReplicaDatabase) Delete(key []byte) error {
+	return errReadOnly
+}
+
+// HasAncient returns an indicator whether the specified data exists in the
+// ancient store.
+func (db *ReplicaDatabase) HasAncient(kind string, number uint64) (bool, error) {
+	gen, err := db.acquire()
+	if err != nil {
+		return false, err
+	}
+	defer gen.refs.Done()
+	return gen.db.HasAncient(kind, number)
+}
+
+// Ancient retrieves an ancient binary blob from the append-only immutable files.
+func (db *ReplicaDatabase) Ancient(kind string, number uint64) ([]byte, error) {
+	gen, err := db.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer gen.refs.Done()
+	return gen.db.Ancient(kind, number)
+}
+
+// AncientRange retrieves multiple items in sequence, starting from the index
+// 'start'.
+func (db *ReplicaDatabase) AncientRange(kind string, start, count, maxBytes uint64) ([][]byte, error) {
+	gen, err := db.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer gen.refs.Done()
+	return gen.db.AncientRange(kind, start, count, maxBytes)
+}
+
+// Ancients returns the ancient item numbers in the ancient store.
+func (db *ReplicaDatabase) Ancients() (uint64, error) {
+	gen, err := db.acquire()
+	if err != nil {
+		return 0, err
+	}
+	defer gen.refs.Done()
+	return gen.db.Ancients()
+}
+
+// Tail returns the number of first stored item in the ancient store.
+func (db *ReplicaDatabase) Tail() (uint64, error) {
+	gen, err := db.acquire()
+	if err != nil {
+		return 0, err
+	}
+	defer gen.refs.Done()
+	return gen.db.Tail()
+}
+
+// AncientSize returns the ancient size of the specified category.
+func (db *ReplicaDatabase) AncientSize(kind string) (uint64, error) {
+	gen, err := db.acquire()
+	if err != nil {
+		return 0, err
+	}
+	defer gen.refs.Done()
+	return gen.db.AncientSize(kind)
+}
+
+// ReadAncients runs the given read operation while ensuring that no writes take
+// place and the same view of the ancient store is used throughout.
+func (db *ReplicaDatabase) ReadAncients(fn func(ethdb.AncientReaderOp) error) error {
+	gen, err := db.acquire()
+	if err != nil {
+		return err
+	}
+	defer gen.refs.Done()
+	return gen.db.ReadAncients(fn)
+}
+
+// ModifyAncients is not supported on a replica.
+func (db *ReplicaDatabase) ModifyAncients(func(ethdb.AncientWriteOp) error) (int64, error) {
+	return 0, errReadOnly
+}
+
+// TruncateHead is not supported on a replica.
+func (db *ReplicaDatabase) TruncateHead(n uint64) (uint64, error) {
+	return 0, errReadOnly
+}
+
+// TruncateTail is not supported on a replica.
+func (db *ReplicaDatabase) TruncateTail(n uint64) (uint64, error) {
+	return 0, errReadOnly
+}
+
+// Sync is a noop on a replica, there's nothing written.
+func (db *ReplicaDatabase) Sync() error {
+	return nil
+}
+
+// MigrateTable is not supported on a replica.
+func (db *ReplicaDatabase) MigrateTable(string, func([]byte) ([]byte, error)) error {
+	return errReadOnly
+}
+
+// NewBatch creates a batch rejecting all writes.
+func (db *ReplicaDatabase) NewBatch() ethdb.Batch {
+	return new(replicaBatch)
+}
+
+// NewBatchWithSize creates a batch rejecting all writes.
+func (db *ReplicaDatabase) NewBatchWithSize(size int) ethdb.Batch {
+	return new(replicaBatch)
+}
+
+// NewIterator creates a binary-alphabetical iterator over a subset of database
+// content with a particular key prefix, starting at a particular initial key.
+// The iterator keeps its view of the database until released.
+func (db *ReplicaDatabase) NewIterator(prefix []byte, start []byte) ethdb.Iterator {
+	gen, err := db.acquire()
+	if err != nil {
+		return &replicaIterator{err: err}
+	}
+	return &replicaIterator{Iterator: gen.db.NewIterator(prefix, start), gen: gen}
+}
+
+// Stat returns a particular internal stat of the database.
+func (db *ReplicaDatabase) Stat(property string) (string, error) {
+	gen, err := db.acquire()
+	if err != nil {
+		return "", err
+	}
+	defer gen.refs.Done()
+	return gen.db.Stat(property)
+}
+
+// AncientDatadir returns the path of root ancient directory.
+func (db *ReplicaDatabase) AncientDatadir() (string, error) {
+	return db.options.AncientsDirectory, nil
+}
+
+// Compact is a noop on a replica, the database is owned by the primary.
+func (db *ReplicaDatabase) Compact(start []byte, limit []byte) error {
+	return nil
+}
+
+// NewSnapshot creates a database snapshot based on the current view. The
+// snapshot keeps its view of the database until released.
+func (db *ReplicaDatabase) NewSnapshot() (ethdb.Snapshot, error) {
+	gen, err := db.acquire()
+	if err != nil {
+		return nil, err
+	}
+	snap, err := gen.db.NewSnapshot()
+	if err != nil {
+		gen.refs.Done()
+		return nil, err
+	}
+	return &replicaSnapshot{Snapshot: snap, gen: gen}, nil
+}
+
+// Close waits for all the readers to finish and closes the database.
+func (db *ReplicaDatabase) Close() error {
+	db.lock.Lock()
+	gen := db.gen
+	db.gen = nil
+	db.lock.Unlock()
+
+	if gen == nil {
+		return nil
+	}
+	gen.refs.Wait()
+	err := gen.close()
+	db.stale.Wait()
+	return err
+}
+
+// replicaIterator is an iterator over a replica generation, releasing the
+// reference on it when the iterator is released.
+type replicaIterator struct {
+	ethdb.Iterator
+	gen  *replicaGeneration
+	err  error
+	once sync.Once
+}
+
+// Next moves the iterator to the next key/value pair.
+func (it *replicaIterator) Next() bool {
+	if it.Iterator == nil {
+		return false
+	}
+	return it.Iterator.Next()
+}
+
+// Error returns any accumulated error.
+func (it *replicaIterator) Error() error {
+	if it.Iterator == nil {
+		return it.err
+	}
+	return it.Iterator.Error()
+}
+
+// Key returns the key of the current key/value pair, or nil if done.
+func (it *replicaIterator) Key() []byte {
+	if it.Iterator == nil {
+		return nil
+	}
+	return it.Iterator.Key()
+}
+
+// Value returns the value of the current key/value pair, or nil if done.
+func (it *replicaIterator) Value() []byte {
+	if it.Iterator == nil {
+		return nil
+	}
+	return it.Iterator.Value()
+}
+
+// Release releases associated resources and the reference on the generation.
+func (it *replicaIterator) Release() {
+	if it.Iterator == nil {
+		return
+	}
+	it.once.Do(func() {
+		it.Iterator.Release()
+		it.gen.refs.Done()
+	})
+}
+
+// replicaSnapshot is a snapshot of a replica generation, releasing the
+// reference on it when the snapshot is released.
+type replicaSnapshot struct {
+	ethdb.Snapshot
+	gen  *replicaGeneration
+	once sync.Once
+}
+
+// Release releases associated resources and the reference on the generation.
+func (snap *replicaSnapshot) Release() {
+	snap.once.Do(func() {
+		snap.Snapshot.Release()
+		snap.gen.refs.Done()
+	})
+}
+
+// replicaBatch is a batch rejecting all the writes, the database is owned by
+// the primary.
+type replicaBatch struct{}
+
+// Put is not supported on a replica.
+func (b *replicaBatch) Put(key, value []byte) error {
+	return errReadOnly
+}
+
+// Delete is not supported on a replica.
+func (b *replicaBatch) Delete(key []byte) error {
+	return errReadOnly
+}
+
+// ValueSize retrieves the amount of data queued up for writing.
+func (b *replicaBatch) ValueSize() int {
+	return 0
+}
+
+// Write is not supported on a replica.
+func (b *replicaBatch) Write() error {
+	return errReadOnly
+}
+
+// Reset resets the batch for reuse.
+func (b *replicaBatch) Reset() {}
+
+// Replay replays nothing, there are no writes.
+func (b *replicaBatch) Replay(w ethdb.KeyValueWriter) error {
+	return nil
+}
@@ -0,0 +1,161 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Tests that a replica can be opened while the primary holds its database, and
+// that the writes of the primary become visible after reloading.
+func TestReplicaDatabase(t *testing.T) {
+	if !PebbleEnabled {
+		t.Skip("pebble not supported")
+	}
+	var (
+		dir     = t.TempDir()
+		options = OpenOptions{
+			Type:              dbPebble,
+			Directory:         dir,
+			AncientsDirectory: filepath.Join(dir, "ancient"),
+			Cache:             16,
+			Handles:           16,
+		}
+	)
+	primary, err := Open(options)
+	if err != nil {
+		t.Fatalf("failed to open primary: %v", err)
+	}
+	defer primary.Close()
+
+	primary.Put([]byte("a"), []byte{1})
+
+	replica, err := NewReplicaDatabase(options, filepath.Join(dir, "replica"))
+	if err != nil {
+		t.Fatalf("failed to open replica: %v", err)
+	}
+	defer replica.Close()
+
+	if blob, _ := replica.Get([]byte("a")); !bytes.Equal(blob, []byte{1}) {
+		t.Fatalf("replica value mismatch: have %x, want %x", blob, []byte{1})
+	}
+	// Writes of the replica are rejected, the ones of the primary are only
+	// visible after a reload
+	if err := replica.Put([]byte("b"), []byte{2}); err != errReadOnly {
+		t.Fatalf("write error mismatch: have %v, want %v", err, errReadOnly)
+	}
+	batch := replica.NewBatch()
+	batch.Put([]byte("b"), []byte{2})
+	if err := batch.Write(); err != errReadOnly {
+		t.Fatalf("batch write error mismatch: have %v, want %v", err, errReadOnly)
+	}
+	primary.Put([]byte("c"), []byte{3})
+	if ok, err := replica.Has([]byte("c")); ok || err != nil {
+		t.Fatalf("primary write visible before reload")
+	}
+	if err := primary.Compact(nil, nil); err != nil {
+		t.Fatalf("failed to compact primary: %v", err)
+	}
+	it := replica.NewIterator(nil, nil)
+	defer it.Release()
+
+	if err := replica.Reload(); err != nil {
+		t.Fatalf("failed to reload replica: %v", err)
+	}
+	if blob, _ := replica.Get([]byte("c")); !bytes.Equal(blob, []byte{3}) {
+		t.Fatalf("reloaded value mismatch: have %x, want %x", blob, []byte{3})
+	}
+	// Compactions of the primary don't affect the checkpoint of the replica
+	for i := 0; i < 1000; i++ {
+		primary.Put([]byte{'d', byte(i >> 8), byte(i)}, bytes.Repeat([]byte{byte(i)}, 1024))
+	}
+	primary.Delete([]byte("c"))
+	if err := primary.Compact(nil, nil); err != nil {
+		t.Fatalf("failed to compact primary: %v", err)
+	}
+	if blob, err := replica.Get([]byte("c")); err != nil || !bytes.Equal(blob, []byte{3}) {
+		t.Fatalf("value mismatch after compaction: have %x (%v), want %x", blob, err, []byte{3})
+	}
+	// Iterators opened before the reload keep their view
+	var keys [][]byte
+	for it.Next() {
+		keys = append(keys, it.Key())
+	}
+	if len(keys) != 1 || !bytes.Equal(keys[0], []byte("a")) {
+		t.Fatalf("iterator keys mismatch: have %q", keys)
+	}
+	if _, err := replica.ModifyAncients(nil); err != errReadOnly {
+		t.Fatalf("ancient write error mismatch: have %v, want %v", err, errReadOnly)
+	}
+	// Closing the replica removes its checkpoints
+	it.Release()
+	replica.Close()
+	if entries, _ := os.ReadDir(filepath.Join(dir, "replica")); len(entries) != 0 {
+		t.Fatalf("checkpoints left behind: %v", entries)
+	}
+}
+
+// Tests that reloading a replica keeps its checkpoint if the primary didn't
+// persist anything since.
+func TestReplicaDatabaseUnchanged(t *testing.T) {
+	if !PebbleEnabled {
+		t.Skip("pebble not supported")
+	}
+	var (
+		dir     = t.TempDir()
+		options = OpenOptions{
+			Type:      dbPebble,
+			Directory: dir,
+			Cache:     16,
+			Handles:   16,
+		}
+	)
+	primary, err := Open(options)
+	if err != nil {
+		t.Fatalf("failed to open primary: %v", err)
+	}
+	defer primary.Close()
+
+	primary.Put([]byte("a"), []byte{1})
+
+	replica, err := NewReplicaDatabase(options, filepath.Join(dir, "replica"))
+	if err != nil {
+		t.Fatalf("failed to open replica: %v", err)
+	}
+	defer replica.Close()
+
+	checkpoint := replica.gen.dir
+	if err := replica.Reload(); err != nil {
+		t.Fatalf("failed to reload replica: %v", err)
+	}
+	if replica.gen.dir != checkpoint {
+		t.Fatalf("unchanged database checkpointed again: have %s, want %s", replica.gen.dir, checkpoint)
+	}
+	primary.Put([]byte("b"), []byte{2})
+	if err := replica.Reload(); err != nil {
+		t.Fatalf("failed to reload replica: %v", err)
+	}
+	if replica.gen.dir == checkpoint {
+		t.Fatalf("modified database not checkpointed again")
+	}
+	if blob, _ := replica.Get([]byte("b")); !bytes.Equal(blob, []byte{2}) {
+		t.Fatalf("reloaded value mismatch: have %x, want %x", blob, []byte{2})
+	}
+}
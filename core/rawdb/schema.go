@@ -76,9 +76,27 @@ var (
 	// trieJournalKey tracks the in-memory trie node layers across restarts.
 	trieJournalKey = []byte("TrieJournal")
 
+	// onlinePruneStatusKey tracks the progress of an online state pruning.
+	onlinePruneStatusKey = []byte("OnlinePruneStatus")
+
 	// txIndexTailKey tracks the oldest block whose transactions have been indexed.
 	txIndexTailKey = []byte("TransactionIndexTail")
 
+	// stateDiffTailKey tracks the oldest block whose state diff is retained.
+	stateDiffTailKey = []byte("StateDiffTail")
+
+	// accountHistoryTailKey tracks the oldest block whose account changes have been indexed.
+	accountHistoryTailKey = []byte("AccountHistoryTail")
+
+	// txHistoryTailKey tracks the oldest block whose transactions have been indexed by address.
+	txHistoryTailKey = []byte("TransactionHistoryTail")
+
+	// tokenIndexHeadKey tracks the last block whose token transfers have been indexed.
+	tokenIndexHeadKey = []byte("TokenIndexHead")
+
+	// tokenIndexStoppedKey tracks the block whose unavailable receipts stopped the token indexing.
+	tokenIndexStoppedKey = []byte("TokenIndexStopped")
+
 	// fastTxLookupLimitKey tracks the transaction lookup limit during fast sync.
 	fastTxLookupLimitKey = []byte("FastTransactionLookupLimit")
 
@@ -119,8 +137,16 @@ var (
 	configPrefix   = []byte("ethereum-config-")  // config prefix for the db
 	genesisPrefix  = []byte("ethereum-genesis-") // genesis state prefix for the db
 
-	LastSafePointBlockKey = []byte("LastSafePointBlockNumber")
+	onlinePruneMarkerPrefix = []byte("prune-marker-") // onlinePruneMarkerPrefix + hash -> empty, trie nodes persisted during online pruning
 
+	stateDiffPrefix      = []byte("state-diff-")     // stateDiffPrefix + num (uint64 big endian) + hash -> block state diff
+	accountHistoryPrefix = []byte("state-account-")  // accountHistoryPrefix + address + ^num (uint64 big endian) + hash -> account nonce and balance
+	txHistoryPrefix      = []byte("address-tx-")     // txHistoryPrefix + address + ^num (uint64 big endian) + ^index (uint32 big endian) + hash -> tx hash and roles
+	tokenTransferPrefix  = []byte("token-transfer-") // tokenTransferPrefix + holder + ^num (uint64 big endian) + ^log index (uint32 big endian) + hash -> token transfers
+	tokenJournalPrefix   = []byte("token-block-")    // tokenJournalPrefix + num (uint64 big endian) + hash -> token transfers of the block
+	tokenBalancePrefix   = []byte("token-balance-")  // tokenBalancePrefix + holder + token + id -> token balance
+
+	LastSafePointBlockKey = []byte("LastSafePointBlockNumber")
 
 	// BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
 	BloomBitsIndexPrefix = []byte("iB")
@@ -133,8 +159,9 @@ var (
 	BloomTrieTablePrefix = []byte("blt-")
 	BloomTrieIndexPrefix = []byte("bltIndex-")
 
-	CliqueSnapshotPrefix   = []byte("clique-")
-	ZephyriaSnapshotPrefix = []byte("zephyria-")
+	CliqueSnapshotPrefix    = []byte("clique-")
+	ZephyriaSnapshotPrefix  = []byte("zephyria-")
+	zephyriaGasTargetPrefix = []byte("zephyriaGasTarget-") // zephyriaGasTargetPrefix + epoch block hash -> gas limit target
 
 	preimageCounter    = metrics.NewRegisteredCounter("db/preimage/total", nil)
 	preimageHitCounter = metrics.NewRegisteredCounter("db/preimage/hits", nil)
@@ -190,6 +217,40 @@ func blockReceiptsKey(number uint64, hash common.Hash) []byte {
 	return append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
 }
 
+// stateDiffKey = stateDiffPrefix + num (uint64 big endian) + hash
+func stateDiffKey(number uint64, hash common.Hash) []byte {
+	return append(append(stateDiffPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
+}
+
+// accountHistoryKey = accountHistoryPrefix + address + ^num (uint64 big endian) + hash
+func accountHistoryKey(address common.Address, number uint64, hash common.Hash) []byte {
+	return append(append(append(accountHistoryPrefix, address.Bytes()...), encodeBlockNumber(^number)...), hash.Bytes()...)
+}
+
+// txHistoryKey = txHistoryPrefix + address + ^num (uint64 big endian) + ^index (uint32 big endian) + hash
+func txHistoryKey(address common.Address, number uint64, index uint32, hash common.Hash) []byte {
+	key := append(append(txHistoryPrefix, address.Bytes()...), encodeBlockNumber(^number)...)
+	key = binary.BigEndian.AppendUint32(key, ^index)
+	return append(key, hash.Bytes()...)
+}
+
+// tokenTransferKey = tokenTransferPrefix + holder + ^num (uint64 big endian) + ^log index (uint32 big endian) + hash
+func tokenTransferKey(holder common.Address, number uint64, logIndex uint32, hash common.Hash) []byte {
+	key := append(append(tokenTransferPrefix, holder.Bytes()...), encodeBlockNumber(^number)...)
+	key = binary.BigEndian.AppendUint32(key, ^logIndex)
+	return append(key, hash.Bytes()...)
+}
+
+// tokenJournalKey = tokenJournalPrefix + num (uint64 big endian) + hash
+func tokenJournalKey(number uint64, hash common.Hash) []byte {
+	return append(append(tokenJournalPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
+}
+
+// tokenBalanceKey = tokenBalancePrefix + holder + token + id
+func tokenBalanceKey(holder common.Address, token common.Address, id common.Hash) []byte {
+	return append(append(append(tokenBalancePrefix, holder.Bytes()...), token.Bytes()...), id.Bytes()...)
+}
+
 // txLookupKey = txLookupPrefix + hash
 func txLookupKey(hash common.Hash) []byte {
 	return append(txLookupPrefix, hash.Bytes()...)
@@ -234,6 +295,16 @@ func preimageKey(hash common.Hash) []byte {
 	return append(PreimagePrefix, hash.Bytes()...)
 }
 
+// onlinePruneMarkerKey = onlinePruneMarkerPrefix + hash
+func onlinePruneMarkerKey(hash common.Hash) []byte {
+	return append(onlinePruneMarkerPrefix, hash.Bytes()...)
+}
+
+// zephyriaGasTargetKey = zephyriaGasTargetPrefix + hash
+func zephyriaGasTargetKey(hash common.Hash) []byte {
+	return append(zephyriaGasTargetPrefix, hash.Bytes()...)
+}
+
 // codeKey = CodePrefix + hash
 func codeKey(hash common.Hash) []byte {
 	return append(CodePrefix, hash.Bytes()...)
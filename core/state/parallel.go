@@ -0,0 +1,280 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// AccessSet is a set of accounts and storage slots accessed by the execution of
+// a transaction. Accounts stand for their balance, nonce and code; the storage
+// slots are tracked individually. Wiped accounts are the ones whose storage as
+// a whole may have changed by being created, destructed or deleted as empty.
+type AccessSet struct {
+	Accounts map[common.Address]struct{}
+	Slots    map[common.Address]map[common.Hash]struct{}
+	Wiped    map[common.Address]struct{}
+}
+
+// NewAccessSet creates an empty access set.
+func NewAccessSet() *AccessSet {
+	return &AccessSet{
+		Accounts: make(map[common.Address]struct{}),
+		Slots:    make(map[common.Address]map[common.Hash]struct{}),
+		Wiped:    make(map[common.Address]struct{}),
+	}
+}
+
+// addSlot inserts a storage slot into the set.
+func (a *AccessSet) addSlot(addr common.Address, slot common.Hash) {
+	slots, ok := a.Slots[addr]
+	if !ok {
+		slots = make(map[common.Hash]struct{})
+		a.Slots[addr] = slots
+	}
+	slots[slot] = struct{}{}
+}
+
+// Merge adds all the entries of another set into this one.
+func (a *AccessSet) Merge(other *AccessSet) {
+	for addr := range other.Accounts {
+		a.Accounts[addr] = struct{}{}
+	}
+	for addr, slots := range other.Slots {
+		for slot := range slots {
+			a.addSlot(addr, slot)
+		}
+	}
+	for addr := range other.Wiped {
+		a.Wiped[addr] = struct{}{}
+	}
+}
+
+// Conflicts reports whether a set of reads observed any state modified by a set
+// of writes.
+func (a *AccessSet) Conflicts(writes *AccessSet) bool {
+	for addr := range a.Accounts {
+		if _, ok := writes.Accounts[addr]; ok {
+			return true
+		}
+	}
+	for addr, slots := range a.Slots {
+		if _, ok := writes.Wiped[addr]; ok {
+			return true
+		}
+		written, ok := writes.Slots[addr]
+		if !ok {
+			continue
+		}
+		for slot := range slots {
+			if _, ok := written[slot]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// recordAccount tracks an account read if read recording is enabled.
+func (s *StateDB) recordAccount(addr common.Address) {
+	if s.reads != nil {
+		s.reads.Accounts[addr] = struct{}{}
+	}
+}
+
+// recordSlot tracks a storage slot read if read recording is enabled.
+func (s *StateDB) recordSlot(addr common.Address, slot common.Hash) {
+	if s.reads != nil {
+		s.reads.addSlot(addr, slot)
+	}
+}
+
+// CopyView creates a copy of the state for executing a transaction in isolation,
+// recording the state read by the execution. Balance increments and decrements
+// are not considered reads, as they commute. The view has no prefetcher.
+//
+// CopyView only reads the source state, so views of a state which is no longer
+// mutated can be created concurrently.
+func (s *StateDB) CopyView() *StateDB {
+	view := s.Copy()
+	view.prefetcher = nil // Inactive copy, nothing to terminate
+	view.reads = NewAccessSet()
+	return view
+}
+
+// Reads returns the state read since the view was created, nil if the state is
+// not a view.
+func (s *StateDB) Reads() *AccessSet {
+	return s.reads
+}
+
+// accountChange is the modification of a single account by a transaction.
+type accountChange struct {
+	balance *big.Int             // Balance before the transaction, nil if unchanged
+	nonce   bool                 // Whether the nonce was changed
+	code    bool                 // Whether the code was changed
+	storage []common.Hash        // Storage slots written, in order of first write
+	seen    map[common.Hash]bool // Storage slots written, for deduplication
+}
+
+// TxChanges is the set of state modifications made by the current transaction,
+// gathered from the journal before the state is finalised.
+type TxChanges struct {
+	Writes *AccessSet // State modified by the transaction
+
+	state     *StateDB
+	accounts  map[common.Address]*accountChange
+	preimages []common.Hash
+	mergeable bool
+}
+
+// Mergeable reports whether the changes can be applied onto another state. The
+// self-destructs and account resets aren't, as they depend on the state of the
+// account as a whole.
+func (c *TxChanges) Mergeable() bool {
+	return c.mergeable
+}
+
+// TxChanges gathers the modifications made by the current transaction. It has
+// to be called before the state is finalised.
+func (s *StateDB) TxChanges() *TxChanges {
+	c := &TxChanges{
+		Writes:    NewAccessSet(),
+		state:     s,
+		accounts:  make(map[common.Address]*accountChange),
+		mergeable: true,
+	}
+	change := func(addr common.Address) *accountChange {
+		ac, ok := c.accounts[addr]
+		if !ok {
+			ac = &accountChange{seen: make(map[common.Hash]bool)}
+			c.accounts[addr] = ac
+		}
+		return ac
+	}
+	for _, entry := range s.journal.entries {
+		switch entry := entry.(type) {
+		case createObjectChange:
+			change(*entry.account)
+			c.Writes.Accounts[*entry.account] = struct{}{}
+			c.Writes.Wiped[*entry.account] = struct{}{}
+
+		case resetObjectChange:
+			c.Writes.Accounts[*entry.account] = struct{}{}
+			c.Writes.Wiped[*entry.account] = struct{}{}
+			c.mergeable = false
+
+		case selfDestructChange:
+			c.Writes.Accounts[*entry.account] = struct{}{}
+			c.Writes.Wiped[*entry.account] = struct{}{}
+			c.mergeable = false
+
+		case balanceChange:
+			if ac := change(*entry.account); ac.balance == nil {
+				ac.balance = entry.prev
+			}
+			c.Writes.Accounts[*entry.account] = struct{}{}
+
+		case nonceChange:
+			change(*entry.account).nonce = true
+			c.Writes.Accounts[*entry.account] = struct{}{}
+
+		case codeChange:
+			change(*entry.account).code = true
+			c.Writes.Accounts[*entry.account] = struct{}{}
+
+		case storageChange:
+			if ac := change(*entry.account); !ac.seen[entry.key] {
+				ac.seen[entry.key] = true
+				ac.storage = append(ac.storage, entry.key)
+			}
+			c.Writes.addSlot(*entry.account, entry.key)
+
+		case touchChange:
+			change(*entry.account)
+			c.Writes.Accounts[*entry.account] = struct{}{}
+			c.Writes.Wiped[*entry.account] = struct{}{}
+
+		case addPreimageChange:
+			c.preimages = append(c.preimages, entry.hash)
+		}
+	}
+	// Accounts may stay dirty without a journal entry, see journal.dirty
+	for addr := range s.journal.dirties {
+		if _, ok := c.accounts[addr]; !ok {
+			change(addr)
+			c.Writes.Accounts[addr] = struct{}{}
+			c.Writes.Wiped[addr] = struct{}{}
+		}
+	}
+	return c
+}
+
+// ApplyTxChanges replays the modifications made by a transaction on another
+// state onto this one, as part of the transaction set by SetTxContext. Balance
+// changes are applied as differences, everything else is overwritten with the
+// values the transaction left behind. The state has to be finalised afterwards.
+func (s *StateDB) ApplyTxChanges(c *TxChanges) {
+	if !c.mergeable {
+		panic("unmergeable transaction changes")
+	}
+	addrs := make([]common.Address, 0, len(c.accounts))
+	for addr := range c.accounts {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i].Cmp(addrs[j]) < 0 })
+
+	for _, addr := range addrs {
+		var (
+			ac  = c.accounts[addr]
+			obj = c.state.stateObjects[addr]
+		)
+		// Apply the balance difference, touching the account regardless so it
+		// ends up dirty and gets deleted if empty, same as on the source
+		delta := new(big.Int)
+		if ac.balance != nil && obj != nil {
+			delta.Sub(obj.Balance(), ac.balance)
+		}
+		if delta.Sign() < 0 {
+			s.SubBalance(addr, delta.Neg(delta))
+		} else {
+			s.AddBalance(addr, delta)
+		}
+		if obj == nil {
+			continue
+		}
+		if ac.nonce {
+			s.SetNonce(addr, obj.Nonce())
+		}
+		if ac.code {
+			s.SetCode(addr, obj.Code())
+		}
+		for _, key := range ac.storage {
+			s.SetState(addr, key, obj.GetState(key))
+		}
+	}
+	for _, log := range c.state.logs[c.state.thash] {
+		cpy := *log
+		s.AddLog(&cpy)
+	}
+	for _, hash := range c.preimages {
+		s.AddPreimage(hash, c.state.preimages[hash])
+	}
+}
@@ -0,0 +1,742 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+const (
+	// onlineBloomFileName is the filename of the state bloom filter used by the
+	// online pruning. It's deliberately different from the offline one so that
+	// RecoverPruning never picks it up.
+	onlineBloomFileName = "onlinebloom.bf.gz"
+
+	// onlineTargetSearchDepth is the maximum number of blocks to look back from
+	// the chain head for a state persisted in the database.
+	onlineTargetSearchDepth = 16384
+
+	// onlineRecentStates is the number of recent states to retain in addition
+	// to the pruning target. It matches the in-memory layers kept by the
+	// hash-based trie database.
+	onlineRecentStates = 128
+)
+
+var (
+	onlinePhaseGauge   = metrics.NewRegisteredGauge("state/pruner/online/phase", nil)
+	onlineMarkedMeter  = metrics.NewRegisteredMeter("state/pruner/online/marked", nil)
+	onlineDeletedMeter = metrics.NewRegisteredMeter("state/pruner/online/deleted", nil)
+	onlineBytesMeter   = metrics.NewRegisteredMeter("state/pruner/online/bytes", nil)
+)
+
+// Phases of the online pruning, also reported via the phase gauge.
+const (
+	OnlineIdle     = "idle"
+	OnlineMarking  = "marking"
+	OnlineSweeping = "sweeping"
+)
+
+var onlinePhaseIDs = map[string]int64{OnlineIdle: 0, OnlineMarking: 1, OnlineSweeping: 2}
+
+var (
+	// errOnlineRunning is returned if a pruning is requested while another
+	// one is still in progress.
+	errOnlineRunning = errors.New("state pruning already in progress")
+
+	// errOnlineStopped is returned if the pruning is interrupted by Stop.
+	errOnlineStopped = errors.New("state pruning stopped")
+)
+
+// OnlineConfig includes the configurations for online pruning.
+type OnlineConfig struct {
+	Datadir   string        // The directory to store the state bloom in
+	BloomSize uint64        // The Megabytes of memory allocated to bloom-filter
+	Interval  time.Duration // Pause between consecutive deletion batches
+}
+
+// Chain is the subset of the blockchain methods required by the online pruner.
+type Chain interface {
+	// CurrentBlock retrieves the current head block of the canonical chain.
+	CurrentBlock() *types.Header
+
+	// GetHeaderByNumber retrieves a canonical block header by number.
+	GetHeaderByNumber(number uint64) *types.Header
+
+	// TrieDB retrieves the live trie database of the chain.
+	TrieDB() *trie.Database
+
+	// Snapshots retrieves the state snapshot tree, nil if snapshots are disabled.
+	Snapshots() *snapshot.Tree
+}
+
+// onlineStatus is the persisted progress of the sweeping phase.
+type onlineStatus struct {
+	Root    common.Hash // State root the pruning is targeting
+	Cursor  []byte      // Database key the sweeping is resumed from
+	Deleted uint64      // Number of trie nodes deleted so far
+	Size    uint64      // Storage size of the trie nodes deleted so far
+}
+
+// OnlineProgress is the progress report of the online pruning.
+type OnlineProgress struct {
+	Phase   string             `json:"phase"`
+	Root    common.Hash        `json:"root"`
+	Marked  uint64             `json:"marked"`
+	Deleted uint64             `json:"deleted"`
+	Size    common.StorageSize `json:"size"`
+	Percent float64            `json:"percent"`
+	Error   string             `json:"error,omitempty"`
+}
+
+// OnlinePruner deletes stale state of the legacy hash-based scheme while the
+// node keeps running. The workflow is:
+//
+//   - install a flush hook into the trie database, so that every node
+//     persisted from now on is considered live and recorded on disk
+//   - persist the head state and regenerate its nodes from the snapshot, or
+//     traverse a persisted state close to the head if no snapshot is available,
+//     then the recent in-memory states, putting all the referenced nodes into a
+//     bloom filter
+//   - iterate the database in throttled batches, deleting all trie nodes
+//     which are not contained in the bloom filter
+//
+// Once the bloom filter is committed, the pruning is resumed across restarts.
+// Contract codes are never deleted.
+type OnlinePruner struct {
+	config OnlineConfig
+	db     ethdb.Database
+	chain  Chain
+
+	bloom  *stateBloom // Live state filter, nil if no pruning is in progress
+	lock   sync.Mutex  // Lock protecting the bloom, the underlying filter isn't thread safe
+	status onlineStatus
+
+	phase  string
+	marked uint64
+	err    error
+	mu     sync.Mutex // Lock protecting the progress fields
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+// NewOnlinePruner creates an online pruner operating on the given chain.
+func NewOnlinePruner(db ethdb.Database, chain Chain, config OnlineConfig) *OnlinePruner {
+	if config.BloomSize < 256 {
+		log.Warn("Sanitizing bloomfilter size", "provided(MB)", config.BloomSize, "updated(MB)", 256)
+		config.BloomSize = 256
+	}
+	return &OnlinePruner{
+		config: config,
+		db:     db,
+		chain:  chain,
+		phase:  OnlineIdle,
+	}
+}
+
+// Resume continues an online pruning interrupted by a shutdown, if any.
+func (p *OnlinePruner) Resume() error {
+	blob := rawdb.ReadOnlinePruneStatus(p.db)
+	if len(blob) == 0 {
+		return nil
+	}
+	var status onlineStatus
+	if err := rlp.DecodeBytes(blob, &status); err != nil {
+		return fmt.Errorf("invalid online prune status: %w", err)
+	}
+	// Install the hook before loading the markers, so that no persisted node
+	// can slip through.
+	if err := p.chain.TrieDB().SetFlushHook(p.onFlush); err != nil {
+		return err
+	}
+	bloom, err := NewStateBloomFromDisk(p.bloomPath())
+	if err != nil {
+		p.chain.TrieDB().SetFlushHook(nil)
+		return fmt.Errorf("failed to load online state bloom: %w", err)
+	}
+	p.lock.Lock()
+	p.bloom = bloom
+	p.lock.Unlock()
+
+	it := rawdb.IterateOnlinePruneMarkers(p.db)
+	for it.Next() {
+		p.lock.Lock()
+		p.bloom.Put(it.Key()[len(it.Key())-common.HashLength:], nil)
+		p.lock.Unlock()
+	}
+	it.Release()
+
+	p.mu.Lock()
+	p.status = status
+	p.quit, p.done = make(chan struct{}), make(chan struct{})
+	quit, done := p.quit, p.done
+	p.mu.Unlock()
+	p.setPhase(OnlineSweeping)
+
+	log.Info("Resuming online state pruning", "root", status.Root, "deleted", status.Deleted, "size", common.StorageSize(status.Size))
+	go p.run(false, quit, done)
+	return nil
+}
+
+// Start initiates a new online pruning with the given bloom size (in MB) and
+// throttling interval. Zero values keep the configured defaults.
+func (p *OnlinePruner) Start(bloomSize uint64, interval time.Duration) error {
+	if p.chain.TrieDB().Scheme() != rawdb.HashScheme {
+		return errors.New("online pruning is only supported in hash scheme")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.phase != OnlineIdle {
+		return errOnlineRunning
+	}
+	if bloomSize != 0 {
+		if bloomSize < 256 {
+			bloomSize = 256
+		}
+		p.config.BloomSize = bloomSize
+	}
+	if interval != 0 {
+		p.config.Interval = interval
+	}
+	bloom, err := newStateBloomWithSize(p.config.BloomSize)
+	if err != nil {
+		return err
+	}
+	// Drop the leftovers of a previous pruning, then start tracking all the
+	// nodes persisted from now on. The status has to go first, a resumption
+	// without the complete set of markers would delete live nodes.
+	rawdb.DeleteOnlinePruneStatus(p.db)
+	os.Remove(p.bloomPath())
+	if err := p.deleteMarkers(); err != nil {
+		return err
+	}
+	p.lock.Lock()
+	p.bloom = bloom
+	p.lock.Unlock()
+	if err := p.chain.TrieDB().SetFlushHook(p.onFlush); err != nil {
+		return err
+	}
+	p.status = onlineStatus{}
+	p.marked, p.err = 0, nil
+	p.quit, p.done = make(chan struct{}), make(chan struct{})
+	p.phase = OnlineMarking
+	onlinePhaseGauge.Update(onlinePhaseIDs[OnlineMarking])
+
+	go p.run(true, p.quit, p.done)
+	return nil
+}
+
+// Stop interrupts the running pruning, if any. Once the marking is done, the
+// flush hook is intentionally left installed, so that nodes persisted during
+// the rest of the shutdown are still recorded for the next resumption.
+func (p *OnlinePruner) Stop() {
+	p.mu.Lock()
+	quit, done := p.quit, p.done
+	p.quit, p.done = nil, nil
+	p.mu.Unlock()
+
+	if quit != nil {
+		close(quit)
+		<-done
+	}
+}
+
+// Progress returns the current progress of the online pruning.
+func (p *OnlinePruner) Progress() OnlineProgress {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	progress := OnlineProgress{
+		Phase:   p.phase,
+		Root:    p.status.Root,
+		Marked:  p.marked,
+		Deleted: p.status.Deleted,
+		Size:    common.StorageSize(p.status.Size),
+	}
+	if len(p.status.Cursor) >= 8 {
+		progress.Percent = float64(binary.BigEndian.Uint64(p.status.Cursor[:8])) / float64(^uint64(0)) * 100
+	}
+	if p.err != nil {
+		progress.Error = p.err.Error()
+	}
+	return progress
+}
+
+// run executes the pruning in the background.
+func (p *OnlinePruner) run(mark bool, quit, done chan struct{}) {
+	defer close(done)
+
+	var err error
+	if mark {
+		err = p.mark(quit)
+	}
+	if err == nil {
+		p.setPhase(OnlineSweeping)
+		if err = p.sweep(quit); errors.Is(err, errOnlineStopped) {
+			log.Info("Online state pruning paused", "deleted", p.status.Deleted, "size", common.StorageSize(p.status.Size))
+			p.setPhase(OnlineIdle)
+			return
+		}
+	}
+	switch {
+	case errors.Is(err, errOnlineStopped):
+		log.Info("Online state pruning aborted during marking")
+		err = nil
+	case err != nil:
+		log.Error("Online state pruning failed", "err", err)
+	default:
+		log.Info("Online state pruning finished", "deleted", p.status.Deleted, "size", common.StorageSize(p.status.Size))
+	}
+	// The pruning either completed or has to be started over, clean up
+	p.chain.TrieDB().SetFlushHook(nil)
+	p.lock.Lock()
+	p.bloom = nil
+	p.lock.Unlock()
+
+	p.deleteMarkers()
+	rawdb.DeleteOnlinePruneStatus(p.db)
+	os.Remove(p.bloomPath())
+
+	p.mu.Lock()
+	p.phase, p.err = OnlineIdle, err
+	p.mu.Unlock()
+	onlinePhaseGauge.Update(onlinePhaseIDs[OnlineIdle])
+}
+
+// mark populates the bloom filter with the live state and commits it to disk.
+func (p *OnlinePruner) mark(quit chan struct{}) error {
+	var (
+		start    = time.Now()
+		triedb   = p.chain.TrieDB()
+		snaptree = p.chain.Snapshots()
+		head     = p.chain.CurrentBlock()
+		target   *types.Header
+	)
+	// Persist the head state if the snapshot covers it, so that it becomes the
+	// target and its nodes can be regenerated from the flat snapshot instead of
+	// being resolved one by one. The flush hook records them as any other.
+	if snaptree != nil && snaptree.Snapshot(head.Root) != nil {
+		if err := triedb.Commit(head.Root, false); err != nil {
+			return err
+		}
+	}
+	// Pick the most recent state persisted in the database as the target,
+	// skipping the ones still held in memory.
+	for number := head.Number.Uint64(); ; number-- {
+		if header := p.chain.GetHeaderByNumber(number); header != nil && rawdb.HasLegacyTrieNode(p.db, header.Root) {
+			target = header
+			break
+		}
+		if number == 0 || head.Number.Uint64()-number >= onlineTargetSearchDepth {
+			return errors.New("no persisted state available")
+		}
+	}
+	log.Info("Marking live state for online pruning", "number", target.Number, "root", target.Root)
+
+	p.mu.Lock()
+	p.status.Root = target.Root
+	p.mu.Unlock()
+
+	// The snapshot layers of the target might be flattened meanwhile, fall
+	// back to the trie in that case. Nodes marked so far are merely retained.
+	err := errors.New("snapshot unavailable")
+	if snaptree != nil {
+		err = markSnapshot(snaptree, target.Root, p, quit)
+	}
+	if errors.Is(err, errOnlineStopped) {
+		return err
+	}
+	if err != nil {
+		log.Info("Marking live state from the trie", "reason", err)
+		if err := markState(triedb, target.Root, p, quit); err != nil {
+			return err
+		}
+	}
+	genesis := p.chain.GetHeaderByNumber(0)
+	if genesis == nil {
+		return errors.New("missing genesis block")
+	}
+	if err := markState(triedb, genesis.Root, p, quit); err != nil {
+		return err
+	}
+	// Mark the nodes of the recent states which are not part of the target,
+	// the target itself might be the head. The newest states are marked first,
+	// the oldest ones might be dropped from memory meanwhile.
+	head = p.chain.CurrentBlock()
+	for i := uint64(0); i < onlineRecentStates && i <= head.Number.Uint64(); i++ {
+		header := p.chain.GetHeaderByNumber(head.Number.Uint64() - i)
+		if header == nil {
+			break
+		}
+		if header.Root == target.Root {
+			continue
+		}
+		err := markDifference(triedb, target.Root, header.Root, p, quit)
+		if errors.Is(err, errOnlineStopped) {
+			return err
+		}
+		var missing *trie.MissingNodeError
+		if errors.As(err, &missing) {
+			log.Debug("Recent state no longer available", "number", header.Number, "root", header.Root)
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	log.Info("Writing online state bloom to disk", "marked", p.Progress().Marked, "elapsed", common.PrettyDuration(time.Since(start)))
+
+	p.lock.Lock()
+	err = p.bloom.Commit(p.bloomPath(), p.bloomPath()+stateBloomFileTempSuffix)
+	p.lock.Unlock()
+	if err != nil {
+		return err
+	}
+	return p.saveStatus(p.db)
+}
+
+// sweep iterates the database and deletes the trie nodes missing from the
+// bloom filter, in throttled batches.
+func (p *OnlinePruner) sweep(quit chan struct{}) error {
+	type candidate struct {
+		key  []byte
+		size int
+	}
+	var (
+		logged     = time.Now()
+		candidates []candidate
+		size       int
+		iter       = p.db.NewIterator(nil, p.status.Cursor)
+	)
+	defer func() { iter.Release() }()
+
+	flush := func(next []byte) error {
+		p.lock.Lock()
+		defer p.lock.Unlock()
+
+		batch := p.db.NewBatch()
+		var deleted, freed uint64
+		for _, c := range candidates {
+			// Nodes might have been persisted again since collected
+			if p.bloom.Contain(c.key) {
+				continue
+			}
+			batch.Delete(c.key)
+			deleted++
+			freed += uint64(len(c.key) + c.size)
+		}
+		p.mu.Lock()
+		p.status.Cursor = next
+		p.status.Deleted += deleted
+		p.status.Size += freed
+		p.mu.Unlock()
+
+		if err := p.saveStatus(batch); err != nil {
+			return err
+		}
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		onlineDeletedMeter.Mark(int64(deleted))
+		onlineBytesMeter.Mark(int64(freed))
+		candidates, size = candidates[:0], 0
+		return nil
+	}
+	for iter.Next() {
+		key := iter.Key()
+		if len(key) != common.HashLength {
+			continue
+		}
+		p.lock.Lock()
+		live := p.bloom.Contain(key)
+		p.lock.Unlock()
+		if live {
+			continue
+		}
+		candidates = append(candidates, candidate{key: common.CopyBytes(key), size: len(iter.Value())})
+		size += len(key) + len(iter.Value())
+
+		if size >= ethdb.IdealBatchSize {
+			next := common.CopyBytes(key)
+			if err := flush(next); err != nil {
+				return err
+			}
+			if time.Since(logged) > 8*time.Second {
+				progress := p.Progress()
+				log.Info("Pruning state data online", "nodes", progress.Deleted, "size", progress.Size, "progress", fmt.Sprintf("%.2f%%", progress.Percent))
+				logged = time.Now()
+			}
+			// Recreate the iterator after every batch commit in order
+			// to allow the underlying compactor to delete the entries.
+			iter.Release()
+			select {
+			case <-quit:
+				return errOnlineStopped
+			case <-time.After(p.config.Interval):
+			}
+			iter = p.db.NewIterator(nil, next)
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	return flush(nil)
+}
+
+// Put implements ethdb.KeyValueWriter, marking the given node as live.
+func (p *OnlinePruner) Put(key []byte, value []byte) error {
+	p.lock.Lock()
+	err := p.bloom.Put(key, value)
+	p.lock.Unlock()
+
+	if err == nil {
+		p.mu.Lock()
+		p.marked++
+		p.mu.Unlock()
+		onlineMarkedMeter.Mark(1)
+	}
+	return err
+}
+
+// Delete implements ethdb.KeyValueWriter.
+func (p *OnlinePruner) Delete(key []byte) error { panic("not supported") }
+
+// onFlush is the trie database hook marking every persisted node as live, both
+// in the bloom filter and on disk for resumption.
+func (p *OnlinePruner) onFlush(w ethdb.KeyValueWriter, hash common.Hash) {
+	p.lock.Lock()
+	if p.bloom != nil {
+		p.bloom.Put(hash.Bytes(), nil)
+	}
+	p.lock.Unlock()
+	rawdb.WriteOnlinePruneMarker(w, hash)
+}
+
+// saveStatus persists the sweeping progress into the given writer.
+func (p *OnlinePruner) saveStatus(w ethdb.KeyValueWriter) error {
+	p.mu.Lock()
+	blob, err := rlp.EncodeToBytes(&p.status)
+	p.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	rawdb.WriteOnlinePruneStatus(w, blob)
+	return nil
+}
+
+// setPhase updates the current pruning phase.
+func (p *OnlinePruner) setPhase(phase string) {
+	p.mu.Lock()
+	p.phase = phase
+	p.mu.Unlock()
+	onlinePhaseGauge.Update(onlinePhaseIDs[phase])
+}
+
+// deleteMarkers removes all the markers of persisted nodes.
+func (p *OnlinePruner) deleteMarkers() error {
+	var (
+		batch = p.db.NewBatch()
+		it    = rawdb.IterateOnlinePruneMarkers(p.db)
+	)
+	defer it.Release()
+
+	for it.Next() {
+		batch.Delete(it.Key())
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	return batch.Write()
+}
+
+// bloomPath returns the location of the online state bloom filter.
+func (p *OnlinePruner) bloomPath() string {
+	return filepath.Join(p.config.Datadir, onlineBloomFileName)
+}
+
+// markSnapshot commits the nodes of the given state into the bloom filter,
+// regenerating them from the snapshot. It's much faster than walking the trie,
+// but the state has to be covered by the snapshot tree.
+func markSnapshot(snaptree *snapshot.Tree, root common.Hash, bloom ethdb.KeyValueWriter, quit chan struct{}) error {
+	mark := func(owner common.Hash, path []byte, hash common.Hash, blob []byte) {
+		bloom.Put(hash.Bytes(), nil)
+	}
+	accIter, err := snaptree.AccountIterator(root, common.Hash{})
+	if err != nil {
+		return err
+	}
+	defer accIter.Release()
+
+	accTrie := trie.NewStackTrie(mark)
+	for accIter.Next() {
+		select {
+		case <-quit:
+			return errOnlineStopped
+		default:
+		}
+		acc, err := types.FullAccount(accIter.Account())
+		if err != nil {
+			return err
+		}
+		if acc.Root != types.EmptyRootHash {
+			if err := markSnapshotStorage(snaptree, root, accIter.Hash(), acc.Root, mark); err != nil {
+				return err
+			}
+		}
+		if !bytes.Equal(acc.CodeHash, types.EmptyCodeHash.Bytes()) {
+			bloom.Put(acc.CodeHash, nil)
+		}
+		blob, err := rlp.EncodeToBytes(acc)
+		if err != nil {
+			return err
+		}
+		if err := accTrie.Update(accIter.Hash().Bytes(), blob); err != nil {
+			return err
+		}
+	}
+	if err := accIter.Error(); err != nil {
+		return err
+	}
+	if got, _ := accTrie.Commit(); got != root {
+		return fmt.Errorf("state root mismatch: have %x, want %x", got, root)
+	}
+	return nil
+}
+
+// markSnapshotStorage regenerates the storage trie of the given account from
+// the snapshot, passing its nodes to the given writer.
+func markSnapshotStorage(snaptree *snapshot.Tree, root common.Hash, account common.Hash, storageRoot common.Hash, mark trie.NodeWriteFunc) error {
+	it, err := snaptree.StorageIterator(root, account, common.Hash{})
+	if err != nil {
+		return err
+	}
+	defer it.Release()
+
+	storageTrie := trie.NewStackTrieWithOwner(mark, account)
+	for it.Next() {
+		if err := storageTrie.Update(it.Hash().Bytes(), it.Slot()); err != nil {
+			return err
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	if got, _ := storageTrie.Commit(); got != storageRoot {
+		return fmt.Errorf("storage root mismatch: account %x, have %x, want %x", account, got, storageRoot)
+	}
+	return nil
+}
+
+// markDifference commits the nodes of the state which are not contained in the
+// base state into the given bloom filter.
+func markDifference(triedb *trie.Database, base, root common.Hash, bloom ethdb.KeyValueWriter, quit chan struct{}) error {
+	baseTrie, err := trie.NewStateTrie(trie.StateTrieID(base), triedb)
+	if err != nil {
+		return err
+	}
+	rootTrie, err := trie.NewStateTrie(trie.StateTrieID(root), triedb)
+	if err != nil {
+		return err
+	}
+	baseIt, err := baseTrie.NodeIterator(nil)
+	if err != nil {
+		return err
+	}
+	rootIt, err := rootTrie.NodeIterator(nil)
+	if err != nil {
+		return err
+	}
+	accIter, _ := trie.NewDifferenceIterator(baseIt, rootIt)
+	for accIter.Next(true) {
+		select {
+		case <-quit:
+			return errOnlineStopped
+		default:
+		}
+		if hash := accIter.Hash(); hash != (common.Hash{}) {
+			bloom.Put(hash.Bytes(), nil)
+		}
+		if !accIter.Leaf() {
+			continue
+		}
+		var acc types.StateAccount
+		if err := rlp.DecodeBytes(accIter.LeafBlob(), &acc); err != nil {
+			return err
+		}
+		if acc.Root == types.EmptyRootHash {
+			continue
+		}
+		// Only mark the storage nodes not present in the base version
+		addrHash := common.BytesToHash(accIter.LeafKey())
+		oldRoot := types.EmptyRootHash
+		if old, err := baseTrie.GetAccountByHash(addrHash); err != nil {
+			return err
+		} else if old != nil {
+			oldRoot = old.Root
+		}
+		if oldRoot == acc.Root {
+			continue
+		}
+		oldTrie, err := trie.NewStateTrie(trie.StorageTrieID(base, addrHash, oldRoot), triedb)
+		if err != nil {
+			return err
+		}
+		newTrie, err := trie.NewStateTrie(trie.StorageTrieID(root, addrHash, acc.Root), triedb)
+		if err != nil {
+			return err
+		}
+		oldIt, err := oldTrie.NodeIterator(nil)
+		if err != nil {
+			return err
+		}
+		newIt, err := newTrie.NodeIterator(nil)
+		if err != nil {
+			return err
+		}
+		storageIter, _ := trie.NewDifferenceIterator(oldIt, newIt)
+		for storageIter.Next(true) {
+			if hash := storageIter.Hash(); hash != (common.Hash{}) {
+				bloom.Put(hash.Bytes(), nil)
+			}
+		}
+		if err := storageIter.Error(); err != nil {
+			return err
+		}
+	}
+	return accIter.Error()
+}
@@ -0,0 +1,161 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+func TestOnlinePruning(t *testing.T) {
+	t.Run("snapshot", func(t *testing.T) { testOnlinePruning(t, true) })
+	t.Run("trie", func(t *testing.T) { testOnlinePruning(t, false) })
+}
+
+func testOnlinePruning(t *testing.T, snapshots bool) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		genesis = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{address: {Balance: big.NewInt(1000000000000000000)}},
+		}
+		signer = types.LatestSigner(genesis.Config)
+	)
+	_, blocks, _ := core.GenerateChainWithGenesis(genesis, ethash.NewFaker(), onlineRecentStates+64, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignNewTx(key, signer, &types.LegacyTx{Nonce: uint64(i), GasPrice: b.BaseFee(), Gas: 21000, To: &common.Address{byte(i)}, Value: big.NewInt(1)})
+		b.AddTx(tx)
+	})
+	// Import the chain in archive mode, so that every state is persisted
+	db := rawdb.NewMemoryDatabase()
+	config := core.DefaultCacheConfigWithScheme(rawdb.HashScheme)
+	config.TrieDirtyDisabled = true
+	if !snapshots {
+		config.SnapshotLimit = 0
+	}
+	chain, err := core.NewBlockChain(db, config, genesis, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	pruner := NewOnlinePruner(db, chain, OnlineConfig{Datadir: t.TempDir(), BloomSize: 256})
+	if err := pruner.Start(0, 0); err != nil {
+		t.Fatalf("failed to start pruning: %v", err)
+	}
+	if err := pruner.Start(0, 0); err != errOnlineRunning {
+		t.Fatalf("concurrent pruning not rejected: %v", err)
+	}
+	for pruner.Progress().Phase != OnlineIdle {
+		time.Sleep(10 * time.Millisecond)
+	}
+	progress := pruner.Progress()
+	if progress.Error != "" {
+		t.Fatalf("pruning failed: %v", progress.Error)
+	}
+	if progress.Deleted == 0 {
+		t.Fatal("no stale state deleted")
+	}
+	// The head, recent and genesis states must be intact, historical ones gone
+	for _, root := range []common.Hash{blocks[len(blocks)-1].Root(), blocks[len(blocks)-onlineRecentStates].Root(), chain.Genesis().Root()} {
+		if err := markState(trie.NewDatabase(db, trie.HashDefaults), root, rawdb.NewMemoryDatabase(), nil); err != nil {
+			t.Fatalf("state %x corrupted: %v", root, err)
+		}
+	}
+	if rawdb.HasLegacyTrieNode(db, blocks[len(blocks)-onlineRecentStates-1].Root()) {
+		t.Fatal("stale state root not pruned")
+	}
+	if len(rawdb.ReadOnlinePruneStatus(db)) != 0 {
+		t.Fatal("prune status left behind")
+	}
+	it := rawdb.IterateOnlinePruneMarkers(db)
+	defer it.Release()
+	if it.Next() {
+		t.Fatal("prune markers left behind")
+	}
+}
+
+// Tests that the nodes regenerated from the snapshot are the ones of the trie.
+func TestMarkSnapshot(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		store   = common.HexToAddress("0xc0") // Stores the caller into its own slot
+		genesis = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				address: {Balance: big.NewInt(1000000000000000000)},
+				store:   {Code: common.FromHex("0x33335500"), Storage: map[common.Hash]common.Hash{{0x01}: {0x01}}},
+			},
+		}
+		signer = types.LatestSigner(genesis.Config)
+	)
+	_, blocks, _ := core.GenerateChainWithGenesis(genesis, ethash.NewFaker(), 8, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignNewTx(key, signer, &types.LegacyTx{Nonce: uint64(i), GasPrice: b.BaseFee(), Gas: 100000, To: &store})
+		b.AddTx(tx)
+	})
+	db := rawdb.NewMemoryDatabase()
+	chain, err := core.NewBlockChain(db, core.DefaultCacheConfigWithScheme(rawdb.HashScheme), genesis, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	var (
+		root = blocks[len(blocks)-1].Root()
+		have = memorydb.New()
+		want = memorydb.New()
+	)
+	if err := markSnapshot(chain.Snapshots(), root, have, nil); err != nil {
+		t.Fatalf("failed to mark snapshot: %v", err)
+	}
+	if err := markState(chain.TrieDB(), root, want, nil); err != nil {
+		t.Fatalf("failed to mark state: %v", err)
+	}
+	if have.Len() != want.Len() {
+		t.Errorf("marked node count mismatch: have %d, want %d", have.Len(), want.Len())
+	}
+	it := want.NewIterator(nil, nil)
+	defer it.Release()
+	for it.Next() {
+		if ok, _ := have.Has(it.Key()); !ok {
+			t.Errorf("node %x not marked", it.Key())
+		}
+	}
+	// States not covered by the snapshot are rejected
+	if err := markSnapshot(chain.Snapshots(), common.Hash{0x01}, have, nil); err == nil {
+		t.Error("missing snapshot not reported")
+	}
+}
@@ -408,7 +408,14 @@ func extractGenesis(db ethdb.Database, stateBloom *stateBloom) error {
 	if genesis == nil {
 		return errors.New("missing genesis block")
 	}
-	t, err := trie.NewStateTrie(trie.StateTrieID(genesis.Root()), trie.NewDatabase(db, trie.HashDefaults))
+	return markState(trie.NewDatabase(db, trie.HashDefaults), genesis.Root(), stateBloom, nil)
+}
+
+// markState traverses the entire state with the given root and commits all the
+// trie nodes and contract code hashes into the given bloomfilter. The traversal
+// is aborted with errOnlineStopped if the quit channel is closed.
+func markState(triedb *trie.Database, root common.Hash, bloom ethdb.KeyValueWriter, quit chan struct{}) error {
+	t, err := trie.NewStateTrie(trie.StateTrieID(root), triedb)
 	if err != nil {
 		return err
 	}
@@ -417,11 +424,16 @@ func extractGenesis(db ethdb.Database, stateBloom *stateBloom) error {
 		return err
 	}
 	for accIter.Next(true) {
+		select {
+		case <-quit:
+			return errOnlineStopped
+		default:
+		}
 		hash := accIter.Hash()
 
 		// Embedded nodes don't have hash.
 		if hash != (common.Hash{}) {
-			stateBloom.Put(hash.Bytes(), nil)
+			bloom.Put(hash.Bytes(), nil)
 		}
 		// If it's a leaf node, yes we are touching an account,
 		// dig into the storage trie further.
@@ -431,8 +443,8 @@ func extractGenesis(db ethdb.Database, stateBloom *stateBloom) error {
 				return err
 			}
 			if acc.Root != types.EmptyRootHash {
-				id := trie.StorageTrieID(genesis.Root(), common.BytesToHash(accIter.LeafKey()), acc.Root)
-				storageTrie, err := trie.NewStateTrie(id, trie.NewDatabase(db, trie.HashDefaults))
+				id := trie.StorageTrieID(root, common.BytesToHash(accIter.LeafKey()), acc.Root)
+				storageTrie, err := trie.NewStateTrie(id, triedb)
 				if err != nil {
 					return err
 				}
@@ -443,7 +455,7 @@ func extractGenesis(db ethdb.Database, stateBloom *stateBloom) error {
 				for storageIter.Next(true) {
 					hash := storageIter.Hash()
 					if hash != (common.Hash{}) {
-						stateBloom.Put(hash.Bytes(), nil)
+						bloom.Put(hash.Bytes(), nil)
 					}
 				}
 				if storageIter.Error() != nil {
@@ -451,7 +463,7 @@ func extractGenesis(db ethdb.Database, stateBloom *stateBloom) error {
 				}
 			}
 			if !bytes.Equal(acc.CodeHash, types.EmptyCodeHash.Bytes()) {
-				stateBloom.Put(acc.CodeHash, nil)
+				bloom.Put(acc.CodeHash, nil)
 			}
 		}
 	}
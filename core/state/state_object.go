@@ -346,6 +346,15 @@ func (s *stateObject) updateTrie() (Trie, error) {
 				origin[khash] = b
 			}
 		}
+		// Track the original value of the slot by its raw key, mutated first time
+		prevs := s.db.storagesPrev[s.address]
+		if prevs == nil {
+			prevs = make(map[common.Hash]common.Hash)
+			s.db.storagesPrev[s.address] = prevs
+		}
+		if _, ok := prevs[key]; !ok {
+			prevs[key] = prev
+		}
 		// Cache the items for preloading
 		usedStorage = append(usedStorage, common.CopyBytes(key[:])) // Copy needed for closure
 	}
@@ -18,6 +18,7 @@
 package state
 
 import (
+	"bytes"
 	"fmt"
 	"math/big"
 	"sort"
@@ -79,6 +80,9 @@ type StateDB struct {
 	accountsOrigin map[common.Address][]byte                 // The original value of mutated accounts in 'slim RLP' encoding
 	storagesOrigin map[common.Address]map[common.Hash][]byte // The original value of mutated slots in prefix-zero trimmed rlp format
 
+	// The original value of mutated slots by their raw key, for state diffs.
+	storagesPrev map[common.Address]map[common.Hash]common.Hash
+
 	// This map holds 'live' objects, which will get modified while processing
 	// a state transition.
 	stateObjects         map[common.Address]*stateObject
@@ -116,6 +120,10 @@ type StateDB struct {
 	// Transient storage
 	transientStorage transientStorage
 
+	// State read by the execution, only tracked by the views created by
+	// CopyView for parallel transaction execution.
+	reads *AccessSet
+
 	// Journal of state modifications. This is the backbone of
 	// Snapshot and RevertToSnapshot.
 	journal        *journal
@@ -160,6 +168,7 @@ func New(root common.Hash, db Database, snaps *snapshot.Tree) (*StateDB, error)
 		storages:             make(map[common.Hash]map[common.Hash][]byte),
 		accountsOrigin:       make(map[common.Address][]byte),
 		storagesOrigin:       make(map[common.Address]map[common.Hash][]byte),
+		storagesPrev:         make(map[common.Address]map[common.Hash]common.Hash),
 		stateObjects:         make(map[common.Address]*stateObject),
 		stateObjectsPending:  make(map[common.Address]struct{}),
 		stateObjectsDirty:    make(map[common.Address]struct{}),
@@ -279,18 +288,21 @@ func (s *StateDB) SubRefund(gas uint64) {
 // Exist reports whether the given account address exists in the state.
 // Notably this also returns true for self-destructed accounts.
 func (s *StateDB) Exist(addr common.Address) bool {
+	s.recordAccount(addr)
 	return s.getStateObject(addr) != nil
 }
 
 // Empty returns whether the state object is either non-existent
 // or empty according to the EIP161 specification (balance = nonce = code = 0)
 func (s *StateDB) Empty(addr common.Address) bool {
+	s.recordAccount(addr)
 	so := s.getStateObject(addr)
 	return so == nil || so.empty()
 }
 
 // GetBalance retrieves the balance from the given address or 0 if object not found
 func (s *StateDB) GetBalance(addr common.Address) *big.Int {
+	s.recordAccount(addr)
 	stateObject := s.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.Balance()
@@ -300,6 +312,7 @@ func (s *StateDB) GetBalance(addr common.Address) *big.Int {
 
 // GetNonce retrieves the nonce from the given address or 0 if object not found
 func (s *StateDB) GetNonce(addr common.Address) uint64 {
+	s.recordAccount(addr)
 	stateObject := s.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.Nonce()
@@ -311,6 +324,7 @@ func (s *StateDB) GetNonce(addr common.Address) uint64 {
 // GetStorageRoot retrieves the storage root from the given address or empty
 // if object not found.
 func (s *StateDB) GetStorageRoot(addr common.Address) common.Hash {
+	s.recordAccount(addr)
 	stateObject := s.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.Root()
@@ -324,6 +338,7 @@ func (s *StateDB) TxIndex() int {
 }
 
 func (s *StateDB) GetCode(addr common.Address) []byte {
+	s.recordAccount(addr)
 	stateObject := s.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.Code()
@@ -332,6 +347,7 @@ func (s *StateDB) GetCode(addr common.Address) []byte {
 }
 
 func (s *StateDB) GetCodeSize(addr common.Address) int {
+	s.recordAccount(addr)
 	stateObject := s.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.CodeSize()
@@ -340,6 +356,7 @@ func (s *StateDB) GetCodeSize(addr common.Address) int {
 }
 
 func (s *StateDB) GetCodeHash(addr common.Address) common.Hash {
+	s.recordAccount(addr)
 	stateObject := s.getStateObject(addr)
 	if stateObject == nil {
 		return common.Hash{}
@@ -349,6 +366,7 @@ func (s *StateDB) GetCodeHash(addr common.Address) common.Hash {
 
 // GetState retrieves a value from the given account's storage trie.
 func (s *StateDB) GetState(addr common.Address, hash common.Hash) common.Hash {
+	s.recordSlot(addr, hash)
 	stateObject := s.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.GetState(hash)
@@ -358,6 +376,7 @@ func (s *StateDB) GetState(addr common.Address, hash common.Hash) common.Hash {
 
 // GetCommittedState retrieves a value from the given account's committed storage trie.
 func (s *StateDB) GetCommittedState(addr common.Address, hash common.Hash) common.Hash {
+	s.recordSlot(addr, hash)
 	stateObject := s.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.GetCommittedState(hash)
@@ -371,6 +390,7 @@ func (s *StateDB) Database() Database {
 }
 
 func (s *StateDB) HasSelfDestructed(addr common.Address) bool {
+	s.recordAccount(addr)
 	stateObject := s.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.selfDestructed
@@ -689,6 +709,7 @@ func (s *StateDB) createObject(addr common.Address) (newobj, prev *stateObject)
 //
 // Carrying over the balance ensures that Ether doesn't disappear.
 func (s *StateDB) CreateAccount(addr common.Address) {
+	s.recordAccount(addr)
 	newObj, prev := s.createObject(addr)
 	if prev != nil {
 		newObj.setBalance(prev.data.Balance)
@@ -707,6 +728,7 @@ func (s *StateDB) Copy() *StateDB {
 		storages:             make(map[common.Hash]map[common.Hash][]byte),
 		accountsOrigin:       make(map[common.Address][]byte),
 		storagesOrigin:       make(map[common.Address]map[common.Hash][]byte),
+		storagesPrev:         make(map[common.Address]map[common.Hash]common.Hash),
 		stateObjects:         make(map[common.Address]*stateObject, len(s.journal.dirties)),
 		stateObjectsPending:  make(map[common.Address]struct{}, len(s.stateObjectsPending)),
 		stateObjectsDirty:    make(map[common.Address]struct{}, len(s.journal.dirties)),
@@ -767,6 +789,12 @@ func (s *StateDB) Copy() *StateDB {
 	state.storages = copy2DSet(s.storages)
 	state.accountsOrigin = copySet(state.accountsOrigin)
 	state.storagesOrigin = copy2DSet(state.storagesOrigin)
+	for addr, slots := range s.storagesPrev {
+		state.storagesPrev[addr] = make(map[common.Hash]common.Hash, len(slots))
+		for key, prev := range slots {
+			state.storagesPrev[addr][key] = prev
+		}
+	}
 
 	// Deep copy the logs occurred in the scope of block
 	for hash, logs := range s.logs {
@@ -1164,6 +1192,62 @@ func (s *StateDB) handleDestruction(nodes *trienode.MergedNodeSet) (map[common.A
 	return incomplete, nil
 }
 
+// StateDiff returns the account and storage changes made in the scope of the
+// block, sorted by address and slot. It needs to be called after the changes
+// are finalized via IntermediateRoot and before Commit.
+//
+// Accounts destructed in the block have their previous storage wiped. Only the
+// storage slots written in the block are listed for them, the previous value
+// of slots only written after the destruction is reported as empty.
+func (s *StateDB) StateDiff() []*types.AccountDiff {
+	diffs := make([]*types.AccountDiff, 0, len(s.stateObjectsDirty))
+	for addr := range s.stateObjectsDirty {
+		obj := s.stateObjects[addr]
+		if obj == nil {
+			continue
+		}
+		diff := &types.AccountDiff{Address: addr}
+		if prev, destructed := s.stateObjectsDestruct[addr]; destructed {
+			diff.Prev, diff.Destructed = types.NewDiffAccount(prev), prev != nil && prev.Root != types.EmptyRootHash
+		} else {
+			diff.Prev = types.NewDiffAccount(obj.origin)
+		}
+		if !obj.deleted {
+			diff.Post = types.NewDiffAccount(&obj.data)
+		}
+		for key, prev := range s.storagesPrev[addr] {
+			var post common.Hash
+			if !obj.deleted {
+				post = obj.originStorage[key]
+			}
+			if prev != post {
+				diff.Storage = append(diff.Storage, &types.SlotDiff{Key: key, Prev: prev, Post: post})
+			}
+		}
+		// Skip the accounts which were merely touched
+		if len(diff.Storage) == 0 && !diff.Destructed && sameDiffAccount(diff.Prev, diff.Post) {
+			continue
+		}
+		sort.Slice(diff.Storage, func(i, j int) bool {
+			return bytes.Compare(diff.Storage[i].Key[:], diff.Storage[j].Key[:]) < 0
+		})
+		diffs = append(diffs, diff)
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		return bytes.Compare(diffs[i].Address[:], diffs[j].Address[:]) < 0
+	})
+	return diffs
+}
+
+// sameDiffAccount reports whether two accounts of a state diff are identical,
+// comparing the balances by value rather than by representation.
+func sameDiffAccount(a, b *types.DiffAccount) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Nonce == b.Nonce && a.Balance.Cmp(b.Balance) == 0 && a.CodeHash == b.CodeHash
+}
+
 // Mark that the block is full processed
 func (s *StateDB) MarkFullProcessed() {
 	s.fullProcessed = true
@@ -1310,6 +1394,7 @@ func (s *StateDB) Commit(block uint64, deleteEmptyObjects bool) (common.Hash, er
 	s.storages = make(map[common.Hash]map[common.Hash][]byte)
 	s.accountsOrigin = make(map[common.Address][]byte)
 	s.storagesOrigin = make(map[common.Address]map[common.Hash][]byte)
+	s.storagesPrev = make(map[common.Address]map[common.Hash]common.Hash)
 	s.stateObjectsDirty = make(map[common.Address]struct{})
 	s.stateObjectsDestruct = make(map[common.Address]*types.StateAccount)
 	return root, nil
@@ -19,6 +19,7 @@ package state
 import (
 	"bytes"
 	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
@@ -1191,3 +1192,68 @@ func TestDeleteStorage(t *testing.T) {
 		t.Fatalf("difference found:\nfast: %v\nslow: %v\n", fastRes, slowRes)
 	}
 }
+
+func TestStateDiff(t *testing.T) {
+	var (
+		db       = NewDatabase(rawdb.NewMemoryDatabase())
+		state, _ = New(types.EmptyRootHash, db, nil)
+		addrA    = common.HexToAddress("0x1")
+		addrB    = common.HexToAddress("0x2")
+		addrC    = common.HexToAddress("0x3")
+		addrD    = common.HexToAddress("0x5")
+		slotA    = common.HexToHash("0x1")
+		slotB    = common.HexToHash("0x2")
+	)
+	state.SetBalance(addrA, big.NewInt(1))
+	state.SetState(addrA, slotA, common.HexToHash("0x1"))
+	state.SetState(addrA, slotB, common.HexToHash("0x2"))
+	state.SetNonce(addrB, 1)
+	state.SetNonce(addrD, 1)
+	root, _ := state.Commit(0, true)
+
+	// Mutate the state in the next block: update a slot, clear a slot,
+	// write a slot back to its original value, delete and create accounts.
+	state, _ = New(root, db, nil)
+	state.SetState(addrA, slotA, common.HexToHash("0x3"))
+	state.SetState(addrA, slotB, common.Hash{})
+	state.SetBalance(addrA, big.NewInt(2))
+	state.IntermediateRoot(true)
+	state.SetState(addrA, slotA, common.HexToHash("0x1"))
+	state.SelfDestruct(addrB)
+	state.SetBalance(addrC, big.NewInt(3))
+	state.AddBalance(common.HexToAddress("0x4"), new(big.Int)) // touched only
+	state.AddBalance(addrD, big.NewInt(5))                     // balance restored
+	state.SubBalance(addrD, big.NewInt(5))
+	state.IntermediateRoot(true)
+
+	want := []*types.AccountDiff{
+		{
+			Address: addrA,
+			Prev:    &types.DiffAccount{Balance: big.NewInt(1), CodeHash: types.EmptyCodeHash},
+			Post:    &types.DiffAccount{Balance: big.NewInt(2), CodeHash: types.EmptyCodeHash},
+			Storage: []*types.SlotDiff{{Key: slotB, Prev: common.HexToHash("0x2")}},
+		},
+		{
+			Address: addrB,
+			Prev:    &types.DiffAccount{Nonce: 1, Balance: new(big.Int), CodeHash: types.EmptyCodeHash},
+		},
+		{
+			Address: addrC,
+			Post:    &types.DiffAccount{Balance: big.NewInt(3), CodeHash: types.EmptyCodeHash},
+		},
+	}
+	dump := func(diff []*types.AccountDiff) string {
+		blob, _ := json.Marshal(diff)
+		return string(blob)
+	}
+	if have := state.StateDiff(); !reflect.DeepEqual(have, want) {
+		t.Fatalf("state diff mismatch:\nhave: %v\nwant: %v", dump(have), dump(want))
+	}
+	// Diffs are tracked in the scope of a block only
+	if _, err := state.Commit(1, true); err != nil {
+		t.Fatal(err)
+	}
+	if diff := state.StateDiff(); len(diff) != 0 {
+		t.Fatalf("state diff not reset after commit: %v", dump(diff))
+	}
+}
@@ -93,31 +93,40 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 	// usually do have two tx, one for validator set contract, another for system reward contract.
 	systemTxs := make([]*types.Transaction, 0, 2)
 
-	for i, tx := range block.Transactions() {
-		if isPoSA {
-			if isSystemTx, err := posa.IsSystemTransaction(tx, block.Header()); err != nil {
-				bloomProcessors.Close()
-				return statedb, nil, nil, 0, err
-			} else if isSystemTx {
-				systemTxs = append(systemTxs, tx)
-				continue
-			}
-		}
-
-		msg, err := TransactionToMessage(tx, signer, header.BaseFee)
+	if p.parallel(cfg, blockNumber, txNum) {
+		var err error
+		commonTxs, systemTxs, receipts, err = p.processParallel(block, statedb, cfg, gp, usedGas, bloomProcessors)
 		if err != nil {
 			bloomProcessors.Close()
 			return statedb, nil, nil, 0, err
 		}
-		statedb.SetTxContext(tx.Hash(), i)
+	} else {
+		for i, tx := range block.Transactions() {
+			if isPoSA {
+				if isSystemTx, err := posa.IsSystemTransaction(tx, block.Header()); err != nil {
+					bloomProcessors.Close()
+					return statedb, nil, nil, 0, err
+				} else if isSystemTx {
+					systemTxs = append(systemTxs, tx)
+					continue
+				}
+			}
 
-		receipt, err := applyTransaction(msg, p.config, gp, statedb, blockNumber, blockHash, tx, usedGas, vmenv, bloomProcessors)
-		if err != nil {
-			bloomProcessors.Close()
-			return statedb, nil, nil, 0, fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), err)
+			msg, err := TransactionToMessage(tx, signer, header.BaseFee)
+			if err != nil {
+				bloomProcessors.Close()
+				return statedb, nil, nil, 0, err
+			}
+			statedb.SetTxContext(tx.Hash(), i)
+
+			receipt, err := applyTransaction(msg, p.config, gp, statedb, blockNumber, blockHash, tx, usedGas, vmenv, bloomProcessors)
+			if err != nil {
+				bloomProcessors.Close()
+				return statedb, nil, nil, 0, fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), err)
+			}
+			commonTxs = append(commonTxs, tx)
+			receipts = append(receipts, receipt)
 		}
-		commonTxs = append(commonTxs, tx)
-		receipts = append(receipts, receipt)
 	}
 	bloomProcessors.Close()
 
@@ -159,9 +168,15 @@ func applyTransaction(msg *Message, config *params.ChainConfig, gp *GasPool, sta
 	}
 	*usedGas += result.UsedGas
 
+	return newReceipt(msg, tx, result, statedb, blockNumber, blockHash, root, *usedGas, receiptProcessors...), err
+}
+
+// newReceipt creates the receipt of a transaction applied onto the state, which
+// was already finalised.
+func newReceipt(msg *Message, tx *types.Transaction, result *ExecutionResult, statedb *state.StateDB, blockNumber *big.Int, blockHash common.Hash, root []byte, usedGas uint64, receiptProcessors ...ReceiptProcessor) *types.Receipt {
 	// Create a new receipt for the transaction, storing the intermediate root and gas used
 	// by the tx.
-	receipt := &types.Receipt{Type: tx.Type(), PostState: root, CumulativeGasUsed: *usedGas}
+	receipt := &types.Receipt{Type: tx.Type(), PostState: root, CumulativeGasUsed: usedGas}
 	if result.Failed() {
 		receipt.Status = types.ReceiptStatusFailed
 	} else {
@@ -169,10 +184,11 @@ func applyTransaction(msg *Message, config *params.ChainConfig, gp *GasPool, sta
 	}
 	receipt.TxHash = tx.Hash()
 	receipt.GasUsed = result.UsedGas
+	receipt.BlobGasUsed = tx.BlobGas()
 
 	// If the transaction created a contract, store the creation address in the receipt.
 	if msg.To == nil {
-		receipt.ContractAddress = crypto.CreateAddress(evm.TxContext.Origin, tx.Nonce())
+		receipt.ContractAddress = crypto.CreateAddress(msg.From, tx.Nonce())
 	}
 
 	// Set the receipt logs and create the bloom filter.
@@ -183,7 +199,7 @@ func applyTransaction(msg *Message, config *params.ChainConfig, gp *GasPool, sta
 	for _, receiptProcessor := range receiptProcessors {
 		receiptProcessor.Apply(receipt)
 	}
-	return receipt, err
+	return receipt
 }
 
 // ApplyTransaction attempts to apply a transaction to the given state database
@@ -198,7 +214,7 @@ func ApplyTransaction(config *params.ChainConfig, bc ChainContext, author *commo
 	// Create a new context to be used in the EVM environment
 	blockContext := NewEVMBlockContext(header, bc, author)
 	vmenv := vm.NewEVM(blockContext, vm.TxContext{BlobHashes: tx.BlobHashes()}, statedb, config, cfg)
-	return applyTransaction(msg, config, gp, statedb, header.Number, header.Hash(), tx, usedGas, vmenv)
+	return applyTransaction(msg, config, gp, statedb, header.Number, header.Hash(), tx, usedGas, vmenv, NewReceiptBloomGenerator())
 }
 
 // ProcessBeaconBlockRoot applies the EIP-4788 system call to the beacon block root
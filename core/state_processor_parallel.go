@@ -0,0 +1,237 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+	"math/big"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var (
+	parallelTxMeter          = metrics.NewRegisteredMeter("chain/parallel/txs", nil)
+	parallelConflictMeter    = metrics.NewRegisteredMeter("chain/parallel/conflicts", nil)
+	parallelConflictRateHist = metrics.NewRegisteredHistogram("chain/parallel/conflictrate", nil, metrics.NewExpDecaySample(1028, 0.015))
+)
+
+// speculation is the outcome of executing a transaction on top of the state at
+// the start of the block, in isolation from the other transactions.
+type speculation struct {
+	msg     *Message
+	result  *ExecutionResult
+	reads   *state.AccessSet
+	changes *state.TxChanges
+	err     error
+	tracer  vm.EVMLogger // Tracer of the transaction, nil if not traced
+
+	done chan struct{} // Closed when the speculation finished
+}
+
+// txTracer is implemented by the tracers whose traces of the transactions are
+// independent of each other. The transactions of a block can be executed in
+// parallel with such a tracer, each one being traced separately.
+type txTracer interface {
+	vm.EVMLogger
+
+	// newTxTracer creates a tracer for a single transaction.
+	newTxTracer() vm.EVMLogger
+
+	// addTxTrace appends the trace of the next transaction of the block,
+	// collected by a tracer created by newTxTracer.
+	addTxTrace(tracer vm.EVMLogger)
+}
+
+// parallel reports whether the transactions of a block are to be executed in
+// parallel. Tracing requires the transactions to run in order unless they are
+// traced separately, and the state roots of pre-Byzantium receipts require the
+// state to be hashed in between.
+func (p *StateProcessor) parallel(cfg vm.Config, number *big.Int, txs int) bool {
+	if p.bc == nil || !p.bc.cacheConfig.ParallelTx {
+		return false
+	}
+	if _, ok := cfg.Tracer.(txTracer); cfg.Tracer != nil && !ok {
+		return false
+	}
+	return txs > 1 && p.config.IsByzantium(number)
+}
+
+// processParallel executes the transactions of a block optimistically in
+// parallel, yielding the same result as executing them one after the other.
+//
+// Every transaction is first executed on its own view of the state at the start
+// of the block, recording the state it read. The results are then applied onto
+// the state in order, as long as none of the state read by a transaction was
+// written by a transaction before it. Conflicting transactions are re-executed
+// on the state, as are the ones which self-destructed or reset an account, as
+// their effects can't be replayed.
+func (p *StateProcessor) processParallel(block *types.Block, statedb *state.StateDB, cfg vm.Config, gp *GasPool, usedGas *uint64, receiptProcessors ...ReceiptProcessor) (commonTxs, systemTxs []*types.Transaction, receipts []*types.Receipt, err error) {
+	var (
+		header       = block.Header()
+		blockHash    = block.Hash()
+		signer       = types.MakeSigner(p.config, header.Number, header.Time)
+		posa, isPoSA = p.engine.(consensus.PoSA)
+		indexes      []int
+	)
+	// System transactions are applied by the consensus engine, gather the rest
+	systemTxs = make([]*types.Transaction, 0, 2)
+	for i, tx := range block.Transactions() {
+		if isPoSA {
+			isSystemTx, err := posa.IsSystemTransaction(tx, header)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			if isSystemTx {
+				systemTxs = append(systemTxs, tx)
+				continue
+			}
+		}
+		commonTxs = append(commonTxs, tx)
+		indexes = append(indexes, i)
+	}
+	receipts = make([]*types.Receipt, 0, len(commonTxs))
+	if len(commonTxs) == 0 {
+		return commonTxs, systemTxs, receipts, nil
+	}
+	// Speculatively execute the transactions in the background
+	var (
+		base    = statedb.CopyView()
+		specs   = make([]*speculation, len(commonTxs))
+		workers = runtime.NumCPU()
+		next    atomic.Int64
+		abort   atomic.Bool
+		wg      sync.WaitGroup
+	)
+	for i := range specs {
+		specs[i] = &speculation{done: make(chan struct{})}
+	}
+	if workers > len(specs) {
+		workers = len(specs)
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// The block context caches block hashes, it can't be shared
+			context := NewEVMBlockContext(header, p.bc, nil)
+			for !abort.Load() {
+				i := int(next.Add(1) - 1)
+				if i >= len(specs) {
+					return
+				}
+				p.speculate(specs[i], commonTxs[i], indexes[i], base, context, signer, header, cfg)
+				close(specs[i].done)
+			}
+		}()
+	}
+	defer func() {
+		abort.Store(true)
+		wg.Wait()
+	}()
+
+	// Apply the results in order, re-executing the conflicting transactions
+	var (
+		vmenv     = vm.NewEVM(NewEVMBlockContext(header, p.bc, nil), vm.TxContext{}, statedb, p.config, cfg)
+		written   = state.NewAccessSet()
+		conflicts int
+	)
+	tracer, _ := cfg.Tracer.(txTracer)
+	for i, tx := range commonTxs {
+		spec := specs[i]
+		<-spec.done
+
+		statedb.SetTxContext(tx.Hash(), indexes[i])
+
+		var (
+			msg    = spec.msg
+			result *ExecutionResult
+			writes *state.AccessSet
+		)
+		if spec.err == nil && spec.changes.Mergeable() && gp.Gas() >= msg.GasLimit && !spec.reads.Conflicts(written) {
+			// The transaction saw the same state as if executed in order
+			statedb.ApplyTxChanges(spec.changes)
+			if err := gp.SubGas(spec.result.UsedGas); err != nil {
+				return nil, nil, nil, err
+			}
+			result, writes = spec.result, spec.changes.Writes
+			if tracer != nil {
+				tracer.addTxTrace(spec.tracer)
+			}
+		} else {
+			conflicts++
+			if msg == nil {
+				if msg, err = TransactionToMessage(tx, signer, header.BaseFee); err != nil {
+					return nil, nil, nil, err
+				}
+			}
+			if tracer != nil {
+				vmenv.Config.Tracer = tracer.newTxTracer()
+			}
+			vmenv.Reset(NewEVMTxContext(msg), statedb)
+			if result, err = ApplyMessage(vmenv, msg, gp); err != nil {
+				return nil, nil, nil, fmt.Errorf("could not apply tx %d [%v]: %w", indexes[i], tx.Hash().Hex(), err)
+			}
+			writes = statedb.TxChanges().Writes
+			if tracer != nil {
+				tracer.addTxTrace(vmenv.Config.Tracer)
+			}
+		}
+		written.Merge(writes)
+		statedb.Finalise(true)
+
+		*usedGas += result.UsedGas
+		receipts = append(receipts, newReceipt(msg, tx, result, statedb, header.Number, blockHash, nil, *usedGas, receiptProcessors...))
+	}
+	parallelTxMeter.Mark(int64(len(commonTxs)))
+	parallelConflictMeter.Mark(int64(conflicts))
+	parallelConflictRateHist.Update(int64(conflicts * 100 / len(commonTxs)))
+
+	return commonTxs, systemTxs, receipts, nil
+}
+
+// speculate executes a transaction on a fresh view of the base state, using a
+// gas pool of its own.
+func (p *StateProcessor) speculate(spec *speculation, tx *types.Transaction, index int, base *state.StateDB, context vm.BlockContext, signer types.Signer, header *types.Header, cfg vm.Config) {
+	msg, err := TransactionToMessage(tx, signer, header.BaseFee)
+	if err != nil {
+		spec.err = err
+		return
+	}
+	view := base.CopyView()
+	view.SetTxContext(tx.Hash(), index)
+
+	if tracer, ok := cfg.Tracer.(txTracer); ok {
+		spec.tracer = tracer.newTxTracer()
+		cfg.Tracer = spec.tracer
+	}
+
+	evm := vm.NewEVM(context, NewEVMTxContext(msg), view, p.config, cfg)
+	result, err := ApplyMessage(evm, msg, new(GasPool).AddGas(header.GasLimit))
+	if err == nil {
+		err = view.Error()
+	}
+	spec.msg, spec.result, spec.err = msg, result, err
+	spec.reads, spec.changes = view.Reads(), view.TxChanges()
+}
@@ -0,0 +1,154 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that executing the transactions of blocks in parallel yields the same
+// state and receipts as executing them in order, for independent transactions
+// as well as conflicting ones and ones which can't be merged.
+func TestParallelProcessing(t *testing.T) {
+	var (
+		keys    = make([]*ecdsa.PrivateKey, 4)
+		senders = make([]common.Address, 4)
+
+		counter  = common.HexToAddress("0xc0") // Increments a shared slot
+		perUser  = common.HexToAddress("0xc1") // Increments a slot of the caller, emitting a log
+		destruct = common.HexToAddress("0xc2") // Self-destructs to the caller
+		reader   = common.HexToAddress("0xc3") // Stores the balance of the coinbase
+		coinbase = common.HexToAddress("0xcb")
+
+		funds = new(big.Int).Mul(big.NewInt(1000), big.NewInt(params.Ether))
+		alloc = GenesisAlloc{
+			counter:  {Code: common.FromHex("0x60005460010160005500")},
+			perUser:  {Code: common.FromHex("0x3354600101335560006000a000")},
+			destruct: {Code: common.FromHex("0x33ff"), Balance: big.NewInt(1)},
+			reader:   {Code: append(append([]byte{byte(vm.PUSH20)}, coinbase.Bytes()...), byte(vm.BALANCE), byte(vm.PUSH1), 0, byte(vm.SSTORE))},
+		}
+	)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		senders[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+		alloc[senders[i]] = GenesisAccount{Balance: funds}
+	}
+	var (
+		gspec  = &Genesis{Config: params.TestChainConfig, Alloc: alloc, BaseFee: big.NewInt(params.InitialBaseFee)}
+		signer = types.LatestSigner(gspec.Config)
+		nonces = make([]uint64, len(keys))
+	)
+	send := func(b *BlockGen, from int, to *common.Address, value int64, data []byte) {
+		tx, err := types.SignNewTx(keys[from], signer, &types.DynamicFeeTx{
+			ChainID:   gspec.Config.ChainID,
+			Nonce:     nonces[from],
+			To:        to,
+			Value:     big.NewInt(value),
+			Gas:       200000,
+			GasFeeCap: b.header.BaseFee,
+			GasTipCap: big.NewInt(1),
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		nonces[from]++
+		b.AddTx(tx)
+	}
+	_, blocks, _ := GenerateChainWithGenesis(gspec, ethash.NewFaker(), 8, func(i int, b *BlockGen) {
+		b.SetCoinbase(coinbase)
+		switch i {
+		case 0:
+			// Independent transfers to fresh accounts and calls to own slots
+			for from := range keys {
+				send(b, from, &common.Address{byte(i), byte(from)}, 1000, nil)
+			}
+			send(b, 0, &perUser, 0, nil)
+			send(b, 1, &perUser, 0, nil)
+		case 1:
+			// Shared slot and shared sender conflicts
+			for from := range keys {
+				send(b, from, &counter, 0, nil)
+			}
+			send(b, 2, &counter, 0, nil)
+			send(b, 2, &perUser, 0, nil)
+		case 2:
+			// Contract creation, reading the coinbase and empty account touches
+			send(b, 0, nil, 0, common.FromHex("0x6001600c60003960016000f300"))
+			send(b, 1, &reader, 0, nil)
+			send(b, 2, &common.Address{0xee}, 0, nil)
+			send(b, 3, &perUser, 0, nil)
+		case 3:
+			// Self-destruct and a transfer to the destructed contract
+			send(b, 0, &destruct, 0, nil)
+			send(b, 1, &destruct, 5, nil)
+			send(b, 2, &perUser, 0, nil)
+		default:
+			for from := range keys {
+				send(b, from, &perUser, int64(from), nil)
+				send(b, from, &senders[(from+1)%len(senders)], 1, nil)
+			}
+		}
+		// The generator doesn't derive the receipt blooms, the processor does
+		for _, receipt := range b.receipts {
+			receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+		}
+	})
+	process := func(parallel bool) []types.Receipts {
+		db := rawdb.NewMemoryDatabase()
+		cacheConfig := *defaultCacheConfig
+		cacheConfig.ParallelTx = parallel
+
+		chain, err := NewBlockChain(db, &cacheConfig, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+		if err != nil {
+			t.Fatalf("failed to create chain: %v", err)
+		}
+		defer chain.Stop()
+
+		if n, err := chain.InsertChain(blocks); err != nil {
+			t.Fatalf("parallel=%v: failed to insert block %d: %v", parallel, n, err)
+		}
+		var receipts []types.Receipts
+		for _, block := range blocks {
+			receipts = append(receipts, chain.GetReceiptsByHash(block.Hash()))
+		}
+		return receipts
+	}
+	var (
+		txs       = parallelTxMeter.Snapshot().Count()
+		conflicts = parallelConflictMeter.Snapshot().Count()
+	)
+	serial, parallel := process(false), process(true)
+	if !reflect.DeepEqual(serial, parallel) {
+		t.Fatalf("receipts mismatch")
+	}
+	txs, conflicts = parallelTxMeter.Snapshot().Count()-txs, parallelConflictMeter.Snapshot().Count()-conflicts
+	if metricsEnabled := txs != 0; metricsEnabled && (conflicts == 0 || conflicts == txs) {
+		t.Errorf("conflict count unexpected: %d of %d transactions", conflicts, txs)
+	}
+}
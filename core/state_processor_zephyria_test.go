@@ -0,0 +1,190 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// The Zephyria engine depends on the core package, its tests live outside of it.
+package core_test
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/zephyria"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/tracers/logger"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that executing the transactions of Zephyria blocks in parallel yields
+// the same state and receipts as executing them in order, the system
+// transactions being applied by the engine after the others, and that a tracer
+// which can't trace the transactions separately falls back to serial execution.
+func TestParallelProcessingZephyria(t *testing.T) {
+	var (
+		validatorKey, _ = crypto.GenerateKey()
+		validator       = crypto.PubkeyToAddress(validatorKey.PublicKey)
+
+		keys    = make([]*ecdsa.PrivateKey, 3)
+		counter = common.HexToAddress("0xc0") // Increments a shared slot
+		funds   = new(big.Int).Mul(big.NewInt(1000), big.NewInt(params.Ether))
+		alloc   = core.GenesisAlloc{counter: {Code: common.FromHex("0x60005460010160005500")}}
+
+		config = *params.PolarysChainConfig
+	)
+	config.ChainID = params.TestChainConfig.ChainID
+	config.Zephyria = &params.ZephyriaConfig{Period: 3, Epoch: 200}
+
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		alloc[crypto.PubkeyToAddress(keys[i].PublicKey)] = core.GenesisAccount{Balance: funds}
+	}
+	// The validator is the only one, always in turn
+	extra := make([]byte, 32+common.AddressLength+crypto.SignatureLength)
+	copy(extra[32:], validator.Bytes())
+
+	var (
+		gspec  = &core.Genesis{Config: &config, Alloc: alloc, ExtraData: extra}
+		signer = types.LatestSigner(&config)
+		nonces = make([]uint64, len(keys))
+		engine = zephyria.New(&config, rawdb.NewMemoryDatabase(), nil, common.Hash{})
+	)
+	defer engine.Close()
+
+	engine.Authorize(validator, nil, func(account accounts.Account, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+		return types.SignTx(tx, types.LatestSignerForChainID(chainID), validatorKey)
+	})
+	gen := func(i int, b *core.BlockGen) {
+		b.SetCoinbase(validator)
+		b.SetExtra(make([]byte, 32+crypto.SignatureLength))
+
+		for from, key := range keys {
+			to := counter
+			if from == 0 {
+				to = common.Address{byte(i), 0xee} // Transfer to a fresh account
+			}
+			tx, err := types.SignNewTx(key, signer, &types.DynamicFeeTx{
+				ChainID:   config.ChainID,
+				Nonce:     nonces[from],
+				To:        &to,
+				Value:     big.NewInt(1000),
+				Gas:       100000,
+				GasFeeCap: b.BaseFee(),
+				GasTipCap: big.NewInt(0),
+			})
+			if err != nil {
+				t.Fatalf("failed to sign transaction: %v", err)
+			}
+			nonces[from]++
+			b.AddTx(tx)
+		}
+		// Pay the validator a tip to be distributed by system transactions
+		tx, _ := types.SignNewTx(keys[0], signer, &types.DynamicFeeTx{
+			ChainID:   config.ChainID,
+			Nonce:     nonces[0],
+			To:        &counter,
+			Gas:       100000,
+			GasFeeCap: new(big.Int).Add(b.BaseFee(), big.NewInt(params.GWei)),
+			GasTipCap: big.NewInt(params.GWei),
+		})
+		nonces[0]++
+		b.AddTx(tx)
+	}
+	seal := func(block *types.Block) *types.Block {
+		header := block.Header()
+		sig, err := crypto.Sign(zephyria.SealHash(header, config.ChainID).Bytes(), validatorKey)
+		if err != nil {
+			t.Fatalf("failed to seal block: %v", err)
+		}
+		copy(header.Extra[len(header.Extra)-crypto.SignatureLength:], sig)
+		return block.WithSeal(header)
+	}
+	// Generate the blocks one by one, sealing each before building on top of
+	// it so that the engine can retrieve the validators at every height
+	db, blocks, _ := core.GenerateChainWithGenesis(gspec, engine, 1, gen)
+	blocks[0] = seal(blocks[0])
+	for i := 1; i < 4; i++ {
+		next, _ := core.GenerateChain(&config, blocks[i-1], engine, db, 1, func(_ int, b *core.BlockGen) { gen(i, b) })
+		blocks = append(blocks, seal(next[0]))
+	}
+	for _, block := range blocks {
+		rawdb.WriteHeader(db, block.Header())
+	}
+	if systemTxs := len(blocks[0].Transactions()) - len(keys) - 1; systemTxs <= 1 {
+		t.Fatalf("system transactions missing: %d", systemTxs)
+	}
+
+	type result struct {
+		Root     common.Hash
+		Receipts types.Receipts
+		Logs     []*types.Log
+		UsedGas  uint64
+	}
+	process := func(parallel bool, cfg vm.Config) []result {
+		cacheConfig := *core.DefaultCacheConfigWithScheme(rawdb.HashScheme)
+		cacheConfig.ParallelTx = parallel
+
+		chain, err := core.NewBlockChain(db, &cacheConfig, gspec, nil, engine, vm.Config{}, nil, nil)
+		if err != nil {
+			t.Fatalf("failed to create chain: %v", err)
+		}
+		defer chain.Stop()
+
+		var results []result
+		for _, block := range blocks {
+			parent := chain.GetHeader(block.ParentHash(), block.NumberU64()-1)
+			statedb, err := chain.StateAt(parent.Root)
+			if err != nil {
+				t.Fatalf("block %d: failed to retrieve parent state: %v", block.NumberU64(), err)
+			}
+			statedb, receipts, logs, usedGas, err := chain.Processor().Process(block, statedb, cfg)
+			if err != nil {
+				t.Fatalf("parallel=%v: failed to process block %d: %v", parallel, block.NumberU64(), err)
+			}
+			root := statedb.IntermediateRoot(config.IsEIP158(block.Number()))
+			if root != block.Root() {
+				t.Errorf("parallel=%v: block %d: state root mismatch: have %x, want %x", parallel, block.NumberU64(), root, block.Root())
+			}
+			results = append(results, result{root, receipts, logs, usedGas})
+		}
+		return results
+	}
+	meter := func() int64 {
+		if m, ok := metrics.DefaultRegistry.Get("chain/parallel/txs").(metrics.Meter); ok {
+			return m.Snapshot().Count()
+		}
+		return 0
+	}
+	serial := process(false, vm.Config{})
+	if parallel := process(true, vm.Config{}); !reflect.DeepEqual(serial, parallel) {
+		t.Fatalf("parallel results mismatch")
+	}
+	// Tracers which can't be split per transaction execute the block in order
+	txs := meter()
+	if traced := process(true, vm.Config{Tracer: logger.NewStructLogger(nil)}); !reflect.DeepEqual(serial, traced) {
+		t.Fatalf("traced results mismatch")
+	}
+	if executed := meter() - txs; executed != 0 {
+		t.Errorf("traced transactions executed in parallel: %d", executed)
+	}
+}
@@ -0,0 +1,260 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// errTxHistoryDisabled is returned when querying the transaction history of an
+// address while the index isn't maintained.
+var errTxHistoryDisabled = errors.New("transaction history index not enabled")
+
+// TransactionsByAddress retrieves at most limit canonical transactions of an
+// address from the transaction history index, newest first, starting from the
+// transaction at the given position.
+//
+// Only the blocks from the index tail onwards are indexed, older transactions
+// are missing until the back-fill reaches them.
+func (bc *BlockChain) TransactionsByAddress(address common.Address, number uint64, index uint32, limit int) ([]*rawdb.AddressTransaction, error) {
+	if rawdb.ReadTxHistoryTail(bc.db) == nil {
+		return nil, errTxHistoryDisabled
+	}
+	return rawdb.ReadAddressTransactions(bc.db, address, number, index, limit), nil
+}
+
+// writeTxHistory indexes the transactions of a block by their sender, recipient
+// and created contract.
+func (bc *BlockChain) writeTxHistory(batch ethdb.KeyValueWriter, block *types.Block) {
+	signer := types.MakeSigner(bc.chainConfig, block.Number(), block.Time())
+	for i, tx := range block.Transactions() {
+		for address, roles := range txParticipants(signer, tx) {
+			rawdb.WriteAddressTransaction(batch, address, block.NumberU64(), block.Hash(), uint32(i), tx.Hash(), roles)
+		}
+	}
+}
+
+// writeInternalTxHistory indexes the transactions of a block by the addresses
+// called by contracts during their execution, which aren't direct participants.
+func (bc *BlockChain) writeInternalTxHistory(batch ethdb.KeyValueWriter, block *types.Block, tracer *internalCallTracer) {
+	var (
+		signer = types.MakeSigner(bc.chainConfig, block.Number(), block.Time())
+		txs    = block.Transactions()
+	)
+	for i, calls := range tracer.calls {
+		if i >= len(txs) {
+			break
+		}
+		direct := txParticipants(signer, txs[i])
+		for address := range calls {
+			if _, ok := direct[address]; ok {
+				continue
+			}
+			rawdb.WriteAddressTransaction(batch, address, block.NumberU64(), block.Hash(), uint32(i), txs[i].Hash(), rawdb.TxRoleInternal)
+		}
+	}
+}
+
+// txParticipants returns the roles of the addresses directly involved in a
+// transaction.
+func txParticipants(signer types.Signer, tx *types.Transaction) map[common.Address]rawdb.TxRole {
+	roles := make(map[common.Address]rawdb.TxRole)
+	from, err := types.Sender(signer, tx)
+	if err == nil {
+		roles[from] |= rawdb.TxRoleSender
+	}
+	if to := tx.To(); to != nil {
+		roles[*to] |= rawdb.TxRoleRecipient
+	} else if err == nil {
+		roles[crypto.CreateAddress(from, tx.Nonce())] |= rawdb.TxRoleCreation
+	}
+	return roles
+}
+
+// newInternalCallTracer creates a tracer collecting the internal calls of the
+// transactions of a block if they are indexed, nil otherwise. A configured VM
+// tracer takes precedence, the internal calls aren't indexed in that case.
+//
+// The transactions are still executed in parallel, each one being traced
+// separately. Only the call frames are recorded, but like any tracer it makes
+// the interpreter invoke the per-opcode hooks, slowing down the execution.
+func (bc *BlockChain) newInternalCallTracer() *internalCallTracer {
+	if !bc.cacheConfig.TxHistory || !bc.cacheConfig.TxHistoryInternal || bc.vmConfig.Tracer != nil {
+		return nil
+	}
+	return new(internalCallTracer)
+}
+
+// traceInternalCalls re-executes a historical block on top of its parent state,
+// collecting the internal calls of its transactions.
+func (bc *BlockChain) traceInternalCalls(block *types.Block) (*internalCallTracer, error) {
+	tracer := new(internalCallTracer)
+	if len(block.Transactions()) == 0 {
+		return tracer, nil
+	}
+	parent := bc.GetHeader(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, consensus.ErrUnknownAncestor
+	}
+	statedb, err := bc.StateAt(parent.Root)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, _, _, err := bc.processor.Process(block, statedb, vm.Config{Tracer: tracer}); err != nil {
+		return nil, err
+	}
+	return tracer, nil
+}
+
+// maintainTxHistory back-fills the transaction history index below the blocks
+// indexed during import, down to the genesis or the first unavailable block.
+// The internal calls are indexed as long as the historical state is available.
+func (bc *BlockChain) maintainTxHistory() {
+	defer bc.wg.Done()
+
+	headCh := make(chan ChainHeadEvent, 1)
+	sub := bc.SubscribeChainHeadEvent(headCh)
+	if sub == nil {
+		return
+	}
+	defer sub.Unsubscribe()
+
+	// Wait for the first indexed block if the index was just enabled
+	tail := rawdb.ReadTxHistoryTail(bc.db)
+	for tail == nil {
+		select {
+		case <-headCh:
+			tail = rawdb.ReadTxHistoryTail(bc.db)
+		case <-bc.quit:
+			return
+		}
+	}
+	if *tail == 0 {
+		return
+	}
+	var (
+		start    = time.Now()
+		logged   = time.Now()
+		first    = *tail
+		internal = bc.cacheConfig.TxHistoryInternal
+	)
+	log.Info("Back-filling transaction history", "tail", first)
+	for number := first; number > 0; number-- {
+		select {
+		case <-bc.quit:
+			return
+		default:
+		}
+		block := bc.GetBlockByNumber(number - 1)
+		if block == nil {
+			log.Warn("Stopped transaction history back-fill, block unavailable", "number", number-1)
+			return
+		}
+		batch := bc.db.NewBatch()
+		bc.writeTxHistory(batch, block)
+		if internal {
+			tracer, err := bc.traceInternalCalls(block)
+			if err != nil {
+				log.Warn("Stopped indexing internal calls", "number", block.NumberU64(), "err", err)
+				internal = false
+			} else {
+				bc.writeInternalTxHistory(batch, block, tracer)
+			}
+		}
+		rawdb.WriteTxHistoryTail(batch, block.NumberU64())
+		if err := batch.Write(); err != nil {
+			log.Crit("Failed writing transaction history", "err", err)
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Back-filling transaction history", "tail", block.NumberU64(), "blocks", first-block.NumberU64(), "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	log.Info("Back-filled transaction history", "blocks", first, "elapsed", common.PrettyDuration(time.Since(start)))
+}
+
+// internalCallTracer collects the addresses called by contracts, apart from the
+// precompiles, grouped by transaction.
+//
+// The system transactions of the consensus engine are applied without tracer.
+// Being the last ones of a block, the traced transactions are its leading ones.
+type internalCallTracer struct {
+	calls       []map[common.Address]struct{}
+	precompiles map[common.Address]struct{}
+}
+
+func (t *internalCallTracer) CaptureTxStart(gasLimit uint64) {
+	t.calls = append(t.calls, make(map[common.Address]struct{}))
+}
+
+func (t *internalCallTracer) CaptureTxEnd(restGas uint64) {}
+
+// newTxTracer implements txTracer, creating a tracer for a transaction executed
+// in parallel.
+func (t *internalCallTracer) newTxTracer() vm.EVMLogger {
+	return new(internalCallTracer)
+}
+
+// addTxTrace implements txTracer, appending the calls of a transaction traced
+// by a tracer created by newTxTracer.
+func (t *internalCallTracer) addTxTrace(tracer vm.EVMLogger) {
+	calls := make(map[common.Address]struct{})
+	if tx := tracer.(*internalCallTracer); len(tx.calls) > 0 {
+		calls = tx.calls[0]
+	}
+	t.calls = append(t.calls, calls)
+}
+
+func (t *internalCallTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	if t.precompiles == nil {
+		rules := env.ChainConfig().Rules(env.Context.BlockNumber, env.Context.Random != nil, env.Context.Time)
+		t.precompiles = make(map[common.Address]struct{})
+		for _, address := range vm.ActivePrecompiles(rules) {
+			t.precompiles[address] = struct{}{}
+		}
+	}
+}
+
+func (t *internalCallTracer) CaptureEnd(output []byte, gasUsed uint64, err error) {}
+
+func (t *internalCallTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	if len(t.calls) == 0 {
+		return
+	}
+	if _, ok := t.precompiles[to]; !ok {
+		t.calls[len(t.calls)-1][to] = struct{}{}
+	}
+}
+
+func (t *internalCallTracer) CaptureExit(output []byte, gasUsed uint64, err error) {}
+
+func (t *internalCallTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+}
+
+func (t *internalCallTracer) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
@@ -0,0 +1,219 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the transactions are indexed by their participants during import
+// and by the back-fill, including the addresses called internally.
+func TestTxHistory(t *testing.T) {
+	var (
+		key, _    = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender    = crypto.PubkeyToAddress(key.PublicKey)
+		recipient = common.HexToAddress("0xaaaa")
+		caller    = common.HexToAddress("0xcccc")
+		callee    = common.HexToAddress("0xbbbb")
+		created   = crypto.CreateAddress(sender, 3)
+		signer    = types.HomesteadSigner{}
+
+		// CALL(gas, callee, 0, 0, 0, 0, 0) followed by a call to the identity precompile
+		code = append(append(append(common.FromHex("60006000600060006000"), byte(vm.PUSH20)), callee.Bytes()...),
+			common.FromHex("5af1506000600060006000600060045af100")...)
+		gspec = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc: GenesisAlloc{
+				sender: {Balance: big.NewInt(params.Ether)},
+				caller: {Code: code},
+			},
+		}
+	)
+	_, blocks, _ := GenerateChainWithGenesis(gspec, ethash.NewFaker(), 4, func(i int, b *BlockGen) {
+		var tx *types.Transaction
+		switch i {
+		case 0, 2:
+			tx = types.NewTransaction(b.TxNonce(sender), recipient, big.NewInt(1), params.TxGas, b.BaseFee(), nil)
+		case 1:
+			tx = types.NewTransaction(b.TxNonce(sender), caller, nil, 100000, b.BaseFee(), nil)
+		case 3:
+			tx = types.NewContractCreation(b.TxNonce(sender), nil, 100000, b.BaseFee(), []byte{0x00})
+		}
+		tx, _ = types.SignTx(tx, signer, key)
+		b.AddTx(tx)
+	})
+	check := func(chain *BlockChain, address common.Address, want map[uint64]rawdb.TxRole) {
+		t.Helper()
+
+		txs, err := chain.TransactionsByAddress(address, math.MaxUint64, math.MaxUint32, 10)
+		if err != nil {
+			t.Fatalf("failed to retrieve transactions of %x: %v", address, err)
+		}
+		if len(txs) != len(want) {
+			t.Fatalf("transaction count mismatch for %x: have %d, want %d", address, len(txs), len(want))
+		}
+		for i, tx := range txs {
+			if i > 0 && tx.BlockNumber >= txs[i-1].BlockNumber {
+				t.Errorf("transactions of %x out of order: %d after %d", address, tx.BlockNumber, txs[i-1].BlockNumber)
+			}
+			block := blocks[tx.BlockNumber-1]
+			if tx.BlockHash != block.Hash() || tx.Hash != block.Transactions()[tx.Index].Hash() {
+				t.Errorf("transaction of %x in block %d mismatch", address, tx.BlockNumber)
+			}
+			if tx.Roles != want[tx.BlockNumber] {
+				t.Errorf("roles of %x in block %d mismatch: have %b, want %b", address, tx.BlockNumber, tx.Roles, want[tx.BlockNumber])
+			}
+		}
+	}
+	verify := func(chain *BlockChain) {
+		t.Helper()
+
+		check(chain, sender, map[uint64]rawdb.TxRole{1: rawdb.TxRoleSender, 2: rawdb.TxRoleSender, 3: rawdb.TxRoleSender, 4: rawdb.TxRoleSender})
+		check(chain, recipient, map[uint64]rawdb.TxRole{1: rawdb.TxRoleRecipient, 3: rawdb.TxRoleRecipient})
+		check(chain, caller, map[uint64]rawdb.TxRole{2: rawdb.TxRoleRecipient})
+		check(chain, callee, map[uint64]rawdb.TxRole{2: rawdb.TxRoleInternal})
+		check(chain, created, map[uint64]rawdb.TxRole{4: rawdb.TxRoleCreation})
+		check(chain, common.BytesToAddress([]byte{0x4}), nil)
+	}
+	config := *defaultCacheConfig
+	config.TrieDirtyDisabled = true
+	config.TxHistory = true
+	config.TxHistoryInternal = true
+
+	// Index the entire chain during import
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), &config, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	if _, err := chain.TransactionsByAddress(sender, math.MaxUint64, math.MaxUint32, 10); err == nil {
+		t.Fatal("transactions retrieved before any block was indexed")
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	verify(chain)
+	chain.Stop()
+
+	// Import the first blocks without the index and back-fill them
+	db := rawdb.NewMemoryDatabase()
+	config.TxHistory, config.TxHistoryInternal = false, false
+	chain, err = NewBlockChain(db, &config, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks[:2]); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	chain.Stop()
+
+	config.TxHistory, config.TxHistoryInternal = true, true
+	chain, err = NewBlockChain(db, &config, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks[2:]); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		if tail := rawdb.ReadTxHistoryTail(db); tail != nil && *tail == 0 {
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("transaction history not back-filled")
+		}
+	}
+	verify(chain)
+}
+
+// Tests that the internal calls are indexed when the transactions of a block
+// are executed in parallel, whether merged or re-executed due to conflicts.
+func TestTxHistoryParallel(t *testing.T) {
+	var (
+		key1, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		key2, _ = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+		sender1 = crypto.PubkeyToAddress(key1.PublicKey)
+		sender2 = crypto.PubkeyToAddress(key2.PublicKey)
+		caller  = common.HexToAddress("0xcccc")
+		callee  = common.HexToAddress("0xbbbb")
+		signer  = types.HomesteadSigner{}
+
+		// CALL(gas, callee, 0, 0, 0, 0, 0)
+		code  = append(append(common.FromHex("60006000600060006000"), byte(vm.PUSH20)), append(callee.Bytes(), common.FromHex("5af100")...)...)
+		gspec = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc: GenesisAlloc{
+				sender1: {Balance: big.NewInt(params.Ether)},
+				sender2: {Balance: big.NewInt(params.Ether)},
+				caller:  {Code: code},
+			},
+		}
+	)
+	// The last transaction depends on the first one, being re-executed
+	_, blocks, _ := GenerateChainWithGenesis(gspec, ethash.NewFaker(), 1, func(i int, b *BlockGen) {
+		for _, tx := range []*types.Transaction{
+			types.NewTransaction(0, common.HexToAddress("0xaaaa"), big.NewInt(1), params.TxGas, b.BaseFee(), nil),
+			types.NewTransaction(0, caller, nil, 100000, b.BaseFee(), nil),
+			types.NewTransaction(1, caller, nil, 100000, b.BaseFee(), nil),
+		} {
+			key := key1
+			if tx.Nonce() == 0 && tx.To() != nil && *tx.To() == caller {
+				key = key2
+			}
+			tx, _ = types.SignTx(tx, signer, key)
+			b.AddTx(tx)
+		}
+	})
+	config := *defaultCacheConfig
+	config.TxHistory = true
+	config.TxHistoryInternal = true
+	config.ParallelTx = true
+
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), &config, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	txs, err := chain.TransactionsByAddress(callee, math.MaxUint64, math.MaxUint32, 10)
+	if err != nil {
+		t.Fatalf("failed to retrieve transactions: %v", err)
+	}
+	if len(txs) != 2 {
+		t.Fatalf("transaction count mismatch: have %d, want 2", len(txs))
+	}
+	for i, index := range []uint32{2, 1} {
+		if txs[i].Index != index || txs[i].Roles != rawdb.TxRoleInternal {
+			t.Errorf("transaction %d mismatch: have index %d roles %b, want index %d roles %b", i, txs[i].Index, txs[i].Roles, index, rawdb.TxRoleInternal)
+		}
+	}
+}
@@ -16,7 +16,11 @@
 
 package txpool
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/core"
+)
 
 var (
 	// ErrAlreadyKnown is returned if the transactions is already contained
@@ -54,4 +58,100 @@ var (
 	// ErrFutureReplacePending is returned if a future transaction replaces a pending
 	// one. Future transactions should only be able to replace other future transactions.
 	ErrFutureReplacePending = errors.New("future transaction tries to replace pending")
+
+	// ErrTxPoolOverflow is returned if the transaction pool is full and can't accept
+	// another remote transaction.
+	ErrTxPoolOverflow = errors.New("txpool is full")
+
+	// ErrTxExpired is reported when a non-executable transaction is evicted after
+	// lingering in the pool for longer than the configured lifetime.
+	ErrTxExpired = errors.New("transaction expired")
+
+	// ErrAddressDenied is returned if the sender or the destination of a
+	// transaction is on the deny list of the pool's admission policy.
+	ErrAddressDenied = errors.New("address denied")
+
+	// ErrSenderRateLimited is returned if the sender of a transaction exceeded
+	// the rate of transactions admitted into the pool from a single account.
+	ErrSenderRateLimited = errors.New("sender rate limit exceeded")
+
+	// ErrContractRateLimited is returned if the destination of a transaction
+	// exceeded the rate of transactions admitted into the pool towards a single
+	// contract.
+	ErrContractRateLimited = errors.New("contract rate limit exceeded")
+
+	// ErrNonceGapLimit is returned if a transaction would exceed the number of
+	// non-executable, nonce-gapped transactions allowed from a single account.
+	ErrNonceGapLimit = errors.New("nonce gap limit exceeded")
+)
+
+// Reason codes attached to pool lifecycle events. They are meant to be stable
+// identifiers that API consumers can switch on, contrary to the error strings.
+const (
+	ReasonAlreadyKnown         = "already_known"
+	ReasonInvalidSender        = "invalid_sender"
+	ReasonUnderpriced          = "underpriced"
+	ReasonReplaceUnderpriced   = "replace_underpriced"
+	ReasonAccountLimit         = "account_limit"
+	ReasonGasLimit             = "gas_limit"
+	ReasonNegativeValue        = "negative_value"
+	ReasonOversizedData        = "oversized_data"
+	ReasonFutureReplacePending = "future_replace_pending"
+	ReasonPoolOverflow         = "pool_overflow"
+	ReasonExpired              = "expired"
+	ReasonNonceTooLow          = "nonce_too_low"
+	ReasonNonceTooHigh         = "nonce_too_high"
+	ReasonInsufficientFunds    = "insufficient_funds"
+	ReasonIntrinsicGas         = "intrinsic_gas"
+	ReasonFeeCapTooLow         = "fee_cap_too_low"
+	ReasonTipAboveFeeCap       = "tip_above_fee_cap"
+	ReasonTxTypeNotSupported   = "tx_type_not_supported"
+	ReasonAddressDenied        = "address_denied"
+	ReasonSenderRateLimited    = "sender_rate_limited"
+	ReasonContractRateLimited  = "contract_rate_limited"
+	ReasonNonceGapLimit        = "nonce_gap_limit"
+	ReasonOther                = "other"
 )
+
+// reasonCodes maps the known pool and consensus errors to their reason codes.
+var reasonCodes = []struct {
+	err  error
+	code string
+}{
+	{ErrAlreadyKnown, ReasonAlreadyKnown},
+	{ErrInvalidSender, ReasonInvalidSender},
+	{ErrUnderpriced, ReasonUnderpriced},
+	{ErrReplaceUnderpriced, ReasonReplaceUnderpriced},
+	{ErrAccountLimitExceeded, ReasonAccountLimit},
+	{ErrGasLimit, ReasonGasLimit},
+	{ErrNegativeValue, ReasonNegativeValue},
+	{ErrOversizedData, ReasonOversizedData},
+	{ErrFutureReplacePending, ReasonFutureReplacePending},
+	{ErrTxPoolOverflow, ReasonPoolOverflow},
+	{ErrTxExpired, ReasonExpired},
+	{ErrAddressDenied, ReasonAddressDenied},
+	{ErrSenderRateLimited, ReasonSenderRateLimited},
+	{ErrContractRateLimited, ReasonContractRateLimited},
+	{ErrNonceGapLimit, ReasonNonceGapLimit},
+	{core.ErrNonceTooLow, ReasonNonceTooLow},
+	{core.ErrNonceTooHigh, ReasonNonceTooHigh},
+	{core.ErrInsufficientFunds, ReasonInsufficientFunds},
+	{core.ErrIntrinsicGas, ReasonIntrinsicGas},
+	{core.ErrFeeCapTooLow, ReasonFeeCapTooLow},
+	{core.ErrTipAboveFeeCap, ReasonTipAboveFeeCap},
+	{core.ErrTxTypeNotSupported, ReasonTxTypeNotSupported},
+}
+
+// ReasonCode converts a pool rejection or eviction error into a stable reason
+// code. Unknown errors are reported as ReasonOther and nil as an empty string.
+func ReasonCode(err error) string {
+	if err == nil {
+		return ""
+	}
+	for _, reason := range reasonCodes {
+		if errors.Is(err, reason.err) {
+			return reason.code
+		}
+	}
+	return ReasonOther
+}
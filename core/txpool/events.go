@@ -0,0 +1,202 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package txpool
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// txEventQueueSize is the number of lifecycle event batches buffered for each
+// subscriber before it's considered too slow and disconnected.
+const txEventQueueSize = 256
+
+// ErrTxEventSubscriberTooSlow is delivered on the error channel of a lifecycle
+// event subscription that was disconnected for not keeping up with the pool.
+var ErrTxEventSubscriberTooSlow = errors.New("subscriber too slow")
+
+// laggingEventSubMeter counts the lifecycle event subscribers disconnected for
+// not keeping up with the pool.
+var laggingEventSubMeter = metrics.NewRegisteredMeter("txpool/events/lagging", nil)
+
+// TxEventKind is the type of a transaction lifecycle event in the pool.
+type TxEventKind uint8
+
+const (
+	TxEventAdmitted TxEventKind = iota // Transaction accepted into the pool
+	TxEventReplaced                    // Transaction superseded by a higher priced one
+	TxEventDropped                     // Transaction evicted from the pool
+	TxEventRejected                    // Transaction refused admission into the pool
+)
+
+// String implements fmt.Stringer.
+func (kind TxEventKind) String() string {
+	switch kind {
+	case TxEventAdmitted:
+		return "admitted"
+	case TxEventReplaced:
+		return "replaced"
+	case TxEventDropped:
+		return "dropped"
+	case TxEventRejected:
+		return "rejected"
+	default:
+		return "unknown"
+	}
+}
+
+// TxEvent is a notification about a single transaction entering, being refused
+// from or leaving the pool for any reason other than block inclusion.
+type TxEvent struct {
+	Kind   TxEventKind
+	Tx     *types.Transaction
+	Reason error // Cause of a rejection or drop, nil otherwise
+
+	// Replacement is the hash of the transaction superseding this one, only set
+	// for TxEventReplaced.
+	Replacement common.Hash
+}
+
+// TxEventReporter is implemented by subpools that report the lifecycle events
+// of their transactions themselves, admissions included, so that replacements
+// and drops are never delivered ahead of them. The main pool reports all the
+// rejections and the admissions of the other subpools.
+type TxEventReporter interface {
+	// SetTxEventFeed sets the feed to report the lifecycle events to. Events
+	// have to be sent in the order they happen.
+	SetTxEventFeed(feed *TxEventFeed)
+}
+
+// TxEventFeed delivers transaction lifecycle events to subscribers. Every
+// subscriber has its own bounded queue, drained in order by a dedicated
+// goroutine, so sending never blocks the pool. Subscribers falling too far
+// behind are disconnected with ErrTxEventSubscriberTooSlow rather than silently
+// missing events.
+//
+// The zero value is ready to use.
+type TxEventFeed struct {
+	subs map[*txEventSub]struct{}
+	lock sync.Mutex
+}
+
+// txEventSub is a subscription to a TxEventFeed.
+type txEventSub struct {
+	feed  *TxEventFeed
+	ch    chan<- []*TxEvent
+	queue chan []*TxEvent // Event batches waiting to be delivered
+	err   chan error
+	quit  chan struct{}
+	once  sync.Once
+}
+
+// Subscribe adds a channel to the feed. Events are delivered in batches, in the
+// order they were sent.
+func (f *TxEventFeed) Subscribe(ch chan<- []*TxEvent) event.Subscription {
+	sub := &txEventSub{
+		feed:  f,
+		ch:    ch,
+		queue: make(chan []*TxEvent, txEventQueueSize),
+		err:   make(chan error, 1),
+		quit:  make(chan struct{}),
+	}
+	f.lock.Lock()
+	if f.subs == nil {
+		f.subs = make(map[*txEventSub]struct{})
+	}
+	f.subs[sub] = struct{}{}
+	f.lock.Unlock()
+
+	go sub.loop()
+	return sub
+}
+
+// Count returns the number of active subscriptions.
+func (f *TxEventFeed) Count() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return len(f.subs)
+}
+
+// Send queues a batch of events for delivery to all subscribers, disconnecting
+// the ones whose queue is full. It never blocks.
+func (f *TxEventFeed) Send(events []*TxEvent) {
+	if len(events) == 0 {
+		return
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for sub := range f.subs {
+		select {
+		case sub.queue <- events:
+		default:
+			delete(f.subs, sub)
+			sub.close(ErrTxEventSubscriberTooSlow)
+
+			laggingEventSubMeter.Mark(1)
+			log.Debug("Disconnected lagging transaction event subscriber")
+		}
+	}
+}
+
+// loop delivers the queued event batches to the subscribed channel.
+func (sub *txEventSub) loop() {
+	for {
+		select {
+		case events := <-sub.queue:
+			select {
+			case sub.ch <- events:
+			case <-sub.quit:
+				return
+			}
+		case <-sub.quit:
+			return
+		}
+	}
+}
+
+// Unsubscribe implements event.Subscription.
+func (sub *txEventSub) Unsubscribe() {
+	sub.feed.lock.Lock()
+	delete(sub.feed.subs, sub)
+	sub.feed.lock.Unlock()
+
+	sub.close(nil)
+}
+
+// Err implements event.Subscription.
+func (sub *txEventSub) Err() <-chan error {
+	return sub.err
+}
+
+// close terminates the delivery, reporting the given error if any.
+func (sub *txEventSub) close(err error) {
+	sub.once.Do(func() {
+		close(sub.quit)
+		if err != nil {
+			sub.err <- err
+		}
+		close(sub.err)
+	})
+}
@@ -0,0 +1,99 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package txpool
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the admissions and rejections are reported to the lifecycle event
+// subscribers in order, and that a blocked subscriber neither holds up the pool
+// nor the other subscribers, but gets disconnected.
+func TestTxEventsAdds(t *testing.T) {
+	var (
+		signer = types.LatestSigner(params.TestChainConfig)
+		key, _ = crypto.GenerateKey()
+		to     = common.Address{0x01}
+		pool   = &TxPool{subpools: []SubPool{new(rejectingSubPool)}}
+	)
+	defer pool.subs.Close()
+
+	blocked := make(chan []*TxEvent) // Never read
+	blockedSub := pool.SubscribeTxEvents(blocked)
+	defer blockedSub.Unsubscribe()
+
+	events := make(chan []*TxEvent)
+	sub := pool.SubscribeTxEvents(events)
+	defer sub.Unsubscribe()
+
+	tx := func(nonce uint64) *types.Transaction {
+		return types.MustSignNewTx(key, signer, &types.LegacyTx{Nonce: nonce, To: &to, Gas: 21000, GasPrice: big.NewInt(1)})
+	}
+	var txs []*types.Transaction
+	for i := 0; i < 2*txEventQueueSize; i++ {
+		txs = append(txs, tx(uint64(i)))
+	}
+	// Add the transactions one by one, checking the events of the live
+	// subscriber after each: they are delivered in order with the reasons
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i, tx := range txs {
+			pool.Add([]*types.Transaction{tx}, false, false)
+
+			var batch []*TxEvent
+			select {
+			case batch = <-events:
+			case <-time.After(5 * time.Second):
+				t.Errorf("event %d not delivered", i)
+				return
+			}
+			if len(batch) != 1 || batch[0].Tx.Hash() != tx.Hash() {
+				t.Errorf("event %d mismatch: have %v, want %x", i, batch, tx.Hash())
+				return
+			}
+			if i%2 == 0 && (batch[0].Kind != TxEventAdmitted || batch[0].Reason != nil) {
+				t.Errorf("event %d: admission mismatch: have %v %v, want %v", i, batch[0].Kind, batch[0].Reason, TxEventAdmitted)
+			}
+			if i%2 == 1 && (batch[0].Kind != TxEventRejected || !errors.Is(batch[0].Reason, ErrUnderpriced)) {
+				t.Errorf("event %d: rejection mismatch: have %v %v, want %v %v", i, batch[0].Kind, batch[0].Reason, TxEventRejected, ErrUnderpriced)
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("additions blocked by the event subscriber")
+	}
+	// The blocked subscriber was disconnected
+	select {
+	case err := <-blockedSub.Err():
+		if !errors.Is(err, ErrTxEventSubscriberTooSlow) {
+			t.Fatalf("disconnection error mismatch: have %v, want %v", err, ErrTxEventSubscriberTooSlow)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("blocked subscriber not disconnected")
+	}
+}
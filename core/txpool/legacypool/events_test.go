@@ -0,0 +1,104 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package legacypool
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/txpool"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Tests that admissions, replacements and evictions happening within the pool
+// are reported to lifecycle event subscribers in order, along with the reason
+// of the drop, and that a blocked subscriber doesn't hold up the pool.
+func TestTxEvents(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupPool()
+	defer pool.Close()
+
+	feed := new(txpool.TxEventFeed)
+	pool.SetTxEventFeed(feed)
+
+	blocked := feed.Subscribe(make(chan []*txpool.TxEvent)) // Never read
+	defer blocked.Unsubscribe()
+
+	events := make(chan []*txpool.TxEvent, 16)
+	sub := feed.Subscribe(events)
+	defer sub.Unsubscribe()
+
+	testAddBalance(pool, crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+
+	// Replace a pending transaction and ensure the replacement is reported
+	// between the admissions
+	original := pricedTransaction(0, 100000, big.NewInt(1), key)
+	if err := pool.addRemoteSync(original); err != nil {
+		t.Fatalf("failed to add original transaction: %v", err)
+	}
+	replacement := pricedTransaction(0, 100000, big.NewInt(2), key)
+	if err := pool.addRemoteSync(replacement); err != nil {
+		t.Fatalf("failed to add replacement transaction: %v", err)
+	}
+	evs := waitTxEvents(t, events, 3)
+	if evs[0].Kind != txpool.TxEventAdmitted || evs[0].Tx.Hash() != original.Hash() {
+		t.Fatalf("admission event mismatch: have %v %x, want %v %x", evs[0].Kind, evs[0].Tx.Hash(), txpool.TxEventAdmitted, original.Hash())
+	}
+	if evs[1].Kind != txpool.TxEventReplaced || evs[1].Tx.Hash() != original.Hash() || evs[1].Replacement != replacement.Hash() {
+		t.Fatalf("replacement event mismatch: have %v %x -> %x, want %v %x -> %x", evs[1].Kind, evs[1].Tx.Hash(), evs[1].Replacement, txpool.TxEventReplaced, original.Hash(), replacement.Hash())
+	}
+	if evs[2].Kind != txpool.TxEventAdmitted || evs[2].Tx.Hash() != replacement.Hash() {
+		t.Fatalf("admission event mismatch: have %v %x, want %v %x", evs[2].Kind, evs[2].Tx.Hash(), txpool.TxEventAdmitted, replacement.Hash())
+	}
+	// Raise the minimum tip and ensure the underpriced transaction is dropped
+	pool.SetGasTip(big.NewInt(3))
+
+	ev := waitTxEvents(t, events, 1)[0]
+	if ev.Kind != txpool.TxEventDropped || ev.Tx.Hash() != replacement.Hash() {
+		t.Fatalf("drop event mismatch: have %v %x, want %v %x", ev.Kind, ev.Tx.Hash(), txpool.TxEventDropped, replacement.Hash())
+	}
+	if !errors.Is(ev.Reason, txpool.ErrUnderpriced) {
+		t.Fatalf("drop reason mismatch: have %v, want %v", ev.Reason, txpool.ErrUnderpriced)
+	}
+	if code := txpool.ReasonCode(ev.Reason); code != txpool.ReasonUnderpriced {
+		t.Fatalf("drop reason code mismatch: have %s, want %s", code, txpool.ReasonUnderpriced)
+	}
+}
+
+// waitTxEvents retrieves the given number of lifecycle events from a
+// subscription, failing the test if they don't arrive in a reasonable amount
+// of time.
+func waitTxEvents(t *testing.T, events chan []*txpool.TxEvent, n int) []*txpool.TxEvent {
+	t.Helper()
+
+	var evs []*txpool.TxEvent
+	for len(evs) < n {
+		select {
+		case batch := <-events:
+			evs = append(evs, batch...)
+		case <-time.After(time.Second):
+			t.Fatalf("lifecycle event timeout")
+		}
+	}
+	if len(evs) != n {
+		t.Fatalf("event count mismatch: have %d, want %d", len(evs), n)
+	}
+	return evs
+}
@@ -18,7 +18,6 @@
 package legacypool
 
 import (
-	"errors"
 	"math"
 	"math/big"
 	"sort"
@@ -56,11 +55,11 @@ const (
 var (
 	// ErrAlreadyKnown is returned if the transactions is already contained
 	// within the pool.
-	ErrAlreadyKnown = errors.New("already known")
+	ErrAlreadyKnown = txpool.ErrAlreadyKnown
 
 	// ErrTxPoolOverflow is returned if the transaction pool is full and can't accept
 	// another remote transaction.
-	ErrTxPoolOverflow = errors.New("txpool is full")
+	ErrTxPoolOverflow = txpool.ErrTxPoolOverflow
 )
 
 var (
@@ -129,6 +128,10 @@ type Config struct {
 	Journal   string           // Journal of local transactions to survive node restarts
 	Rejournal time.Duration    // Time interval to regenerate the local transaction journal
 
+	Snapshot       string        // Snapshot of all remote transactions to survive node restarts
+	SnapshotMaxAge time.Duration // Maximum age of a snapshot to still be replayed on startup
+	SnapshotLimit  uint64        // Maximum number of transactions to snapshot and replay
+
 	PriceLimit uint64 // Minimum gas price to enforce for acceptance into the pool
 	PriceBump  uint64 // Minimum price bump percentage to replace an already existing transaction (nonce)
 
@@ -145,6 +148,8 @@ var DefaultConfig = Config{
 	Journal:   "transactions.rlp",
 	Rejournal: time.Hour,
 
+	SnapshotMaxAge: 30 * time.Minute,
+
 	PriceLimit: 1,
 	PriceBump:  10,
 
@@ -192,6 +197,9 @@ func (config *Config) sanitize() Config {
 		log.Warn("Sanitizing invalid txpool lifetime", "provided", conf.Lifetime, "updated", DefaultConfig.Lifetime)
 		conf.Lifetime = DefaultConfig.Lifetime
 	}
+	if conf.SnapshotLimit < 1 {
+		conf.SnapshotLimit = conf.GlobalSlots + conf.GlobalQueue
+	}
 	return conf
 }
 
@@ -208,6 +216,7 @@ type LegacyPool struct {
 	chain       BlockChain
 	gasTip      atomic.Pointer[big.Int]
 	txFeed      event.Feed
+	eventFeed   *txpool.TxEventFeed // Lifecycle event feed of the main pool, nil if unset
 	signer      types.Signer
 	mu          sync.RWMutex
 
@@ -218,6 +227,8 @@ type LegacyPool struct {
 	locals  *accountSet // Set of local transaction to exempt from eviction rules
 	journal *journal    // Journal of local transaction to back up to disk
 
+	snapshot *poolSnapshot // Snapshot of remote transactions to dump on shutdown
+
 	reserve txpool.AddressReserver       // Address reserver to ensure exclusivity across subpools
 	pending map[common.Address]*list     // All currently processable transactions
 	queue   map[common.Address]*list     // Queued but non-processable transactions
@@ -234,6 +245,8 @@ type LegacyPool struct {
 	initDoneCh      chan struct{}  // is closed once the pool is initialized (for tests)
 
 	changesSinceReorg int // A counter for how many drops we've performed in-between reorg.
+
+	txEvents []*txpool.TxEvent // Lifecycle events accumulated under the pool lock, fed before releasing it
 }
 
 type txpoolResetRequest struct {
@@ -273,6 +286,9 @@ func New(config Config, chain BlockChain) *LegacyPool {
 	if !config.NoLocals && config.Journal != "" {
 		pool.journal = newTxJournal(config.Journal)
 	}
+	if config.Snapshot != "" {
+		pool.snapshot = newPoolSnapshot(config.Snapshot, config.SnapshotMaxAge, config.SnapshotLimit)
+	}
 	return pool
 }
 
@@ -326,6 +342,12 @@ func (pool *LegacyPool) Init(gasTip *big.Int, head *types.Header, reserve txpool
 			log.Warn("Failed to rotate transaction journal", "err", err)
 		}
 	}
+	// If full pool snapshotting is enabled, replay the remotes from the last run
+	if pool.snapshot != nil {
+		if err := pool.snapshot.load(pool.addRemotesSync); err != nil {
+			log.Warn("Failed to load transaction pool snapshot", "err", err)
+		}
+	}
 	pool.wg.Add(1)
 	go pool.loop()
 	return nil
@@ -383,9 +405,11 @@ func (pool *LegacyPool) loop() {
 					for _, tx := range list {
 						pool.removeTx(tx.Hash(), true, true)
 					}
+					pool.reportDrops(list, txpool.ErrTxExpired)
 					queuedEvictionMeter.Mark(int64(len(list)))
 				}
 			}
+			pool.flushTxEvents()
 			pool.mu.Unlock()
 
 		// Handle local transaction journal rotation
@@ -410,6 +434,16 @@ func (pool *LegacyPool) Close() error {
 	if pool.journal != nil {
 		pool.journal.close()
 	}
+	if pool.snapshot != nil {
+		pool.mu.RLock()
+		pending, queued := pool.remotes()
+		head := pool.currentHead.Load().Hash()
+		pool.mu.RUnlock()
+
+		if err := pool.snapshot.write(head, pending, queued); err != nil {
+			log.Warn("Failed to write transaction pool snapshot", "err", err)
+		}
+	}
 	log.Info("Transaction pool stopped")
 	return nil
 }
@@ -431,12 +465,72 @@ func (pool *LegacyPool) SubscribeTransactions(ch chan<- core.NewTxsEvent, reorgs
 	return pool.txFeed.Subscribe(ch)
 }
 
+// SetTxEventFeed implements txpool.TxEventReporter, reporting the admissions,
+// replacements and evictions of transactions tracked by the legacy pool.
+func (pool *LegacyPool) SetTxEventFeed(feed *txpool.TxEventFeed) {
+	pool.eventFeed = feed
+}
+
+// reportTx records a lifecycle event of a transaction to be fed to subscribers
+// before the pool lock is released.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *LegacyPool) reportTx(kind txpool.TxEventKind, tx *types.Transaction, reason error, replacement common.Hash) {
+	if pool.eventFeed == nil || pool.eventFeed.Count() == 0 {
+		return
+	}
+	pool.txEvents = append(pool.txEvents, &txpool.TxEvent{
+		Kind:        kind,
+		Tx:          tx,
+		Reason:      reason,
+		Replacement: replacement,
+	})
+}
+
+// reportDrops records the eviction of a batch of transactions for the same
+// reason.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *LegacyPool) reportDrops(txs []*types.Transaction, reason error) {
+	for _, tx := range txs {
+		pool.reportTx(txpool.TxEventDropped, tx, reason, common.Hash{})
+	}
+}
+
+// reportUnpayable records the eviction of a batch of transactions filtered out
+// for exceeding either the balance of their sender or the block gas limit.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *LegacyPool) reportUnpayable(txs []*types.Transaction, gasLimit uint64) {
+	for _, tx := range txs {
+		reason := core.ErrInsufficientFunds
+		if tx.Gas() > gasLimit {
+			reason = txpool.ErrGasLimit
+		}
+		pool.reportTx(txpool.TxEventDropped, tx, reason, common.Hash{})
+	}
+}
+
+// flushTxEvents feeds the lifecycle events accumulated so far to subscribers.
+// Feeding never blocks, so it's done under the pool lock to keep the events of
+// concurrent operations in order.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *LegacyPool) flushTxEvents() {
+	if len(pool.txEvents) > 0 {
+		pool.eventFeed.Send(pool.txEvents)
+		pool.txEvents = nil
+	}
+}
+
 // SetGasTip updates the minimum gas tip required by the transaction pool for a
 // new transaction, and drops all transactions below this threshold.
 func (pool *LegacyPool) SetGasTip(tip *big.Int) {
 	pool.mu.Lock()
-	defer pool.mu.Unlock()
-
+	defer func() {
+		pool.flushTxEvents()
+		pool.mu.Unlock()
+	}()
 	old := pool.gasTip.Load()
 	pool.gasTip.Store(new(big.Int).Set(tip))
 
@@ -447,6 +541,7 @@ func (pool *LegacyPool) SetGasTip(tip *big.Int) {
 		for _, tx := range drop {
 			pool.removeTx(tx.Hash(), false, true)
 		}
+		pool.reportDrops(drop, txpool.ErrUnderpriced)
 		pool.priced.Removed(len(drop))
 	}
 	log.Info("Legacy pool tip threshold updated", "tip", tip)
@@ -586,6 +681,26 @@ func (pool *LegacyPool) local() map[common.Address]types.Transactions {
 	return txs
 }
 
+// remotes retrieves all currently known remote transactions, grouped by origin
+// account and sorted by nonce, split into executable and non-executable sets.
+// The returned transaction sets are copies and can be freely modified by calling
+// code.
+func (pool *LegacyPool) remotes() (map[common.Address]types.Transactions, map[common.Address]types.Transactions) {
+	pending := make(map[common.Address]types.Transactions)
+	for addr, list := range pool.pending {
+		if !pool.locals.contains(addr) {
+			pending[addr] = list.Flatten()
+		}
+	}
+	queued := make(map[common.Address]types.Transactions)
+	for addr, list := range pool.queue {
+		if !pool.locals.contains(addr) {
+			queued[addr] = list.Flatten()
+		}
+	}
+	return pending, queued
+}
+
 // validateTxBasics checks whether a transaction is valid according to the consensus
 // rules, but does not check state-dependent validation such as sufficient balance.
 // This check is meant as an early check which only needs to be performed once,
@@ -757,6 +872,7 @@ func (pool *LegacyPool) add(tx *types.Transaction, local bool) (replaced bool, e
 
 			pool.changesSinceReorg += dropped
 		}
+		pool.reportDrops(drop, txpool.ErrUnderpriced)
 	}
 
 	// Try to replace an existing transaction in the pending pool
@@ -772,6 +888,7 @@ func (pool *LegacyPool) add(tx *types.Transaction, local bool) (replaced bool, e
 			pool.all.Remove(old.Hash())
 			pool.priced.Removed(1)
 			pendingReplaceMeter.Mark(1)
+			pool.reportTx(txpool.TxEventReplaced, old, nil, hash)
 		}
 		pool.all.Add(tx, isLocal)
 		pool.priced.Put(tx, isLocal)
@@ -847,6 +964,7 @@ func (pool *LegacyPool) enqueueTx(hash common.Hash, tx *types.Transaction, local
 		pool.all.Remove(old.Hash())
 		pool.priced.Removed(1)
 		queuedReplaceMeter.Mark(1)
+		pool.reportTx(txpool.TxEventReplaced, old, nil, hash)
 	} else {
 		// Nothing was replaced, bump the queued counter
 		queuedGauge.Inc(1)
@@ -947,7 +1065,8 @@ func (pool *LegacyPool) addRemote(tx *types.Transaction) error {
 	return errs[0]
 }
 
-// addRemotesSync is like addRemotes, but waits for pool reorganization. Tests use this method.
+// addRemotesSync is like addRemotes, but waits for pool reorganization. Tests and
+// the snapshot replay use this method.
 func (pool *LegacyPool) addRemotesSync(txs []*types.Transaction) []error {
 	return pool.Add(txs, false, true)
 }
@@ -993,6 +1112,7 @@ func (pool *LegacyPool) Add(txs []*types.Transaction, local, sync bool) []error
 	// Process all the new transaction and merge any errors into the original slice
 	pool.mu.Lock()
 	newErrs, dirtyAddrs := pool.addTxsLocked(news, local)
+	pool.flushTxEvents()
 	pool.mu.Unlock()
 
 	var nilSlot = 0
@@ -1019,6 +1139,9 @@ func (pool *LegacyPool) addTxsLocked(txs []*types.Transaction, local bool) ([]er
 	for i, tx := range txs {
 		replaced, err := pool.add(tx, local)
 		errs[i] = err
+		if err == nil {
+			pool.reportTx(txpool.TxEventAdmitted, tx, nil, common.Hash{})
+		}
 		if err == nil && !replaced {
 			dirty.addTx(tx)
 		}
@@ -1303,6 +1426,7 @@ func (pool *LegacyPool) runReorg(done chan struct{}, reset *txpoolResetRequest,
 
 	dropBetweenReorgHistogram.Update(int64(pool.changesSinceReorg))
 	pool.changesSinceReorg = 0 // Reset change counter
+	pool.flushTxEvents()
 	pool.mu.Unlock()
 
 	// Notify subsystems for newly added transactions
@@ -1450,6 +1574,7 @@ func (pool *LegacyPool) promoteExecutables(accounts []common.Address) []*types.T
 		}
 		log.Trace("Removed unpayable queued transactions", "count", len(drops))
 		queuedNofundsMeter.Mark(int64(len(drops)))
+		pool.reportUnpayable(drops, gasLimit)
 
 		// Gather all executable transactions and promote them
 		readies := list.Ready(pool.pendingNonces.get(addr))
@@ -1472,6 +1597,7 @@ func (pool *LegacyPool) promoteExecutables(accounts []common.Address) []*types.T
 				log.Trace("Removed cap-exceeding queued transaction", "hash", hash)
 			}
 			queuedRateLimitMeter.Mark(int64(len(caps)))
+			pool.reportDrops(caps, txpool.ErrAccountLimitExceeded)
 		}
 		// Mark all the items dropped as removed
 		pool.priced.Removed(len(forwards) + len(drops) + len(caps))
@@ -1539,6 +1665,7 @@ func (pool *LegacyPool) truncatePending() {
 						pool.pendingNonces.setIfLower(offenders[i], tx.Nonce())
 						log.Trace("Removed fairness-exceeding pending transaction", "hash", hash)
 					}
+					pool.reportDrops(caps, ErrTxPoolOverflow)
 					pool.priced.Removed(len(caps))
 					pendingGauge.Dec(int64(len(caps)))
 					if pool.locals.contains(offenders[i]) {
@@ -1566,6 +1693,7 @@ func (pool *LegacyPool) truncatePending() {
 					pool.pendingNonces.setIfLower(addr, tx.Nonce())
 					log.Trace("Removed fairness-exceeding pending transaction", "hash", hash)
 				}
+				pool.reportDrops(caps, ErrTxPoolOverflow)
 				pool.priced.Removed(len(caps))
 				pendingGauge.Dec(int64(len(caps)))
 				if pool.locals.contains(addr) {
@@ -1608,6 +1736,7 @@ func (pool *LegacyPool) truncateQueue() {
 		if size := uint64(list.Len()); size <= drop {
 			for _, tx := range list.Flatten() {
 				pool.removeTx(tx.Hash(), true, true)
+				pool.reportTx(txpool.TxEventDropped, tx, ErrTxPoolOverflow, common.Hash{})
 			}
 			drop -= size
 			queuedRateLimitMeter.Mark(int64(size))
@@ -1617,6 +1746,7 @@ func (pool *LegacyPool) truncateQueue() {
 		txs := list.Flatten()
 		for i := len(txs) - 1; i >= 0 && drop > 0; i-- {
 			pool.removeTx(txs[i].Hash(), true, true)
+			pool.reportTx(txpool.TxEventDropped, txs[i], ErrTxPoolOverflow, common.Hash{})
 			drop--
 			queuedRateLimitMeter.Mark(1)
 		}
@@ -1651,6 +1781,7 @@ func (pool *LegacyPool) demoteUnexecutables() {
 			pool.all.Remove(hash)
 		}
 		pendingNofundsMeter.Mark(int64(len(drops)))
+		pool.reportUnpayable(drops, gasLimit)
 
 		for _, tx := range invalids {
 			hash := tx.Hash()
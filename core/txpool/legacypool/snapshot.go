@@ -0,0 +1,191 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package legacypool
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// snapshotVersion is the current version of the pool snapshot file format. It
+// needs to be bumped whenever the layout of the header or the payload changes.
+const snapshotVersion = 1
+
+// errSnapshotVersion is returned if a pool snapshot was written by a client
+// using an incompatible file format.
+var errSnapshotVersion = errors.New("unsupported pool snapshot version")
+
+// snapshotHeader is the first item of a pool snapshot file, followed by the
+// RLP stream of the dumped transactions.
+type snapshotHeader struct {
+	Version uint64      // Version of the snapshot file format
+	Created uint64      // Unix timestamp of when the snapshot was written
+	Head    common.Hash // Chain head the transactions were valid against
+	Count   uint64      // Number of transactions following the header
+}
+
+// poolSnapshot is a one-shot dump of all the remote transactions tracked by the
+// pool, written on shutdown and replayed on the next startup. Contrary to the
+// journal it is not appended to during runtime, so it only protects against
+// clean restarts.
+type poolSnapshot struct {
+	path   string        // Filesystem path to store the transactions at
+	maxAge time.Duration // Maximum age of a snapshot to still be replayed
+	limit  uint64        // Maximum number of transactions to dump or replay
+}
+
+// newPoolSnapshot creates a new pool snapshot handler.
+func newPoolSnapshot(path string, maxAge time.Duration, limit uint64) *poolSnapshot {
+	return &poolSnapshot{
+		path:   path,
+		maxAge: maxAge,
+		limit:  limit,
+	}
+}
+
+// load parses a pool snapshot from disk, validating its header and injecting
+// its contents into the specified pool. The snapshot is deleted afterwards, as
+// any transaction still in the pool will be dumped again on the next shutdown.
+func (snap *poolSnapshot) load(add func([]*types.Transaction) []error) error {
+	input, err := os.Open(snap.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		// Skip the parsing if the snapshot file doesn't exist at all
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer func() {
+		input.Close()
+		if err := os.Remove(snap.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Warn("Failed to remove pool snapshot", "path", snap.path, "err", err)
+		}
+	}()
+	stream := rlp.NewStream(input, 0)
+
+	// Ensure the snapshot is something we can and want to use
+	var header snapshotHeader
+	if err := stream.Decode(&header); err != nil {
+		return fmt.Errorf("failed to decode pool snapshot header: %w", err)
+	}
+	if header.Version != snapshotVersion {
+		return fmt.Errorf("%w: have %d, want %d", errSnapshotVersion, header.Version, snapshotVersion)
+	}
+	created := time.Unix(int64(header.Created), 0)
+	if age := time.Since(created); snap.maxAge > 0 && age > snap.maxAge {
+		log.Info("Discarding stale pool snapshot", "transactions", header.Count, "age", common.PrettyAge(created), "maxage", snap.maxAge)
+		return nil
+	}
+	// Inject the transactions in small-ish batches, revalidating them against
+	// the current head of the pool.
+	var (
+		total, dropped int
+		failure        error
+		batch          types.Transactions
+	)
+	loadBatch := func(txs types.Transactions) {
+		for _, err := range add(txs) {
+			if err != nil {
+				log.Trace("Failed to add snapshotted transaction", "err", err)
+				dropped++
+			}
+		}
+	}
+	for snap.limit == 0 || uint64(total) < snap.limit {
+		tx := new(types.Transaction)
+		if err = stream.Decode(tx); err != nil {
+			if err != io.EOF {
+				failure = err
+			}
+			break
+		}
+		total++
+
+		if batch = append(batch, tx); batch.Len() > 1024 {
+			loadBatch(batch)
+			batch = batch[:0]
+		}
+	}
+	if batch.Len() > 0 {
+		loadBatch(batch)
+	}
+	log.Info("Loaded transaction pool snapshot", "transactions", total, "dropped", dropped, "head", header.Head, "age", common.PrettyAge(created))
+
+	return failure
+}
+
+// write dumps the given transactions into a new pool snapshot, replacing any
+// previous one. Executable transactions are written first, so that they are
+// the ones retained if the snapshot limit is reached. Within each set, accounts
+// are ordered by address to make the truncation deterministic.
+func (snap *poolSnapshot) write(head common.Hash, pending, queued map[common.Address]types.Transactions) error {
+	var txs types.Transactions
+	for _, set := range []map[common.Address]types.Transactions{pending, queued} {
+		addrs := make([]common.Address, 0, len(set))
+		for addr := range set {
+			addrs = append(addrs, addr)
+		}
+		sort.Slice(addrs, func(i, j int) bool {
+			return bytes.Compare(addrs[i][:], addrs[j][:]) < 0
+		})
+		for _, addr := range addrs {
+			txs = append(txs, set[addr]...)
+		}
+	}
+	if snap.limit > 0 && uint64(len(txs)) > snap.limit {
+		txs = txs[:snap.limit]
+	}
+	output, err := os.OpenFile(snap.path+".new", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	header := &snapshotHeader{
+		Version: snapshotVersion,
+		Created: uint64(time.Now().Unix()),
+		Head:    head,
+		Count:   uint64(len(txs)),
+	}
+	if err := rlp.Encode(output, header); err != nil {
+		output.Close()
+		return err
+	}
+	for _, tx := range txs {
+		if err := rlp.Encode(output, tx); err != nil {
+			output.Close()
+			return err
+		}
+	}
+	if err := output.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(snap.path+".new", snap.path); err != nil {
+		return err
+	}
+	log.Info("Written transaction pool snapshot", "transactions", len(txs), "head", head)
+	return nil
+}
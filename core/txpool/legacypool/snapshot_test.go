@@ -0,0 +1,181 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package legacypool
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Tests that remote transactions are dumped into the pool snapshot on shutdown
+// and replayed, revalidated against the new head, on the next startup.
+func TestSnapshotting(t *testing.T) {
+	t.Parallel()
+
+	statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := newTestBlockChain(params.TestChainConfig, 1000000, statedb, new(event.Feed))
+
+	config := testTxPoolConfig
+	config.Snapshot = filepath.Join(t.TempDir(), "snapshot.rlp")
+
+	pool := New(config, blockchain)
+	pool.Init(new(big.Int).SetUint64(config.PriceLimit), blockchain.CurrentBlock(), makeAddressReserver())
+
+	// Add a few executable and a non-executable remote transaction
+	key, _ := crypto.GenerateKey()
+	testAddBalance(pool, crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+
+	txs := []*types.Transaction{
+		transaction(0, 100000, key),
+		transaction(1, 100000, key),
+		transaction(2, 100000, key),
+		transaction(4, 100000, key),
+	}
+	for i, err := range pool.addRemotesSync(txs) {
+		if err != nil {
+			t.Fatalf("failed to add remote transaction %d: %v", i, err)
+		}
+	}
+	if pending, queued := pool.Stats(); pending != 3 || queued != 1 {
+		t.Fatalf("pool stats mismatch: have %d/%d, want %d/%d", pending, queued, 3, 1)
+	}
+	pool.Close()
+
+	// Bump the nonce of the account to invalidate the first transaction and
+	// ensure the rest survives the restart
+	statedb.SetNonce(crypto.PubkeyToAddress(key.PublicKey), 1)
+	blockchain = newTestBlockChain(params.TestChainConfig, 1000000, statedb, new(event.Feed))
+
+	pool = New(config, blockchain)
+	pool.Init(new(big.Int).SetUint64(config.PriceLimit), blockchain.CurrentBlock(), makeAddressReserver())
+	defer pool.Close()
+
+	if pending, queued := pool.Stats(); pending != 2 || queued != 1 {
+		t.Fatalf("pool stats mismatch: have %d/%d, want %d/%d", pending, queued, 2, 1)
+	}
+	if err := validatePoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+	if _, err := os.Stat(config.Snapshot); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("snapshot not removed after replay: %v", err)
+	}
+}
+
+// Tests that stale or incompatible pool snapshots are discarded.
+func TestSnapshotRejection(t *testing.T) {
+	t.Parallel()
+
+	key, _ := crypto.GenerateKey()
+	tests := []struct {
+		header snapshotHeader
+		added  int
+		err    error
+	}{
+		{header: snapshotHeader{Version: snapshotVersion, Created: uint64(time.Now().Unix())}, added: 1},
+		{header: snapshotHeader{Version: snapshotVersion, Created: uint64(time.Now().Add(-time.Hour).Unix())}, added: 0},
+		{header: snapshotHeader{Version: snapshotVersion + 1, Created: uint64(time.Now().Unix())}, added: 0, err: errSnapshotVersion},
+	}
+	for i, tt := range tests {
+		path := filepath.Join(t.TempDir(), "snapshot.rlp")
+
+		header, _ := rlp.EncodeToBytes(&tt.header)
+		tx, _ := rlp.EncodeToBytes(transaction(0, 100000, key))
+		if err := os.WriteFile(path, append(header, tx...), 0644); err != nil {
+			t.Fatalf("test %d: failed to write snapshot: %v", i, err)
+		}
+		var added int
+		err := newPoolSnapshot(path, 30*time.Minute, 0).load(func(txs []*types.Transaction) []error {
+			added += len(txs)
+			return make([]error, len(txs))
+		})
+		if !errors.Is(err, tt.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+		if added != tt.added {
+			t.Errorf("test %d: added transaction mismatch: have %d, want %d", i, added, tt.added)
+		}
+	}
+}
+
+// Tests that the transactions beyond the snapshot limit are dropped in address
+// order, executable ones being retained first.
+func TestSnapshotTruncation(t *testing.T) {
+	t.Parallel()
+
+	var (
+		keys    = make([]*ecdsa.PrivateKey, 4)
+		pending = make(map[common.Address]types.Transactions)
+		queued  = make(map[common.Address]types.Transactions)
+		addrs   []common.Address
+	)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		addr := crypto.PubkeyToAddress(keys[i].PublicKey)
+		pending[addr] = types.Transactions{transaction(0, 100000, keys[i]), transaction(1, 100000, keys[i])}
+		queued[addr] = types.Transactions{transaction(3, 100000, keys[i])}
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return bytes.Compare(addrs[i][:], addrs[j][:]) < 0 })
+
+	// The first pending accounts in address order fill up the limit, the last
+	// one being cut off in the middle
+	var want []common.Hash
+	for _, addr := range addrs {
+		for _, tx := range pending[addr] {
+			want = append(want, tx.Hash())
+		}
+	}
+	want = want[:5]
+
+	path := filepath.Join(t.TempDir(), "snapshot.rlp")
+	for i := 0; i < 4; i++ {
+		snap := newPoolSnapshot(path, 30*time.Minute, 5)
+		if err := snap.write(common.Hash{}, pending, queued); err != nil {
+			t.Fatalf("run %d: failed to write snapshot: %v", i, err)
+		}
+		var have []common.Hash
+		err := snap.load(func(txs []*types.Transaction) []error {
+			for _, tx := range txs {
+				have = append(have, tx.Hash())
+			}
+			return make([]error, len(txs))
+		})
+		if err != nil {
+			t.Fatalf("run %d: failed to load snapshot: %v", i, err)
+		}
+		if !reflect.DeepEqual(have, want) {
+			t.Fatalf("run %d: snapshotted transactions mismatch: have %x, want %x", i, have, want)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package txpool
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// maxPolicyBuckets is the number of token buckets tracked by the admission policy
+// after which idle (fully refilled) ones are garbage collected.
+const maxPolicyBuckets = 16384
+
+var (
+	deniedMeter              = metrics.NewRegisteredMeter("txpool/policy/denied", nil)
+	senderRateLimitedMeter   = metrics.NewRegisteredMeter("txpool/policy/sender", nil)
+	contractRateLimitedMeter = metrics.NewRegisteredMeter("txpool/policy/contract", nil)
+	nonceGapLimitedMeter     = metrics.NewRegisteredMeter("txpool/policy/gapped", nil)
+)
+
+// AdmissionConfig are the configuration parameters of the admission policy that
+// the transaction pool enforces on top of the limits of the individual subpools.
+// All the limits are disabled when set to zero.
+type AdmissionConfig struct {
+	SenderRate    float64 // Transactions per second admitted from a single sender
+	SenderBurst   uint64  // Transactions admitted from a single sender in a burst
+	ContractRate  float64 // Transactions per second admitted towards a single destination
+	ContractBurst uint64  // Transactions admitted towards a single destination in a burst
+	GapSlots      uint64  // Maximum number of nonce-gapped transactions per sender
+	Lists         string  // JSON file with the allowed and denied addresses (hot-reloadable)
+}
+
+// AdmissionLists are the addresses explicitly trusted or banned by the pool.
+// Allowed senders are exempt from all rate and gap limits, whereas transactions
+// from or to a denied address are rejected outright.
+type AdmissionLists struct {
+	Allow []common.Address `json:"allow"`
+	Deny  []common.Address `json:"deny"`
+}
+
+// tokenBucket is a classic token bucket rate limiter, refilled lazily whenever
+// a token is requested.
+type tokenBucket struct {
+	tokens float64
+	last   mclock.AbsTime
+}
+
+// take refills the bucket based on the time elapsed since the last request and
+// attempts to consume a single token from it.
+func (b *tokenBucket) take(now mclock.AbsTime, rate float64, burst float64) bool {
+	b.refill(now, rate, burst)
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// put returns a token to the bucket, without exceeding its capacity.
+func (b *tokenBucket) put(burst float64) {
+	if b.tokens++; b.tokens > burst {
+		b.tokens = burst
+	}
+}
+
+// refill tops up the bucket based on the time elapsed since the last request.
+func (b *tokenBucket) refill(now mclock.AbsTime, rate float64, burst float64) {
+	b.tokens += rate * float64(now-b.last) / float64(mclock.AbsTime(1e9))
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+}
+
+// AdmissionPolicy enforces per-sender and per-destination rate limits, a cap on
+// nonce-gapped transactions and an allow/deny list on transactions entering the
+// pool, to prevent a single actor from hogging the pool with cheap spam.
+type AdmissionPolicy struct {
+	config AdmissionConfig
+	signer types.Signer
+	clock  mclock.Clock
+
+	allow map[common.Address]struct{}
+	deny  map[common.Address]struct{}
+
+	senders   map[common.Address]*tokenBucket
+	contracts map[common.Address]*tokenBucket
+
+	lock sync.Mutex
+}
+
+// NewAdmissionPolicy creates a new admission policy, loading the allow and deny
+// lists from disk if configured.
+func NewAdmissionPolicy(config AdmissionConfig, signer types.Signer) (*AdmissionPolicy, error) {
+	policy := &AdmissionPolicy{
+		config:    config,
+		signer:    signer,
+		clock:     mclock.System{},
+		allow:     make(map[common.Address]struct{}),
+		deny:      make(map[common.Address]struct{}),
+		senders:   make(map[common.Address]*tokenBucket),
+		contracts: make(map[common.Address]*tokenBucket),
+	}
+	if config.SenderRate > 0 && config.SenderBurst == 0 {
+		policy.config.SenderBurst = 1
+	}
+	if config.ContractRate > 0 && config.ContractBurst == 0 {
+		policy.config.ContractBurst = 1
+	}
+	if config.Lists != "" {
+		if err := policy.Reload(); err != nil {
+			return nil, err
+		}
+	}
+	return policy, nil
+}
+
+// Reload re-reads the allow and deny lists from the configured file, replacing
+// the currently active ones.
+func (p *AdmissionPolicy) Reload() error {
+	if p.config.Lists == "" {
+		return errors.New("no admission list file configured")
+	}
+	blob, err := os.ReadFile(p.config.Lists)
+	if err != nil {
+		return err
+	}
+	var lists AdmissionLists
+	if err := json.Unmarshal(blob, &lists); err != nil {
+		return fmt.Errorf("invalid admission list file %s: %w", p.config.Lists, err)
+	}
+	p.SetLists(lists)
+	log.Info("Loaded transaction pool admission lists", "allowed", len(lists.Allow), "denied", len(lists.Deny))
+	return nil
+}
+
+// SetLists replaces the currently active allow and deny lists.
+func (p *AdmissionPolicy) SetLists(lists AdmissionLists) {
+	allow := make(map[common.Address]struct{}, len(lists.Allow))
+	for _, addr := range lists.Allow {
+		allow[addr] = struct{}{}
+	}
+	deny := make(map[common.Address]struct{}, len(lists.Deny))
+	for _, addr := range lists.Deny {
+		deny[addr] = struct{}{}
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.allow, p.deny = allow, deny
+}
+
+// Lists returns the currently active allow and deny lists.
+func (p *AdmissionPolicy) Lists() AdmissionLists {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	lists := AdmissionLists{
+		Allow: make([]common.Address, 0, len(p.allow)),
+		Deny:  make([]common.Address, 0, len(p.deny)),
+	}
+	for addr := range p.allow {
+		lists.Allow = append(lists.Allow, addr)
+	}
+	for addr := range p.deny {
+		lists.Deny = append(lists.Deny, addr)
+	}
+	return lists
+}
+
+// admit checks whether a transaction is acceptable by the policy, consuming a
+// token from the rate limiters of its sender and destination if so. The tokens
+// are given back with refund if the subpools reject the transaction. The gapped
+// parameter is the number of nonce-gapped transactions the sender already has
+// in the pool if the transaction itself is gapped, or -1 otherwise.
+//
+// Local transactions are only subject to the deny list.
+func (p *AdmissionPolicy) admit(tx *types.Transaction, from common.Address, local bool, gapped int) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if _, ok := p.deny[from]; ok {
+		deniedMeter.Mark(1)
+		return fmt.Errorf("%w: sender %v", ErrAddressDenied, from)
+	}
+	if to := tx.To(); to != nil {
+		if _, ok := p.deny[*to]; ok {
+			deniedMeter.Mark(1)
+			return fmt.Errorf("%w: destination %v", ErrAddressDenied, *to)
+		}
+	}
+	if _, ok := p.allow[from]; ok || local {
+		return nil
+	}
+	if p.config.GapSlots > 0 && gapped >= 0 && uint64(gapped) >= p.config.GapSlots {
+		nonceGapLimitedMeter.Mark(1)
+		return fmt.Errorf("%w: sender %v has %d gapped transactions", ErrNonceGapLimit, from, gapped)
+	}
+	// Check both rate limiters before consuming any token, so a transaction
+	// rejected by one doesn't eat into the allowance of the other
+	now := p.clock.Now()
+
+	var sender, contract *tokenBucket
+	if p.config.SenderRate > 0 {
+		sender = p.bucket(p.senders, from, now, p.config.SenderRate, float64(p.config.SenderBurst))
+		sender.refill(now, p.config.SenderRate, float64(p.config.SenderBurst))
+		if sender.tokens < 1 {
+			senderRateLimitedMeter.Mark(1)
+			return fmt.Errorf("%w: sender %v", ErrSenderRateLimited, from)
+		}
+	}
+	if to := tx.To(); to != nil && p.config.ContractRate > 0 {
+		contract = p.bucket(p.contracts, *to, now, p.config.ContractRate, float64(p.config.ContractBurst))
+		contract.refill(now, p.config.ContractRate, float64(p.config.ContractBurst))
+		if contract.tokens < 1 {
+			contractRateLimitedMeter.Mark(1)
+			return fmt.Errorf("%w: destination %v", ErrContractRateLimited, *to)
+		}
+	}
+	if sender != nil {
+		sender.take(now, p.config.SenderRate, float64(p.config.SenderBurst))
+	}
+	if contract != nil {
+		contract.take(now, p.config.ContractRate, float64(p.config.ContractBurst))
+	}
+	return nil
+}
+
+// refund gives back the tokens consumed by admit for a transaction that was
+// rejected later on by the pool, so that only accepted transactions count
+// against the rate limits of their sender and destination.
+func (p *AdmissionPolicy) refund(tx *types.Transaction, from common.Address, local bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if _, ok := p.allow[from]; ok || local {
+		return
+	}
+	if bucket, ok := p.senders[from]; ok && p.config.SenderRate > 0 {
+		bucket.put(float64(p.config.SenderBurst))
+	}
+	if to := tx.To(); to != nil && p.config.ContractRate > 0 {
+		if bucket, ok := p.contracts[*to]; ok {
+			bucket.put(float64(p.config.ContractBurst))
+		}
+	}
+}
+
+// bucket retrieves the token bucket of an address, creating a full one if none
+// is tracked yet. If too many buckets are tracked, the ones that are full again
+// are dropped, as they are equivalent to freshly created ones.
+//
+// Note, this method assumes the policy lock is held!
+func (p *AdmissionPolicy) bucket(buckets map[common.Address]*tokenBucket, addr common.Address, now mclock.AbsTime, rate float64, burst float64) *tokenBucket {
+	if bucket, ok := buckets[addr]; ok {
+		return bucket
+	}
+	if len(buckets) >= maxPolicyBuckets {
+		for addr, bucket := range buckets {
+			if bucket.refill(now, rate, burst); bucket.tokens >= burst {
+				delete(buckets, addr)
+			}
+		}
+	}
+	bucket := &tokenBucket{tokens: burst, last: now}
+	buckets[addr] = bucket
+	return bucket
+}
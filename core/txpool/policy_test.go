@@ -0,0 +1,162 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package txpool
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the admission policy enforces the configured rate limits, nonce
+// gap cap and allow/deny lists.
+func TestAdmissionPolicy(t *testing.T) {
+	var (
+		signer    = types.LatestSigner(params.TestChainConfig)
+		key, _    = crypto.GenerateKey()
+		sender    = crypto.PubkeyToAddress(key.PublicKey)
+		contract  = common.Address{0x01}
+		other     = common.Address{0x02}
+		clock     = new(mclock.Simulated)
+		policy, _ = NewAdmissionPolicy(AdmissionConfig{
+			SenderRate:    1,
+			SenderBurst:   2,
+			ContractRate:  1,
+			ContractBurst: 3,
+			GapSlots:      1,
+		}, signer)
+	)
+	policy.clock = clock
+
+	tx := func(nonce uint64, to common.Address) *types.Transaction {
+		return types.MustSignNewTx(key, signer, &types.LegacyTx{Nonce: nonce, To: &to, Gas: 21000, GasPrice: big.NewInt(1)})
+	}
+	tests := []struct {
+		tx     *types.Transaction
+		local  bool
+		gapped int
+		wait   time.Duration
+		err    error
+	}{
+		{tx: tx(0, contract), gapped: -1},
+		{tx: tx(1, contract), gapped: -1},
+		{tx: tx(2, contract), gapped: -1, err: ErrSenderRateLimited}, // sender burst depleted
+		{tx: tx(2, contract), gapped: -1, local: true},               // locals are exempt
+		{tx: tx(2, contract), gapped: -1, wait: time.Second},         // sender refilled
+		{tx: tx(4, other), gapped: 1, wait: time.Second, err: ErrNonceGapLimit},
+		{tx: tx(3, other), gapped: -1},
+	}
+	for i, tt := range tests {
+		clock.Run(tt.wait)
+		if err := policy.admit(tt.tx, sender, tt.local, tt.gapped); !errors.Is(err, tt.err) {
+			t.Errorf("test %d: admission error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+	// Drain the contract's allowance from an allowed sender, ensuring that it is
+	// exempt from the limits while other senders are throttled
+	policy.SetLists(AdmissionLists{Allow: []common.Address{other}})
+	for i := 0; i < 5; i++ {
+		if err := policy.admit(tx(uint64(i), contract), other, false, -1); err != nil {
+			t.Fatalf("allowed transaction %d rejected: %v", i, err)
+		}
+	}
+	clock.Run(10 * time.Second)
+	for i := 0; i < 3; i++ {
+		if err := policy.admit(tx(uint64(i), contract), common.Address{byte(0x10 + i)}, false, -1); err != nil {
+			t.Fatalf("transaction %d rejected: %v", i, err)
+		}
+	}
+	if err := policy.admit(tx(3, contract), common.Address{0x20}, false, -1); !errors.Is(err, ErrContractRateLimited) {
+		t.Errorf("contract rate limit error mismatch: have %v, want %v", err, ErrContractRateLimited)
+	}
+	// Deny the contract and ensure even local transactions towards it are rejected
+	policy.SetLists(AdmissionLists{Deny: []common.Address{contract}})
+	if err := policy.admit(tx(5, contract), sender, true, -1); !errors.Is(err, ErrAddressDenied) {
+		t.Errorf("deny list error mismatch: have %v, want %v", err, ErrAddressDenied)
+	}
+}
+
+// rejectingSubPool is a subpool accepting only the transactions with even nonces.
+type rejectingSubPool struct {
+	SubPool
+}
+
+func (p *rejectingSubPool) Filter(tx *types.Transaction) bool { return true }
+func (p *rejectingSubPool) Nonce(addr common.Address) uint64  { return 0 }
+
+func (p *rejectingSubPool) Add(txs []*types.Transaction, local bool, sync bool) []error {
+	errs := make([]error, len(txs))
+	for i, tx := range txs {
+		if tx.Nonce()%2 == 1 {
+			errs[i] = ErrUnderpriced
+		}
+	}
+	return errs
+}
+
+// Tests that transactions rejected by the subpools don't consume the rate limit
+// tokens of their sender and destination.
+func TestAdmissionPolicyRefund(t *testing.T) {
+	var (
+		signer    = types.LatestSigner(params.TestChainConfig)
+		key, _    = crypto.GenerateKey()
+		contract  = common.Address{0x01}
+		clock     = new(mclock.Simulated)
+		policy, _ = NewAdmissionPolicy(AdmissionConfig{
+			SenderRate:    1,
+			SenderBurst:   2,
+			ContractRate:  1,
+			ContractBurst: 2,
+		}, signer)
+	)
+	policy.clock = clock
+
+	pool := &TxPool{subpools: []SubPool{new(rejectingSubPool)}}
+	pool.SetAdmissionPolicy(policy)
+
+	tx := func(nonce uint64) *types.Transaction {
+		return types.MustSignNewTx(key, signer, &types.LegacyTx{Nonce: nonce, To: &contract, Gas: 21000, GasPrice: big.NewInt(1)})
+	}
+	// Rejected transactions may be retried any number of times
+	for i := 0; i < 5; i++ {
+		if errs := pool.Add([]*types.Transaction{tx(1)}, false, false); !errors.Is(errs[0], ErrUnderpriced) {
+			t.Fatalf("attempt %d: error mismatch: have %v, want %v", i, errs[0], ErrUnderpriced)
+		}
+	}
+	// Accepted ones consume the allowance
+	tests := []struct {
+		nonce uint64
+		err   error
+	}{
+		{nonce: 0},
+		{nonce: 3, err: ErrUnderpriced},
+		{nonce: 2},
+		{nonce: 4, err: ErrSenderRateLimited},
+	}
+	for i, tt := range tests {
+		if errs := pool.Add([]*types.Transaction{tx(tt.nonce)}, false, false); !errors.Is(errs[0], tt.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, errs[0], tt.err)
+		}
+	}
+}
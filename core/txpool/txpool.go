@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"math/big"
 	"sync"
+	"sync/atomic"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
@@ -70,6 +71,9 @@ type TxPool struct {
 	reservations map[common.Address]SubPool // Map with the account to pool reservations
 	reserveLock  sync.Mutex                 // Lock protecting the account reservations
 
+	eventFeed TxEventFeed                     // Feed of lifecycle events across all subpools
+	policy    atomic.Pointer[AdmissionPolicy] // Admission policy enforced on top of the subpools
+
 	subs event.SubscriptionScope // Subscription scope to unsubscribe all on shutdown
 	quit chan chan error         // Quit channel to tear down the head updater
 }
@@ -88,6 +92,9 @@ func New(gasTip *big.Int, chain BlockChain, subpools []SubPool) (*TxPool, error)
 		quit:         make(chan chan error),
 	}
 	for i, subpool := range subpools {
+		if reporter, ok := subpool.(TxEventReporter); ok {
+			reporter.SetTxEventFeed(&pool.eventFeed)
+		}
 		if err := subpool.Init(gasTip, head, pool.reserver(i, subpool)); err != nil {
 			for j := i - 1; j >= 0; j-- {
 				subpools[j].Close()
@@ -273,9 +280,27 @@ func (p *TxPool) Add(txs []*types.Transaction, local bool, sync bool) []error {
 	txsets := make([][]*types.Transaction, len(p.subpools))
 	splits := make([]int, len(txs))
 
+	// Run the transactions through the admission policy first, so spam gets
+	// rejected before any expensive validation in the subpools
+	var (
+		errs     = make([]error, len(txs))
+		policy   = p.policy.Load()
+		admitted []bool
+	)
+	if policy != nil {
+		p.admit(policy, txs, local, errs)
+
+		admitted = make([]bool, len(txs))
+		for i, err := range errs {
+			admitted[i] = err == nil
+		}
+	}
 	for i, tx := range txs {
 		// Mark this transaction belonging to no-subpool
 		splits[i] = -1
+		if errs[i] != nil {
+			continue
+		}
 
 		// Try to find a subpool that accepts the transaction
 		for j, subpool := range p.subpools {
@@ -292,8 +317,11 @@ func (p *TxPool) Add(txs []*types.Transaction, local bool, sync bool) []error {
 	for i := 0; i < len(p.subpools); i++ {
 		errsets[i] = p.subpools[i].Add(txsets[i], local, sync)
 	}
-	errs := make([]error, len(txs))
 	for i, split := range splits {
+		// Skip the transactions rejected by the admission policy
+		if errs[i] != nil {
+			continue
+		}
 		// If the transaction was rejected by all subpools, mark it unsupported
 		if split == -1 {
 			errs[i] = core.ErrTxTypeNotSupported
@@ -303,9 +331,98 @@ func (p *TxPool) Add(txs []*types.Transaction, local bool, sync bool) []error {
 		errs[i] = errsets[split][0]
 		errsets[split] = errsets[split][1:]
 	}
+	// Hand back the rate limit tokens of the transactions the subpools rejected,
+	// only the ones entering the pool count against the allowances
+	for i, ok := range admitted {
+		if ok && errs[i] != nil {
+			if from, err := types.Sender(policy.signer, txs[i]); err == nil {
+				policy.refund(txs[i], from, local)
+			}
+		}
+	}
+	p.reportAdds(txs, errs, splits)
 	return errs
 }
 
+// admit runs a batch of transactions through the admission policy, recording
+// the rejections into the given error slice.
+func (p *TxPool) admit(policy *AdmissionPolicy, txs []*types.Transaction, local bool, errs []error) {
+	// gapState tracks the nonces of a sender, updated with the transactions of
+	// the batch already admitted, to decide whether a transaction is gapped
+	type gapState struct {
+		next   uint64              // Next executable nonce of the sender
+		queued map[uint64]struct{} // Nonces of the non-executable transactions
+	}
+	gaps := make(map[common.Address]*gapState)
+
+	for i, tx := range txs {
+		from, err := types.Sender(policy.signer, tx)
+		if err != nil {
+			continue // Leave it to the subpools to reject with a proper error
+		}
+		gapped := -1
+		if policy.config.GapSlots > 0 {
+			state, ok := gaps[from]
+			if !ok {
+				state = &gapState{next: p.Nonce(from), queued: make(map[uint64]struct{})}
+				_, queued := p.ContentFrom(from)
+				for _, tx := range queued {
+					state.queued[tx.Nonce()] = struct{}{}
+				}
+				gaps[from] = state
+			}
+			// Replacing an already gapped transaction doesn't create a new gap
+			if _, replace := state.queued[tx.Nonce()]; tx.Nonce() > state.next && !replace {
+				gapped = len(state.queued)
+			}
+		}
+		if errs[i] = policy.admit(tx, from, local, gapped); errs[i] != nil {
+			continue
+		}
+		if state, ok := gaps[from]; ok {
+			switch {
+			case tx.Nonce() == state.next:
+				state.next++
+			case tx.Nonce() > state.next:
+				state.queued[tx.Nonce()] = struct{}{}
+			}
+		}
+	}
+}
+
+// SetAdmissionPolicy sets the admission policy to enforce on the transactions
+// entering the pool on top of the limits of the individual subpools.
+func (p *TxPool) SetAdmissionPolicy(policy *AdmissionPolicy) {
+	p.policy.Store(policy)
+}
+
+// AdmissionPolicy returns the currently enforced admission policy, or nil if no
+// policy was set.
+func (p *TxPool) AdmissionPolicy() *AdmissionPolicy {
+	return p.policy.Load()
+}
+
+// reportAdds feeds the outcome of a batch of transaction additions to any event
+// subscribers. Already known transactions are not reported as they are merely
+// the result of network gossip, neither are the admissions into subpools which
+// report them in order with their other lifecycle events.
+func (p *TxPool) reportAdds(txs []*types.Transaction, errs []error, splits []int) {
+	events := make([]*TxEvent, 0, len(txs))
+	for i, tx := range txs {
+		switch {
+		case errs[i] == nil:
+			if _, ok := p.subpools[splits[i]].(TxEventReporter); !ok {
+				events = append(events, &TxEvent{Kind: TxEventAdmitted, Tx: tx})
+			}
+		case errors.Is(errs[i], ErrAlreadyKnown):
+			continue
+		default:
+			events = append(events, &TxEvent{Kind: TxEventRejected, Tx: tx, Reason: errs[i]})
+		}
+	}
+	p.eventFeed.Send(events)
+}
+
 // Pending retrieves all currently processable transactions, grouped by origin
 // account and sorted by nonce.
 func (p *TxPool) Pending(enforceTips bool) map[common.Address][]*LazyTransaction {
@@ -328,6 +445,15 @@ func (p *TxPool) SubscribeTransactions(ch chan<- core.NewTxsEvent, reorgs bool)
 	return p.subs.Track(event.JoinSubscriptions(subs...))
 }
 
+// SubscribeTxEvents registers a subscription for transaction lifecycle events:
+// admissions and rejections for all subpools, along with replacements and drops
+// for the subpools able to report them. Events are delivered asynchronously and
+// in order; a subscriber falling too far behind is disconnected with
+// ErrTxEventSubscriberTooSlow.
+func (p *TxPool) SubscribeTxEvents(ch chan<- []*TxEvent) event.Subscription {
+	return p.subs.Track(p.eventFeed.Subscribe(ch))
+}
+
 // Nonce returns the next nonce of an account, with all transactions executable
 // by the pool already applied on top.
 func (p *TxPool) Nonce(addr common.Address) uint64 {
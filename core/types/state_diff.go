@@ -0,0 +1,116 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"encoding/json"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// StateDiff is the set of account and storage changes applied by a block.
+// Only the account changes are part of the consensus-independent storage
+// encoding, the block number and hash are derived from the database key.
+type StateDiff struct {
+	Number   uint64         `rlp:"-"`
+	Hash     common.Hash    `rlp:"-"`
+	Accounts []*AccountDiff // Mutated accounts, sorted by address
+}
+
+// AccountDiff is the change of a single account within a block.
+type AccountDiff struct {
+	Address    common.Address
+	Prev       *DiffAccount `rlp:"nil"` // Account before the block, nil if not existent
+	Post       *DiffAccount `rlp:"nil"` // Account after the block, nil if deleted
+	Destructed bool         // Flag whether the previous storage was wiped
+	Storage    []*SlotDiff  // Mutated storage slots, sorted by key
+}
+
+// DiffAccount is the state of an account tracked in a state diff.
+type DiffAccount struct {
+	Nonce    uint64
+	Balance  *big.Int
+	CodeHash common.Hash
+}
+
+// SlotDiff is the change of a single storage slot within a block.
+type SlotDiff struct {
+	Key  common.Hash
+	Prev common.Hash
+	Post common.Hash
+}
+
+// NewDiffAccount creates the state diff representation of an account, nil if
+// the account doesn't exist.
+func NewDiffAccount(account *StateAccount) *DiffAccount {
+	if account == nil {
+		return nil
+	}
+	return &DiffAccount{
+		Nonce:    account.Nonce,
+		Balance:  new(big.Int).Set(account.Balance),
+		CodeHash: common.BytesToHash(account.CodeHash),
+	}
+}
+
+// MarshalJSON marshals the state diff along with the block it belongs to.
+func (d *StateDiff) MarshalJSON() ([]byte, error) {
+	accounts := d.Accounts
+	if accounts == nil {
+		accounts = []*AccountDiff{}
+	}
+	return json.Marshal(&struct {
+		Number   hexutil.Uint64 `json:"blockNumber"`
+		Hash     common.Hash    `json:"blockHash"`
+		Accounts []*AccountDiff `json:"accounts"`
+	}{hexutil.Uint64(d.Number), d.Hash, accounts})
+}
+
+// MarshalJSON marshals the account change.
+func (d *AccountDiff) MarshalJSON() ([]byte, error) {
+	storage := d.Storage
+	if storage == nil {
+		storage = []*SlotDiff{}
+	}
+	return json.Marshal(&struct {
+		Address    common.Address `json:"address"`
+		Prev       *DiffAccount   `json:"prev"`
+		Post       *DiffAccount   `json:"post"`
+		Destructed bool           `json:"destructed"`
+		Storage    []*SlotDiff    `json:"storage"`
+	}{d.Address, d.Prev, d.Post, d.Destructed, storage})
+}
+
+// MarshalJSON marshals the account state.
+func (a *DiffAccount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		Nonce    hexutil.Uint64 `json:"nonce"`
+		Balance  *hexutil.Big   `json:"balance"`
+		CodeHash common.Hash    `json:"codeHash"`
+	}{hexutil.Uint64(a.Nonce), (*hexutil.Big)(a.Balance), a.CodeHash})
+}
+
+// MarshalJSON marshals the storage slot change.
+func (s *SlotDiff) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		Key  common.Hash `json:"key"`
+		Prev common.Hash `json:"prev"`
+		Post common.Hash `json:"post"`
+	}{s.Key, s.Prev, s.Post})
+}
@@ -25,6 +25,7 @@ import (
 	"strings"
 
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/txpool"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/rlp"
 )
@@ -141,3 +142,43 @@ func (api *AdminAPI) ImportChain(file string) (bool, error) {
 	}
 	return true, nil
 }
+
+// errNoAdmissionPolicy is returned if the transaction pool runs without an
+// admission policy.
+var errNoAdmissionPolicy = errors.New("transaction pool admission policy not enabled")
+
+// TxPoolLists returns the addresses currently allowed and denied by the
+// admission policy of the transaction pool.
+func (api *AdminAPI) TxPoolLists() (*txpool.AdmissionLists, error) {
+	policy := api.eth.TxPool().AdmissionPolicy()
+	if policy == nil {
+		return nil, errNoAdmissionPolicy
+	}
+	lists := policy.Lists()
+	return &lists, nil
+}
+
+// ReloadTxPoolLists re-reads the allowed and denied addresses of the admission
+// policy of the transaction pool from the configured file.
+func (api *AdminAPI) ReloadTxPoolLists() (bool, error) {
+	policy := api.eth.TxPool().AdmissionPolicy()
+	if policy == nil {
+		return false, errNoAdmissionPolicy
+	}
+	if err := policy.Reload(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SetTxPoolLists replaces the allowed and denied addresses of the admission
+// policy of the transaction pool. The change is not persisted, a subsequent
+// reload restores the lists from the configured file.
+func (api *AdminAPI) SetTxPoolLists(lists txpool.AdmissionLists) (bool, error) {
+	policy := api.eth.TxPool().AdmissionPolicy()
+	if policy == nil {
+		return false, errNoAdmissionPolicy
+	}
+	policy.SetLists(lists)
+	return true, nil
+}
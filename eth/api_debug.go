@@ -26,6 +26,7 @@ import (
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/state/pruner"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
@@ -46,6 +47,63 @@ func NewDebugAPI(eth *Ethereum) *DebugAPI {
 	return &DebugAPI{eth: eth}
 }
 
+// debugDocs documents the methods of the DebugAPI in rpc_discover.
+var debugDocs = map[string]rpc.MethodDoc{
+	"dumpBlock": {
+		Summary: "Returns the entire state at a block.",
+		Params:  []string{"blockNumber"},
+	},
+	"preimage": {
+		Summary: "Returns the preimage of a trie key hash.",
+		Params:  []string{"hash"},
+	},
+	"getBadBlocks": {
+		Summary: "Returns the last blocks rejected by the node.",
+	},
+	"accountRange": {
+		Summary: "Returns a range of the accounts at a block, in trie order.",
+		Params:  []string{"block", "start", "maxResults", "nocode", "nostorage", "incompletes"},
+	},
+	"storageRangeAt": {
+		Summary: "Returns a range of the storage of a contract, as left by a transaction of a block.",
+		Params:  []string{"block", "txIndex", "contractAddress", "keyStart", "maxResult"},
+	},
+	"getModifiedAccountsByNumber": {
+		Summary: "Returns the accounts modified between two blocks, or by a single block if no end is given.",
+		Params:  []string{"startNum", "endNum"},
+	},
+	"getModifiedAccountsByHash": {
+		Summary: "Returns the accounts modified between two blocks, or by a single block if no end is given.",
+		Params:  []string{"startHash", "endHash"},
+	},
+	"getAccessibleState": {
+		Summary: "Returns the first block in a range whose state is available.",
+		Params:  []string{"from", "to"},
+	},
+	"setTrieFlushInterval": {
+		Summary: "Sets how often the in-memory tries are persisted, in block processing time.",
+		Params:  []string{"interval"},
+	},
+	"getTrieFlushInterval": {
+		Summary: "Returns how often the in-memory tries are persisted, in block processing time.",
+	},
+	"pruneState": {
+		Summary:     "Starts deleting the stale state in the background.",
+		Description: "Only supported by the hash-based scheme on non-archive nodes, once synced.",
+		Params:      []string{"options"},
+	},
+	"pruneStateStatus": {
+		Summary: "Returns the progress of the online state pruning.",
+		Result:  "progress",
+	},
+	"getStateDiff": {
+		Summary:     "Returns the accounts and storage slots changed by a block.",
+		Description: "Requires state diffs to be enabled and the block to be within their retention window.",
+		Params:      []string{"block"},
+		Result:      "stateDiff",
+	},
+}
+
 // DumpBlock retrieves the entire state of the database at a given block.
 func (api *DebugAPI) DumpBlock(blockNr rpc.BlockNumber) (state.Dump, error) {
 	opts := &state.DumpConfig{
@@ -443,3 +501,61 @@ func (api *DebugAPI) GetTrieFlushInterval() (string, error) {
 	}
 	return api.eth.blockchain.GetTrieFlushInterval().String(), nil
 }
+
+// PruneStateArgs represents the optional arguments of debug_pruneState.
+type PruneStateArgs struct {
+	BloomSize *hexutil.Uint64 `json:"bloomSize"` // Megabytes of memory allocated to the bloom filter
+	Throttle  *hexutil.Uint64 `json:"throttle"`  // Milliseconds to pause between deletion batches
+}
+
+// PruneState starts deleting the stale state from the database in the
+// background, while the node keeps processing blocks. It's only supported
+// by the hash-based scheme on non-archive nodes.
+func (api *DebugAPI) PruneState(args *PruneStateArgs) error {
+	if api.eth.pruner == nil {
+		return errors.New("online state pruning is only supported for hash-based scheme in full mode")
+	}
+	if !api.eth.Synced() {
+		return errors.New("state pruning is unavailable while syncing")
+	}
+	var (
+		bloomSize uint64
+		interval  time.Duration
+	)
+	if args != nil {
+		if args.BloomSize != nil {
+			bloomSize = uint64(*args.BloomSize)
+		}
+		if args.Throttle != nil {
+			interval = time.Duration(*args.Throttle) * time.Millisecond
+		}
+	}
+	return api.eth.pruner.Start(bloomSize, interval)
+}
+
+// PruneStateStatus returns the progress of the online state pruning.
+func (api *DebugAPI) PruneStateStatus() (*pruner.OnlineProgress, error) {
+	if api.eth.pruner == nil {
+		return nil, errors.New("online state pruning is only supported for hash-based scheme in full mode")
+	}
+	progress := api.eth.pruner.Progress()
+	return &progress, nil
+}
+
+// GetStateDiff returns the accounts and storage slots changed by the given
+// block. It requires the node to be running with state diffs enabled and
+// the block to be within the retention window.
+func (api *DebugAPI) GetStateDiff(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*types.StateDiff, error) {
+	header, err := api.eth.APIBackend.HeaderByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		return nil, errors.New("block not found")
+	}
+	diff := api.eth.blockchain.GetStateDiff(header.Hash(), header.Number.Uint64())
+	if diff == nil {
+		return nil, fmt.Errorf("state diff of block #%d not stored", header.Number.Uint64())
+	}
+	return diff, nil
+}
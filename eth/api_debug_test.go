@@ -18,17 +18,25 @@ package eth
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"math/big"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/ethereum/go-ethereum/trie"
 	"golang.org/x/exp/slices"
 )
@@ -219,3 +227,80 @@ func TestStorageRangeAt(t *testing.T) {
 		}
 	}
 }
+
+func TestGetStateDiff(t *testing.T) {
+	var (
+		key, _  = crypto.GenerateKey()
+		sender  = crypto.PubkeyToAddress(key.PublicKey)
+		to      = common.Address{0x01}
+		signer  = types.HomesteadSigner{}
+		genesis = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{sender: {Balance: big.NewInt(params.Ether)}},
+		}
+		engine = ethash.NewFaker()
+	)
+	_, blocks, _ := core.GenerateChainWithGenesis(genesis, engine, 4, func(i int, gen *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(sender), to, big.NewInt(1), params.TxGas, gen.BaseFee(), nil), signer, key)
+		gen.AddTx(tx)
+	})
+	config := *core.DefaultCacheConfigWithScheme(rawdb.HashScheme)
+	config.StateDiffs = true
+	config.StateDiffHistory = 2
+
+	chain, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), &config, genesis, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	eth := &Ethereum{blockchain: chain}
+	eth.APIBackend = &EthAPIBackend{eth: eth}
+	api := NewDebugAPI(eth)
+
+	// The retained diffs are served by number and by hash
+	for _, tt := range []struct {
+		query rpc.BlockNumberOrHash
+		block *types.Block
+	}{
+		{rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber), blocks[3]},
+		{rpc.BlockNumberOrHashWithHash(blocks[2].Hash(), true), blocks[2]},
+	} {
+		query := tt.query
+		diff, err := api.GetStateDiff(context.Background(), query)
+		if err != nil {
+			t.Fatalf("%v: failed to retrieve state diff: %v", query, err)
+		}
+		if diff.Number != tt.block.NumberU64() || diff.Hash != tt.block.Hash() {
+			t.Errorf("%v: block mismatch: have %d %x, want %d %x", query, diff.Number, diff.Hash, tt.block.NumberU64(), tt.block.Hash())
+		}
+		// The sender, recipient and coinbase changed
+		if len(diff.Accounts) != 3 {
+			t.Fatalf("%v: account count mismatch: have %d, want 3", query, len(diff.Accounts))
+		}
+		for _, account := range diff.Accounts {
+			if account.Address == to {
+				want := new(big.Int).SetUint64(diff.Number)
+				if account.Post == nil || account.Post.Balance.Cmp(want) != 0 {
+					t.Errorf("%v: recipient balance mismatch: have %v, want %v", query, account.Post, want)
+				}
+			}
+		}
+		blob, err := json.Marshal(diff)
+		if err != nil {
+			t.Fatalf("%v: failed to encode state diff: %v", query, err)
+		}
+		if want := fmt.Sprintf(`{"blockNumber":"%#x","blockHash":"%s",`, diff.Number, diff.Hash.Hex()); !strings.HasPrefix(string(blob), want) {
+			t.Errorf("%v: encoding mismatch: have %s, want prefix %s", query, blob, want)
+		}
+	}
+	// Expired and unknown blocks are reported
+	if _, err := api.GetStateDiff(context.Background(), rpc.BlockNumberOrHashWithNumber(1)); err == nil {
+		t.Error("expired state diff served")
+	}
+	if _, err := api.GetStateDiff(context.Background(), rpc.BlockNumberOrHashWithHash(common.Hash{0x01}, false)); err == nil {
+		t.Error("state diff of unknown block served")
+	}
+}
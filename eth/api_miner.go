@@ -17,11 +17,14 @@
 package eth
 
 import (
+	"fmt"
 	"math/big"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/miner"
 )
 
 // MinerAPI provides an API to control the miner.
@@ -83,3 +86,36 @@ func (api *MinerAPI) SetEtherbase(etherbase common.Address) bool {
 func (api *MinerAPI) SetRecommitInterval(interval int) {
 	api.e.Miner().SetRecommitInterval(time.Duration(interval) * time.Millisecond)
 }
+
+// SimulateBlockArgs are the optional parameters of a block production simulation.
+type SimulateBlockArgs struct {
+	Transactions []hexutil.Bytes `json:"transactions"` // Binary encoded transactions to include instead of the pool's
+	Timestamp    *hexutil.Uint64 `json:"timestamp"`    // Timestamp override of the block
+	Coinbase     *common.Address `json:"coinbase"`     // Coinbase override of the block
+}
+
+// SimulateBlock builds the block this node would produce right now on top of
+// the current head, either from the transaction pool or from the supplied list
+// of transactions. The block, including the consensus engine's system
+// transactions, is neither sealed nor broadcast.
+func (api *MinerAPI) SimulateBlock(args *SimulateBlockArgs) (*miner.SimulatedBlock, error) {
+	var params miner.SimulateArgs
+	if args != nil {
+		if args.Transactions != nil {
+			params.Transactions = make([]*types.Transaction, len(args.Transactions))
+			for i, blob := range args.Transactions {
+				tx := new(types.Transaction)
+				if err := tx.UnmarshalBinary(blob); err != nil {
+					return nil, fmt.Errorf("invalid transaction %d: %v", i, err)
+				}
+				params.Transactions[i] = tx
+			}
+		}
+		if args.Timestamp != nil {
+			timestamp := uint64(*args.Timestamp)
+			params.Timestamp = &timestamp
+		}
+		params.Coinbase = args.Coinbase
+	}
+	return api.e.Miner().SimulateBlock(&params)
+}
@@ -23,6 +23,7 @@ import (
 	"math/big"
 	"runtime"
 	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
@@ -45,6 +46,7 @@ import (
 	"github.com/ethereum/go-ethereum/eth/gasprice"
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
 	"github.com/ethereum/go-ethereum/eth/protocols/snap"
+	"github.com/ethereum/go-ethereum/eth/tokens"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
@@ -73,6 +75,9 @@ type Ethereum struct {
 	txPool *txpool.TxPool
 
 	blockchain         *core.BlockChain
+	pruner             *pruner.OnlinePruner // Online state pruner, nil if unsupported
+	replica            *replicaFollower     // Follower of the primary node, nil if not a read replica
+	tokenIndexer       *tokens.Indexer      // Token transfer indexer, nil if disabled
 	handler            *handler
 	ethDialCandidates  enode.Iterator
 	snapDialCandidates enode.Iterator
@@ -131,12 +136,33 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	log.Info("Allocated trie memory caches", "clean", common.StorageSize(config.TrieCleanCache)*1024*1024, "dirty", common.StorageSize(config.TrieDirtyCache)*1024*1024)
 
 	// Assemble the Ethereum object
-	chainDb, err := stack.OpenDatabaseWithFreezer("chaindata", config.DatabaseCache, config.DatabaseHandles, config.DatabaseFreezer, "eth/db/chaindata/", false)
-	if err != nil {
-		return nil, err
+	var (
+		chainDb   ethdb.Database
+		replicaDb *rawdb.ReplicaDatabase
+		err       error
+	)
+	if config.ReplicaOf != "" {
+		// Read replicas share the database of the primary, which is the only one
+		// writing it. Everything maintaining the database is left to the primary.
+		if replicaDb, err = openReplicaDatabase(stack, config); err != nil {
+			return nil, err
+		}
+		if rawdb.ReadStateScheme(replicaDb) == rawdb.PathScheme {
+			replicaDb.Close()
+			return nil, errors.New("read replicas are not supported with the path state scheme")
+		}
+		chainDb = replicaDb
+		config.StateScheme = rawdb.HashScheme
+		config.SnapshotCache = 0
+		log.Info("Running as read replica", "primary", config.ReplicaOf)
+	} else {
+		chainDb, err = stack.OpenDatabaseWithFreezer("chaindata", config.DatabaseCache, config.DatabaseHandles, config.DatabaseFreezer, "eth/db/chaindata/", false)
+		if err != nil {
+			return nil, err
+		}
 	}
 	// Try to recover offline state pruning only in hash-based.
-	if config.StateScheme == rawdb.HashScheme {
+	if config.StateScheme == rawdb.HashScheme && replicaDb == nil {
 		if err := pruner.RecoverPruning(stack.ResolvePath(""), chainDb); err != nil {
 			log.Error("Failed to recover state", "error", err)
 		}
@@ -198,13 +224,21 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 		cacheConfig = &core.CacheConfig{
 			TrieCleanLimit:      config.TrieCleanCache,
 			TrieCleanNoPrefetch: config.NoPrefetch,
+			ParallelTx:          config.ParallelTx,
 			TrieDirtyLimit:      config.TrieDirtyCache,
 			TrieDirtyDisabled:   config.NoPruning,
 			TrieTimeLimit:       config.TrieTimeout,
 			SnapshotLimit:       config.SnapshotCache,
 			Preimages:           config.Preimages,
 			StateHistory:        config.StateHistory,
+			HistoryBlocks:       config.HistoryBlocks,
 			StateScheme:         config.StateScheme,
+			StateDiffs:          config.StateDiffs,
+			StateDiffHistory:    config.StateDiffHistory,
+			AccountHistory:      config.AccountHistory,
+			TxHistory:           config.TxHistory,
+			TxHistoryInternal:   config.TxHistoryInternal,
+			Replica:             replicaDb != nil,
 		}
 	)
 	// Override the chain config with provided settings.
@@ -215,12 +249,41 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	if config.OverrideVerkle != nil {
 		overrides.OverrideVerkle = config.OverrideVerkle
 	}
-	eth.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, config.Genesis, &overrides, eth.engine, vmConfig, eth.shouldPreserve, &config.TransactionHistory)
+	// Replicas leave the transaction indices to the primary owning the database
+	txLookupLimit := &config.TransactionHistory
+	if replicaDb != nil {
+		txLookupLimit = nil
+	}
+	eth.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, config.Genesis, &overrides, eth.engine, vmConfig, eth.shouldPreserve, txLookupLimit)
 	if err != nil {
 		return nil, err
 	}
+	if replicaDb != nil {
+		eth.replica = newReplicaFollower(replicaDb, eth.blockchain, config)
+	}
+	// Replicas serve the token index of the primary without maintaining it
+	if replicaDb == nil {
+		if config.TokenIndex {
+			eth.tokenIndexer = tokens.NewIndexer(chainDb, eth.blockchain)
+		} else if _, _, ok := rawdb.ReadTokenIndexHead(chainDb); ok {
+			log.Warn("Token index disabled, discarding it")
+			rawdb.DeleteTokenIndex(chainDb)
+		}
+	}
 	eth.bloomIndexer.Start(eth.blockchain)
 
+	// Resume the online state pruning if it was interrupted, only in hash-based.
+	if eth.blockchain.TrieDB().Scheme() == rawdb.HashScheme && !config.NoPruning && replicaDb == nil {
+		eth.pruner = pruner.NewOnlinePruner(chainDb, eth.blockchain, pruner.OnlineConfig{
+			Datadir:   stack.ResolvePath(""),
+			BloomSize: 2048,
+			Interval:  100 * time.Millisecond,
+		})
+		if err := eth.pruner.Resume(); err != nil {
+			log.Error("Failed to resume online state pruning", "err", err)
+		}
+	}
+
 	if config.BlobPool.Datadir != "" {
 		config.BlobPool.Datadir = stack.ResolvePath(config.BlobPool.Datadir)
 	}
@@ -229,12 +292,24 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = stack.ResolvePath(config.TxPool.Journal)
 	}
+	if config.TxPool.Snapshot != "" {
+		config.TxPool.Snapshot = stack.ResolvePath(config.TxPool.Snapshot)
+	}
 	legacyPool := legacypool.New(config.TxPool, eth.blockchain)
 
 	eth.txPool, err = txpool.New(new(big.Int).SetUint64(config.TxPool.PriceLimit), eth.blockchain, []txpool.SubPool{legacyPool, blobPool})
 	if err != nil {
 		return nil, err
 	}
+	if config.TxAdmission.Lists != "" {
+		config.TxAdmission.Lists = stack.ResolvePath(config.TxAdmission.Lists)
+	}
+	policy, err := txpool.NewAdmissionPolicy(config.TxAdmission, types.LatestSigner(eth.blockchain.Config()))
+	if err != nil {
+		return nil, err
+	}
+	eth.txPool.SetAdmissionPolicy(policy)
+
 	// Permit the downloader to use the trie cache allowance during fast sync
 	cacheLimit := cacheConfig.TrieCleanLimit + cacheConfig.TrieDirtyLimit + cacheConfig.SnapshotLimit
 	if eth.handler, err = newHandler(&handlerConfig{
@@ -331,9 +406,13 @@ func (s *Ethereum) APIs() []rpc.API {
 		}, {
 			Namespace: "debug",
 			Service:   NewDebugAPI(s),
+			Docs:      debugDocs,
 		}, {
 			Namespace: "net",
 			Service:   s.netRPCService,
+		}, {
+			Namespace: "polarys",
+			Service:   tokens.NewAPI(s.chainDb),
 		},
 	}...)
 }
@@ -423,6 +502,9 @@ func (s *Ethereum) SetEtherbase(etherbase common.Address) {
 // is already running, this method adjust the number of threads allowed to use
 // and updates the minimum price required by the transaction pool.
 func (s *Ethereum) StartMining() error {
+	if s.replica != nil {
+		return errors.New("read replicas can't mine, the chain is owned by the primary node")
+	}
 	// If the miner was not running, initialize it
 	if !s.IsMining() {
 		// Propagate the initial price point to the transaction pool
@@ -541,6 +623,14 @@ func (s *Ethereum) Start() error {
 	}
 	// Start the networking layer and the light server if requested
 	s.handler.Start(maxPeers)
+
+	// Start following the primary if running as a read replica
+	if s.replica != nil {
+		s.replica.start()
+	}
+	if s.tokenIndexer != nil {
+		s.tokenIndexer.Start()
+	}
 	return nil
 }
 
@@ -551,12 +641,21 @@ func (s *Ethereum) Stop() error {
 	s.ethDialCandidates.Close()
 	s.snapDialCandidates.Close()
 	s.handler.Stop()
+	if s.replica != nil {
+		s.replica.stop()
+	}
 
 	// Then stop everything else.
 	s.bloomIndexer.Close()
 	close(s.closeBloomHandler)
 	s.txPool.Close()
 	s.miner.Close()
+	if s.pruner != nil {
+		s.pruner.Stop()
+	}
+	if s.tokenIndexer != nil {
+		s.tokenIndexer.Stop()
+	}
 	s.blockchain.Stop()
 	s.engine.Close()
 
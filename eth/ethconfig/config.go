@@ -29,6 +29,7 @@ import (
 	"github.com/ethereum/go-ethereum/consensus/zephyria"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/txpool"
 	"github.com/ethereum/go-ethereum/core/txpool/blobpool"
 	"github.com/ethereum/go-ethereum/core/txpool/legacypool"
 	"github.com/ethereum/go-ethereum/eth/downloader"
@@ -102,13 +103,29 @@ type Config struct {
 
 	NoPruning  bool // Whether to disable pruning and flush everything to disk
 	NoPrefetch bool // Whether to disable prefetching and only load state on demand
+	ParallelTx bool // Whether to execute the transactions of imported blocks in parallel
 
 	// Deprecated, use 'TransactionHistory' instead.
 	TxLookupLimit      uint64 `toml:",omitempty"` // The maximum number of blocks from head whose tx indices are reserved.
 	TransactionHistory uint64 `toml:",omitempty"` // The maximum number of blocks from head whose tx indices are reserved.
 	StateHistory       uint64 `toml:",omitempty"` // The maximum number of blocks from head whose state histories are reserved.
+	HistoryBlocks      uint64 `toml:",omitempty"` // The maximum number of blocks from head whose bodies and receipts are reserved.
 	StateScheme        string `toml:",omitempty"` // State scheme used to store ethereum state and merkle trie nodes on top
 
+	StateDiffs       bool   `toml:",omitempty"` // Whether to store the state diff of every imported block
+	StateDiffHistory uint64 `toml:",omitempty"` // The maximum number of blocks from head whose state diffs are reserved.
+	AccountHistory   bool   `toml:",omitempty"` // Whether to index the historical balances and nonces of accounts
+
+	TxHistory         bool `toml:",omitempty"` // Whether to index the transactions by the addresses involved
+	TxHistoryInternal bool `toml:",omitempty"` // Whether to index the transactions by the addresses called internally too
+	TokenIndex        bool `toml:",omitempty"` // Whether to index the token transfers and balances of the accounts
+
+	// Read replica settings. A replica serves the database of a primary node
+	// running on the same machine, following its head over RPC.
+	ReplicaOf       string `toml:",omitempty"` // Data directory of the primary node, empty if not a replica
+	ReplicaAncient  string `toml:",omitempty"` // Ancient directory of the primary node (default = inside its chaindata)
+	ReplicaEndpoint string `toml:",omitempty"` // RPC endpoint of the primary node (default = IPC socket in its data directory)
+
 	// RequiredBlocks is a set of block number -> hash mappings which must be in the
 	// canonical chain of all remote peers. Setting the option makes geth verify the
 	// presence of these blocks for every new peer connection.
@@ -141,8 +158,9 @@ type Config struct {
 	Miner miner.Config
 
 	// Transaction pool options
-	TxPool   legacypool.Config
-	BlobPool blobpool.Config
+	TxPool      legacypool.Config
+	BlobPool    blobpool.Config
+	TxAdmission txpool.AdmissionConfig
 
 	// Gas Price Oracle options
 	GPO gasprice.Config
@@ -163,6 +181,14 @@ type Config struct {
 	// send-transaction variants. The unit is ether.
 	RPCTxFeeCap float64
 
+	// RPCLogRangeLimit is the maximum number of blocks searched by a log query,
+	// unlimited if zero.
+	RPCLogRangeLimit uint64
+
+	// RPCLogResultLimit is the maximum number of logs returned by a log query,
+	// unlimited if zero.
+	RPCLogResultLimit int
+
 	// OverrideCancun (TODO: remove after the fork)
 	OverrideCancun *uint64 `toml:",omitempty"`
 
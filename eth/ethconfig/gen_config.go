@@ -7,6 +7,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/txpool"
 	"github.com/ethereum/go-ethereum/core/txpool/blobpool"
 	"github.com/ethereum/go-ethereum/core/txpool/legacypool"
 	"github.com/ethereum/go-ethereum/eth/downloader"
@@ -24,10 +25,21 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		SnapDiscoveryURLs       []string
 		NoPruning               bool
 		NoPrefetch              bool
+		ParallelTx              bool
 		TxLookupLimit           uint64                 `toml:",omitempty"`
 		TransactionHistory      uint64                 `toml:",omitempty"`
 		StateHistory            uint64                 `toml:",omitempty"`
+		HistoryBlocks           uint64                 `toml:",omitempty"`
 		StateScheme             string                 `toml:",omitempty"`
+		StateDiffs              bool                   `toml:",omitempty"`
+		StateDiffHistory        uint64                 `toml:",omitempty"`
+		AccountHistory          bool                   `toml:",omitempty"`
+		TxHistory               bool                   `toml:",omitempty"`
+		TxHistoryInternal       bool                   `toml:",omitempty"`
+		TokenIndex              bool                   `toml:",omitempty"`
+		ReplicaOf               string                 `toml:",omitempty"`
+		ReplicaAncient          string                 `toml:",omitempty"`
+		ReplicaEndpoint         string                 `toml:",omitempty"`
 		RequiredBlocks          map[uint64]common.Hash `toml:"-"`
 		LightServ               int                    `toml:",omitempty"`
 		LightIngress            int                    `toml:",omitempty"`
@@ -48,12 +60,15 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		Miner                   miner.Config
 		TxPool                  legacypool.Config
 		BlobPool                blobpool.Config
+		TxAdmission             txpool.AdmissionConfig
 		GPO                     gasprice.Config
 		EnablePreimageRecording bool
 		DocRoot                 string `toml:"-"`
 		RPCGasCap               uint64
 		RPCEVMTimeout           time.Duration
 		RPCTxFeeCap             float64
+		RPCLogRangeLimit        uint64
+		RPCLogResultLimit       int
 		OverrideCancun          *uint64 `toml:",omitempty"`
 		OverrideVerkle          *uint64 `toml:",omitempty"`
 	}
@@ -65,10 +80,21 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.SnapDiscoveryURLs = c.SnapDiscoveryURLs
 	enc.NoPruning = c.NoPruning
 	enc.NoPrefetch = c.NoPrefetch
+	enc.ParallelTx = c.ParallelTx
 	enc.TxLookupLimit = c.TxLookupLimit
 	enc.TransactionHistory = c.TransactionHistory
 	enc.StateHistory = c.StateHistory
+	enc.HistoryBlocks = c.HistoryBlocks
 	enc.StateScheme = c.StateScheme
+	enc.StateDiffs = c.StateDiffs
+	enc.StateDiffHistory = c.StateDiffHistory
+	enc.AccountHistory = c.AccountHistory
+	enc.TxHistory = c.TxHistory
+	enc.TxHistoryInternal = c.TxHistoryInternal
+	enc.TokenIndex = c.TokenIndex
+	enc.ReplicaOf = c.ReplicaOf
+	enc.ReplicaAncient = c.ReplicaAncient
+	enc.ReplicaEndpoint = c.ReplicaEndpoint
 	enc.RequiredBlocks = c.RequiredBlocks
 	enc.LightServ = c.LightServ
 	enc.LightIngress = c.LightIngress
@@ -89,12 +115,15 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.Miner = c.Miner
 	enc.TxPool = c.TxPool
 	enc.BlobPool = c.BlobPool
+	enc.TxAdmission = c.TxAdmission
 	enc.GPO = c.GPO
 	enc.EnablePreimageRecording = c.EnablePreimageRecording
 	enc.DocRoot = c.DocRoot
 	enc.RPCGasCap = c.RPCGasCap
 	enc.RPCEVMTimeout = c.RPCEVMTimeout
 	enc.RPCTxFeeCap = c.RPCTxFeeCap
+	enc.RPCLogRangeLimit = c.RPCLogRangeLimit
+	enc.RPCLogResultLimit = c.RPCLogResultLimit
 	enc.OverrideCancun = c.OverrideCancun
 	enc.OverrideVerkle = c.OverrideVerkle
 	return &enc, nil
@@ -110,10 +139,21 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		SnapDiscoveryURLs       []string
 		NoPruning               *bool
 		NoPrefetch              *bool
+		ParallelTx              *bool
 		TxLookupLimit           *uint64                `toml:",omitempty"`
 		TransactionHistory      *uint64                `toml:",omitempty"`
 		StateHistory            *uint64                `toml:",omitempty"`
+		HistoryBlocks           *uint64                `toml:",omitempty"`
 		StateScheme             *string                `toml:",omitempty"`
+		StateDiffs              *bool                  `toml:",omitempty"`
+		StateDiffHistory        *uint64                `toml:",omitempty"`
+		AccountHistory          *bool                  `toml:",omitempty"`
+		TxHistory               *bool                  `toml:",omitempty"`
+		TxHistoryInternal       *bool                  `toml:",omitempty"`
+		TokenIndex              *bool                  `toml:",omitempty"`
+		ReplicaOf               *string                `toml:",omitempty"`
+		ReplicaAncient          *string                `toml:",omitempty"`
+		ReplicaEndpoint         *string                `toml:",omitempty"`
 		RequiredBlocks          map[uint64]common.Hash `toml:"-"`
 		LightServ               *int                   `toml:",omitempty"`
 		LightIngress            *int                   `toml:",omitempty"`
@@ -134,12 +174,15 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		Miner                   *miner.Config
 		TxPool                  *legacypool.Config
 		BlobPool                *blobpool.Config
+		TxAdmission             *txpool.AdmissionConfig
 		GPO                     *gasprice.Config
 		EnablePreimageRecording *bool
 		DocRoot                 *string `toml:"-"`
 		RPCGasCap               *uint64
 		RPCEVMTimeout           *time.Duration
 		RPCTxFeeCap             *float64
+		RPCLogRangeLimit        *uint64
+		RPCLogResultLimit       *int
 		OverrideCancun          *uint64 `toml:",omitempty"`
 		OverrideVerkle          *uint64 `toml:",omitempty"`
 	}
@@ -168,6 +211,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.NoPrefetch != nil {
 		c.NoPrefetch = *dec.NoPrefetch
 	}
+	if dec.ParallelTx != nil {
+		c.ParallelTx = *dec.ParallelTx
+	}
 	if dec.TxLookupLimit != nil {
 		c.TxLookupLimit = *dec.TxLookupLimit
 	}
@@ -177,9 +223,39 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.StateHistory != nil {
 		c.StateHistory = *dec.StateHistory
 	}
+	if dec.HistoryBlocks != nil {
+		c.HistoryBlocks = *dec.HistoryBlocks
+	}
 	if dec.StateScheme != nil {
 		c.StateScheme = *dec.StateScheme
 	}
+	if dec.StateDiffs != nil {
+		c.StateDiffs = *dec.StateDiffs
+	}
+	if dec.StateDiffHistory != nil {
+		c.StateDiffHistory = *dec.StateDiffHistory
+	}
+	if dec.AccountHistory != nil {
+		c.AccountHistory = *dec.AccountHistory
+	}
+	if dec.TxHistory != nil {
+		c.TxHistory = *dec.TxHistory
+	}
+	if dec.TxHistoryInternal != nil {
+		c.TxHistoryInternal = *dec.TxHistoryInternal
+	}
+	if dec.TokenIndex != nil {
+		c.TokenIndex = *dec.TokenIndex
+	}
+	if dec.ReplicaOf != nil {
+		c.ReplicaOf = *dec.ReplicaOf
+	}
+	if dec.ReplicaAncient != nil {
+		c.ReplicaAncient = *dec.ReplicaAncient
+	}
+	if dec.ReplicaEndpoint != nil {
+		c.ReplicaEndpoint = *dec.ReplicaEndpoint
+	}
 	if dec.RequiredBlocks != nil {
 		c.RequiredBlocks = dec.RequiredBlocks
 	}
@@ -240,6 +316,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.BlobPool != nil {
 		c.BlobPool = *dec.BlobPool
 	}
+	if dec.TxAdmission != nil {
+		c.TxAdmission = *dec.TxAdmission
+	}
 	if dec.GPO != nil {
 		c.GPO = *dec.GPO
 	}
@@ -258,6 +337,12 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.RPCTxFeeCap != nil {
 		c.RPCTxFeeCap = *dec.RPCTxFeeCap
 	}
+	if dec.RPCLogRangeLimit != nil {
+		c.RPCLogRangeLimit = *dec.RPCLogRangeLimit
+	}
+	if dec.RPCLogResultLimit != nil {
+		c.RPCLogResultLimit = *dec.RPCLogResultLimit
+	}
 	if dec.OverrideCancun != nil {
 		c.OverrideCancun = dec.OverrideCancun
 	}
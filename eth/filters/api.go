@@ -333,31 +333,55 @@ func (api *FilterAPI) NewFilter(crit FilterCriteria) (rpc.ID, error) {
 
 // GetLogs returns logs matching the given argument that are stored within the state.
 func (api *FilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([]*types.Log, error) {
-	var filter *Filter
-	if crit.BlockHash != nil {
-		// Block filter requested, construct a single-shot filter
-		filter = api.sys.NewBlockFilter(*crit.BlockHash, crit.Addresses, crit.Topics)
-	} else {
-		// Convert the RPC block numbers into internal representations
-		begin := rpc.LatestBlockNumber.Int64()
-		if crit.FromBlock != nil {
-			begin = crit.FromBlock.Int64()
-		}
-		end := rpc.LatestBlockNumber.Int64()
-		if crit.ToBlock != nil {
-			end = crit.ToBlock.Int64()
-		}
-		// Construct the range filter
-		filter = api.sys.NewRangeFilter(begin, end, crit.Addresses, crit.Topics)
-	}
 	// Run the filter and return all the logs
-	logs, err := filter.Logs(ctx)
+	logs, err := api.newFilter(crit).Logs(ctx)
 	if err != nil {
 		return nil, err
 	}
 	return returnLogs(logs), err
 }
 
+// LogsPage is a page of the results of a paginated log query.
+type LogsPage struct {
+	Logs   []*types.Log `json:"logs"`
+	Cursor *LogCursor   `json:"cursor"` // Position to resume the query from, nil if complete
+}
+
+// GetLogsPage returns a page of the logs matching the given argument, starting
+// from the position of the cursor returned by the previous page, or from the
+// beginning if nil. The page holds at most limit logs, capped by the result
+// limit of the node, and searches at most as many blocks as the range limit.
+func (api *FilterAPI) GetLogsPage(ctx context.Context, crit FilterCriteria, cursor *LogCursor, limit *hexutil.Uint) (*LogsPage, error) {
+	var n int
+	if limit != nil {
+		n = int(*limit)
+	}
+	logs, next, err := api.newFilter(crit).LogsPage(ctx, cursor, n)
+	if err != nil {
+		return nil, err
+	}
+	return &LogsPage{Logs: returnLogs(logs), Cursor: next}, nil
+}
+
+// newFilter creates the filter of a log query.
+func (api *FilterAPI) newFilter(crit FilterCriteria) *Filter {
+	if crit.BlockHash != nil {
+		// Block filter requested, construct a single-shot filter
+		return api.sys.NewBlockFilter(*crit.BlockHash, crit.Addresses, crit.Topics)
+	}
+	// Convert the RPC block numbers into internal representations
+	begin := rpc.LatestBlockNumber.Int64()
+	if crit.FromBlock != nil {
+		begin = crit.FromBlock.Int64()
+	}
+	end := rpc.LatestBlockNumber.Int64()
+	if crit.ToBlock != nil {
+		end = crit.ToBlock.Int64()
+	}
+	// Construct the range filter
+	return api.sys.NewRangeFilter(begin, end, crit.Addresses, crit.Topics)
+}
+
 // UninstallFilter removes the filter with the given filter id.
 func (api *FilterAPI) UninstallFilter(id rpc.ID) bool {
 	api.filtersMu.Lock()
@@ -18,15 +18,93 @@ package filters
 
 import (
 	"context"
+	"encoding/binary"
 	"errors"
+	"fmt"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/bloombits"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/internal/tracing"
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
+// defaultPageSize is the number of logs returned by a page of a paginated log
+// query, if neither the query nor the configuration limit it.
+const defaultPageSize = 1000
+
+// LimitExceededError is returned for log queries exceeding the range or result
+// limits of the node. It suggests a block range within the limits, starting at
+// the beginning of the queried range.
+type LimitExceededError struct {
+	msg      string
+	From, To uint64 // Suggested block range
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("%s, retry with the range [%#x, %#x]", e.msg, e.From, e.To)
+}
+
+// ErrorCode returns the JSON-RPC error code of the "limit exceeded" error.
+func (e *LimitExceededError) ErrorCode() int { return -32005 }
+
+// ErrorData returns the suggested block range.
+func (e *LimitExceededError) ErrorData() interface{} {
+	return map[string]hexutil.Uint64{"from": hexutil.Uint64(e.From), "to": hexutil.Uint64(e.To)}
+}
+
+// LogCursor is the position of a log in the chain, from which paginated log
+// queries resume. It is encoded as an opaque hex string in JSON.
+type LogCursor struct {
+	Block    uint64 // Number of the block of the log
+	LogIndex uint   // Index of the log in the block
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (c LogCursor) MarshalText() ([]byte, error) {
+	var enc [12]byte
+	binary.BigEndian.PutUint64(enc[:8], c.Block)
+	binary.BigEndian.PutUint32(enc[8:], uint32(c.LogIndex))
+	return hexutil.Bytes(enc[:]).MarshalText()
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (c *LogCursor) UnmarshalText(input []byte) error {
+	var dec hexutil.Bytes
+	if err := dec.UnmarshalText(input); err != nil {
+		return err
+	}
+	if len(dec) != 12 {
+		return errors.New("invalid log cursor")
+	}
+	c.Block = binary.BigEndian.Uint64(dec[:8])
+	c.LogIndex = uint(binary.BigEndian.Uint32(dec[8:]))
+	return nil
+}
+
+// String returns the encoded cursor.
+func (c LogCursor) String() string {
+	enc, _ := c.MarshalText()
+	return string(enc)
+}
+
+// page returns the first limit logs at or after the position of the cursor, the
+// logs being ordered by position. If more logs remain, the cursor to resume from
+// is the position of the next one, otherwise it is next.
+func (c *LogCursor) page(logs []*types.Log, limit int, next *LogCursor) ([]*types.Log, *LogCursor, error) {
+	if c != nil {
+		for len(logs) > 0 && logs[0].BlockNumber == c.Block && logs[0].Index < c.LogIndex {
+			logs = logs[1:]
+		}
+	}
+	if len(logs) > limit {
+		return logs[:limit], &LogCursor{Block: logs[limit].BlockNumber, LogIndex: logs[limit].Index}, nil
+	}
+	return logs, next, nil
+}
+
 // Filter can be used to retrieve and filter logs.
 type Filter struct {
 	sys *FilterSystem
@@ -94,16 +172,23 @@ func newFilter(sys *FilterSystem, addresses []common.Address, topics [][]common.
 
 // Logs searches the blockchain for matching log entries, returning all from the
 // first block that contains matches, updating the start of the filter accordingly.
-func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
+//
+// Range queries spanning more blocks or matching more logs than the configured
+// limits fail with a LimitExceededError.
+func (f *Filter) Logs(ctx context.Context) (found []*types.Log, err error) {
+	ctx, span := f.startSpan(ctx, "filters.Logs")
+	defer func() {
+		span.SetAttributes(tracing.Int("logs", len(found)))
+		span.SetError(err)
+		span.End()
+	}()
+
 	// If we're doing singleton block filtering, execute and return
 	if f.block != nil {
-		header, err := f.sys.backend.HeaderByHash(ctx, *f.block)
+		header, err := f.blockHeader(ctx)
 		if err != nil {
 			return nil, err
 		}
-		if header == nil {
-			return nil, errors.New("unknown block")
-		}
 		return f.blockLogs(ctx, header)
 	}
 
@@ -121,7 +206,173 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	if beginPending && endPending {
 		return f.pendingLogs(), nil
 	}
+	if err := f.resolveRange(ctx); err != nil {
+		return nil, err
+	}
+	var (
+		begin       = uint64(f.begin)
+		rangeLimit  = f.sys.cfg.RangeLimit
+		resultLimit = f.sys.cfg.ResultLimit
+	)
+	if rangeLimit > 0 && f.end >= f.begin && uint64(f.end-f.begin) >= rangeLimit {
+		return nil, &LimitExceededError{
+			msg:  fmt.Sprintf("block range exceeds the limit of %d blocks", rangeLimit),
+			From: begin,
+			To:   begin + rangeLimit - 1,
+		}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	logChan, errChan := f.rangeLogsAsync(ctx)
+	var logs []*types.Log
+	for {
+		select {
+		case log := <-logChan:
+			if resultLimit > 0 && len(logs) == resultLimit {
+				// Suggest the blocks before the one overflowing the limit, or
+				// that single block if it overflows on its own
+				to := log.BlockNumber
+				if to > begin {
+					to--
+				}
+				return nil, &LimitExceededError{
+					msg:  fmt.Sprintf("query returned more than %d results", resultLimit),
+					From: begin,
+					To:   to,
+				}
+			}
+			logs = append(logs, log)
+		case err := <-errChan:
+			if err != nil {
+				// if an error occurs during extraction, we do return the extracted data
+				return logs, err
+			}
+			// Append the pending ones
+			if endPending {
+				pendingLogs := f.pendingLogs()
+				logs = append(logs, pendingLogs...)
+			}
+			return logs, nil
+		}
+	}
+}
+
+// LogsPage searches the blockchain for at most limit matching log entries,
+// starting at the position of the cursor or at the beginning of the filter's
+// range if nil. It returns the cursor to resume the search from, which is nil
+// if the search is complete.
+//
+// A page covers at most the configured range limit of blocks. If the range of
+// the filter is larger, the search resumes at the next block, even if the page
+// returned no logs. The limit is capped by the configured result limit, it
+// defaults to the result limit or to defaultPageSize if there is none. Pending
+// logs aren't paginated and are never returned.
+func (f *Filter) LogsPage(ctx context.Context, cursor *LogCursor, limit int) (found []*types.Log, next *LogCursor, err error) {
+	ctx, span := f.startSpan(ctx, "filters.LogsPage")
+	defer func() {
+		span.SetAttributes(tracing.Int("logs", len(found)), tracing.Bool("more", next != nil))
+		span.SetError(err)
+		span.End()
+	}()
+
+	if resultLimit := f.sys.cfg.ResultLimit; resultLimit > 0 && (limit <= 0 || limit > resultLimit) {
+		limit = resultLimit
+	}
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	// Single blocks are paginated within the logs of the block
+	if f.block != nil {
+		header, err := f.blockHeader(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		if cursor != nil && cursor.Block != header.Number.Uint64() {
+			return nil, nil, errors.New("cursor outside of the filtered block")
+		}
+		logs, err := f.blockLogs(ctx, header)
+		if err != nil {
+			return nil, nil, err
+		}
+		return cursor.page(logs, limit, nil)
+	}
+	if f.begin == rpc.PendingBlockNumber.Int64() {
+		return nil, nil, errors.New("pending logs can't be paginated")
+	}
+	if err := f.resolveRange(ctx); err != nil {
+		return nil, nil, err
+	}
+	if cursor != nil {
+		if cursor.Block < uint64(f.begin) || cursor.Block > uint64(f.end) {
+			return nil, nil, errors.New("cursor outside of the filtered range")
+		}
+		f.begin = int64(cursor.Block)
+	}
+	if f.begin > f.end {
+		return nil, nil, nil
+	}
+	// Cap the range searched by the page, resuming at the first block beyond
+	var resume *LogCursor
+	if rangeLimit := f.sys.cfg.RangeLimit; rangeLimit > 0 && uint64(f.end-f.begin) >= rangeLimit {
+		f.end = f.begin + int64(rangeLimit) - 1
+		resume = &LogCursor{Block: uint64(f.end) + 1}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		logChan, errChan = f.rangeLogsAsync(ctx)
+		logs             []*types.Log
+	)
+	for {
+		select {
+		case log := <-logChan:
+			if cursor != nil && log.BlockNumber == cursor.Block && log.Index < cursor.LogIndex {
+				continue
+			}
+			logs = append(logs, log)
+			// Stop once the first log beyond the page was found
+			if len(logs) > limit {
+				return cursor.page(logs, limit, resume)
+			}
+		case err := <-errChan:
+			if err != nil {
+				return nil, nil, err
+			}
+			return cursor.page(logs, limit, resume)
+		}
+	}
+}
 
+// startSpan starts the tracing span of a query, describing the filter criteria.
+func (f *Filter) startSpan(ctx context.Context, name string) (context.Context, *tracing.Span) {
+	ctx, span := tracing.StartSpan(ctx, name, tracing.Int("addresses", len(f.addresses)), tracing.Int("topics", len(f.topics)))
+	if span != nil {
+		if f.block != nil {
+			span.SetAttributes(tracing.String("block", f.block.Hex()))
+		} else {
+			span.SetAttributes(tracing.String("from", rpc.BlockNumber(f.begin).String()), tracing.String("to", rpc.BlockNumber(f.end).String()))
+		}
+	}
+	return ctx, span
+}
+
+// blockHeader returns the header of the block of a single block filter.
+func (f *Filter) blockHeader(ctx context.Context) (*types.Header, error) {
+	header, err := f.sys.backend.HeaderByHash(ctx, *f.block)
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		return nil, errors.New("unknown block")
+	}
+	return header, nil
+}
+
+// resolveRange resolves the block tags delimiting the range of the filter into
+// block numbers, a pending end being resolved to the latest block.
+func (f *Filter) resolveRange(ctx context.Context) error {
 	resolveSpecial := func(number int64) (int64, error) {
 		var hdr *types.Header
 		switch number {
@@ -151,31 +402,12 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	var err error
 	// range query need to resolve the special begin/end block number
 	if f.begin, err = resolveSpecial(f.begin); err != nil {
-		return nil, err
+		return err
 	}
 	if f.end, err = resolveSpecial(f.end); err != nil {
-		return nil, err
-	}
-
-	logChan, errChan := f.rangeLogsAsync(ctx)
-	var logs []*types.Log
-	for {
-		select {
-		case log := <-logChan:
-			logs = append(logs, log)
-		case err := <-errChan:
-			if err != nil {
-				// if an error occurs during extraction, we do return the extracted data
-				return logs, err
-			}
-			// Append the pending ones
-			if endPending {
-				pendingLogs := f.pendingLogs()
-				logs = append(logs, pendingLogs...)
-			}
-			return logs, nil
-		}
+		return err
 	}
+	return nil
 }
 
 // rangeLogsAsync retrieves block-range logs that match the filter criteria asynchronously,
@@ -183,7 +415,7 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 func (f *Filter) rangeLogsAsync(ctx context.Context) (chan *types.Log, chan error) {
 	var (
 		logChan = make(chan *types.Log)
-		errChan = make(chan error)
+		errChan = make(chan error, 1) // buffered to not block after the consumer gave up
 	)
 
 	go func() {
@@ -202,15 +434,25 @@ func (f *Filter) rangeLogsAsync(ctx context.Context) (chan *types.Log, chan erro
 			if indexed > end {
 				indexed = end + 1
 			}
-			if err = f.indexedLogs(ctx, indexed-1, logChan); err != nil {
+			_, span := tracing.StartSpan(ctx, "filters.indexedLogs", tracing.Int64("from", f.begin), tracing.Uint64("to", indexed-1))
+			err = f.indexedLogs(ctx, indexed-1, logChan)
+			span.SetError(err)
+			span.End()
+			if err != nil {
 				errChan <- err
 				return
 			}
 		}
 
-		if err := f.unindexedLogs(ctx, end, logChan); err != nil {
-			errChan <- err
-			return
+		if f.begin <= int64(end) {
+			_, span := tracing.StartSpan(ctx, "filters.unindexedLogs", tracing.Int64("from", f.begin), tracing.Uint64("to", end))
+			err = f.unindexedLogs(ctx, end, logChan)
+			span.SetError(err)
+			span.End()
+			if err != nil {
+				errChan <- err
+				return
+			}
 		}
 
 		errChan <- nil
@@ -256,7 +498,11 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64, logChan chan *type
 				return err
 			}
 			for _, log := range found {
-				logChan <- log
+				select {
+				case logChan <- log:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
 			}
 
 		case <-ctx.Done():
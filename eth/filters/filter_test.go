@@ -19,6 +19,7 @@ package filters
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"math/big"
 	"strings"
 	"testing"
@@ -369,3 +370,97 @@ func TestFilters(t *testing.T) {
 		}
 	})
 }
+
+// TestLogLimits tests that range queries exceeding the configured limits fail,
+// suggesting a narrower range, and that paginated queries return all the logs.
+func TestLogLimits(t *testing.T) {
+	var (
+		db    = rawdb.NewMemoryDatabase()
+		addr  = common.BytesToAddress([]byte("jeff"))
+		gspec = &core.Genesis{
+			BaseFee: big.NewInt(params.InitialBaseFee),
+			Config:  params.TestChainConfig,
+		}
+	)
+	// Blocks 2, 3 and 5 hold two logs each
+	_, chain, receipts := core.GenerateChainWithGenesis(gspec, ethash.NewFaker(), 9, func(i int, gen *core.BlockGen) {
+		if i != 1 && i != 2 && i != 4 {
+			return
+		}
+		receipt := types.NewReceipt(nil, false, 0)
+		receipt.Logs = []*types.Log{{Address: addr}, {Address: addr}}
+		receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+		gen.AddUncheckedReceipt(receipt)
+		gen.AddUncheckedTx(types.NewTransaction(999, common.HexToAddress("0x999"), big.NewInt(999), 999, gen.BaseFee(), nil))
+	})
+	gspec.MustCommit(db, trie.NewDatabase(db, trie.HashDefaults))
+	for i, block := range chain {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteHeadBlockHash(db, block.Hash())
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+	}
+	addrs := []common.Address{addr}
+
+	// Queries over the limits should suggest the longest range within them
+	var limitErr *LimitExceededError
+	_, sys := newTestFilterSystem(t, db, Config{ResultLimit: 3})
+	if _, err := sys.NewRangeFilter(0, 9, addrs, nil).Logs(context.Background()); !errors.As(err, &limitErr) || limitErr.From != 0 || limitErr.To != 2 {
+		t.Errorf("result limit: have error %v, want range [0, 2]", err)
+	}
+	if logs, err := sys.NewRangeFilter(0, 2, addrs, nil).Logs(context.Background()); err != nil || len(logs) != 2 {
+		t.Errorf("result limit: suggested range returned %d logs, error %v", len(logs), err)
+	}
+	_, sys = newTestFilterSystem(t, db, Config{RangeLimit: 5})
+	if _, err := sys.NewRangeFilter(0, 9, addrs, nil).Logs(context.Background()); !errors.As(err, &limitErr) || limitErr.From != 0 || limitErr.To != 4 {
+		t.Errorf("range limit: have error %v, want range [0, 4]", err)
+	}
+	if logs, err := sys.NewRangeFilter(0, 4, addrs, nil).Logs(context.Background()); err != nil || len(logs) != 4 {
+		t.Errorf("range limit: suggested range returned %d logs, error %v", len(logs), err)
+	}
+	// Paginated queries should return all logs, resuming within blocks
+	_, sys = newTestFilterSystem(t, db, Config{RangeLimit: 4})
+	var (
+		cursor *LogCursor
+		all    []*types.Log
+		pages  int
+	)
+	for {
+		logs, next, err := sys.NewRangeFilter(0, 9, addrs, nil).LogsPage(context.Background(), cursor, 3)
+		if err != nil {
+			t.Fatalf("page %d: %v", pages, err)
+		}
+		if len(logs) > 3 {
+			t.Fatalf("page %d: %d logs over the limit", pages, len(logs))
+		}
+		all, pages = append(all, logs...), pages+1
+		if next == nil {
+			break
+		}
+		// Cursors should survive encoding
+		enc, _ := next.MarshalText()
+		cursor = new(LogCursor)
+		if err := cursor.UnmarshalText(enc); err != nil || *cursor != *next {
+			t.Fatalf("page %d: cursor %v doesn't round-trip: %v", pages, next, err)
+		}
+	}
+	want := []LogCursor{{2, 0}, {2, 1}, {3, 0}, {3, 1}, {5, 0}, {5, 1}}
+	if len(all) != len(want) {
+		t.Fatalf("paginated logs: have %d, want %d", len(all), len(want))
+	}
+	for i, log := range all {
+		if have := (LogCursor{log.BlockNumber, log.Index}); have != want[i] {
+			t.Errorf("log %d: have position %v, want %v", i, have, want[i])
+		}
+	}
+	// Single block queries should paginate within the block
+	filter := sys.NewBlockFilter(chain[1].Hash(), addrs, nil)
+	logs, next, err := filter.LogsPage(context.Background(), nil, 1)
+	if err != nil || len(logs) != 1 || next == nil || *next != (LogCursor{2, 1}) {
+		t.Fatalf("block page 0: have %d logs, cursor %v, error %v", len(logs), next, err)
+	}
+	logs, next, err = filter.LogsPage(context.Background(), next, 1)
+	if err != nil || len(logs) != 1 || logs[0].Index != 1 || next != nil {
+		t.Fatalf("block page 1: have %d logs, cursor %v, error %v", len(logs), next, err)
+	}
+}
@@ -224,6 +224,11 @@ func ServiceGetBlockBodiesQuery(chain *core.BlockChain, query GetBlockBodiesRequ
 			lookups >= 2*maxBodiesServe {
 			break
 		}
+		// Pruned history is unavailable, cut the response short so the remote
+		// side retrieves the remainder from someone else.
+		if chain.HistoryPruned(hash) {
+			break
+		}
 		if data := chain.GetBodyRLP(hash); len(data) != 0 {
 			bodies = append(bodies, data)
 			bytes += len(data)
@@ -255,6 +260,10 @@ func ServiceGetReceiptsQuery(chain *core.BlockChain, query GetReceiptsRequest) [
 			lookups >= 2*maxReceiptsServe {
 			break
 		}
+		// Pruned history is unavailable, don't serve empty receipts in its place
+		if chain.HistoryPruned(hash) {
+			break
+		}
 		// Retrieve the requested block's receipts
 		results := chain.GetReceiptsByHash(hash)
 		if results == nil {
@@ -0,0 +1,275 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// replicaPollInterval is the frequency of reloading the primary's database
+	// if no head subscription can be established, as well as the frequency of
+	// retrying the subscription.
+	replicaPollInterval = 3 * time.Second
+
+	// replicaReloadInterval is the minimum time between two reloads of the
+	// primary's database, head announcements arriving faster are coalesced.
+	replicaReloadInterval = time.Second
+
+	// replicaDialTimeout is the maximum time allowed for connecting to the primary.
+	replicaDialTimeout = 5 * time.Second
+)
+
+// openReplicaDatabase opens the chain database of the primary node configured
+// as the replicated one, see ethconfig.Config.ReplicaOf. The checkpoints of the
+// primary's database are created in the local data directory.
+func openReplicaDatabase(stack *node.Node, config *ethconfig.Config) (*rawdb.ReplicaDatabase, error) {
+	var (
+		instance    = filepath.Join(config.ReplicaOf, "geth")
+		ancient     = config.ReplicaAncient
+		checkpoints = stack.ResolvePath("replica")
+	)
+	if checkpoints == "" {
+		return nil, errors.New("read replicas need a data directory for the database checkpoints")
+	}
+	switch {
+	case ancient == "":
+		ancient = filepath.Join(instance, "chaindata", "ancient")
+	case !filepath.IsAbs(ancient):
+		ancient = filepath.Join(instance, ancient)
+	}
+	return rawdb.NewReplicaDatabase(rawdb.OpenOptions{
+		Directory:         filepath.Join(instance, "chaindata"),
+		AncientsDirectory: ancient,
+		Namespace:         "eth/db/chaindata/",
+		Cache:             config.DatabaseCache,
+		Handles:           config.DatabaseHandles,
+	}, checkpoints)
+}
+
+// replicaFollower keeps a read replica in sync with the primary node whose
+// database it shares. Whenever the primary announces a new head, the database
+// is reopened and the chain is moved to the new head.
+type replicaFollower struct {
+	db       *rawdb.ReplicaDatabase
+	chain    *core.BlockChain
+	endpoint string
+
+	client *rpc.Client // Connection to the primary, nil if not connected
+	lock   sync.Mutex  // Protects the client
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newReplicaFollower creates a follower of the primary node reachable at the
+// given endpoint, defaulting to the IPC socket in the primary's data directory.
+func newReplicaFollower(db *rawdb.ReplicaDatabase, chain *core.BlockChain, config *ethconfig.Config) *replicaFollower {
+	endpoint := config.ReplicaEndpoint
+	if endpoint == "" {
+		endpoint = filepath.Join(config.ReplicaOf, "geth.ipc")
+	}
+	return &replicaFollower{
+		db:       db,
+		chain:    chain,
+		endpoint: endpoint,
+		quit:     make(chan struct{}),
+	}
+}
+
+// start launches the background goroutine following the primary.
+func (f *replicaFollower) start() {
+	f.wg.Add(1)
+	go f.loop()
+}
+
+// stop terminates the follower and disconnects from the primary.
+func (f *replicaFollower) stop() {
+	close(f.quit)
+	f.wg.Wait()
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.client != nil {
+		f.client.Close()
+		f.client = nil
+	}
+}
+
+// loop follows the head of the primary, preferably by subscribing to its new
+// heads and falling back to polling its database. At most one reload runs at a
+// time, the ones requested meanwhile or too soon after are merged into one.
+func (f *replicaFollower) loop() {
+	defer f.wg.Done()
+
+	ticker := time.NewTicker(replicaPollInterval)
+	defer ticker.Stop()
+
+	var (
+		heads = make(chan *types.Header, 16)
+		sub   *rpc.ClientSubscription
+		errc  <-chan error
+
+		syncing  chan struct{}    // Closed when the running reload finishes, nil if idle
+		throttle <-chan time.Time // Fires when the next reload is allowed, nil if not waiting
+		pending  bool             // Whether a reload was requested while busy or throttled
+		reloaded time.Time        // Start of the last reload
+	)
+	defer func() {
+		if sub != nil {
+			sub.Unsubscribe()
+		}
+		if syncing != nil {
+			<-syncing
+		}
+	}()
+	reload := func() {
+		if syncing != nil || throttle != nil {
+			pending = true
+			return
+		}
+		if wait := replicaReloadInterval - time.Since(reloaded); wait > 0 {
+			pending, throttle = true, time.After(wait)
+			return
+		}
+		pending, reloaded = false, time.Now()
+
+		done := make(chan struct{})
+		syncing = done
+		go func() {
+			defer close(done)
+			f.sync()
+		}()
+	}
+	subscribe := func() {
+		var err error
+		if sub, err = f.subscribe(heads); err != nil {
+			log.Debug("Failed to subscribe to primary heads", "endpoint", f.endpoint, "err", err)
+			return
+		}
+		errc = sub.Err()
+		log.Info("Following primary node", "endpoint", f.endpoint)
+	}
+	reloaded = time.Now()
+	f.sync()
+	subscribe()
+
+	for {
+		select {
+		case <-heads:
+			// Coalesce the queued up announcements into a single reload
+			for len(heads) > 0 {
+				<-heads
+			}
+			reload()
+
+		case <-syncing:
+			syncing = nil
+			if pending {
+				reload()
+			}
+
+		case <-throttle:
+			throttle = nil
+			if pending {
+				reload()
+			}
+
+		case err := <-errc:
+			log.Warn("Lost primary head subscription, polling", "endpoint", f.endpoint, "err", err)
+			sub, errc = nil, nil
+
+		case <-ticker.C:
+			if sub == nil {
+				reload()
+				subscribe()
+			}
+
+		case <-f.quit:
+			return
+		}
+	}
+}
+
+// subscribe connects to the primary and subscribes to its new heads.
+func (f *replicaFollower) subscribe(heads chan *types.Header) (*rpc.ClientSubscription, error) {
+	client, err := f.dial()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), replicaDialTimeout)
+	defer cancel()
+	return client.EthSubscribe(ctx, heads, "newHeads")
+}
+
+// dial returns the connection to the primary, establishing it if needed.
+func (f *replicaFollower) dial() (*rpc.Client, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.client != nil {
+		return f.client, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), replicaDialTimeout)
+	defer cancel()
+
+	client, err := rpc.DialContext(ctx, f.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	f.client = client
+	return client, nil
+}
+
+// sync reopens the primary's database and moves the chain to its head.
+func (f *replicaFollower) sync() {
+	if err := f.db.Reload(); err != nil {
+		log.Warn("Failed to reload primary database", "err", err)
+		return
+	}
+	if err := f.chain.ReloadHead(); err != nil {
+		log.Warn("Failed to follow primary head", "err", err)
+	}
+}
+
+// sendTx forwards a transaction to the primary, read replicas don't take part
+// in the network themselves.
+func (f *replicaFollower) sendTx(ctx context.Context, tx *types.Transaction) error {
+	client, err := f.dial()
+	if err != nil {
+		return fmt.Errorf("primary node unreachable: %v", err)
+	}
+	data, err := tx.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return client.CallContext(ctx, nil, "eth_sendRawTransaction", hexutil.Bytes(data))
+}
@@ -35,6 +35,10 @@ import (
 	"github.com/ethereum/go-ethereum/trie"
 )
 
+// errHistoricalStateUnavailable is returned if the state of a block is pruned
+// and can't be regenerated.
+var errHistoricalStateUnavailable = errors.New("historical state unavailable")
+
 // noopReleaser is returned in case there is no operation expected
 // for releasing state.
 var noopReleaser = tracers.StateReleaseFunc(func() {})
@@ -176,16 +180,79 @@ func (eth *Ethereum) hashState(ctx context.Context, block *types.Block, reexec u
 	return statedb, func() { triedb.Dereference(block.Root()) }, nil
 }
 
-func (eth *Ethereum) pathState(block *types.Block) (*state.StateDB, func(), error) {
+func (eth *Ethereum) pathState(ctx context.Context, block *types.Block, reexec uint64, base *state.StateDB) (*state.StateDB, func(), error) {
 	// Check if the requested state is available in the live chain.
 	statedb, err := eth.blockchain.StateAt(block.Root())
 	if err == nil {
 		return statedb, noopReleaser, nil
 	}
-	// TODO historic state is not supported in path-based scheme.
-	// Fully archive node in pbss will be implemented by relying
-	// on state history, but needs more work on top.
-	return nil, nil, errors.New("historical state not available in path scheme yet")
+	// The path-based scheme only retains the recent states (the in-memory layers
+	// on top of the persistent one), which can't be pinned nor recreated in an
+	// ephemeral database. Regenerate the requested state in memory on top of the
+	// closest available ancestor instead, without committing anything. There's
+	// no point in looking for one deeper than the retained layers, so blocks not
+	// descending from a recent state can't be served, whatever reexec says.
+	var (
+		current = block
+		blocks  []*types.Block
+		report  = true
+	)
+	if base != nil {
+		statedb, report = base, false
+		blocks = append(blocks, block)
+	} else {
+		limit := reexec
+		if limit > core.TriesInMemory {
+			limit = core.TriesInMemory
+		}
+		for i := uint64(0); i < limit; i++ {
+			if err := ctx.Err(); err != nil {
+				return nil, nil, err
+			}
+			if current.NumberU64() == 0 {
+				return nil, nil, fmt.Errorf("%w: genesis state is missing", errHistoricalStateUnavailable)
+			}
+			blocks = append(blocks, current)
+			parent := eth.blockchain.GetBlock(current.ParentHash(), current.NumberU64()-1)
+			if parent == nil {
+				return nil, nil, fmt.Errorf("missing block %v %d", current.ParentHash(), current.NumberU64()-1)
+			}
+			current = parent
+
+			statedb, err = eth.blockchain.StateAt(current.Root())
+			if err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: block %d is not within the %d recent states retained by the path scheme", errHistoricalStateUnavailable, block.NumberU64(), core.TriesInMemory)
+		}
+	}
+	var (
+		start  = time.Now()
+		logged time.Time
+	)
+	for i := len(blocks) - 1; i >= 0; i-- {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		// Print progress logs if long enough time elapsed
+		if time.Since(logged) > 8*time.Second && report {
+			log.Info("Regenerating historical state", "block", blocks[i].NumberU64(), "target", block.NumberU64(), "remaining", i, "elapsed", time.Since(start))
+			logged = time.Now()
+		}
+		if _, _, _, _, err := eth.blockchain.Processor().Process(blocks[i], statedb, vm.Config{}); err != nil {
+			return nil, nil, fmt.Errorf("processing block %d failed: %v", blocks[i].NumberU64(), err)
+		}
+		// Finalize the state in memory, the live database must stay untouched
+		if root := statedb.IntermediateRoot(eth.blockchain.Config().IsEIP158(blocks[i].Number())); root != blocks[i].Root() {
+			return nil, nil, fmt.Errorf("state root mismatch after block %d: have %x, want %x", blocks[i].NumberU64(), root, blocks[i].Root())
+		}
+	}
+	if report {
+		log.Info("Historical state regenerated", "block", block.NumberU64(), "elapsed", time.Since(start))
+	}
+	return statedb, noopReleaser, nil
 }
 
 // stateAtBlock retrieves the state database associated with a certain block.
@@ -214,7 +281,7 @@ func (eth *Ethereum) stateAtBlock(ctx context.Context, block *types.Block, reexe
 	if eth.blockchain.TrieDB().Scheme() == rawdb.HashScheme {
 		return eth.hashState(ctx, block, reexec, base, readOnly, preferDisk)
 	}
-	return eth.pathState(block)
+	return eth.pathState(ctx, block, reexec, base)
 }
 
 // stateAtTransaction returns the execution environment of a certain transaction.
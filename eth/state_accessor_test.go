@@ -0,0 +1,100 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the path scheme regenerates the states of the blocks on top of the
+// retained recent states, and reports the older ones as unavailable instead of
+// re-executing the chain from far back.
+func TestPathStateAtBlock(t *testing.T) {
+	var (
+		key, _  = crypto.GenerateKey()
+		addr    = crypto.PubkeyToAddress(key.PublicKey)
+		signer  = types.HomesteadSigner{}
+		genesis = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{addr: {Balance: big.NewInt(params.Ether)}},
+		}
+		engine = ethash.NewFaker()
+		n      = 2*core.TriesInMemory + 10
+	)
+	// Generate the canonical chain and a side block on top of its parent head,
+	// which is never imported.
+	generate := func(fork bool) []*types.Block {
+		_, blocks, _ := core.GenerateChainWithGenesis(genesis, engine, n, func(i int, gen *core.BlockGen) {
+			to := common.Address{0x01}
+			if fork && i == n-1 {
+				to = common.Address{0x02}
+			}
+			tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(addr), to, big.NewInt(1), params.TxGas, gen.BaseFee(), nil), signer, key)
+			gen.AddTx(tx)
+		})
+		return blocks
+	}
+	blocks, side := generate(false), generate(true)[n-1]
+
+	chain, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), core.DefaultCacheConfigWithScheme(rawdb.PathScheme), genesis, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	eth := &Ethereum{blockchain: chain}
+
+	// Recent states are served from the live database
+	head := blocks[n-1]
+	statedb, _, err := eth.pathState(context.Background(), head, 1000, nil)
+	if err != nil {
+		t.Fatalf("failed to retrieve head state: %v", err)
+	}
+	if nonce := statedb.GetNonce(addr); nonce != uint64(n) {
+		t.Errorf("head nonce mismatch: have %d, want %d", nonce, n)
+	}
+	// States below the retained ones are unavailable, no matter the reexec
+	old := blocks[n-core.TriesInMemory-5]
+	if _, _, err := eth.pathState(context.Background(), old, 1000, nil); !errors.Is(err, errHistoricalStateUnavailable) {
+		t.Fatalf("old state error mismatch: have %v, want %v", err, errHistoricalStateUnavailable)
+	}
+	// Blocks on top of a retained state are regenerated in memory
+	statedb, _, err = eth.pathState(context.Background(), side, 1000, nil)
+	if err != nil {
+		t.Fatalf("failed to regenerate side block state: %v", err)
+	}
+	if balance := statedb.GetBalance(common.Address{0x02}); balance.Cmp(common.Big1) != 0 {
+		t.Errorf("side block balance mismatch: have %v, want %v", balance, common.Big1)
+	}
+	if _, err := chain.StateAt(side.Root()); err == nil {
+		t.Error("regenerated state persisted")
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tokens
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+const (
+	defaultTransfersPageSize = 100  // Transfer logs returned per page if no limit is requested
+	maxTransfersPageSize     = 1000 // Maximum number of transfer logs returned per page
+)
+
+var (
+	// errIndexDisabled is returned when querying the token index while it isn't
+	// maintained.
+	errIndexDisabled = errors.New("token index not enabled")
+
+	// errIndexStopped is returned when querying the token index after the
+	// indexing stopped, as it no longer follows the chain.
+	errIndexStopped = errors.New("token indexing stopped")
+)
+
+// checkIndex returns an error if the token index can't be queried.
+func checkIndex(db ethdb.KeyValueReader) error {
+	if _, _, ok := rawdb.ReadTokenIndexHead(db); !ok {
+		return errIndexDisabled
+	}
+	if number, stopped := rawdb.ReadTokenIndexStopped(db); stopped {
+		return fmt.Errorf("%w: receipts of block %d unavailable", errIndexStopped, number)
+	}
+	return nil
+}
+
+// Balances retrieves the non-zero token balances of a holder.
+func Balances(db ethdb.Database, holder common.Address) ([]*rawdb.TokenBalance, error) {
+	if err := checkIndex(db); err != nil {
+		return nil, err
+	}
+	return rawdb.ReadTokenBalances(db, holder), nil
+}
+
+// Transfers retrieves the canonical token transfers of a holder logged by at
+// most limit logs, newest first, starting from the position of the cursor
+// returned by the previous page, or from the chain head if nil. If more logs
+// remain, the cursor to resume from is returned along the page.
+func Transfers(db ethdb.Database, holder common.Address, cursor *filters.LogCursor, limit int) ([]*rawdb.TokenTransfer, *filters.LogCursor, error) {
+	if err := checkIndex(db); err != nil {
+		return nil, nil, err
+	}
+	if limit <= 0 {
+		limit = defaultTransfersPageSize
+	}
+	if limit > maxTransfersPageSize {
+		limit = maxTransfersPageSize
+	}
+	number, index := uint64(math.MaxUint64), uint32(math.MaxUint32)
+	if cursor != nil {
+		number, index = cursor.Block, uint32(cursor.LogIndex)
+	}
+	transfers := rawdb.ReadTokenTransfers(db, holder, number, index, limit+1)
+
+	// A batch transfer log holds several transfers, cut the page at the first
+	// transfer of the log beyond the limit
+	for i, logs := 0, 0; i < len(transfers); i++ {
+		if i == 0 || transfers[i].BlockNumber != transfers[i-1].BlockNumber || transfers[i].LogIndex != transfers[i-1].LogIndex {
+			if logs++; logs > limit {
+				return transfers[:i], &filters.LogCursor{Block: transfers[i].BlockNumber, LogIndex: transfers[i].LogIndex}, nil
+			}
+		}
+	}
+	return transfers, nil, nil
+}
+
+// API serves the token index over RPC.
+type API struct {
+	db ethdb.Database
+}
+
+// NewAPI creates the RPC service of the token index stored in db.
+func NewAPI(db ethdb.Database) *API {
+	return &API{db: db}
+}
+
+// Balance is the balance of an account in a token, as returned over RPC.
+type Balance struct {
+	Token    common.Address `json:"token"`
+	Standard string         `json:"standard"`
+	ID       *hexutil.Big   `json:"id,omitempty"` // Identifier of the token, omitted for ERC-20
+	Balance  *hexutil.Big   `json:"balance"`
+}
+
+// Transfer is a token transfer, as returned over RPC.
+type Transfer struct {
+	Token           common.Address `json:"token"`
+	Standard        string         `json:"standard"`
+	From            common.Address `json:"from"`
+	To              common.Address `json:"to"`
+	ID              *hexutil.Big   `json:"id,omitempty"` // Identifier of the token, omitted for ERC-20
+	Value           *hexutil.Big   `json:"value"`
+	BlockNumber     hexutil.Uint64 `json:"blockNumber"`
+	BlockHash       common.Hash    `json:"blockHash"`
+	TransactionHash common.Hash    `json:"transactionHash"`
+	LogIndex        hexutil.Uint   `json:"logIndex"`
+}
+
+// TransfersPage is a page of the results of a token transfer query.
+type TransfersPage struct {
+	Transfers []*Transfer        `json:"transfers"`
+	Cursor    *filters.LogCursor `json:"cursor"` // Position to resume the query from, nil if complete
+}
+
+// GetTokenBalances returns the non-zero balances of an account in the ERC-20
+// tokens and in each of the ERC-721 and ERC-1155 tokens it holds.
+func (api *API) GetTokenBalances(ctx context.Context, address common.Address) ([]*Balance, error) {
+	balances, err := Balances(api.db, address)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*Balance, len(balances))
+	for i, balance := range balances {
+		result[i] = &Balance{
+			Token:    balance.Token,
+			Standard: balance.Standard.String(),
+			Balance:  (*hexutil.Big)(balance.Balance),
+		}
+		if balance.Standard != rawdb.TokenERC20 {
+			result[i].ID = (*hexutil.Big)(balance.ID)
+		}
+	}
+	return result, nil
+}
+
+// GetTokenTransfers returns a page of the canonical token transfers from and to
+// an account, newest first. The page starts from the position of the cursor
+// returned by the previous page, or from the chain head if nil, and holds the
+// transfers of at most limit logs.
+func (api *API) GetTokenTransfers(ctx context.Context, address common.Address, cursor *filters.LogCursor, limit *hexutil.Uint) (*TransfersPage, error) {
+	var n int
+	if limit != nil {
+		n = int(*limit)
+	}
+	transfers, next, err := Transfers(api.db, address, cursor, n)
+	if err != nil {
+		return nil, err
+	}
+	page := &TransfersPage{Transfers: make([]*Transfer, len(transfers)), Cursor: next}
+	for i, transfer := range transfers {
+		page.Transfers[i] = &Transfer{
+			Token:           transfer.Token,
+			Standard:        transfer.Standard.String(),
+			From:            transfer.From,
+			To:              transfer.To,
+			Value:           (*hexutil.Big)(transfer.Value),
+			BlockNumber:     hexutil.Uint64(transfer.BlockNumber),
+			BlockHash:       transfer.BlockHash,
+			TransactionHash: transfer.TxHash,
+			LogIndex:        hexutil.Uint(transfer.LogIndex),
+		}
+		if transfer.Standard != rawdb.TokenERC20 {
+			page.Transfers[i].ID = (*hexutil.Big)(transfer.ID)
+		}
+	}
+	return page, nil
+}
@@ -0,0 +1,161 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tokens
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// The ERC-20 and ERC-721 transfer events share their signature, they differ by
+// the token amount being indexed or not.
+const (
+	erc20JSON = `[{"anonymous":false,"inputs":[{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":false,"name":"value","type":"uint256"}],"name":"Transfer","type":"event"}]`
+
+	erc721JSON = `[{"anonymous":false,"inputs":[{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":true,"name":"tokenId","type":"uint256"}],"name":"Transfer","type":"event"}]`
+
+	erc1155JSON = `[
+		{"anonymous":false,"inputs":[{"indexed":true,"name":"operator","type":"address"},{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":false,"name":"id","type":"uint256"},{"indexed":false,"name":"value","type":"uint256"}],"name":"TransferSingle","type":"event"},
+		{"anonymous":false,"inputs":[{"indexed":true,"name":"operator","type":"address"},{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":false,"name":"ids","type":"uint256[]"},{"indexed":false,"name":"values","type":"uint256[]"}],"name":"TransferBatch","type":"event"}
+	]`
+)
+
+var (
+	erc20ABI   = mustParseABI(erc20JSON)
+	erc721ABI  = mustParseABI(erc721JSON)
+	erc1155ABI = mustParseABI(erc1155JSON)
+
+	transferTopic       = erc20ABI.Events["Transfer"].ID
+	transferSingleTopic = erc1155ABI.Events["TransferSingle"].ID
+	transferBatchTopic  = erc1155ABI.Events["TransferBatch"].ID
+)
+
+func mustParseABI(definition string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(definition))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// mayContainTransfers reports whether the bloom filter of a block matches any
+// of the token transfer events.
+func mayContainTransfers(bloom types.Bloom) bool {
+	return bloom.Test(transferTopic.Bytes()) || bloom.Test(transferSingleTopic.Bytes()) || bloom.Test(transferBatchTopic.Bytes())
+}
+
+// decodeTransfers decodes the token transfers of a log, nil if it isn't a well
+// formed transfer event of any supported standard.
+func decodeTransfers(log *types.Log) []*rawdb.TokenTransfer {
+	if len(log.Topics) == 0 {
+		return nil
+	}
+	transfer := func(standard rawdb.TokenStandard, from, to common.Address, id, value *big.Int) *rawdb.TokenTransfer {
+		return &rawdb.TokenTransfer{
+			Token:    log.Address,
+			Standard: standard,
+			From:     from,
+			To:       to,
+			ID:       id,
+			Value:    value,
+			TxHash:   log.TxHash,
+			LogIndex: log.Index,
+		}
+	}
+	switch {
+	case log.Topics[0] == transferTopic && len(log.Topics) == 3:
+		var event struct {
+			From  common.Address
+			To    common.Address
+			Value *big.Int
+		}
+		if !unpackLog(erc20ABI, "Transfer", &event, log) {
+			return nil
+		}
+		return []*rawdb.TokenTransfer{transfer(rawdb.TokenERC20, event.From, event.To, new(big.Int), event.Value)}
+
+	case log.Topics[0] == transferTopic && len(log.Topics) == 4:
+		var event struct {
+			From    common.Address
+			To      common.Address
+			TokenId *big.Int
+		}
+		if !unpackLog(erc721ABI, "Transfer", &event, log) {
+			return nil
+		}
+		return []*rawdb.TokenTransfer{transfer(rawdb.TokenERC721, event.From, event.To, event.TokenId, big.NewInt(1))}
+
+	case log.Topics[0] == transferSingleTopic:
+		var event struct {
+			Operator common.Address
+			From     common.Address
+			To       common.Address
+			Id       *big.Int
+			Value    *big.Int
+		}
+		if !unpackLog(erc1155ABI, "TransferSingle", &event, log) {
+			return nil
+		}
+		return []*rawdb.TokenTransfer{transfer(rawdb.TokenERC1155, event.From, event.To, event.Id, event.Value)}
+
+	case log.Topics[0] == transferBatchTopic:
+		var event struct {
+			Operator common.Address
+			From     common.Address
+			To       common.Address
+			Ids      []*big.Int
+			Values   []*big.Int
+		}
+		if !unpackLog(erc1155ABI, "TransferBatch", &event, log) || len(event.Ids) != len(event.Values) {
+			return nil
+		}
+		transfers := make([]*rawdb.TokenTransfer, len(event.Ids))
+		for i := range event.Ids {
+			transfers[i] = transfer(rawdb.TokenERC1155, event.From, event.To, event.Ids[i], event.Values[i])
+		}
+		return transfers
+	}
+	return nil
+}
+
+// unpackLog decodes the fields of an event from the data and topics of a log,
+// the same way as the contract bindings do.
+func unpackLog(contract abi.ABI, name string, out interface{}, log *types.Log) bool {
+	event := contract.Events[name]
+	var indexed abi.Arguments
+	for _, arg := range event.Inputs {
+		if arg.Indexed {
+			indexed = append(indexed, arg)
+		}
+	}
+	if len(log.Topics) != len(indexed)+1 {
+		return false
+	}
+	if len(indexed) < len(event.Inputs) {
+		if err := contract.UnpackIntoInterface(out, name, log.Data); err != nil {
+			return false
+		}
+	} else if len(log.Data) > 0 {
+		return false
+	}
+	return abi.ParseTopics(out, indexed, log.Topics[1:]) == nil
+}
@@ -0,0 +1,347 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package tokens implements the index of the ERC-20, ERC-721 and ERC-1155 token
+// transfers and balances of the accounts.
+package tokens
+
+import (
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Chain is the blockchain the token transfers are indexed from.
+type Chain interface {
+	CurrentBlock() *types.Header
+	GetHeader(hash common.Hash, number uint64) *types.Header
+	GetCanonicalHash(number uint64) common.Hash
+	GetReceiptsByHash(hash common.Hash) types.Receipts
+	HistoryTail() uint64
+	SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription
+	SubscribeChainSideEvent(ch chan<- core.ChainSideEvent) event.Subscription
+}
+
+// Indexer follows the canonical chain, indexing the token transfers of its
+// blocks by the holders involved and maintaining their token balances. The
+// transfers of the blocks leaving the canonical chain are reverted.
+//
+// The balances are the sums of the logged transfers, they are only exact for
+// contracts logging all their mints and burns as transfers. On nodes with
+// pruned history, they only sum the transfers since the history tail.
+type Indexer struct {
+	db    ethdb.Database
+	chain Chain
+
+	trigger chan struct{} // Notifies the indexer of a chain update, never blocking the chain feeds
+	failed  bool          // Whether the indexing stopped due to unavailable receipts
+	quit    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewIndexer creates a token transfer indexer over the chain stored in db.
+func NewIndexer(db ethdb.Database, chain Chain) *Indexer {
+	return &Indexer{
+		db:      db,
+		chain:   chain,
+		trigger: make(chan struct{}, 1),
+		quit:    make(chan struct{}),
+	}
+}
+
+// Start starts indexing the chain in the background, from the first block with
+// receipts if the index doesn't exist yet. Indexing stopped by unavailable
+// receipts is retried, they might have been retrieved in the meantime.
+func (idx *Indexer) Start() {
+	rawdb.DeleteTokenIndexStopped(idx.db)
+
+	idx.wg.Add(2)
+	go idx.eventLoop()
+	go idx.indexLoop()
+}
+
+// Stop terminates the indexer, the indexed blocks are kept.
+func (idx *Indexer) Stop() {
+	close(idx.quit)
+	idx.wg.Wait()
+}
+
+// eventLoop notifies the indexer of new canonical heads and of the blocks which
+// left the canonical chain.
+func (idx *Indexer) eventLoop() {
+	defer idx.wg.Done()
+
+	var (
+		headCh = make(chan core.ChainHeadEvent, 10)
+		sideCh = make(chan core.ChainSideEvent, 10)
+	)
+	headSub := idx.chain.SubscribeChainHeadEvent(headCh)
+	defer headSub.Unsubscribe()
+	sideSub := idx.chain.SubscribeChainSideEvent(sideCh)
+	defer sideSub.Unsubscribe()
+
+	for {
+		select {
+		case <-headCh:
+		case <-sideCh:
+		case <-headSub.Err():
+			return
+		case <-sideSub.Err():
+			return
+		case <-idx.quit:
+			return
+		}
+		select {
+		case idx.trigger <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// indexLoop brings the index up to the canonical head whenever it changes.
+func (idx *Indexer) indexLoop() {
+	defer idx.wg.Done()
+
+	for {
+		for idx.sync() {
+		}
+		select {
+		case <-idx.trigger:
+		case <-idx.quit:
+			return
+		}
+	}
+}
+
+// sync reverts the indexed blocks which left the canonical chain, then indexes
+// the canonical blocks up to the head. It reports whether the canonical chain
+// changed during the update, needing another one.
+func (idx *Indexer) sync() bool {
+	if idx.failed {
+		return false
+	}
+	number, hash, ok := rawdb.ReadTokenIndexHead(idx.db)
+	u := newIndexUpdate(idx.db)
+
+	// Start a new index from the first block with receipts, dropping the
+	// balances of an index discarded while they were being deleted
+	next := number + 1
+	if !ok {
+		rawdb.DeleteTokenIndex(idx.db)
+
+		next = idx.chain.HistoryTail()
+		if next == 0 {
+			log.Info("Indexing token transfers from genesis")
+		} else {
+			log.Warn("Indexing token transfers from history tail, earlier transfers are missing from balances", "tail", next)
+			u.number, u.hash = next-1, idx.chain.GetCanonicalHash(next-1)
+		}
+	}
+
+	// Revert the blocks which left the canonical chain, down to the common
+	// ancestor with the current one
+	for ok && idx.chain.GetCanonicalHash(number) != hash {
+		header := idx.chain.GetHeader(hash, number)
+		if header == nil {
+			log.Error("Token index head unavailable, reindexing", "number", number, "hash", hash)
+			rawdb.DeleteTokenIndex(idx.db)
+			return true
+		}
+		u.revert(rawdb.ReadTokenJournal(idx.db, hash, number))
+		number, hash = number-1, header.ParentHash
+		u.setHead(number, hash)
+	}
+	if u.reverted > 0 {
+		log.Info("Reverted token transfers of side chain", "blocks", u.reverted, "ancestor", number)
+	}
+	// Index the canonical blocks up to the head
+	var (
+		head   = idx.chain.CurrentBlock().Number.Uint64()
+		start  = time.Now()
+		logged = time.Now()
+	)
+	for ; next <= head; next++ {
+		select {
+		case <-idx.quit:
+			u.commit()
+			return false
+		default:
+		}
+		header := idx.chain.GetHeader(idx.chain.GetCanonicalHash(next), next)
+		if header == nil || (next > 0 && header.ParentHash != u.hash) {
+			// The canonical chain changed in the meantime, start over
+			u.commit()
+			return true
+		}
+		if mayContainTransfers(header.Bloom) {
+			receipts := idx.chain.GetReceiptsByHash(header.Hash())
+			if receipts == nil {
+				log.Warn("Stopped indexing token transfers, receipts unavailable", "number", next)
+				u.commit()
+				rawdb.WriteTokenIndexStopped(idx.db, next)
+				idx.failed = true
+				return false
+			}
+			u.apply(header, receipts)
+		}
+		u.setHead(next, header.Hash())
+
+		if u.batch.ValueSize() > ethdb.IdealBatchSize {
+			u.commit()
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Indexing token transfers", "number", next, "head", head, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	u.commit()
+	return false
+}
+
+// balanceKey identifies the balance of a holder in a token.
+type balanceKey struct {
+	holder common.Address
+	token  common.Address
+	id     common.Hash
+}
+
+// balanceDelta is the pending change of a token balance.
+type balanceDelta struct {
+	standard rawdb.TokenStandard
+	delta    *big.Int
+}
+
+// indexUpdate accumulates the changes of the index for a range of blocks, the
+// balances being updated by the sum of their transfers when committed.
+type indexUpdate struct {
+	db       ethdb.Database
+	batch    ethdb.Batch
+	balances map[balanceKey]*balanceDelta
+	reverted int
+
+	number uint64      // Number of the last block of the update
+	hash   common.Hash // Hash of the last block of the update
+	moved  bool        // Whether the index head changed
+}
+
+func newIndexUpdate(db ethdb.Database) *indexUpdate {
+	number, hash, _ := rawdb.ReadTokenIndexHead(db)
+	return &indexUpdate{
+		db:       db,
+		batch:    db.NewBatch(),
+		balances: make(map[balanceKey]*balanceDelta),
+		number:   number,
+		hash:     hash,
+	}
+}
+
+// setHead sets the last block of the update.
+func (u *indexUpdate) setHead(number uint64, hash common.Hash) {
+	u.number, u.hash, u.moved = number, hash, true
+}
+
+// apply indexes the token transfers logged in a block by the holders involved,
+// journaling them for reverting the block if it leaves the canonical chain.
+func (u *indexUpdate) apply(header *types.Header, receipts types.Receipts) {
+	var (
+		number  = header.Number.Uint64()
+		hash    = header.Hash()
+		journal []*rawdb.TokenTransfer
+	)
+	for _, receipt := range receipts {
+		for _, log := range receipt.Logs {
+			transfers := decodeTransfers(log)
+			if len(transfers) == 0 {
+				continue
+			}
+			rawdb.WriteTokenTransfers(u.batch, transfers[0].From, number, hash, uint32(log.Index), transfers)
+			if transfers[0].To != transfers[0].From {
+				rawdb.WriteTokenTransfers(u.batch, transfers[0].To, number, hash, uint32(log.Index), transfers)
+			}
+			for _, transfer := range transfers {
+				u.transfer(transfer, false)
+			}
+			journal = append(journal, transfers...)
+		}
+	}
+	if len(journal) > 0 {
+		rawdb.WriteTokenJournal(u.batch, hash, number, journal)
+	}
+}
+
+// revert reverts the balance changes of the token transfers of a block.
+func (u *indexUpdate) revert(journal []*rawdb.TokenTransfer) {
+	for _, transfer := range journal {
+		u.transfer(transfer, true)
+	}
+	u.reverted++
+}
+
+// transfer moves the tokens of a transfer between the balances of the holders,
+// backwards if reverted. The balances of the zero address, from which tokens
+// are minted and to which they are burnt, aren't tracked.
+func (u *indexUpdate) transfer(transfer *rawdb.TokenTransfer, reverted bool) {
+	value := transfer.Value
+	if reverted {
+		value = new(big.Int).Neg(value)
+	}
+	if transfer.From != (common.Address{}) {
+		u.add(transfer.From, transfer, new(big.Int).Neg(value))
+	}
+	if transfer.To != (common.Address{}) {
+		u.add(transfer.To, transfer, value)
+	}
+}
+
+func (u *indexUpdate) add(holder common.Address, transfer *rawdb.TokenTransfer, value *big.Int) {
+	key := balanceKey{holder: holder, token: transfer.Token, id: common.BigToHash(transfer.ID)}
+	if balance, ok := u.balances[key]; ok {
+		balance.delta.Add(balance.delta, value)
+		return
+	}
+	u.balances[key] = &balanceDelta{standard: transfer.Standard, delta: new(big.Int).Set(value)}
+}
+
+// commit updates the balances and the index head, flushing the update to the
+// database.
+func (u *indexUpdate) commit() {
+	if !u.moved {
+		return
+	}
+	for key, balance := range u.balances {
+		if balance.delta.Sign() == 0 {
+			continue
+		}
+		id := key.id.Big()
+		current := rawdb.ReadTokenBalance(u.db, key.holder, key.token, id)
+		rawdb.WriteTokenBalance(u.batch, key.holder, key.token, balance.standard, id, current.Add(current, balance.delta))
+	}
+	rawdb.WriteTokenIndexHead(u.batch, u.number, u.hash)
+	if err := u.batch.Write(); err != nil {
+		log.Crit("Failed to write token index", "err", err)
+	}
+	u.batch.Reset()
+	u.balances = make(map[balanceKey]*balanceDelta)
+	u.moved = false
+}
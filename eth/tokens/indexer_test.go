@@ -0,0 +1,348 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tokens
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+var (
+	holderA = common.HexToAddress("0xa")
+	holderB = common.HexToAddress("0xb")
+	holderC = common.HexToAddress("0xc")
+
+	erc20Token   = common.HexToAddress("0x20")
+	erc721Token  = common.HexToAddress("0x721")
+	erc1155Token = common.HexToAddress("0x1155")
+)
+
+// testChain is a chain of headers and receipts the indexer follows.
+type testChain struct {
+	db       ethdb.Database
+	lock     sync.Mutex
+	headers  map[common.Hash]*types.Header
+	receipts map[common.Hash]types.Receipts
+	head     *types.Header
+	tail     uint64 // First block whose receipts are available
+	headFeed event.Feed
+	sideFeed event.Feed
+}
+
+func newTestChain(db ethdb.Database) *testChain {
+	chain := &testChain{
+		db:       db,
+		headers:  make(map[common.Hash]*types.Header),
+		receipts: make(map[common.Hash]types.Receipts),
+	}
+	chain.add(nil, 0)
+	return chain
+}
+
+// add creates a block with the given logs on top of parent, or the genesis if
+// parent is nil, making it the canonical head.
+func (c *testChain) add(parent *types.Header, fork byte, logs ...*types.Log) *types.Header {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	header := &types.Header{Number: new(big.Int), Extra: []byte{fork}}
+	if parent != nil {
+		header.Number.Add(parent.Number, common.Big1)
+		header.ParentHash = parent.Hash()
+	}
+	receipt := &types.Receipt{Status: types.ReceiptStatusSuccessful, Logs: logs}
+	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+	header.Bloom = receipt.Bloom
+	for i, log := range logs {
+		log.TxHash, log.Index = common.Hash{fork, byte(header.Number.Uint64())}, uint(i)
+	}
+	c.headers[header.Hash()] = header
+	c.receipts[header.Hash()] = types.Receipts{receipt}
+
+	// Make the new block and its ancestors canonical
+	for h := header; h != nil && rawdb.ReadCanonicalHash(c.db, h.Number.Uint64()) != h.Hash(); h = c.headers[h.ParentHash] {
+		rawdb.WriteCanonicalHash(c.db, h.Hash(), h.Number.Uint64())
+	}
+	if c.head != nil {
+		for n := header.Number.Uint64() + 1; n <= c.head.Number.Uint64(); n++ {
+			rawdb.DeleteCanonicalHash(c.db, n)
+		}
+	}
+	c.head = header
+	return header
+}
+
+func (c *testChain) CurrentBlock() *types.Header {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.head
+}
+
+func (c *testChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.headers[hash]
+}
+
+func (c *testChain) GetCanonicalHash(number uint64) common.Hash {
+	return rawdb.ReadCanonicalHash(c.db, number)
+}
+
+func (c *testChain) GetReceiptsByHash(hash common.Hash) types.Receipts {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if header := c.headers[hash]; header == nil || header.Number.Uint64() < c.tail {
+		return nil
+	}
+	return c.receipts[hash]
+}
+
+func (c *testChain) HistoryTail() uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tail
+}
+
+func (c *testChain) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription {
+	return c.headFeed.Subscribe(ch)
+}
+
+func (c *testChain) SubscribeChainSideEvent(ch chan<- core.ChainSideEvent) event.Subscription {
+	return c.sideFeed.Subscribe(ch)
+}
+
+// transferLog creates a transfer log of the given standard.
+func transferLog(standard rawdb.TokenStandard, from, to common.Address, ids, values []int64) *types.Log {
+	topic := func(address common.Address) common.Hash { return common.BytesToHash(address.Bytes()) }
+	bigs := func(values []int64) []*big.Int {
+		result := make([]*big.Int, len(values))
+		for i, value := range values {
+			result[i] = big.NewInt(value)
+		}
+		return result
+	}
+	switch standard {
+	case rawdb.TokenERC20:
+		data, _ := erc20ABI.Events["Transfer"].Inputs.NonIndexed().Pack(big.NewInt(values[0]))
+		return &types.Log{Address: erc20Token, Topics: []common.Hash{transferTopic, topic(from), topic(to)}, Data: data}
+	case rawdb.TokenERC721:
+		return &types.Log{Address: erc721Token, Topics: []common.Hash{transferTopic, topic(from), topic(to), common.BigToHash(big.NewInt(ids[0]))}}
+	default:
+		data, _ := erc1155ABI.Events["TransferBatch"].Inputs.NonIndexed().Pack(bigs(ids), bigs(values))
+		return &types.Log{Address: erc1155Token, Topics: []common.Hash{transferBatchTopic, topic(from), topic(from), topic(to)}, Data: data}
+	}
+}
+
+// waitIndexHead waits until the token index reaches the given head.
+func waitIndexHead(t *testing.T, db ethdb.Database, head *types.Header) {
+	t.Helper()
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		if number, hash, ok := rawdb.ReadTokenIndexHead(db); ok && number == head.Number.Uint64() && hash == head.Hash() {
+			return
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("block %d not indexed", head.Number)
+		}
+	}
+}
+
+// Tests that the token transfers and balances are indexed from the canonical
+// chain, and that the blocks leaving it are reverted.
+func TestIndexer(t *testing.T) {
+	var (
+		db    = rawdb.NewMemoryDatabase()
+		chain = newTestChain(db)
+		zero  = common.Address{}
+		b1    = chain.add(chain.head, 0, transferLog(rawdb.TokenERC20, zero, holderA, nil, []int64{100}))
+		b2    = chain.add(b1, 0,
+			transferLog(rawdb.TokenERC20, holderA, holderB, nil, []int64{30}),
+			transferLog(rawdb.TokenERC721, zero, holderB, []int64{7}, nil),
+			&types.Log{Address: erc20Token, Topics: []common.Hash{transferTopic, {}, {}}}, // malformed, skipped
+		)
+		_ = chain.add(b2, 0, transferLog(rawdb.TokenERC1155, zero, holderC, []int64{1, 2}, []int64{5, 6}))
+	)
+	if _, err := Balances(db, holderA); err == nil {
+		t.Fatal("balances retrieved before indexing")
+	}
+	indexer := NewIndexer(db, chain)
+	indexer.Start()
+	defer indexer.Stop()
+
+	waitHead := func(head *types.Header) {
+		t.Helper()
+		waitIndexHead(t, db, head)
+	}
+	type balance struct {
+		token common.Address
+		id    int64
+		value int64
+	}
+	checkBalances := func(holder common.Address, want ...balance) {
+		t.Helper()
+		balances, err := Balances(db, holder)
+		if err != nil {
+			t.Fatalf("failed to retrieve balances of %x: %v", holder, err)
+		}
+		if len(balances) != len(want) {
+			t.Fatalf("balance count mismatch for %x: have %d, want %d", holder, len(balances), len(want))
+		}
+		for i, b := range balances {
+			if b.Token != want[i].token || b.ID.Int64() != want[i].id || b.Balance.Int64() != want[i].value {
+				t.Errorf("balance %d of %x mismatch: have %x/%v=%v, want %x/%d=%d", i, holder, b.Token, b.ID, b.Balance, want[i].token, want[i].id, want[i].value)
+			}
+		}
+	}
+	waitHead(chain.CurrentBlock())
+	checkBalances(holderA, balance{erc20Token, 0, 70})
+	checkBalances(holderB, balance{erc20Token, 0, 30}, balance{erc721Token, 7, 1})
+	checkBalances(holderC, balance{erc1155Token, 1, 5}, balance{erc1155Token, 2, 6})
+	checkBalances(zero)
+
+	// Replace the last block with a longer side chain
+	b3 := chain.add(b2, 1, transferLog(rawdb.TokenERC20, holderB, holderC, nil, []int64{10}))
+	b4 := chain.add(b3, 1, transferLog(rawdb.TokenERC721, holderB, holderC, []int64{7}, nil))
+	chain.sideFeed.Send(core.ChainSideEvent{})
+	chain.headFeed.Send(core.ChainHeadEvent{})
+	waitHead(b4)
+
+	checkBalances(holderA, balance{erc20Token, 0, 70})
+	checkBalances(holderB, balance{erc20Token, 0, 20})
+	checkBalances(holderC, balance{erc20Token, 0, 10}, balance{erc721Token, 7, 1})
+
+	// Page through the transfers of a holder, newest first
+	var (
+		cursor *filters.LogCursor
+		blocks []uint64
+	)
+	for {
+		transfers, next, err := Transfers(db, holderB, cursor, 1)
+		if err != nil {
+			t.Fatalf("failed to retrieve transfers: %v", err)
+		}
+		if len(transfers) != 1 {
+			t.Fatalf("transfer count mismatch: have %d, want 1", len(transfers))
+		}
+		blocks = append(blocks, transfers[0].BlockNumber)
+		if cursor = next; cursor == nil {
+			break
+		}
+	}
+	if want := []uint64{4, 3, 2, 2}; !reflect.DeepEqual(blocks, want) {
+		t.Fatalf("transfer blocks mismatch: have %v, want %v", blocks, want)
+	}
+	transfers, _, _ := Transfers(db, holderC, nil, 10)
+	if len(transfers) != 2 || transfers[0].Standard != rawdb.TokenERC721 || transfers[1].Value.Int64() != 10 {
+		t.Errorf("transfers of side chain not skipped: %+v", transfers)
+	}
+}
+
+// Tests that a new index starts from the first block with receipts on nodes
+// with pruned history.
+func TestIndexerHistoryTail(t *testing.T) {
+	var (
+		db    = rawdb.NewMemoryDatabase()
+		chain = newTestChain(db)
+		zero  = common.Address{}
+		b1    = chain.add(chain.head, 0, transferLog(rawdb.TokenERC20, zero, holderA, nil, []int64{100}))
+		b2    = chain.add(b1, 0, transferLog(rawdb.TokenERC20, holderA, holderB, nil, []int64{30}))
+		b3    = chain.add(b2, 0, transferLog(rawdb.TokenERC20, holderB, holderC, nil, []int64{10}))
+	)
+	chain.tail = 2
+
+	indexer := NewIndexer(db, chain)
+	indexer.Start()
+	defer indexer.Stop()
+	waitIndexHead(t, db, b3)
+
+	transfers, _, err := Transfers(db, holderA, nil, 10)
+	if err != nil {
+		t.Fatalf("failed to retrieve transfers: %v", err)
+	}
+	if len(transfers) != 1 || transfers[0].BlockNumber != 2 {
+		t.Fatalf("transfers before the history tail indexed: %+v", transfers)
+	}
+	balances, err := Balances(db, holderB)
+	if err != nil {
+		t.Fatalf("failed to retrieve balances: %v", err)
+	}
+	if len(balances) != 1 || balances[0].Balance.Int64() != 20 {
+		t.Errorf("balance mismatch: %+v", balances)
+	}
+}
+
+// Tests that the index can't be queried once the indexing stopped due to
+// unavailable receipts, and that it resumes when restarted.
+func TestIndexerStopped(t *testing.T) {
+	var (
+		db    = rawdb.NewMemoryDatabase()
+		chain = newTestChain(db)
+		zero  = common.Address{}
+		b1    = chain.add(chain.head, 0, transferLog(rawdb.TokenERC20, zero, holderA, nil, []int64{100}))
+		b2    = chain.add(b1, 0, transferLog(rawdb.TokenERC20, holderA, holderB, nil, []int64{30}))
+	)
+	receipts := chain.receipts[b2.Hash()]
+	delete(chain.receipts, b2.Hash())
+
+	indexer := NewIndexer(db, chain)
+	indexer.Start()
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		if number, stopped := rawdb.ReadTokenIndexStopped(db); stopped {
+			if number != 2 {
+				t.Fatalf("indexing stopped at wrong block: have %d, want 2", number)
+			}
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("indexing not stopped")
+		}
+	}
+	indexer.Stop()
+
+	if _, err := Balances(db, holderA); !errors.Is(err, errIndexStopped) {
+		t.Errorf("balances error mismatch: have %v, want %v", err, errIndexStopped)
+	}
+	if _, _, err := Transfers(db, holderA, nil, 10); !errors.Is(err, errIndexStopped) {
+		t.Errorf("transfers error mismatch: have %v, want %v", err, errIndexStopped)
+	}
+	// Restart the indexing once the receipts are available
+	chain.receipts[b2.Hash()] = receipts
+
+	indexer = NewIndexer(db, chain)
+	indexer.Start()
+	defer indexer.Stop()
+	waitIndexHead(t, db, b2)
+
+	balances, err := Balances(db, holderB)
+	if err != nil {
+		t.Fatalf("failed to retrieve balances: %v", err)
+	}
+	if len(balances) != 1 || balances[0].Balance.Int64() != 30 {
+		t.Errorf("balance mismatch: %+v", balances)
+	}
+}
@@ -39,6 +39,7 @@ import (
 	"github.com/ethereum/go-ethereum/eth/tracers/logger"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/internal/tracing"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
@@ -578,7 +579,16 @@ func (api *API) StandardTraceBadBlockToFile(ctx context.Context, hash common.Has
 // traceBlock configures a new tracer according to the provided configuration, and
 // executes all the transactions contained within. The return value will be one item
 // per transaction, dependent on the requested tracer.
-func (api *API) traceBlock(ctx context.Context, block *types.Block, config *TraceConfig) ([]*txTraceResult, error) {
+func (api *API) traceBlock(ctx context.Context, block *types.Block, config *TraceConfig) (traced []*txTraceResult, err error) {
+	ctx, span := tracing.StartSpan(ctx, "tracers.traceBlock", tracing.Uint64("block", block.NumberU64()), tracing.Int("txs", len(block.Transactions())))
+	if span != nil && config != nil && config.Tracer != nil {
+		span.SetAttributes(tracing.String("tracer", *config.Tracer))
+	}
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+
 	if block.NumberU64() == 0 {
 		return nil, errors.New("genesis is not traceable")
 	}
@@ -952,16 +962,28 @@ func (api *API) TraceCall(ctx context.Context, args ethapi.TransactionArgs, bloc
 // traceTx configures a new tracer according to the provided configuration, and
 // executes the given message in the provided environment. The return value will
 // be tracer dependent.
-func (api *API) traceTx(ctx context.Context, message *core.Message, txctx *Context, vmctx vm.BlockContext, statedb *state.StateDB, config *TraceConfig) (interface{}, error) {
+func (api *API) traceTx(ctx context.Context, message *core.Message, txctx *Context, vmctx vm.BlockContext, statedb *state.StateDB, config *TraceConfig) (result interface{}, err error) {
 	var (
 		tracer    Tracer
-		err       error
 		timeout   = defaultTraceTimeout
 		txContext = core.NewEVMTxContext(message)
 	)
 	if config == nil {
 		config = &TraceConfig{}
 	}
+	ctx, span := tracing.StartSpan(ctx, "tracers.traceTx", tracing.Int("index", txctx.TxIndex))
+	if span != nil {
+		if txctx.TxHash != (common.Hash{}) {
+			span.SetAttributes(tracing.String("tx", txctx.TxHash.Hex()))
+		}
+		if config.Tracer != nil {
+			span.SetAttributes(tracing.String("tracer", *config.Tracer))
+		}
+	}
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
 	// Default tracer is the struct logger
 	tracer = logger.NewStructLogger(config.Config)
 	if config.Tracer != nil {
@@ -407,6 +407,37 @@ func (ec *Client) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]typ
 	return result, err
 }
 
+// FilterLogsPage executes a filter query, returning a page of at most limit logs
+// starting at the given cursor, or at the beginning of the query if empty. The
+// returned cursor resumes the query on the next page, it is empty once all the
+// matching logs were returned. A zero limit leaves the page size to the node.
+func (ec *Client) FilterLogsPage(ctx context.Context, q ethereum.FilterQuery, cursor string, limit uint) ([]types.Log, string, error) {
+	arg, err := toFilterArg(q)
+	if err != nil {
+		return nil, "", err
+	}
+	var (
+		cursorArg, limitArg interface{}
+		result              struct {
+			Logs   []types.Log `json:"logs"`
+			Cursor *string     `json:"cursor"`
+		}
+	)
+	if cursor != "" {
+		cursorArg = cursor
+	}
+	if limit != 0 {
+		limitArg = hexutil.Uint(limit)
+	}
+	if err := ec.c.CallContext(ctx, &result, "eth_getLogsPage", arg, cursorArg, limitArg); err != nil {
+		return nil, "", err
+	}
+	if result.Cursor == nil {
+		return result.Logs, "", nil
+	}
+	return result.Logs, *result.Cursor, nil
+}
+
 // SubscribeFilterLogs subscribes to the results of a streaming filter query.
 func (ec *Client) SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
 	arg, err := toFilterArg(q)
@@ -0,0 +1,207 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build (arm64 || amd64) && !openbsd
+
+package pebble
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// replicaCheckpointAttempts is the number of times creating a checkpoint of a
+// live database is attempted before giving up, if the owner keeps modifying
+// its manifest in the meantime.
+const replicaCheckpointAttempts = 8
+
+// NewReplica opens a private checkpoint of the database of another live process.
+//
+// The checkpoint is created in the given directory, which must be on the same
+// file system as the source database: the immutable tables are hard linked, so
+// the compactions of the owner can't remove them from under the replica, while
+// the manifest and the write-ahead logs are copied. The returned instance only
+// sees the data persisted by the time of opening, a new checkpoint is needed to
+// catch up.
+func NewReplica(file string, checkpoint string, cache int, handles int, namespace string) (*Database, error) {
+	var err error
+	for i := 0; i < replicaCheckpointAttempts; i++ {
+		if err = createCheckpoint(file, checkpoint); err != nil {
+			if errors.Is(err, errCheckpointRace) {
+				continue
+			}
+			return nil, err
+		}
+		var db *Database
+		if db, err = open(checkpoint, cache, handles, namespace, true, true, true); err == nil {
+			return db, nil
+		}
+	}
+	os.RemoveAll(checkpoint)
+	return nil, fmt.Errorf("failed to checkpoint live database %s: %w", file, err)
+}
+
+// errCheckpointRace is returned if the source database changed its manifest
+// while a checkpoint was being created.
+var errCheckpointRace = errors.New("database modified during checkpoint")
+
+// createCheckpoint populates the checkpoint directory with a consistent view of
+// the source database, i.e. the files referenced by its manifest as of the end
+// of the copy. Since the owner only deletes files after recording it in the
+// manifest, an unchanged manifest means none of the files were pulled.
+func createCheckpoint(src string, dst string) error {
+	if err := os.RemoveAll(dst); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dst, 0700); err != nil {
+		return err
+	}
+	before, err := manifestState(src)
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	// Copy the manifest first and link the tables it references afterwards. The
+	// logs are copied last, if some of them are flushed and dropped meanwhile,
+	// the manifest changes and the checkpoint is retried.
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !isManifestFile(name) && !strings.HasPrefix(name, "OPTIONS-") {
+			continue
+		}
+		if err := copyFile(filepath.Join(src, name), filepath.Join(dst, name)); err != nil {
+			return checkpointError(err)
+		}
+	}
+	for _, entry := range entries {
+		if name := entry.Name(); entry.Type().IsRegular() && strings.HasSuffix(name, ".sst") {
+			err := os.Link(filepath.Join(src, name), filepath.Join(dst, name))
+			if errors.Is(err, syscall.EXDEV) {
+				return fmt.Errorf("replica checkpoint %s must be on the same file system as %s: %w", dst, src, err)
+			}
+			if err != nil {
+				return checkpointError(err)
+			}
+		}
+	}
+	for _, entry := range entries {
+		if name := entry.Name(); entry.Type().IsRegular() && strings.HasSuffix(name, ".log") {
+			if err := copyFile(filepath.Join(src, name), filepath.Join(dst, name)); err != nil {
+				return checkpointError(err)
+			}
+		}
+	}
+	after, err := manifestState(src)
+	if err != nil {
+		return err
+	}
+	if before != after {
+		return errCheckpointRace
+	}
+	return nil
+}
+
+// checkpointError reports files vanishing while a checkpoint is being created
+// as a race with the owner of the database.
+func checkpointError(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return errCheckpointRace
+	}
+	return err
+}
+
+// isManifestFile reports whether the file is part of the version bookkeeping
+// of a pebble database: the manifests and the markers pointing to the active
+// one and to the format version.
+func isManifestFile(name string) bool {
+	return name == "CURRENT" || strings.HasPrefix(name, "MANIFEST-") || strings.HasPrefix(name, "marker.")
+}
+
+// ReplicaState returns a fingerprint of the persisted content of a pebble
+// database: the names and sizes of its version bookkeeping files and of its
+// write-ahead logs. A checkpoint taken at the same fingerprint is up to date,
+// the owner didn't persist anything since.
+func ReplicaState(file string) (string, error) {
+	state, err := manifestState(file)
+	if err != nil {
+		return "", err
+	}
+	entries, err := os.ReadDir(file)
+	if err != nil {
+		return "", err
+	}
+	var logs strings.Builder
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".log") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return "", checkpointError(err)
+		}
+		fmt.Fprintf(&logs, "%s:%d:%d;", entry.Name(), info.Size(), info.ModTime().UnixNano())
+	}
+	return state + logs.String(), nil
+}
+
+// manifestState returns the names and sizes of the version bookkeeping files of
+// a pebble database, which change whenever the set of live files does.
+func manifestState(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	var state strings.Builder
+	for _, entry := range entries {
+		if !isManifestFile(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return "", checkpointError(err)
+		}
+		fmt.Fprintf(&state, "%s:%d;", entry.Name(), info.Size())
+	}
+	return state.String(), nil
+}
+
+// copyFile copies the current content of a file.
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,388 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package exporter implements the streaming of the canonical blocks, with their
+// transactions, receipts and logs, to external sinks.
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// chainEventChanSize is the size of the channels listening to the chain events.
+	chainEventChanSize = 10
+
+	// checkpointFile is the name of the file tracking the last exported block in
+	// the instance directory.
+	checkpointFile = "exporter.checkpoint"
+)
+
+var (
+	retryDelay        = 5 * time.Second  // Delay before delivering again the records a sink failed to write
+	webhookBackoff    = time.Second      // Initial delay between two webhook delivery attempts
+	maxWebhookBackoff = 30 * time.Second // Maximum delay between two webhook delivery attempts
+)
+
+// Config are the settings of the exporter, which is enabled if any sink is.
+type Config struct {
+	File        string `toml:",omitempty"` // Directory of the NDJSON files, empty if disabled
+	FileMaxSize uint64 `toml:",omitempty"` // Size in bytes beyond which a new NDJSON file is started
+
+	Webhook        string `toml:",omitempty"` // URL the records are posted to, empty if disabled
+	WebhookRetries int    `toml:",omitempty"` // Attempts of a webhook delivery before backing off
+
+	Kafka          string `toml:",omitempty"` // Address of the Kafka broker, empty if disabled
+	KafkaTopic     string `toml:",omitempty"` // Topic the records are produced to
+	KafkaPartition int32  `toml:",omitempty"` // Partition of the topic, led by the broker
+}
+
+// DefaultConfig contains the default settings of the exporter.
+var DefaultConfig = Config{
+	FileMaxSize:    128 * 1024 * 1024,
+	WebhookRetries: 5,
+	KafkaTopic:     "polarys-blocks",
+}
+
+// Enabled reports whether any sink is configured.
+func (c *Config) Enabled() bool {
+	return c.File != "" || c.Webhook != "" || c.Kafka != ""
+}
+
+// backend encompasses the functionality needed to export the chain.
+type backend interface {
+	SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription
+	SubscribeChainSideEvent(ch chan<- core.ChainSideEvent) event.Subscription
+	SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription
+	CurrentHeader() *types.Header
+	HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error)
+	HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error)
+	BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error)
+	GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error)
+	ChainConfig() *params.ChainConfig
+}
+
+// checkpoint is the last block delivered to all the sinks.
+type checkpoint struct {
+	Number uint64      `json:"number"`
+	Hash   common.Hash `json:"hash"`
+}
+
+// Service streams the canonical chain to the configured sinks. The records of
+// each block are delivered to all of them before the block is checkpointed, a
+// restarted exporter resuming after the checkpoint. When blocks leave the
+// canonical chain, their retract records are delivered, newest first, before
+// the blocks replacing them.
+//
+// A sink failing to write is retried until it succeeds, holding back the others,
+// unless the failure is permanent, which stops the export.
+// The records delivered to a sink right before a crash, but not checkpointed,
+// are delivered again on restart: consumers deduplicate them by identifier.
+type Service struct {
+	backend    backend
+	sinks      []Sink
+	checkpoint string      // Path of the checkpoint file
+	head       *checkpoint // Last exported block, nil if none
+
+	pending   string       // Identifier of the first record being delivered
+	delivered map[int]bool // Sinks the pending records were delivered to
+
+	trigger chan struct{} // Notifies the exporter of a chain update, never blocking the chain feeds
+	ctx     context.Context
+	cancel  context.CancelFunc
+	subs    []event.Subscription
+	wg      sync.WaitGroup
+}
+
+// New creates an exporter with the configured sinks and registers it with the
+// node.
+func New(stack *node.Node, backend backend, config *Config) error {
+	var sinks []Sink
+	if config.File != "" {
+		sink, err := newFileSink(stack.ResolvePath(config.File), config.FileMaxSize)
+		if err != nil {
+			return err
+		}
+		sinks = append(sinks, sink)
+	}
+	if config.Webhook != "" {
+		sinks = append(sinks, newWebhookSink(config.Webhook, config.WebhookRetries))
+	}
+	if config.Kafka != "" {
+		sinks = append(sinks, newKafkaSink(config.Kafka, config.KafkaTopic, config.KafkaPartition))
+	}
+	s, err := newService(backend, sinks, stack.ResolvePath(checkpointFile))
+	if err != nil {
+		return err
+	}
+	stack.RegisterLifecycle(s)
+	return nil
+}
+
+func newService(backend backend, sinks []Sink, path string) (*Service, error) {
+	if len(sinks) == 0 {
+		return nil, errors.New("no exporter sink configured")
+	}
+	s := &Service{
+		backend:    backend,
+		sinks:      sinks,
+		checkpoint: path,
+		delivered:  make(map[int]bool),
+		trigger:    make(chan struct{}, 1),
+	}
+	blob, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		s.head = new(checkpoint)
+		if err := json.Unmarshal(blob, s.head); err != nil {
+			return nil, fmt.Errorf("invalid exporter checkpoint: %v", err)
+		}
+	case !os.IsNotExist(err):
+		return nil, err
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	return s, nil
+}
+
+// Start implements node.Lifecycle, starting the export from the checkpoint, or
+// from the current head if none.
+func (s *Service) Start() error {
+	var (
+		chainCh   = make(chan core.ChainEvent, chainEventChanSize)
+		sideCh    = make(chan core.ChainSideEvent, chainEventChanSize)
+		removedCh = make(chan core.RemovedLogsEvent, chainEventChanSize)
+	)
+	s.subs = []event.Subscription{
+		s.backend.SubscribeChainEvent(chainCh),
+		s.backend.SubscribeChainSideEvent(sideCh),
+		s.backend.SubscribeRemovedLogsEvent(removedCh),
+	}
+	s.wg.Add(2)
+	go s.eventLoop(chainCh, sideCh, removedCh)
+	go s.exportLoop()
+
+	log.Info("Chain exporter started", "sinks", len(s.sinks))
+	return nil
+}
+
+// Stop implements node.Lifecycle, terminating the export.
+func (s *Service) Stop() error {
+	s.cancel()
+	for _, sub := range s.subs {
+		sub.Unsubscribe()
+	}
+	s.wg.Wait()
+	for _, sink := range s.sinks {
+		sink.Close()
+	}
+	log.Info("Chain exporter stopped")
+	return nil
+}
+
+// eventLoop notifies the exporter of the blocks joining and leaving the
+// canonical chain.
+func (s *Service) eventLoop(chainCh chan core.ChainEvent, sideCh chan core.ChainSideEvent, removedCh chan core.RemovedLogsEvent) {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case <-chainCh:
+		case <-sideCh:
+		case <-removedCh:
+		case <-s.ctx.Done():
+			return
+		}
+		s.notify()
+	}
+}
+
+// notify triggers an export, if none is pending already.
+func (s *Service) notify() {
+	select {
+	case s.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// exportLoop exports the chain whenever it changes, retrying after a delay if
+// any sink fails.
+func (s *Service) exportLoop() {
+	defer s.wg.Done()
+
+	for {
+		var retry <-chan time.Time
+		if err := s.export(); err != nil {
+			if s.ctx.Err() != nil {
+				return
+			}
+			if errors.Is(err, errSinkFatal) {
+				log.Error("Chain export stopped", "err", err)
+				return
+			}
+			log.Warn("Failed to export chain, retrying", "err", err, "delay", retryDelay)
+			retry = time.After(retryDelay)
+		}
+		select {
+		case <-s.trigger:
+		case <-retry:
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// export retracts the exported blocks which left the canonical chain, then
+// exports the canonical blocks up to the head.
+func (s *Service) export() error {
+	for s.head != nil {
+		header, err := s.backend.HeaderByNumber(s.ctx, rpc.BlockNumber(s.head.Number))
+		if err != nil {
+			return err
+		}
+		if header != nil && header.Hash() == s.head.Hash {
+			break
+		}
+		retracted, err := s.backend.HeaderByHash(s.ctx, s.head.Hash)
+		if err != nil {
+			return err
+		}
+		if retracted == nil {
+			log.Error("Exported block unavailable, resuming from head", "number", s.head.Number, "hash", s.head.Hash)
+			s.head = nil
+			break
+		}
+		if err := s.deliver([]*Record{retractRecord(s.head.Number, s.head.Hash)}); err != nil {
+			return err
+		}
+		if err := s.setHead(s.head.Number-1, retracted.ParentHash); err != nil {
+			return err
+		}
+	}
+	head := s.backend.CurrentHeader().Number.Uint64()
+	next := head
+	if s.head != nil {
+		next = s.head.Number + 1
+	}
+	for ; next <= head; next++ {
+		if err := s.ctx.Err(); err != nil {
+			return err
+		}
+		header, err := s.backend.HeaderByNumber(s.ctx, rpc.BlockNumber(next))
+		if err != nil {
+			return err
+		}
+		if header == nil || (s.head != nil && header.ParentHash != s.head.Hash) {
+			// The canonical chain changed in the meantime, start over
+			s.notify()
+			return nil
+		}
+		block, err := s.backend.BlockByHash(s.ctx, header.Hash())
+		if err != nil {
+			return err
+		}
+		receipts, err := s.backend.GetReceipts(s.ctx, header.Hash())
+		if err != nil {
+			return err
+		}
+		if block == nil || (receipts == nil && len(block.Transactions()) > 0) {
+			return fmt.Errorf("block %d unavailable", next)
+		}
+		if err := s.deliver(blockRecords(block, receipts, s.backend.ChainConfig())); err != nil {
+			return err
+		}
+		if err := s.setHead(next, header.Hash()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deliver writes the records to all the sinks. On failure, the same records are
+// delivered again only to the sinks which didn't write them.
+func (s *Service) deliver(records []*Record) error {
+	if records[0].ID != s.pending {
+		s.pending = records[0].ID
+		s.delivered = make(map[int]bool)
+	}
+	for i, sink := range s.sinks {
+		if s.delivered[i] {
+			continue
+		}
+		if err := sink.Write(s.ctx, records); err != nil {
+			return err
+		}
+		s.delivered[i] = true
+	}
+	return nil
+}
+
+// setHead checkpoints the last block delivered to all the sinks, replacing the
+// checkpoint file atomically and durably.
+func (s *Service) setHead(number uint64, hash common.Hash) error {
+	head := &checkpoint{Number: number, Hash: hash}
+	blob, err := json.Marshal(head)
+	if err != nil {
+		return err
+	}
+	// Flush the new checkpoint before the rename, lest a crash leaves it empty
+	tmp := s.checkpoint + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(blob); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.checkpoint); err != nil {
+		return err
+	}
+	// Flush the directory too, persisting the rename
+	dir, err := os.Open(filepath.Dir(s.checkpoint))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	if err := dir.Sync(); err != nil {
+		return err
+	}
+	s.head = head
+	s.pending, s.delivered = "", make(map[int]bool)
+	return nil
+}
@@ -0,0 +1,280 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// testBackend exports a local blockchain.
+type testBackend struct {
+	chain *core.BlockChain
+}
+
+func (b *testBackend) SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription {
+	return b.chain.SubscribeChainEvent(ch)
+}
+
+func (b *testBackend) SubscribeChainSideEvent(ch chan<- core.ChainSideEvent) event.Subscription {
+	return b.chain.SubscribeChainSideEvent(ch)
+}
+
+func (b *testBackend) SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription {
+	return b.chain.SubscribeRemovedLogsEvent(ch)
+}
+
+func (b *testBackend) CurrentHeader() *types.Header {
+	return b.chain.CurrentHeader()
+}
+
+func (b *testBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	return b.chain.GetHeaderByNumber(uint64(number)), nil
+}
+
+func (b *testBackend) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	return b.chain.GetHeaderByHash(hash), nil
+}
+
+func (b *testBackend) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	return b.chain.GetBlockByHash(hash), nil
+}
+
+func (b *testBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	return b.chain.GetReceiptsByHash(hash), nil
+}
+
+func (b *testBackend) ChainConfig() *params.ChainConfig {
+	return b.chain.Config()
+}
+
+// testSink collects the written records, failing the requested number of writes.
+type testSink struct {
+	lock    sync.Mutex
+	records []*Record
+	fails   int
+}
+
+func (s *testSink) Write(ctx context.Context, records []*Record) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.fails > 0 {
+		s.fails--
+		return errors.New("sink failure")
+	}
+	s.records = append(s.records, records...)
+	return nil
+}
+
+func (s *testSink) Close() error { return nil }
+
+// blocks summarizes the block and retract records written to the sink.
+func (s *testSink) blocks() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var blocks []string
+	for _, record := range s.records {
+		if record.Kind == KindBlock || record.Kind == KindRetract {
+			blocks = append(blocks, fmt.Sprintf("%s %d %x", record.Kind, record.BlockNumber, record.BlockHash[:4]))
+		}
+	}
+	return blocks
+}
+
+// Tests that the canonical blocks are exported with their transactions and
+// receipts, that the blocks leaving the canonical chain are retracted, and that
+// a restarted exporter resumes from its checkpoint.
+func TestExporter(t *testing.T) {
+	defer func(delay time.Duration) { retryDelay = delay }(retryDelay)
+	retryDelay = 10 * time.Millisecond
+
+	var (
+		key, _    = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender    = crypto.PubkeyToAddress(key.PublicKey)
+		recipient = common.HexToAddress("0xaaaa")
+		signer    = types.HomesteadSigner{}
+		gspec     = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{sender: {Balance: big.NewInt(params.Ether)}},
+		}
+		engine = ethash.NewFaker()
+	)
+	db, canon, _ := core.GenerateChainWithGenesis(gspec, engine, 3, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(sender), recipient, big.NewInt(1), params.TxGas, b.BaseFee(), nil), signer, key)
+		b.AddTx(tx)
+	})
+	generate := func(parent *types.Block, n int, coinbase byte) []*types.Block {
+		blocks, _ := core.GenerateChain(gspec.Config, parent, engine, db, n, func(i int, b *core.BlockGen) {
+			b.SetCoinbase(common.Address{coinbase})
+			tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(sender), recipient, big.NewInt(1), params.TxGas, b.BaseFee(), nil), signer, key)
+			b.AddTx(tx)
+		})
+		return blocks
+	}
+	chain, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), nil, gspec, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(canon[:2]); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	var (
+		path  = filepath.Join(t.TempDir(), checkpointFile)
+		good  = new(testSink)
+		flaky = &testSink{fails: 2}
+	)
+	service, err := newService(&testBackend{chain}, []Sink{good, flaky}, path)
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+	if err := service.Start(); err != nil {
+		t.Fatalf("failed to start exporter: %v", err)
+	}
+	summary := func(kind string, blocks ...*types.Block) []string {
+		var result []string
+		for _, block := range blocks {
+			result = append(result, fmt.Sprintf("%s %d %x", kind, block.NumberU64(), block.Hash().Bytes()[:4]))
+		}
+		return result
+	}
+	wait := func(sink *testSink, want []string) {
+		t.Helper()
+		for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+			have := sink.blocks()
+			if reflect.DeepEqual(have, want) {
+				return
+			}
+			if time.Since(start) > 5*time.Second {
+				t.Fatalf("exported blocks mismatch:\nhave %v\nwant %v", have, want)
+			}
+		}
+	}
+	// The export starts at the head, then follows the chain
+	want := summary(KindBlock, canon[1])
+	wait(good, want)
+	wait(flaky, want)
+
+	if _, err := chain.InsertChain(canon[2:]); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	want = append(want, summary(KindBlock, canon[2])...)
+	wait(good, want)
+
+	// Reorg to a longer side chain, retracting the replaced blocks
+	fork := generate(canon[0], 3, 1)
+	if _, err := chain.InsertChain(fork); err != nil {
+		t.Fatalf("failed to insert fork: %v", err)
+	}
+	want = append(want, summary(KindRetract, canon[2], canon[1])...)
+	want = append(want, summary(KindBlock, fork...)...)
+	wait(good, want)
+	wait(flaky, want)
+	service.Stop()
+
+	kinds := make(map[string]int)
+	for _, record := range good.records {
+		kinds[record.Kind]++
+	}
+	if exp := map[string]int{KindBlock: 5, KindTransaction: 5, KindReceipt: 5, KindRetract: 2}; !reflect.DeepEqual(kinds, exp) {
+		t.Errorf("record kinds mismatch: have %v, want %v", kinds, exp)
+	}
+	// Restart the exporter, resuming after the checkpoint
+	more := generate(fork[len(fork)-1], 1, 1)
+	if _, err := chain.InsertChain(more); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	resumed := new(testSink)
+	service, err = newService(&testBackend{chain}, []Sink{resumed}, path)
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+	if err := service.Start(); err != nil {
+		t.Fatalf("failed to start exporter: %v", err)
+	}
+	defer service.Stop()
+	wait(resumed, summary(KindBlock, more...))
+}
+
+// Tests that the records of a block hold its transactions, each followed by its
+// receipt and logs.
+func TestBlockRecords(t *testing.T) {
+	var (
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		signer = types.HomesteadSigner{}
+		to     = common.HexToAddress("0xaaaa")
+	)
+	tx1, _ := types.SignTx(types.NewTransaction(0, to, big.NewInt(1), params.TxGas, big.NewInt(1), nil), signer, key)
+	tx2, _ := types.SignTx(types.NewContractCreation(1, nil, 100000, big.NewInt(1), []byte{0x00}), signer, key)
+	receipts := types.Receipts{
+		{Status: types.ReceiptStatusSuccessful, GasUsed: 21000, CumulativeGasUsed: 21000, Logs: []*types.Log{
+			{Address: to, Topics: []common.Hash{{0x01}}, Index: 0},
+			{Address: to, Data: []byte{0x02}, Index: 1},
+		}},
+		{Status: types.ReceiptStatusSuccessful, GasUsed: 50000, CumulativeGasUsed: 71000, ContractAddress: crypto.CreateAddress(crypto.PubkeyToAddress(key.PublicKey), 1)},
+	}
+	block := types.NewBlock(&types.Header{Number: big.NewInt(7)}, []*types.Transaction{tx1, tx2}, nil, receipts, trie.NewStackTrie(nil))
+
+	records := blockRecords(block, receipts, params.TestChainConfig)
+	var kinds []string
+	for _, record := range records {
+		kinds = append(kinds, record.Kind)
+		if record.BlockNumber != 7 || record.BlockHash != block.Hash() {
+			t.Errorf("record %s of wrong block: %d %x", record.ID, record.BlockNumber, record.BlockHash)
+		}
+	}
+	if want := []string{KindBlock, KindTransaction, KindReceipt, KindLog, KindLog, KindTransaction, KindReceipt}; !reflect.DeepEqual(kinds, want) {
+		t.Fatalf("record kinds mismatch: have %v, want %v", kinds, want)
+	}
+	if from := records[1].Transaction.From; from != crypto.PubkeyToAddress(key.PublicKey) {
+		t.Errorf("sender mismatch: have %x", from)
+	}
+	if log := records[4].Log; log.Index != 1 || log.TransactionHash != tx1.Hash() || log.Data[0] != 0x02 {
+		t.Errorf("log mismatch: %+v", log)
+	}
+	if receipt := records[6].Receipt; receipt.ContractAddress == nil || *receipt.ContractAddress != receipts[1].ContractAddress {
+		t.Errorf("contract address mismatch: %+v", receipt)
+	}
+	ids := make(map[string]bool)
+	for _, record := range records {
+		if ids[record.ID] {
+			t.Errorf("duplicate record id %s", record.ID)
+		}
+		ids[record.ID] = true
+	}
+}
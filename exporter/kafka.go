@@ -0,0 +1,297 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	kafkaClientID     = "polarys-exporter"
+	kafkaProduceKey   = 0 // API key of the produce requests
+	kafkaProduceVer   = 3 // Version of the produce requests, the first one carrying record batches
+	kafkaTimeout      = 30 * time.Second
+	kafkaMaxBatchSize = 1 << 20 // Maximum size of the records of a produce request, the default broker limit
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// kafkaError is an error code returned by the broker for a produce request.
+type kafkaError int16
+
+func (e kafkaError) Error() string {
+	return fmt.Sprintf("kafka produce failed with error code %d", int16(e))
+}
+
+// rejected reports whether the broker refused the records themselves, which
+// can't be produced by retrying.
+func (e kafkaError) rejected() bool {
+	switch e {
+	case 10, 18, 87: // MESSAGE_TOO_LARGE, RECORD_LIST_TOO_LARGE, INVALID_RECORD
+		return true
+	}
+	return false
+}
+
+// fatal reports whether the broker refused the requests due to the settings of
+// the sink or of the broker, which no request can succeed with.
+func (e kafkaError) fatal() bool {
+	switch e {
+	case 17, 21, 29, 31, 35, 43, 44: // INVALID_TOPIC_EXCEPTION, INVALID_REQUIRED_ACKS, TOPIC_AUTHORIZATION_FAILED, CLUSTER_AUTHORIZATION_FAILED, UNSUPPORTED_VERSION, UNSUPPORTED_FOR_MESSAGE_FORMAT, POLICY_VIOLATION
+		return true
+	}
+	return false
+}
+
+// kafkaSink produces the records to a partition of a topic, speaking the Kafka
+// wire protocol to the broker leading the partition. Each record is keyed by
+// its identifier and holds its JSON encoding.
+//
+// The producer doesn't discover the partition leaders, it is meant for single
+// broker deployments and Kafka compatible services exposing a single endpoint.
+type kafkaSink struct {
+	addr      string
+	topic     string
+	partition int32
+
+	conn          net.Conn
+	reader        *bufio.Reader
+	correlationID int32
+}
+
+// newKafkaSink creates a sink producing to a partition of a topic through the
+// broker at the given address.
+func newKafkaSink(addr string, topic string, partition int32) *kafkaSink {
+	return &kafkaSink{addr: addr, topic: topic, partition: partition}
+}
+
+// Write implements Sink, producing the records in batches acknowledged by all
+// the in-sync replicas. Records refused by the broker, e.g. beyond its size
+// limit, are dropped as they would be retried forever.
+func (s *kafkaSink) Write(ctx context.Context, records []*Record) error {
+	var (
+		keys   [][]byte
+		values [][]byte
+		size   int
+	)
+	for _, record := range records {
+		value, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		if size+len(value) > kafkaMaxBatchSize && len(values) > 0 {
+			if err := s.produceOrDrop(ctx, keys, values); err != nil {
+				return err
+			}
+			keys, values, size = nil, nil, 0
+		}
+		keys, values = append(keys, []byte(record.ID)), append(values, value)
+		size += len(record.ID) + len(value)
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	return s.produceOrDrop(ctx, keys, values)
+}
+
+// produceOrDrop produces a batch of records. If the broker refuses the batch,
+// its records are produced one by one, dropping those refused too. If the
+// broker refuses any request, the export is stopped.
+func (s *kafkaSink) produceOrDrop(ctx context.Context, keys, values [][]byte) error {
+	err := s.produce(ctx, keys, values)
+
+	var code kafkaError
+	switch {
+	case !errors.As(err, &code):
+		return err
+	case code.fatal():
+		return fmt.Errorf("%w: %v", errSinkFatal, err)
+	case !code.rejected():
+		return err
+	case len(values) == 1:
+		log.Error("Dropping record refused by Kafka broker", "id", string(keys[0]), "size", len(values[0]), "err", err)
+		return nil
+	}
+	for i := range values {
+		if err := s.produceOrDrop(ctx, keys[i:i+1], values[i:i+1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// produce sends a produce request holding a single record batch and waits for
+// its acknowledgement. The connection is dropped on any failure, a new one is
+// established by the next request.
+func (s *kafkaSink) produce(ctx context.Context, keys, values [][]byte) error {
+	if s.conn == nil {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+		if err != nil {
+			return err
+		}
+		s.conn, s.reader = conn, bufio.NewReader(conn)
+	}
+	deadline := time.Now().Add(kafkaTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	s.conn.SetDeadline(deadline)
+
+	s.correlationID++
+	err := s.roundTrip(s.correlationID, keys, values)
+	if err != nil {
+		s.conn.Close()
+		s.conn, s.reader = nil, nil
+	}
+	return err
+}
+
+func (s *kafkaSink) roundTrip(correlationID int32, keys, values [][]byte) error {
+	req := encodeProduceRequest(correlationID, s.topic, s.partition, encodeRecordBatch(keys, values, time.Now()))
+	if _, err := s.conn.Write(req); err != nil {
+		return err
+	}
+	var size [4]byte
+	if _, err := io.ReadFull(s.reader, size[:]); err != nil {
+		return err
+	}
+	resp := make([]byte, binary.BigEndian.Uint32(size[:]))
+	if _, err := io.ReadFull(s.reader, resp); err != nil {
+		return err
+	}
+	return decodeProduceResponse(resp, correlationID)
+}
+
+// Close implements Sink.
+func (s *kafkaSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
+
+// encodeProduceRequest encodes a size delimited produce request for a record
+// batch, to be acknowledged by all the in-sync replicas.
+func encodeProduceRequest(correlationID int32, topic string, partition int32, batch []byte) []byte {
+	req := make([]byte, 4, 64+len(topic)+len(batch))
+	req = binary.BigEndian.AppendUint16(req, kafkaProduceKey)
+	req = binary.BigEndian.AppendUint16(req, kafkaProduceVer)
+	req = binary.BigEndian.AppendUint32(req, uint32(correlationID))
+	req = appendKafkaString(req, kafkaClientID)
+
+	req = binary.BigEndian.AppendUint16(req, 0xffff) // null transactional id
+	req = binary.BigEndian.AppendUint16(req, 0xffff) // acks from all in-sync replicas
+	req = binary.BigEndian.AppendUint32(req, uint32(kafkaTimeout/time.Millisecond))
+	req = binary.BigEndian.AppendUint32(req, 1) // topics
+	req = appendKafkaString(req, topic)
+	req = binary.BigEndian.AppendUint32(req, 1) // partitions
+	req = binary.BigEndian.AppendUint32(req, uint32(partition))
+	req = binary.BigEndian.AppendUint32(req, uint32(len(batch)))
+	req = append(req, batch...)
+
+	binary.BigEndian.PutUint32(req, uint32(len(req)-4))
+	return req
+}
+
+// encodeRecordBatch encodes the records into a v2 record batch, without
+// compression nor idempotence.
+func encodeRecordBatch(keys, values [][]byte, now time.Time) []byte {
+	timestamp := uint64(now.UnixMilli())
+
+	// Encode the part of the batch covered by the checksum
+	body := binary.BigEndian.AppendUint16(nil, 0) // attributes
+	body = binary.BigEndian.AppendUint32(body, uint32(len(values)-1))
+	body = binary.BigEndian.AppendUint64(body, timestamp)
+	body = binary.BigEndian.AppendUint64(body, timestamp)
+	body = binary.BigEndian.AppendUint64(body, 0xffffffffffffffff) // no producer id
+	body = binary.BigEndian.AppendUint16(body, 0xffff)             // no producer epoch
+	body = binary.BigEndian.AppendUint32(body, 0xffffffff)         // no base sequence
+	body = binary.BigEndian.AppendUint32(body, uint32(len(values)))
+	for i := range values {
+		var record []byte
+		record = append(record, 0)                     // attributes
+		record = binary.AppendVarint(record, 0)        // timestamp delta
+		record = binary.AppendVarint(record, int64(i)) // offset delta
+		record = binary.AppendVarint(record, int64(len(keys[i])))
+		record = append(record, keys[i]...)
+		record = binary.AppendVarint(record, int64(len(values[i])))
+		record = append(record, values[i]...)
+		record = binary.AppendVarint(record, 0) // headers
+
+		body = binary.AppendVarint(body, int64(len(record)))
+		body = append(body, record...)
+	}
+	batch := binary.BigEndian.AppendUint64(nil, 0) // base offset
+	batch = binary.BigEndian.AppendUint32(batch, uint32(4+1+4+len(body)))
+	batch = binary.BigEndian.AppendUint32(batch, 0xffffffff) // partition leader epoch
+	batch = append(batch, 2)                                 // magic
+	batch = binary.BigEndian.AppendUint32(batch, crc32.Checksum(body, crc32c))
+	return append(batch, body...)
+}
+
+// decodeProduceResponse checks the response to a produce request for errors.
+func decodeProduceResponse(resp []byte, correlationID int32) error {
+	errShort := errors.New("short kafka produce response")
+	if len(resp) < 8 {
+		return errShort
+	}
+	if id := int32(binary.BigEndian.Uint32(resp)); id != correlationID {
+		return fmt.Errorf("kafka correlation id mismatch: have %d, want %d", id, correlationID)
+	}
+	topics := binary.BigEndian.Uint32(resp[4:])
+	resp = resp[8:]
+	for i := uint32(0); i < topics; i++ {
+		if len(resp) < 2 {
+			return errShort
+		}
+		n := int(binary.BigEndian.Uint16(resp))
+		if len(resp) < 2+n+4 {
+			return errShort
+		}
+		partitions := binary.BigEndian.Uint32(resp[2+n:])
+		resp = resp[2+n+4:]
+		for j := uint32(0); j < partitions; j++ {
+			// partition (int32), error code (int16), base offset (int64), log append time (int64)
+			if len(resp) < 4+2+8+8 {
+				return errShort
+			}
+			if code := kafkaError(binary.BigEndian.Uint16(resp[4:])); code != 0 {
+				return code
+			}
+			resp = resp[4+2+8+8:]
+		}
+	}
+	return nil
+}
+
+// appendKafkaString appends a length prefixed string.
+func appendKafkaString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
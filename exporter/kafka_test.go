@@ -0,0 +1,194 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Tests that the record batches carry the records and a valid checksum.
+func TestKafkaRecordBatch(t *testing.T) {
+	var (
+		keys   = [][]byte{[]byte("a"), []byte("bb")}
+		values = [][]byte{[]byte(`{"kind":"block"}`), []byte(`{"kind":"retract"}`)}
+	)
+	batch := encodeRecordBatch(keys, values, time.Unix(1, 0))
+
+	if length := int(binary.BigEndian.Uint32(batch[8:])); length != len(batch)-12 {
+		t.Fatalf("batch length mismatch: have %d, want %d", length, len(batch)-12)
+	}
+	if magic := batch[16]; magic != 2 {
+		t.Fatalf("magic mismatch: have %d, want 2", magic)
+	}
+	if crc := binary.BigEndian.Uint32(batch[17:]); crc != crc32.Checksum(batch[21:], crc32c) {
+		t.Fatalf("checksum mismatch")
+	}
+	body := batch[21:]
+	if count := binary.BigEndian.Uint32(body[36:]); count != 2 {
+		t.Fatalf("record count mismatch: have %d, want 2", count)
+	}
+	records := body[40:]
+	for i := range values {
+		size, n := binary.Varint(records)
+		record := records[n : n+int(size)]
+		records = records[n+int(size):]
+
+		record = record[1:] // attributes
+		for _, want := range []int64{0, int64(i)} {
+			delta, n := binary.Varint(record)
+			if delta != want {
+				t.Fatalf("record %d: delta mismatch: have %d, want %d", i, delta, want)
+			}
+			record = record[n:]
+		}
+		for _, want := range [][]byte{keys[i], values[i]} {
+			size, n := binary.Varint(record)
+			if have := record[n : n+int(size)]; !bytes.Equal(have, want) {
+				t.Fatalf("record %d: field mismatch: have %q, want %q", i, have, want)
+			}
+			record = record[n+int(size):]
+		}
+	}
+	if len(records) != 0 {
+		t.Fatalf("trailing batch bytes: %x", records)
+	}
+}
+
+// produceResponse creates the response to a produce request for a partition.
+func produceResponse(correlationID int32, code int16) []byte {
+	resp := binary.BigEndian.AppendUint32(nil, uint32(correlationID))
+	resp = binary.BigEndian.AppendUint32(resp, 1) // topics
+	resp = appendKafkaString(resp, "topic")
+	resp = binary.BigEndian.AppendUint32(resp, 1) // partitions
+	resp = binary.BigEndian.AppendUint32(resp, 0)
+	resp = binary.BigEndian.AppendUint16(resp, uint16(code))
+	resp = binary.BigEndian.AppendUint64(resp, 10) // base offset
+	resp = binary.BigEndian.AppendUint64(resp, 0)  // log append time
+	return binary.BigEndian.AppendUint32(resp, 0)  // throttle time
+}
+
+// Tests that the produce responses are checked for errors.
+func TestKafkaProduceResponse(t *testing.T) {
+	if err := decodeProduceResponse(produceResponse(7, 0), 7); err != nil {
+		t.Errorf("valid response rejected: %v", err)
+	}
+	if err := decodeProduceResponse(produceResponse(6, 0), 7); err == nil {
+		t.Error("correlation id mismatch accepted")
+	}
+	if err := decodeProduceResponse(produceResponse(7, 6), 7); err == nil {
+		t.Error("error code accepted")
+	}
+	if err := decodeProduceResponse(produceResponse(7, 0)[:20], 7); err == nil {
+		t.Error("short response accepted")
+	}
+}
+
+// testBroker is a Kafka broker answering the produce requests with the error
+// code returned by respond for their size.
+type testBroker struct {
+	listener net.Listener
+	respond  func(size int) int16
+	accepted chan int // Sizes of the accepted requests
+}
+
+func newTestBroker(t *testing.T, respond func(size int) int16) *testBroker {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	b := &testBroker{listener: listener, respond: respond, accepted: make(chan int, 16)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(conn)
+		}
+	}()
+	return b
+}
+
+func (b *testBroker) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return
+		}
+		req := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return
+		}
+		code := b.respond(len(req))
+		if code == 0 {
+			b.accepted <- len(req)
+		}
+		resp := produceResponse(int32(binary.BigEndian.Uint32(req[4:])), code)
+		conn.Write(append(binary.BigEndian.AppendUint32(nil, uint32(len(resp))), resp...))
+	}
+}
+
+// Tests that the records refused by the broker are dropped instead of being
+// retried forever, and that the requests refused due to the settings stop the
+// export.
+func TestKafkaRefusedRecords(t *testing.T) {
+	records := []*Record{{ID: "a"}, {ID: strings.Repeat("b", 2000)}, {ID: "c"}}
+
+	// Records over the broker limit are dropped, the others produced
+	broker := newTestBroker(t, func(size int) int16 {
+		if size > 1000 {
+			return 10 // MESSAGE_TOO_LARGE
+		}
+		return 0
+	})
+	sink := newKafkaSink(broker.listener.Addr().String(), "topic", 0)
+	defer sink.Close()
+
+	if err := sink.Write(context.Background(), records); err != nil {
+		t.Fatalf("failed to write records: %v", err)
+	}
+	if len(broker.accepted) != 2 {
+		t.Errorf("accepted request count mismatch: have %d, want 2", len(broker.accepted))
+	}
+	// Refused requests stop the export, others are retried
+	for code, fatal := range map[int16]bool{29: true, 6: false} {
+		code := code
+		broker := newTestBroker(t, func(int) int16 { return code })
+		sink := newKafkaSink(broker.listener.Addr().String(), "topic", 0)
+		defer sink.Close()
+
+		err := sink.Write(context.Background(), records[:1])
+		if err == nil {
+			t.Fatalf("code %d: write succeeded", code)
+		}
+		if errors.Is(err, errSinkFatal) != fatal {
+			t.Errorf("code %d: fatal mismatch: have %v, want %v", code, errors.Is(err, errSinkFatal), fatal)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Kinds of the exported records.
+const (
+	KindBlock       = "block"
+	KindTransaction = "transaction"
+	KindReceipt     = "receipt"
+	KindLog         = "log"
+	KindRetract     = "retract" // The block left the canonical chain, its records are void
+)
+
+// Record is an exported record. The block, transaction, receipt and log records
+// of a block are exported together, followed by a retract record if the block
+// leaves the canonical chain.
+type Record struct {
+	Kind        string         `json:"kind"`
+	ID          string         `json:"id"` // Unique identifier of the record, for deduplication by consumers
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	BlockHash   common.Hash    `json:"blockHash"`
+
+	Block       *Block       `json:"block,omitempty"`
+	Transaction *Transaction `json:"transaction,omitempty"`
+	Receipt     *Receipt     `json:"receipt,omitempty"`
+	Log         *Log         `json:"log,omitempty"`
+}
+
+// Block is the exported header of a block.
+type Block struct {
+	ParentHash   common.Hash    `json:"parentHash"`
+	Timestamp    hexutil.Uint64 `json:"timestamp"`
+	Miner        common.Address `json:"miner"`
+	GasLimit     hexutil.Uint64 `json:"gasLimit"`
+	GasUsed      hexutil.Uint64 `json:"gasUsed"`
+	BaseFee      *hexutil.Big   `json:"baseFeePerGas,omitempty"`
+	Transactions hexutil.Uint   `json:"transactionCount"`
+}
+
+// Transaction is an exported transaction.
+type Transaction struct {
+	Hash     common.Hash     `json:"hash"`
+	Index    hexutil.Uint    `json:"transactionIndex"`
+	Type     hexutil.Uint64  `json:"type"`
+	From     common.Address  `json:"from"`
+	To       *common.Address `json:"to"`
+	Nonce    hexutil.Uint64  `json:"nonce"`
+	Value    *hexutil.Big    `json:"value"`
+	Gas      hexutil.Uint64  `json:"gas"`
+	GasPrice *hexutil.Big    `json:"gasPrice"`
+	Input    hexutil.Bytes   `json:"input"`
+}
+
+// Receipt is an exported transaction receipt.
+type Receipt struct {
+	TransactionHash   common.Hash     `json:"transactionHash"`
+	TransactionIndex  hexutil.Uint    `json:"transactionIndex"`
+	Status            hexutil.Uint64  `json:"status"`
+	GasUsed           hexutil.Uint64  `json:"gasUsed"`
+	CumulativeGasUsed hexutil.Uint64  `json:"cumulativeGasUsed"`
+	EffectiveGasPrice *hexutil.Big    `json:"effectiveGasPrice,omitempty"`
+	ContractAddress   *common.Address `json:"contractAddress"`
+}
+
+// Log is an exported log.
+type Log struct {
+	TransactionHash  common.Hash    `json:"transactionHash"`
+	TransactionIndex hexutil.Uint   `json:"transactionIndex"`
+	Index            hexutil.Uint   `json:"logIndex"`
+	Address          common.Address `json:"address"`
+	Topics           []common.Hash  `json:"topics"`
+	Data             hexutil.Bytes  `json:"data"`
+}
+
+// blockRecords creates the records of a canonical block: the block itself, then
+// each transaction followed by its receipt and logs.
+func blockRecords(block *types.Block, receipts types.Receipts, config *params.ChainConfig) []*Record {
+	var (
+		number = hexutil.Uint64(block.NumberU64())
+		hash   = block.Hash()
+		signer = types.MakeSigner(config, block.Number(), block.Time())
+		txs    = block.Transactions()
+	)
+	record := func(kind string, id string) *Record {
+		return &Record{Kind: kind, ID: fmt.Sprintf("%s:%x%s", kind, hash, id), BlockNumber: number, BlockHash: hash}
+	}
+	header := record(KindBlock, "")
+	header.Block = &Block{
+		ParentHash:   block.ParentHash(),
+		Timestamp:    hexutil.Uint64(block.Time()),
+		Miner:        block.Coinbase(),
+		GasLimit:     hexutil.Uint64(block.GasLimit()),
+		GasUsed:      hexutil.Uint64(block.GasUsed()),
+		BaseFee:      (*hexutil.Big)(block.BaseFee()),
+		Transactions: hexutil.Uint(len(txs)),
+	}
+	records := []*Record{header}
+	for i, tx := range txs {
+		from, _ := types.Sender(signer, tx)
+		r := record(KindTransaction, fmt.Sprintf(":%d", i))
+		r.Transaction = &Transaction{
+			Hash:     tx.Hash(),
+			Index:    hexutil.Uint(i),
+			Type:     hexutil.Uint64(tx.Type()),
+			From:     from,
+			To:       tx.To(),
+			Nonce:    hexutil.Uint64(tx.Nonce()),
+			Value:    (*hexutil.Big)(tx.Value()),
+			Gas:      hexutil.Uint64(tx.Gas()),
+			GasPrice: (*hexutil.Big)(tx.GasPrice()),
+			Input:    tx.Data(),
+		}
+		records = append(records, r)
+
+		if i >= len(receipts) {
+			continue
+		}
+		receipt := receipts[i]
+		r = record(KindReceipt, fmt.Sprintf(":%d", i))
+		r.Receipt = &Receipt{
+			TransactionHash:   tx.Hash(),
+			TransactionIndex:  hexutil.Uint(i),
+			Status:            hexutil.Uint64(receipt.Status),
+			GasUsed:           hexutil.Uint64(receipt.GasUsed),
+			CumulativeGasUsed: hexutil.Uint64(receipt.CumulativeGasUsed),
+			EffectiveGasPrice: (*hexutil.Big)(receipt.EffectiveGasPrice),
+		}
+		if receipt.ContractAddress != (common.Address{}) {
+			address := receipt.ContractAddress
+			r.Receipt.ContractAddress = &address
+		}
+		records = append(records, r)
+
+		for _, log := range receipt.Logs {
+			r = record(KindLog, fmt.Sprintf(":%d", log.Index))
+			r.Log = &Log{
+				TransactionHash:  tx.Hash(),
+				TransactionIndex: hexutil.Uint(i),
+				Index:            hexutil.Uint(log.Index),
+				Address:          log.Address,
+				Topics:           log.Topics,
+				Data:             log.Data,
+			}
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+// retractRecord creates the record voiding the records of a block which left
+// the canonical chain.
+func retractRecord(number uint64, hash common.Hash) *Record {
+	return &Record{Kind: KindRetract, ID: fmt.Sprintf("%s:%x", KindRetract, hash), BlockNumber: hexutil.Uint64(number), BlockHash: hash}
+}
@@ -0,0 +1,179 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// errSinkFatal is returned by the sinks which can't write any records without
+// operator intervention, stopping the export.
+var errSinkFatal = errors.New("sink failed permanently")
+
+// Sink is a destination of the exported records.
+type Sink interface {
+	// Write delivers the records of a block in order. If an error is returned,
+	// the same records are written again later, unless it wraps errSinkFatal.
+	Write(ctx context.Context, records []*Record) error
+
+	// Close releases the resources of the sink.
+	Close() error
+}
+
+// fileSink writes the records as newline delimited JSON into files, starting
+// a new one once the current one reaches the size limit.
+type fileSink struct {
+	dir     string
+	maxSize uint64
+
+	file *os.File
+	size uint64
+}
+
+// newFileSink creates a sink writing NDJSON files into the given directory.
+func newFileSink(dir string, maxSize uint64) (*fileSink, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &fileSink{dir: dir, maxSize: maxSize}, nil
+}
+
+// Write implements Sink, appending the records to the current file.
+func (s *fileSink) Write(ctx context.Context, records []*Record) error {
+	if s.file == nil || (s.maxSize > 0 && s.size >= s.maxSize) {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+	}
+	// Drop any partially written records, they are written again
+	if _, err := s.file.Write(buf.Bytes()); err != nil {
+		s.file.Truncate(int64(s.size))
+		s.file.Seek(int64(s.size), io.SeekStart)
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+	s.size += uint64(buf.Len())
+	return nil
+}
+
+// rotate closes the current file and creates a new one, named after the time
+// so that the files sort in the order they were written.
+func (s *fileSink) rotate() error {
+	if s.file != nil {
+		if err := s.file.Close(); err != nil {
+			return err
+		}
+		s.file = nil
+	}
+	name := filepath.Join(s.dir, fmt.Sprintf("blocks-%s.ndjson", time.Now().UTC().Format("20060102T150405.000000000")))
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	s.file, s.size = file, 0
+	return nil
+}
+
+// Close implements Sink.
+func (s *fileSink) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	return s.file.Close()
+}
+
+// webhookSink posts the records of each block as a JSON array to an HTTP
+// endpoint, retrying with exponential backoff until it responds with a 2xx
+// status code.
+type webhookSink struct {
+	url     string
+	retries int
+	client  *http.Client
+}
+
+// newWebhookSink creates a sink posting to the given URL, attempting each
+// delivery the given number of times before reporting a failure.
+func newWebhookSink(url string, retries int) *webhookSink {
+	if retries < 1 {
+		retries = 1
+	}
+	return &webhookSink{url: url, retries: retries, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Write implements Sink.
+func (s *webhookSink) Write(ctx context.Context, records []*Record) error {
+	body, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	backoff := webhookBackoff
+	for attempt := 1; ; attempt++ {
+		if err = s.post(ctx, body); err == nil || attempt >= s.retries {
+			return err
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if backoff *= 2; backoff > maxWebhookBackoff {
+			backoff = maxWebhookBackoff
+		}
+	}
+}
+
+func (s *webhookSink) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// Close implements Sink.
+func (s *webhookSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
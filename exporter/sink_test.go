@@ -0,0 +1,117 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Tests that the file sink writes a JSON record per line, starting a new file
+// once the size limit is reached.
+func TestFileSink(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := newFileSink(dir, 1)
+	if err != nil {
+		t.Fatalf("failed to create sink: %v", err)
+	}
+	for i := uint64(1); i <= 3; i++ {
+		records := []*Record{retractRecord(i, common.Hash{byte(i)}), retractRecord(i, common.Hash{byte(i), 1})}
+		if err := sink.Write(context.Background(), records); err != nil {
+			t.Fatalf("failed to write records: %v", err)
+		}
+	}
+	sink.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "blocks-*.ndjson"))
+	if len(files) != 3 {
+		t.Fatalf("file count mismatch: have %d, want 3", len(files))
+	}
+	sort.Strings(files)
+	for i, name := range files {
+		file, err := os.Open(name)
+		if err != nil {
+			t.Fatalf("failed to open file: %v", err)
+		}
+		var lines int
+		for scanner := bufio.NewScanner(file); scanner.Scan(); lines++ {
+			var record Record
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				t.Fatalf("file %d line %d: invalid record: %v", i, lines, err)
+			}
+			if record.Kind != KindRetract || uint64(record.BlockNumber) != uint64(i+1) {
+				t.Errorf("file %d line %d: record mismatch: %+v", i, lines, record)
+			}
+		}
+		file.Close()
+		if lines != 2 {
+			t.Errorf("file %d: line count mismatch: have %d, want 2", i, lines)
+		}
+	}
+}
+
+// Tests that the webhook sink retries failed deliveries up to its limit.
+func TestWebhookSink(t *testing.T) {
+	defer func(backoff time.Duration) { webhookBackoff = backoff }(webhookBackoff)
+	webhookBackoff = time.Millisecond
+
+	var (
+		calls    int
+		failures = 2
+		received []*Record
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	records := []*Record{retractRecord(1, common.Hash{1}), retractRecord(2, common.Hash{2})}
+
+	// Fail with fewer attempts than failures, then succeed with enough
+	sink := newWebhookSink(server.URL, failures)
+	if err := sink.Write(context.Background(), records); err == nil {
+		t.Fatal("delivery succeeded despite failures")
+	}
+	calls = 0
+	sink = newWebhookSink(server.URL, failures+1)
+	if err := sink.Write(context.Background(), records); err != nil {
+		t.Fatalf("failed to deliver records: %v", err)
+	}
+	if calls != failures+1 {
+		t.Errorf("attempt count mismatch: have %d, want %d", calls, failures+1)
+	}
+	if len(received) != len(records) || received[1].ID != records[1].ID {
+		t.Errorf("received records mismatch: %+v", received)
+	}
+}
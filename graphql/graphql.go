@@ -32,9 +32,11 @@ import (
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/consensus/misc/eip1559"
+	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/eth/tokens"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -138,6 +140,200 @@ func (a *Account) Storage(ctx context.Context, args struct{ Slot common.Hash })
 	return state.GetState(a.address, args.Slot), nil
 }
 
+// TransactionsPage is a page of the transactions of an account.
+type TransactionsPage struct {
+	transactions []*Transaction
+	cursor       *ethapi.TxHistoryCursor
+}
+
+func (p *TransactionsPage) Transactions(ctx context.Context) []*Transaction {
+	return p.transactions
+}
+
+func (p *TransactionsPage) Cursor(ctx context.Context) *string {
+	if p.cursor == nil {
+		return nil
+	}
+	cursor := p.cursor.String()
+	return &cursor
+}
+
+func (a *Account) Transactions(ctx context.Context, args struct {
+	Cursor *string
+	Limit  *int32
+}) (*TransactionsPage, error) {
+	var cursor *ethapi.TxHistoryCursor
+	if args.Cursor != nil {
+		cursor = new(ethapi.TxHistoryCursor)
+		if err := cursor.UnmarshalText([]byte(*args.Cursor)); err != nil {
+			return nil, err
+		}
+	}
+	var limit int
+	if args.Limit != nil {
+		if *args.Limit < 0 {
+			return nil, errors.New("negative limit")
+		}
+		limit = int(*args.Limit)
+	}
+	txs, next, err := ethapi.AddressTransactions(ctx, a.r.backend, a.address, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		page   = &TransactionsPage{transactions: make([]*Transaction, 0, len(txs)), cursor: next}
+		blocks = make(map[common.Hash]*Block)
+	)
+	for _, tx := range txs {
+		block, ok := blocks[tx.BlockHash]
+		if !ok {
+			numberOrHash := rpc.BlockNumberOrHashWithHash(tx.BlockHash, false)
+			block = &Block{r: a.r, numberOrHash: &numberOrHash, hash: tx.BlockHash}
+			blocks[tx.BlockHash] = block
+		}
+		// Omit the transactions of blocks whose bodies have been pruned
+		b, err := block.resolve(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if b == nil || int(tx.Index) >= len(b.Transactions()) {
+			continue
+		}
+		page.transactions = append(page.transactions, &Transaction{
+			r:     a.r,
+			hash:  tx.Hash,
+			tx:    b.Transactions()[tx.Index],
+			block: block,
+			index: uint64(tx.Index),
+		})
+	}
+	return page, nil
+}
+
+// TokenBalance is the balance of an account in a token.
+type TokenBalance struct {
+	balance *rawdb.TokenBalance
+}
+
+func (b *TokenBalance) Token(ctx context.Context) common.Address {
+	return b.balance.Token
+}
+
+func (b *TokenBalance) Standard(ctx context.Context) string {
+	return b.balance.Standard.String()
+}
+
+func (b *TokenBalance) Id(ctx context.Context) *hexutil.Big {
+	if b.balance.Standard == rawdb.TokenERC20 {
+		return nil
+	}
+	return (*hexutil.Big)(b.balance.ID)
+}
+
+func (b *TokenBalance) Balance(ctx context.Context) hexutil.Big {
+	return hexutil.Big(*b.balance.Balance)
+}
+
+func (a *Account) TokenBalances(ctx context.Context) ([]*TokenBalance, error) {
+	balances, err := tokens.Balances(a.r.backend.ChainDb(), a.address)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*TokenBalance, len(balances))
+	for i, balance := range balances {
+		result[i] = &TokenBalance{balance: balance}
+	}
+	return result, nil
+}
+
+// TokenTransfer is a transfer of tokens logged by a token contract.
+type TokenTransfer struct {
+	r        *Resolver
+	transfer *rawdb.TokenTransfer
+}
+
+func (t *TokenTransfer) Token(ctx context.Context) common.Address {
+	return t.transfer.Token
+}
+
+func (t *TokenTransfer) Standard(ctx context.Context) string {
+	return t.transfer.Standard.String()
+}
+
+func (t *TokenTransfer) From(ctx context.Context) common.Address {
+	return t.transfer.From
+}
+
+func (t *TokenTransfer) To(ctx context.Context) common.Address {
+	return t.transfer.To
+}
+
+func (t *TokenTransfer) Id(ctx context.Context) *hexutil.Big {
+	if t.transfer.Standard == rawdb.TokenERC20 {
+		return nil
+	}
+	return (*hexutil.Big)(t.transfer.ID)
+}
+
+func (t *TokenTransfer) Value(ctx context.Context) hexutil.Big {
+	return hexutil.Big(*t.transfer.Value)
+}
+
+func (t *TokenTransfer) Transaction(ctx context.Context) *Transaction {
+	return &Transaction{r: t.r, hash: t.transfer.TxHash}
+}
+
+func (t *TokenTransfer) Index(ctx context.Context) hexutil.Uint64 {
+	return hexutil.Uint64(t.transfer.LogIndex)
+}
+
+// TokenTransfersPage is a page of the token transfers of an account.
+type TokenTransfersPage struct {
+	transfers []*TokenTransfer
+	cursor    *filters.LogCursor
+}
+
+func (p *TokenTransfersPage) Transfers(ctx context.Context) []*TokenTransfer {
+	return p.transfers
+}
+
+func (p *TokenTransfersPage) Cursor(ctx context.Context) *string {
+	if p.cursor == nil {
+		return nil
+	}
+	cursor := p.cursor.String()
+	return &cursor
+}
+
+func (a *Account) TokenTransfers(ctx context.Context, args struct {
+	Cursor *string
+	Limit  *int32
+}) (*TokenTransfersPage, error) {
+	var cursor *filters.LogCursor
+	if args.Cursor != nil {
+		cursor = new(filters.LogCursor)
+		if err := cursor.UnmarshalText([]byte(*args.Cursor)); err != nil {
+			return nil, err
+		}
+	}
+	var limit int
+	if args.Limit != nil {
+		if *args.Limit < 0 {
+			return nil, errors.New("negative limit")
+		}
+		limit = int(*args.Limit)
+	}
+	transfers, next, err := tokens.Transfers(a.r.backend.ChainDb(), a.address, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+	page := &TokenTransfersPage{transfers: make([]*TokenTransfer, len(transfers)), cursor: next}
+	for i, transfer := range transfers {
+		page.transfers[i] = &TokenTransfer{r: a.r, transfer: transfer}
+	}
+	return page, nil
+}
+
 // Log represents an individual log message. All arguments are mandatory.
 type Log struct {
 	r           *Resolver
@@ -169,6 +365,10 @@ func (l *Log) Data(ctx context.Context) hexutil.Bytes {
 	return l.log.Data
 }
 
+func (l *Log) Removed(ctx context.Context) bool {
+	return l.log.Removed
+}
+
 // AccessTuple represents EIP-2930
 type AccessTuple struct {
 	address     common.Address
@@ -1283,6 +1483,9 @@ func (p *Pending) EstimateGas(ctx context.Context, args struct {
 type Resolver struct {
 	backend      ethapi.Backend
 	filterSystem *filters.FilterSystem
+
+	eventsOnce sync.Once
+	events     *filters.EventSystem // Event system backing the subscriptions, created on first use
 }
 
 func (r *Resolver) Block(ctx context.Context, args struct {
@@ -1407,26 +1610,82 @@ type FilterCriteria struct {
 }
 
 func (r *Resolver) Logs(ctx context.Context, args struct{ Filter FilterCriteria }) ([]*Log, error) {
+	return runFilter(ctx, r, r.newFilter(&args.Filter))
+}
+
+// LogsPage is a page of the results of a paginated log query.
+type LogsPage struct {
+	logs   []*Log
+	cursor *filters.LogCursor
+}
+
+func (p *LogsPage) Logs(ctx context.Context) []*Log {
+	return p.logs
+}
+
+func (p *LogsPage) Cursor(ctx context.Context) *string {
+	if p.cursor == nil {
+		return nil
+	}
+	cursor := p.cursor.String()
+	return &cursor
+}
+
+func (r *Resolver) LogsPage(ctx context.Context, args struct {
+	Filter FilterCriteria
+	Cursor *string
+	Limit  *int32
+}) (*LogsPage, error) {
+	var cursor *filters.LogCursor
+	if args.Cursor != nil {
+		cursor = new(filters.LogCursor)
+		if err := cursor.UnmarshalText([]byte(*args.Cursor)); err != nil {
+			return nil, err
+		}
+	}
+	var limit int
+	if args.Limit != nil {
+		if *args.Limit < 0 {
+			return nil, errors.New("negative limit")
+		}
+		limit = int(*args.Limit)
+	}
+	logs, next, err := r.newFilter(&args.Filter).LogsPage(ctx, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+	page := &LogsPage{logs: make([]*Log, 0, len(logs)), cursor: next}
+	for _, log := range logs {
+		page.logs = append(page.logs, &Log{
+			r:           r,
+			transaction: &Transaction{r: r, hash: log.TxHash},
+			log:         log,
+		})
+	}
+	return page, nil
+}
+
+// newFilter creates the range filter of a log query.
+func (r *Resolver) newFilter(crit *FilterCriteria) *filters.Filter {
 	// Convert the RPC block numbers into internal representations
 	begin := rpc.LatestBlockNumber.Int64()
-	if args.Filter.FromBlock != nil {
-		begin = int64(*args.Filter.FromBlock)
+	if crit.FromBlock != nil {
+		begin = int64(*crit.FromBlock)
 	}
 	end := rpc.LatestBlockNumber.Int64()
-	if args.Filter.ToBlock != nil {
-		end = int64(*args.Filter.ToBlock)
+	if crit.ToBlock != nil {
+		end = int64(*crit.ToBlock)
 	}
 	var addresses []common.Address
-	if args.Filter.Addresses != nil {
-		addresses = *args.Filter.Addresses
+	if crit.Addresses != nil {
+		addresses = *crit.Addresses
 	}
 	var topics [][]common.Hash
-	if args.Filter.Topics != nil {
-		topics = *args.Filter.Topics
+	if crit.Topics != nil {
+		topics = *crit.Topics
 	}
 	// Construct the range filter
-	filter := r.filterSystem.NewRangeFilter(begin, end, addresses, topics)
-	return runFilter(ctx, r, filter)
+	return r.filterSystem.NewRangeFilter(begin, end, addresses, topics)
 }
 
 func (r *Resolver) GasPrice(ctx context.Context) (hexutil.Big, error) {
@@ -41,6 +41,7 @@ import (
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/params"
 
+	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -422,6 +423,175 @@ func TestWithdrawals(t *testing.T) {
 	}
 }
 
+// Tests that subscriptions are streamed over websocket connections speaking the
+// graphql-transport-ws protocol, next to plain queries.
+func TestGraphQLSubscriptions(t *testing.T) {
+	stack := createNode(t)
+	defer stack.Close()
+
+	genesis := &core.Genesis{
+		Config:     params.AllEthashProtocolChanges,
+		GasLimit:   11500000,
+		Difficulty: big.NewInt(1048576),
+	}
+	ethBackend, err := eth.New(stack, &ethconfig.Config{
+		Genesis:        genesis,
+		NetworkId:      1337,
+		TrieCleanCache: 5,
+		TrieDirtyCache: 5,
+		TrieTimeout:    60 * time.Minute,
+		SnapshotCache:  5,
+	})
+	if err != nil {
+		t.Fatalf("could not create eth backend: %v", err)
+	}
+	filterSystem := filters.NewFilterSystem(ethBackend.APIBackend, filters.Config{})
+	if _, err := newHandler(stack, ethBackend.APIBackend, filterSystem, []string{}, []string{}); err != nil {
+		t.Fatalf("could not create graphql service: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	endpoint := "ws" + strings.TrimPrefix(stack.HTTPEndpoint(), "http") + "/graphql"
+	dialer := websocket.Dialer{Subprotocols: []string{wsProtocol}}
+	conn, _, err := dialer.Dial(endpoint, nil)
+	if err != nil {
+		t.Fatalf("could not dial websocket: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+
+	send := func(id, typ, payload string) {
+		msg := wsMessage{ID: id, Type: typ}
+		if payload != "" {
+			msg.Payload = json.RawMessage(payload)
+		}
+		if err := conn.WriteJSON(msg); err != nil {
+			t.Fatalf("failed to send %s: %v", typ, err)
+		}
+	}
+	expect := func(id, typ, payload string) {
+		t.Helper()
+		var msg wsMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("failed to read %s: %v", typ, err)
+		}
+		if msg.ID != id || msg.Type != typ || (payload != "" && string(msg.Payload) != payload) {
+			t.Fatalf("message mismatch: have %s %s %s, want %s %s %s", msg.ID, msg.Type, msg.Payload, id, typ, payload)
+		}
+	}
+	send("", wsMsgConnectionInit, "")
+	expect("", wsMsgConnectionAck, "")
+
+	// Queries are answered once
+	send("1", wsMsgSubscribe, `{"query":"{block{number}}"}`)
+	expect("1", wsMsgNext, `{"data":{"block":{"number":"0x0"}}}`)
+	expect("1", wsMsgComplete, "")
+
+	// Subscriptions stream until completed by the client
+	send("2", wsMsgSubscribe, `{"query":"subscription{newBlocks{number}}"}`)
+	time.Sleep(100 * time.Millisecond) // Wait for the subscription to be installed
+
+	chain, _ := core.GenerateChain(genesis.Config, ethBackend.BlockChain().Genesis(), ethash.NewFaker(), ethBackend.ChainDb(), 2, func(i int, gen *core.BlockGen) {})
+	if _, err := ethBackend.BlockChain().InsertChain(chain); err != nil {
+		t.Fatalf("could not import blocks: %v", err)
+	}
+	expect("2", wsMsgNext, `{"data":{"newBlocks":{"number":"0x1"}}}`)
+	expect("2", wsMsgNext, `{"data":{"newBlocks":{"number":"0x2"}}}`)
+	send("2", wsMsgComplete, "")
+
+	// Invalid operations are reported as errors
+	send("3", wsMsgSubscribe, `{"query":"subscription{bleh}"}`)
+	expect("3", wsMsgError, "")
+
+	// Reusing the id of a running operation terminates the connection
+	send("4", wsMsgSubscribe, `{"query":"subscription{pendingTransactions{hash}}"}`)
+	send("4", wsMsgSubscribe, `{"query":"subscription{pendingTransactions{hash}}"}`)
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, wsCloseSubscriberExist) {
+		t.Fatalf("connection not closed with code %d: %v", wsCloseSubscriberExist, err)
+	}
+}
+
+func TestGraphQLSlowSubscriber(t *testing.T) {
+	stack := createNode(t)
+	defer stack.Close()
+
+	genesis := &core.Genesis{
+		Config:     params.AllEthashProtocolChanges,
+		GasLimit:   11500000,
+		Difficulty: big.NewInt(1048576),
+	}
+	ethBackend, err := eth.New(stack, &ethconfig.Config{
+		Genesis:        genesis,
+		NetworkId:      1337,
+		TrieCleanCache: 5,
+		TrieDirtyCache: 5,
+		TrieTimeout:    60 * time.Minute,
+		SnapshotCache:  5,
+		NoPruning:      true, // Archive mode, the states are committed right away
+	})
+	if err != nil {
+		t.Fatalf("could not create eth backend: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	r := &Resolver{backend: ethBackend.APIBackend, filterSystem: filters.NewFilterSystem(ethBackend.APIBackend, filters.Config{})}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	failures := make(chan error, 1)
+	blocks, err := r.NewBlocks(withSubscriptionFailure(ctx, func(err error) { failures <- err }))
+	if err != nil {
+		t.Fatalf("could not subscribe: %v", err)
+	}
+	// A second subscriber keeping up tells when all the blocks were delivered
+	synced, err := r.NewBlocks(ctx)
+	if err != nil {
+		t.Fatalf("could not subscribe: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond) // Wait for the subscriptions to be installed
+
+	// Import more blocks than buffered without consuming any of them
+	chain, _ := core.GenerateChain(genesis.Config, ethBackend.BlockChain().Genesis(), ethash.NewFaker(), ethBackend.ChainDb(), subscriptionBuffer+2, func(i int, gen *core.BlockGen) {})
+	if _, err := ethBackend.BlockChain().InsertChain(chain); err != nil {
+		t.Fatalf("could not import blocks: %v", err)
+	}
+	timeout := time.After(10 * time.Second)
+	for block := range synced {
+		if block.header.Number.Uint64() == uint64(len(chain)) {
+			break
+		}
+	}
+	// The slow subscriber is dropped after the buffered blocks
+	for i := 0; ; i++ {
+		select {
+		case block, ok := <-blocks:
+			if !ok {
+				if i != subscriptionBuffer {
+					t.Fatalf("subscription closed after %d blocks, want %d", i, subscriptionBuffer)
+				}
+				// The subscriber is told why, instead of a regular completion
+				select {
+				case err := <-failures:
+					if err.Error() != "subscriber too slow" {
+						t.Fatalf("failure mismatch: have %v, want %v", err, errSubscriberTooSlow)
+					}
+				default:
+					t.Fatalf("slow subscriber dropped without failure")
+				}
+				return
+			}
+			if want := uint64(i + 1); block.header.Number.Uint64() != want {
+				t.Fatalf("block %d: wrong number %d", i, block.header.Number)
+			}
+		case <-timeout:
+			t.Fatalf("slow subscriber not dropped, received %d blocks", i)
+		}
+	}
+}
+
 func createNode(t *testing.T) *node.Node {
 	stack, err := node.New(&node.Config{
 		HTTPHost:     "127.0.0.1",
@@ -448,20 +618,22 @@ func newGQLService(t *testing.T, stack *node.Node, shanghai bool, gspec *core.Ge
 	var engine consensus.Engine = ethash.NewFaker()
 	if shanghai {
 		engine = beacon.NewFaker()
-		chainCfg := gspec.Config
+		chainCfg := *gspec.Config // Don't modify the shared configs
 		chainCfg.TerminalTotalDifficultyPassed = true
 		chainCfg.TerminalTotalDifficulty = common.Big0
+		chainCfg.MontanasBlock = common.Big0
 		// GenerateChain will increment timestamps by 10.
 		// Shanghai upgrade at block 1.
 		shanghaiTime := uint64(5)
 		chainCfg.ShanghaiTime = &shanghaiTime
+		gspec.Config = &chainCfg
 	}
 	ethBackend, err := eth.New(stack, ethConf)
 	if err != nil {
 		t.Fatalf("could not create eth backend: %v", err)
 	}
 	// Create some blocks and import them
-	chain, _ := core.GenerateChain(params.AllEthashProtocolChanges, ethBackend.BlockChain().Genesis(),
+	chain, _ := core.GenerateChain(gspec.Config, ethBackend.BlockChain().Genesis(),
 		engine, ethBackend.ChainDb(), genBlocks, genfunc)
 	_, err = ethBackend.BlockChain().InsertChain(chain)
 	if err != nil {
@@ -36,6 +36,7 @@ const schema string = `
     schema {
         query: Query
         mutation: Mutation
+        subscription: Subscription
     }
 
     # Account is an Ethereum account at a particular block.
@@ -54,6 +55,20 @@ const schema string = `
         # Storage provides access to the storage of a contract account, indexed
         # by its 32 byte slot identifier.
         storage(slot: Bytes32!): Bytes32!
+        # Transactions returns a page of at most limit canonical transactions
+        # sent by, sent to, creating or internally calling this account, newest
+        # first, starting at the cursor returned by the previous page or at the
+        # chain head if not supplied. It requires the transaction history index.
+        transactions(cursor: String, limit: Int): TransactionsPage!
+        # TokenBalances returns the non-zero balances of this account in the
+        # ERC-20 tokens and in each of the ERC-721 and ERC-1155 tokens it holds.
+        # It requires the token index.
+        tokenBalances: [TokenBalance!]!
+        # TokenTransfers returns a page of the canonical token transfers from
+        # and to this account logged by at most limit logs, newest first,
+        # starting at the cursor returned by the previous page or at the chain
+        # head if not supplied. It requires the token index.
+        tokenTransfers(cursor: String, limit: Int): TokenTransfersPage!
     }
 
     # Log is an Ethereum event log.
@@ -69,6 +84,9 @@ const schema string = `
         data: Bytes!
         # Transaction is the transaction that generated this log entry.
         transaction: Transaction!
+        # Removed is true if the log was reverted by a chain reorganisation. It
+        # is only ever set on logs delivered through a subscription.
+        removed: Boolean!
     }
 
     # EIP-2718
@@ -337,6 +355,68 @@ const schema string = `
         topics: [[Bytes32!]!]
     }
 
+    # TransactionsPage is a page of the transactions of an account.
+    type TransactionsPage {
+        # Transactions are the transactions of the page.
+        transactions: [Transaction!]!
+        # Cursor is the position to resume the query from, null once all the
+        # transactions of the account were returned.
+        cursor: String
+    }
+
+    # TokenBalance is the balance of an account in a token.
+    type TokenBalance {
+        # Token is the address of the token contract.
+        token: Address!
+        # Standard is the standard of the token contract: ERC-20, ERC-721 or
+        # ERC-1155.
+        standard: String!
+        # Id is the identifier of the token, null for ERC-20.
+        id: BigInt
+        # Balance is the amount of tokens held, negative if the contract
+        # doesn't log all its mints as transfers.
+        balance: BigInt!
+    }
+
+    # TokenTransfer is a transfer of tokens logged by a token contract.
+    type TokenTransfer {
+        # Token is the address of the token contract.
+        token: Address!
+        # Standard is the standard of the token contract: ERC-20, ERC-721 or
+        # ERC-1155.
+        standard: String!
+        # From is the address the tokens were transferred from, zero if minted.
+        from: Address!
+        # To is the address the tokens were transferred to, zero if burnt.
+        to: Address!
+        # Id is the identifier of the token, null for ERC-20.
+        id: BigInt
+        # Value is the amount of tokens transferred.
+        value: BigInt!
+        # Transaction is the transaction which logged the transfer.
+        transaction: Transaction!
+        # Index is the index of the log of the transfer in the block.
+        index: Long!
+    }
+
+    # TokenTransfersPage is a page of the token transfers of an account.
+    type TokenTransfersPage {
+        # Transfers are the token transfers of the page.
+        transfers: [TokenTransfer!]!
+        # Cursor is the position to resume the query from, null once all the
+        # token transfers of the account were returned.
+        cursor: String
+    }
+
+    # LogsPage is a page of the results of a paginated log query.
+    type LogsPage {
+        # Logs are the log entries of the page.
+        logs: [Log!]!
+        # Cursor is the position to resume the query from, null once all the
+        # matching log entries were returned.
+        cursor: String
+    }
+
     # SyncState contains the current synchronisation state of the client.
     type SyncState {
         # StartingBlock is the block number at which synchronisation started.
@@ -375,6 +455,10 @@ const schema string = `
         transaction(hash: Bytes32!): Transaction
         # Logs returns log entries matching the provided filter.
         logs(filter: FilterCriteria!): [Log!]!
+        # LogsPage returns a page of at most limit log entries matching the
+        # provided filter, starting at the cursor returned by the previous page
+        # or at the beginning of the range if not supplied.
+        logsPage(filter: FilterCriteria!, cursor: String, limit: Int): LogsPage!
         # GasPrice returns the node's estimate of a gas price sufficient to
         # ensure a transaction is mined in a timely fashion.
         gasPrice: BigInt!
@@ -391,4 +475,18 @@ const schema string = `
         # SendRawTransaction sends an RLP-encoded transaction to the network.
         sendRawTransaction(data: Bytes!): Bytes32!
     }
+
+    type Subscription {
+        # NewBlocks streams the blocks added to the canonical chain, in the order
+        # they are imported.
+        newBlocks: Block!
+        # NewLogs streams the log entries of blocks added to the canonical chain
+        # matching the provided filter. Log entries of blocks removed from the
+        # canonical chain by a reorganisation are streamed again, marked as removed.
+        newLogs(filter: BlockFilterCriteria): Log!
+        # PendingTransactions streams the transactions entering the transaction pool.
+        pendingTransactions: Transaction!
+        # FinalizedBlocks streams the blocks as they become finalized.
+        finalizedBlocks: Block!
+    }
 `
@@ -28,15 +28,21 @@ import (
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/gorilla/websocket"
 	"github.com/graph-gophers/graphql-go"
 	gqlErrors "github.com/graph-gophers/graphql-go/errors"
 )
 
 type handler struct {
-	Schema *graphql.Schema
+	Schema  *graphql.Schema
+	origins []string // Origins allowed to open websocket connections
 }
 
 func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if websocket.IsWebSocketUpgrade(r) {
+		h.serveWebsocket(w, r)
+		return
+	}
 	var params struct {
 		Query         string                 `json:"query"`
 		OperationName string                 `json:"operationName"`
@@ -114,13 +120,13 @@ func New(stack *node.Node, backend ethapi.Backend, filterSystem *filters.FilterS
 // newHandler returns a new `http.Handler` that will answer GraphQL queries.
 // It additionally exports an interactive query browser on the / endpoint.
 func newHandler(stack *node.Node, backend ethapi.Backend, filterSystem *filters.FilterSystem, cors, vhosts []string) (*handler, error) {
-	q := Resolver{backend, filterSystem}
+	q := Resolver{backend: backend, filterSystem: filterSystem}
 
 	s, err := graphql.ParseSchema(schema, &q)
 	if err != nil {
 		return nil, err
 	}
-	h := handler{Schema: s}
+	h := handler{Schema: s, origins: cors}
 	handler := node.NewHTTPHandlerStack(h, cors, vhosts, nil)
 
 	stack.RegisterHandler("GraphQL UI", "/graphql/ui", GraphiQL{})
@@ -0,0 +1,189 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	// errNoEventSystem is returned for subscriptions to a resolver created
+	// without a filter system.
+	errNoEventSystem = errors.New("subscriptions are not supported")
+
+	// errSubscriberTooSlow is reported to the subscribers dropped for falling
+	// behind, see subscriptionBuffer.
+	errSubscriberTooSlow = errors.New("subscriber too slow")
+)
+
+// eventSystem returns the event system backing the subscriptions, creating it
+// on first use so that nodes without subscribers don't pay for its event loop.
+func (r *Resolver) eventSystem() (*filters.EventSystem, error) {
+	if r.filterSystem == nil {
+		return nil, errNoEventSystem
+	}
+	r.eventsOnce.Do(func() {
+		r.events = filters.NewEventSystem(r.filterSystem, false)
+	})
+	return r.events, nil
+}
+
+// subscriptionFailureKey is the context key of the callback notifying the
+// transport of a subscription about its termination by the server.
+type subscriptionFailureKey struct{}
+
+// withSubscriptionFailure returns a context reporting the reason why the server
+// terminated the subscriptions started with it to the given callback.
+func withSubscriptionFailure(ctx context.Context, fail func(error)) context.Context {
+	return context.WithValue(ctx, subscriptionFailureKey{}, fail)
+}
+
+// failSubscription reports the reason of terminating a subscription, if the
+// transport is interested in it.
+func failSubscription(ctx context.Context, err error) {
+	if fail, ok := ctx.Value(subscriptionFailureKey{}).(func(error)); ok {
+		fail(err)
+	}
+}
+
+// subscriptionBuffer is the number of results buffered for a subscriber. The
+// subscription is terminated if the subscriber falls further behind, so that
+// it doesn't hold up the event system shared by all the subscriptions.
+const subscriptionBuffer = 256
+
+// stream forwards the events of an event system subscription to a GraphQL
+// subscription, converting every event into any number of results. The event
+// subscription is torn down once the context is cancelled, which happens when
+// the client unsubscribes or disconnects, or once the subscriber falls behind
+// by more than subscriptionBuffer results, reported as errSubscriberTooSlow.
+func stream[E, R any](ctx context.Context, sub *filters.Subscription, events <-chan E, convert func(E) []R) <-chan R {
+	results := make(chan R, subscriptionBuffer)
+	go func() {
+		defer close(results)
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case ev := <-events:
+				for _, result := range convert(ev) {
+					select {
+					case results <- result:
+					default:
+						log.Debug("Dropping slow GraphQL subscriber", "buffered", len(results))
+						failSubscription(ctx, errSubscriberTooSlow)
+						return
+					}
+				}
+			case <-sub.Err():
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return results
+}
+
+// newHeaderBlock wraps a header delivered by a subscription into a block.
+func (r *Resolver) newHeaderBlock(header *types.Header) []*Block {
+	hash := header.Hash()
+	numberOrHash := rpc.BlockNumberOrHashWithHash(hash, false)
+	return []*Block{{
+		r:            r,
+		numberOrHash: &numberOrHash,
+		hash:         hash,
+		header:       header,
+	}}
+}
+
+// NewBlocks streams the blocks added to the canonical chain.
+func (r *Resolver) NewBlocks(ctx context.Context) (<-chan *Block, error) {
+	events, err := r.eventSystem()
+	if err != nil {
+		return nil, err
+	}
+	headers := make(chan *types.Header)
+	return stream(ctx, events.SubscribeNewHeads(headers), headers, r.newHeaderBlock), nil
+}
+
+// FinalizedBlocks streams the blocks as they become finalized.
+func (r *Resolver) FinalizedBlocks(ctx context.Context) (<-chan *Block, error) {
+	events, err := r.eventSystem()
+	if err != nil {
+		return nil, err
+	}
+	headers := make(chan *types.Header)
+	return stream(ctx, events.SubscribeFinalizedHeaders(headers), headers, r.newHeaderBlock), nil
+}
+
+// PendingTransactions streams the transactions entering the transaction pool.
+func (r *Resolver) PendingTransactions(ctx context.Context) (<-chan *Transaction, error) {
+	events, err := r.eventSystem()
+	if err != nil {
+		return nil, err
+	}
+	txs := make(chan []*types.Transaction)
+	return stream(ctx, events.SubscribePendingTxs(txs), txs, func(txs []*types.Transaction) []*Transaction {
+		ret := make([]*Transaction, 0, len(txs))
+		for _, tx := range txs {
+			ret = append(ret, &Transaction{r: r, hash: tx.Hash(), tx: tx})
+		}
+		return ret
+	}), nil
+}
+
+// NewLogs streams the log entries of the blocks added to or removed from the
+// canonical chain which match the filter. The field can't be named after the
+// logs query, as all root types share the same resolver.
+func (r *Resolver) NewLogs(ctx context.Context, args struct{ Filter *BlockFilterCriteria }) (<-chan *Log, error) {
+	events, err := r.eventSystem()
+	if err != nil {
+		return nil, err
+	}
+	var crit ethereum.FilterQuery
+	if args.Filter != nil {
+		if args.Filter.Addresses != nil {
+			crit.Addresses = *args.Filter.Addresses
+		}
+		if args.Filter.Topics != nil {
+			crit.Topics = *args.Filter.Topics
+		}
+	}
+	logs := make(chan []*types.Log)
+	sub, err := events.SubscribeLogs(crit, logs)
+	if err != nil {
+		return nil, err
+	}
+	return stream(ctx, sub, logs, func(logs []*types.Log) []*Log {
+		ret := make([]*Log, 0, len(logs))
+		for _, log := range logs {
+			ret = append(ret, &Log{
+				r:           r,
+				transaction: &Transaction{r: r, hash: log.TxHash},
+				log:         log,
+			})
+		}
+		return ret
+	}), nil
+}
@@ -0,0 +1,357 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/gorilla/websocket"
+	"github.com/graph-gophers/graphql-go"
+)
+
+const (
+	// wsProtocol is the websocket subprotocol of the GraphQL over WebSocket
+	// protocol, as implemented by the graphql-ws library.
+	wsProtocol = "graphql-transport-ws"
+
+	wsInitTimeout      = 10 * time.Second // Time allowed for the connection_init message
+	wsWriteTimeout     = 10 * time.Second // Time allowed for writing a message
+	wsPingInterval     = 30 * time.Second // Interval of the keepalive pings
+	wsReadLimit        = 1024 * 1024      // Maximum size of an incoming message
+	wsMaxSubscriptions = 128              // Maximum number of operations per connection
+)
+
+// Close codes defined by the graphql-transport-ws protocol.
+const (
+	wsCloseInvalidMessage  = 4400
+	wsCloseUnauthorized    = 4401
+	wsCloseBadProtocol     = 4406
+	wsCloseInitTimeout     = 4408
+	wsCloseSubscriberExist = 4409
+	wsCloseTooManyInits    = 4429
+)
+
+// Message types defined by the graphql-transport-ws protocol.
+const (
+	wsMsgConnectionInit = "connection_init"
+	wsMsgConnectionAck  = "connection_ack"
+	wsMsgPing           = "ping"
+	wsMsgPong           = "pong"
+	wsMsgSubscribe      = "subscribe"
+	wsMsgNext           = "next"
+	wsMsgError          = "error"
+	wsMsgComplete       = "complete"
+)
+
+// wsMessage is a message of the graphql-transport-ws protocol.
+type wsMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// wsSubscribePayload is the payload of a subscribe message.
+type wsSubscribePayload struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// wsOperation is an operation running on a connection.
+type wsOperation struct {
+	cancel context.CancelFunc
+
+	lock sync.Mutex
+	err  error // Reason of the server terminating the operation, if any
+}
+
+// fail records the reason of the server terminating the operation.
+func (op *wsOperation) fail(err error) {
+	op.lock.Lock()
+	defer op.lock.Unlock()
+
+	if op.err == nil {
+		op.err = err
+	}
+}
+
+// failure returns the reason of the server terminating the operation, or nil
+// if it ended normally.
+func (op *wsOperation) failure() error {
+	op.lock.Lock()
+	defer op.lock.Unlock()
+
+	return op.err
+}
+
+// wsConn is a websocket connection speaking the graphql-transport-ws protocol.
+// Every operation runs in a goroutine of its own, its results are written to
+// the connection as they are produced.
+type wsConn struct {
+	schema *graphql.Schema
+	conn   *websocket.Conn
+
+	writeLock sync.Mutex // Serialises the writes of the operations
+
+	lock sync.Mutex
+	ops  map[string]*wsOperation
+	wg   sync.WaitGroup
+}
+
+// checkOrigin accepts websocket connections from the origins allowed by the
+// CORS configuration, as well as same origin ones and non-browser clients.
+func (h handler) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, allowed := range h.origins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// serveWebsocket upgrades a request to a websocket connection and serves the
+// GraphQL operations sent over it until it is closed.
+func (h handler) serveWebsocket(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{
+		Subprotocols: []string{wsProtocol},
+		CheckOrigin:  h.checkOrigin,
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Debug("GraphQL websocket upgrade failed", "err", err)
+		return
+	}
+	defer conn.Close()
+
+	c := &wsConn{
+		schema: h.Schema,
+		conn:   conn,
+		ops:    make(map[string]*wsOperation),
+	}
+	if conn.Subprotocol() != wsProtocol {
+		c.close(wsCloseBadProtocol, "Subprotocol not acceptable")
+		return
+	}
+	c.run()
+}
+
+// run reads and handles the messages of the client until the connection fails
+// or is closed by either side, then waits for the operations to terminate.
+func (c *wsConn) run() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		c.wg.Wait()
+	}()
+	go c.pingLoop(ctx)
+
+	c.conn.SetReadLimit(wsReadLimit)
+	c.conn.SetReadDeadline(time.Now().Add(wsInitTimeout))
+
+	var acked bool
+	for {
+		var msg wsMessage
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			var (
+				netErr    net.Error
+				syntaxErr *json.SyntaxError
+				typeErr   *json.UnmarshalTypeError
+			)
+			switch {
+			case !acked && errors.As(err, &netErr) && netErr.Timeout():
+				c.close(wsCloseInitTimeout, "Connection initialisation timeout")
+			case errors.As(err, &syntaxErr) || errors.As(err, &typeErr):
+				c.close(wsCloseInvalidMessage, "Invalid message received")
+			}
+			return
+		}
+		switch msg.Type {
+		case wsMsgConnectionInit:
+			if acked {
+				c.close(wsCloseTooManyInits, "Too many initialisation requests")
+				return
+			}
+			acked = true
+			c.conn.SetReadDeadline(time.Time{})
+			c.write(&wsMessage{Type: wsMsgConnectionAck})
+
+		case wsMsgPing:
+			c.write(&wsMessage{Type: wsMsgPong, Payload: msg.Payload})
+
+		case wsMsgPong:
+
+		case wsMsgSubscribe:
+			if !acked {
+				c.close(wsCloseUnauthorized, "Unauthorized")
+				return
+			}
+			var payload wsSubscribePayload
+			if msg.ID == "" || json.Unmarshal(msg.Payload, &payload) != nil {
+				c.close(wsCloseInvalidMessage, "Invalid subscribe message")
+				return
+			}
+			if !c.subscribe(ctx, msg.ID, &payload) {
+				c.close(wsCloseSubscriberExist, fmt.Sprintf("Subscriber for %s already exists", msg.ID))
+				return
+			}
+
+		case wsMsgComplete:
+			c.unsubscribe(msg.ID, nil)
+
+		default:
+			c.close(wsCloseInvalidMessage, fmt.Sprintf("Invalid message type %q", msg.Type))
+			return
+		}
+	}
+}
+
+// subscribe starts an operation, returning false if the id is already in use.
+func (c *wsConn) subscribe(ctx context.Context, id string, payload *wsSubscribePayload) bool {
+	c.lock.Lock()
+	if _, ok := c.ops[id]; ok {
+		c.lock.Unlock()
+		return false
+	}
+	if len(c.ops) >= wsMaxSubscriptions {
+		c.lock.Unlock()
+		c.writeErrors(id, []string{"too many operations"})
+		return true
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	op := &wsOperation{cancel: cancel}
+	ctx = withSubscriptionFailure(ctx, op.fail)
+	c.ops[id] = op
+	c.lock.Unlock()
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer c.unsubscribe(id, op)
+
+		responses, err := c.schema.Subscribe(ctx, payload.Query, payload.OperationName, payload.Variables)
+		if err != nil {
+			c.writeErrors(id, []string{err.Error()})
+			return
+		}
+		// Keep consuming the responses after cancellation, the schema doesn't
+		// terminate the operation otherwise.
+		var failed bool
+		for resp := range responses {
+			if ctx.Err() != nil {
+				continue
+			}
+			res := resp.(*graphql.Response)
+			if len(res.Data) == 0 && len(res.Errors) > 0 {
+				// The operation was rejected, reported by an error message
+				blob, _ := json.Marshal(res.Errors)
+				c.write(&wsMessage{ID: id, Type: wsMsgError, Payload: blob})
+				failed = true
+				continue
+			}
+			blob, err := json.Marshal(res)
+			if err != nil {
+				c.writeErrors(id, []string{err.Error()})
+				failed = true
+				continue
+			}
+			c.write(&wsMessage{ID: id, Type: wsMsgNext, Payload: blob})
+		}
+		if failed || ctx.Err() != nil {
+			return
+		}
+		// Operations terminated by the server are reported as failed
+		if err := op.failure(); err != nil {
+			c.writeErrors(id, []string{err.Error()})
+			return
+		}
+		c.write(&wsMessage{ID: id, Type: wsMsgComplete})
+	}()
+	return true
+}
+
+// unsubscribe cancels an operation and releases its id. If op is non-nil, the
+// operation is only removed if the id still belongs to it.
+func (c *wsConn) unsubscribe(id string, op *wsOperation) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if cur, ok := c.ops[id]; ok && (op == nil || cur == op) {
+		cur.cancel()
+		delete(c.ops, id)
+	}
+}
+
+// writeErrors sends an error message with the given error messages.
+func (c *wsConn) writeErrors(id string, errs []string) {
+	type wsError struct {
+		Message string `json:"message"`
+	}
+	payload := make([]wsError, len(errs))
+	for i, err := range errs {
+		payload[i] = wsError{Message: err}
+	}
+	blob, _ := json.Marshal(payload)
+	c.write(&wsMessage{ID: id, Type: wsMsgError, Payload: blob})
+}
+
+// write sends a message to the client. A failed write closes the connection,
+// terminating the read loop and with it all operations.
+func (c *wsConn) write(msg *wsMessage) {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if err := c.conn.WriteJSON(msg); err != nil {
+		log.Debug("GraphQL websocket write failed", "err", err)
+		c.conn.Close()
+	}
+}
+
+// close terminates the connection with the given close code.
+func (c *wsConn) close(code int, reason string) {
+	c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(wsWriteTimeout))
+}
+
+// pingLoop keeps the connection alive through proxies dropping idle ones.
+func (c *wsConn) pingLoop(ctx context.Context) {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package era
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ComputeAccumulator calculates the accumulator root of an epoch: the root of a
+// binary keccak256 merkle tree over the (block hash, total difficulty) records
+// of the epoch, padded with zero leaves to MaxSize items and mixed in with the
+// number of records.
+//
+// Leaves are keccak256(hash || td), td being a 32 byte big endian integer.
+func ComputeAccumulator(hashes []common.Hash, tds []*big.Int) (common.Hash, error) {
+	if len(hashes) != len(tds) {
+		return common.Hash{}, errors.New("must have equal number hashes as td values")
+	}
+	if len(hashes) > MaxSize {
+		return common.Hash{}, fmt.Errorf("too many records: have %d, max %d", len(hashes), MaxSize)
+	}
+	nodes := make([]common.Hash, MaxSize)
+	for i := range hashes {
+		if tds[i].Sign() < 0 || tds[i].BitLen() > 256 {
+			return common.Hash{}, fmt.Errorf("invalid total difficulty %v", tds[i])
+		}
+		var td [32]byte
+		tds[i].FillBytes(td[:])
+		nodes[i] = crypto.Keccak256Hash(hashes[i][:], td[:])
+	}
+	for len(nodes) > 1 {
+		for i := 0; i < len(nodes)/2; i++ {
+			nodes[i] = crypto.Keccak256Hash(nodes[2*i][:], nodes[2*i+1][:])
+		}
+		nodes = nodes[:len(nodes)/2]
+	}
+	var length [32]byte
+	binary.LittleEndian.PutUint64(length[:8], uint64(len(hashes)))
+	return crypto.Keccak256Hash(nodes[0][:], length[:]), nil
+}
@@ -0,0 +1,166 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package era
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/golang/snappy"
+)
+
+// Builder is used to create era files of chain history.
+//
+// The file layout is a sequence of e2store records:
+//
+//	era := Version | block-tuple* | Accumulator | BlockIndex
+//	block-tuple := CompressedHeader | CompressedBody | CompressedReceipts | TotalDifficulty
+//
+// Headers, bodies and receipts hold the snappy compressed RLP encodings as
+// stored in the chain freezer (receipts in their storage format). The total
+// difficulty is a 32 byte big endian integer and the accumulator is the root
+// computed by ComputeAccumulator.
+//
+// The block index provides random access into the file:
+//
+//	BlockIndex := starting-number | offset* | count
+//
+// All fields are 8 byte little endian integers, the offsets are relative to
+// the beginning of the index record and point to the block's header record.
+type Builder struct {
+	w        *writer
+	start    *uint64
+	written  uint64
+	offsets  []uint64
+	hashes   []common.Hash
+	tds      []*big.Int
+	finished bool
+}
+
+// NewBuilder returns a new era builder writing into the given stream.
+func NewBuilder(w io.Writer) *Builder {
+	return &Builder{w: newWriter(w)}
+}
+
+// Add writes a block, its receipts and the chain total difficulty into the era
+// file.
+func (b *Builder) Add(block *types.Block, receipts types.Receipts, td *big.Int) error {
+	header, err := rlp.EncodeToBytes(block.Header())
+	if err != nil {
+		return err
+	}
+	body, err := rlp.EncodeToBytes(block.Body())
+	if err != nil {
+		return err
+	}
+	storage := make([]*types.ReceiptForStorage, len(receipts))
+	for i, receipt := range receipts {
+		storage[i] = (*types.ReceiptForStorage)(receipt)
+	}
+	encoded, err := rlp.EncodeToBytes(storage)
+	if err != nil {
+		return err
+	}
+	return b.AddRLP(header, body, encoded, block.NumberU64(), block.Hash(), td)
+}
+
+// AddRLP writes an already RLP encoded block into the era file. Blocks need to
+// be added in ascending, contiguous order.
+func (b *Builder) AddRLP(header, body, receipts []byte, number uint64, hash common.Hash, td *big.Int) error {
+	if b.finished {
+		return errors.New("era builder already finalized")
+	}
+	// Write the version record before the first block and track the start
+	if b.start == nil {
+		if err := b.write(TypeVersion, nil); err != nil {
+			return err
+		}
+		b.start = &number
+	}
+	if len(b.offsets) >= MaxSize {
+		return fmt.Errorf("exceeds maximum batch size of %d", MaxSize)
+	}
+	if want := *b.start + uint64(len(b.offsets)); number != want {
+		return fmt.Errorf("non-contiguous block: have %d, want %d", number, want)
+	}
+	if td.Sign() < 0 || td.BitLen() > 256 {
+		return fmt.Errorf("invalid total difficulty %v", td)
+	}
+	b.offsets = append(b.offsets, b.written)
+	b.hashes = append(b.hashes, hash)
+	b.tds = append(b.tds, new(big.Int).Set(td))
+
+	if err := b.write(TypeCompressedHeader, snappy.Encode(nil, header)); err != nil {
+		return err
+	}
+	if err := b.write(TypeCompressedBody, snappy.Encode(nil, body)); err != nil {
+		return err
+	}
+	if err := b.write(TypeCompressedReceipts, snappy.Encode(nil, receipts)); err != nil {
+		return err
+	}
+	var blob [32]byte
+	td.FillBytes(blob[:])
+	return b.write(TypeTotalDifficulty, blob[:])
+}
+
+// Finalize writes the accumulator and the block index records, completing the
+// era file. The accumulator root is returned.
+func (b *Builder) Finalize() (common.Hash, error) {
+	if b.start == nil {
+		return common.Hash{}, errors.New("finalize called on empty builder")
+	}
+	if b.finished {
+		return common.Hash{}, errors.New("era builder already finalized")
+	}
+	root, err := ComputeAccumulator(b.hashes, b.tds)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("failed to compute accumulator: %w", err)
+	}
+	if err := b.write(TypeAccumulator, root[:]); err != nil {
+		return common.Hash{}, err
+	}
+	// Assemble the block index, offsets are relative to the index record
+	var (
+		count = len(b.offsets)
+		index = make([]byte, 16+8*count)
+		base  = b.written
+	)
+	binary.LittleEndian.PutUint64(index, *b.start)
+	for i, offset := range b.offsets {
+		binary.LittleEndian.PutUint64(index[8+i*8:], uint64(int64(offset)-int64(base)))
+	}
+	binary.LittleEndian.PutUint64(index[8+count*8:], uint64(count))
+	if err := b.write(TypeBlockIndex, index); err != nil {
+		return common.Hash{}, err
+	}
+	b.finished = true
+	return root, nil
+}
+
+// write appends a record to the era file, tracking the written size.
+func (b *Builder) write(typ uint16, value []byte) error {
+	n, err := b.w.Write(typ, value)
+	b.written += uint64(n)
+	return err
+}
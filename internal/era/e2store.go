@@ -0,0 +1,100 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package era
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// headerSize is the size of an e2store entry header: a 2 byte type, a 4 byte
+// little endian value length and 2 reserved bytes which must be zero.
+const headerSize = 8
+
+// entry is a single type-length-value record of an e2store file.
+type entry struct {
+	Type  uint16
+	Value []byte
+}
+
+// writer is a helper to write type-length-value records into an e2store file.
+type writer struct {
+	w io.Writer
+}
+
+// newWriter creates an e2store record writer on top of the given stream.
+func newWriter(w io.Writer) *writer {
+	return &writer{w: w}
+}
+
+// Write writes a single record into the stream, returning the number of bytes
+// written including the header.
+func (w *writer) Write(typ uint16, b []byte) (int, error) {
+	if uint64(len(b)) > uint64(^uint32(0)) {
+		return 0, fmt.Errorf("value too large: %d bytes", len(b))
+	}
+	var header [headerSize]byte
+	binary.LittleEndian.PutUint16(header[:2], typ)
+	binary.LittleEndian.PutUint32(header[2:6], uint32(len(b)))
+	if n, err := w.w.Write(header[:]); err != nil {
+		return n, err
+	}
+	n, err := w.w.Write(b)
+	return headerSize + n, err
+}
+
+// reader is a helper to read type-length-value records from an e2store file.
+type reader struct {
+	r io.ReaderAt
+}
+
+// newReader creates an e2store record reader on top of the given file.
+func newReader(r io.ReaderAt) *reader {
+	return &reader{r: r}
+}
+
+// ReadMetadataAt reads the header of the record at the given offset, returning
+// its type and value length.
+func (r *reader) ReadMetadataAt(off int64) (uint16, uint32, error) {
+	var header [headerSize]byte
+	if _, err := r.r.ReadAt(header[:], off); err != nil {
+		return 0, 0, err
+	}
+	if header[6] != 0 || header[7] != 0 {
+		return 0, 0, errors.New("reserved bytes are non-zero")
+	}
+	return binary.LittleEndian.Uint16(header[:2]), binary.LittleEndian.Uint32(header[2:6]), nil
+}
+
+// ReadAt reads the record at the given offset, returning it along with the
+// total number of bytes it occupies in the file.
+func (r *reader) ReadAt(off int64) (*entry, int64, error) {
+	typ, length, err := r.ReadMetadataAt(off)
+	if err != nil {
+		return nil, 0, err
+	}
+	value := make([]byte, length)
+	if _, err := r.r.ReadAt(value, off+headerSize); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, 0, err
+	}
+	return &entry{Type: typ, Value: value}, headerSize + int64(length), nil
+}
@@ -0,0 +1,295 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package era implements a portable archive format for chain history. Each era
+// file holds a fixed range of headers, bodies, receipts and total difficulties,
+// an index for random access and an accumulator root to verify the contents.
+package era
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/golang/snappy"
+)
+
+// Record types of an era file.
+const (
+	TypeVersion            uint16 = 0x3265
+	TypeCompressedHeader   uint16 = 0x03
+	TypeCompressedBody     uint16 = 0x04
+	TypeCompressedReceipts uint16 = 0x05
+	TypeTotalDifficulty    uint16 = 0x06
+	TypeAccumulator        uint16 = 0x07
+	TypeBlockIndex         uint16 = 0x3266
+
+	// MaxSize is the maximum number of blocks a single era file may hold.
+	MaxSize = 8192
+)
+
+// Filename returns the name of the era file of the given network and epoch,
+// suffixed with the leading bytes of its accumulator root.
+func Filename(network string, epoch int, root common.Hash) string {
+	return fmt.Sprintf("%s-%05d-%s.era1", network, epoch, root.Hex()[2:10])
+}
+
+// ReadDir returns the era files of the given network in the directory, sorted
+// by epoch (os.ReadDir sorts by name and epochs are zero padded). It errors if
+// the epochs are not contiguous, the run may start at any epoch though.
+func ReadDir(dir, network string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", dir, err)
+	}
+	var (
+		next  uint64
+		names []string
+	)
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".era1" {
+			continue
+		}
+		parts := strings.Split(strings.TrimSuffix(entry.Name(), ".era1"), "-")
+		if len(parts) != 3 || parts[0] != network {
+			continue
+		}
+		epoch, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed era filename: %s", entry.Name())
+		}
+		if len(names) > 0 && epoch != next {
+			return nil, fmt.Errorf("missing epoch %d", next)
+		}
+		next = epoch + 1
+		names = append(names, entry.Name())
+	}
+	return names, nil
+}
+
+// ReadAtSeekCloser is the interface required to access an era file.
+type ReadAtSeekCloser interface {
+	io.ReaderAt
+	io.Seeker
+	io.Closer
+}
+
+// Era reads an era file.
+type Era struct {
+	f     ReadAtSeekCloser
+	s     *reader
+	start uint64 // number of the first block in the file
+	count uint64 // number of blocks in the file
+	index int64  // offset of the block index record
+}
+
+// Open opens the era file at the given path.
+func Open(path string) (*Era, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	e, err := From(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return e, nil
+}
+
+// From wraps an already opened era file, validating its structure.
+func From(f ReadAtSeekCloser) (*Era, error) {
+	length, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	e := &Era{f: f, s: newReader(f)}
+
+	// Check the version record leading the file
+	if typ, _, err := e.s.ReadMetadataAt(0); err != nil {
+		return nil, err
+	} else if typ != TypeVersion {
+		return nil, fmt.Errorf("invalid version record type %#x", typ)
+	}
+	// Locate the block index by its trailing count
+	if length < headerSize+8 {
+		return nil, errors.New("era file too short")
+	}
+	if e.count, err = e.readUint64(length - 8); err != nil {
+		return nil, err
+	}
+	if e.count == 0 || e.count > MaxSize {
+		return nil, fmt.Errorf("invalid block count %d", e.count)
+	}
+	e.index = length - int64(headerSize+16+8*e.count)
+	if e.index < 0 {
+		return nil, errors.New("era file too short")
+	}
+	if typ, size, err := e.s.ReadMetadataAt(e.index); err != nil {
+		return nil, err
+	} else if typ != TypeBlockIndex || uint64(size) != 16+8*e.count {
+		return nil, fmt.Errorf("invalid block index record (type %#x, size %d)", typ, size)
+	}
+	if e.start, err = e.readUint64(e.index + headerSize); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Close closes the underlying era file.
+func (e *Era) Close() error {
+	return e.f.Close()
+}
+
+// Start returns the number of the first block in the era file.
+func (e *Era) Start() uint64 {
+	return e.start
+}
+
+// Count returns the number of blocks in the era file.
+func (e *Era) Count() uint64 {
+	return e.count
+}
+
+// Accumulator reads the accumulator root stored in the era file.
+func (e *Era) Accumulator() (common.Hash, error) {
+	// The accumulator record directly precedes the block index
+	off := e.index - headerSize - common.HashLength
+	if off < 0 {
+		return common.Hash{}, errors.New("era file too short")
+	}
+	entry, _, err := e.s.ReadAt(off)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if entry.Type != TypeAccumulator {
+		return common.Hash{}, fmt.Errorf("invalid accumulator record type %#x", entry.Type)
+	}
+	return common.BytesToHash(entry.Value), nil
+}
+
+// GetRawByNumber returns the RLP encoded header, body and storage receipts,
+// and the total difficulty of the given block.
+func (e *Era) GetRawByNumber(num uint64) (header, body, receipts []byte, td *big.Int, err error) {
+	off, err := e.offset(num)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if header, off, err = e.readCompressed(off, TypeCompressedHeader); err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if body, off, err = e.readCompressed(off, TypeCompressedBody); err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if receipts, off, err = e.readCompressed(off, TypeCompressedReceipts); err != nil {
+		return nil, nil, nil, nil, err
+	}
+	entry, _, err := e.s.ReadAt(off)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if entry.Type != TypeTotalDifficulty || len(entry.Value) != 32 {
+		return nil, nil, nil, nil, fmt.Errorf("invalid total difficulty record (type %#x, size %d)", entry.Type, len(entry.Value))
+	}
+	return header, body, receipts, new(big.Int).SetBytes(entry.Value), nil
+}
+
+// GetBlockByNumber returns the decoded block, receipts and total difficulty of
+// the given block. The receipts only hold the consensus fields and the type.
+func (e *Era) GetBlockByNumber(num uint64) (*types.Block, types.Receipts, *big.Int, error) {
+	rawHeader, rawBody, rawReceipts, td, err := e.GetRawByNumber(num)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var (
+		header  types.Header
+		body    types.Body
+		storage []*types.ReceiptForStorage
+	)
+	if err := rlp.DecodeBytes(rawHeader, &header); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid header %d: %w", num, err)
+	}
+	if err := rlp.DecodeBytes(rawBody, &body); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid body %d: %w", num, err)
+	}
+	if err := rlp.DecodeBytes(rawReceipts, &storage); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid receipts %d: %w", num, err)
+	}
+	if len(storage) != len(body.Transactions) {
+		return nil, nil, nil, fmt.Errorf("receipt count mismatch in block %d: have %d, want %d", num, len(storage), len(body.Transactions))
+	}
+	receipts := make(types.Receipts, len(storage))
+	for i, receipt := range storage {
+		receipts[i] = (*types.Receipt)(receipt)
+		receipts[i].Type = body.Transactions[i].Type()
+	}
+	block := types.NewBlockWithHeader(&header).WithBody(body.Transactions, body.Uncles)
+	if body.Withdrawals != nil {
+		block = block.WithWithdrawals(body.Withdrawals)
+	}
+	return block, receipts, td, nil
+}
+
+// offset returns the file offset of the header record of the given block.
+func (e *Era) offset(num uint64) (int64, error) {
+	if num < e.start || num >= e.start+e.count {
+		return 0, fmt.Errorf("block %d out of range [%d, %d)", num, e.start, e.start+e.count)
+	}
+	rel, err := e.readUint64(e.index + headerSize + 8 + int64(num-e.start)*8)
+	if err != nil {
+		return 0, err
+	}
+	off := e.index + int64(rel)
+	if off < 0 || off >= e.index {
+		return 0, fmt.Errorf("invalid offset of block %d", num)
+	}
+	return off, nil
+}
+
+// readCompressed reads and decompresses a record of the expected type at the
+// given offset, returning the offset of the next record.
+func (e *Era) readCompressed(off int64, typ uint16) ([]byte, int64, error) {
+	entry, n, err := e.s.ReadAt(off)
+	if err != nil {
+		return nil, 0, err
+	}
+	if entry.Type != typ {
+		return nil, 0, fmt.Errorf("unexpected record type %#x, want %#x", entry.Type, typ)
+	}
+	data, err := snappy.Decode(nil, entry.Value)
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, off + n, nil
+}
+
+// readUint64 reads a little endian integer at the given offset.
+func (e *Era) readUint64(off int64) (uint64, error) {
+	var buf [8]byte
+	if _, err := e.f.ReadAt(buf[:], off); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(buf[:]), nil
+}
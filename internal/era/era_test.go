@@ -0,0 +1,158 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package era
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// makeChain creates a chain of blocks with a transaction and receipt each,
+// starting at the given number.
+func makeChain(start, count uint64) ([]*types.Block, []types.Receipts, []*big.Int) {
+	var (
+		blocks   []*types.Block
+		receipts []types.Receipts
+		tds      []*big.Int
+		parent   common.Hash
+		td       = big.NewInt(int64(start))
+		to       = common.Address{0xaa}
+	)
+	for n := start; n < start+count; n++ {
+		tx := types.NewTx(&types.LegacyTx{Nonce: n, GasPrice: big.NewInt(1), Gas: 21000, To: &to})
+		receipt := &types.Receipt{
+			Type:              tx.Type(),
+			Status:            types.ReceiptStatusSuccessful,
+			CumulativeGasUsed: 21000,
+			Logs:              []*types.Log{{Address: to, Topics: []common.Hash{{byte(n)}}, Data: []byte{byte(n)}}},
+		}
+		receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+
+		header := &types.Header{Number: new(big.Int).SetUint64(n), ParentHash: parent, Difficulty: big.NewInt(2), GasLimit: 30_000_000}
+		block := types.NewBlock(header, []*types.Transaction{tx}, nil, []*types.Receipt{receipt}, trie.NewStackTrie(nil))
+		td = new(big.Int).Add(td, block.Difficulty())
+
+		blocks = append(blocks, block)
+		receipts = append(receipts, types.Receipts{receipt})
+		tds = append(tds, td)
+		parent = block.Hash()
+	}
+	return blocks, receipts, tds
+}
+
+// writeEra builds an era file out of the given chain.
+func writeEra(t *testing.T, blocks []*types.Block, receipts []types.Receipts, tds []*big.Int) (string, common.Hash) {
+	t.Helper()
+
+	f, err := os.Create(filepath.Join(t.TempDir(), "test.era1"))
+	if err != nil {
+		t.Fatalf("failed to create era file: %v", err)
+	}
+	defer f.Close()
+
+	builder := NewBuilder(f)
+	for i, block := range blocks {
+		if err := builder.Add(block, receipts[i], tds[i]); err != nil {
+			t.Fatalf("failed to add block %d: %v", block.NumberU64(), err)
+		}
+	}
+	root, err := builder.Finalize()
+	if err != nil {
+		t.Fatalf("failed to finalize era file: %v", err)
+	}
+	return f.Name(), root
+}
+
+func TestEraRoundtrip(t *testing.T) {
+	t.Parallel()
+
+	blocks, receipts, tds := makeChain(128, 64)
+	path, root := writeEra(t, blocks, receipts, tds)
+
+	e, err := Open(path)
+	if err != nil {
+		t.Fatalf("failed to open era file: %v", err)
+	}
+	defer e.Close()
+
+	if e.Start() != 128 || e.Count() != 64 {
+		t.Fatalf("range mismatch: have [%d, +%d), want [128, +64)", e.Start(), e.Count())
+	}
+	if stored, err := e.Accumulator(); err != nil || stored != root {
+		t.Fatalf("accumulator mismatch: have %x (%v), want %x", stored, err, root)
+	}
+	// Read the blocks back in random order
+	for _, i := range []int{63, 0, 17, 32} {
+		block, rs, td, err := e.GetBlockByNumber(blocks[i].NumberU64())
+		if err != nil {
+			t.Fatalf("failed to read block %d: %v", i, err)
+		}
+		if block.Hash() != blocks[i].Hash() {
+			t.Errorf("block %d: hash mismatch", i)
+		}
+		if len(rs) != 1 || rs[0].CumulativeGasUsed != receipts[i][0].CumulativeGasUsed || rs[0].Bloom != receipts[i][0].Bloom {
+			t.Errorf("block %d: receipt mismatch", i)
+		}
+		if td.Cmp(tds[i]) != 0 {
+			t.Errorf("block %d: td mismatch: have %v, want %v", i, td, tds[i])
+		}
+	}
+	if _, _, _, err := e.GetBlockByNumber(192); err == nil {
+		t.Fatal("out of range block retrieved")
+	}
+	if verified, err := Verify(e); err != nil || verified != root {
+		t.Fatalf("verification failed: root %x, err %v", verified, err)
+	}
+}
+
+func TestEraVerifyTampered(t *testing.T) {
+	t.Parallel()
+
+	blocks, receipts, tds := makeChain(0, 8)
+
+	// Mismatching total difficulty
+	tampered := append([]*big.Int{}, tds...)
+	tampered[4] = new(big.Int).Add(tampered[4], common.Big1)
+	path, _ := writeEra(t, blocks, receipts, tampered)
+	if err := verifyFile(path); err == nil {
+		t.Error("tampered total difficulty not detected")
+	}
+	// Mismatching receipts
+	swapped := append([]types.Receipts{}, receipts...)
+	swapped[2], swapped[3] = swapped[3], swapped[2]
+	path, _ = writeEra(t, blocks, swapped, tds)
+	if err := verifyFile(path); err == nil {
+		t.Error("tampered receipts not detected")
+	}
+}
+
+func verifyFile(path string) error {
+	e, err := Open(path)
+	if err != nil {
+		return err
+	}
+	defer e.Close()
+
+	_, err = Verify(e)
+	return err
+}
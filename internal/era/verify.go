@@ -0,0 +1,85 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package era
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// Verify checks the internal consistency of an era file without any chain data:
+// the blocks must link up by parent hash, the bodies and receipts must match
+// the roots committed to in their headers, the total difficulties must add up
+// and the accumulator must match the stored one. The accumulator is returned.
+//
+// Consensus seals are not checked, the accumulator needs to be compared against
+// a trusted source to authenticate the history.
+func Verify(e *Era) (common.Hash, error) {
+	var (
+		hashes = make([]common.Hash, 0, e.Count())
+		tds    = make([]*big.Int, 0, e.Count())
+	)
+	for num := e.Start(); num < e.Start()+e.Count(); num++ {
+		block, receipts, td, err := e.GetBlockByNumber(num)
+		if err != nil {
+			return common.Hash{}, err
+		}
+		if block.NumberU64() != num {
+			return common.Hash{}, fmt.Errorf("block number mismatch: have %d, want %d", block.NumberU64(), num)
+		}
+		if len(hashes) > 0 {
+			if block.ParentHash() != hashes[len(hashes)-1] {
+				return common.Hash{}, fmt.Errorf("block %d: parent hash mismatch", num)
+			}
+			if want := new(big.Int).Add(tds[len(tds)-1], block.Difficulty()); td.Cmp(want) != 0 {
+				return common.Hash{}, fmt.Errorf("block %d: total difficulty mismatch: have %v, want %v", num, td, want)
+			}
+		}
+		if hash := types.DeriveSha(block.Transactions(), trie.NewStackTrie(nil)); hash != block.TxHash() {
+			return common.Hash{}, fmt.Errorf("block %d: transaction root mismatch: have %x, want %x", num, hash, block.TxHash())
+		}
+		if hash := types.CalcUncleHash(block.Uncles()); hash != block.UncleHash() {
+			return common.Hash{}, fmt.Errorf("block %d: uncle hash mismatch: have %x, want %x", num, hash, block.UncleHash())
+		}
+		if want := block.Header().WithdrawalsHash; want != nil {
+			if hash := types.DeriveSha(types.Withdrawals(block.Withdrawals()), trie.NewStackTrie(nil)); hash != *want {
+				return common.Hash{}, fmt.Errorf("block %d: withdrawals root mismatch: have %x, want %x", num, hash, *want)
+			}
+		}
+		if hash := types.DeriveSha(receipts, trie.NewStackTrie(nil)); hash != block.ReceiptHash() {
+			return common.Hash{}, fmt.Errorf("block %d: receipt root mismatch: have %x, want %x", num, hash, block.ReceiptHash())
+		}
+		hashes = append(hashes, block.Hash())
+		tds = append(tds, td)
+	}
+	root, err := ComputeAccumulator(hashes, tds)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	stored, err := e.Accumulator()
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if root != stored {
+		return common.Hash{}, fmt.Errorf("accumulator mismatch: have %x, want %x", root, stored)
+	}
+	return root, nil
+}
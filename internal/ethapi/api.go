@@ -37,10 +37,12 @@ import (
 	"github.com/ethereum/go-ethereum/consensus/misc/eip1559"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/txpool"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/eth/tracers/logger"
+	"github.com/ethereum/go-ethereum/internal/tracing"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/params"
@@ -160,6 +162,33 @@ func NewTxPoolAPI(b Backend) *TxPoolAPI {
 	return &TxPoolAPI{b}
 }
 
+// txPoolDocs documents the methods of the txpool namespace in rpc_discover.
+var txPoolDocs = map[string]rpc.MethodDoc{
+	"content": {
+		Summary: "Returns the pending and queued transactions, grouped by sender and nonce.",
+	},
+	"contentFrom": {
+		Summary: "Returns the pending and queued transactions of an account, grouped by nonce.",
+		Params:  []string{"address"},
+	},
+	"status": {
+		Summary: "Returns the number of pending and queued transactions.",
+	},
+	"inspect": {
+		Summary: "Returns a textual summary of the pending and queued transactions, grouped by sender and nonce.",
+	},
+	"contentAt": {
+		Summary: "Returns a pooled transaction and whether it is pending or queued, or null if the pool doesn't track it.",
+		Params:  []string{"hash"},
+	},
+	"diagnose": {
+		Summary:     "Explains why the pooled transactions of an account are or aren't executable.",
+		Description: "Reports the nonce gaps and the other conditions holding back the pending and queued transactions of the account against the current chain head.",
+		Params:      []string{"address"},
+		Result:      "diagnosis",
+	},
+}
+
 // Content returns the transactions contained within the transaction pool.
 func (s *TxPoolAPI) Content() map[string]map[string]map[string]*RPCTransaction {
 	content := map[string]map[string]map[string]*RPCTransaction{
@@ -254,6 +283,204 @@ func (s *TxPoolAPI) Inspect() map[string]map[string]map[string]string {
 	return content
 }
 
+// ContentAt returns the pooled transaction with the given hash along with its
+// position within the pool, or nil if the pool doesn't track it.
+func (s *TxPoolAPI) ContentAt(hash common.Hash) map[string]interface{} {
+	tx := s.b.GetPoolTransaction(hash)
+	if tx == nil {
+		return nil
+	}
+	from, _ := types.Sender(types.LatestSigner(s.b.ChainConfig()), tx)
+	pending, queue := s.b.TxPoolContentFrom(from)
+
+	status := "unknown"
+	for _, ptx := range pending {
+		if ptx.Hash() == hash {
+			status = "pending"
+		}
+	}
+	for _, qtx := range queue {
+		if qtx.Hash() == hash {
+			status = "queued"
+		}
+	}
+	return map[string]interface{}{
+		"status":      status,
+		"transaction": NewRPCPendingTransaction(tx, s.b.CurrentHeader(), s.b.ChainConfig()),
+	}
+}
+
+// NonceGap is an inclusive range of nonces missing from the pool, preventing
+// any queued transaction above it from becoming executable.
+type NonceGap struct {
+	From hexutil.Uint64 `json:"from"`
+	To   hexutil.Uint64 `json:"to"`
+}
+
+// TxPoolAccountDiagnosis explains why the pooled transactions of an account are
+// or aren't executable.
+type TxPoolAccountDiagnosis struct {
+	StateNonce hexutil.Uint64 `json:"stateNonce"`
+	PoolNonce  hexutil.Uint64 `json:"poolNonce"`
+	Balance    *hexutil.Big   `json:"balance"`
+	Pending    hexutil.Uint   `json:"pending"`
+	Queued     hexutil.Uint   `json:"queued"`
+	Gaps       []NonceGap     `json:"gaps"`
+	Issues     []string       `json:"issues"`
+}
+
+// Diagnose inspects the pending and queued transactions of an account against
+// the current chain head, reporting nonce gaps and other conditions holding
+// them back from being included in a block.
+func (s *TxPoolAPI) Diagnose(ctx context.Context, addr common.Address) (*TxPoolAccountDiagnosis, error) {
+	state, header, err := s.b.StateAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	poolNonce, err := s.b.GetPoolNonce(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	pending, queue := s.b.TxPoolContentFrom(addr)
+
+	var (
+		balance = state.GetBalance(addr)
+		result  = &TxPoolAccountDiagnosis{
+			StateNonce: hexutil.Uint64(state.GetNonce(addr)),
+			PoolNonce:  hexutil.Uint64(poolNonce),
+			Balance:    (*hexutil.Big)(balance),
+			Pending:    hexutil.Uint(len(pending)),
+			Queued:     hexutil.Uint(len(queue)),
+			Gaps:       []NonceGap{},
+			Issues:     []string{},
+		}
+	)
+	// Find the nonces missing between the executable and the queued transactions
+	next := poolNonce
+	for _, tx := range queue {
+		if tx.Nonce() > next {
+			result.Gaps = append(result.Gaps, NonceGap{From: hexutil.Uint64(next), To: hexutil.Uint64(tx.Nonce() - 1)})
+		}
+		next = tx.Nonce() + 1
+	}
+	for _, gap := range result.Gaps {
+		result.Issues = append(result.Issues, fmt.Sprintf("missing nonces %d-%d hold back queued transactions", gap.From, gap.To))
+	}
+	// Check the transactions against the balance and the current base fee
+	for _, tx := range append(pending, queue...) {
+		if tx.Cost().Cmp(balance) > 0 {
+			result.Issues = append(result.Issues, fmt.Sprintf("nonce %d: cost %v exceeds balance %v", tx.Nonce(), tx.Cost(), balance))
+		}
+		if header.BaseFee != nil && tx.GasFeeCap().Cmp(header.BaseFee) < 0 {
+			result.Issues = append(result.Issues, fmt.Sprintf("nonce %d: fee cap %v below base fee %v", tx.Nonce(), tx.GasFeeCap(), header.BaseFee))
+		}
+		if tx.Gas() > header.GasLimit {
+			result.Issues = append(result.Issues, fmt.Sprintf("nonce %d: gas %d exceeds block gas limit %d", tx.Nonce(), tx.Gas(), header.GasLimit))
+		}
+	}
+	return result, nil
+}
+
+// RPCTxPoolEvent is the RPC representation of a transaction pool lifecycle event.
+type RPCTxPoolEvent struct {
+	Type        string          `json:"type"`
+	Hash        common.Hash     `json:"hash"`
+	From        common.Address  `json:"from"`
+	To          *common.Address `json:"to"`
+	Nonce       hexutil.Uint64  `json:"nonce"`
+	Reason      string          `json:"reason,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	Replacement *common.Hash    `json:"replacement,omitempty"`
+}
+
+// TxPoolEventFilter restricts the events delivered by a txpool subscription.
+// Empty fields match everything.
+type TxPoolEventFilter struct {
+	Addresses []common.Address `json:"addresses"` // Senders or recipients to report
+	Types     []string         `json:"types"`     // Event types to report
+}
+
+// Events creates a subscription that is triggered whenever a transaction is
+// admitted into, replaced in, dropped from or rejected by the transaction pool.
+// Rejections and drops carry the reason code of the underlying error.
+func (s *TxPoolAPI) Events(ctx context.Context, filter *TxPoolEventFilter) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	var (
+		addresses = make(map[common.Address]struct{})
+		kinds     = make(map[string]struct{})
+	)
+	if filter != nil {
+		for _, addr := range filter.Addresses {
+			addresses[addr] = struct{}{}
+		}
+		for _, kind := range filter.Types {
+			kinds[kind] = struct{}{}
+		}
+	}
+	var (
+		rpcSub = notifier.CreateSubscription()
+		events = make(chan []*txpool.TxEvent, 128)
+		sub    = s.b.SubscribeTxPoolEvents(events)
+		signer = types.LatestSigner(s.b.ChainConfig())
+	)
+	go func() {
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case batch := <-events:
+				for _, ev := range batch {
+					if len(kinds) > 0 {
+						if _, ok := kinds[ev.Kind.String()]; !ok {
+							continue
+						}
+					}
+					from, _ := types.Sender(signer, ev.Tx)
+					if len(addresses) > 0 {
+						_, fromOk := addresses[from]
+						toOk := false
+						if to := ev.Tx.To(); to != nil {
+							_, toOk = addresses[*to]
+						}
+						if !fromOk && !toOk {
+							continue
+						}
+					}
+					notifier.Notify(rpcSub.ID, newRPCTxPoolEvent(ev, from))
+				}
+			case <-rpcSub.Err():
+				return
+			case <-sub.Err():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
+// newRPCTxPoolEvent converts a pool lifecycle event into its RPC representation.
+func newRPCTxPoolEvent(ev *txpool.TxEvent, from common.Address) *RPCTxPoolEvent {
+	result := &RPCTxPoolEvent{
+		Type:  ev.Kind.String(),
+		Hash:  ev.Tx.Hash(),
+		From:  from,
+		To:    ev.Tx.To(),
+		Nonce: hexutil.Uint64(ev.Tx.Nonce()),
+	}
+	if ev.Reason != nil {
+		result.Reason = txpool.ReasonCode(ev.Reason)
+		result.Error = ev.Reason.Error()
+	}
+	if ev.Kind == txpool.TxEventReplaced {
+		replacement := ev.Replacement
+		result.Replacement = &replacement
+	}
+	return result
+}
+
 // EthereumAccountAPI provides an API to access accounts managed by this node.
 // It offers only methods that can retrieve accounts.
 type EthereumAccountAPI struct {
@@ -632,8 +859,17 @@ func (s *BlockChainAPI) BlockNumber() hexutil.Uint64 {
 // given block number. The rpc.LatestBlockNumber and rpc.PendingBlockNumber meta
 // block numbers are also allowed.
 func (s *BlockChainAPI) GetBalance(ctx context.Context, address common.Address, blockNrOrHash rpc.BlockNumberOrHash) (*hexutil.Big, error) {
+	// Try the account history index first, which doesn't need the state
+	_, balance, ok, historyErr := s.b.AccountHistory(ctx, address, blockNrOrHash)
+	if historyErr == nil && ok {
+		return (*hexutil.Big)(balance), nil
+	}
 	state, _, err := s.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
 	if state == nil || err != nil {
+		// Explain why the index doesn't cover the block either
+		if historyErr != nil {
+			return nil, historyErr
+		}
 		return nil, err
 	}
 	return (*hexutil.Big)(state.GetBalance(address)), state.Error()
@@ -1115,9 +1351,21 @@ func doCall(ctx context.Context, b Backend, args TransactionArgs, state *state.S
 	return result, nil
 }
 
-func DoCall(ctx context.Context, b Backend, args TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *StateOverride, blockOverrides *BlockOverrides, timeout time.Duration, globalGasCap uint64) (*core.ExecutionResult, error) {
+func DoCall(ctx context.Context, b Backend, args TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *StateOverride, blockOverrides *BlockOverrides, timeout time.Duration, globalGasCap uint64) (result *core.ExecutionResult, err error) {
 	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
 
+	ctx, span := tracing.StartSpan(ctx, "ethapi.DoCall", tracing.String("block", blockNrOrHash.String()))
+	if span != nil && args.To != nil {
+		span.SetAttributes(tracing.String("to", args.To.Hex()))
+	}
+	defer func() {
+		if result != nil {
+			span.SetAttributes(tracing.Uint64("gas.used", result.UsedGas), tracing.Bool("reverted", result.Failed()))
+		}
+		span.SetError(err)
+		span.End()
+	}()
+
 	state, header, err := b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
 	if state == nil || err != nil {
 		return nil, err
@@ -1874,12 +2122,20 @@ func (s *TransactionAPI) GetTransactionCount(ctx context.Context, address common
 		}
 		return (*hexutil.Uint64)(&nonce), nil
 	}
-	// Resolve block number and use its state to ask for the nonce
+	// Try the account history index, otherwise resolve the state of the block
+	nonce, _, ok, historyErr := s.b.AccountHistory(ctx, address, blockNrOrHash)
+	if historyErr == nil && ok {
+		return (*hexutil.Uint64)(&nonce), nil
+	}
 	state, _, err := s.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
 	if state == nil || err != nil {
+		// Explain why the index doesn't cover the block either
+		if historyErr != nil {
+			return nil, historyErr
+		}
 		return nil, err
 	}
-	nonce := state.GetNonce(address)
+	nonce = state.GetNonce(address)
 	return (*hexutil.Uint64)(&nonce), state.Error()
 }
 
@@ -2361,8 +2617,8 @@ func (api *DebugAPI) ChaindbCompact() error {
 }
 
 // SetHead rewinds the head of the blockchain to a previous block.
-func (api *DebugAPI) SetHead(number hexutil.Uint64) {
-	api.b.SetHead(uint64(number))
+func (api *DebugAPI) SetHead(number hexutil.Uint64) error {
+	return api.b.SetHead(uint64(number))
 }
 
 // NetAPI offers network related RPC methods
@@ -26,6 +26,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -40,6 +41,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/bloombits"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/txpool"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -417,6 +419,8 @@ func newTestBackend(t *testing.T, n int, gspec *core.Genesis, engine consensus.E
 			TrieTimeLimit:     5 * time.Minute,
 			SnapshotLimit:     0,
 			TrieDirtyDisabled: true, // Archive mode
+			TxHistory:         true,
+			AccountHistory:    true,
 		}
 	)
 	// Generate blocks for testing
@@ -445,6 +449,7 @@ func (b testBackend) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
 func (b testBackend) FeeHistory(ctx context.Context, blockCount uint64, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*big.Int, [][]*big.Int, []*big.Int, []float64, error) {
 	return nil, nil, nil, nil, nil
 }
+func (b testBackend) Chain() *core.BlockChain           { return b.chain }
 func (b testBackend) ChainDb() ethdb.Database           { return b.db }
 func (b testBackend) AccountManager() *accounts.Manager { return nil }
 func (b testBackend) ExtRPCEnabled() bool               { return false }
@@ -452,7 +457,7 @@ func (b testBackend) RPCGasCap() uint64                 { return 10000000 }
 func (b testBackend) RPCEVMTimeout() time.Duration      { return time.Second }
 func (b testBackend) RPCTxFeeCap() float64              { return 0 }
 func (b testBackend) UnprotectedAllowed() bool          { return false }
-func (b testBackend) SetHead(number uint64)             {}
+func (b testBackend) SetHead(number uint64) error       { return nil }
 func (b testBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
 	if number == rpc.LatestBlockNumber {
 		return b.chain.CurrentBlock(), nil
@@ -522,6 +527,16 @@ func (b testBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOr
 	panic("only implemented for number")
 }
 func (b testBackend) PendingBlockAndReceipts() (*types.Block, types.Receipts) { panic("implement me") }
+func (b testBackend) AccountHistory(ctx context.Context, address common.Address, blockNrOrHash rpc.BlockNumberOrHash) (uint64, *big.Int, bool, error) {
+	header, err := b.HeaderByNumberOrHash(ctx, blockNrOrHash)
+	if header == nil || err != nil {
+		return 0, nil, false, err
+	}
+	return b.chain.AccountHistoryAt(address, header.Hash(), header.Number.Uint64())
+}
+func (b testBackend) TransactionsByAddress(ctx context.Context, address common.Address, number uint64, index uint32, limit int) ([]*rawdb.AddressTransaction, error) {
+	return b.chain.TransactionsByAddress(address, number, index, limit)
+}
 func (b testBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
 	header, err := b.HeaderByHash(ctx, hash)
 	if header == nil || err != nil {
@@ -579,6 +594,9 @@ func (b testBackend) TxPoolContentFrom(addr common.Address) ([]*types.Transactio
 func (b testBackend) SubscribeNewTxsEvent(events chan<- core.NewTxsEvent) event.Subscription {
 	panic("implement me")
 }
+func (b testBackend) SubscribeTxPoolEvents(events chan<- []*txpool.TxEvent) event.Subscription {
+	panic("implement me")
+}
 func (b testBackend) ChainConfig() *params.ChainConfig { return b.chain.Config() }
 func (b testBackend) Engine() consensus.Engine         { return b.chain.Engine() }
 func (b testBackend) GetLogs(ctx context.Context, blockHash common.Hash, number uint64) ([][]*types.Log, error) {
@@ -587,6 +605,9 @@ func (b testBackend) GetLogs(ctx context.Context, blockHash common.Hash, number
 func (b testBackend) SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription {
 	panic("implement me")
 }
+func (b testBackend) SubscribeFinalizedHeaderEvent(ch chan<- core.FinalizedHeaderEvent) event.Subscription {
+	panic("implement me")
+}
 func (b testBackend) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription {
 	panic("implement me")
 }
@@ -846,7 +867,7 @@ func TestCall(t *testing.T) {
 		},
 	}
 	for i, tc := range testSuite {
-		result, err := api.Call(context.Background(), tc.call, &rpc.BlockNumberOrHash{BlockNumber: &tc.blockNumber}, &tc.overrides, &tc.blockOverrides)
+		result, err := api.Call(context.Background(), tc.call, rpc.BlockNumberOrHash{BlockNumber: &tc.blockNumber}, &tc.overrides, &tc.blockOverrides)
 		if tc.expectErr != nil {
 			if err == nil {
 				t.Errorf("test %d: want error %v, have nothing", i, tc.expectErr)
@@ -870,11 +891,244 @@ func TestCall(t *testing.T) {
 	}
 }
 
+func TestSimulateV1(t *testing.T) {
+	t.Parallel()
+	// Initialize test accounts
+	var (
+		accounts = newAccounts(3)
+		genesis  = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+			},
+		}
+		genBlocks = 10
+	)
+	api := NewBlockChainAPI(newTestBackend(t, genBlocks, genesis, ethash.NewFaker(), func(i int, b *core.BlockGen) {}))
+
+	// Returns the balance of accounts[2]
+	getBalance := append(append(hexutil.Bytes{0x73}, accounts[2].addr.Bytes()...),
+		0x31,             // BALANCE
+		0x60, 0x00, 0x52, // MSTORE offset 0
+		0x60, 0x20, 0x60, 0x00, 0xf3,
+	)
+	opts := SimulateOpts{
+		TraceTransfers: true,
+		BlockStateCalls: []SimulateBlock{
+			{
+				StateOverrides: &StateOverride{
+					accounts[1].addr: OverrideAccount{Balance: newRPCBalance(big.NewInt(params.Ether))},
+				},
+				Calls: []TransactionArgs{
+					{
+						From:  &accounts[1].addr,
+						To:    &accounts[2].addr,
+						Value: (*hexutil.Big)(big.NewInt(1000)),
+					},
+					{
+						From:  &accounts[0].addr,
+						Input: &hexutil.Bytes{0x60, 0x00, 0x60, 0x00, 0xfd}, // REVERT
+					},
+				},
+			},
+			{
+				BlockOverrides: &BlockOverrides{Number: (*hexutil.Big)(big.NewInt(20))},
+				Calls: []TransactionArgs{
+					{
+						From:  &accounts[0].addr,
+						Input: &getBalance,
+					},
+					{
+						From: &accounts[0].addr,
+						Input: &hexutil.Bytes{
+							0x43,             // NUMBER
+							0x60, 0x00, 0x52, // MSTORE offset 0
+							0x60, 0x20, 0x60, 0x00, 0xf3,
+						},
+					},
+				},
+			},
+		},
+	}
+	results, err := api.SimulateV1(context.Background(), opts, nil)
+	if err != nil {
+		t.Fatalf("simulation failed: %v", err)
+	}
+	blob, err := json.Marshal(results)
+	if err != nil {
+		t.Fatalf("failed to encode results: %v", err)
+	}
+	var blocks []struct {
+		Number     *hexutil.Big
+		ParentHash common.Hash
+		Hash       common.Hash
+		Calls      []struct {
+			Status     hexutil.Uint64
+			ReturnData hexutil.Bytes
+			Logs       []*types.Log
+			Error      *simulateCallError
+		}
+	}
+	if err := json.Unmarshal(blob, &blocks); err != nil {
+		t.Fatalf("failed to decode results: %v", err)
+	}
+	if len(blocks) != 2 {
+		t.Fatalf("block count mismatch: have %d, want 2", len(blocks))
+	}
+	if have := blocks[0].Number.ToInt().Uint64(); have != uint64(genBlocks+1) {
+		t.Errorf("block 0: number mismatch: have %d, want %d", have, genBlocks+1)
+	}
+	if have := blocks[1].Number.ToInt().Uint64(); have != 20 {
+		t.Errorf("block 1: number mismatch: have %d, want 20", have)
+	}
+	if blocks[1].ParentHash != blocks[0].Hash {
+		t.Errorf("block 1: parent hash mismatch: have %x, want %x", blocks[1].ParentHash, blocks[0].Hash)
+	}
+	// The transfer should be logged, the revert reported
+	transfer := blocks[0].Calls[0]
+	if uint64(transfer.Status) != types.ReceiptStatusSuccessful || len(transfer.Logs) != 1 {
+		t.Fatalf("transfer: status %d, %d logs", transfer.Status, len(transfer.Logs))
+	}
+	if log := transfer.Logs[0]; log.Address != transferAddress || log.Topics[2] != common.BytesToHash(accounts[2].addr.Bytes()) {
+		t.Errorf("transfer: log mismatch: %v", log)
+	}
+	revert := blocks[0].Calls[1]
+	if uint64(revert.Status) != types.ReceiptStatusFailed || revert.Error == nil || revert.Error.Code != 3 {
+		t.Errorf("revert: status %d, error %v", revert.Status, revert.Error)
+	}
+	// The state and the block overrides should carry to the next block
+	if have := new(big.Int).SetBytes(blocks[1].Calls[0].ReturnData); have.Cmp(big.NewInt(1000)) != 0 {
+		t.Errorf("balance mismatch: have %v, want 1000", have)
+	}
+	if have := new(big.Int).SetBytes(blocks[1].Calls[1].ReturnData); have.Cmp(big.NewInt(20)) != 0 {
+		t.Errorf("number mismatch: have %v, want 20", have)
+	}
+}
+
 type Account struct {
 	key  *ecdsa.PrivateKey
 	addr common.Address
 }
 
+func TestGetTransactionsByAddress(t *testing.T) {
+	t.Parallel()
+	// Initialize test accounts
+	var (
+		accounts = newAccounts(2)
+		genesis  = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+			},
+		}
+		genBlocks = 3
+		signer    = types.HomesteadSigner{}
+	)
+	backend := newTestBackend(t, genBlocks, genesis, ethash.NewFaker(), func(i int, b *core.BlockGen) {
+		// Two transfers from account[0] to account[1] per block
+		for j := 0; j < 2; j++ {
+			tx, _ := types.SignTx(types.NewTx(&types.LegacyTx{Nonce: b.TxNonce(accounts[0].addr), To: &accounts[1].addr, Value: big.NewInt(1000), Gas: params.TxGas, GasPrice: b.BaseFee()}), signer, accounts[0].key)
+			b.AddTx(tx)
+		}
+	})
+	api := NewTransactionAPI(backend, new(AddrLocker))
+
+	var (
+		limit  = hexutil.Uint(4)
+		cursor *TxHistoryCursor
+		hashes []common.Hash
+	)
+	for pages := 0; ; pages++ {
+		if pages == 2 {
+			t.Fatal("too many pages")
+		}
+		page, err := api.GetTransactionsByAddress(context.Background(), accounts[1].addr, cursor, &limit)
+		if err != nil {
+			t.Fatalf("failed to retrieve transactions: %v", err)
+		}
+		for _, tx := range page.Transactions {
+			hashes = append(hashes, tx.Hash)
+		}
+		if cursor = page.Cursor; cursor == nil {
+			break
+		}
+		// Round-trip the cursor through its encoding
+		enc, _ := cursor.MarshalText()
+		cursor = new(TxHistoryCursor)
+		if err := cursor.UnmarshalText(enc); err != nil {
+			t.Fatalf("failed to decode cursor %s: %v", enc, err)
+		}
+	}
+	var want []common.Hash
+	for n := genBlocks; n > 0; n-- {
+		txs := backend.chain.GetBlockByNumber(uint64(n)).Transactions()
+		want = append(want, txs[1].Hash(), txs[0].Hash())
+	}
+	if !reflect.DeepEqual(hashes, want) {
+		t.Errorf("transactions mismatch: have %v, want %v", hashes, want)
+	}
+}
+
+// statelessBackend serves the chain without the states of its blocks.
+type statelessBackend struct {
+	*testBackend
+}
+
+func (b statelessBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.Header, error) {
+	return nil, nil, errors.New("state unavailable")
+}
+
+func TestAccountHistory(t *testing.T) {
+	t.Parallel()
+	// Initialize test accounts
+	var (
+		accounts = newAccounts(2)
+		genesis  = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+			},
+		}
+		genBlocks = 3
+		signer    = types.HomesteadSigner{}
+	)
+	backend := newTestBackend(t, genBlocks, genesis, ethash.NewFaker(), func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTx(&types.LegacyTx{Nonce: b.TxNonce(accounts[0].addr), To: &accounts[1].addr, Value: big.NewInt(1000), Gas: params.TxGas, GasPrice: b.BaseFee()}), signer, accounts[0].key)
+		b.AddTx(tx)
+	})
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		if tail := rawdb.ReadAccountHistoryTail(backend.db); tail != nil && *tail == 0 {
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("account history not back-filled")
+		}
+	}
+	// Serve the balances and nonces from the index alone
+	var (
+		blockAPI = NewBlockChainAPI(statelessBackend{backend})
+		txAPI    = NewTransactionAPI(statelessBackend{backend}, new(AddrLocker))
+	)
+	for n := 0; n <= genBlocks; n++ {
+		number := rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(n))
+
+		balance, err := blockAPI.GetBalance(context.Background(), accounts[1].addr, number)
+		if err != nil {
+			t.Fatalf("block %d: failed to retrieve balance: %v", n, err)
+		}
+		if want := big.NewInt(int64(1000 * n)); balance.ToInt().Cmp(want) != 0 {
+			t.Errorf("block %d: balance mismatch: have %v, want %v", n, balance, want)
+		}
+		nonce, err := txAPI.GetTransactionCount(context.Background(), accounts[0].addr, number)
+		if err != nil {
+			t.Fatalf("block %d: failed to retrieve nonce: %v", n, err)
+		}
+		if uint64(*nonce) != uint64(n) {
+			t.Errorf("block %d: nonce mismatch: have %d, want %d", n, *nonce, n)
+		}
+	}
+}
+
 func newAccounts(n int) (accounts []Account) {
 	for i := 0; i < n; i++ {
 		key, _ := crypto.GenerateKey()
@@ -1360,6 +1614,7 @@ func TestRPCGetBlockOrHeader(t *testing.T) {
 
 func setupReceiptBackend(t *testing.T, genBlocks int) (*testBackend, []common.Hash) {
 	config := *params.TestChainConfig
+	config.MontanasBlock = big.NewInt(0)
 	config.ShanghaiTime = new(uint64)
 	config.CancunTime = new(uint64)
 	var (
@@ -1635,3 +1890,205 @@ func testRPCResponseWithFile(t *testing.T, testid int, result interface{}, rpc s
 	}
 	require.JSONEqf(t, string(want), string(data), "test %d: json not match, want: %s, have: %s", testid, string(want), string(data))
 }
+
+// poolBackend serves a fixed transaction pool content on top of the chain.
+type poolBackend struct {
+	*testBackend
+	pending []*types.Transaction
+	queued  []*types.Transaction
+	nonce   uint64
+	events  event.Feed
+}
+
+func (b *poolBackend) GetPoolTransaction(hash common.Hash) *types.Transaction {
+	for _, tx := range append(b.pending, b.queued...) {
+		if tx.Hash() == hash {
+			return tx
+		}
+	}
+	return nil
+}
+func (b *poolBackend) GetPoolNonce(ctx context.Context, addr common.Address) (uint64, error) {
+	return b.nonce, nil
+}
+func (b *poolBackend) TxPoolContentFrom(addr common.Address) ([]*types.Transaction, []*types.Transaction) {
+	return b.pending, b.queued
+}
+func (b *poolBackend) SubscribeTxPoolEvents(events chan<- []*txpool.TxEvent) event.Subscription {
+	return b.events.Subscribe(events)
+}
+
+func TestTxPoolContentAt(t *testing.T) {
+	t.Parallel()
+
+	var (
+		accounts = newAccounts(1)
+		genesis  = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{accounts[0].addr: {Balance: big.NewInt(params.Ether)}},
+		}
+		signer = types.LatestSigner(params.TestChainConfig)
+		tx     = func(nonce uint64) *types.Transaction {
+			return types.MustSignNewTx(accounts[0].key, signer, &types.LegacyTx{Nonce: nonce, To: &common.Address{0x01}, Gas: params.TxGas, GasPrice: big.NewInt(params.GWei)})
+		}
+		backend = &poolBackend{testBackend: newTestBackend(t, 0, genesis, ethash.NewFaker(), nil)}
+		api     = NewTxPoolAPI(backend)
+	)
+	backend.pending = []*types.Transaction{tx(0)}
+	backend.queued = []*types.Transaction{tx(2)}
+
+	for _, tt := range []struct {
+		tx     *types.Transaction
+		status string
+	}{
+		{backend.pending[0], "pending"},
+		{backend.queued[0], "queued"},
+	} {
+		result := api.ContentAt(tt.tx.Hash())
+		if result == nil {
+			t.Fatalf("nonce %d: transaction not found", tt.tx.Nonce())
+		}
+		if result["status"] != tt.status {
+			t.Errorf("nonce %d: status mismatch: have %v, want %s", tt.tx.Nonce(), result["status"], tt.status)
+		}
+		if rpcTx := result["transaction"].(*RPCTransaction); rpcTx.Hash != tt.tx.Hash() || rpcTx.From != accounts[0].addr {
+			t.Errorf("nonce %d: transaction mismatch: have %x from %x, want %x from %x", tt.tx.Nonce(), rpcTx.Hash, rpcTx.From, tt.tx.Hash(), accounts[0].addr)
+		}
+	}
+	if result := api.ContentAt(tx(1).Hash()); result != nil {
+		t.Errorf("untracked transaction found: %v", result)
+	}
+}
+
+func TestTxPoolDiagnose(t *testing.T) {
+	t.Parallel()
+
+	var (
+		accounts = newAccounts(1)
+		genesis  = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{accounts[0].addr: {Balance: big.NewInt(params.Ether)}},
+		}
+		signer = types.LatestSigner(params.TestChainConfig)
+		tx     = func(nonce uint64, value *big.Int, gas uint64, gasPrice *big.Int) *types.Transaction {
+			return types.MustSignNewTx(accounts[0].key, signer, &types.LegacyTx{Nonce: nonce, To: &common.Address{0x01}, Value: value, Gas: gas, GasPrice: gasPrice})
+		}
+		gwei    = big.NewInt(params.GWei)
+		backend = &poolBackend{testBackend: newTestBackend(t, 1, genesis, ethash.NewFaker(), func(i int, b *core.BlockGen) {
+			b.AddTx(tx(0, nil, params.TxGas, gwei))
+		})}
+		api = NewTxPoolAPI(backend)
+	)
+	backend.nonce = 2
+	backend.pending = []*types.Transaction{tx(1, nil, params.TxGas, gwei)}
+	backend.queued = []*types.Transaction{
+		tx(3, big.NewInt(2*params.Ether), params.TxGas, gwei), // Exceeds the balance
+		tx(6, nil, 10_000_000, big.NewInt(1)),                 // Below base fee, above gas limit
+	}
+	result, err := api.Diagnose(context.Background(), accounts[0].addr)
+	if err != nil {
+		t.Fatalf("failed to diagnose account: %v", err)
+	}
+	if result.StateNonce != 1 || result.PoolNonce != 2 || result.Pending != 1 || result.Queued != 2 {
+		t.Errorf("summary mismatch: have nonces %d/%d, counts %d/%d, want 1/2, 1/2", result.StateNonce, result.PoolNonce, result.Pending, result.Queued)
+	}
+	wantGaps := []NonceGap{{From: 2, To: 2}, {From: 4, To: 5}}
+	if !reflect.DeepEqual(result.Gaps, wantGaps) {
+		t.Errorf("gaps mismatch: have %v, want %v", result.Gaps, wantGaps)
+	}
+	wantIssues := []string{
+		"missing nonces 2-2",
+		"missing nonces 4-5",
+		"nonce 3: cost",
+		"nonce 6: fee cap 1 below base fee",
+		"nonce 6: gas 10000000 exceeds block gas limit",
+	}
+	if len(result.Issues) != len(wantIssues) {
+		t.Fatalf("issue count mismatch: have %q, want %d", result.Issues, len(wantIssues))
+	}
+	for i, want := range wantIssues {
+		if !strings.HasPrefix(result.Issues[i], want) {
+			t.Errorf("issue %d mismatch: have %q, want prefix %q", i, result.Issues[i], want)
+		}
+	}
+}
+
+func TestTxPoolEvents(t *testing.T) {
+	t.Parallel()
+
+	var (
+		accounts = newAccounts(2)
+		genesis  = &core.Genesis{Config: params.TestChainConfig}
+		signer   = types.LatestSigner(params.TestChainConfig)
+		tx       = func(from int, nonce uint64, to common.Address) *types.Transaction {
+			return types.MustSignNewTx(accounts[from].key, signer, &types.LegacyTx{Nonce: nonce, To: &to, Gas: params.TxGas, GasPrice: big.NewInt(params.GWei)})
+		}
+		backend = &poolBackend{testBackend: newTestBackend(t, 0, genesis, ethash.NewFaker(), nil)}
+		server  = rpc.NewServer()
+	)
+	defer server.Stop()
+	if err := server.RegisterName("txpool", NewTxPoolAPI(backend)); err != nil {
+		t.Fatalf("failed to register API: %v", err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	// Subscribe to the rejections and replacements involving the first account
+	events := make(chan *RPCTxPoolEvent, 16)
+	sub, err := client.Subscribe(context.Background(), "txpool", events, "events", &TxPoolEventFilter{
+		Addresses: []common.Address{accounts[0].addr},
+		Types:     []string{"rejected", "replaced"},
+	})
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	var (
+		rejected    = tx(1, 0, accounts[0].addr)
+		replaced    = tx(0, 0, common.Address{0x01})
+		replacement = tx(0, 0, common.Address{0x02})
+		replaceHash = replacement.Hash()
+	)
+	backend.events.Send([]*txpool.TxEvent{
+		{Kind: txpool.TxEventAdmitted, Tx: tx(0, 1, common.Address{0x01})},                                // Filtered by type
+		{Kind: txpool.TxEventRejected, Tx: tx(1, 1, common.Address{0x01}), Reason: txpool.ErrUnderpriced}, // Filtered by address
+		{Kind: txpool.TxEventRejected, Tx: rejected, Reason: txpool.ErrUnderpriced},
+		{Kind: txpool.TxEventReplaced, Tx: replaced, Replacement: replacement.Hash()},
+	})
+	want := []*RPCTxPoolEvent{
+		{
+			Type:   "rejected",
+			Hash:   rejected.Hash(),
+			From:   accounts[1].addr,
+			To:     &accounts[0].addr,
+			Reason: txpool.ReasonCode(txpool.ErrUnderpriced),
+			Error:  txpool.ErrUnderpriced.Error(),
+		},
+		{
+			Type:        "replaced",
+			Hash:        replaced.Hash(),
+			From:        accounts[0].addr,
+			To:          replaced.To(),
+			Replacement: &replaceHash,
+		},
+	}
+
+	for i := range want {
+		select {
+		case ev := <-events:
+			if !reflect.DeepEqual(ev, want[i]) {
+				t.Errorf("event %d mismatch: have %+v, want %+v", i, ev, want[i])
+			}
+		case err := <-sub.Err():
+			t.Fatalf("subscription failed: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("event %d not delivered", i)
+		}
+	}
+	select {
+	case ev := <-events:
+		t.Errorf("unexpected event: %+v", ev)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,528 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/consensus/misc/eip1559"
+	"github.com/ethereum/go-ethereum/consensus/misc/eip4844"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+const (
+	// maxSimulateBlocks is the maximum number of blocks a simulation may span.
+	maxSimulateBlocks = 256
+
+	// simulateTimestampIncrement is the default difference between the
+	// timestamps of consecutive simulated blocks, unless the chain runs with
+	// a block period of its own.
+	simulateTimestampIncrement = 12
+)
+
+var (
+	// transferAddress is the pseudo contract emitting the logs of ether
+	// transfers, if requested by the simulation.
+	transferAddress = common.HexToAddress("0xEeeeeEeeeEeEeeEeEeEeeEEEeeeeEeeeeeeeEEeE")
+
+	// transferTopic is the topic of the ether transfer logs, same as the one
+	// of ERC-20 transfers.
+	transferTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+)
+
+// SimulateOpts are the arguments of a multi-block simulation.
+type SimulateOpts struct {
+	BlockStateCalls        []SimulateBlock `json:"blockStateCalls"`
+	TraceTransfers         bool            `json:"traceTransfers"`         // Emit logs for ether transfers
+	Validation             bool            `json:"validation"`             // Enforce nonces, balances and base fees
+	ReturnFullTransactions bool            `json:"returnFullTransactions"` // Return transaction objects, not hashes
+}
+
+// SimulateBlock is a simulated block: the overrides applied before its calls
+// are executed, and the calls themselves.
+type SimulateBlock struct {
+	BlockOverrides *BlockOverrides   `json:"blockOverrides"`
+	StateOverrides *StateOverride    `json:"stateOverrides"`
+	Calls          []TransactionArgs `json:"calls"`
+}
+
+// simulateCallError is the error of a failed simulated call.
+type simulateCallError struct {
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+	Data    string `json:"data,omitempty"`
+}
+
+// simulator executes the blocks of a simulation one after the other on top of
+// the same state.
+type simulator struct {
+	b       Backend
+	config  *params.ChainConfig
+	state   *state.StateDB
+	base    *types.Header   // Header of the block the simulation builds on
+	headers []*types.Header // Headers of the blocks simulated so far
+	opts    *SimulateOpts
+	gasCap  uint64 // Gas left to all the calls of the simulation
+}
+
+// SimulateV1 executes a sequence of simulated blocks on top of the given block.
+// Every block may override the header fields of its own and the state, the
+// state changes of every call carry over to the following calls and blocks.
+// The simulated blocks are returned along with the outcome of their calls, in
+// the format of receipts extended with the return data and the error, if any.
+//
+// Unless validation is requested, calls are executed like eth_call: nonces and
+// balances aren't enforced and the base fee defaults to zero. The calls are
+// never signed. Block rewards and withdrawals are not simulated.
+func (s *BlockChainAPI) SimulateV1(ctx context.Context, opts SimulateOpts, blockNrOrHash *rpc.BlockNumberOrHash) ([]map[string]interface{}, error) {
+	if len(opts.BlockStateCalls) == 0 {
+		return nil, errors.New("empty input")
+	}
+	if len(opts.BlockStateCalls) > maxSimulateBlocks {
+		return nil, fmt.Errorf("too many blocks: %d > %d", len(opts.BlockStateCalls), maxSimulateBlocks)
+	}
+	bNrOrHash := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+	if blockNrOrHash != nil {
+		bNrOrHash = *blockNrOrHash
+	}
+	state, header, err := s.b.StateAndHeaderByNumberOrHash(ctx, bNrOrHash)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	// The whole simulation shares the timeout and the gas cap of a call
+	timeout := s.b.RPCEVMTimeout()
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	sim := &simulator{
+		b:      s.b,
+		config: s.b.ChainConfig(),
+		state:  state,
+		base:   header,
+		opts:   &opts,
+		gasCap: s.b.RPCGasCap(),
+	}
+	if sim.gasCap == 0 {
+		sim.gasCap = math.MaxUint64
+	}
+	results := make([]map[string]interface{}, 0, len(opts.BlockStateCalls))
+	for i, block := range opts.BlockStateCalls {
+		result, err := sim.processBlock(ctx, &block)
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return nil, fmt.Errorf("execution aborted (timeout = %v)", timeout)
+			}
+			return nil, fmt.Errorf("block %d: %w", i, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// makeHeader creates the header of the next simulated block, without the
+// fields depending on the execution of its calls.
+func (sim *simulator) makeHeader(overrides *BlockOverrides) (*types.Header, error) {
+	parent := sim.base
+	if len(sim.headers) > 0 {
+		parent = sim.headers[len(sim.headers)-1]
+	}
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		UncleHash:  types.EmptyUncleHash,
+		Coinbase:   parent.Coinbase,
+		Difficulty: parent.Difficulty,
+		Number:     new(big.Int).Add(parent.Number, common.Big1),
+		GasLimit:   parent.GasLimit,
+		Time:       parent.Time + sim.blockPeriod(),
+		MixDigest:  parent.MixDigest,
+	}
+	if overrides != nil {
+		if overrides.Number != nil {
+			if overrides.Number.ToInt().Cmp(parent.Number) <= 0 {
+				return nil, fmt.Errorf("block numbers must be increasing: %v <= %v", overrides.Number.ToInt(), parent.Number)
+			}
+			header.Number = new(big.Int).Set(overrides.Number.ToInt())
+		}
+		if overrides.Time != nil {
+			if uint64(*overrides.Time) <= parent.Time {
+				return nil, fmt.Errorf("block timestamps must be increasing: %d <= %d", uint64(*overrides.Time), parent.Time)
+			}
+			header.Time = uint64(*overrides.Time)
+		}
+		if overrides.GasLimit != nil {
+			header.GasLimit = uint64(*overrides.GasLimit)
+		}
+		if overrides.Coinbase != nil {
+			header.Coinbase = *overrides.Coinbase
+		}
+		if overrides.Difficulty != nil {
+			header.Difficulty = new(big.Int).Set(overrides.Difficulty.ToInt())
+		}
+		if overrides.Random != nil {
+			header.MixDigest = *overrides.Random
+		}
+	}
+	if sim.config.IsLondon(header.Number) {
+		switch {
+		case overrides != nil && overrides.BaseFee != nil:
+			header.BaseFee = new(big.Int).Set(overrides.BaseFee.ToInt())
+		case sim.opts.Validation && parent.BaseFee != nil:
+			header.BaseFee = eip1559.CalcBaseFee(sim.config, parent)
+		default:
+			header.BaseFee = new(big.Int)
+		}
+	}
+	if sim.config.IsShanghai(header.Number, header.Time) {
+		header.WithdrawalsHash = &types.EmptyWithdrawalsHash
+	}
+	if sim.config.IsCancun(header.Number, header.Time) {
+		var excess uint64
+		if parent.ExcessBlobGas != nil && parent.BlobGasUsed != nil {
+			excess = eip4844.CalcExcessBlobGas(*parent.ExcessBlobGas, *parent.BlobGasUsed)
+		}
+		header.ExcessBlobGas, header.BlobGasUsed = &excess, new(uint64)
+		header.ParentBeaconRoot = new(common.Hash)
+	}
+	return header, nil
+}
+
+// blockPeriod returns the default time between two simulated blocks.
+func (sim *simulator) blockPeriod() uint64 {
+	if sim.config.Zephyria != nil && sim.config.Zephyria.Period > 0 {
+		return sim.config.Zephyria.Period
+	}
+	return simulateTimestampIncrement
+}
+
+// getHashFn returns the block hash resolver of a simulated block, resolving
+// the preceding simulated blocks as well as the canonical ones.
+func (sim *simulator) getHashFn(ctx context.Context) func(uint64) common.Hash {
+	canonical := core.GetHashFn(sim.base, NewChainContext(ctx, sim.b))
+	return func(n uint64) common.Hash {
+		switch {
+		case n == sim.base.Number.Uint64():
+			return sim.base.Hash()
+		case n < sim.base.Number.Uint64():
+			return canonical(n)
+		}
+		for _, header := range sim.headers {
+			if header.Number.Uint64() == n {
+				return header.Hash()
+			}
+		}
+		return common.Hash{}
+	}
+}
+
+// processBlock executes the calls of a simulated block and assembles it.
+func (sim *simulator) processBlock(ctx context.Context, block *SimulateBlock) (map[string]interface{}, error) {
+	header, err := sim.makeHeader(block.BlockOverrides)
+	if err != nil {
+		return nil, err
+	}
+	if err := block.StateOverrides.Apply(sim.state); err != nil {
+		return nil, err
+	}
+	var (
+		gp       = new(core.GasPool).AddGas(header.GasLimit)
+		signer   = types.MakeSigner(sim.config, header.Number, header.Time)
+		blockCtx = core.NewEVMBlockContext(header, NewChainContext(ctx, sim.b), &header.Coinbase)
+		vmConfig = vm.Config{NoBaseFee: !sim.opts.Validation}
+		tracer   *transferTracer
+
+		txs      = make([]*types.Transaction, 0, len(block.Calls))
+		receipts = make([]*types.Receipt, 0, len(block.Calls))
+		senders  = make([]common.Address, 0, len(block.Calls))
+		results  = make([]*core.ExecutionResult, 0, len(block.Calls))
+		usedGas  uint64
+		logIndex uint
+	)
+	blockCtx.GetHash = sim.getHashFn(ctx)
+	if block.BlockOverrides != nil && block.BlockOverrides.BlobBaseFee != nil {
+		blockCtx.BlobBaseFee = block.BlockOverrides.BlobBaseFee.ToInt()
+	}
+	if sim.opts.TraceTransfers {
+		tracer = new(transferTracer)
+		vmConfig.Tracer = tracer
+	}
+	for i := range block.Calls {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		args := block.Calls[i]
+		msg, tx, err := sim.prepareCall(&args, header, gp.Gas())
+		if err != nil {
+			return nil, fmt.Errorf("call %d: %w", i, err)
+		}
+		sim.state.SetTxContext(tx.Hash(), i)
+
+		var contract common.Address
+		if msg.To == nil {
+			contract = crypto.CreateAddress(msg.From, sim.state.GetNonce(msg.From))
+		}
+		evm, vmError := sim.b.GetEVM(ctx, msg, sim.state, header, &vmConfig, &blockCtx)
+		done := make(chan struct{})
+		go func() {
+			select {
+			case <-ctx.Done():
+				evm.Cancel()
+			case <-done:
+			}
+		}()
+		result, err := core.ApplyMessage(evm, msg, gp)
+		close(done)
+		if err := vmError(); err != nil {
+			return nil, err
+		}
+		if evm.Cancelled() {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("call %d: %w", i, err)
+		}
+		sim.gasCap -= result.UsedGas
+
+		var root []byte
+		if sim.config.IsByzantium(header.Number) {
+			sim.state.Finalise(true)
+		} else {
+			root = sim.state.IntermediateRoot(sim.config.IsEIP158(header.Number)).Bytes()
+		}
+		usedGas += result.UsedGas
+
+		receipt := &types.Receipt{
+			Type:              tx.Type(),
+			PostState:         root,
+			CumulativeGasUsed: usedGas,
+			TxHash:            tx.Hash(),
+			GasUsed:           result.UsedGas,
+			EffectiveGasPrice: msg.GasPrice,
+			ContractAddress:   contract,
+			BlockNumber:       header.Number,
+			TransactionIndex:  uint(i),
+		}
+		if result.Failed() {
+			receipt.Status = types.ReceiptStatusFailed
+		} else {
+			receipt.Status = types.ReceiptStatusSuccessful
+		}
+		if tracer != nil {
+			receipt.Logs = tracer.Logs(tx.Hash(), i, header.Number.Uint64())
+		} else {
+			receipt.Logs = sim.state.GetLogs(tx.Hash(), header.Number.Uint64(), common.Hash{})
+		}
+		for _, log := range receipt.Logs {
+			log.Index = logIndex
+			logIndex++
+		}
+		receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+
+		txs = append(txs, tx)
+		receipts = append(receipts, receipt)
+		senders = append(senders, msg.From)
+		results = append(results, result)
+	}
+	header.GasUsed = usedGas
+	header.Root = sim.state.IntermediateRoot(sim.config.IsEIP158(header.Number))
+
+	assembled := types.NewBlock(header, txs, nil, receipts, trie.NewStackTrie(nil))
+	sim.headers = append(sim.headers, assembled.Header())
+
+	hash := assembled.Hash()
+	calls := make([]map[string]interface{}, len(receipts))
+	for i, receipt := range receipts {
+		receipt.BlockHash = hash
+		for _, log := range receipt.Logs {
+			log.BlockHash = hash
+		}
+		call := marshalReceipt(receipt, hash, header.Number.Uint64(), signer, txs[i], i)
+		call["from"] = senders[i]
+		call["returnData"] = hexutil.Bytes(results[i].Return())
+		if err := results[i].Err; err != nil {
+			callErr := &simulateCallError{Message: err.Error(), Code: errCodeVMError}
+			if errors.Is(err, vm.ErrExecutionReverted) {
+				revert := newRevertError(results[i])
+				callErr = &simulateCallError{Message: revert.Error(), Code: revert.ErrorCode(), Data: revert.reason}
+			}
+			call["error"] = callErr
+		}
+		calls[i] = call
+	}
+	fields := RPCMarshalBlock(assembled, true, sim.opts.ReturnFullTransactions, sim.config)
+	fields["calls"] = calls
+	return fields, nil
+}
+
+// errCodeVMError is the error code of simulated calls failing for reasons other
+// than a revert, which are reported like the reverts of eth_call.
+const errCodeVMError = -32015
+
+// prepareCall fills the defaults of a simulated call and converts it into the
+// message to execute and the transaction to include in the block. The gas limit
+// defaults to the gas left in the block, capped by the gas left to the whole
+// simulation.
+func (sim *simulator) prepareCall(args *TransactionArgs, header *types.Header, blockGas uint64) (*core.Message, *types.Transaction, error) {
+	if args.Nonce == nil {
+		nonce := hexutil.Uint64(sim.state.GetNonce(args.from()))
+		args.Nonce = &nonce
+	}
+	if args.Gas == nil {
+		gas := hexutil.Uint64(blockGas)
+		if sim.gasCap < blockGas {
+			gas = hexutil.Uint64(sim.gasCap)
+		}
+		args.Gas = &gas
+	}
+	if uint64(*args.Gas) > sim.gasCap {
+		return nil, nil, fmt.Errorf("gas cap exceeded: %d > %d", uint64(*args.Gas), sim.gasCap)
+	}
+	if args.ChainID == nil {
+		args.ChainID = (*hexutil.Big)(sim.config.ChainID)
+	}
+	msg, err := args.ToMessage(0, header.BaseFee)
+	if err != nil {
+		return nil, nil, err
+	}
+	msg.Nonce = uint64(*args.Nonce)
+	msg.SkipAccountChecks = !sim.opts.Validation
+
+	// Back the fee fields the transaction needs with the ones of the message
+	if args.MaxFeePerGas == nil && args.GasPrice == nil {
+		args.GasPrice = (*hexutil.Big)(msg.GasPrice)
+	}
+	if args.MaxFeePerGas != nil && args.MaxPriorityFeePerGas == nil {
+		args.MaxPriorityFeePerGas = (*hexutil.Big)(msg.GasTipCap)
+	}
+	return msg, args.ToTransaction(), nil
+}
+
+// transferTracer collects the logs of a call along with logs for the ether
+// transfers it makes, in the order they happen. The logs of reverted call
+// frames are dropped.
+type transferTracer struct {
+	frames [][]*types.Log // Logs of the call frames being executed
+	logs   []*types.Log   // Logs of the last call
+}
+
+func (t *transferTracer) transfer(from, to common.Address, value *big.Int) {
+	if value == nil || value.Sign() == 0 {
+		return
+	}
+	t.emit(&types.Log{
+		Address: transferAddress,
+		Topics:  []common.Hash{transferTopic, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())},
+		Data:    common.BigToHash(value).Bytes(),
+	})
+}
+
+func (t *transferTracer) emit(log *types.Log) {
+	frame := len(t.frames) - 1
+	t.frames[frame] = append(t.frames[frame], log)
+}
+
+// Logs returns the logs of the last call, attributed to the given transaction.
+func (t *transferTracer) Logs(txHash common.Hash, txIndex int, number uint64) []*types.Log {
+	for _, log := range t.logs {
+		log.TxHash, log.TxIndex, log.BlockNumber = txHash, uint(txIndex), number
+	}
+	return t.logs
+}
+
+func (t *transferTracer) CaptureTxStart(gasLimit uint64) { t.logs = nil }
+
+func (t *transferTracer) CaptureTxEnd(restGas uint64) {}
+
+func (t *transferTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.frames = [][]*types.Log{nil}
+	t.transfer(from, to, value)
+}
+
+func (t *transferTracer) CaptureEnd(output []byte, gasUsed uint64, err error) {
+	if err == nil {
+		t.logs = t.frames[0]
+	}
+	t.frames = nil
+}
+
+func (t *transferTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	t.frames = append(t.frames, nil)
+	switch typ {
+	case vm.CALL, vm.CREATE, vm.CREATE2, vm.SELFDESTRUCT:
+		t.transfer(from, to, value)
+	}
+}
+
+func (t *transferTracer) CaptureExit(output []byte, gasUsed uint64, err error) {
+	frame := t.frames[len(t.frames)-1]
+	t.frames = t.frames[:len(t.frames)-1]
+	if err == nil {
+		parent := len(t.frames) - 1
+		t.frames[parent] = append(t.frames[parent], frame...)
+	}
+}
+
+func (t *transferTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	if op < vm.LOG0 || op > vm.LOG4 || err != nil {
+		return
+	}
+	var (
+		stack  = scope.Stack.Data()
+		offset = stack[len(stack)-1]
+		size   = stack[len(stack)-2]
+		topics = make([]common.Hash, int(op-vm.LOG0))
+	)
+	for i := range topics {
+		topics[i] = stack[len(stack)-3-i].Bytes32()
+	}
+	t.emit(&types.Log{
+		Address: scope.Contract.Address(),
+		Topics:  topics,
+		Data:    logData(scope.Memory, offset.Uint64(), size.Uint64()),
+	})
+}
+
+// logData returns the data of a log operation about to be executed. The memory
+// is only expanded to cover the data after the operation is traced, the missing
+// part is zero-padded as the expansion would.
+func logData(mem *vm.Memory, offset, size uint64) []byte {
+	data := make([]byte, size)
+	if store := mem.Data(); offset < uint64(len(store)) {
+		copy(data, store[offset:])
+	}
+	return data
+}
+
+func (t *transferTracer) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
@@ -0,0 +1,114 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestSimulateTraceTransfers(t *testing.T) {
+	t.Parallel()
+
+	var (
+		accounts = newAccounts(1)
+		contract = common.Address{0xc0}
+		genesis  = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+				// MSTORE(0, 0xff), LOG0(16, 32): half of the logged data lies
+				// beyond the memory expanded so far
+				contract: {Code: []byte{
+					0x60, 0xff, 0x60, 0x00, 0x52, // MSTORE(0, 0xff)
+					0x60, 0x20, 0x60, 0x10, 0xa0, // LOG0(16, 32)
+					0x00, // STOP
+				}},
+			},
+		}
+	)
+	api := NewBlockChainAPI(newTestBackend(t, 1, genesis, ethash.NewFaker(), nil))
+
+	value := (*hexutil.Big)(big.NewInt(1000))
+	results, err := api.SimulateV1(context.Background(), SimulateOpts{
+		TraceTransfers: true,
+		BlockStateCalls: []SimulateBlock{{
+			Calls: []TransactionArgs{{From: &accounts[0].addr, To: &contract, Value: value}},
+		}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("simulation failed: %v", err)
+	}
+	logs := results[0]["calls"].([]map[string]interface{})[0]["logs"].([]*types.Log)
+	if len(logs) != 2 {
+		t.Fatalf("wrong number of logs: have %d, want 2", len(logs))
+	}
+	if logs[0].Address != transferAddress || logs[0].Topics[0] != transferTopic || new(big.Int).SetBytes(logs[0].Data).Cmp(value.ToInt()) != 0 {
+		t.Errorf("transfer log mismatch: %+v", logs[0])
+	}
+	want := make([]byte, 32)
+	want[15] = 0xff
+	if logs[1].Address != contract || !bytes.Equal(logs[1].Data, want) {
+		t.Errorf("contract log mismatch: have data %x, want %x", logs[1].Data, want)
+	}
+}
+
+func TestSimulateDefaultGas(t *testing.T) {
+	t.Parallel()
+
+	var (
+		accounts = newAccounts(2)
+		genesis  = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{accounts[0].addr: {Balance: big.NewInt(params.Ether)}},
+		}
+		backend = newTestBackend(t, 1, genesis, ethash.NewFaker(), nil)
+		api     = NewBlockChainAPI(backend)
+	)
+	for _, tt := range []struct {
+		gasLimit uint64
+		want     uint64
+	}{
+		{gasLimit: backend.RPCGasCap() / 2, want: backend.RPCGasCap() / 2}, // Block gas below the cap
+		{gasLimit: backend.RPCGasCap() * 2, want: backend.RPCGasCap()},     // Block gas above the cap
+	} {
+		gasLimit := hexutil.Uint64(tt.gasLimit)
+		results, err := api.SimulateV1(context.Background(), SimulateOpts{
+			ReturnFullTransactions: true,
+			BlockStateCalls: []SimulateBlock{{
+				BlockOverrides: &BlockOverrides{GasLimit: &gasLimit},
+				Calls:          []TransactionArgs{{From: &accounts[0].addr, To: &accounts[1].addr}},
+			}},
+		}, nil)
+		if err != nil {
+			t.Fatalf("block gas limit %d: simulation failed: %v", tt.gasLimit, err)
+		}
+		tx := results[0]["transactions"].([]interface{})[0].(*RPCTransaction)
+		if uint64(tx.Gas) != tt.want {
+			t.Errorf("block gas limit %d: default gas mismatch: have %d, want %d", tt.gasLimit, tx.Gas, tt.want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+)
+
+const (
+	defaultTxHistoryPageSize = 100  // Transactions returned per page if no limit is requested
+	maxTxHistoryPageSize     = 1000 // Maximum number of transactions returned per page
+)
+
+// TxHistoryCursor is the position of a transaction in the chain, from which
+// paginated transaction history queries resume. It is encoded as an opaque hex
+// string in JSON.
+type TxHistoryCursor struct {
+	Block uint64 // Number of the block of the transaction
+	Index uint   // Index of the transaction in the block
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (c TxHistoryCursor) MarshalText() ([]byte, error) {
+	var enc [12]byte
+	binary.BigEndian.PutUint64(enc[:8], c.Block)
+	binary.BigEndian.PutUint32(enc[8:], uint32(c.Index))
+	return hexutil.Bytes(enc[:]).MarshalText()
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (c *TxHistoryCursor) UnmarshalText(input []byte) error {
+	var dec hexutil.Bytes
+	if err := dec.UnmarshalText(input); err != nil {
+		return err
+	}
+	if len(dec) != 12 {
+		return errors.New("invalid transaction history cursor")
+	}
+	c.Block = binary.BigEndian.Uint64(dec[:8])
+	c.Index = uint(binary.BigEndian.Uint32(dec[8:]))
+	return nil
+}
+
+// String returns the encoded cursor.
+func (c TxHistoryCursor) String() string {
+	enc, _ := c.MarshalText()
+	return string(enc)
+}
+
+// AddressTransactions retrieves a page of the canonical transactions involving
+// an address, newest first, starting from the position of the cursor returned
+// by the previous page, or from the chain head if nil. If more transactions
+// remain, the cursor to resume from is returned along the page.
+func AddressTransactions(ctx context.Context, b Backend, address common.Address, cursor *TxHistoryCursor, limit int) ([]*rawdb.AddressTransaction, *TxHistoryCursor, error) {
+	if limit <= 0 {
+		limit = defaultTxHistoryPageSize
+	}
+	if limit > maxTxHistoryPageSize {
+		limit = maxTxHistoryPageSize
+	}
+	number, index := uint64(math.MaxUint64), uint32(math.MaxUint32)
+	if cursor != nil {
+		number, index = cursor.Block, uint32(cursor.Index)
+	}
+	txs, err := b.TransactionsByAddress(ctx, address, number, index, limit+1)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(txs) > limit {
+		return txs[:limit], &TxHistoryCursor{Block: txs[limit].BlockNumber, Index: uint(txs[limit].Index)}, nil
+	}
+	return txs, nil, nil
+}
+
+// TransactionsPage is a page of the results of a transaction history query.
+type TransactionsPage struct {
+	Transactions []*RPCTransaction `json:"transactions"`
+	Cursor       *TxHistoryCursor  `json:"cursor"` // Position to resume the query from, nil if complete
+}
+
+// GetTransactionsByAddress returns a page of the canonical transactions sent by,
+// sent to, creating or, if indexed, internally calling the given address, newest
+// first. The page starts from the position of the cursor returned by the previous
+// page, or from the chain head if nil, and holds at most limit transactions.
+//
+// The transactions of blocks whose bodies have been pruned are omitted.
+func (s *TransactionAPI) GetTransactionsByAddress(ctx context.Context, address common.Address, cursor *TxHistoryCursor, limit *hexutil.Uint) (*TransactionsPage, error) {
+	var n int
+	if limit != nil {
+		n = int(*limit)
+	}
+	txs, next, err := AddressTransactions(ctx, s.b, address, cursor, n)
+	if err != nil {
+		return nil, err
+	}
+	page := &TransactionsPage{Transactions: make([]*RPCTransaction, 0, len(txs)), Cursor: next}
+	for _, tx := range txs {
+		block, err := s.b.BlockByHash(ctx, tx.BlockHash)
+		if err != nil {
+			return nil, err
+		}
+		if block == nil {
+			continue
+		}
+		if rpcTx := newRPCTransactionFromBlockIndex(block, uint64(tx.Index), s.b.ChainConfig()); rpcTx != nil {
+			page.Transactions = append(page.Transactions, rpcTx)
+		}
+	}
+	return page, nil
+}
@@ -34,6 +34,7 @@ const (
 	VMCategory         = "VIRTUAL MACHINE"
 	LoggingCategory    = "LOGGING AND DEBUGGING"
 	MetricsCategory    = "METRICS AND STATS"
+	ExporterCategory   = "CHAIN EXPORTER"
 	MiscCategory       = "MISC"
 	DeprecatedCategory = "ALIASED (deprecated)"
 )
@@ -0,0 +1,290 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+const (
+	// DefaultOTLPEndpoint is the traces endpoint of an OpenTelemetry collector
+	// running on the local machine.
+	DefaultOTLPEndpoint = "http://localhost:4318/v1/traces"
+
+	otlpQueueSize     = 8192            // spans buffered before new ones are dropped
+	otlpBatchSize     = 512             // maximum number of spans per export request
+	otlpFlushInterval = 5 * time.Second // maximum delay before a span is exported
+	otlpTimeout       = 10 * time.Second
+	otlpScopeName     = "github.com/ethereum/go-ethereum"
+)
+
+var (
+	exportedSpanMeter = metrics.NewRegisteredMeter("tracing/spans/exported", nil)
+	droppedSpanMeter  = metrics.NewRegisteredMeter("tracing/spans/dropped", nil)
+)
+
+// OTLPExporter sends spans in batches to an OpenTelemetry collector, using the
+// JSON encoding of the OTLP/HTTP protocol. Spans are dropped if the collector
+// can't keep up or is unreachable.
+type OTLPExporter struct {
+	endpoint string
+	resource []Attribute
+	client   *http.Client
+	queue    chan *SpanData
+	closeCh  chan struct{}
+	wg       sync.WaitGroup
+	failing  bool // whether the last export failed, only accessed by loop
+}
+
+// NewOTLPExporter creates an exporter posting to the given OTLP/HTTP traces
+// endpoint. The resource attributes describe the process emitting the spans,
+// e.g. its service.name.
+func NewOTLPExporter(endpoint string, resource ...Attribute) (*OTLPExporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q: want http(s)://host[:port]/path", endpoint)
+	}
+	e := &OTLPExporter{
+		endpoint: endpoint,
+		resource: resource,
+		client:   &http.Client{Timeout: otlpTimeout},
+		queue:    make(chan *SpanData, otlpQueueSize),
+		closeCh:  make(chan struct{}),
+	}
+	e.wg.Add(1)
+	go e.loop()
+	return e, nil
+}
+
+// ExportSpan implements Exporter.
+func (e *OTLPExporter) ExportSpan(span *SpanData) {
+	select {
+	case e.queue <- span:
+	default:
+		droppedSpanMeter.Mark(1)
+	}
+}
+
+// Close exports the queued spans and stops the exporter.
+func (e *OTLPExporter) Close() {
+	close(e.closeCh)
+	e.wg.Wait()
+	e.client.CloseIdleConnections()
+}
+
+func (e *OTLPExporter) loop() {
+	defer e.wg.Done()
+
+	ticker := time.NewTicker(otlpFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*SpanData, 0, otlpBatchSize)
+	for {
+		select {
+		case span := <-e.queue:
+			if batch = append(batch, span); len(batch) >= otlpBatchSize {
+				e.export(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				e.export(batch)
+				batch = batch[:0]
+			}
+		case <-e.closeCh:
+			for {
+				select {
+				case span := <-e.queue:
+					if batch = append(batch, span); len(batch) >= otlpBatchSize {
+						e.export(batch)
+						batch = batch[:0]
+					}
+				default:
+					if len(batch) > 0 {
+						e.export(batch)
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+// export sends a batch of spans, reporting when the collector becomes
+// unreachable and when it recovers.
+func (e *OTLPExporter) export(batch []*SpanData) {
+	err := e.post(batch)
+	switch {
+	case err != nil:
+		droppedSpanMeter.Mark(int64(len(batch)))
+		if !e.failing {
+			log.Warn("Failed to export RPC traces", "endpoint", e.endpoint, "err", err)
+		}
+	case e.failing:
+		log.Info("Resumed exporting RPC traces", "endpoint", e.endpoint)
+		fallthrough
+	default:
+		exportedSpanMeter.Mark(int64(len(batch)))
+	}
+	e.failing = err != nil
+}
+
+func (e *OTLPExporter) post(batch []*SpanData) error {
+	body, err := json.Marshal(newOTLPRequest(e.resource, batch))
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), otlpTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("collector responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// The types below mirror the JSON encoding of the OTLP ExportTraceServiceRequest.
+// 64 bit integers are encoded as strings and IDs as hex, as the protocol requires.
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            *otlpStatus    `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// otlpStatusError is the status code of failed spans.
+const otlpStatusError = 2
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func newOTLPRequest(resource []Attribute, batch []*SpanData) *otlpRequest {
+	spans := make([]otlpSpan, len(batch))
+	for i, span := range batch {
+		spans[i] = otlpSpan{
+			TraceID:           span.TraceID.String(),
+			SpanID:            span.SpanID.String(),
+			Name:              span.Name,
+			Kind:              span.Kind,
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Attributes:        newOTLPAttributes(span.Attributes),
+		}
+		if span.ParentID.IsValid() {
+			spans[i].ParentSpanID = span.ParentID.String()
+		}
+		if span.Error != "" {
+			spans[i].Status = &otlpStatus{Code: otlpStatusError, Message: span.Error}
+		}
+	}
+	return &otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource:   otlpResource{Attributes: newOTLPAttributes(resource)},
+			ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: otlpScopeName}, Spans: spans}},
+		}},
+	}
+}
+
+func newOTLPAttributes(attrs []Attribute) []otlpKeyValue {
+	kvs := make([]otlpKeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		kv := otlpKeyValue{Key: attr.Key}
+		switch v := attr.Value.(type) {
+		case string:
+			kv.Value.StringValue = &v
+		case int64:
+			s := strconv.FormatInt(v, 10)
+			kv.Value.IntValue = &s
+		case bool:
+			kv.Value.BoolValue = &v
+		case float64:
+			kv.Value.DoubleValue = &v
+		default:
+			s := fmt.Sprint(v)
+			kv.Value.StringValue = &s
+		}
+		kvs = append(kvs, kv)
+	}
+	return kvs
+}
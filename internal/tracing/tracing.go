@@ -0,0 +1,242 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package tracing records OpenTelemetry-compatible spans of the work done on
+// behalf of RPC requests.
+//
+// Spans are only recorded while an exporter is installed with SetExporter.
+// Otherwise StartSpan returns a nil span and all span methods are no-ops, so
+// instrumented code does not need to check whether tracing is enabled.
+package tracing
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// SpanKind is the role of a span in a trace, using the OpenTelemetry values.
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+// String returns the hex encoding of the ID.
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+// IsValid reports whether the ID is non-zero.
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+// String returns the hex encoding of the ID.
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+// IsValid reports whether the ID is non-zero.
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+// Attribute is a key-value pair describing a span. The value is a string, an
+// int64, a bool or a float64.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// String creates a string attribute.
+func String(key, value string) Attribute { return Attribute{key, value} }
+
+// Int creates an integer attribute.
+func Int(key string, value int) Attribute { return Attribute{key, int64(value)} }
+
+// Int64 creates an integer attribute.
+func Int64(key string, value int64) Attribute { return Attribute{key, value} }
+
+// Uint64 creates an integer attribute. Values above the int64 range are clamped.
+func Uint64(key string, value uint64) Attribute {
+	if value > 1<<63-1 {
+		value = 1<<63 - 1
+	}
+	return Attribute{key, int64(value)}
+}
+
+// Bool creates a boolean attribute.
+func Bool(key string, value bool) Attribute { return Attribute{key, value} }
+
+// Float64 creates a floating point attribute.
+func Float64(key string, value float64) Attribute { return Attribute{key, value} }
+
+// SpanData is the record of a finished span handed to the exporter.
+type SpanData struct {
+	Name       string
+	Kind       SpanKind
+	TraceID    TraceID
+	SpanID     SpanID
+	ParentID   SpanID // zero for root spans
+	Start      time.Time
+	End        time.Time
+	Attributes []Attribute
+	Error      string // set if the operation failed
+}
+
+// Exporter receives finished spans. ExportSpan is called on the goroutine
+// ending the span, so it must not block.
+type Exporter interface {
+	ExportSpan(span *SpanData)
+}
+
+type exporterBox struct{ Exporter }
+
+var exporter atomic.Value // exporterBox
+
+// SetExporter installs the exporter of all spans recorded afterwards. Tracing
+// is disabled if e is nil.
+func SetExporter(e Exporter) {
+	exporter.Store(exporterBox{e})
+}
+
+// Enabled reports whether spans are being recorded.
+func Enabled() bool {
+	return currentExporter() != nil
+}
+
+func currentExporter() Exporter {
+	box, _ := exporter.Load().(exporterBox)
+	return box.Exporter
+}
+
+// Span is an operation being traced. Spans are not safe for concurrent use, but
+// child spans may be started on other goroutines.
+type Span struct {
+	data     SpanData
+	exporter Exporter
+	ended    bool
+}
+
+type spanContextKey struct{}
+
+// spanContext is the part of a span inherited by its children.
+type spanContext struct {
+	traceID TraceID
+	spanID  SpanID
+}
+
+// StartSpan starts a span as a child of the span in ctx, if any. The returned
+// context carries the new span. If tracing is disabled, ctx is returned
+// unchanged along with a nil span.
+func StartSpan(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	e := currentExporter()
+	if e == nil {
+		return ctx, nil
+	}
+	span := &Span{
+		data: SpanData{
+			Name:       name,
+			Kind:       SpanKindInternal,
+			Start:      time.Now(),
+			Attributes: attrs,
+		},
+		exporter: e,
+	}
+	var ids [24]byte
+	crand.Read(ids[:])
+	if parent, ok := ctx.Value(spanContextKey{}).(spanContext); ok {
+		span.data.TraceID = parent.traceID
+		span.data.ParentID = parent.spanID
+	} else {
+		copy(span.data.TraceID[:], ids[8:])
+	}
+	copy(span.data.SpanID[:], ids[:8])
+	return context.WithValue(ctx, spanContextKey{}, spanContext{span.data.TraceID, span.data.SpanID}), span
+}
+
+// SetKind sets the role of the span in the trace. Spans are internal by default.
+func (s *Span) SetKind(kind SpanKind) {
+	if s != nil {
+		s.data.Kind = kind
+	}
+}
+
+// SetAttributes adds attributes to the span.
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s != nil {
+		s.data.Attributes = append(s.data.Attributes, attrs...)
+	}
+}
+
+// SetError marks the span as failed if err is non-nil.
+func (s *Span) SetError(err error) {
+	if s != nil && err != nil {
+		s.data.Error = err.Error()
+	}
+}
+
+// TraceID returns the ID of the trace the span belongs to, or the zero ID if
+// the span is nil.
+func (s *Span) TraceID() TraceID {
+	if s == nil {
+		return TraceID{}
+	}
+	return s.data.TraceID
+}
+
+// End finishes the span and hands it to the exporter. Calls after the first
+// have no effect.
+func (s *Span) End() {
+	if s == nil || s.ended {
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	s.exporter.ExportSpan(&s.data)
+}
+
+var errInvalidTraceParent = errors.New("invalid traceparent")
+
+// ContextWithTraceParent makes spans started from the returned context children
+// of the remote span described by the given W3C traceparent header value.
+func ContextWithTraceParent(ctx context.Context, traceparent string) (context.Context, error) {
+	// The header is version-traceid-parentid-flags, with future versions
+	// possibly appending more fields.
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return ctx, errInvalidTraceParent
+	}
+	var parent spanContext
+	if len(parts[1]) != 2*len(parent.traceID) || len(parts[2]) != 2*len(parent.spanID) {
+		return ctx, errInvalidTraceParent
+	}
+	if _, err := hex.Decode(parent.traceID[:], []byte(parts[1])); err != nil {
+		return ctx, errInvalidTraceParent
+	}
+	if _, err := hex.Decode(parent.spanID[:], []byte(parts[2])); err != nil {
+		return ctx, errInvalidTraceParent
+	}
+	if !parent.traceID.IsValid() || !parent.spanID.IsValid() {
+		return ctx, errInvalidTraceParent
+	}
+	return context.WithValue(ctx, spanContextKey{}, parent), nil
+}
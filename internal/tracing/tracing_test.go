@@ -0,0 +1,160 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []*SpanData
+}
+
+func (r *spanRecorder) ExportSpan(span *SpanData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, span)
+}
+
+func TestSpans(t *testing.T) {
+	ctx := context.Background()
+	if _, span := StartSpan(ctx, "disabled"); span != nil {
+		t.Fatal("span recorded while tracing is disabled")
+	}
+	rec := new(spanRecorder)
+	SetExporter(rec)
+	defer SetExporter(nil)
+
+	ctx, root := StartSpan(ctx, "root", String("a", "b"))
+	root.SetKind(SpanKindServer)
+	_, child := StartSpan(ctx, "child")
+	child.SetError(errors.New("failed"))
+	child.End()
+	root.End()
+	root.End()
+
+	if len(rec.spans) != 2 {
+		t.Fatalf("wrong number of spans exported: %d", len(rec.spans))
+	}
+	have, want := rec.spans[0], rec.spans[1]
+	if have.Name != "child" || have.Kind != SpanKindInternal || have.Error != "failed" {
+		t.Errorf("child span mismatch: %+v", have)
+	}
+	if have.TraceID != want.TraceID || have.ParentID != want.SpanID || have.SpanID == want.SpanID {
+		t.Errorf("child span not linked to parent")
+	}
+	if want.ParentID.IsValid() || want.Kind != SpanKindServer || len(want.Attributes) != 1 {
+		t.Errorf("root span mismatch: %+v", want)
+	}
+}
+
+func TestTraceParent(t *testing.T) {
+	rec := new(spanRecorder)
+	SetExporter(rec)
+	defer SetExporter(nil)
+
+	ctx, err := ContextWithTraceParent(context.Background(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, span := StartSpan(ctx, "span")
+	span.End()
+	if have := rec.spans[0].TraceID.String(); have != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace ID mismatch: %s", have)
+	}
+	if have := rec.spans[0].ParentID.String(); have != "00f067aa0ba902b7" {
+		t.Errorf("parent ID mismatch: %s", have)
+	}
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01",
+	} {
+		if _, err := ContextWithTraceParent(context.Background(), invalid); err == nil {
+			t.Errorf("invalid traceparent %q accepted", invalid)
+		}
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []*otlpRequest
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req otlpRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("invalid export request: %v", err)
+		}
+		mu.Lock()
+		requests = append(requests, &req)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	if _, err := NewOTLPExporter("localhost:4318"); err == nil {
+		t.Fatal("endpoint without scheme accepted")
+	}
+	exp, err := NewOTLPExporter(srv.URL, String("service.name", "geth"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetExporter(exp)
+	ctx, root := StartSpan(context.Background(), "root", Int("size", 10), Bool("ok", true))
+	_, child := StartSpan(ctx, "child", Float64("ratio", 0.5))
+	child.SetError(errors.New("failed"))
+	child.End()
+	root.End()
+	SetExporter(nil)
+	exp.Close()
+
+	if len(requests) != 1 {
+		t.Fatalf("wrong number of export requests: %d", len(requests))
+	}
+	enc, _ := json.Marshal(requests[0].ResourceSpans[0].Resource)
+	if want := `{"attributes":[{"key":"service.name","value":{"stringValue":"geth"}}]}`; string(enc) != want {
+		t.Errorf("resource mismatch:\nhave %s\nwant %s", enc, want)
+	}
+	spans := requests[0].ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("wrong number of spans: %d", len(spans))
+	}
+	if spans[0].ParentSpanID != spans[1].SpanID || spans[0].TraceID != root.TraceID().String() {
+		t.Errorf("child span not linked to parent: %+v", spans[0])
+	}
+	if spans[0].Status == nil || spans[0].Status.Code != otlpStatusError || spans[0].Status.Message != "failed" {
+		t.Errorf("child status mismatch: %+v", spans[0].Status)
+	}
+	enc, _ = json.Marshal(spans[1].Attributes)
+	if want := `[{"key":"size","value":{"intValue":"10"}},{"key":"ok","value":{"boolValue":true}}]`; string(enc) != want {
+		t.Errorf("attributes mismatch:\nhave %s\nwant %s", enc, want)
+	}
+	if spans[1].ParentSpanID != "" || spans[1].Status != nil || spans[1].StartTimeUnixNano == "" {
+		t.Errorf("root span mismatch: %+v", spans[1])
+	}
+}
@@ -31,6 +31,7 @@ var Modules = map[string]string{
 	"les":      LESJs,
 	"vflux":    VfluxJs,
 	"dev":      DevJs,
+	"polarys":  PolarysJs,
 }
 
 const CliqueJs = `
@@ -153,6 +154,19 @@ web3._extend({
 			call: 'admin_importChain',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'txPoolLists',
+			call: 'admin_txPoolLists'
+		}),
+		new web3._extend.Method({
+			name: 'reloadTxPoolLists',
+			call: 'admin_reloadTxPoolLists'
+		}),
+		new web3._extend.Method({
+			name: 'setTxPoolLists',
+			call: 'admin_setTxPoolLists',
+			params: 1
+		}),
 		new web3._extend.Method({
 			name: 'sleepBlocks',
 			call: 'admin_sleepBlocks',
@@ -501,6 +515,23 @@ web3._extend({
 			call: 'debug_getTrieFlushInterval',
 			params: 0
 		}),
+		new web3._extend.Method({
+			name: 'pruneState',
+			call: 'debug_pruneState',
+			params: 1,
+			inputFormatter: [null],
+		}),
+		new web3._extend.Method({
+			name: 'pruneStateStatus',
+			call: 'debug_pruneStateStatus',
+			params: 0,
+		}),
+		new web3._extend.Method({
+			name: 'getStateDiff',
+			call: 'debug_getStateDiff',
+			params: 1,
+			inputFormatter: [null],
+		}),
 	],
 	properties: []
 });
@@ -611,6 +642,18 @@ web3._extend({
 			call: 'eth_getLogs',
 			params: 1,
 		}),
+		new web3._extend.Method({
+			name: 'getLogsPage',
+			call: 'eth_getLogsPage',
+			params: 3,
+			inputFormatter: [null, null, null],
+		}),
+		new web3._extend.Method({
+			name: 'getTransactionsByAddress',
+			call: 'eth_getTransactionsByAddress',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, null],
+		}),
 		new web3._extend.Method({
 			name: 'call',
 			call: 'eth_call',
@@ -622,6 +665,12 @@ web3._extend({
 			call: 'eth_getBlockReceipts',
 			params: 1,
 		}),
+		new web3._extend.Method({
+			name: 'simulateV1',
+			call: 'eth_simulateV1',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputDefaultBlockNumberFormatter],
+		}),
 	],
 	properties: [
 		new web3._extend.Property({
@@ -689,6 +738,12 @@ web3._extend({
 			name: 'getHashrate',
 			call: 'miner_getHashrate'
 		}),
+		new web3._extend.Method({
+			name: 'simulateBlock',
+			call: 'miner_simulateBlock',
+			params: 1,
+			inputFormatter: [null]
+		}),
 	],
 	properties: []
 });
@@ -911,3 +966,24 @@ web3._extend({
 	],
 });
 `
+
+const PolarysJs = `
+web3._extend({
+	property: 'polarys',
+	methods:
+	[
+		new web3._extend.Method({
+			name: 'getTokenBalances',
+			call: 'polarys_getTokenBalances',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getTokenTransfers',
+			call: 'polarys_getTokenTransfers',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, null]
+		}),
+	],
+});
+`
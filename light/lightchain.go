@@ -540,6 +540,11 @@ func (lc *LightChain) SubscribeChainSideEvent(ch chan<- core.ChainSideEvent) eve
 	return lc.scope.Track(lc.chainSideFeed.Subscribe(ch))
 }
 
+// SubscribeFinalizedHeaderEvent registers a subscription of FinalizedHeaderEvent.
+func (lc *LightChain) SubscribeFinalizedHeaderEvent(ch chan<- core.FinalizedHeaderEvent) event.Subscription {
+	return lc.scope.Track(lc.finalizedHeaderFeed.Subscribe(ch))
+}
+
 // SubscribeLogsEvent implements the interface of filters.Backend
 // LightChain does not send logs events, so return an empty subscription.
 func (lc *LightChain) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription {
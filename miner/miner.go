@@ -241,6 +241,12 @@ func (miner *Miner) SubscribePendingLogs(ch chan<- []*types.Log) event.Subscript
 	return miner.worker.pendingLogsFeed.Subscribe(ch)
 }
 
+// SimulateBlock builds the block the miner would produce on top of the current
+// head with the provided parameters, without sealing or broadcasting it.
+func (miner *Miner) SimulateBlock(args *SimulateArgs) (*SimulatedBlock, error) {
+	return miner.worker.simulateBlock(args)
+}
+
 // BuildPayload builds the payload according to the provided parameters.
 func (miner *Miner) BuildPayload(args *BuildPayloadArgs) (*Payload, error) {
 	return miner.worker.buildPayload(args)
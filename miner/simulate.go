@@ -0,0 +1,155 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/txpool"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// SimulateArgs contains the parameters for simulating the production of a block
+// on top of the current chain head.
+type SimulateArgs struct {
+	Transactions []*types.Transaction // Transactions to include, nil to pull them from the pool
+	Timestamp    *uint64              // Timestamp override, the current time if nil
+	Coinbase     *common.Address      // Coinbase override, the miner's etherbase if nil
+}
+
+// SkippedTransaction is a transaction left out of a simulated block.
+type SkippedTransaction struct {
+	Hash   common.Hash `json:"hash"`
+	Reason string      `json:"reason"`
+}
+
+// SimulatedFees is the breakdown of the fees paid by the transactions of a
+// simulated block.
+type SimulatedFees struct {
+	BaseFee   *hexutil.Big `json:"baseFee"`   // Base fee of the block, nil before London
+	Burnt     *hexutil.Big `json:"burnt"`     // Base fee portion of the fees paid
+	Priority  *hexutil.Big `json:"priority"`  // Tip portion of the fees paid
+	Collected *hexutil.Big `json:"collected"` // Fees collected for distribution by the consensus engine
+}
+
+// SimulatedBlock is the outcome of a block production simulation. The block is
+// assembled by the consensus engine, including its system transactions, but is
+// neither sealed nor broadcast.
+type SimulatedBlock struct {
+	Header       *types.Header         `json:"header"`
+	Transactions []common.Hash         `json:"transactions"`
+	Receipts     []*types.Receipt      `json:"receipts"`
+	GasUsed      hexutil.Uint64        `json:"gasUsed"`
+	Fees         *SimulatedFees        `json:"fees"`
+	Skipped      []*SkippedTransaction `json:"skipped"`
+}
+
+// simulateBlock builds the block the worker would produce on top of the current
+// head, without sealing or announcing it.
+func (w *worker) simulateBlock(args *SimulateArgs) (*SimulatedBlock, error) {
+	params := &generateParams{
+		timestamp: uint64(time.Now().Unix()),
+		coinbase:  w.etherbase(),
+		simulate:  true,
+		txs:       args.Transactions,
+	}
+	if args.Timestamp != nil {
+		params.timestamp, params.forceTime = *args.Timestamp, true
+	}
+	if args.Coinbase != nil {
+		params.coinbase = *args.Coinbase
+	}
+	res := w.getSealingBlock(params)
+	if res.err != nil {
+		return nil, res.err
+	}
+	var (
+		block  = res.block
+		result = &SimulatedBlock{
+			Header:       block.Header(),
+			Transactions: make([]common.Hash, 0, len(block.Transactions())),
+			Receipts:     res.receipts,
+			GasUsed:      hexutil.Uint64(block.GasUsed()),
+			Fees: &SimulatedFees{
+				Priority:  (*hexutil.Big)(totalFees(block, res.receipts)),
+				Collected: (*hexutil.Big)(res.fees),
+			},
+			Skipped: res.skipped,
+		}
+	)
+	for _, tx := range block.Transactions() {
+		result.Transactions = append(result.Transactions, tx.Hash())
+	}
+	if baseFee := block.BaseFee(); baseFee != nil {
+		result.Fees.BaseFee = (*hexutil.Big)(baseFee)
+		result.Fees.Burnt = (*hexutil.Big)(new(big.Int).Mul(baseFee, new(big.Int).SetUint64(block.GasUsed())))
+	}
+	if result.Skipped == nil {
+		result.Skipped = []*SkippedTransaction{}
+	}
+	return result, nil
+}
+
+// commitSuppliedTransactions fills the block with a user supplied transaction
+// list instead of the pool's content, ordering them the same way pooled ones
+// would be. Transactions that didn't make it into the block are recorded as
+// skipped.
+func (w *worker) commitSuppliedTransactions(env *environment, txs []*types.Transaction) error {
+	pending := make(map[common.Address][]*txpool.LazyTransaction)
+	for _, tx := range txs {
+		from, err := types.Sender(env.signer, tx)
+		if err != nil {
+			env.skip(tx.Hash(), err.Error())
+			continue
+		}
+		pending[from] = append(pending[from], &txpool.LazyTransaction{
+			Hash:      tx.Hash(),
+			Tx:        tx,
+			Time:      tx.Time(),
+			GasFeeCap: tx.GasFeeCap(),
+			GasTipCap: tx.GasTipCap(),
+			Gas:       tx.Gas(),
+			BlobGas:   tx.BlobGas(),
+		})
+	}
+	for _, list := range pending {
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].Tx.Nonce() < list[j].Tx.Nonce()
+		})
+	}
+	err := w.commitTransactions(env, newTransactionsByPriceAndNonce(env.signer, pending, env.header.BaseFee), nil)
+
+	// Transactions dropped together with a failing one from the same sender, or
+	// never reached, aren't recorded by the block filling; do it here instead
+	seen := make(map[common.Hash]struct{})
+	for _, tx := range env.txs {
+		seen[tx.Hash()] = struct{}{}
+	}
+	for _, skipped := range env.skipped {
+		seen[skipped.Hash] = struct{}{}
+	}
+	for _, tx := range txs {
+		if _, ok := seen[tx.Hash()]; !ok {
+			env.skip(tx.Hash(), "not included")
+		}
+	}
+	return err
+}
@@ -96,6 +96,9 @@ type environment struct {
 	receipts []*types.Receipt
 	sidecars []*types.BlobTxSidecar
 	blobs    int
+
+	simulated bool                  // Flag whether the block is built for simulation only
+	skipped   []*SkippedTransaction // Transactions left out of a simulated block
 }
 
 // copy creates a deep copy of environment.
@@ -107,6 +110,8 @@ func (env *environment) copy() *environment {
 		coinbase: env.coinbase,
 		header:   types.CopyHeader(env.header),
 		receipts: copyReceipts(env.receipts),
+
+		simulated: env.simulated,
 	}
 	if env.gasPool != nil {
 		gasPool := *env.gasPool
@@ -118,9 +123,20 @@ func (env *environment) copy() *environment {
 	cpy.sidecars = make([]*types.BlobTxSidecar, len(env.sidecars))
 	copy(cpy.sidecars, env.sidecars)
 
+	cpy.skipped = make([]*SkippedTransaction, len(env.skipped))
+	copy(cpy.skipped, env.skipped)
+
 	return cpy
 }
 
+// skip records a transaction left out of the block along with the reason, if
+// the block is built for simulation.
+func (env *environment) skip(hash common.Hash, reason string) {
+	if env.simulated {
+		env.skipped = append(env.skipped, &SkippedTransaction{Hash: hash, Reason: reason})
+	}
+}
+
 // discard terminates the background prefetcher go-routine. It should
 // always be called for all created environment instances otherwise
 // the go-routine leak can happen.
@@ -159,6 +175,8 @@ type newPayloadResult struct {
 	block    *types.Block
 	fees     *big.Int               // total block fees
 	sidecars []*types.BlobTxSidecar // collected blobs of blob transactions
+	receipts []*types.Receipt       // receipts of the block, including system transactions
+	skipped  []*SkippedTransaction  // transactions left out of a simulated block
 }
 
 // getWorkReq represents a request for getting a new sealing work with provided parameters.
@@ -827,11 +845,13 @@ func (w *worker) commitTransactions(env *environment, txs *transactionsByPriceAn
 		// If we don't have enough space for the next transaction, skip the account.
 		if env.gasPool.Gas() < ltx.Gas {
 			log.Trace("Not enough gas left for transaction", "hash", ltx.Hash, "left", env.gasPool.Gas(), "needed", ltx.Gas)
+			env.skip(ltx.Hash, "not enough gas left in block")
 			txs.Pop()
 			continue
 		}
 		if left := uint64(params.MaxBlobGasPerBlock - env.blobs*params.BlobTxBlobGasPerBlob); left < ltx.BlobGas {
 			log.Trace("Not enough blob gas left for transaction", "hash", ltx.Hash, "left", left, "needed", ltx.BlobGas)
+			env.skip(ltx.Hash, "not enough blob gas left in block")
 			txs.Pop()
 			continue
 		}
@@ -839,6 +859,7 @@ func (w *worker) commitTransactions(env *environment, txs *transactionsByPriceAn
 		tx := ltx.Resolve()
 		if tx == nil {
 			log.Trace("Ignoring evicted transaction", "hash", ltx.Hash)
+			env.skip(ltx.Hash, "evicted from pool")
 			txs.Pop()
 			continue
 		}
@@ -850,6 +871,7 @@ func (w *worker) commitTransactions(env *environment, txs *transactionsByPriceAn
 		// phase, start ignoring the sender until we do.
 		if tx.Protected() && !w.chainConfig.IsEIP155(env.header.Number) {
 			log.Trace("Ignoring replay protected transaction", "hash", ltx.Hash, "eip155", w.chainConfig.EIP155Block)
+			env.skip(ltx.Hash, "replay protected before EIP-155")
 			txs.Pop()
 			continue
 		}
@@ -861,6 +883,7 @@ func (w *worker) commitTransactions(env *environment, txs *transactionsByPriceAn
 		case errors.Is(err, core.ErrNonceTooLow):
 			// New head notification data race between the transaction pool and miner, shift
 			log.Trace("Skipping transaction with low nonce", "hash", ltx.Hash, "sender", from, "nonce", tx.Nonce())
+			env.skip(ltx.Hash, err.Error())
 			txs.Shift()
 
 		case errors.Is(err, nil):
@@ -873,10 +896,11 @@ func (w *worker) commitTransactions(env *environment, txs *transactionsByPriceAn
 			// Transaction is regarded as invalid, drop all consecutive transactions from
 			// the same sender because of `nonce-too-high` clause.
 			log.Debug("Transaction failed, account skipped", "hash", ltx.Hash, "err", err)
+			env.skip(ltx.Hash, err.Error())
 			txs.Pop()
 		}
 	}
-	if !w.isRunning() && len(coalescedLogs) > 0 {
+	if !w.isRunning() && !env.simulated && len(coalescedLogs) > 0 {
 		// We don't push the pendingLogsEvent while we are sealing. The reason is that
 		// when we are sealing, the worker will regenerate a sealing block every 3 seconds.
 		// In order to avoid pushing the repeated pendingLog, we disable the pending log pushing.
@@ -905,6 +929,9 @@ type generateParams struct {
 	beaconRoot  *common.Hash      // The beacon root (cancun field).
 	prevWork    *environment
 	noTxs       bool // Flag whether an empty block without any transaction is expected
+
+	simulate bool                 // Flag whether the block is built for simulation only
+	txs      []*types.Transaction // Transactions to include instead of the pool's, simulation only
 }
 
 // prepareWork constructs the sealing task according to the given parameters,
@@ -932,11 +959,19 @@ func (w *worker) prepareWork(genParams *generateParams) (*environment, error) {
 		}
 		timestamp = parent.Time + 1
 	}
+	// Converge on the gas limit set by chain governance if the consensus engine
+	// supports it, falling back to the locally configured ceiling otherwise.
+	gasCeil := w.config.GasCeil
+	if targeter, ok := w.engine.(consensus.GasLimitTargeter); ok {
+		if target, ok := targeter.GasLimitTarget(w.chain, parent); ok {
+			gasCeil = target
+		}
+	}
 	// Construct the sealing block header.
 	header := &types.Header{
 		ParentHash: parent.Hash(),
 		Number:     new(big.Int).Add(parent.Number, common.Big1),
-		GasLimit:   core.CalcGasLimit(parent.GasLimit, w.config.GasCeil),
+		GasLimit:   core.CalcGasLimit(parent.GasLimit, gasCeil),
 		Time:       timestamp,
 		Coinbase:   genParams.coinbase,
 	}
@@ -961,6 +996,11 @@ func (w *worker) prepareWork(genParams *generateParams) (*environment, error) {
 		log.Error("Failed to prepare header for sealing", "err", err)
 		return nil, err
 	}
+	// Simulated blocks are never sealed, so they may credit a coinbase the
+	// engine wouldn't pick itself.
+	if genParams.simulate {
+		header.Coinbase = genParams.coinbase
+	}
 	// Could potentially happen if starting to mine in an odd state.
 	// Note genParams.coinbase can be different with header.Coinbase
 	// since clique algorithm can modify the coinbase field in header.
@@ -969,6 +1009,7 @@ func (w *worker) prepareWork(genParams *generateParams) (*environment, error) {
 		log.Error("Failed to create sealing context", "err", err)
 		return nil, err
 	}
+	env.simulated = genParams.simulate
 
 	// Handle upgrade build-in system contract code
 	systemcontracts.UpgradeBuildInSystemContract(w.chainConfig, header.Number, env.state)
@@ -1023,13 +1064,22 @@ func (w *worker) generateWork(params *generateParams) *newPayloadResult {
 	defer work.discard()
 
 	if !params.noTxs {
-		err := w.fillTransactions(nil, work)
+		var err error
+		if params.txs != nil {
+			err = w.commitSuppliedTransactions(work, params.txs)
+		} else {
+			err = w.fillTransactions(nil, work)
+		}
 		if errors.Is(err, errBlockInterruptedByTimeout) {
 			log.Warn("Block building is interrupted", "allowance", common.PrettyDuration(w.newpayloadTimeout))
 		}
 	}
 	fees := work.state.GetBalance(consensus.SystemAddress)
-	block, _, err := w.engine.FinalizeAndAssemble(w.chain, work.header, work.state, work.txs, nil, work.receipts, params.withdrawals)
+	finalize := w.engine.FinalizeAndAssemble
+	if simulator, ok := w.engine.(consensus.BlockSimulator); ok && params.simulate {
+		finalize = simulator.FinalizeAndAssembleUnsigned
+	}
+	block, receipts, err := finalize(w.chain, work.header, work.state, work.txs, nil, work.receipts, params.withdrawals)
 	if err != nil {
 		return &newPayloadResult{err: err}
 	}
@@ -1037,6 +1087,8 @@ func (w *worker) generateWork(params *generateParams) *newPayloadResult {
 		block:    block,
 		fees:     fees,
 		sidecars: work.sidecars,
+		receipts: receipts,
+		skipped:  work.skipped,
 	}
 }
 
@@ -17,6 +17,7 @@
 package miner
 
 import (
+	"math"
 	"math/big"
 	"sync/atomic"
 	"testing"
@@ -27,8 +28,10 @@ import (
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/clique"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/consensus/zephyria"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/systemcontracts"
 	"github.com/ethereum/go-ethereum/core/txpool"
 	"github.com/ethereum/go-ethereum/core/txpool/legacypool"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -50,9 +53,10 @@ const (
 
 var (
 	// Test chain configurations
-	testTxPoolConfig  legacypool.Config
-	ethashChainConfig *params.ChainConfig
-	cliqueChainConfig *params.ChainConfig
+	testTxPoolConfig    legacypool.Config
+	ethashChainConfig   *params.ChainConfig
+	cliqueChainConfig   *params.ChainConfig
+	zephyriaChainConfig *params.ChainConfig
 
 	// Test accounts
 	testBankKey, _  = crypto.GenerateKey()
@@ -83,6 +87,13 @@ func init() {
 		Period: 10,
 		Epoch:  30000,
 	}
+	zephyriaChainConfig = new(params.ChainConfig)
+	*zephyriaChainConfig = *params.PolarysChainConfig
+	zephyriaChainConfig.ChainID = params.TestChainConfig.ChainID
+	zephyriaChainConfig.Zephyria = &params.ZephyriaConfig{
+		Period: 3,
+		Epoch:  30000,
+	}
 
 	signer := types.LatestSigner(params.TestChainConfig)
 	tx1 := types.MustSignNewTx(testBankKey, signer, &types.AccessListTx{
@@ -125,6 +136,11 @@ func newTestWorkerBackend(t *testing.T, chainConfig *params.ChainConfig, engine
 		e.Authorize(testBankAddress, func(account accounts.Account, s string, data []byte) ([]byte, error) {
 			return crypto.Sign(crypto.Keccak256(data), testBankKey)
 		})
+	case *zephyria.Zephyria:
+		// The bank is the only validator, but the engine isn't authorized to
+		// sign as it unless the test does so.
+		gspec.ExtraData = make([]byte, 32+common.AddressLength+crypto.SignatureLength)
+		copy(gspec.ExtraData[32:32+common.AddressLength], testBankAddress.Bytes())
 	case *ethash.Ethash:
 	default:
 		t.Fatalf("unexpected consensus engine type: %T", engine)
@@ -499,3 +515,92 @@ func testGetSealingWork(t *testing.T, chainConfig *params.ChainConfig, engine co
 		}
 	}
 }
+
+func TestSimulateBlock(t *testing.T) {
+	engine := ethash.NewFaker()
+	defer engine.Close()
+
+	w, b := newTestWorker(t, ethashChainConfig, engine, rawdb.NewMemoryDatabase(), 0)
+	defer w.close()
+
+	// Simulate a block from the pool, overriding the block parameters
+	var (
+		timestamp = uint64(time.Now().Unix()) + 100
+		coinbase  = common.HexToAddress("0xdeadbeef")
+	)
+	res, err := w.simulateBlock(&SimulateArgs{Timestamp: &timestamp, Coinbase: &coinbase})
+	if err != nil {
+		t.Fatalf("failed to simulate block: %v", err)
+	}
+	if res.Header.Time != timestamp || res.Header.Coinbase != coinbase {
+		t.Errorf("block overrides mismatch: have %d/%x, want %d/%x", res.Header.Time, res.Header.Coinbase, timestamp, coinbase)
+	}
+	if len(res.Transactions) != 1 || res.Transactions[0] != pendingTxs[0].Hash() {
+		t.Errorf("included transactions mismatch: have %v, want [%x]", res.Transactions, pendingTxs[0].Hash())
+	}
+	if len(res.Receipts) != 1 || uint64(res.GasUsed) != params.TxGas {
+		t.Errorf("execution results mismatch: have %d receipts, %d gas", len(res.Receipts), res.GasUsed)
+	}
+	// Simulate a block from a supplied list, ensuring the unexecutable ones are
+	// reported as skipped
+	gapped := types.MustSignNewTx(testBankKey, types.LatestSigner(ethashChainConfig), &types.LegacyTx{
+		Nonce:    5,
+		To:       &testUserAddress,
+		Gas:      params.TxGas,
+		GasPrice: big.NewInt(params.InitialBaseFee),
+	})
+	res, err = w.simulateBlock(&SimulateArgs{Transactions: []*types.Transaction{gapped}})
+	if err != nil {
+		t.Fatalf("failed to simulate block: %v", err)
+	}
+	if len(res.Transactions) != 0 {
+		t.Errorf("included transactions mismatch: have %d, want %d", len(res.Transactions), 0)
+	}
+	if len(res.Skipped) != 1 || res.Skipped[0].Hash != gapped.Hash() {
+		t.Errorf("skipped transactions mismatch: have %v, want [%x]", res.Skipped, gapped.Hash())
+	}
+	// Ensure nothing was persisted or consumed by the simulations
+	if head := b.chain.CurrentBlock().Number.Uint64(); head != 0 {
+		t.Errorf("chain head mismatch: have %d, want %d", head, 0)
+	}
+	if pending, _ := b.txPool.Stats(); pending != 1 {
+		t.Errorf("pending transactions mismatch: have %d, want %d", pending, 1)
+	}
+}
+
+// Tests that blocks of a PoSA chain can be simulated without an authorized
+// validator and for any coinbase, leaving the system transactions unsigned.
+func TestSimulateZephyriaBlock(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+
+	// The genesis hash only feeds the fork IDs announced in the headers.
+	engine := zephyria.New(zephyriaChainConfig, db, nil, common.Hash{})
+	defer engine.Close()
+
+	w, b := newTestWorker(t, zephyriaChainConfig, engine, db, 0)
+	defer w.close()
+
+	coinbase := common.HexToAddress("0xdeadbeef")
+	res, err := w.simulateBlock(&SimulateArgs{Coinbase: &coinbase})
+	if err != nil {
+		t.Fatalf("failed to simulate block: %v", err)
+	}
+	if res.Header.Coinbase != coinbase {
+		t.Errorf("coinbase mismatch: have %x, want %x", res.Header.Coinbase, coinbase)
+	}
+	// The first block initializes the system contracts from the coinbase, with
+	// unsigned transactions following the pooled one.
+	if len(res.Transactions) < 2 || res.Transactions[0] != pendingTxs[0].Hash() {
+		t.Fatalf("included transactions mismatch: have %v", res.Transactions)
+	}
+	initTx := types.NewTransaction(0, common.HexToAddress(systemcontracts.ValidatorController), common.Big0, math.MaxUint64/2, common.Big0, crypto.Keccak256([]byte("init()"))[:4])
+	if res.Transactions[1] != initTx.Hash() {
+		t.Errorf("system transaction mismatch: have %x, want unsigned %x", res.Transactions[1], initTx.Hash())
+	}
+	if len(res.Receipts) != len(res.Transactions) {
+		t.Errorf("receipts mismatch: have %d, want %d", len(res.Receipts), len(res.Transactions))
+	}
+	if head := b.chain.CurrentBlock().Number.Uint64(); head != 0 {
+		t.Errorf("chain head mismatch: have %d, want %d", head, 0)
+	}
+}
@@ -179,6 +179,7 @@ func (api *adminAPI) StartHTTP(host *string, port *int, cors *string, apis *stri
 		rpcEndpointConfig: rpcEndpointConfig{
 			batchItemLimit:         api.node.config.BatchRequestLimit,
 			batchResponseSizeLimit: api.node.config.BatchResponseMaxSize,
+			slowQueryThreshold:     api.node.config.RPCSlowQueryThreshold,
 		},
 	}
 	if cors != nil {
@@ -257,6 +258,7 @@ func (api *adminAPI) StartWS(host *string, port *int, allowedOrigins *string, ap
 		rpcEndpointConfig: rpcEndpointConfig{
 			batchItemLimit:         api.node.config.BatchRequestLimit,
 			batchResponseSizeLimit: api.node.config.BatchResponseMaxSize,
+			slowQueryThreshold:     api.node.config.RPCSlowQueryThreshold,
 		},
 	}
 	if apis != nil {
@@ -24,6 +24,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -137,6 +138,14 @@ type Config struct {
 	// HTTPPathPrefix specifies a path prefix on which http-rpc is to be served.
 	HTTPPathPrefix string `toml:",omitempty"`
 
+	// HTTPH2C enables HTTP/2 over cleartext TCP on the HTTP RPC server, next to
+	// HTTP/1.1.
+	HTTPH2C bool `toml:",omitempty"`
+
+	// HTTPGRPC enables the gRPC transport on the HTTP RPC server, exposing the
+	// HTTP API modules. It implies HTTPH2C.
+	HTTPGRPC bool `toml:",omitempty"`
+
 	// AuthAddr is the listening address on which authenticated APIs are provided.
 	AuthAddr string `toml:",omitempty"`
 
@@ -204,6 +213,18 @@ type Config struct {
 	// BatchResponseMaxSize is the maximum number of bytes returned from a batched rpc call.
 	BatchResponseMaxSize int `toml:",omitempty"`
 
+	// RPCQuotas configures the API keys of the HTTP and WebSocket RPC endpoints
+	// and their quotas. The endpoints are open to everyone if nil.
+	RPCQuotas *RPCQuotaConfig `toml:",omitempty"`
+
+	// RPCSlowQueryThreshold makes the RPC servers log the calls taking longer
+	// than the given duration. Slow calls aren't logged if zero.
+	RPCSlowQueryThreshold time.Duration `toml:",omitempty"`
+
+	// RPCTracingEndpoint is the OTLP/HTTP traces endpoint of an OpenTelemetry
+	// collector receiving the spans of the RPC calls. Tracing is disabled if empty.
+	RPCTracingEndpoint string `toml:",omitempty"`
+
 	// JWTSecret is the path to the hex-encoded jwt secret.
 	JWTSecret string `toml:",omitempty"`
 
@@ -34,6 +34,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/internal/tracing"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -64,7 +65,8 @@ type Node struct {
 	ipc           *ipcServer  // Stores information about the ipc http server
 	inprocHandler *rpc.Server // In-process RPC request handler to process the API requests
 
-	databases map[*closeTrackingDB]struct{} // All open databases
+	databases    map[*closeTrackingDB]struct{} // All open databases
+	spanExporter *tracing.OTLPExporter         // Exports the spans of RPC calls, nil if tracing is disabled
 }
 
 const (
@@ -103,6 +105,7 @@ func New(conf *Config) (*Node, error) {
 	}
 	server := rpc.NewServer()
 	server.SetBatchLimits(conf.BatchRequestLimit, conf.BatchResponseMaxSize)
+	server.SetSlowQueryThreshold(conf.RPCSlowQueryThreshold)
 	node := &Node{
 		config:        conf,
 		inprocHandler: server,
@@ -147,6 +150,21 @@ func New(conf *Config) (*Node, error) {
 		return nil, err
 	}
 
+	// Export the spans of RPC calls if tracing is enabled.
+	if conf.RPCTracingEndpoint != "" {
+		resource := []tracing.Attribute{tracing.String("service.name", conf.name())}
+		if conf.Version != "" {
+			resource = append(resource, tracing.String("service.version", conf.Version))
+		}
+		exporter, err := tracing.NewOTLPExporter(conf.RPCTracingEndpoint, resource...)
+		if err != nil {
+			return nil, err
+		}
+		node.spanExporter = exporter
+		tracing.SetExporter(exporter)
+		node.log.Info("Exporting RPC traces", "endpoint", conf.RPCTracingEndpoint)
+	}
+
 	// Configure RPC servers.
 	node.http = newHTTPServer(node.log, conf.HTTPTimeouts)
 	node.httpAuth = newHTTPServer(node.log, conf.HTTPTimeouts)
@@ -245,6 +263,12 @@ func (n *Node) doClose(errs []error) error {
 		}
 	}
 
+	// Flush the spans of the calls served before shutdown.
+	if n.spanExporter != nil {
+		tracing.SetExporter(nil)
+		n.spanExporter.Close()
+	}
+
 	// Release instance directory lock.
 	n.closeDataDir()
 
@@ -404,9 +428,15 @@ func (n *Node) startRPC() error {
 		openAPIs, allAPIs = n.getAPIs()
 	)
 
+	quotas, err := newRPCQuotas(n.config.RPCQuotas)
+	if err != nil {
+		return err
+	}
 	rpcConfig := rpcEndpointConfig{
 		batchItemLimit:         n.config.BatchRequestLimit,
 		batchResponseSizeLimit: n.config.BatchResponseMaxSize,
+		slowQueryThreshold:     n.config.RPCSlowQueryThreshold,
+		quotas:                 quotas,
 	}
 
 	initHttp := func(server *httpServer, port int) error {
@@ -417,6 +447,8 @@ func (n *Node) startRPC() error {
 			CorsAllowedOrigins: n.config.HTTPCors,
 			Vhosts:             n.config.HTTPVirtualHosts,
 			Modules:            n.config.HTTPModules,
+			H2C:                n.config.HTTPH2C,
+			GRPC:               n.config.HTTPGRPC,
 			prefix:             n.config.HTTPPathPrefix,
 			rpcEndpointConfig:  rpcConfig,
 		}); err != nil {
@@ -453,6 +485,7 @@ func (n *Node) startRPC() error {
 			jwtSecret:              secret,
 			batchItemLimit:         engineAPIBatchItemLimit,
 			batchResponseSizeLimit: engineAPIBatchResponseSizeLimit,
+			slowQueryThreshold:     n.config.RPCSlowQueryThreshold,
 		}
 		if err := server.enableRPC(allAPIs, httpConfig{
 			CorsAllowedOrigins: DefaultAuthCors,
@@ -541,6 +574,7 @@ func (n *Node) startInProc(apis []rpc.API) error {
 		if err := n.inprocHandler.RegisterName(api.Namespace, api.Service); err != nil {
 			return err
 		}
+		n.inprocHandler.RegisterDocs(api.Namespace, api.Docs)
 	}
 	return nil
 }
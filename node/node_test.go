@@ -22,8 +22,10 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/crypto"
@@ -61,6 +63,48 @@ func TestNodeCloseMultipleTimes(t *testing.T) {
 	}
 }
 
+// Tests that the spans of RPC calls are exported to the configured collector,
+// and flushed when the node is closed.
+func TestNodeRPCTracing(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		bodies []string
+	)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+	}))
+	defer collector.Close()
+
+	conf := testNodeConfig()
+	conf.RPCTracingEndpoint = "localhost:4318"
+	if _, err := New(conf); err == nil {
+		t.Fatal("invalid tracing endpoint accepted")
+	}
+	conf.RPCTracingEndpoint = collector.URL
+	stack, err := New(conf)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	client := stack.Attach()
+	if err := client.Call(nil, "rpc_modules"); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	client.Close()
+	stack.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies) != 1 || !strings.Contains(bodies[0], `"name":"rpc_modules"`) || !strings.Contains(bodies[0], `"stringValue":"test node"`) {
+		t.Fatalf("spans not exported: %v", bodies)
+	}
+}
+
 func TestNodeStartMultipleTimes(t *testing.T) {
 	stack, err := New(testNodeConfig())
 	if err != nil {
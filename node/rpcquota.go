@@ -0,0 +1,489 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// DefaultAPIKeyHeader is the request header carrying the API key of a
+	// client, unless configured otherwise.
+	DefaultAPIKeyHeader = "X-API-Key"
+
+	// anonymousCaller is the name of clients without API key in metrics.
+	anonymousCaller = "anonymous"
+
+	// errcodeQuotaExceeded is the error code of calls rejected for exceeding
+	// the quota of the caller, the "limit exceeded" code of EIP-1474.
+	errcodeQuotaExceeded = -32005
+
+	// quotaDefaultCallGas is the gas charged for calls not specifying theirs,
+	// the default gas cap of eth_call.
+	quotaDefaultCallGas = 50_000_000
+
+	// quotaUnknownLogRange is the block range charged for log queries whose
+	// range can't be told without resolving block tags.
+	quotaUnknownLogRange = 10_000
+)
+
+// RPCQuotaConfig configures API keys for the public HTTP and WebSocket RPC
+// endpoints. Every key belongs to a tier, limiting the rate of the requests of
+// the key and the compute units they may spend, both overall and per method.
+//
+// Clients send their key either in a request header or as the last segment of
+// the request path, e.g. ws://host:8546/<key> for browsers which can't set the
+// headers of websocket connections.
+//
+// The other handlers served on the HTTP endpoint, like GraphQL, require keys
+// too. Their requests and websocket connections are charged as calls of the
+// route, e.g. "graphql", whose queries and subscriptions are not metered.
+type RPCQuotaConfig struct {
+	Header    string                  `toml:",omitempty"` // Request header carrying the API key, X-API-Key if empty
+	Anonymous string                  `toml:",omitempty"` // Tier of the clients without key, rejected if empty
+	Tiers     map[string]RPCQuotaTier `toml:",omitempty"`
+	Keys      []RPCAPIKey             `toml:",omitempty"`
+}
+
+// RPCAPIKey is an API key accepted by the RPC endpoints.
+type RPCAPIKey struct {
+	Name string // Name of the key in logs and metrics
+	Key  string // Secret sent by the client
+	Tier string // Tier of the key
+}
+
+// RPCQuotaTier is a set of quotas shared by API keys.
+type RPCQuotaTier struct {
+	Limit   RPCQuota            `toml:",omitempty"` // Quota across all methods
+	Methods map[string]RPCQuota `toml:",omitempty"` // Quotas of individual methods, on top of the overall one
+	Units   map[string]uint64   `toml:",omitempty"` // Base compute units of methods, overriding the defaults
+}
+
+// RPCQuota limits the sustained rate of requests and compute units. Callers
+// may burst up to a second worth of either.
+type RPCQuota struct {
+	Requests float64 `toml:",omitempty"` // Requests per second, unlimited if zero
+	Units    float64 `toml:",omitempty"` // Compute units per second, unlimited if zero
+}
+
+// defaultComputeUnits are the base compute units of the methods more expensive
+// than the single unit charged by default.
+var defaultComputeUnits = map[string]uint64{
+	"eth_call":               20,
+	"eth_estimateGas":        20,
+	"eth_createAccessList":   20,
+	"eth_getLogs":            20,
+	"eth_getProof":           20,
+	"eth_getBlockReceipts":   20,
+	"eth_feeHistory":         10,
+	"eth_sendRawTransaction": 10,
+	"eth_simulateV1":         100,
+}
+
+// Base compute units of the method families not listed individually.
+const (
+	traceComputeUnits = 500 // debug_trace*
+	debugComputeUnits = 100 // other debug_* methods
+)
+
+// computeUnits returns the compute units charged for a call. On top of the
+// base units of the method, calls executing the EVM are charged a unit per
+// million gas allowed, log queries a unit per ten blocks searched.
+func computeUnits(tier *RPCQuotaTier, method string, params json.RawMessage) uint64 {
+	units, ok := tier.Units[method]
+	if !ok {
+		switch {
+		case defaultComputeUnits[method] != 0:
+			units = defaultComputeUnits[method]
+		case strings.HasPrefix(method, "debug_trace"):
+			units = traceComputeUnits
+		case strings.HasPrefix(method, "debug_"):
+			units = debugComputeUnits
+		default:
+			units = 1
+		}
+	}
+	switch method {
+	case "eth_call", "eth_estimateGas", "eth_createAccessList":
+		units += callGas(params) / 1_000_000
+	case "eth_getLogs":
+		units += logRange(params) / 10
+	}
+	return units
+}
+
+// firstParam decodes the first positional parameter of a call into v.
+func firstParam(params json.RawMessage, v interface{}) bool {
+	var args []json.RawMessage
+	if err := json.Unmarshal(params, &args); err != nil || len(args) == 0 {
+		return false
+	}
+	return json.Unmarshal(args[0], v) == nil
+}
+
+// callGas returns the gas allowed to a call.
+func callGas(params json.RawMessage) uint64 {
+	var args struct {
+		Gas *hexutil.Uint64 `json:"gas"`
+	}
+	if !firstParam(params, &args) || args.Gas == nil {
+		return quotaDefaultCallGas
+	}
+	return uint64(*args.Gas)
+}
+
+// logRange returns the number of blocks a log query searches.
+func logRange(params json.RawMessage) uint64 {
+	var args struct {
+		BlockHash *common.Hash     `json:"blockHash"`
+		FromBlock *rpc.BlockNumber `json:"fromBlock"`
+		ToBlock   *rpc.BlockNumber `json:"toBlock"`
+	}
+	if !firstParam(params, &args) {
+		return 1
+	}
+	if args.BlockHash != nil {
+		return 1
+	}
+	from, to := rpc.LatestBlockNumber, rpc.LatestBlockNumber
+	if args.FromBlock != nil {
+		from = *args.FromBlock
+	}
+	if args.ToBlock != nil {
+		to = *args.ToBlock
+	}
+	switch {
+	case from < 0 && to < 0:
+		return 1
+	case from < 0 || to < 0:
+		return quotaUnknownLogRange
+	case to < from:
+		return 1
+	}
+	return uint64(to-from) + 1
+}
+
+// quotaBucket is a token bucket refilled at a constant rate, holding at most a
+// second worth of tokens. Calls are admitted while the bucket holds a token and
+// may overdraw it, so that a single call may cost more than the capacity.
+type quotaBucket struct {
+	rate   float64 // Tokens per second, unlimited if zero
+	tokens float64
+	last   time.Time
+}
+
+func newQuotaBucket(rate float64) quotaBucket {
+	return quotaBucket{rate: rate, tokens: math.Max(rate, 1), last: time.Now()}
+}
+
+// refill adds the tokens accrued since the last call and returns how long the
+// caller has to wait until the bucket holds a token again.
+func (b *quotaBucket) refill(now time.Time) time.Duration {
+	if b.rate == 0 {
+		return 0
+	}
+	b.tokens = math.Min(math.Max(b.rate, 1), b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1-b.tokens)/b.rate*float64(time.Second)) + time.Millisecond
+}
+
+// take removes tokens from the bucket.
+func (b *quotaBucket) take(n float64) {
+	if b.rate != 0 {
+		b.tokens -= n
+	}
+}
+
+// quotaLimiter enforces a quota.
+type quotaLimiter struct {
+	requests quotaBucket
+	units    quotaBucket
+}
+
+func newQuotaLimiter(quota RPCQuota) *quotaLimiter {
+	return &quotaLimiter{
+		requests: newQuotaBucket(quota.Requests),
+		units:    newQuotaBucket(quota.Units),
+	}
+}
+
+// refill returns how long the caller has to wait until a call is admitted.
+func (l *quotaLimiter) refill(now time.Time) time.Duration {
+	wait := l.requests.refill(now)
+	if units := l.units.refill(now); units > wait {
+		wait = units
+	}
+	return wait
+}
+
+func (l *quotaLimiter) take(units uint64) {
+	l.requests.take(1)
+	l.units.take(float64(units))
+}
+
+// quotaAccount tracks the usage of an API key.
+type quotaAccount struct {
+	name string
+	tier *RPCQuotaTier
+
+	lock    sync.Mutex
+	limit   *quotaLimiter
+	methods map[string]*quotaLimiter // Limiters of the methods with quotas of their own
+
+	requestMeter  metrics.Meter
+	unitMeter     metrics.Meter
+	rejectedMeter metrics.Meter
+}
+
+func newQuotaAccount(name string, tier *RPCQuotaTier) *quotaAccount {
+	account := &quotaAccount{
+		name:          name,
+		tier:          tier,
+		limit:         newQuotaLimiter(tier.Limit),
+		methods:       make(map[string]*quotaLimiter, len(tier.Methods)),
+		requestMeter:  metrics.GetOrRegisterMeter("rpc/quota/"+name+"/requests", nil),
+		unitMeter:     metrics.GetOrRegisterMeter("rpc/quota/"+name+"/units", nil),
+		rejectedMeter: metrics.GetOrRegisterMeter("rpc/quota/"+name+"/rejected", nil),
+	}
+	for method, quota := range tier.Methods {
+		account.methods[method] = newQuotaLimiter(quota)
+	}
+	return account
+}
+
+// charge admits a call if neither the overall quota nor the one of the method
+// are exhausted, charging it to both.
+func (a *quotaAccount) charge(method string, units uint64) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	now := time.Now()
+	wait := a.limit.refill(now)
+	limit := a.methods[method]
+	if limit != nil {
+		if w := limit.refill(now); w > wait {
+			wait = w
+		}
+	}
+	if wait > 0 {
+		a.rejectedMeter.Mark(1)
+		return &quotaExceededError{method: method, retryAfter: wait}
+	}
+	a.limit.take(units)
+	if limit != nil {
+		limit.take(units)
+	}
+	a.requestMeter.Mark(1)
+	a.unitMeter.Mark(int64(units))
+	return nil
+}
+
+// quotaExceededError is returned for calls exceeding the quota of the caller.
+type quotaExceededError struct {
+	method     string
+	retryAfter time.Duration
+}
+
+func (e *quotaExceededError) Error() string {
+	return fmt.Sprintf("quota exceeded for %s, retry in %v", e.method, e.retryAfter.Round(time.Millisecond))
+}
+
+func (e *quotaExceededError) ErrorCode() int { return errcodeQuotaExceeded }
+
+// ErrorData returns the number of seconds to wait before retrying.
+func (e *quotaExceededError) ErrorData() interface{} {
+	return map[string]interface{}{"retryAfter": math.Ceil(e.retryAfter.Seconds())}
+}
+
+type apiKeyContextKey struct{}
+
+// rpcQuotas authenticates the clients of the RPC endpoints by their API keys
+// and enforces the quotas of their tiers.
+type rpcQuotas struct {
+	header    string
+	keys      map[string]*quotaAccount // Accounts by secret
+	names     map[string]*quotaAccount // Accounts by name
+	anonymous *quotaAccount            // Account of the clients without key, nil if rejected
+}
+
+// newRPCQuotas validates the configuration of the API keys and creates their
+// accounts. It returns nil if no configuration is given.
+func newRPCQuotas(config *RPCQuotaConfig) (*rpcQuotas, error) {
+	if config == nil {
+		return nil, nil
+	}
+	q := &rpcQuotas{
+		header: config.Header,
+		keys:   make(map[string]*quotaAccount, len(config.Keys)),
+		names:  make(map[string]*quotaAccount, len(config.Keys)),
+	}
+	if q.header == "" {
+		q.header = DefaultAPIKeyHeader
+	}
+	tiers := make(map[string]*RPCQuotaTier, len(config.Tiers))
+	for name, tier := range config.Tiers {
+		tier := tier
+		tiers[name] = &tier
+	}
+	for _, key := range config.Keys {
+		switch {
+		case key.Name == "" || key.Name == anonymousCaller:
+			return nil, fmt.Errorf("invalid API key name %q", key.Name)
+		case key.Key == "" || strings.Contains(key.Key, "/"):
+			return nil, fmt.Errorf("invalid API key %q", key.Name)
+		case tiers[key.Tier] == nil:
+			return nil, fmt.Errorf("unknown tier %q of API key %q", key.Tier, key.Name)
+		case q.names[key.Name] != nil:
+			return nil, fmt.Errorf("duplicate API key name %q", key.Name)
+		case q.keys[key.Key] != nil:
+			return nil, fmt.Errorf("API key %q duplicates %q", key.Name, q.keys[key.Key].name)
+		}
+		account := newQuotaAccount(key.Name, tiers[key.Tier])
+		q.keys[key.Key] = account
+		q.names[key.Name] = account
+	}
+	if config.Anonymous != "" {
+		tier := tiers[config.Anonymous]
+		if tier == nil {
+			return nil, fmt.Errorf("unknown tier %q of anonymous clients", config.Anonymous)
+		}
+		q.anonymous = newQuotaAccount(anonymousCaller, tier)
+	}
+	return q, nil
+}
+
+// stripKey removes the API key from the path of a request, if its last segment
+// is one, so that the request is routed like one without key.
+func (q *rpcQuotas) stripKey(r *http.Request) *http.Request {
+	if q == nil {
+		return r
+	}
+	idx := strings.LastIndexByte(r.URL.Path, '/')
+	if idx < 0 || q.keys[r.URL.Path[idx+1:]] == nil {
+		return r
+	}
+	path := r.URL.Path[:idx]
+	if path == "" {
+		path = "/"
+	}
+	r = r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, r.URL.Path[idx+1:]))
+	r.URL.Path, r.URL.RawPath = path, ""
+	return r
+}
+
+// handler returns an http.Handler rejecting requests without a valid API key,
+// unless anonymous clients are allowed, and identifying the callers to the RPC
+// server.
+func (q *rpcQuotas) handler(next http.Handler) http.Handler {
+	if q == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		account, ok := q.authenticate(w, r)
+		if !ok {
+			return
+		}
+		if account != q.anonymous {
+			r = r.WithContext(rpc.WithCaller(r.Context(), account.name))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// routeHandler returns an http.Handler guarding a handler registered via
+// Node.RegisterHandler, e.g. GraphQL. Clients are authenticated like the ones
+// of the RPC endpoints, and every request or websocket connection is charged
+// as a call of the route, named after its path without slashes.
+func (q *rpcQuotas) routeHandler(next http.Handler, pattern string) http.Handler {
+	if q == nil {
+		return next
+	}
+	route := strings.Trim(pattern, "/")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		account, ok := q.authenticate(w, r)
+		if !ok {
+			return
+		}
+		if err := account.charge(route, computeUnits(account.tier, route, nil)); err != nil {
+			log.Debug("Rejected HTTP request over quota", "caller", account.name, "route", route)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(err.(*quotaExceededError).retryAfter.Seconds()))))
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+		if account != q.anonymous {
+			r = r.WithContext(rpc.WithCaller(r.Context(), account.name))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticate returns the account of the client sending a request. If the
+// client is rejected, the error response is written and false returned.
+func (q *rpcQuotas) authenticate(w http.ResponseWriter, r *http.Request) (*quotaAccount, bool) {
+	key, _ := r.Context().Value(apiKeyContextKey{}).(string)
+	if key == "" {
+		key = r.Header.Get(q.header)
+	}
+	if key == "" {
+		if q.anonymous == nil {
+			http.Error(w, "missing API key", http.StatusUnauthorized)
+			return nil, false
+		}
+		return q.anonymous, true
+	}
+	account := q.keys[key]
+	if account == nil {
+		http.Error(w, "invalid API key", http.StatusUnauthorized)
+		return nil, false
+	}
+	return account, true
+}
+
+// filter charges calls to the account of their caller, rejecting them when its
+// quotas are exhausted.
+func (q *rpcQuotas) filter(ctx context.Context, method string, params json.RawMessage) error {
+	account := q.anonymous
+	if caller := rpc.PeerInfoFromContext(ctx).Caller; caller != "" {
+		account = q.names[caller]
+	}
+	if account == nil {
+		return errors.New("missing API key")
+	}
+	err := account.charge(method, computeUnits(account.tier, method, params))
+	if err != nil {
+		log.Debug("Rejected RPC call over quota", "caller", account.name, "method", method)
+	}
+	return err
+}
@@ -0,0 +1,201 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// TestRPCQuotas checks that the RPC endpoints authenticate clients by their API
+// keys and enforce the quotas of their tiers.
+func TestRPCQuotas(t *testing.T) {
+	quotas, err := newRPCQuotas(&RPCQuotaConfig{
+		Tiers: map[string]RPCQuotaTier{
+			"free": {
+				Limit:   RPCQuota{Requests: 100},
+				Methods: map[string]RPCQuota{"test_greet": {Requests: 2}},
+			},
+		},
+		Keys: []RPCAPIKey{{Name: "alice", Key: "s3cret", Tier: "free"}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create quotas: %v", err)
+	}
+	endpoint := rpcEndpointConfig{quotas: quotas}
+	srv := createAndStartServer(t, &httpConfig{Modules: []string{"test"}, rpcEndpointConfig: endpoint}, true, &wsConfig{Origins: []string{"*"}, rpcEndpointConfig: endpoint}, nil)
+	defer srv.stop()
+	url := "http://" + srv.listenAddr()
+
+	// Requests without a valid key should be rejected
+	if resp := rpcRequest(t, url, "test_greet"); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("missing key: status %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+	if resp := rpcRequest(t, url, "test_greet", DefaultAPIKeyHeader, "wrong"); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("invalid key: status %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+	// The key may be sent in the header or the path, until the quota of the
+	// method is exhausted
+	type response struct {
+		Result string
+		Error  *struct {
+			Code int
+			Data struct{ RetryAfter float64 }
+		}
+	}
+	call := func(url string, headers ...string) *response {
+		t.Helper()
+
+		resp := rpcRequest(t, url, "test_greet", headers...)
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status %d, want %d", resp.StatusCode, http.StatusOK)
+		}
+		blob, _ := io.ReadAll(resp.Body)
+		var res response
+		if err := json.Unmarshal(blob, &res); err != nil {
+			t.Fatalf("invalid response %s: %v", blob, err)
+		}
+		return &res
+	}
+	if res := call(url, DefaultAPIKeyHeader, "s3cret"); res.Error != nil || res.Result != "Hello" {
+		t.Errorf("header key: unexpected response %+v", res)
+	}
+	if res := call(url + "/s3cret"); res.Error != nil || res.Result != "Hello" {
+		t.Errorf("path key: unexpected response %+v", res)
+	}
+	res := call(url, DefaultAPIKeyHeader, "s3cret")
+	if res.Error == nil || res.Error.Code != errcodeQuotaExceeded || res.Error.Data.RetryAfter <= 0 {
+		t.Errorf("over quota: unexpected response %+v", res)
+	}
+	// Other methods should only be limited by the overall quota
+	client, err := rpc.DialWebsocket(context.Background(), "ws://"+srv.listenAddr()+"/s3cret", "")
+	if err != nil {
+		t.Fatalf("failed to dial websocket: %v", err)
+	}
+	defer client.Close()
+
+	var modules map[string]string
+	if err := client.Call(&modules, "rpc_modules"); err != nil {
+		t.Errorf("websocket call failed: %v", err)
+	}
+}
+
+// TestRPCQuotaRoutes checks that the handlers registered next to the RPC
+// endpoint require API keys and are charged to their quotas.
+func TestRPCQuotaRoutes(t *testing.T) {
+	quotas, err := newRPCQuotas(&RPCQuotaConfig{
+		Tiers: map[string]RPCQuotaTier{
+			"free": {
+				Limit:   RPCQuota{Requests: 100},
+				Methods: map[string]RPCQuota{"graphql": {Requests: 1}},
+			},
+		},
+		Keys: []RPCAPIKey{{Name: "alice", Key: "s3cret", Tier: "free"}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create quotas: %v", err)
+	}
+	srv := createAndStartServer(t, &httpConfig{rpcEndpointConfig: rpcEndpointConfig{quotas: quotas}}, false, nil, nil)
+	defer srv.stop()
+	srv.mux.Handle("/graphql", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	url := "http://" + srv.listenAddr() + "/graphql"
+
+	get := func(url string, headers ...string) *http.Response {
+		t.Helper()
+
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		for i := 0; i < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	if resp := get(url); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("missing key: status %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+	if resp := get(url + "/s3cret"); resp.StatusCode != http.StatusOK {
+		t.Errorf("path key: status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	resp := get(url, DefaultAPIKeyHeader, "s3cret")
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("over quota: status %d, retry after %q", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+}
+
+// TestRPCQuotaConfig checks that invalid quota configurations are rejected.
+func TestRPCQuotaConfig(t *testing.T) {
+	tiers := map[string]RPCQuotaTier{"free": {}}
+	tests := []*RPCQuotaConfig{
+		{Tiers: tiers, Keys: []RPCAPIKey{{Name: "alice", Key: "a", Tier: "paid"}}},
+		{Tiers: tiers, Keys: []RPCAPIKey{{Name: "alice", Key: "", Tier: "free"}}},
+		{Tiers: tiers, Keys: []RPCAPIKey{{Name: "alice", Key: "a/b", Tier: "free"}}},
+		{Tiers: tiers, Keys: []RPCAPIKey{{Name: "alice", Key: "a", Tier: "free"}, {Name: "alice", Key: "b", Tier: "free"}}},
+		{Tiers: tiers, Keys: []RPCAPIKey{{Name: "alice", Key: "a", Tier: "free"}, {Name: "bob", Key: "a", Tier: "free"}}},
+		{Tiers: tiers, Anonymous: "paid"},
+	}
+	for i, config := range tests {
+		if _, err := newRPCQuotas(config); err == nil {
+			t.Errorf("test %d: invalid config accepted", i)
+		}
+	}
+}
+
+func TestComputeUnits(t *testing.T) {
+	tier := &RPCQuotaTier{Units: map[string]uint64{"eth_getBalance": 5}}
+	tests := []struct {
+		method string
+		params string
+		want   uint64
+	}{
+		{"eth_blockNumber", `[]`, 1},
+		{"eth_getBalance", `["0x0000000000000000000000000000000000000000", "latest"]`, 5},
+		{"debug_traceTransaction", `[]`, traceComputeUnits},
+		{"debug_getRawBlock", `[]`, debugComputeUnits},
+		{"eth_call", `[{"gas": "0x2dc6c0"}, "latest"]`, 23},
+		{"eth_call", `[{}, "latest"]`, 20 + quotaDefaultCallGas/1_000_000},
+		{"eth_getLogs", `[{"fromBlock": "0x0", "toBlock": "0x63"}]`, 30},
+		{"eth_getLogs", `[{"fromBlock": "0x0"}]`, 20 + quotaUnknownLogRange/10},
+		{"eth_getLogs", `[{"blockHash": "0x0000000000000000000000000000000000000000000000000000000000000000"}]`, 20},
+		{"eth_getLogs", `[{}]`, 20},
+	}
+	for i, tt := range tests {
+		if have := computeUnits(tier, tt.method, json.RawMessage(tt.params)); have != tt.want {
+			t.Errorf("test %d (%s): compute units mismatch: have %d, want %d", i, tt.method, have, tt.want)
+		}
+	}
+	var quotaErr *quotaExceededError
+	account := newQuotaAccount("test", &RPCQuotaTier{Limit: RPCQuota{Units: 10}})
+	if err := account.charge("eth_call", 100); err != nil {
+		t.Fatalf("first call rejected: %v", err)
+	}
+	if err := account.charge("eth_blockNumber", 1); !errors.As(err, &quotaErr) {
+		t.Fatalf("overdrawn account: have error %v, want quota exceeded", err)
+	}
+}
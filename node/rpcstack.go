@@ -33,6 +33,8 @@ import (
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/rs/cors"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 // httpConfig is the JSON-RPC/HTTP configuration.
@@ -40,6 +42,8 @@ type httpConfig struct {
 	Modules            []string
 	CorsAllowedOrigins []string
 	Vhosts             []string
+	H2C                bool   // accept HTTP/2 over cleartext TCP
+	GRPC               bool   // serve the gRPC transport, implies H2C
 	prefix             string // path prefix on which to mount http handler
 	rpcEndpointConfig
 }
@@ -56,11 +60,15 @@ type rpcEndpointConfig struct {
 	jwtSecret              []byte // optional JWT secret
 	batchItemLimit         int
 	batchResponseSizeLimit int
+	slowQueryThreshold     time.Duration // calls taking longer are logged, disabled if zero
+	quotas                 *rpcQuotas    // optional API keys and quotas
 }
 
 type rpcHandler struct {
 	http.Handler
+	grpc   http.Handler // nil if gRPC is disabled
 	server *rpc.Server
+	quotas *rpcQuotas
 }
 
 type httpServer struct {
@@ -138,6 +146,9 @@ func (h *httpServer) start() error {
 
 	// Initialize the server.
 	h.server = &http.Server{Handler: h}
+	if h.httpConfig.H2C || h.httpConfig.GRPC {
+		h.server.Handler = h2c.NewHandler(h, new(http2.Server))
+	}
 	if h.timeouts != (rpc.HTTPTimeouts{}) {
 		CheckTimeouts(&h.timeouts)
 		h.server.ReadTimeout = h.timeouts.ReadTimeout
@@ -175,6 +186,7 @@ func (h *httpServer) start() error {
 		"prefix", h.httpConfig.prefix,
 		"cors", strings.Join(h.httpConfig.CorsAllowedOrigins, ","),
 		"vhosts", strings.Join(h.httpConfig.Vhosts, ","),
+		"h2c", h.httpConfig.H2C || h.httpConfig.GRPC, "grpc", h.httpConfig.GRPC,
 	)
 
 	// Log all handlers mounted on server.
@@ -198,22 +210,38 @@ func (h *httpServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// check if ws request and serve if ws enabled
 	ws := h.wsHandler.Load().(*rpcHandler)
 	if ws != nil && isWebsocket(r) {
+		r := ws.quotas.stripKey(r)
 		if checkPath(r, h.wsConfig.prefix) {
 			ws.ServeHTTP(w, r)
+			return
+		}
+		// Handlers registered via Node.RegisterHandler may serve websockets of
+		// their own, like the GraphQL subscriptions.
+		if h.rpcAllowed() {
+			if muxHandler, pattern := h.mux.Handler(r); pattern != "" {
+				ws.quotas.routeHandler(muxHandler, pattern).ServeHTTP(w, r)
+			}
 		}
 		return
 	}
 
 	// if http-rpc is enabled, try to serve request
 	rpc := h.httpHandler.Load().(*rpcHandler)
+	if rpc != nil && rpc.grpc != nil && isGRPC(r) {
+		// gRPC methods are served on fixed paths, regardless of the prefix
+		rpc.grpc.ServeHTTP(w, r)
+		return
+	}
 	if rpc != nil {
 		// First try to route in the mux.
 		// Requests to a path below root are handled by the mux,
 		// which has all the handlers registered via Node.RegisterHandler.
-		// These are made available when RPC is enabled.
+		// These are made available when RPC is enabled, behind the same
+		// API keys.
+		r := rpc.quotas.stripKey(r)
 		muxHandler, pattern := h.mux.Handler(r)
 		if pattern != "" {
-			muxHandler.ServeHTTP(w, r)
+			rpc.quotas.routeHandler(muxHandler, pattern).ServeHTTP(w, r)
 			return
 		}
 
@@ -304,14 +332,23 @@ func (h *httpServer) enableRPC(apis []rpc.API, config httpConfig) error {
 	// Create RPC server and handler.
 	srv := rpc.NewServer()
 	srv.SetBatchLimits(config.batchItemLimit, config.batchResponseSizeLimit)
+	srv.SetSlowQueryThreshold(config.slowQueryThreshold)
+	if config.quotas != nil {
+		srv.SetCallFilter(config.quotas.filter)
+	}
 	if err := RegisterApis(apis, config.Modules, srv); err != nil {
 		return err
 	}
 	h.httpConfig = config
-	h.httpHandler.Store(&rpcHandler{
-		Handler: NewHTTPHandlerStack(srv, config.CorsAllowedOrigins, config.Vhosts, config.jwtSecret),
+	handler := &rpcHandler{
+		Handler: NewHTTPHandlerStack(config.quotas.handler(srv), config.CorsAllowedOrigins, config.Vhosts, config.jwtSecret),
 		server:  srv,
-	})
+		quotas:  config.quotas,
+	}
+	if config.GRPC {
+		handler.grpc = NewGRPCHandlerStack(config.quotas.handler(srv.GRPCHandler()), config.Vhosts, config.jwtSecret)
+	}
+	h.httpHandler.Store(handler)
 	return nil
 }
 
@@ -336,13 +373,18 @@ func (h *httpServer) enableWS(apis []rpc.API, config wsConfig) error {
 	// Create RPC server and handler.
 	srv := rpc.NewServer()
 	srv.SetBatchLimits(config.batchItemLimit, config.batchResponseSizeLimit)
+	srv.SetSlowQueryThreshold(config.slowQueryThreshold)
+	if config.quotas != nil {
+		srv.SetCallFilter(config.quotas.filter)
+	}
 	if err := RegisterApis(apis, config.Modules, srv); err != nil {
 		return err
 	}
 	h.wsConfig = config
 	h.wsHandler.Store(&rpcHandler{
-		Handler: NewWSHandlerStack(srv.WebsocketHandler(config.Origins), config.jwtSecret),
+		Handler: NewWSHandlerStack(config.quotas.handler(srv.WebsocketHandler(config.Origins)), config.jwtSecret),
 		server:  srv,
+		quotas:  config.quotas,
 	})
 	return nil
 }
@@ -379,6 +421,11 @@ func (h *httpServer) wsAllowed() bool {
 	return h.wsHandler.Load().(*rpcHandler) != nil
 }
 
+// isGRPC checks whether an http request is a gRPC call.
+func isGRPC(r *http.Request) bool {
+	return rpc.IsGRPCRequest(r)
+}
+
 // isWebsocket checks the header of an http request for a websocket upgrade request.
 func isWebsocket(r *http.Request) bool {
 	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket") &&
@@ -396,6 +443,16 @@ func NewHTTPHandlerStack(srv http.Handler, cors []string, vhosts []string, jwtSe
 	return newGzipHandler(handler)
 }
 
+// NewGRPCHandlerStack returns a wrapped grpc-related handler. CORS doesn't apply,
+// as browsers can't make gRPC calls.
+func NewGRPCHandlerStack(srv http.Handler, vhosts []string, jwtSecret []byte) http.Handler {
+	handler := newVHostHandler(vhosts, srv)
+	if len(jwtSecret) != 0 {
+		handler = newJWTHandler(jwtSecret, handler)
+	}
+	return handler
+}
+
 // NewWSHandlerStack returns a wrapped ws-related handler.
 func NewWSHandlerStack(srv http.Handler, jwtSecret []byte) http.Handler {
 	if len(jwtSecret) != 0 {
@@ -564,7 +621,7 @@ func (w *gzipResponseWriter) close() {
 
 func newGzipHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") || isWebsocket(r) || isGRPC(r) {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -638,6 +695,7 @@ func RegisterApis(apis []rpc.API, modules []string, srv *rpc.Server) error {
 			if err := srv.RegisterName(api.Namespace, api.Service); err != nil {
 				return err
 			}
+			srv.RegisterDocs(api.Namespace, api.Docs)
 		}
 	}
 	return nil
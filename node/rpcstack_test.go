@@ -18,6 +18,7 @@ package node
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -596,6 +597,33 @@ func TestHTTPWriteTimeout(t *testing.T) {
 	})
 }
 
+// TestGRPC checks that the gRPC transport is served next to plain JSON-RPC.
+func TestGRPC(t *testing.T) {
+	srv := createAndStartServer(t, &httpConfig{Modules: []string{"test"}, GRPC: true}, false, &wsConfig{}, nil)
+	defer srv.stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client, err := rpc.DialContext(ctx, "grpc://"+srv.listenAddr())
+	if err != nil {
+		t.Fatalf("can't dial: %v", err)
+	}
+	defer client.Close()
+
+	var greeting string
+	if err := client.CallContext(ctx, &greeting, "test_greet"); err != nil {
+		t.Fatalf("gRPC call failed: %v", err)
+	}
+	if greeting != "Hello" {
+		t.Errorf("wrong response: have %q, want %q", greeting, "Hello")
+	}
+	resp := rpcRequest(t, "http://"+srv.listenAddr(), "test_greet")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("HTTP request failed: status %d", resp.StatusCode)
+	}
+}
+
 func apis() []rpc.API {
 	return []rpc.API{
 		{
@@ -18,6 +18,7 @@ package rpc
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
@@ -41,6 +42,8 @@ type clientConfig struct {
 	idgen              func() ID
 	batchItemLimit     int
 	batchResponseLimit int
+	callFilter         CallFilter
+	slowQueryThreshold time.Duration
 }
 
 func (cfg *clientConfig) initHeaders() {
@@ -0,0 +1,497 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The gRPC transport carries JSON-RPC messages in the following service, each
+// message holding a single JSON-RPC request, response, batch or notification:
+//
+//	syntax = "proto3";
+//	package polarys.rpc.v1;
+//
+//	service JSONRPC {
+//	  // Call serves a request or a batch, like JSON-RPC over HTTP.
+//	  rpc Call(Message) returns (Message);
+//	  // Subscribe opens a session carrying messages in both directions, like a
+//	  // WebSocket connection, which supports subscriptions.
+//	  rpc Subscribe(stream Message) returns (stream Message);
+//	}
+//
+//	message Message {
+//	  bytes json = 1;
+//	}
+const (
+	grpcService       = "polarys.rpc.v1.JSONRPC"
+	grpcCallPath      = "/" + grpcService + "/Call"
+	grpcSubscribePath = "/" + grpcService + "/Subscribe"
+	grpcContentType   = "application/grpc"
+
+	grpcDefaultReadLimit = 32 * 1024 * 1024 // Maximum size of the messages read by the clients
+)
+
+// gRPC status codes, see https://grpc.github.io/grpc/core/md_doc_statuscodes.html.
+const (
+	grpcStatusOK                = 0
+	grpcStatusInvalidArgument   = 3
+	grpcStatusResourceExhausted = 8
+	grpcStatusUnimplemented     = 12
+	grpcStatusInternal          = 13
+	grpcStatusUnavailable       = 14
+)
+
+// grpcError is a gRPC status other than OK.
+type grpcError struct {
+	code    int
+	message string
+}
+
+func (e *grpcError) Error() string {
+	return fmt.Sprintf("grpc status %d: %s", e.code, e.message)
+}
+
+// IsGRPCRequest reports whether an HTTP request is a gRPC call.
+func IsGRPCRequest(r *http.Request) bool {
+	mt := r.Header.Get("content-type")
+	return mt == grpcContentType || strings.HasPrefix(mt, grpcContentType+"+") || strings.HasPrefix(mt, grpcContentType+";")
+}
+
+// GRPCHandler returns a handler that serves JSON-RPC over gRPC. As gRPC requires
+// HTTP/2, the handler must be served over TLS or through an h2c handler.
+func (s *Server) GRPCHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			http.Error(w, "gRPC requires HTTP/2", http.StatusHTTPVersionNotSupported)
+			return
+		}
+		if r.Method != http.MethodPost || !IsGRPCRequest(r) {
+			http.Error(w, "invalid gRPC request", http.StatusUnsupportedMediaType)
+			return
+		}
+		if mt := r.Header.Get("content-type"); mt != grpcContentType && !strings.HasPrefix(mt, grpcContentType+"+proto") {
+			writeGRPCStatus(w, grpcStatusUnimplemented, "only protobuf messages are supported")
+			return
+		}
+		w.Header().Set("content-type", grpcContentType)
+		if !s.run.Load() {
+			writeGRPCStatus(w, grpcStatusUnavailable, "server is stopped")
+			return
+		}
+		switch r.URL.Path {
+		case grpcCallPath:
+			s.serveGRPCCall(w, r)
+		case grpcSubscribePath:
+			s.serveGRPCSubscribe(w, r)
+		default:
+			writeGRPCStatus(w, grpcStatusUnimplemented, "unknown method "+r.URL.Path)
+		}
+	})
+}
+
+// serveGRPCCall serves a single request or batch, subscriptions aren't allowed.
+func (s *Server) serveGRPCCall(w http.ResponseWriter, r *http.Request) {
+	payload, err := readGRPCMessage(r.Body, maxRequestContentLength)
+	if err != nil {
+		writeGRPCError(w, err)
+		return
+	}
+	var (
+		resp  bytes.Buffer
+		conn  = &grpcCallConn{Reader: bytes.NewReader(payload), Writer: &resp, remote: r.RemoteAddr}
+		codec = NewCodec(conn)
+		ctx   = context.WithValue(r.Context(), peerInfoContextKey{}, grpcPeerInfo(r))
+	)
+	ctx = httpRequestContext(ctx, w, r)
+	defer codec.close()
+	s.serveSingleRequest(ctx, codec)
+
+	if _, err := w.Write(encodeGRPCMessage(bytes.TrimSpace(resp.Bytes()))); err != nil {
+		return
+	}
+	writeGRPCStatus(w, grpcStatusOK, "")
+}
+
+// serveGRPCSubscribe serves a session until the client closes its stream.
+func (s *Server) serveGRPCSubscribe(w http.ResponseWriter, r *http.Request) {
+	// Like WebSocket connections, the sessions outlive the HTTP timeouts
+	ctrl := http.NewResponseController(w)
+	ctrl.SetReadDeadline(time.Time{})
+	ctrl.SetWriteDeadline(time.Time{})
+
+	w.WriteHeader(http.StatusOK)
+	if err := ctrl.Flush(); err != nil {
+		return
+	}
+	stream := &grpcStream{
+		r:         r.Body,
+		w:         w,
+		flush:     ctrl.Flush,
+		close:     func() { r.Body.Close() },
+		readLimit: maxRequestContentLength,
+	}
+	s.ServeCodec(newGRPCCodec(stream, grpcPeerInfo(r)), 0)
+
+	// Stop writing before the handler returns
+	stream.Close()
+	writeGRPCStatus(w, grpcStatusOK, "")
+}
+
+// grpcPeerInfo describes the client of a gRPC call.
+func grpcPeerInfo(r *http.Request) PeerInfo {
+	info := PeerInfo{Transport: "grpc", RemoteAddr: r.RemoteAddr}
+	info.HTTP.Version = r.Proto
+	info.HTTP.Host = r.Host
+	info.HTTP.UserAgent = r.Header.Get("User-Agent")
+	info.Caller = callerFromContext(r.Context())
+	return info
+}
+
+// grpcCallConn turns a unary gRPC call into a Conn.
+type grpcCallConn struct {
+	io.Reader
+	io.Writer
+	remote string
+}
+
+// Close does nothing and always returns nil.
+func (c *grpcCallConn) Close() error { return nil }
+
+// RemoteAddr returns the peer address of the call.
+func (c *grpcCallConn) RemoteAddr() string { return c.remote }
+
+// SetWriteDeadline does nothing and always returns nil.
+func (c *grpcCallConn) SetWriteDeadline(time.Time) error { return nil }
+
+// grpcStream reads and writes the messages of a gRPC stream. Writes fail once
+// the stream is closed.
+type grpcStream struct {
+	r         io.Reader
+	w         io.Writer
+	flush     func() error
+	close     func() // Interrupts the pending reads and writes
+	readLimit int
+
+	closeOnce sync.Once
+	mu        sync.Mutex // Held while writing
+	closed    bool
+}
+
+func (s *grpcStream) readMessage() ([]byte, error) {
+	return readGRPCMessage(s.r, s.readLimit)
+}
+
+func (s *grpcStream) writeMessage(payload []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return net.ErrClosed
+	}
+	if _, err := s.w.Write(encodeGRPCMessage(payload)); err != nil {
+		return err
+	}
+	if s.flush != nil {
+		return s.flush()
+	}
+	return nil
+}
+
+// Close stops the stream, interrupting any pending read, and waits for the
+// pending write to end.
+func (s *grpcStream) Close() error {
+	s.closeOnce.Do(s.close)
+
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	return nil
+}
+
+// SetWriteDeadline does nothing and always returns nil, the writes are bounded
+// by the HTTP/2 flow control.
+func (s *grpcStream) SetWriteDeadline(time.Time) error { return nil }
+
+// grpcCodec carries a JSON-RPC message in each message of a gRPC stream.
+type grpcCodec struct {
+	*jsonCodec
+	info PeerInfo
+}
+
+func newGRPCCodec(stream *grpcStream, info PeerInfo) ServerCodec {
+	encode := func(v interface{}, isErrorResponse bool) error {
+		payload, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		return stream.writeMessage(payload)
+	}
+	decode := func(v interface{}) error {
+		payload, err := stream.readMessage()
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(payload, v)
+	}
+	codec := &grpcCodec{
+		jsonCodec: NewFuncCodec(stream, encode, decode).(*jsonCodec),
+		info:      info,
+	}
+	codec.remote = info.RemoteAddr
+	return codec
+}
+
+func (c *grpcCodec) peerInfo() PeerInfo {
+	return c.info
+}
+
+// encodeGRPCMessage frames a JSON payload as an uncompressed gRPC message.
+func encodeGRPCMessage(payload []byte) []byte {
+	msg := protowire.AppendTag(nil, 1, protowire.BytesType)
+	msg = protowire.AppendBytes(msg, payload)
+
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	return append(frame, msg...)
+}
+
+// readGRPCMessage reads a gRPC message, returning its JSON payload. The end of
+// the stream is reported as io.EOF.
+func readGRPCMessage(r io.Reader, limit int) ([]byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, &grpcError{grpcStatusInvalidArgument, "truncated message"}
+		}
+		return nil, err
+	}
+	if header[0] != 0 {
+		return nil, &grpcError{grpcStatusUnimplemented, "compressed messages are not supported"}
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if limit > 0 && size > uint32(limit) {
+		return nil, &grpcError{grpcStatusResourceExhausted, fmt.Sprintf("message too large (%d>%d)", size, limit)}
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, &grpcError{grpcStatusInvalidArgument, "truncated message"}
+		}
+		return nil, err
+	}
+	// Extract the payload, skipping any unknown field
+	var payload []byte
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			return nil, &grpcError{grpcStatusInvalidArgument, "invalid message"}
+		}
+		msg = msg[n:]
+		if num == 1 && typ == protowire.BytesType {
+			payload, n = protowire.ConsumeBytes(msg)
+		} else {
+			n = protowire.ConsumeFieldValue(num, typ, msg)
+		}
+		if n < 0 {
+			return nil, &grpcError{grpcStatusInvalidArgument, "invalid message"}
+		}
+		msg = msg[n:]
+	}
+	return payload, nil
+}
+
+// writeGRPCError ends a call with the status of an error.
+func writeGRPCError(w http.ResponseWriter, err error) {
+	var status *grpcError
+	if errors.As(err, &status) {
+		writeGRPCStatus(w, status.code, status.message)
+		return
+	}
+	if err == io.EOF {
+		writeGRPCStatus(w, grpcStatusInvalidArgument, "missing message")
+		return
+	}
+	writeGRPCStatus(w, grpcStatusInternal, err.Error())
+}
+
+// writeGRPCStatus ends a call with the given status, sent in the trailers.
+func writeGRPCStatus(w http.ResponseWriter, code int, message string) {
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(code))
+	if message != "" {
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", encodeGRPCStatusMessage(message))
+	}
+}
+
+// encodeGRPCStatusMessage percent-encodes the status message as required by
+// the gRPC over HTTP/2 protocol.
+func encodeGRPCStatusMessage(message string) string {
+	var b strings.Builder
+	for i := 0; i < len(message); i++ {
+		if c := message[i]; c >= 0x20 && c <= 0x7e && c != '%' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// checkGRPCStatus returns the error reported by the status of a gRPC response,
+// sent in its headers or trailers.
+func checkGRPCStatus(header http.Header) error {
+	status := header.Get("Grpc-Status")
+	if status == "" || status == strconv.Itoa(grpcStatusOK) {
+		return nil
+	}
+	code, err := strconv.Atoi(status)
+	if err != nil {
+		return fmt.Errorf("invalid grpc status %q", status)
+	}
+	message, err := url.PathUnescape(header.Get("Grpc-Message"))
+	if err != nil {
+		message = header.Get("Grpc-Message")
+	}
+	return &grpcError{code, message}
+}
+
+// DialGRPC creates a new RPC client that communicates with a JSON-RPC server
+// over gRPC. The endpoint has the grpc scheme for HTTP/2 over cleartext TCP,
+// or the grpcs one for HTTP/2 over TLS.
+//
+// The context is used for the initial connection establishment. It does not
+// affect subsequent interactions with the client.
+func DialGRPC(ctx context.Context, endpoint string) (*Client, error) {
+	cfg := new(clientConfig)
+	connect, err := newClientTransportGRPC(endpoint, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return newClient(ctx, cfg, connect)
+}
+
+func newClientTransportGRPC(endpoint string, cfg *clientConfig) (reconnectFunc, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	transport := new(http2.Transport)
+	switch u.Scheme {
+	case "grpc":
+		u.Scheme = "http"
+		transport.AllowHTTP = true
+		transport.DialTLSContext = func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, addr)
+		}
+	case "grpcs":
+		u.Scheme = "https"
+	default:
+		return nil, fmt.Errorf("no known gRPC transport for URL scheme %q", u.Scheme)
+	}
+	u.Path = grpcSubscribePath
+	target := u.String()
+
+	connect := func(ctx context.Context) (ServerCodec, error) {
+		header := cfg.httpHeaders.Clone()
+		if header == nil {
+			header = make(http.Header)
+		}
+		header.Set("content-type", grpcContentType)
+		header.Set("te", "trailers")
+		if cfg.httpAuth != nil {
+			if err := cfg.httpAuth(header); err != nil {
+				return nil, err
+			}
+		}
+		// The session outlives the dial context, which only bounds the handshake
+		sessionCtx, cancel := context.WithCancel(context.Background())
+		body, pipe := io.Pipe()
+		req, err := http.NewRequestWithContext(sessionCtx, http.MethodPost, target, body)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		req.Header = header
+
+		type result struct {
+			resp *http.Response
+			err  error
+		}
+		done := make(chan result, 1)
+		go func() {
+			resp, err := transport.RoundTrip(req)
+			done <- result{resp, err}
+		}()
+		var res result
+		select {
+		case res = <-done:
+		case <-ctx.Done():
+			cancel()
+			pipe.Close()
+			return nil, ctx.Err()
+		}
+		if res.err == nil {
+			switch {
+			case res.resp.StatusCode != http.StatusOK:
+				res.err = fmt.Errorf("grpc handshake failed: %s", res.resp.Status)
+			case !strings.HasPrefix(res.resp.Header.Get("content-type"), grpcContentType):
+				res.err = fmt.Errorf("grpc handshake failed: unexpected content type %q", res.resp.Header.Get("content-type"))
+			default:
+				res.err = checkGRPCStatus(res.resp.Header)
+			}
+			if res.err != nil {
+				res.resp.Body.Close()
+			}
+		}
+		if res.err != nil {
+			cancel()
+			pipe.Close()
+			return nil, res.err
+		}
+		stream := &grpcStream{
+			r: res.resp.Body,
+			w: pipe,
+			close: func() {
+				pipe.Close()
+				res.resp.Body.Close()
+				cancel()
+				transport.CloseIdleConnections()
+			},
+			readLimit: grpcDefaultReadLimit,
+		}
+		return newGRPCCodec(stream, PeerInfo{Transport: "grpc", RemoteAddr: endpoint}), nil
+	}
+	return connect, nil
+}
@@ -0,0 +1,194 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// newGRPCTestServer serves the test services over gRPC with h2c.
+func newGRPCTestServer(t *testing.T) (*Server, *httptest.Server) {
+	t.Helper()
+
+	server := newTestServer()
+	httpsrv := httptest.NewServer(h2c.NewHandler(server.GRPCHandler(), new(http2.Server)))
+	t.Cleanup(func() {
+		httpsrv.Close()
+		server.Stop()
+	})
+	return server, httpsrv
+}
+
+func TestGRPCClient(t *testing.T) {
+	_, httpsrv := newGRPCTestServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client, err := DialContext(ctx, "grpc://"+httpsrv.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("can't dial: %v", err)
+	}
+	defer client.Close()
+
+	// Plain calls and batches
+	var result echoResult
+	if err := client.CallContext(ctx, &result, "test_echo", "hello", 10, &echoArgs{"world"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := (echoResult{"hello", 10, &echoArgs{"world"}}); result.String != want.String || result.Int != want.Int || result.Args.S != want.Args.S {
+		t.Errorf("echo mismatch: have %+v, want %+v", result, want)
+	}
+	batch := []BatchElem{
+		{Method: "test_echo", Args: []interface{}{"a", 1, nil}, Result: new(echoResult)},
+		{Method: "no_such_method", Result: new(string)},
+	}
+	if err := client.BatchCallContext(ctx, batch); err != nil {
+		t.Fatal(err)
+	}
+	if batch[0].Error != nil || batch[0].Result.(*echoResult).String != "a" {
+		t.Errorf("batch element 0 mismatch: %+v", batch[0])
+	}
+	if batch[1].Error == nil {
+		t.Errorf("batch element 1 succeeded")
+	}
+	var info PeerInfo
+	if err := client.CallContext(ctx, &info, "test_peerInfo"); err != nil {
+		t.Fatal(err)
+	}
+	if info.Transport != "grpc" || info.HTTP.Version != "HTTP/2.0" {
+		t.Errorf("peer info mismatch: %+v", info)
+	}
+
+	// Subscriptions are carried by the stream
+	nc := make(chan int)
+	sub, err := client.Subscribe(ctx, "nftest", nc, "someSubscription", 5, 0)
+	if err != nil {
+		t.Fatalf("can't subscribe: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		select {
+		case val := <-nc:
+			if val != i {
+				t.Fatalf("value mismatch: have %d, want %d", val, i)
+			}
+		case err := <-sub.Err():
+			t.Fatalf("subscription failed: %v", err)
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for notifications")
+		}
+	}
+	sub.Unsubscribe()
+}
+
+func TestGRPCUnaryCall(t *testing.T) {
+	_, httpsrv := newGRPCTestServer(t)
+
+	transport := &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}
+	defer transport.CloseIdleConnections()
+
+	call := func(path string, body []byte) (*http.Response, []byte) {
+		req, _ := http.NewRequest(http.MethodPost, httpsrv.URL+path, bytes.NewReader(body))
+		req.Header.Set("content-type", "application/grpc+proto")
+		req.Header.Set("te", "trailers")
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("call failed: %v", err)
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("can't read response: %v", err)
+		}
+		return resp, data
+	}
+	resp, data := call(grpcCallPath, encodeGRPCMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["x",1]}`)))
+	if err := checkGRPCStatus(resp.Trailer); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	payload, err := readGRPCMessage(bytes.NewReader(data), 0)
+	if err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if want := `{"jsonrpc":"2.0","id":1,"result":{"String":"x","Int":1,"Args":null}}`; string(payload) != want {
+		t.Errorf("response mismatch:\nhave %s\nwant %s", payload, want)
+	}
+
+	// Subscriptions need a stream
+	_, data = call(grpcCallPath, encodeGRPCMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"nftest_subscribe","params":["someSubscription",1,0]}`)))
+	if payload, _ := readGRPCMessage(bytes.NewReader(data), 0); !strings.Contains(string(payload), "notifications not supported") {
+		t.Errorf("subscription allowed in unary call: %s", payload)
+	}
+
+	// Failures are reported in the status
+	for _, test := range []struct {
+		path string
+		body []byte
+		code string
+	}{
+		{"/polarys.rpc.v1.JSONRPC/Unknown", encodeGRPCMessage(nil), "12"},
+		{grpcCallPath, nil, "3"},
+		{grpcCallPath, []byte{1, 0, 0, 0, 0}, "12"},
+		{grpcCallPath, []byte{0, 0xff, 0xff, 0xff, 0xff}, "8"},
+	} {
+		resp, _ := call(test.path, test.body)
+		if code := resp.Trailer.Get("Grpc-Status"); code != test.code {
+			t.Errorf("%s %x: status mismatch: have %s, want %s", test.path, test.body, code, test.code)
+		}
+	}
+}
+
+func TestGRPCMessageEncoding(t *testing.T) {
+	payload := []byte(`{"jsonrpc":"2.0"}`)
+	frame := encodeGRPCMessage(payload)
+
+	// A message with an unknown field before the payload
+	msg := append([]byte{0x10, 0x05}, frame[5:]...)
+	msg = append([]byte{0, 0, 0, 0, byte(len(msg))}, msg...)
+
+	for _, input := range [][]byte{frame, msg} {
+		have, err := readGRPCMessage(bytes.NewReader(input), 0)
+		if err != nil {
+			t.Fatalf("can't read message %x: %v", input, err)
+		}
+		if !bytes.Equal(have, payload) {
+			t.Errorf("payload mismatch: have %s, want %s", have, payload)
+		}
+	}
+	if _, err := readGRPCMessage(bytes.NewReader(frame[:len(frame)-1]), 0); err == nil {
+		t.Error("truncated message accepted")
+	}
+	if have := encodeGRPCStatusMessage("bad 100% ü"); have != "bad 100%25 %C3%BC" {
+		t.Errorf("status message mismatch: %s", have)
+	}
+}
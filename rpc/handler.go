@@ -25,6 +25,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ethereum/go-ethereum/internal/tracing"
 	"github.com/ethereum/go-ethereum/log"
 )
 
@@ -62,6 +63,8 @@ type handler struct {
 	allowSubscribe       bool
 	batchRequestLimit    int
 	batchResponseMaxSize int
+	callFilter           CallFilter    // consulted before serving calls, may be nil
+	slowQueryThreshold   time.Duration // calls taking longer are logged, disabled if zero
 
 	subLock    sync.Mutex
 	serverSubs map[ID]*Subscription
@@ -458,18 +461,17 @@ func (h *handler) handleSubscriptionResult(msg *jsonrpcMessage) {
 }
 
 // handleCallMsg executes a call message and returns the answer.
-func (h *handler) handleCallMsg(ctx *callProc, msg *jsonrpcMessage) *jsonrpcMessage {
-	start := time.Now()
+func (h *handler) handleCallMsg(cp *callProc, msg *jsonrpcMessage) *jsonrpcMessage {
 	switch {
 	case msg.isNotification():
-		h.handleCall(ctx, msg)
-		h.log.Debug("Served "+msg.Method, "duration", time.Since(start))
+		_, rid, elapsed := h.serveCall(cp, msg)
+		h.log.Debug("Served "+msg.Method, "request", rid, "duration", elapsed)
 		return nil
 
 	case msg.isCall():
-		resp := h.handleCall(ctx, msg)
+		resp, rid, elapsed := h.serveCall(cp, msg)
 		var ctx []interface{}
-		ctx = append(ctx, "reqid", idForLog{msg.ID}, "duration", time.Since(start))
+		ctx = append(ctx, "reqid", idForLog{msg.ID}, "request", rid, "duration", elapsed)
 		if resp.Error != nil {
 			ctx = append(ctx, "err", resp.Error.Message)
 			if resp.Error.Data != nil {
@@ -489,8 +491,54 @@ func (h *handler) handleCallMsg(ctx *callProc, msg *jsonrpcMessage) *jsonrpcMess
 	}
 }
 
+// serveCall runs a call within its own request and span, and reports it to the
+// slow query log if it took longer than the threshold.
+func (h *handler) serveCall(cp *callProc, msg *jsonrpcMessage) (*jsonrpcMessage, string, time.Duration) {
+	start := time.Now()
+	ctx, rid := withRequestID(cp.ctx)
+	ctx, span := tracing.StartSpan(ctx, msg.Method,
+		tracing.String("rpc.system", "jsonrpc"),
+		tracing.String("rpc.service", msg.namespace()),
+		tracing.String("rpc.method", msg.Method),
+		tracing.String("rpc.request_id", rid),
+	)
+	if span != nil {
+		peer := PeerInfoFromContext(ctx)
+		span.SetKind(tracing.SpanKindServer)
+		span.SetAttributes(tracing.String("rpc.transport", peer.Transport), tracing.String("client.address", peer.RemoteAddr))
+	}
+	// The call gets its own context, but the notifiers it creates must still be
+	// activated by the caller.
+	call := &callProc{ctx: ctx}
+	resp := h.handleCall(call, msg)
+	cp.notifiers = append(cp.notifiers, call.notifiers...)
+	elapsed := time.Since(start)
+
+	size := len(resp.Result)
+	if resp.Error != nil {
+		span.SetAttributes(tracing.Int("rpc.jsonrpc.error_code", resp.Error.Code))
+		span.SetError(resp.Error)
+	}
+	span.SetAttributes(tracing.Int("rpc.response_size", size))
+	span.End()
+
+	if h.slowQueryThreshold > 0 && elapsed >= h.slowQueryThreshold {
+		ctx := []interface{}{"method", msg.Method, "request", rid, "params", paramsDigest(msg.Params), "duration", elapsed, "size", size}
+		if span != nil {
+			ctx = append(ctx, "trace", span.TraceID())
+		}
+		h.log.Warn("Slow RPC call", ctx...)
+	}
+	return resp, rid, elapsed
+}
+
 // handleCall processes method calls.
 func (h *handler) handleCall(cp *callProc, msg *jsonrpcMessage) *jsonrpcMessage {
+	if h.callFilter != nil && !msg.isUnsubscribe() {
+		if err := h.callFilter(cp.ctx, msg.Method, msg.Params); err != nil {
+			return msg.errorResponse(err)
+		}
+	}
 	if msg.isSubscribe() {
 		return h.handleSubscribe(cp, msg)
 	}
@@ -0,0 +1,404 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const (
+	openRPCVersion = "1.2.6" // Version of the OpenRPC specification the documents follow
+	openRPCTitle   = "Polarys JSON-RPC API"
+	schemaRefBase  = "#/components/schemas/"
+)
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// MethodDoc documents an RPC method in the OpenRPC document served by
+// rpc_discover. All the fields are optional.
+type MethodDoc struct {
+	Summary     string   // Short description of the method
+	Description string   // Detailed description of the method
+	Params      []string // Names of the parameters, derived from their types if missing
+	Result      string   // Name of the result, "result" if missing
+	Deprecated  bool     // Whether the method is deprecated
+}
+
+// OpenRPCDocument describes the methods served by the server, following the
+// OpenRPC specification.
+type OpenRPCDocument struct {
+	OpenRPC    string            `json:"openrpc"`
+	Info       OpenRPCInfo       `json:"info"`
+	Methods    []*OpenRPCMethod  `json:"methods"`
+	Components OpenRPCComponents `json:"components"`
+}
+
+// OpenRPCInfo is the metadata of an OpenRPC document.
+type OpenRPCInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// OpenRPCMethod describes a method of an OpenRPC document.
+type OpenRPCMethod struct {
+	Name           string            `json:"name"`
+	Summary        string            `json:"summary,omitempty"`
+	Description    string            `json:"description,omitempty"`
+	Params         []*OpenRPCContent `json:"params"`
+	Result         *OpenRPCContent   `json:"result"`
+	Deprecated     bool              `json:"deprecated,omitempty"`
+	ParamStructure string            `json:"paramStructure"`
+}
+
+// OpenRPCContent describes a parameter or the result of a method.
+type OpenRPCContent struct {
+	Name     string      `json:"name"`
+	Required bool        `json:"required,omitempty"`
+	Schema   *JSONSchema `json:"schema"`
+}
+
+// OpenRPCComponents holds the schemas of the named types, which are referenced
+// by the methods.
+type OpenRPCComponents struct {
+	Schemas map[string]*JSONSchema `json:"schemas"`
+}
+
+// JSONSchema is the subset of JSON Schema describing the RPC values.
+type JSONSchema struct {
+	Ref                  string                 `json:"$ref,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *JSONSchema            `json:"additionalProperties,omitempty"`
+	OneOf                []*JSONSchema          `json:"oneOf,omitempty"`
+}
+
+var (
+	quantitySchema = &JSONSchema{Title: "hex encoded unsigned integer", Type: "string", Pattern: "^0x(0|[1-9a-f][0-9a-f]*)$"}
+	bytesSchema    = &JSONSchema{Title: "hex encoded bytes", Type: "string", Pattern: "^0x[0-9a-f]*$"}
+	addressSchema  = &JSONSchema{Title: "hex encoded address", Type: "string", Pattern: "^0x[0-9a-fA-F]{40}$"}
+	hashSchema     = &JSONSchema{Title: "32 byte hex encoded hash", Type: "string", Pattern: "^0x[0-9a-f]{64}$"}
+
+	blockNumberSchema = &JSONSchema{
+		Title: "block number or tag",
+		OneOf: []*JSONSchema{
+			quantitySchema,
+			{Title: "block tag", Type: "string", Enum: []string{"earliest", "latest", "pending", "safe", "finalized"}},
+		},
+	}
+	blockNumberOrHashSchema = &JSONSchema{
+		Title: "block number, tag or hash",
+		OneOf: []*JSONSchema{
+			blockNumberSchema,
+			hashSchema,
+			{
+				Title: "block number or hash",
+				Type:  "object",
+				Properties: map[string]*JSONSchema{
+					"blockNumber":      blockNumberSchema,
+					"blockHash":        hashSchema,
+					"requireCanonical": {Type: "boolean"},
+				},
+			},
+		},
+	}
+)
+
+// knownSchemas are the schemas of the types whose JSON encoding can't be derived
+// from their Go definition.
+var knownSchemas = map[reflect.Type]*JSONSchema{
+	reflect.TypeOf(hexutil.Big{}):       quantitySchema,
+	reflect.TypeOf(hexutil.Uint64(0)):   quantitySchema,
+	reflect.TypeOf(hexutil.Uint(0)):     quantitySchema,
+	reflect.TypeOf(hexutil.Bytes{}):     bytesSchema,
+	reflect.TypeOf(common.Address{}):    addressSchema,
+	reflect.TypeOf(common.Hash{}):       hashSchema,
+	reflect.TypeOf(big.Int{}):           {Type: "integer"},
+	reflect.TypeOf(json.RawMessage{}):   {},
+	reflect.TypeOf(BlockNumber(0)):      blockNumberSchema,
+	reflect.TypeOf(BlockNumberOrHash{}): blockNumberOrHashSchema,
+	reflect.TypeOf(ID("")):              {Title: "subscription identifier", Type: "string"},
+}
+
+// RegisterDocs documents the methods of a service in the OpenRPC document served
+// by rpc_discover. The documentation is keyed by method name, without the
+// service name, and may be registered before or after the methods.
+func (s *Server) RegisterDocs(name string, docs map[string]MethodDoc) {
+	s.services.registerDocs(name, docs)
+}
+
+// Discover returns the OpenRPC document describing the methods of the server.
+// Subscriptions are not listed, they are created through the subscribe method
+// of their service.
+func (s *RPCService) Discover() *OpenRPCDocument {
+	return s.server.services.openRPC()
+}
+
+func (r *serviceRegistry) registerDocs(name string, docs map[string]MethodDoc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.docs == nil {
+		r.docs = make(map[string]MethodDoc)
+	}
+	for method, doc := range docs {
+		r.docs[name+serviceMethodSeparator+method] = doc
+	}
+}
+
+// openRPC builds the OpenRPC document of the registered methods, deriving the
+// schemas of their parameters and results from the Go types.
+func (r *serviceRegistry) openRPC() *OpenRPCDocument {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var (
+		builder = newSchemaBuilder()
+		names   []string
+		methods = make(map[string]*callback)
+	)
+	for _, svc := range r.services {
+		for name, cb := range svc.callbacks {
+			name = svc.name + serviceMethodSeparator + name
+			names = append(names, name)
+			methods[name] = cb
+		}
+	}
+	sort.Strings(names)
+
+	doc := &OpenRPCDocument{
+		OpenRPC: openRPCVersion,
+		Info:    OpenRPCInfo{Title: openRPCTitle, Version: "1.0"},
+		Methods: make([]*OpenRPCMethod, 0, len(names)),
+	}
+	for _, name := range names {
+		doc.Methods = append(doc.Methods, builder.method(name, methods[name], r.docs[name]))
+	}
+	doc.Components.Schemas = builder.schemas
+	return doc
+}
+
+// schemaBuilder derives the JSON schemas of Go types, collecting the schemas of
+// the named structs as components.
+type schemaBuilder struct {
+	schemas map[string]*JSONSchema // Schemas of the named structs, by component name
+	names   map[reflect.Type]string
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{
+		schemas: make(map[string]*JSONSchema),
+		names:   make(map[reflect.Type]string),
+	}
+}
+
+// method describes a method. The trailing pointer parameters are optional, as
+// the server fills the missing ones with nil.
+func (b *schemaBuilder) method(name string, cb *callback, doc MethodDoc) *OpenRPCMethod {
+	m := &OpenRPCMethod{
+		Name:           name,
+		Summary:        doc.Summary,
+		Description:    doc.Description,
+		Params:         make([]*OpenRPCContent, len(cb.argTypes)),
+		Deprecated:     doc.Deprecated,
+		ParamStructure: "by-position",
+	}
+	optional := len(cb.argTypes)
+	for optional > 0 && cb.argTypes[optional-1].Kind() == reflect.Ptr {
+		optional--
+	}
+	used := make(map[string]bool)
+	for i, typ := range cb.argTypes {
+		var param string
+		if i < len(doc.Params) {
+			param = doc.Params[i]
+		} else {
+			param = paramName(typ, i, used)
+		}
+		used[param] = true
+		m.Params[i] = &OpenRPCContent{Name: param, Required: i < optional, Schema: b.schema(typ)}
+	}
+	result := doc.Result
+	if result == "" {
+		result = "result"
+	}
+	m.Result = &OpenRPCContent{Name: result, Schema: &JSONSchema{Type: "null"}}
+	if fntype := cb.fn.Type(); fntype.NumOut() > 0 && cb.errPos != 0 {
+		m.Result.Schema = b.schema(fntype.Out(0))
+	}
+	return m
+}
+
+// paramName derives the name of an undocumented parameter from its type, falling
+// back to its position if the type is unnamed or named another parameter already.
+func paramName(typ reflect.Type, index int, used map[string]bool) string {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	// Predeclared types don't make meaningful names
+	if name := typ.Name(); name != "" && typ.PkgPath() != "" {
+		if name = formatName(name); !used[name] {
+			return name
+		}
+	}
+	return fmt.Sprintf("param%d", index+1)
+}
+
+// schema derives the schema of a type from its JSON encoding.
+func (b *schemaBuilder) schema(typ reflect.Type) *JSONSchema {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if schema, ok := knownSchemas[typ]; ok {
+		return schema
+	}
+	var (
+		ptr        = reflect.PointerTo(typ)
+		marshaler  = typ.Implements(jsonMarshalerType) || ptr.Implements(jsonMarshalerType)
+		textMarshl = typ.Implements(textMarshalerType) || ptr.Implements(textMarshalerType)
+	)
+	switch {
+	case textMarshl && !marshaler:
+		return &JSONSchema{Type: "string"}
+	case marshaler && (typ.Kind() != reflect.Struct || !hasExportedFields(typ)):
+		return &JSONSchema{}
+	}
+	switch typ.Kind() {
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	case reflect.Slice:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return &JSONSchema{Title: "base64 encoded bytes", Type: "string"}
+		}
+		return &JSONSchema{Type: "array", Items: b.schema(typ.Elem())}
+	case reflect.Array:
+		return &JSONSchema{Type: "array", Items: b.schema(typ.Elem())}
+	case reflect.Map:
+		return &JSONSchema{Type: "object", AdditionalProperties: b.schema(typ.Elem())}
+	case reflect.Struct:
+		if typ.Name() == "" {
+			return b.structSchema(typ)
+		}
+		return b.ref(typ)
+	default:
+		return &JSONSchema{}
+	}
+}
+
+// ref returns a reference to the component schema of a named struct, adding the
+// component if missing. The types are named after their package to avoid clashes.
+func (b *schemaBuilder) ref(typ reflect.Type) *JSONSchema {
+	name, ok := b.names[typ]
+	if !ok {
+		name = strings.NewReplacer("[", "_", "]", "", "*", "", "/", ".", ",", "_", " ", "").Replace(path.Base(typ.PkgPath()) + "." + typ.Name())
+		for i := 2; b.schemas[name] != nil; i++ {
+			name = fmt.Sprintf("%s.%s%d", path.Base(typ.PkgPath()), typ.Name(), i)
+		}
+		// Register the name before the fields, which may refer to the type
+		b.names[typ] = name
+		b.schemas[name] = &JSONSchema{}
+
+		schema := b.structSchema(typ)
+		schema.Title = typ.Name()
+		b.schemas[name] = schema
+	}
+	return &JSONSchema{Ref: schemaRefBase + name}
+}
+
+// structSchema derives the schema of a struct from its fields, following the
+// encoding/json rules. The structs with a custom encoding, usually generated by
+// gencodec, are described by their fields too, as their JSON names match.
+func (b *schemaBuilder) structSchema(typ reflect.Type) *JSONSchema {
+	schema := &JSONSchema{Type: "object", Properties: make(map[string]*JSONSchema)}
+	b.addFields(schema, typ)
+	return schema
+}
+
+func (b *schemaBuilder) addFields(schema *JSONSchema, typ reflect.Type) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		ftype := field.Type
+		for ftype.Kind() == reflect.Ptr {
+			ftype = ftype.Elem()
+		}
+		if field.Anonymous && name == "" && ftype.Kind() == reflect.Struct {
+			b.addFields(schema, ftype) // Embedded struct, its fields are promoted
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if _, ok := schema.Properties[name]; ok {
+			continue // Shadowed by an outer field
+		}
+		if strings.Contains(opts, "string") {
+			schema.Properties[name] = &JSONSchema{Type: "string"}
+		} else {
+			schema.Properties[name] = b.schema(field.Type)
+		}
+		required := field.Type.Kind() != reflect.Ptr && !strings.Contains(opts, "omitempty")
+		if field.Tag.Get("gencodec") == "required" {
+			required = true
+		}
+		if required {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+// hasExportedFields reports whether a struct has any exported field, which the
+// custom encodings usually follow.
+func hasExportedFields(typ reflect.Type) bool {
+	for i := 0; i < typ.NumField(); i++ {
+		if typ.Field(i).IsExported() {
+			return true
+		}
+	}
+	return false
+}
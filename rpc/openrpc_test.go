@@ -0,0 +1,124 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+type discoverService struct{}
+
+type discoverEmbedded struct {
+	Block BlockNumber `json:"block"`
+}
+
+type discoverArgs struct {
+	From  *common.Address `json:"from"`
+	Value *hexutil.Big    `json:"value,omitempty"`
+	Data  hexutil.Bytes   `json:"data"`
+	Skip  string          `json:"-"`
+	Next  *discoverArgs   `json:"next,omitempty"`
+	discoverEmbedded
+}
+
+func (s *discoverService) Call(args discoverArgs, block BlockNumberOrHash, overrides *map[common.Address]hexutil.Uint64) (hexutil.Bytes, error) {
+	return nil, nil
+}
+
+func TestDiscover(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+	if err := server.RegisterName("discover", new(discoverService)); err != nil {
+		t.Fatal(err)
+	}
+	server.RegisterDocs("test", map[string]MethodDoc{
+		"echo": {Summary: "Echoes the arguments.", Params: []string{"str", "int"}, Result: "echo"},
+	})
+	client := DialInProc(server)
+	defer client.Close()
+
+	var doc OpenRPCDocument
+	if err := client.Call(&doc, "rpc_discover"); err != nil {
+		t.Fatal(err)
+	}
+	methods := make(map[string]*OpenRPCMethod)
+	for _, method := range doc.Methods {
+		methods[method.Name] = method
+	}
+	for _, name := range []string{"rpc_discover", "rpc_modules", "test_echo", "discover_call"} {
+		if methods[name] == nil {
+			t.Errorf("method %s missing", name)
+		}
+	}
+	if methods["nftest_someSubscription"] != nil {
+		t.Errorf("subscription listed as method")
+	}
+	// Check the documented method, the undocumented parameter falling back to
+	// its type name
+	echo, _ := json.Marshal(methods["test_echo"])
+	want := `{"name":"test_echo","summary":"Echoes the arguments.","params":[` +
+		`{"name":"str","required":true,"schema":{"type":"string"}},` +
+		`{"name":"int","required":true,"schema":{"type":"integer"}},` +
+		`{"name":"echoArgs","schema":{"$ref":"#/components/schemas/rpc.echoArgs"}}],` +
+		`"result":{"name":"echo","schema":{"$ref":"#/components/schemas/rpc.echoResult"}},"paramStructure":"by-position"}`
+	if string(echo) != want {
+		t.Errorf("test_echo mismatch:\nhave %s\nwant %s", echo, want)
+	}
+	if result := methods["test_noArgsRets"].Result.Schema; result.Type != "null" {
+		t.Errorf("empty result mismatch: %+v", result)
+	}
+	// Check the schemas derived from the types
+	call := methods["discover_call"]
+	if have := []string{call.Params[0].Name, call.Params[1].Name, call.Params[2].Name}; !reflect.DeepEqual(have, []string{"discoverArgs", "blockNumberOrHash", "param3"}) {
+		t.Errorf("parameter names mismatch: %v", have)
+	}
+	if !call.Params[1].Required || call.Params[2].Required {
+		t.Errorf("optional parameters mismatch")
+	}
+	if overrides := call.Params[2].Schema; overrides.Type != "object" || overrides.AdditionalProperties.Pattern != quantitySchema.Pattern {
+		t.Errorf("map schema mismatch: %+v", overrides)
+	}
+	if result := call.Result.Schema; result.Pattern != bytesSchema.Pattern {
+		t.Errorf("result schema mismatch: %+v", result)
+	}
+	args := doc.Components.Schemas["rpc.discoverArgs"]
+	if args == nil {
+		t.Fatalf("argument schema missing")
+	}
+	have := make(map[string]string)
+	for name, schema := range args.Properties {
+		have[name] = schema.Ref + schema.Pattern + schema.Title
+	}
+	wantProps := map[string]string{
+		"from":  addressSchema.Pattern + addressSchema.Title,
+		"value": quantitySchema.Pattern + quantitySchema.Title,
+		"data":  bytesSchema.Pattern + bytesSchema.Title,
+		"next":  "#/components/schemas/rpc.discoverArgs",
+		"block": blockNumberSchema.Title,
+	}
+	if !reflect.DeepEqual(have, wantProps) {
+		t.Errorf("properties mismatch:\nhave %v\nwant %v", have, wantProps)
+	}
+	if !reflect.DeepEqual(args.Required, []string{"data", "block"}) {
+		t.Errorf("required properties mismatch: %v", args.Required)
+	}
+}
@@ -18,9 +18,11 @@ package rpc
 
 import (
 	"context"
+	"encoding/json"
 	"io"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/ethereum/go-ethereum/log"
 )
@@ -51,8 +53,15 @@ type Server struct {
 	run                atomic.Bool
 	batchItemLimit     int
 	batchResponseLimit int
+	callFilter         CallFilter
+	slowQueryThreshold time.Duration
 }
 
+// CallFilter is consulted before the server runs a method call or creates a
+// subscription. If it returns an error, the error is sent to the client instead
+// of running the method.
+type CallFilter func(ctx context.Context, method string, params json.RawMessage) error
+
 // NewServer creates a new server instance with no registered handlers.
 func NewServer() *Server {
 	server := &Server{
@@ -78,6 +87,25 @@ func (s *Server) SetBatchLimits(itemLimit, maxResponseSize int) {
 	s.batchResponseLimit = maxResponseSize
 }
 
+// SetCallFilter installs a filter on the method calls served, e.g. to enforce
+// quotas. The filter must be safe for concurrent use.
+//
+// This method should be called before processing any requests via ServeCodec, ServeHTTP,
+// ServeListener etc.
+func (s *Server) SetCallFilter(filter CallFilter) {
+	s.callFilter = filter
+}
+
+// SetSlowQueryThreshold makes the server log the method calls taking longer than
+// the given duration, along with their request ID, a digest of their parameters
+// and their response size. Slow calls aren't logged if the threshold is zero.
+//
+// This method should be called before processing any requests via ServeCodec, ServeHTTP,
+// ServeListener etc.
+func (s *Server) SetSlowQueryThreshold(threshold time.Duration) {
+	s.slowQueryThreshold = threshold
+}
+
 // RegisterName creates a service for the given receiver type under the given name. When no
 // methods on the given receiver match the criteria to be either a RPC method or a
 // subscription an error is returned. Otherwise a new service is created and added to the
@@ -103,6 +131,8 @@ func (s *Server) ServeCodec(codec ServerCodec, options CodecOption) {
 		idgen:              s.idgen,
 		batchItemLimit:     s.batchItemLimit,
 		batchResponseLimit: s.batchResponseLimit,
+		callFilter:         s.callFilter,
+		slowQueryThreshold: s.slowQueryThreshold,
 	}
 	c := initClient(codec, &s.services, cfg)
 	<-codec.closed()
@@ -138,6 +168,8 @@ func (s *Server) serveSingleRequest(ctx context.Context, codec ServerCodec) {
 
 	h := newHandler(ctx, codec, s.idgen, &s.services, s.batchItemLimit, s.batchResponseLimit)
 	h.allowSubscribe = false
+	h.callFilter = s.callFilter
+	h.slowQueryThreshold = s.slowQueryThreshold
 	defer h.close(io.EOF, nil)
 
 	reqs, batch, err := codec.readBatch()
@@ -210,10 +242,30 @@ type PeerInfo struct {
 		Origin    string
 		Host      string
 	}
+
+	// Caller identifies the client if it was authenticated by the middleware
+	// in front of the server, e.g. by the name of its API key. It is empty for
+	// anonymous clients.
+	Caller string
 }
 
 type peerInfoContextKey struct{}
 
+type callerContextKey struct{}
+
+// WithCaller returns a copy of the HTTP request context which identifies the
+// client as the given caller. HTTP middleware uses it to pass the identity of
+// the clients it authenticated to the server, which reports it in PeerInfo.
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerContextKey{}, caller)
+}
+
+// callerFromContext returns the caller set by WithCaller.
+func callerFromContext(ctx context.Context) string {
+	caller, _ := ctx.Value(callerContextKey{}).(string)
+	return caller
+}
+
 // PeerInfoFromContext returns information about the client's network connection.
 // Use this with the context passed to RPC method handler functions.
 //
@@ -38,6 +38,7 @@ var (
 type serviceRegistry struct {
 	mu       sync.Mutex
 	services map[string]service
+	docs     map[string]MethodDoc // documentation of the methods, by full method name
 }
 
 // service represents a registered object.
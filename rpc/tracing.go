@@ -0,0 +1,104 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/internal/tracing"
+)
+
+const (
+	// requestIDHeader is the HTTP header carrying the request ID. Clients may
+	// set it to correlate their requests with the server logs, otherwise the
+	// server assigns one. It is echoed in the response either way.
+	requestIDHeader = "X-Request-Id"
+
+	// traceParentHeader is the W3C trace context header. Spans of requests
+	// carrying it become part of the client's trace.
+	traceParentHeader = "Traceparent"
+
+	maxRequestIDLength = 128
+)
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the ID of the RPC request being served. All
+// calls of an HTTP batch share the ID of the HTTP request, while the calls
+// received on persistent connections have their own.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok
+}
+
+// withRequestID ensures the context carries a request ID.
+func withRequestID(ctx context.Context) (context.Context, string) {
+	if id, ok := RequestIDFromContext(ctx); ok {
+		return ctx, id
+	}
+	id := newRequestID()
+	return context.WithValue(ctx, requestIDKey{}, id), id
+}
+
+func newRequestID() string {
+	var id [8]byte
+	crand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
+
+// validRequestID reports whether a client supplied request ID is safe to put
+// into logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// httpRequestContext adds the request ID and remote trace parent of an HTTP
+// request to ctx, and reports the request ID in the response headers.
+func httpRequestContext(ctx context.Context, w http.ResponseWriter, r *http.Request) context.Context {
+	id := r.Header.Get(requestIDHeader)
+	if !validRequestID(id) {
+		id = newRequestID()
+	}
+	w.Header().Set(requestIDHeader, id)
+	ctx = context.WithValue(ctx, requestIDKey{}, id)
+
+	if traceparent := r.Header.Get(traceParentHeader); traceparent != "" && tracing.Enabled() {
+		if parent, err := tracing.ContextWithTraceParent(ctx, traceparent); err == nil {
+			ctx = parent
+		}
+	}
+	return ctx
+}
+
+// paramsDigest returns a short hash identifying the parameters of a call
+// without logging them.
+func paramsDigest(params []byte) string {
+	hash := sha256.Sum256(params)
+	return hex.EncodeToString(hash[:8])
+}
@@ -0,0 +1,201 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/internal/tracing"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+type requestIDService struct{}
+
+func (requestIDService) RequestID(ctx context.Context) string {
+	id, _ := RequestIDFromContext(ctx)
+	return id
+}
+
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []*tracing.SpanData
+}
+
+func (r *spanRecorder) ExportSpan(span *tracing.SpanData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, span)
+}
+
+func TestHTTPRequestID(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+	if err := server.RegisterName("rid", requestIDService{}); err != nil {
+		t.Fatal(err)
+	}
+	httpsrv := httptest.NewServer(server)
+	defer httpsrv.Close()
+
+	call := func(id string, body string) (string, string) {
+		req, _ := http.NewRequest(http.MethodPost, httpsrv.URL, strings.NewReader(body))
+		req.Header.Set("content-type", contentType)
+		if id != "" {
+			req.Header.Set(requestIDHeader, id)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.Header.Get(requestIDHeader), strings.TrimSpace(string(data))
+	}
+	// Client supplied IDs are kept, and shared by the calls of a batch.
+	have, resp := call("client-id", `[{"jsonrpc":"2.0","id":1,"method":"rid_requestID"},{"jsonrpc":"2.0","id":2,"method":"rid_requestID"}]`)
+	if want := `[{"jsonrpc":"2.0","id":1,"result":"client-id"},{"jsonrpc":"2.0","id":2,"result":"client-id"}]`; have != "client-id" || resp != want {
+		t.Errorf("response mismatch: header %q, body %s", have, resp)
+	}
+	// Otherwise the server assigns one.
+	for _, id := range []string{"", "bad id", strings.Repeat("a", maxRequestIDLength+1)} {
+		have, resp := call(id, `{"jsonrpc":"2.0","id":1,"method":"rid_requestID"}`)
+		if len(have) != 16 || !strings.Contains(resp, `"result":"`+have+`"`) {
+			t.Errorf("request ID %q: response mismatch: header %q, body %s", id, have, resp)
+		}
+	}
+}
+
+func TestRequestIDPerCall(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+	if err := server.RegisterName("rid", requestIDService{}); err != nil {
+		t.Fatal(err)
+	}
+	client := DialInProc(server)
+	defer client.Close()
+
+	var first, second string
+	if err := client.Call(&first, "rid_requestID"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Call(&second, "rid_requestID"); err != nil {
+		t.Fatal(err)
+	}
+	if first == "" || first == second {
+		t.Errorf("calls on a connection must have distinct request IDs: %q, %q", first, second)
+	}
+}
+
+func TestCallSpans(t *testing.T) {
+	rec := new(spanRecorder)
+	tracing.SetExporter(rec)
+	defer tracing.SetExporter(nil)
+
+	server := newTestServer()
+	defer server.Stop()
+	httpsrv := httptest.NewServer(server)
+	defer httpsrv.Close()
+
+	body := `[{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["x",1]},{"jsonrpc":"2.0","id":2,"method":"test_returnError"}]`
+	req, _ := http.NewRequest(http.MethodPost, httpsrv.URL, strings.NewReader(body))
+	req.Header.Set("content-type", contentType)
+	req.Header.Set(traceParentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.spans) != 2 {
+		t.Fatalf("wrong number of spans: %d", len(rec.spans))
+	}
+	for i, want := range []struct {
+		name string
+		err  bool
+	}{{"test_echo", false}, {"test_returnError", true}} {
+		span := rec.spans[i]
+		if span.Name != want.name || span.Kind != tracing.SpanKindServer || (span.Error != "") != want.err {
+			t.Errorf("span %d mismatch: %+v", i, span)
+		}
+		if span.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || span.ParentID.String() != "00f067aa0ba902b7" {
+			t.Errorf("span %d not linked to remote parent", i)
+		}
+		attrs := make(map[string]interface{})
+		for _, attr := range span.Attributes {
+			attrs[attr.Key] = attr.Value
+		}
+		if attrs["rpc.method"] != want.name || attrs["rpc.service"] != "test" || attrs["rpc.transport"] != "http" || attrs["rpc.request_id"] != resp.Header.Get(requestIDHeader) {
+			t.Errorf("span %d attributes mismatch: %v", i, attrs)
+		}
+	}
+}
+
+func TestSlowQueryLog(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		records []*log.Record
+	)
+	handler := log.Root().GetHandler()
+	defer log.Root().SetHandler(handler)
+	log.Root().SetHandler(log.FuncHandler(func(r *log.Record) error {
+		if r.Msg == "Slow RPC call" {
+			mu.Lock()
+			records = append(records, r)
+			mu.Unlock()
+		}
+		return nil
+	}))
+
+	server := newTestServer()
+	defer server.Stop()
+	server.SetSlowQueryThreshold(50 * time.Millisecond)
+	client := DialInProc(server)
+	defer client.Close()
+
+	if err := client.Call(nil, "test_sleep", 100*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Call(nil, "test_echo", "x", 1); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(records) != 1 {
+		t.Fatalf("wrong number of slow calls logged: %d", len(records))
+	}
+	ctx := make(map[string]interface{})
+	for i := 0; i < len(records[0].Ctx); i += 2 {
+		ctx[records[0].Ctx[i].(string)] = records[0].Ctx[i+1]
+	}
+	if ctx["method"] != "test_sleep" || ctx["params"] != paramsDigest([]byte("[100000000]")) || ctx["size"] != 4 {
+		t.Errorf("log context mismatch: %v", ctx)
+	}
+	if d, _ := ctx["duration"].(time.Duration); d < 100*time.Millisecond {
+		t.Errorf("wrong duration logged: %v", ctx["duration"])
+	}
+	if id, _ := ctx["request"].(string); len(id) != 16 {
+		t.Errorf("wrong request ID logged: %v", ctx["request"])
+	}
+}
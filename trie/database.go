@@ -239,6 +239,30 @@ func (db *Database) Dereference(root common.Hash) error {
 	return nil
 }
 
+// SetFlushHook installs a callback invoked for every trie node persisted to
+// disk, or removes it if nil is passed. It's only supported by hash-based
+// database and will return an error for others.
+func (db *Database) SetFlushHook(hook hashdb.FlushHook) error {
+	hdb, ok := db.backend.(*hashdb.Database)
+	if !ok {
+		return errors.New("not supported")
+	}
+	hdb.SetFlushHook(hook)
+	return nil
+}
+
+// DeferFlush postpones (or resumes) flushing the buffered dirty nodes into
+// disk. It's only supported by path-based database and will return an error
+// for others.
+func (db *Database) DeferFlush(deferred bool) error {
+	pdb, ok := db.backend.(*pathdb.Database)
+	if !ok {
+		return errors.New("not supported")
+	}
+	pdb.DeferFlush(deferred)
+	return nil
+}
+
 // Node retrieves the rlp-encoded node blob with provided node hash. It's
 // only supported by hash-based database and will return an error for others.
 // Note, this function should be deprecated once ETH66 is deprecated.
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"reflect"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/VictoriaMetrics/fastcache"
@@ -65,6 +66,11 @@ type ChildResolver interface {
 	ForEach(node []byte, onChild func(common.Hash))
 }
 
+// FlushHook is invoked for every trie node persisted to disk, right before the
+// containing batch is written. Anything written into the provided writer is
+// committed atomically with the node.
+type FlushHook func(w ethdb.KeyValueWriter, hash common.Hash)
+
 // Config contains the settings for database.
 type Config struct {
 	CleanCacheSize int // Maximum memory allowance (in bytes) for caching clean nodes
@@ -107,6 +113,8 @@ type Database struct {
 	dirtiesSize  common.StorageSize // Storage size of the dirty node cache (exc. metadata)
 	childrenSize common.StorageSize // Storage size of the external children tracking
 
+	hook atomic.Pointer[FlushHook] // Optional callback for persisted nodes (e.g. online pruning)
+
 	lock sync.RWMutex
 }
 
@@ -367,6 +375,7 @@ func (db *Database) Cap(limit common.StorageSize) error {
 		// Fetch the oldest referenced node and push into the batch
 		node := db.dirties[oldest]
 		rawdb.WriteLegacyTrieNode(batch, oldest, node.node)
+		db.onFlush(batch, oldest)
 
 		// If we exceeded the ideal batch size, commit and reset
 		if batch.ValueSize() >= ethdb.IdealBatchSize {
@@ -495,6 +504,7 @@ func (db *Database) commit(hash common.Hash, batch ethdb.Batch, uncacher *cleane
 	}
 	// If we've reached an optimal batch size, commit and start over
 	rawdb.WriteLegacyTrieNode(batch, hash, node.node)
+	db.onFlush(batch, hash)
 	if batch.ValueSize() >= ethdb.IdealBatchSize {
 		if err := batch.Write(); err != nil {
 			return err
@@ -510,6 +520,23 @@ func (db *Database) commit(hash common.Hash, batch ethdb.Batch, uncacher *cleane
 	return nil
 }
 
+// SetFlushHook installs a callback to be invoked for every node persisted from
+// the dirty cache, or removes it if nil is passed.
+func (db *Database) SetFlushHook(hook FlushHook) {
+	if hook == nil {
+		db.hook.Store(nil)
+		return
+	}
+	db.hook.Store(&hook)
+}
+
+// onFlush invokes the flush hook, if any, for a node added to the given batch.
+func (db *Database) onFlush(batch ethdb.KeyValueWriter, hash common.Hash) {
+	if hook := db.hook.Load(); hook != nil {
+		(*hook)(batch, hash)
+	}
+}
+
 // cleaner is a database batch replayer that takes a batch of write operations
 // and cleans up the trie database from anything written to disk.
 type cleaner struct {
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"io"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -135,6 +136,7 @@ type Database struct {
 	diskdb     ethdb.Database           // Persistent storage for matured trie nodes
 	tree       *layerTree               // The group for all known layers
 	freezer    *rawdb.ResettableFreezer // Freezer for storing trie histories, nil possible in tests
+	deferred   atomic.Bool              // Flag if node buffer flushes are postponed
 	lock       sync.RWMutex             // Lock to prevent mutations from happening at the same time
 }
 
@@ -434,6 +436,13 @@ func (db *Database) Initialized(genesisRoot common.Hash) bool {
 	return inited
 }
 
+// DeferFlush postpones (or resumes) flushing the node buffer into disk once
+// it exceeds the memory allowance. The postponed buffer can grow up to twice
+// the allowance, the flush is forced beyond that.
+func (db *Database) DeferFlush(deferred bool) {
+	db.deferred.Store(deferred)
+}
+
 // SetBufferSize sets the node buffer size to the provided value(in bytes).
 func (db *Database) SetBufferSize(size int) error {
 	db.lock.Lock()
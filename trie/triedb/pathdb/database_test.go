@@ -499,6 +499,45 @@ func TestCommit(t *testing.T) {
 	}
 }
 
+func TestDeferFlush(t *testing.T) {
+	tester := newTester(t)
+	defer tester.release()
+
+	extend := func(n int) {
+		for i := 0; i < n; i++ {
+			parent := tester.lastHash()
+			root, nodes, states := tester.generate(parent)
+			if err := tester.db.Update(root, parent, uint64(len(tester.roots)), nodes, states); err != nil {
+				t.Fatalf("Failed to update state changes, err: %v", err)
+			}
+			tester.roots = append(tester.roots, root)
+		}
+	}
+	// Shrink the buffer allowance to the current content, so that the next
+	// layer exceeds it, and start postponing the flushes
+	buffer := tester.db.tree.bottom().buffer
+	if buffer.size == 0 {
+		t.Fatal("Empty node buffer")
+	}
+	buffer.limit = buffer.size
+	tester.db.DeferFlush(true)
+
+	persisted := rawdb.ReadPersistentStateID(tester.db.diskdb)
+	extend(1)
+	if id := rawdb.ReadPersistentStateID(tester.db.diskdb); id != persisted {
+		t.Fatalf("Deferred buffer flushed: persisted id %d, want %d", id, persisted)
+	}
+	// Resume the flushes, the buffer should be persisted with the next layer
+	tester.db.DeferFlush(false)
+	extend(1)
+	if id := rawdb.ReadPersistentStateID(tester.db.diskdb); id <= persisted {
+		t.Fatalf("Buffer not flushed: persisted id %d, want > %d", id, persisted)
+	}
+	if err := tester.verifyState(tester.lastHash()); err != nil {
+		t.Fatalf("State is invalid, err: %v", err)
+	}
+}
+
 func TestJournal(t *testing.T) {
 	tester := newTester(t)
 	defer tester.release()
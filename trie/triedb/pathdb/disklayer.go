@@ -199,6 +199,9 @@ func (dl *diskLayer) commit(bottom *diffLayer, force bool) (*diskLayer, error) {
 	// many nodes cached. The clean cache is inherited from the original
 	// disk layer for reusing.
 	ndl := newDiskLayer(bottom.root, bottom.stateID(), dl.db, dl.cleans, dl.buffer.commit(bottom.nodes))
+	if !force && dl.db.deferred.Load() && ndl.buffer.size <= 2*ndl.buffer.limit {
+		return ndl, nil
+	}
 	err := ndl.buffer.flush(ndl.db.diskdb, ndl.cleans, ndl.id, force)
 	if err != nil {
 		return nil, err